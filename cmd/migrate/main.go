@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"top-up-api/config"
+	"top-up-api/internal/db"
+	"top-up-api/pkg/migrate"
+)
+
+const _usage = `usage: migrate [flags] <command>
+
+commands:
+  up [n]         apply all pending migrations, or the next n
+  down [n]       revert the last applied migration, or the last n
+  status         list migrations and when they were applied
+  baseline <n>   record the migrations up to version n as applied without
+                 running them, for a database created before migrations
+  create <name>  add an empty up and down migration
+  seed           load the sample data, never run by up
+
+flags:
+`
+
+func main() {
+	dir := flag.String("dir", "sql/migrations", "directory of the migration files")
+	seeds := flag.String("seeds", "sql/data", "directory of the seed files")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), _usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// create only writes files, it does not need a database
+	if args[0] == "create" {
+		if len(args) != 2 {
+			log.Fatal("create needs a migration name")
+		}
+		up, down, err := migrate.Create(*dir, args[1])
+		if err != nil {
+			log.Fatalf("failed to create migration: %v", err)
+		}
+		fmt.Printf("created %s\ncreated %s\n", up, down)
+		return
+	}
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+	database, err := db.NewDB(cfg)
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	defer database.Close()
+	sqlDB, err := database.Database.DB()
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+
+	ctx := context.Background()
+	migrator := migrate.New(sqlDB, os.DirFS(*dir))
+	switch args[0] {
+	case "up":
+		runSteps(ctx, "applied", args, 0, migrator.Up)
+	case "down":
+		runSteps(ctx, "reverted", args, 1, migrator.Down)
+	case "baseline":
+		if len(args) != 2 {
+			log.Fatal("baseline needs a migration version")
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 1 {
+			log.Fatalf("invalid migration version %q", args[1])
+		}
+		migrations, err := migrator.Baseline(ctx, version)
+		for _, migration := range migrations {
+			fmt.Printf("baselined %06d_%s\n", migration.Version, migration.Name)
+		}
+		if errors.Is(err, migrate.ErrNoChange) {
+			fmt.Println("no change")
+			return
+		}
+		if err != nil {
+			log.Fatalf("baseline failed: %v", err)
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalf("failed to read migration status: %v", err)
+		}
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%06d_%-40s %s\n", status.Version, status.Name, appliedAt)
+		}
+	case "seed":
+		files, err := migrate.Seed(ctx, sqlDB, os.DirFS(*seeds))
+		if err != nil {
+			log.Fatalf("failed to seed database: %v", err)
+		}
+		for _, file := range files {
+			fmt.Printf("seeded %s\n", file)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// runSteps parses the optional step count of up and down, runs the command and
+// prints the migrations it went through.
+func runSteps(ctx context.Context, action string, args []string, steps int, run func(context.Context, int) ([]migrate.Migration, error)) {
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			log.Fatalf("invalid number of steps %q", args[1])
+		}
+		steps = n
+	}
+
+	migrations, err := run(ctx, steps)
+	for _, migration := range migrations {
+		fmt.Printf("%s %06d_%s\n", action, migration.Version, migration.Name)
+	}
+	if errors.Is(err, migrate.ErrNoChange) {
+		fmt.Println("no change")
+		return
+	}
+	if err != nil {
+		log.Fatalf("migration failed: %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+	"top-up-api/config"
+	"top-up-api/internal/db"
+	"top-up-api/internal/repository"
+	"top-up-api/internal/schema"
+	"top-up-api/internal/service"
+)
+
+const _usage = `usage: settlement [flags]
+
+writes the settlement report of the successful orders per day, provider and
+supplier as CSV, yesterday's by default
+
+flags:
+`
+
+func main() {
+	cfg, err := config.NewConfig()
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+
+	yesterday := time.Now().In(cfg.Settlement.Location()).AddDate(0, 0, -1).Format("2006-01-02")
+	from := flag.String("from", yesterday, "first day of the report, YYYY-MM-DD")
+	to := flag.String("to", "", "last day of the report, YYYY-MM-DD, the from day by default")
+	out := flag.String("out", "", "file to write the report to instead of stdout")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), _usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *to == "" {
+		to = from
+	}
+
+	database, err := db.NewDB(cfg)
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	defer database.Close()
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("failed to create report file: %v", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	settlementService := service.NewSettlementService(repository.NewSettlementRepository(database.Database), repository.NewProviderRepository(database.Database), cfg.Settlement)
+	request := schema.SettlementReportRequest{From: *from, To: *to}
+	if err := settlementService.ExportSettlementReport(context.Background(), w, request); err != nil {
+		log.Fatalf("failed to export settlement report: %v", err)
+	}
+}
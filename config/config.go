@@ -2,6 +2,11 @@ package config
 
 import (
 	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+	// Settlement timezones are known without the zoneinfo of the host
+	_ "time/tzdata"
 
 	"github.com/spf13/viper"
 )
@@ -9,15 +14,26 @@ import (
 type (
 	// Config -.
 	Config struct {
-		Env      string `mapstructure:"env"`
-		App      `mapstructure:"app"`
-		HTTP     `mapstructure:"http"`
-		Log      `mapstructure:"logger"`
-		Postgres `mapstructure:"postgres"`
-		Redis    `mapstructure:"redis"`
-		JWT      `mapstructure:"jwt"`
-		Kafka    `mapstructure:"kafka"`
-		Grpc     `mapstructure:"grpc"`
+		Env              string `mapstructure:"env"`
+		App              `mapstructure:"app"`
+		HTTP             `mapstructure:"http"`
+		Log              `mapstructure:"logger"`
+		Postgres         `mapstructure:"postgres"`
+		Redis            `mapstructure:"redis"`
+		JWT              `mapstructure:"jwt"`
+		Kafka            `mapstructure:"kafka"`
+		Grpc             `mapstructure:"grpc"`
+		Outbox           `mapstructure:"outbox"`
+		Webhook          `mapstructure:"webhook"`
+		ProviderDispatch `mapstructure:"provider_dispatch"`
+		ProviderCallback `mapstructure:"provider_callback"`
+		Admin            `mapstructure:"admin"`
+		Payment          `mapstructure:"payment"`
+		Idempotency      `mapstructure:"idempotency"`
+		OrderExpiry      `mapstructure:"order_expiry"`
+		Reconciliation   `mapstructure:"reconciliation"`
+		Settlement       `mapstructure:"settlement"`
+		OrderBatch       `mapstructure:"order_batch"`
 	}
 
 	// App -.
@@ -75,12 +91,129 @@ type (
 	Grpc struct {
 		Port       string `mapstructure:"port"`
 		GrpcClient `mapstructure:"client"`
+		GrpcTLS    `mapstructure:"tls"`
 	}
 
 	GrpcClient struct {
 		Auth     string `mapstructure:"auth_url"`
 		Provider string `mapstructure:"provider_url"`
 	}
+
+	// GrpcTLS -.
+	GrpcTLS struct {
+		CertFile     string `mapstructure:"cert_file"`
+		KeyFile      string `mapstructure:"key_file"`
+		ClientCAFile string `mapstructure:"client_ca_file"`
+	}
+
+	// ProviderDispatch -.
+	ProviderDispatch struct {
+		MaxAttempts    int           `mapstructure:"max_attempts"`
+		RetryBackoff   time.Duration `mapstructure:"retry_backoff"`
+		RequestTimeout time.Duration `mapstructure:"request_timeout"`
+		ReloadInterval time.Duration `mapstructure:"reload_interval"`
+		CircuitBreaker `mapstructure:"circuit_breaker"`
+	}
+
+	// ProviderCallback -.
+	ProviderCallback struct {
+		SignatureTolerance time.Duration `mapstructure:"signature_tolerance"`
+	}
+
+	// CircuitBreaker -.
+	CircuitBreaker struct {
+		WindowSize           int           `mapstructure:"window_size"`
+		MinRequests          int           `mapstructure:"min_requests"`
+		FailureRateThreshold float64       `mapstructure:"failure_rate_threshold"`
+		SlowCallThreshold    time.Duration `mapstructure:"slow_call_threshold"`
+		OpenTimeout          time.Duration `mapstructure:"open_timeout"`
+		HalfOpenProbes       int           `mapstructure:"half_open_probes"`
+	}
+
+	// Admin -.
+	Admin struct {
+		Token string `mapstructure:"token"`
+	}
+
+	// Idempotency -.
+	Idempotency struct {
+		TTL         time.Duration `mapstructure:"ttl"`
+		LockTimeout time.Duration `mapstructure:"lock_timeout"`
+	}
+
+	// Payment -.
+	Payment struct {
+		Token          string   `mapstructure:"token"`
+		MessageSecret  string   `mapstructure:"message_secret"`
+		ClientNames    []string `mapstructure:"client_names"`
+		CreateURL      string   `mapstructure:"create_url"`
+		UpdateURL      string   `mapstructure:"update_url"`
+		CallbackURL    string   `mapstructure:"callback_url"`
+		BatchCreateURL string   `mapstructure:"batch_create_url"`
+		BatchUpdateURL string   `mapstructure:"batch_update_url"`
+	}
+
+	// OrderExpiry -.
+	OrderExpiry struct {
+		PendingTimeout time.Duration `mapstructure:"pending_timeout"`
+		SweepInterval  time.Duration `mapstructure:"sweep_interval"`
+		BatchSize      int           `mapstructure:"batch_size"`
+	}
+
+	// Reconciliation -.
+	Reconciliation struct {
+		SLA           time.Duration `mapstructure:"sla"`
+		EscalateAfter time.Duration `mapstructure:"escalate_after"`
+		Interval      time.Duration `mapstructure:"interval"`
+		BatchSize     int           `mapstructure:"batch_size"`
+	}
+
+	// OrderBatch -.
+	OrderBatch struct {
+		DispatchInterval time.Duration `mapstructure:"dispatch_interval"`
+		DispatchRate     int           `mapstructure:"dispatch_rate"`
+	}
+
+	// Settlement -.
+	Settlement struct {
+		Timezone  string                      `mapstructure:"timezone"`
+		Providers map[string]SettlementLayout `mapstructure:"providers"`
+	}
+
+	// SettlementLayout names the header columns of the settlement files of a
+	// provider, and the statuses of the rows it fulfilled.
+	SettlementLayout struct {
+		Delimiter       string   `mapstructure:"delimiter"`
+		OrderIDColumn   string   `mapstructure:"order_id_column"`
+		AmountColumn    string   `mapstructure:"amount_column"`
+		StatusColumn    string   `mapstructure:"status_column"`
+		SuccessStatuses []string `mapstructure:"success_statuses"`
+	}
+
+	// Outbox -.
+	Outbox struct {
+		PollInterval time.Duration `mapstructure:"poll_interval"`
+		BatchSize    int           `mapstructure:"batch_size"`
+		MaxAttempts  int           `mapstructure:"max_attempts"`
+		BaseBackoff  time.Duration `mapstructure:"base_backoff"`
+		MaxBackoff   time.Duration `mapstructure:"max_backoff"`
+		HTTPTimeout  time.Duration `mapstructure:"http_timeout"`
+		Lease        time.Duration `mapstructure:"lease"`
+	}
+
+	// Webhook -.
+	Webhook struct {
+		PollInterval time.Duration `mapstructure:"poll_interval"`
+		BatchSize    int           `mapstructure:"batch_size"`
+		MaxAttempts  int           `mapstructure:"max_attempts"`
+		BaseBackoff  time.Duration `mapstructure:"base_backoff"`
+		MaxBackoff   time.Duration `mapstructure:"max_backoff"`
+		HTTPTimeout  time.Duration `mapstructure:"http_timeout"`
+		Lease        time.Duration `mapstructure:"lease"`
+		// AllowInsecureURLs lets subscriptions use http URLs and internal
+		// addresses, it is meant for local development only.
+		AllowInsecureURLs bool `mapstructure:"allow_insecure_urls"`
+	}
 )
 
 func (p *Postgres) DSN() string {
@@ -106,5 +239,31 @@ func NewConfig() (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	if _, err := time.LoadLocation(cfg.Settlement.Timezone); err != nil {
+		return nil, fmt.Errorf("invalid settlement timezone: %w", err)
+	}
+	for providerCode, layout := range cfg.Settlement.Providers {
+		if utf8.RuneCountInString(layout.Delimiter) > 1 {
+			return nil, fmt.Errorf("invalid settlement file delimiter %q of provider %s, it must be a single character", layout.Delimiter, providerCode)
+		}
+	}
 	return cfg, nil
 }
+
+// Layout returns the settlement file layout configured for the provider, the
+// zero layout when there is none. Provider codes are matched ignoring case,
+// the configuration loader lowercases map keys.
+func (s Settlement) Layout(providerCode string) SettlementLayout {
+	return s.Providers[strings.ToLower(providerCode)]
+}
+
+// Location returns the timezone settlement days start at midnight in, UTC when
+// none is set. NewConfig rejects an unknown timezone.
+func (s Settlement) Location() *time.Location {
+	location, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
@@ -15,9 +15,195 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/order/batch": {
+            "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Create an order for each line of the batch, all of them paid with a single payment of the batch total.\nA batch has at most 1000 lines. Batch orders get the cashback of their sku, promotions and the wallet don't apply to them.\nA batch with invalid lines is rejected with the errors of every invalid line, lines are numbered from 1.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "order"
+                ],
+                "summary": "Create order batch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Replays the first response when the request is retried with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Order batch request",
+                        "name": "orderBatchRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/top-up-api_internal_schema.OrderBatchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/top-up-api_internal_schema.OrderBatchResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/order/batch/confirm": {
+            "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Payment result of an order batch, only accepted from the payment service with its service token.\nThe total must match the batch and a payment reference can only pay one batch. The orders of a paid batch are sent to their providers at a limited rate.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "order"
+                ],
+                "summary": "Confirm order batch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Replays the first response when the request is retried with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Order batch confirm request",
+                        "name": "orderBatchConfirmRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/top-up-api_internal_schema.OrderBatchConfirmRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/top-up-api_internal_schema.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/order/batch/upload": {
+            "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Create an order batch from a CSV file with a phone_number and a sku_id column, each row after the header is a line of the batch.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "order"
+                ],
+                "summary": "Upload order batch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Replays the first response when the request is retried with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "CSV file of the batch lines",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/top-up-api_internal_schema.OrderBatchResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/order/batch/{batch_id}": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Get an order batch of the authenticated user with the status of its payment, the number of its orders in each status and the result of each line.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "order"
+                ],
+                "summary": "Get order batch",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Batch ID",
+                        "name": "batch_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/top-up-api_internal_schema.OrderBatchResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/order/confirm": {
             "post": {
-                "description": "Confirm order",
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Payment result of an order, only accepted from the payment service with its service token.\nThe amounts must match the order and a payment reference can only confirm one order.",
                 "consumes": [
                     "application/json"
                 ],
@@ -29,6 +215,12 @@ const docTemplate = `{
                 ],
                 "summary": "Confirm order",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Replays the first response when the request is retried with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
                     {
                         "description": "Order confirm request",
                         "name": "orderConfirmRequest",
@@ -56,7 +248,7 @@ const docTemplate = `{
                         "Bearer": []
                     }
                 ],
-                "description": "Create order",
+                "description": "Create order, wallet_amount of the total is paid from the user's wallet and the payment service collects the rest.\nAn optional voucher_code applies a voucher promotion, the promotions applied to the order are returned with it.\nphone_number must be a Vietnamese mobile number of the carrier the sku is for, it is stored in its national form.",
                 "consumes": [
                     "application/json"
                 ],
@@ -68,6 +260,12 @@ const docTemplate = `{
                 ],
                 "summary": "Create order",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Replays the first response when the request is retried with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
                     {
                         "description": "Order request",
                         "name": "orderRequest",
@@ -90,7 +288,7 @@ const docTemplate = `{
         },
         "/order/update-status": {
             "patch": {
-                "description": "Update order status",
+                "description": "Status callback of the provider an order was sent to. It is signed with the provider's callback secret:\nX-Provider-Signature is \"t=\u003cunix timestamp\u003e,v1=\u003chex HMAC-SHA256 of \"\u003ctimestamp\u003e.\u003cnonce\u003e.\u003cbody\u003e\"\u003e\" and a nonce is only accepted once.",
                 "consumes": [
                     "application/json"
                 ],
@@ -101,6 +299,33 @@ const docTemplate = `{
                     "order"
                 ],
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Provider code",
+                        "name": "X-Provider-Code",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique callback nonce",
+                        "name": "X-Provider-Nonce",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Callback signature",
+                        "name": "X-Provider-Signature",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replays the first response when the request is retried with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
                     {
                         "description": "Order update request",
                         "name": "orderUpdateRequest",
@@ -121,104 +346,137 @@ const docTemplate = `{
                 }
             }
         },
-        "/purchase-history/{user_id}": {
+        "/order/{order_id}": {
             "get": {
                 "security": [
                     {
                         "Bearer": []
                     }
                 ],
-                "description": "Get purchase history",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Get an order of the authenticated user with its current status",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "purchase-history"
+                    "order"
                 ],
-                "summary": "Get purchase history",
+                "summary": "Get order",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "User ID",
-                        "name": "user_id",
+                        "description": "Order ID",
+                        "name": "order_id",
                         "in": "path",
                         "required": true
                     },
                     {
                         "type": "integer",
-                        "description": "Page number",
-                        "name": "page",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Page size",
-                        "name": "pageSize",
-                        "in": "query"
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/top-up-api_internal_schema.PaginationResponse"
+                            "$ref": "#/definitions/top-up-api_internal_schema.OrderDetailResponse"
                         }
                     }
                 }
             }
         },
-        "/sku": {
+        "/order/{order_id}/events": {
             "get": {
-                "description": "Get card details grouped by supplier",
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Stream the status changes of an order of the authenticated user as server-sent events. The first event carries the current status and the stream ends once the order succeeds or fails.",
+                "produces": [
+                    "text/event-stream"
+                ],
                 "tags": [
-                    "sku"
+                    "order"
+                ],
+                "summary": "Watch order status",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Order ID",
+                        "name": "order_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    }
                 ],
-                "summary": "Get card details grouped by supplier",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/top-up-api_internal_schema.SkusGroupBySupplier"
+                            "$ref": "#/definitions/top-up-api_internal_schema.OrderStatusUpdate"
                         }
                     }
                 }
             }
         },
-        "/sku/{supplierCode}": {
+        "/order/{order_id}/timeline": {
             "get": {
-                "description": "Get sku details by supplier code",
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Get every status change of an order of the authenticated user with its source",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "sku"
+                    "order"
                 ],
-                "summary": "Get sku details by supplier code",
+                "summary": "Get order timeline",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Supplier code",
-                        "name": "supplierCode",
+                        "type": "integer",
+                        "description": "Order ID",
+                        "name": "order_id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/top-up-api_internal_schema.SkuResponse"
-                            }
+                            "$ref": "#/definitions/top-up-api_internal_schema.OrderTimelineResponse"
                         }
                     }
                 }
             }
         },
-        "/supplier": {
+        "/purchase-history/{user_id}": {
             "get": {
-                "description": "Get supplier",
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Get purchase history",
                 "consumes": [
                     "application/json"
                 ],
@@ -226,9 +484,99 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "supplier"
+                    "purchase-history"
                 ],
-                "summary": "Get supplier",
+                "summary": "Get purchase history",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/top-up-api_internal_schema.PaginationResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/sku": {
+            "get": {
+                "description": "Get card details grouped by supplier",
+                "tags": [
+                    "sku"
+                ],
+                "summary": "Get card details grouped by supplier",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/top-up-api_internal_schema.SkusGroupBySupplier"
+                        }
+                    }
+                }
+            }
+        },
+        "/sku/{supplierCode}": {
+            "get": {
+                "description": "Get sku details by supplier code",
+                "tags": [
+                    "sku"
+                ],
+                "summary": "Get sku details by supplier code",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Supplier code",
+                        "name": "supplierCode",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/top-up-api_internal_schema.SkuResponse"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/supplier": {
+            "get": {
+                "description": "Get supplier",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "supplier"
+                ],
+                "summary": "Get supplier",
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -241,37 +589,630 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "top-up-api_internal_model.PurchaseHistoryStatus": {
-            "type": "string",
-            "enum": [
-                "pending",
-                "confirm",
-                "success",
-                "failed"
-            ],
-            "x-enum-varnames": [
-                "PurchaseHistoryStatusPending",
-                "PurchaseHistoryStatusConfirm",
-                "PurchaseHistoryStatusSuccess",
-                "PurchaseHistoryStatusFailed"
-            ]
         },
-        "top-up-api_internal_model.SupplierStatus": {
-            "type": "string",
-            "enum": [
-                "active",
-                "inactive"
-            ],
-            "x-enum-varnames": [
-                "SupplierStatusActive",
-                "SupplierStatusInactive"
-            ]
+        "/supplier/suggest": {
+            "get": {
+                "description": "Supplier of the carrier a Vietnamese mobile number belongs to, told by the number prefix.\nNumbers ported to another carrier keep their prefix and are suggested the carrier they left.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "supplier"
+                ],
+                "summary": "Suggest supplier",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Phone number, with or without the +84 country prefix",
+                        "name": "phone_number",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/top-up-api_internal_schema.SupplierSuggestionResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/wallet/{user_id}": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Balance of the user's wallet, credited with cashback and spent on orders",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "wallet"
+                ],
+                "summary": "Get wallet balance",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/top-up-api_internal_schema.WalletBalanceResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/wallet/{user_id}/statement": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Movements of the user's wallet, newest first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "wallet"
+                ],
+                "summary": "Get wallet statement",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/top-up-api_internal_schema.PaginationResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{user_id}/deliveries/{id}/redeliver": {
+            "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Queue a delivery of a webhook subscription of the user again with a fresh attempt budget",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhook"
+                ],
+                "summary": "Redeliver webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Delivery ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replays the first response when the request is retried with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/top-up-api_internal_schema.WebhookDeliveryResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{user_id}/subscriptions": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Webhook subscriptions of the user",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhook"
+                ],
+                "summary": "Get webhook subscriptions",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/top-up-api_internal_schema.WebhookSubscriptionResponse"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Subscribe an endpoint to the order.succeeded and order.failed events of the user's orders.\nThe signing secret is only returned here: the X-Webhook-Signature header of a delivery is \"t=\u003cunix timestamp\u003e,v1=\u003chex HMAC-SHA256 of \"\u003ctimestamp\u003e.\u003cbody\u003e\"\u003e\".",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhook"
+                ],
+                "summary": "Create webhook subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replays the first response when the request is retried with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Webhook subscription request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/top-up-api_internal_schema.WebhookSubscriptionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/top-up-api_internal_schema.WebhookSubscriptionCreatedResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{user_id}/subscriptions/{id}": {
+            "put": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Replace a webhook subscription of the user, its signing secret is kept",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhook"
+                ],
+                "summary": "Update webhook subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replays the first response when the request is retried with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Webhook subscription request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/top-up-api_internal_schema.WebhookSubscriptionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/top-up-api_internal_schema.WebhookSubscriptionResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Delete a webhook subscription of the user, its pending deliveries end up failed",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhook"
+                ],
+                "summary": "Delete webhook subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/top-up-api_internal_schema.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{user_id}/subscriptions/{id}/deliveries": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Delivery log of a webhook subscription of the user, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhook"
+                ],
+                "summary": "Get webhook deliveries",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/top-up-api_internal_schema.PaginationResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "top-up-api_internal_model.OrderStatusEventSource": {
+            "type": "string",
+            "enum": [
+                "http",
+                "grpc",
+                "kafka",
+                "provider_callback",
+                "dispatcher",
+                "expiry_sweeper",
+                "reconciler",
+                "manual_review",
+                "admin"
+            ],
+            "x-enum-varnames": [
+                "OrderStatusEventSourceHTTP",
+                "OrderStatusEventSourceGRPC",
+                "OrderStatusEventSourceKafka",
+                "OrderStatusEventSourceProviderCallback",
+                "OrderStatusEventSourceDispatcher",
+                "OrderStatusEventSourceExpirySweeper",
+                "OrderStatusEventSourceReconciler",
+                "OrderStatusEventSourceManualReview",
+                "OrderStatusEventSourceAdmin"
+            ]
+        },
+        "top-up-api_internal_model.PurchaseHistoryStatus": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "confirm",
+                "success",
+                "failed",
+                "expired"
+            ],
+            "x-enum-varnames": [
+                "PurchaseHistoryStatusPending",
+                "PurchaseHistoryStatusConfirm",
+                "PurchaseHistoryStatusSuccess",
+                "PurchaseHistoryStatusFailed",
+                "PurchaseHistoryStatusExpired"
+            ]
+        },
+        "top-up-api_internal_model.SupplierStatus": {
+            "type": "string",
+            "enum": [
+                "active",
+                "inactive"
+            ],
+            "x-enum-varnames": [
+                "SupplierStatusActive",
+                "SupplierStatusInactive"
+            ]
+        },
+        "top-up-api_internal_model.WebhookDeliveryStatus": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "delivered",
+                "failed"
+            ],
+            "x-enum-varnames": [
+                "WebhookDeliveryStatusPending",
+                "WebhookDeliveryStatusDelivered",
+                "WebhookDeliveryStatusFailed"
+            ]
+        },
+        "top-up-api_internal_schema.AppliedPromotion": {
+            "type": "object",
+            "properties": {
+                "cash_back_value": {
+                    "type": "integer"
+                },
+                "code": {
+                    "type": "string"
+                },
+                "promotion_id": {
+                    "type": "integer"
+                },
+                "released": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "top-up-api_internal_schema.OrderBatchConfirmRequest": {
+            "type": "object",
+            "required": [
+                "payment_reference"
+            ],
+            "properties": {
+                "batch_id": {
+                    "type": "integer"
+                },
+                "payment_reference": {
+                    "type": "string",
+                    "maxLength": 128
+                },
+                "status": {
+                    "$ref": "#/definitions/top-up-api_internal_model.PurchaseHistoryStatus"
+                },
+                "total_price": {
+                    "type": "integer"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "top-up-api_internal_schema.OrderBatchLine": {
+            "type": "object",
+            "properties": {
+                "phone_number": {
+                    "type": "string"
+                },
+                "sku_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "top-up-api_internal_schema.OrderBatchLineResponse": {
+            "type": "object",
+            "properties": {
+                "cash_back_value": {
+                    "type": "integer"
+                },
+                "line": {
+                    "type": "integer"
+                },
+                "order_id": {
+                    "type": "integer"
+                },
+                "phone_number": {
+                    "type": "string"
+                },
+                "sku_id": {
+                    "type": "integer"
+                },
+                "status": {
+                    "$ref": "#/definitions/top-up-api_internal_model.PurchaseHistoryStatus"
+                },
+                "total_price": {
+                    "type": "integer"
+                }
+            }
+        },
+        "top-up-api_internal_schema.OrderBatchProgress": {
+            "type": "object",
+            "properties": {
+                "confirm": {
+                    "type": "integer"
+                },
+                "expired": {
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "pending": {
+                    "type": "integer"
+                },
+                "success": {
+                    "type": "integer"
+                }
+            }
+        },
+        "top-up-api_internal_schema.OrderBatchRequest": {
+            "type": "object",
+            "properties": {
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/top-up-api_internal_schema.OrderBatchLine"
+                    }
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "top-up-api_internal_schema.OrderBatchResponse": {
+            "type": "object",
+            "properties": {
+                "batch_id": {
+                    "type": "integer"
+                },
+                "cash_back_value": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "line_count": {
+                    "type": "integer"
+                },
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/top-up-api_internal_schema.OrderBatchLineResponse"
+                    }
+                },
+                "progress": {
+                    "$ref": "#/definitions/top-up-api_internal_schema.OrderBatchProgress"
+                },
+                "status": {
+                    "$ref": "#/definitions/top-up-api_internal_model.PurchaseHistoryStatus"
+                },
+                "total_price": {
+                    "type": "integer"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
         },
         "top-up-api_internal_schema.OrderConfirmRequest": {
             "type": "object",
+            "required": [
+                "payment_reference"
+            ],
             "properties": {
                 "cash_back_value": {
                     "type": "integer"
@@ -279,6 +1220,10 @@ const docTemplate = `{
                 "order_id": {
                     "type": "integer"
                 },
+                "payment_reference": {
+                    "type": "string",
+                    "maxLength": 128
+                },
                 "phone_number": {
                     "type": "string"
                 },
@@ -296,8 +1241,58 @@ const docTemplate = `{
                 }
             }
         },
+        "top-up-api_internal_schema.OrderDetailResponse": {
+            "type": "object",
+            "properties": {
+                "batch_id": {
+                    "type": "integer"
+                },
+                "batch_line": {
+                    "type": "integer"
+                },
+                "cash_back_value": {
+                    "type": "integer"
+                },
+                "confirmed_at": {
+                    "type": "string"
+                },
+                "order_id": {
+                    "type": "integer"
+                },
+                "phone_number": {
+                    "type": "string"
+                },
+                "promotions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/top-up-api_internal_schema.AppliedPromotion"
+                    }
+                },
+                "sku": {
+                    "$ref": "#/definitions/top-up-api_internal_schema.SkuResponse"
+                },
+                "status": {
+                    "$ref": "#/definitions/top-up-api_internal_model.PurchaseHistoryStatus"
+                },
+                "total_price": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                },
+                "wallet_amount": {
+                    "type": "integer"
+                }
+            }
+        },
         "top-up-api_internal_schema.OrderRequest": {
             "type": "object",
+            "required": [
+                "phone_number"
+            ],
             "properties": {
                 "phone_number": {
                     "type": "string"
@@ -307,12 +1302,24 @@ const docTemplate = `{
                 },
                 "user_id": {
                     "type": "integer"
+                },
+                "voucher_code": {
+                    "type": "string"
+                },
+                "wallet_amount": {
+                    "type": "integer"
                 }
             }
         },
         "top-up-api_internal_schema.OrderResponse": {
             "type": "object",
             "properties": {
+                "batch_id": {
+                    "type": "integer"
+                },
+                "batch_line": {
+                    "type": "integer"
+                },
                 "cash_back_value": {
                     "type": "integer"
                 },
@@ -322,8 +1329,11 @@ const docTemplate = `{
                 "phone_number": {
                     "type": "string"
                 },
-                "rand_provider_weight": {
-                    "type": "integer"
+                "promotions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/top-up-api_internal_schema.AppliedPromotion"
+                    }
                 },
                 "sku": {
                     "$ref": "#/definitions/top-up-api_internal_schema.SkuResponse"
@@ -336,6 +1346,60 @@ const docTemplate = `{
                 },
                 "user_id": {
                     "type": "integer"
+                },
+                "wallet_amount": {
+                    "type": "integer"
+                }
+            }
+        },
+        "top-up-api_internal_schema.OrderStatusEventResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "previous_status": {
+                    "$ref": "#/definitions/top-up-api_internal_model.PurchaseHistoryStatus"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "source": {
+                    "$ref": "#/definitions/top-up-api_internal_model.OrderStatusEventSource"
+                },
+                "status": {
+                    "$ref": "#/definitions/top-up-api_internal_model.PurchaseHistoryStatus"
+                }
+            }
+        },
+        "top-up-api_internal_schema.OrderStatusUpdate": {
+            "type": "object",
+            "properties": {
+                "order_id": {
+                    "type": "integer"
+                },
+                "previous_status": {
+                    "$ref": "#/definitions/top-up-api_internal_model.PurchaseHistoryStatus"
+                },
+                "status": {
+                    "$ref": "#/definitions/top-up-api_internal_model.PurchaseHistoryStatus"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "top-up-api_internal_schema.OrderTimelineResponse": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/top-up-api_internal_schema.OrderStatusEventResponse"
+                    }
+                },
+                "order_id": {
+                    "type": "integer"
                 }
             }
         },
@@ -474,6 +1538,157 @@ const docTemplate = `{
                     "$ref": "#/definitions/top-up-api_internal_model.SupplierStatus"
                 }
             }
+        },
+        "top-up-api_internal_schema.SupplierSuggestionResponse": {
+            "type": "object",
+            "properties": {
+                "phone_number": {
+                    "type": "string"
+                },
+                "supplier": {
+                    "$ref": "#/definitions/top-up-api_internal_schema.SupplierResponse"
+                }
+            }
+        },
+        "top-up-api_internal_schema.WalletBalanceResponse": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "type": "integer"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "top-up-api_internal_schema.WebhookDeliveryResponse": {
+            "type": "object",
+            "properties": {
+                "aggregate_id": {
+                    "type": "integer"
+                },
+                "attempts": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "delivered_at": {
+                    "type": "string"
+                },
+                "event_type": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "next_attempt_at": {
+                    "type": "string"
+                },
+                "payload": {
+                    "type": "string"
+                },
+                "response_status": {
+                    "type": "integer"
+                },
+                "status": {
+                    "$ref": "#/definitions/top-up-api_internal_model.WebhookDeliveryStatus"
+                },
+                "subscription_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "top-up-api_internal_schema.WebhookSubscriptionCreatedResponse": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "event_types": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "secret": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "top-up-api_internal_schema.WebhookSubscriptionRequest": {
+            "type": "object",
+            "required": [
+                "event_types",
+                "name",
+                "url"
+            ],
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "event_types": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 100
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "top-up-api_internal_schema.WebhookSubscriptionResponse": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "event_types": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
         }
     },
     "securityDefinitions": {
@@ -15,7 +15,10 @@ import (
 	grpcServers "top-up-api/internal/grpc/server"
 	"top-up-api/internal/kafka/consumer"
 	"top-up-api/internal/service"
+	"top-up-api/internal/worker"
 	"top-up-api/pkg/httpserver"
+	"top-up-api/pkg/idempotency"
+	kfk "top-up-api/pkg/kafka"
 	"top-up-api/pkg/logger"
 	"top-up-api/pkg/redis"
 	"top-up-api/pkg/validator"
@@ -51,8 +54,14 @@ func Run(cfg *config.Config) {
 	redis := redis.NewRedis(cfg.Redis)
 	logger.Info(fmt.Sprintf("redis connected to %s", redis))
 
+	// Kafka producer
+	producer, err := kfk.NewProducerFactory(&cfg.Kafka).CreateProducer()
+	if err != nil {
+		logger.Error(fmt.Errorf("app - Run - kfk.CreateProducer: %w", err))
+	}
+
 	// Services
-	services := service.NewContainer(db.Database, logger, redis, validator, cfg, *grpcClients)
+	services := service.NewContainer(db.Database, logger, redis, validator, cfg, grpcClients, producer)
 
 	// Create gRPC server
 	lis, err := net.Listen("tcp", ":"+cfg.Grpc.Port)
@@ -60,19 +69,33 @@ func Run(cfg *config.Config) {
 		logger.Error(fmt.Errorf("app - Run - net.Listen: %w", err))
 		os.Exit(1)
 	}
-	grpcServer := grpc.NewServer()
-	grpcServices := grpcServers.NewGRPCServiceServer(services)
+	grpcServerOptions, err := grpcServers.NewServerOptions(cfg.Grpc.GrpcTLS)
+	if err != nil {
+		logger.Error(fmt.Errorf("app - Run - grpcServers.NewServerOptions: %w", err))
+		os.Exit(1)
+	}
+	// Idempotency keys of the HTTP and gRPC APIs
+	idempotencyStore := idempotency.NewStore(redis, cfg.Idempotency.TTL, cfg.Idempotency.LockTimeout)
+
+	grpcServerOptions = append(grpcServerOptions, grpc.UnaryInterceptor(grpcServers.NewIdempotencyInterceptor(idempotencyStore)))
+	grpcServer := grpc.NewServer(grpcServerOptions...)
+	grpcServices := grpcServers.NewGRPCServiceServer(services, grpcClients.AuthGRPCClient, cfg.Payment)
 	grpcServices.Register(grpcServer)
 	go grpcServer.Serve(lis)
 
 	// Kafka consumers
-	consumers := consumer.NewConsumers(&cfg.Kafka, services)
+	consumers := consumer.NewConsumers(&cfg.Kafka, cfg.Payment, services)
 	kafkaCtx, kafkaContextCancel := context.WithCancel(context.Background())
 	consumers.StartKafkaConsumers(kafkaCtx)
 
+	// Background workers
+	workers := worker.NewWorkers(cfg, services)
+	workerCtx, workerContextCancel := context.WithCancel(context.Background())
+	workers.StartWorkers(workerCtx)
+
 	// HTTP Server
 	handler := gin.Default()
-	controller.NewRouter(handler, services, grpcClients)
+	controller.NewRouter(handler, services, grpcClients, idempotencyStore, cfg.Admin, cfg.Payment)
 
 	httpServer := httpserver.New(handler, httpserver.Port(cfg.HTTP.Port))
 	// Waiting signal
@@ -109,6 +132,21 @@ func Run(cfg *config.Config) {
 		logger.Error(fmt.Errorf("app - Run - services.CloseKafka: %w", err))
 	}
 
+	// Background workers
+	workerContextCancel()
+	workers.Wait()
+
+	// Provider dispatches still in flight
+	services.OrderService.WaitForDispatches()
+
+	// Kafka producer
+	if producer != nil {
+		err = producer.Close()
+		if err != nil {
+			logger.Error(fmt.Errorf("app - Run - producer.Close: %w", err))
+		}
+	}
+
 	// Database connection
 	err = db.Close()
 	if err != nil {
@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"top-up-api/internal/mapper"
+	"top-up-api/internal/service"
+	"top-up-api/pkg/logger"
+	"top-up-api/pkg/validator"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Admin routes are served under /v1/admin, outside the public Swagger docs.
+
+type AdminRouter struct {
+	orderService      service.OrderService
+	supplierService   service.SupplierService
+	skuService        service.SkuService
+	cashBackService   service.CashBackService
+	providerService   service.ProviderService
+	webhookService    service.WebhookService
+	promotionService  service.PromotionService
+	settlementService service.SettlementService
+	logger            logger.Interface
+	validator         validator.Interface
+}
+
+func NewAdminRouter(handler *gin.RouterGroup, services *service.Container) {
+	h := &AdminRouter{
+		orderService:      services.OrderService,
+		supplierService:   services.SupplierService,
+		skuService:        services.SkuService,
+		cashBackService:   services.CashBackService,
+		providerService:   services.ProviderService,
+		webhookService:    services.WebhookService,
+		promotionService:  services.PromotionService,
+		settlementService: services.SettlementService,
+		logger:            services.Logger,
+		validator:         services.Validator,
+	}
+	providerRoutes := handler.Group("/providers")
+	{
+		providerRoutes.GET("/health", h.GetProviderHealth)
+		providerRoutes.POST("/reload", h.ReloadProviders)
+		providerRoutes.POST("", h.CreateProvider)
+		providerRoutes.PUT("/:id", h.UpdateProvider)
+		providerRoutes.DELETE("/:id", h.DeleteProvider)
+		providerRoutes.POST("/:id/callback-secret", h.RotateProviderCallbackSecret)
+	}
+	supplierRoutes := handler.Group("/suppliers")
+	{
+		supplierRoutes.POST("", h.CreateSupplier)
+		supplierRoutes.PUT("/:id", h.UpdateSupplier)
+		supplierRoutes.DELETE("/:id", h.DeleteSupplier)
+	}
+	skuRoutes := handler.Group("/skus")
+	{
+		skuRoutes.POST("", h.CreateSku)
+		skuRoutes.PUT("/:id", h.UpdateSku)
+		skuRoutes.DELETE("/:id", h.DeleteSku)
+	}
+	cashBackRoutes := handler.Group("/cashbacks")
+	{
+		cashBackRoutes.POST("", h.CreateCashBack)
+		cashBackRoutes.PUT("/:id", h.UpdateCashBack)
+		cashBackRoutes.DELETE("/:id", h.DeleteCashBack)
+	}
+	promotionRoutes := handler.Group("/promotions")
+	{
+		promotionRoutes.POST("", h.CreatePromotion)
+		promotionRoutes.GET("", h.GetPromotions)
+		promotionRoutes.PUT("/:id", h.UpdatePromotion)
+		promotionRoutes.DELETE("/:id", h.DeletePromotion)
+	}
+	webhookRoutes := handler.Group("/webhooks")
+	{
+		webhookRoutes.POST("", h.CreateWebhook)
+		webhookRoutes.GET("", h.GetWebhooks)
+		webhookRoutes.PUT("/:id", h.UpdateWebhook)
+		webhookRoutes.DELETE("/:id", h.DeleteWebhook)
+		webhookRoutes.GET("/:id/deliveries", h.GetWebhookDeliveries)
+		webhookRoutes.POST("/deliveries/:id/redeliver", h.RedeliverWebhook)
+	}
+	orderRoutes := handler.Group("/orders")
+	{
+		orderRoutes.GET("/:id/timeline", h.GetOrderTimeline)
+		orderRoutes.POST("/:id/reverse", h.ReverseOrder)
+	}
+	orderReviewRoutes := handler.Group("/order-reviews")
+	{
+		orderReviewRoutes.GET("", h.GetOrderReviews)
+		orderReviewRoutes.POST("/:id/resolve", h.ResolveOrderReview)
+	}
+	settlementRoutes := handler.Group("/settlements")
+	{
+		settlementRoutes.GET("", h.GetSettlementReport)
+		settlementRoutes.GET("/export", h.ExportSettlementReport)
+		settlementRoutes.POST("/imports", h.ImportProviderSettlement)
+		settlementRoutes.GET("/imports", h.GetSettlementImports)
+		settlementRoutes.GET("/imports/:id/discrepancies", h.GetSettlementDiscrepancies)
+		settlementRoutes.POST("/discrepancies/:id/resolve", h.ResolveSettlementDiscrepancy)
+	}
+}
+
+// GetProviderHealth returns the circuit breaker state and health score of every provider
+func (h *AdminRouter) GetProviderHealth(c *gin.Context) {
+	c.JSON(http.StatusOK, mapper.SuccessResponse(h.orderService.GetProviderHealth(c)))
+}
+
+// ReloadProviders rebuilds the provider routing table from the database,
+// the previous table stays in use when the reload fails
+func (h *AdminRouter) ReloadProviders(c *gin.Context) {
+	if err := h.orderService.ReloadProviders(c); err != nil {
+		h.logger.Error(errors.New("failed to reload providers"), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, mapper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(h.orderService.GetProviderHealth(c)))
+}
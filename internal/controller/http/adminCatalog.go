@@ -0,0 +1,268 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"top-up-api/internal/mapper"
+	"top-up-api/internal/schema"
+	"top-up-api/pkg/errs"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// CreateSupplier adds a supplier
+func (h *AdminRouter) CreateSupplier(c *gin.Context) {
+	var request schema.SupplierRequest
+	if !h.bindAdminRequest(c, &request) {
+		return
+	}
+	supplier, err := h.supplierService.CreateSupplier(c, request)
+	if err != nil {
+		h.catalogFailure(c, "failed to create supplier", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(supplier))
+}
+
+// UpdateSupplier replaces a supplier, a changed routing strategy applies to the next dispatch
+func (h *AdminRouter) UpdateSupplier(c *gin.Context) {
+	id, ok := h.parseAdminID(c)
+	if !ok {
+		return
+	}
+	var request schema.SupplierRequest
+	if !h.bindAdminRequest(c, &request) {
+		return
+	}
+	supplier, err := h.supplierService.UpdateSupplier(c, id, request)
+	if err != nil {
+		h.catalogFailure(c, "failed to update supplier", err)
+		return
+	}
+	h.reloadRouting(c)
+	c.JSON(http.StatusOK, mapper.SuccessResponse(supplier))
+}
+
+// DeleteSupplier soft deletes a supplier
+func (h *AdminRouter) DeleteSupplier(c *gin.Context) {
+	id, ok := h.parseAdminID(c)
+	if !ok {
+		return
+	}
+	if err := h.supplierService.DeleteSupplier(c, id); err != nil {
+		h.catalogFailure(c, "failed to delete supplier", err)
+		return
+	}
+	h.reloadRouting(c)
+	c.JSON(http.StatusOK, mapper.SuccessResponse(nil))
+}
+
+// CreateSku adds a sku to a supplier
+func (h *AdminRouter) CreateSku(c *gin.Context) {
+	var request schema.SkuRequest
+	if !h.bindAdminRequest(c, &request) {
+		return
+	}
+	sku, err := h.skuService.CreateSku(c, request)
+	if err != nil {
+		h.catalogFailure(c, "failed to create sku", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(sku))
+}
+
+// UpdateSku replaces a sku
+func (h *AdminRouter) UpdateSku(c *gin.Context) {
+	id, ok := h.parseAdminID(c)
+	if !ok {
+		return
+	}
+	var request schema.SkuRequest
+	if !h.bindAdminRequest(c, &request) {
+		return
+	}
+	sku, err := h.skuService.UpdateSku(c, id, request)
+	if err != nil {
+		h.catalogFailure(c, "failed to update sku", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(sku))
+}
+
+// DeleteSku soft deletes a sku
+func (h *AdminRouter) DeleteSku(c *gin.Context) {
+	id, ok := h.parseAdminID(c)
+	if !ok {
+		return
+	}
+	if err := h.skuService.DeleteSku(c, id); err != nil {
+		h.catalogFailure(c, "failed to delete sku", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(nil))
+}
+
+// CreateCashBack adds a cash back rule
+func (h *AdminRouter) CreateCashBack(c *gin.Context) {
+	var request schema.CashBackRequest
+	if !h.bindAdminRequest(c, &request) {
+		return
+	}
+	cashBack, err := h.cashBackService.CreateCashBack(c, request)
+	if err != nil {
+		h.catalogFailure(c, "failed to create cash back", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(cashBack))
+}
+
+// UpdateCashBack replaces a cash back rule
+func (h *AdminRouter) UpdateCashBack(c *gin.Context) {
+	id, ok := h.parseAdminID(c)
+	if !ok {
+		return
+	}
+	var request schema.CashBackRequest
+	if !h.bindAdminRequest(c, &request) {
+		return
+	}
+	cashBack, err := h.cashBackService.UpdateCashBack(c, id, request)
+	if err != nil {
+		h.catalogFailure(c, "failed to update cash back", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(cashBack))
+}
+
+// DeleteCashBack soft deletes a cash back rule
+func (h *AdminRouter) DeleteCashBack(c *gin.Context) {
+	id, ok := h.parseAdminID(c)
+	if !ok {
+		return
+	}
+	if err := h.cashBackService.DeleteCashBack(c, id); err != nil {
+		h.catalogFailure(c, "failed to delete cash back", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(nil))
+}
+
+// CreateProvider adds a provider together with the suppliers it serves. The
+// callback secret of the provider is only returned here and on rotation.
+func (h *AdminRouter) CreateProvider(c *gin.Context) {
+	var request schema.ProviderRequest
+	if !h.bindAdminRequest(c, &request) {
+		return
+	}
+	provider, err := h.providerService.CreateProvider(c, request)
+	if err != nil {
+		h.catalogFailure(c, "failed to create provider", err)
+		return
+	}
+	h.reloadRouting(c)
+	c.JSON(http.StatusOK, mapper.SuccessResponse(provider))
+}
+
+// UpdateProvider replaces a provider and the suppliers it serves with their weights
+func (h *AdminRouter) UpdateProvider(c *gin.Context) {
+	id, ok := h.parseAdminID(c)
+	if !ok {
+		return
+	}
+	var request schema.ProviderRequest
+	if !h.bindAdminRequest(c, &request) {
+		return
+	}
+	provider, err := h.providerService.UpdateProvider(c, id, request)
+	if err != nil {
+		h.catalogFailure(c, "failed to update provider", err)
+		return
+	}
+	h.reloadRouting(c)
+	c.JSON(http.StatusOK, mapper.SuccessResponse(provider))
+}
+
+// DeleteProvider soft deletes a provider, which takes it out of routing
+func (h *AdminRouter) DeleteProvider(c *gin.Context) {
+	id, ok := h.parseAdminID(c)
+	if !ok {
+		return
+	}
+	if err := h.providerService.DeleteProvider(c, id); err != nil {
+		h.catalogFailure(c, "failed to delete provider", err)
+		return
+	}
+	h.reloadRouting(c)
+	c.JSON(http.StatusOK, mapper.SuccessResponse(nil))
+}
+
+// RotateProviderCallbackSecret issues a new callback secret for a provider
+func (h *AdminRouter) RotateProviderCallbackSecret(c *gin.Context) {
+	id, ok := h.parseAdminID(c)
+	if !ok {
+		return
+	}
+	provider, err := h.providerService.RotateCallbackSecret(c, id)
+	if err != nil {
+		h.catalogFailure(c, "failed to rotate provider callback secret", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(provider))
+}
+
+func (h *AdminRouter) bindAdminRequest(c *gin.Context, request interface{}) bool {
+	if err := c.ShouldBindJSON(request); err != nil {
+		h.logger.Error(errors.New("failed to bind admin request"), zap.Error(err))
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Bad Request", err.Error()))
+		return false
+	}
+	if err := h.validator.Validate(request); err != nil {
+		h.logger.Error(errors.New("validation failed for admin request"), zap.Error(err))
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Validation Error", err.Error()))
+		return false
+	}
+	return true
+}
+
+func (h *AdminRouter) parseAdminID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		h.logger.Error(err)
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Invalid request", err.Error()))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func (h *AdminRouter) catalogFailure(c *gin.Context, message string, err error) {
+	h.logger.Error(errors.New(message), zap.Error(err))
+	code, status := catalogErrorStatus(err)
+	c.JSON(code, mapper.ErrorResponse(code, status, err.Error()))
+}
+
+// reloadRouting applies supplier and provider changes to dispatch right away. A
+// failed reload is only logged, the periodic reload picks the change up later.
+func (h *AdminRouter) reloadRouting(c *gin.Context) {
+	if err := h.orderService.ReloadProviders(c); err != nil {
+		h.logger.Warn("failed to reload providers after a catalog change", zap.Error(err))
+	}
+}
+
+// catalogErrorStatus maps catalog service errors to HTTP status codes.
+func catalogErrorStatus(err error) (int, string) {
+	var notFoundErr *errs.NotFoundError
+	var conflictErr *errs.ConflictError
+	var badRequestErr *errs.BadRequestError
+	switch {
+	case errors.As(err, &notFoundErr):
+		return http.StatusNotFound, "Not Found"
+	case errors.As(err, &conflictErr):
+		return http.StatusConflict, "Conflict"
+	case errors.As(err, &badRequestErr):
+		return http.StatusBadRequest, "Bad Request"
+	default:
+		return http.StatusInternalServerError, "Internal Server Error"
+	}
+}
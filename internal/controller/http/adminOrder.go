@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"top-up-api/internal/mapper"
+	"top-up-api/internal/schema"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// GetOrderTimeline returns every status change of an order with its source and reason
+func (h *AdminRouter) GetOrderTimeline(c *gin.Context) {
+	id, ok := h.parseAdminID(c)
+	if !ok {
+		return
+	}
+	timeline, err := h.orderService.GetOrderTimeline(c, id)
+	if err != nil {
+		h.logger.Error(errors.New("failed to get order timeline"), zap.Error(err))
+		code, status := orderErrorStatus(err)
+		c.JSON(code, mapper.ErrorResponse(code, status, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(timeline))
+}
+
+// ReverseOrder fails a successful order, e.g. once the carrier took the top-up
+// back, with the reason kept on its timeline
+func (h *AdminRouter) ReverseOrder(c *gin.Context) {
+	id, ok := h.parseAdminID(c)
+	if !ok {
+		return
+	}
+	var request schema.OrderReversalRequest
+	if !h.bindAdminRequest(c, &request) {
+		return
+	}
+	if err := h.orderService.ReverseOrder(c, id, request); err != nil {
+		h.logger.Error(errors.New("failed to reverse order"), zap.Error(err))
+		code, status := orderErrorStatus(err)
+		c.JSON(code, mapper.ErrorResponse(code, status, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(nil))
+}
@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"top-up-api/internal/mapper"
+	"top-up-api/internal/model"
+	"top-up-api/internal/schema"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// GetOrderReviews lists the stuck orders queued for manual review, the open
+// ones unless ?status= asks for resolved or all of them
+func (h *AdminRouter) GetOrderReviews(c *gin.Context) {
+	status := model.OrderReviewStatus(c.DefaultQuery("status", string(model.OrderReviewStatusOpen)))
+	switch status {
+	case model.OrderReviewStatusOpen, model.OrderReviewStatusResolved:
+	case "all":
+		status = ""
+	default:
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Bad Request", "status must be open, resolved or all"))
+		return
+	}
+
+	reviews, err := h.orderService.GetOrderReviews(c, status)
+	if err != nil {
+		h.catalogFailure(c, "failed to get order reviews", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(reviews))
+}
+
+// ResolveOrderReview settles the order of a review as success or failed and
+// closes the review
+func (h *AdminRouter) ResolveOrderReview(c *gin.Context) {
+	id, ok := h.parseAdminID(c)
+	if !ok {
+		return
+	}
+	var request schema.OrderReviewResolveRequest
+	if !h.bindAdminRequest(c, &request) {
+		return
+	}
+	review, err := h.orderService.ResolveOrderReview(c, id, request)
+	if err != nil {
+		h.logger.Error(errors.New("failed to resolve order review"), zap.Error(err))
+		code, status := orderErrorStatus(err)
+		c.JSON(code, mapper.ErrorResponse(code, status, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(review))
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"net/http"
+	"top-up-api/internal/mapper"
+	"top-up-api/internal/schema"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreatePromotion starts a cashback campaign or a voucher
+func (h *AdminRouter) CreatePromotion(c *gin.Context) {
+	var request schema.PromotionRequest
+	if !h.bindAdminRequest(c, &request) {
+		return
+	}
+	promotion, err := h.promotionService.CreatePromotion(c, request)
+	if err != nil {
+		h.catalogFailure(c, "failed to create promotion", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(promotion))
+}
+
+// GetPromotions lists every promotion with how many times it was redeemed
+func (h *AdminRouter) GetPromotions(c *gin.Context) {
+	promotions, err := h.promotionService.GetPromotions(c)
+	if err != nil {
+		h.catalogFailure(c, "failed to get promotions", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(promotions))
+}
+
+// UpdatePromotion replaces a promotion, its redemptions are kept
+func (h *AdminRouter) UpdatePromotion(c *gin.Context) {
+	id, ok := h.parseAdminID(c)
+	if !ok {
+		return
+	}
+	var request schema.PromotionRequest
+	if !h.bindAdminRequest(c, &request) {
+		return
+	}
+	promotion, err := h.promotionService.UpdatePromotion(c, id, request)
+	if err != nil {
+		h.catalogFailure(c, "failed to update promotion", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(promotion))
+}
+
+// DeletePromotion soft deletes a promotion, orders that got it keep their cashback
+func (h *AdminRouter) DeletePromotion(c *gin.Context) {
+	id, ok := h.parseAdminID(c)
+	if !ok {
+		return
+	}
+	if err := h.promotionService.DeletePromotion(c, id); err != nil {
+		h.catalogFailure(c, "failed to delete promotion", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(nil))
+}
@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"top-up-api/internal/mapper"
+	"top-up-api/internal/model"
+	"top-up-api/internal/schema"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// _maxSettlementFileSize bounds an uploaded settlement file, a provider lists
+// a day of transactions
+const _maxSettlementFileSize = 20 << 20
+
+// GetSettlementReport sums up the successful orders per day, provider and
+// supplier from ?from= to ?to=, both YYYY-MM-DD and included
+func (h *AdminRouter) GetSettlementReport(c *gin.Context) {
+	report, err := h.settlementService.GetSettlementReport(c, settlementReportRequest(c))
+	if err != nil {
+		h.catalogFailure(c, "failed to get settlement report", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(report))
+}
+
+// ExportSettlementReport returns the settlement report as a CSV attachment
+func (h *AdminRouter) ExportSettlementReport(c *gin.Context) {
+	request := settlementReportRequest(c)
+	// The report is written to a buffer first, a failure still gets a JSON error
+	var report bytes.Buffer
+	if err := h.settlementService.ExportSettlementReport(c, &report, request); err != nil {
+		h.catalogFailure(c, "failed to export settlement report", err)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="settlement_%s_%s.csv"`, request.From, request.To))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", report.Bytes())
+}
+
+func settlementReportRequest(c *gin.Context) schema.SettlementReportRequest {
+	return schema.SettlementReportRequest{From: c.Query("from"), To: c.Query("to")}
+}
+
+// ImportProviderSettlement diffs the settlement file of a provider, uploaded
+// as the multipart file field along with provider_code and day, against the
+// purchase history of that day
+func (h *AdminRouter) ImportProviderSettlement(c *gin.Context) {
+	var request schema.SettlementImportRequest
+	if err := c.ShouldBind(&request); err != nil {
+		h.logger.Error(errors.New("failed to bind settlement import request"), zap.Error(err))
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Bad Request", err.Error()))
+		return
+	}
+	if err := h.validator.Validate(request); err != nil {
+		h.logger.Error(errors.New("validation failed for settlement import request"), zap.Error(err))
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Validation Error", err.Error()))
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Bad Request", "settlement file is required"))
+		return
+	}
+	if fileHeader.Size > _maxSettlementFileSize {
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Bad Request", fmt.Sprintf("settlement file is larger than %d bytes", _maxSettlementFileSize)))
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		h.catalogFailure(c, "failed to open settlement file", err)
+		return
+	}
+	defer file.Close()
+
+	request.FileName = fileHeader.Filename
+	settlementImport, err := h.settlementService.ImportProviderSettlement(c, request, file)
+	if err != nil {
+		h.catalogFailure(c, "failed to import settlement file", err)
+		return
+	}
+	c.JSON(http.StatusCreated, mapper.SuccessResponse(settlementImport))
+}
+
+// GetSettlementImports lists the settlement files imported, newest first
+func (h *AdminRouter) GetSettlementImports(c *gin.Context) {
+	imports, err := h.settlementService.GetSettlementImports(c)
+	if err != nil {
+		h.catalogFailure(c, "failed to get settlement imports", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(imports))
+}
+
+// GetSettlementDiscrepancies lists the discrepancies of an import, the open
+// ones unless ?status= asks for resolved or all of them
+func (h *AdminRouter) GetSettlementDiscrepancies(c *gin.Context) {
+	id, ok := h.parseAdminID(c)
+	if !ok {
+		return
+	}
+	status := model.SettlementDiscrepancyStatus(c.DefaultQuery("status", string(model.SettlementDiscrepancyStatusOpen)))
+	switch status {
+	case model.SettlementDiscrepancyStatusOpen, model.SettlementDiscrepancyStatusResolved:
+	case "all":
+		status = ""
+	default:
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Bad Request", "status must be open, resolved or all"))
+		return
+	}
+
+	discrepancies, err := h.settlementService.GetSettlementDiscrepancies(c, id, status)
+	if err != nil {
+		h.catalogFailure(c, "failed to get settlement discrepancies", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(discrepancies))
+}
+
+// ResolveSettlementDiscrepancy closes a discrepancy with a note on how it was
+// settled with the provider
+func (h *AdminRouter) ResolveSettlementDiscrepancy(c *gin.Context) {
+	id, ok := h.parseAdminID(c)
+	if !ok {
+		return
+	}
+	var request schema.SettlementDiscrepancyResolveRequest
+	if !h.bindAdminRequest(c, &request) {
+		return
+	}
+	discrepancy, err := h.settlementService.ResolveSettlementDiscrepancy(c, id, request)
+	if err != nil {
+		h.catalogFailure(c, "failed to resolve settlement discrepancy", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(discrepancy))
+}
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"top-up-api/internal/mapper"
+	"top-up-api/internal/schema"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateWebhook subscribes an endpoint of a partner to the events of its
+// orders. The signing secret is only returned here.
+func (h *AdminRouter) CreateWebhook(c *gin.Context) {
+	var request schema.AdminWebhookSubscriptionRequest
+	if !h.bindAdminRequest(c, &request) {
+		return
+	}
+	subscription, err := h.webhookService.CreateSubscription(c, request.UserID, request.WebhookSubscriptionRequest)
+	if err != nil {
+		h.catalogFailure(c, "failed to create webhook subscription", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(subscription))
+}
+
+// GetWebhooks lists the webhook subscriptions of every partner
+func (h *AdminRouter) GetWebhooks(c *gin.Context) {
+	subscriptions, err := h.webhookService.GetSubscriptions(c)
+	if err != nil {
+		h.catalogFailure(c, "failed to get webhook subscriptions", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(subscriptions))
+}
+
+// UpdateWebhook replaces a webhook subscription, its secret is kept. It is
+// also how a subscription gets assigned to the partner that owns it.
+func (h *AdminRouter) UpdateWebhook(c *gin.Context) {
+	id, ok := h.parseAdminID(c)
+	if !ok {
+		return
+	}
+	var request schema.AdminWebhookSubscriptionRequest
+	if !h.bindAdminRequest(c, &request) {
+		return
+	}
+	subscription, err := h.webhookService.UpdateSubscription(c, id, request)
+	if err != nil {
+		h.catalogFailure(c, "failed to update webhook subscription", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(subscription))
+}
+
+// DeleteWebhook soft deletes a webhook subscription, its pending deliveries end up failed
+func (h *AdminRouter) DeleteWebhook(c *gin.Context) {
+	id, ok := h.parseAdminID(c)
+	if !ok {
+		return
+	}
+	if err := h.webhookService.DeleteSubscription(c, id); err != nil {
+		h.catalogFailure(c, "failed to delete webhook subscription", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(nil))
+}
+
+// GetWebhookDeliveries returns the delivery log of a subscription, newest first
+func (h *AdminRouter) GetWebhookDeliveries(c *gin.Context) {
+	id, ok := h.parseAdminID(c)
+	if !ok {
+		return
+	}
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Invalid page number", ""))
+		return
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if err != nil || pageSize < 1 {
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Invalid page size", ""))
+		return
+	}
+
+	deliveries, err := h.webhookService.GetDeliveries(c, id, page, pageSize)
+	if err != nil {
+		h.catalogFailure(c, "failed to get webhook deliveries", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(deliveries))
+}
+
+// RedeliverWebhook queues a delivery again with a fresh attempt budget
+func (h *AdminRouter) RedeliverWebhook(c *gin.Context) {
+	id, ok := h.parseAdminID(c)
+	if !ok {
+		return
+	}
+	delivery, err := h.webhookService.Redeliver(c, id)
+	if err != nil {
+		h.catalogFailure(c, "failed to redeliver webhook", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(delivery))
+}
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"top-up-api/internal/mapper"
+	"top-up-api/pkg/idempotency"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuth only lets requests through that carry the configured admin token as
+// a bearer token. Every request is rejected when no token is configured.
+func AdminAuth(token string) gin.HandlerFunc {
+	return bearerTokenAuth(token, "invalid admin token")
+}
+
+// PaymentAuth only lets requests through that carry the payment service token
+// as a bearer token. Every request is rejected when no token is configured.
+func PaymentAuth(token string) gin.HandlerFunc {
+	return bearerTokenAuth(token, "invalid payment service token")
+}
+
+func bearerTokenAuth(token, message string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if token == "" || !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, mapper.ErrorResponse(http.StatusUnauthorized, "Unauthorized", message))
+			return
+		}
+		c.Next()
+	}
+}
+
+const (
+	_idempotencyKeyHeader     = "Idempotency-Key"
+	_idempotentReplayedHeader = "Idempotent-Replayed"
+	_idempotencyKeyMaxLength  = 255
+)
+
+// Idempotency replays the stored response when a mutating request is retried
+// with the same Idempotency-Key header, and answers 409 when the key comes with
+// another method, path or body or while its first request still runs. Requests
+// without the header run as usual.
+func Idempotency(store *idempotency.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(_idempotencyKeyHeader)
+		if key == "" || !isMutating(c.Request.Method) {
+			c.Next()
+			return
+		}
+		if len(key) > _idempotencyKeyMaxLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Bad Request", "idempotency key is too long"))
+			return
+		}
+
+		body, err := c.GetRawData()
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Bad Request", err.Error()))
+			return
+		}
+		// The handler binds the body again
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Keys run before the routes authenticate the caller, they are scoped to
+		// the credentials the request carries
+		key = idempotency.ScopedKey("http", key, []byte(c.GetHeader("Authorization")), []byte(c.GetHeader(_providerCodeHeader)))
+		fingerprint := idempotency.Fingerprint([]byte(c.Request.Method), []byte(c.Request.URL.Path), body)
+		stored, err := store.Begin(c, key, fingerprint)
+		switch {
+		case errors.Is(err, idempotency.ErrKeyReused), errors.Is(err, idempotency.ErrInProgress):
+			c.AbortWithStatusJSON(http.StatusConflict, mapper.ErrorResponse(http.StatusConflict, "Conflict", err.Error()))
+			return
+		case err != nil:
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, mapper.ErrorResponse(http.StatusServiceUnavailable, "Service Unavailable", err.Error()))
+			return
+		case stored != nil:
+			c.Header(_idempotentReplayedHeader, "true")
+			c.Data(stored.Status, stored.ContentType, stored.Body)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		ctx := context.WithoutCancel(c.Request.Context())
+		if !isFinalStatus(recorder.Status()) {
+			store.Release(ctx, key)
+			return
+		}
+		store.Complete(ctx, key, idempotency.Response{
+			Fingerprint: fingerprint,
+			Status:      recorder.Status(),
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		})
+	}
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// isFinalStatus reports whether a response answers the request for good. Server
+// errors, rate limits and rejected credentials are worth retrying with the key.
+func isFinalStatus(status int) bool {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+		return false
+	default:
+		return status < http.StatusInternalServerError
+	}
+}
+
+// responseRecorder keeps a copy of the response body written to the client.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
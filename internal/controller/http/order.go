@@ -1,11 +1,18 @@
 package controller
 
 import (
+	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 	"top-up-api/internal/mapper"
+	"top-up-api/internal/model"
 	"top-up-api/internal/schema"
 	"top-up-api/internal/service"
+	"top-up-api/internal/statemachine"
+	"top-up-api/pkg/errs"
 	"top-up-api/pkg/logger"
 	"top-up-api/pkg/validator"
 
@@ -15,30 +22,49 @@ import (
 	"go.uber.org/zap"
 )
 
+const (
+	_sseHeartbeatInterval = 15 * time.Second
+
+	_providerCodeHeader      = "X-Provider-Code"
+	_providerNonceHeader     = "X-Provider-Nonce"
+	_providerSignatureHeader = "X-Provider-Signature"
+)
+
 type OrderRouter struct {
 	service   service.OrderService
+	callbacks service.ProviderCallbackService
 	auth      grpcClient.AuthGRPCClient
 	logger    logger.Interface
 	validator validator.Interface
 }
 
-func NewOrderRouter(handler *gin.RouterGroup, s service.OrderService, a grpcClient.AuthGRPCClient, l logger.Interface, v validator.Interface) {
-	h := &OrderRouter{service: s, auth: a, logger: l, validator: v}
+func NewOrderRouter(handler *gin.RouterGroup, s service.OrderService, cb service.ProviderCallbackService, a grpcClient.AuthGRPCClient, paymentAuth gin.HandlerFunc, l logger.Interface, v validator.Interface) {
+	h := &OrderRouter{service: s, callbacks: cb, auth: a, logger: l, validator: v}
 	orderRoutes := handler.Group("/order")
 	{
 		orderRoutes.POST("/create", h.CreateOrder)
-		orderRoutes.POST("/confirm", h.ConfirmOrder)
+		orderRoutes.POST("/confirm", paymentAuth, h.ConfirmOrder)
 		orderRoutes.PATCH("/update-status", h.UpdateOrderStatus)
+		orderRoutes.GET("/:order_id", h.GetOrder)
+		orderRoutes.GET("/:order_id/timeline", h.GetOrderTimeline)
+		orderRoutes.GET("/:order_id/events", h.WatchOrder)
+		orderRoutes.POST("/batch", h.CreateOrderBatch)
+		orderRoutes.POST("/batch/upload", h.UploadOrderBatch)
+		orderRoutes.POST("/batch/confirm", paymentAuth, h.ConfirmOrderBatch)
+		orderRoutes.GET("/batch/:batch_id", h.GetOrderBatch)
 	}
 }
 
 // BasePath /v1/api
 
 // @Summary Create order
-// @Description Create order
+// @Description Create order, wallet_amount of the total is paid from the user's wallet and the payment service collects the rest.
+// @Description An optional voucher_code applies a voucher promotion, the promotions applied to the order are returned with it.
+// @Description phone_number must be a Vietnamese mobile number of the carrier the sku is for, it is stored in its national form.
 // @Tags order
 // @Accept json
 // @Produce json
+// @Param Idempotency-Key header string false "Replays the first response when the request is retried with the same key"
 // @Param orderRequest body top-up-api_internal_schema.OrderRequest true "Order request"
 // @Success 200 {object} top-up-api_internal_schema.OrderResponse
 // @Router /order/create [post]
@@ -51,6 +77,12 @@ func (h *OrderRouter) CreateOrder(c *gin.Context) {
 		return
 	}
 
+	if err := h.validator.Validate(orderRequest); err != nil {
+		h.logger.Error(errors.New("validation failed for order request"), zap.Error(err))
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Validation Error", err.Error()))
+		return
+	}
+
 	token := c.GetHeader("Authorization")
 	err := h.auth.AuthenticateService(c, mapper.ToAuthRequest(token, uint64(orderRequest.UserID)))
 	if err != nil {
@@ -59,23 +91,28 @@ func (h *OrderRouter) CreateOrder(c *gin.Context) {
 		return
 	}
 
-	orderResponse, err := h.service.CreateOrder(c, orderRequest)
+	ctx := service.WithOrderEventSource(c, model.OrderStatusEventSourceHTTP)
+	orderResponse, err := h.service.CreateOrder(ctx, orderRequest)
 	if err != nil {
 		h.logger.Error(errors.New("failed to create order"), zap.Error(err))
-		c.JSON(http.StatusInternalServerError, mapper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error", err.Error()))
+		code, message := orderErrorStatus(err)
+		c.JSON(code, mapper.ErrorResponse(code, message, err.Error()))
 		return
 	}
 	c.JSON(http.StatusOK, mapper.SuccessResponse(orderResponse))
 }
 
 // @Summary Confirm order
-// @Description Confirm order
+// @Description Payment result of an order, only accepted from the payment service with its service token.
+// @Description The amounts must match the order and a payment reference can only confirm one order.
 // @Tags order
 // @Accept json
 // @Produce json
+// @Param Idempotency-Key header string false "Replays the first response when the request is retried with the same key"
 // @Param orderConfirmRequest body top-up-api_internal_schema.OrderConfirmRequest true "Order confirm request"
 // @Success 200 {object} top-up-api_internal_schema.OrderConfirmRequest
 // @Router /order/confirm [post]
+// @Security Bearer
 func (h *OrderRouter) ConfirmOrder(c *gin.Context) {
 	orderConfirmRequest := schema.OrderConfirmRequest{}
 	if err := c.ShouldBindJSON(&orderConfirmRequest); err != nil {
@@ -90,36 +127,237 @@ func (h *OrderRouter) ConfirmOrder(c *gin.Context) {
 		return
 	}
 
-	err := h.service.ConfirmOrder(c, orderConfirmRequest)
+	ctx := service.WithOrderEventSource(c, model.OrderStatusEventSourceHTTP)
+	err := h.service.ConfirmOrder(ctx, orderConfirmRequest)
 	if err != nil {
 		h.logger.Error(errors.New("failed to confirm order"), zap.Error(err))
-		c.JSON(http.StatusInternalServerError, mapper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error", err.Error()))
+		code, message := orderErrorStatus(err)
+		c.JSON(code, mapper.ErrorResponse(code, message, err.Error()))
 		return
 	}
 	c.JSON(http.StatusOK, mapper.SuccessResponse(nil))
 }
 
 // Summary Update order status
-// @Description Update order status
+// @Description Status callback of the provider an order was sent to. It is signed with the provider's callback secret:
+// @Description X-Provider-Signature is "t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<nonce>.<body>">" and a nonce is only accepted once.
 // @Tags order
 // @Accept json
 // @Produce json
+// @Param X-Provider-Code header string true "Provider code"
+// @Param X-Provider-Nonce header string true "Unique callback nonce"
+// @Param X-Provider-Signature header string true "Callback signature"
+// @Param Idempotency-Key header string false "Replays the first response when the request is retried with the same key"
 // @Param orderUpdateRequest body top-up-api_internal_schema.OrderUpdateRequest true "Order update request"
 // @Success 200 {object} top-up-api_internal_schema.Response
 // @Router /order/update-status [patch]
 func (h *OrderRouter) UpdateOrderStatus(c *gin.Context) {
+	// The signature covers the raw body, so it is read before it is bound
+	body, err := c.GetRawData()
+	if err != nil {
+		h.logger.Error(errors.New("failed to read order update request"), zap.Error(err))
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Bad Request", err.Error()))
+		return
+	}
+	credentials := schema.ProviderCallbackCredentials{
+		ProviderCode: c.GetHeader(_providerCodeHeader),
+		Nonce:        c.GetHeader(_providerNonceHeader),
+		Signature:    c.GetHeader(_providerSignatureHeader),
+	}
+	if err := h.callbacks.Authenticate(c, credentials, body); err != nil {
+		h.logger.Error(errors.New("failed to authenticate provider callback"), zap.Error(err))
+		code, message := orderErrorStatus(err)
+		c.JSON(code, mapper.ErrorResponse(code, message, err.Error()))
+		return
+	}
+
 	orderUpdateRequest := schema.OrderUpdateRequest{}
-	if err := c.ShouldBindJSON(&orderUpdateRequest); err != nil {
+	if err := json.Unmarshal(body, &orderUpdateRequest); err != nil {
 		h.logger.Error(errors.New("failed to bind order update request"), zap.Error(err))
 		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Bad Request", err.Error()))
 		return
 	}
+	orderUpdateRequest.ProviderCode = credentials.ProviderCode
 
-	err := h.service.UpdateOrderStatus(c, orderUpdateRequest)
-	if err != nil {
+	ctx := service.WithOrderEventSource(c, model.OrderStatusEventSourceProviderCallback)
+	if err := h.service.UpdateOrderStatus(ctx, orderUpdateRequest); err != nil {
 		h.logger.Error(errors.New("failed to update order status"), zap.Error(err))
-		c.JSON(http.StatusInternalServerError, mapper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error", err.Error()))
+		code, message := orderErrorStatus(err)
+		c.JSON(code, mapper.ErrorResponse(code, message, err.Error()))
 		return
 	}
 	c.JSON(http.StatusOK, mapper.SuccessResponse(nil))
 }
+
+// @Summary Get order
+// @Description Get an order of the authenticated user with its current status
+// @Tags order
+// @Produce json
+// @Param order_id path int true "Order ID"
+// @Param user_id query int true "User ID"
+// @Success 200 {object} top-up-api_internal_schema.OrderDetailResponse
+// @Router /order/{order_id} [get]
+// @Security Bearer
+func (h *OrderRouter) GetOrder(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("order_id"), 10, 64)
+	if err != nil {
+		h.logger.Error(err)
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Invalid request", err.Error()))
+		return
+	}
+	userID, err := strconv.ParseUint(c.Query("user_id"), 10, 64)
+	if err != nil {
+		h.logger.Error(err)
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Invalid request", err.Error()))
+		return
+	}
+
+	token := c.GetHeader("Authorization")
+	err = h.auth.AuthenticateService(c, mapper.ToAuthRequest(token, userID))
+	if err != nil {
+		h.logger.Error(err)
+		c.JSON(http.StatusUnauthorized, mapper.ErrorResponse(http.StatusUnauthorized, "Unauthorized", err.Error()))
+		return
+	}
+
+	order, err := h.service.GetOrder(c, uint(orderID), uint(userID))
+	if err != nil {
+		h.logger.Error(errors.New("failed to get order"), zap.Error(err))
+		code, message := orderErrorStatus(err)
+		c.JSON(code, mapper.ErrorResponse(code, message, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(order))
+}
+
+// @Summary Get order timeline
+// @Description Get every status change of an order of the authenticated user with its source
+// @Tags order
+// @Produce json
+// @Param order_id path int true "Order ID"
+// @Param user_id query int true "User ID"
+// @Success 200 {object} top-up-api_internal_schema.OrderTimelineResponse
+// @Router /order/{order_id}/timeline [get]
+// @Security Bearer
+func (h *OrderRouter) GetOrderTimeline(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("order_id"), 10, 64)
+	if err != nil {
+		h.logger.Error(err)
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Invalid request", err.Error()))
+		return
+	}
+	userID, err := strconv.ParseUint(c.Query("user_id"), 10, 64)
+	if err != nil {
+		h.logger.Error(err)
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Invalid request", err.Error()))
+		return
+	}
+
+	token := c.GetHeader("Authorization")
+	err = h.auth.AuthenticateService(c, mapper.ToAuthRequest(token, userID))
+	if err != nil {
+		h.logger.Error(err)
+		c.JSON(http.StatusUnauthorized, mapper.ErrorResponse(http.StatusUnauthorized, "Unauthorized", err.Error()))
+		return
+	}
+
+	timeline, err := h.service.GetUserOrderTimeline(c, uint(orderID), uint(userID))
+	if err != nil {
+		h.logger.Error(errors.New("failed to get order timeline"), zap.Error(err))
+		code, message := orderErrorStatus(err)
+		c.JSON(code, mapper.ErrorResponse(code, message, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(timeline))
+}
+
+// @Summary Watch order status
+// @Description Stream the status changes of an order of the authenticated user as server-sent events. The first event carries the current status and the stream ends once the order succeeds or fails.
+// @Tags order
+// @Produce text/event-stream
+// @Param order_id path int true "Order ID"
+// @Param user_id query int true "User ID"
+// @Success 200 {object} top-up-api_internal_schema.OrderStatusUpdate
+// @Router /order/{order_id}/events [get]
+// @Security Bearer
+func (h *OrderRouter) WatchOrder(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("order_id"), 10, 64)
+	if err != nil {
+		h.logger.Error(err)
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Invalid request", err.Error()))
+		return
+	}
+	userID, err := strconv.ParseUint(c.Query("user_id"), 10, 64)
+	if err != nil {
+		h.logger.Error(err)
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Invalid request", err.Error()))
+		return
+	}
+
+	token := c.GetHeader("Authorization")
+	err = h.auth.AuthenticateService(c, mapper.ToAuthRequest(token, userID))
+	if err != nil {
+		h.logger.Error(err)
+		c.JSON(http.StatusUnauthorized, mapper.ErrorResponse(http.StatusUnauthorized, "Unauthorized", err.Error()))
+		return
+	}
+
+	updates, err := h.service.WatchOrder(c.Request.Context(), uint(orderID), uint(userID))
+	if err != nil {
+		h.logger.Error(errors.New("failed to watch order"), zap.Error(err))
+		code, message := orderErrorStatus(err)
+		c.JSON(code, mapper.ErrorResponse(code, message, err.Error()))
+		return
+	}
+
+	// The stream outlives the server write timeout
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	heartbeat := time.NewTicker(_sseHeartbeatInterval)
+	defer heartbeat.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				return false
+			}
+			c.SSEvent("status", update)
+			return true
+		case <-heartbeat.C:
+			// Comment lines keep proxies from closing an idle stream
+			_, err := io.WriteString(w, ": heartbeat\n\n")
+			return err == nil
+		}
+	})
+}
+
+// orderErrorStatus maps order service errors to HTTP status codes.
+func orderErrorStatus(err error) (int, string) {
+	var transitionErr *statemachine.TransitionError
+	var unknownStatusErr *statemachine.UnknownStatusError
+	var notFoundErr *errs.NotFoundError
+	var unauthorizedErr *errs.UnauthorizedError
+	var forbiddenErr *errs.ForbiddenError
+	var badRequestErr *errs.BadRequestError
+	var conflictErr *errs.ConflictError
+	switch {
+	case errors.As(err, &notFoundErr):
+		return http.StatusNotFound, "Not Found"
+	case errors.As(err, &badRequestErr):
+		return http.StatusBadRequest, "Bad Request"
+	case errors.As(err, &conflictErr):
+		return http.StatusConflict, "Conflict"
+	case errors.As(err, &unauthorizedErr):
+		return http.StatusUnauthorized, "Unauthorized"
+	case errors.As(err, &forbiddenErr):
+		return http.StatusForbidden, "Forbidden"
+	case errors.As(err, &transitionErr):
+		return http.StatusConflict, "Conflict"
+	case errors.As(err, &unknownStatusErr):
+		return http.StatusBadRequest, "Bad Request"
+	default:
+		return http.StatusInternalServerError, "Internal Server Error"
+	}
+}
@@ -0,0 +1,189 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"top-up-api/internal/mapper"
+	"top-up-api/internal/model"
+	"top-up-api/internal/schema"
+	"top-up-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// _maxOrderBatchFileSize bounds an uploaded batch file, a thousand lines fit
+// well within it
+const _maxOrderBatchFileSize = 1 << 20
+
+// @Summary Create order batch
+// @Description Create an order for each line of the batch, all of them paid with a single payment of the batch total.
+// @Description A batch has at most 1000 lines. Batch orders get the cashback of their sku, promotions and the wallet don't apply to them.
+// @Description A batch with invalid lines is rejected with the errors of every invalid line, lines are numbered from 1.
+// @Tags order
+// @Accept json
+// @Produce json
+// @Param Idempotency-Key header string false "Replays the first response when the request is retried with the same key"
+// @Param orderBatchRequest body top-up-api_internal_schema.OrderBatchRequest true "Order batch request"
+// @Success 200 {object} top-up-api_internal_schema.OrderBatchResponse
+// @Router /order/batch [post]
+// @Security Bearer
+func (h *OrderRouter) CreateOrderBatch(c *gin.Context) {
+	request := schema.OrderBatchRequest{}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.logger.Error(errors.New("failed to bind order batch request"), zap.Error(err))
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Bad Request", err.Error()))
+		return
+	}
+
+	token := c.GetHeader("Authorization")
+	err := h.auth.AuthenticateService(c, mapper.ToAuthRequest(token, uint64(request.UserID)))
+	if err != nil {
+		h.logger.Error(err)
+		c.JSON(http.StatusUnauthorized, mapper.ErrorResponse(http.StatusUnauthorized, "Unauthorized", err.Error()))
+		return
+	}
+
+	ctx := service.WithOrderEventSource(c, model.OrderStatusEventSourceHTTP)
+	batch, err := h.service.CreateOrderBatch(ctx, request)
+	if err != nil {
+		h.logger.Error(errors.New("failed to create order batch"), zap.Error(err))
+		code, message := orderErrorStatus(err)
+		c.JSON(code, mapper.ErrorResponse(code, message, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(batch))
+}
+
+// @Summary Upload order batch
+// @Description Create an order batch from a CSV file with a phone_number and a sku_id column, each row after the header is a line of the batch.
+// @Tags order
+// @Accept multipart/form-data
+// @Produce json
+// @Param Idempotency-Key header string false "Replays the first response when the request is retried with the same key"
+// @Param user_id formData int true "User ID"
+// @Param file formData file true "CSV file of the batch lines"
+// @Success 200 {object} top-up-api_internal_schema.OrderBatchResponse
+// @Router /order/batch/upload [post]
+// @Security Bearer
+func (h *OrderRouter) UploadOrderBatch(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.PostForm("user_id"), 10, 64)
+	if err != nil {
+		h.logger.Error(err)
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Invalid request", err.Error()))
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Bad Request", "order batch file is required"))
+		return
+	}
+	if fileHeader.Size > _maxOrderBatchFileSize {
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Bad Request", fmt.Sprintf("order batch file is larger than %d bytes", _maxOrderBatchFileSize)))
+		return
+	}
+
+	token := c.GetHeader("Authorization")
+	err = h.auth.AuthenticateService(c, mapper.ToAuthRequest(token, userID))
+	if err != nil {
+		h.logger.Error(err)
+		c.JSON(http.StatusUnauthorized, mapper.ErrorResponse(http.StatusUnauthorized, "Unauthorized", err.Error()))
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		h.logger.Error(errors.New("failed to open order batch file"), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, mapper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error", err.Error()))
+		return
+	}
+	defer file.Close()
+
+	ctx := service.WithOrderEventSource(c, model.OrderStatusEventSourceHTTP)
+	batch, err := h.service.CreateOrderBatchFromFile(ctx, uint(userID), file)
+	if err != nil {
+		h.logger.Error(errors.New("failed to create order batch"), zap.Error(err))
+		code, message := orderErrorStatus(err)
+		c.JSON(code, mapper.ErrorResponse(code, message, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(batch))
+}
+
+// @Summary Confirm order batch
+// @Description Payment result of an order batch, only accepted from the payment service with its service token.
+// @Description The total must match the batch and a payment reference can only pay one batch. The orders of a paid batch are sent to their providers at a limited rate.
+// @Tags order
+// @Accept json
+// @Produce json
+// @Param Idempotency-Key header string false "Replays the first response when the request is retried with the same key"
+// @Param orderBatchConfirmRequest body top-up-api_internal_schema.OrderBatchConfirmRequest true "Order batch confirm request"
+// @Success 200 {object} top-up-api_internal_schema.Response
+// @Router /order/batch/confirm [post]
+// @Security Bearer
+func (h *OrderRouter) ConfirmOrderBatch(c *gin.Context) {
+	request := schema.OrderBatchConfirmRequest{}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.logger.Error(errors.New("failed to bind order batch confirm request"), zap.Error(err))
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Bad Request", err.Error()))
+		return
+	}
+
+	if err := h.validator.Validate(request); err != nil {
+		h.logger.Error(errors.New("validation failed for order batch confirm request"), zap.Error(err))
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Validation Error", err.Error()))
+		return
+	}
+
+	ctx := service.WithOrderEventSource(c, model.OrderStatusEventSourceHTTP)
+	if err := h.service.ConfirmOrderBatch(ctx, request); err != nil {
+		h.logger.Error(errors.New("failed to confirm order batch"), zap.Error(err))
+		code, message := orderErrorStatus(err)
+		c.JSON(code, mapper.ErrorResponse(code, message, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(nil))
+}
+
+// @Summary Get order batch
+// @Description Get an order batch of the authenticated user with the status of its payment, the number of its orders in each status and the result of each line.
+// @Tags order
+// @Produce json
+// @Param batch_id path int true "Batch ID"
+// @Param user_id query int true "User ID"
+// @Success 200 {object} top-up-api_internal_schema.OrderBatchResponse
+// @Router /order/batch/{batch_id} [get]
+// @Security Bearer
+func (h *OrderRouter) GetOrderBatch(c *gin.Context) {
+	batchID, err := strconv.ParseUint(c.Param("batch_id"), 10, 64)
+	if err != nil {
+		h.logger.Error(err)
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Invalid request", err.Error()))
+		return
+	}
+	userID, err := strconv.ParseUint(c.Query("user_id"), 10, 64)
+	if err != nil {
+		h.logger.Error(err)
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Invalid request", err.Error()))
+		return
+	}
+
+	token := c.GetHeader("Authorization")
+	err = h.auth.AuthenticateService(c, mapper.ToAuthRequest(token, userID))
+	if err != nil {
+		h.logger.Error(err)
+		c.JSON(http.StatusUnauthorized, mapper.ErrorResponse(http.StatusUnauthorized, "Unauthorized", err.Error()))
+		return
+	}
+
+	batch, err := h.service.GetOrderBatch(c, uint(batchID), uint(userID))
+	if err != nil {
+		h.logger.Error(errors.New("failed to get order batch"), zap.Error(err))
+		code, message := orderErrorStatus(err)
+		c.JSON(code, mapper.ErrorResponse(code, message, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(batch))
+}
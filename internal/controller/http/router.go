@@ -2,9 +2,11 @@ package controller
 
 import (
 	"net/http"
+	"top-up-api/config"
 	docs "top-up-api/docs"
 	grpcClient "top-up-api/internal/grpc/client"
 	"top-up-api/internal/service"
+	"top-up-api/pkg/idempotency"
 
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -12,7 +14,7 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func NewRouter(handler *gin.Engine, services *service.Container, grpcClients *grpcClient.GRPCServiceClient) {
+func NewRouter(handler *gin.Engine, services *service.Container, grpcClients *grpcClient.GRPCServiceClient, idempotencyStore *idempotency.Store, adminConfig config.Admin, paymentConfig config.Payment) {
 	// Health check endpoint
 	handler.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -26,11 +28,18 @@ func NewRouter(handler *gin.Engine, services *service.Container, grpcClients *gr
 	swaggerHandler := ginSwagger.DisablingWrapHandler(swaggerFiles.Handler, "DISABLE_SWAGGER_HTTP_HANDLER")
 	handler.GET("/swagger/*any", swaggerHandler)
 
-	h := handler.Group("/v1/api")
+	h := handler.Group("/v1/api", Idempotency(idempotencyStore))
 	{
 		NewSupplierRouter(h, services.SupplierService, services.Logger)
 		NewSkuRouter(h, services.SkuService, services.Logger)
 		NewPurchaseHistoryRouter(h, services.PurchaseHistoryService, grpcClients.AuthGRPCClient, services.Logger)
-		NewOrderRouter(h, services.OrderService, grpcClients.AuthGRPCClient, services.Logger, services.Validator)
+		NewWalletRouter(h, services.WalletService, grpcClients.AuthGRPCClient, services.Logger)
+		NewWebhookRouter(h, services.WebhookService, grpcClients.AuthGRPCClient, services.Logger, services.Validator)
+		NewOrderRouter(h, services.OrderService, services.ProviderCallbackService, grpcClients.AuthGRPCClient, PaymentAuth(paymentConfig.Token), services.Logger, services.Validator)
+	}
+
+	a := handler.Group("/v1/admin", AdminAuth(adminConfig.Token), Idempotency(idempotencyStore))
+	{
+		NewAdminRouter(a, services)
 	}
 }
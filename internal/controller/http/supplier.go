@@ -21,6 +21,7 @@ func NewSupplierRouter(handler *gin.RouterGroup, s service.SupplierService, l lo
 	supplierRoutes := handler.Group("/supplier")
 	{
 		supplierRoutes.GET("/", h.GetSuppliers)
+		supplierRoutes.GET("/suggest", h.SuggestSupplier)
 	}
 }
 
@@ -42,3 +43,23 @@ func (h *SupplierRouter) GetSuppliers(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, mapper.SuccessResponse(suppliers))
 }
+
+// @Summary Suggest supplier
+// @Description Supplier of the carrier a Vietnamese mobile number belongs to, told by the number prefix.
+// @Description Numbers ported to another carrier keep their prefix and are suggested the carrier they left.
+// @Tags supplier
+// @Accept json
+// @Produce json
+// @Param phone_number query string true "Phone number, with or without the +84 country prefix"
+// @Success 200 {object} top-up-api_internal_schema.SupplierSuggestionResponse
+// @Router /supplier/suggest [get]
+func (h *SupplierRouter) SuggestSupplier(c *gin.Context) {
+	suggestion, err := h.service.SuggestSupplier(c, c.Query("phone_number"))
+	if err != nil {
+		h.logger.Error(errors.New("error suggesting supplier"), zap.Error(err))
+		code, message := orderErrorStatus(err)
+		c.JSON(code, mapper.ErrorResponse(code, message, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(suggestion))
+}
@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	grpcClient "top-up-api/internal/grpc/client"
+	"top-up-api/internal/mapper"
+	"top-up-api/internal/service"
+	"top-up-api/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WalletRouter struct {
+	service service.WalletService
+	logger  logger.Interface
+	auth    grpcClient.AuthGRPCClient
+}
+
+func NewWalletRouter(handler *gin.RouterGroup, s service.WalletService, a grpcClient.AuthGRPCClient, l logger.Interface) {
+	h := &WalletRouter{service: s, logger: l, auth: a}
+	walletRoutes := handler.Group("/wallet")
+	{
+		walletRoutes.GET("/:user_id", h.GetBalance)
+		walletRoutes.GET("/:user_id/statement", h.GetStatement)
+	}
+}
+
+// BasePath /v1/api
+
+// @Summary Get wallet balance
+// @Description Balance of the user's wallet, credited with cashback and spent on orders
+// @Tags wallet
+// @Accept json
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Security Bearer
+// @Success 200 {object} top-up-api_internal_schema.WalletBalanceResponse
+// @Router /wallet/{user_id} [get]
+func (h *WalletRouter) GetBalance(c *gin.Context) {
+	userID, ok := h.authenticate(c)
+	if !ok {
+		return
+	}
+
+	balance, err := h.service.GetBalance(c, userID)
+	if err != nil {
+		h.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, mapper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(balance))
+}
+
+// @Summary Get wallet statement
+// @Description Movements of the user's wallet, newest first
+// @Tags wallet
+// @Accept json
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Page size"
+// @Security Bearer
+// @Success 200 {object} top-up-api_internal_schema.PaginationResponse
+// @Router /wallet/{user_id}/statement [get]
+func (h *WalletRouter) GetStatement(c *gin.Context) {
+	userID, ok := h.authenticate(c)
+	if !ok {
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		h.logger.Error(err)
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Invalid page number", ""))
+		return
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if err != nil || pageSize < 1 {
+		h.logger.Error(err)
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Invalid page size", ""))
+		return
+	}
+
+	statement, err := h.service.GetStatement(c, userID, page, pageSize)
+	if err != nil {
+		h.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, mapper.ErrorResponse(http.StatusInternalServerError, "Internal Server Error", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, statement)
+}
+
+// authenticate checks the token in the request belongs to the user of the
+// wallet, and answers the request itself when it doesn't.
+func (h *WalletRouter) authenticate(c *gin.Context) (uint, bool) {
+	token := c.GetHeader("Authorization")
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 64)
+	if err != nil {
+		h.logger.Error(err)
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Invalid request", err.Error()))
+		return 0, false
+	}
+
+	if err := h.auth.AuthenticateService(c, mapper.ToAuthRequest(token, userID)); err != nil {
+		h.logger.Error(err)
+		c.JSON(http.StatusUnauthorized, mapper.ErrorResponse(http.StatusUnauthorized, "Unauthorized", err.Error()))
+		return 0, false
+	}
+	return uint(userID), true
+}
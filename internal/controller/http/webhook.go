@@ -0,0 +1,266 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	grpcClient "top-up-api/internal/grpc/client"
+	"top-up-api/internal/mapper"
+	"top-up-api/internal/schema"
+	"top-up-api/internal/service"
+	"top-up-api/pkg/logger"
+	"top-up-api/pkg/validator"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type WebhookRouter struct {
+	service   service.WebhookService
+	auth      grpcClient.AuthGRPCClient
+	logger    logger.Interface
+	validator validator.Interface
+}
+
+func NewWebhookRouter(handler *gin.RouterGroup, s service.WebhookService, a grpcClient.AuthGRPCClient, l logger.Interface, v validator.Interface) {
+	h := &WebhookRouter{service: s, auth: a, logger: l, validator: v}
+	webhookRoutes := handler.Group("/webhooks/:user_id")
+	{
+		webhookRoutes.POST("/subscriptions", h.CreateSubscription)
+		webhookRoutes.GET("/subscriptions", h.GetSubscriptions)
+		webhookRoutes.PUT("/subscriptions/:id", h.UpdateSubscription)
+		webhookRoutes.DELETE("/subscriptions/:id", h.DeleteSubscription)
+		webhookRoutes.GET("/subscriptions/:id/deliveries", h.GetDeliveries)
+		webhookRoutes.POST("/deliveries/:id/redeliver", h.Redeliver)
+	}
+}
+
+// BasePath /v1/api
+
+// @Summary Create webhook subscription
+// @Description Subscribe an endpoint to the order.succeeded and order.failed events of the user's orders.
+// @Description The signing secret is only returned here: the X-Webhook-Signature header of a delivery is "t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">".
+// @Tags webhook
+// @Accept json
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Param Idempotency-Key header string false "Replays the first response when the request is retried with the same key"
+// @Param request body top-up-api_internal_schema.WebhookSubscriptionRequest true "Webhook subscription request"
+// @Success 200 {object} top-up-api_internal_schema.WebhookSubscriptionCreatedResponse
+// @Router /webhooks/{user_id}/subscriptions [post]
+// @Security Bearer
+func (h *WebhookRouter) CreateSubscription(c *gin.Context) {
+	userID, ok := h.authenticate(c)
+	if !ok {
+		return
+	}
+	var request schema.WebhookSubscriptionRequest
+	if !h.bind(c, &request) {
+		return
+	}
+
+	subscription, err := h.service.CreateSubscription(c, userID, request)
+	if err != nil {
+		h.failure(c, "failed to create webhook subscription", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(subscription))
+}
+
+// @Summary Get webhook subscriptions
+// @Description Webhook subscriptions of the user
+// @Tags webhook
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Success 200 {array} top-up-api_internal_schema.WebhookSubscriptionResponse
+// @Router /webhooks/{user_id}/subscriptions [get]
+// @Security Bearer
+func (h *WebhookRouter) GetSubscriptions(c *gin.Context) {
+	userID, ok := h.authenticate(c)
+	if !ok {
+		return
+	}
+
+	subscriptions, err := h.service.GetUserSubscriptions(c, userID)
+	if err != nil {
+		h.failure(c, "failed to get webhook subscriptions", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(subscriptions))
+}
+
+// @Summary Update webhook subscription
+// @Description Replace a webhook subscription of the user, its signing secret is kept
+// @Tags webhook
+// @Accept json
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Param id path int true "Subscription ID"
+// @Param Idempotency-Key header string false "Replays the first response when the request is retried with the same key"
+// @Param request body top-up-api_internal_schema.WebhookSubscriptionRequest true "Webhook subscription request"
+// @Success 200 {object} top-up-api_internal_schema.WebhookSubscriptionResponse
+// @Router /webhooks/{user_id}/subscriptions/{id} [put]
+// @Security Bearer
+func (h *WebhookRouter) UpdateSubscription(c *gin.Context) {
+	userID, ok := h.authenticate(c)
+	if !ok {
+		return
+	}
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+	var request schema.WebhookSubscriptionRequest
+	if !h.bind(c, &request) {
+		return
+	}
+
+	subscription, err := h.service.UpdateUserSubscription(c, userID, id, request)
+	if err != nil {
+		h.failure(c, "failed to update webhook subscription", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(subscription))
+}
+
+// @Summary Delete webhook subscription
+// @Description Delete a webhook subscription of the user, its pending deliveries end up failed
+// @Tags webhook
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Param id path int true "Subscription ID"
+// @Success 200 {object} top-up-api_internal_schema.Response
+// @Router /webhooks/{user_id}/subscriptions/{id} [delete]
+// @Security Bearer
+func (h *WebhookRouter) DeleteSubscription(c *gin.Context) {
+	userID, ok := h.authenticate(c)
+	if !ok {
+		return
+	}
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteUserSubscription(c, userID, id); err != nil {
+		h.failure(c, "failed to delete webhook subscription", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(nil))
+}
+
+// @Summary Get webhook deliveries
+// @Description Delivery log of a webhook subscription of the user, newest first
+// @Tags webhook
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Param id path int true "Subscription ID"
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Page size"
+// @Success 200 {object} top-up-api_internal_schema.PaginationResponse
+// @Router /webhooks/{user_id}/subscriptions/{id}/deliveries [get]
+// @Security Bearer
+func (h *WebhookRouter) GetDeliveries(c *gin.Context) {
+	userID, ok := h.authenticate(c)
+	if !ok {
+		return
+	}
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Invalid page number", ""))
+		return
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if err != nil || pageSize < 1 {
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Invalid page size", ""))
+		return
+	}
+
+	deliveries, err := h.service.GetUserDeliveries(c, userID, id, page, pageSize)
+	if err != nil {
+		h.failure(c, "failed to get webhook deliveries", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(deliveries))
+}
+
+// @Summary Redeliver webhook
+// @Description Queue a delivery of a webhook subscription of the user again with a fresh attempt budget
+// @Tags webhook
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Param id path int true "Delivery ID"
+// @Param Idempotency-Key header string false "Replays the first response when the request is retried with the same key"
+// @Success 200 {object} top-up-api_internal_schema.WebhookDeliveryResponse
+// @Router /webhooks/{user_id}/deliveries/{id}/redeliver [post]
+// @Security Bearer
+func (h *WebhookRouter) Redeliver(c *gin.Context) {
+	userID, ok := h.authenticate(c)
+	if !ok {
+		return
+	}
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+
+	delivery, err := h.service.RedeliverUserDelivery(c, userID, id)
+	if err != nil {
+		h.failure(c, "failed to redeliver webhook", err)
+		return
+	}
+	c.JSON(http.StatusOK, mapper.SuccessResponse(delivery))
+}
+
+// authenticate checks the token in the request belongs to the user of the
+// path, and answers the request itself when it doesn't.
+func (h *WebhookRouter) authenticate(c *gin.Context) (uint, bool) {
+	token := c.GetHeader("Authorization")
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 64)
+	if err != nil {
+		h.logger.Error(err)
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Invalid request", err.Error()))
+		return 0, false
+	}
+
+	if err := h.auth.AuthenticateService(c, mapper.ToAuthRequest(token, userID)); err != nil {
+		h.logger.Error(err)
+		c.JSON(http.StatusUnauthorized, mapper.ErrorResponse(http.StatusUnauthorized, "Unauthorized", err.Error()))
+		return 0, false
+	}
+	return uint(userID), true
+}
+
+func (h *WebhookRouter) parseID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		h.logger.Error(err)
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Invalid request", err.Error()))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func (h *WebhookRouter) bind(c *gin.Context, request interface{}) bool {
+	if err := c.ShouldBindJSON(request); err != nil {
+		h.logger.Error(errors.New("failed to bind webhook subscription request"), zap.Error(err))
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Bad Request", err.Error()))
+		return false
+	}
+	if err := h.validator.Validate(request); err != nil {
+		h.logger.Error(errors.New("validation failed for webhook subscription request"), zap.Error(err))
+		c.JSON(http.StatusBadRequest, mapper.ErrorResponse(http.StatusBadRequest, "Validation Error", err.Error()))
+		return false
+	}
+	return true
+}
+
+func (h *WebhookRouter) failure(c *gin.Context, message string, err error) {
+	h.logger.Error(errors.New(message), zap.Error(err))
+	code, status := catalogErrorStatus(err)
+	c.JSON(code, mapper.ErrorResponse(code, status, err.Error()))
+}
@@ -1,9 +1,7 @@
 package db
 
 import (
-	"os"
 	"top-up-api/config"
-	"top-up-api/internal/model"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -13,41 +11,18 @@ type DB struct {
 	Database *gorm.DB
 }
 
+// NewDB only connects, the schema is owned by the migrations in sql/migrations
+// and applied with cmd/migrate.
 func NewDB(cfg *config.Config) (*DB, error) {
 	db, err := gorm.Open(postgres.Open(cfg.Postgres.DSN()), &gorm.Config{
 		// Logger: logger.Default.LogMode(logger.Silent),
+		// Report unique and foreign key violations as gorm.ErrDuplicatedKey and gorm.ErrForeignKeyViolated
+		TranslateError: true,
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	query, err := os.ReadFile("sql/init.sql")
-	if err != nil {
-		return nil, err
-	}
-	db.Exec(string(query))
-
-	models := model.GetModels()
-	if cfg.Env == "dev" {
-		db.Migrator().DropTable(models...)
-	}
-
-	db.AutoMigrate(models...)
-	if cfg.Env == "dev" {
-		files, err := os.ReadDir("sql/data")
-		if err != nil {
-			return nil, err
-		}
-		for _, file := range files {
-			query, err = os.ReadFile("sql/data/" + file.Name())
-			if err != nil {
-				return nil, err
-			}
-			db.Exec(string(query))
-		}
-
-	}
-
 	return &DB{Database: db}, nil
 }
 
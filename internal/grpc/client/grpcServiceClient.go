@@ -1,6 +1,8 @@
 package grpc
 
 import (
+	"fmt"
+	"sync"
 	"top-up-api/config"
 	"top-up-api/internal/model"
 )
@@ -8,6 +10,9 @@ import (
 type GRPCServiceClient struct {
 	AuthGRPCClient      AuthGRPCClient
 	ProviderGRPCClients map[string]ProviderGRPCClient
+
+	mu              sync.RWMutex
+	providerSources map[string]string
 }
 
 func NewGRPCServiceClient(
@@ -23,21 +28,64 @@ func NewGRPCServiceClient(
 	}, nil
 }
 
-func (s *GRPCServiceClient) BuildProviderGRPCClients(providers []model.Provider) {
-	clients := make(map[string]ProviderGRPCClient)
+// ProviderGRPCClient returns the connection of a gRPC provider.
+func (s *GRPCServiceClient) ProviderGRPCClient(code string) (ProviderGRPCClient, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	client, ok := s.ProviderGRPCClients[code]
+	return client, ok
+}
+
+// SyncProviderGRPCClients opens a connection for every gRPC provider that is new
+// or whose source changed, and closes the connections of providers that are gone.
+func (s *GRPCServiceClient) SyncProviderGRPCClients(providers []model.Provider) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ProviderGRPCClients == nil {
+		s.ProviderGRPCClients = make(map[string]ProviderGRPCClient)
+	}
+	if s.providerSources == nil {
+		s.providerSources = make(map[string]string)
+	}
+
+	wanted := make(map[string]struct{})
 	for _, provider := range providers {
-		if provider.Type == "grpc" {
-			client, err := NewProviderGRPCClient(provider.Source)
-			if err != nil {
-				panic("failed to create gRPC client for " + provider.Code + ": " + err.Error())
-			}
-			clients[provider.Code] = client
+		if provider.Type != "grpc" {
+			continue
+		}
+		wanted[provider.Code] = struct{}{}
+
+		existing, ok := s.ProviderGRPCClients[provider.Code]
+		if ok && s.providerSources[provider.Code] == provider.Source {
+			continue
+		}
+
+		client, err := NewProviderGRPCClient(provider.Source)
+		if err != nil {
+			return fmt.Errorf("failed to create gRPC client for %s: %w", provider.Code, err)
+		}
+		if ok {
+			existing.Close()
 		}
+		s.ProviderGRPCClients[provider.Code] = client
+		s.providerSources[provider.Code] = provider.Source
 	}
-	s.ProviderGRPCClients = clients
+
+	for code, client := range s.ProviderGRPCClients {
+		if _, ok := wanted[code]; !ok {
+			client.Close()
+			delete(s.ProviderGRPCClients, code)
+			delete(s.providerSources, code)
+		}
+	}
+	return nil
 }
 
 func (s *GRPCServiceClient) CloseConnection() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	s.AuthGRPCClient.Close()
 	for _, service := range s.ProviderGRPCClients {
 		service.Close()
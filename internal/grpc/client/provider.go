@@ -11,6 +11,7 @@ import (
 type ProviderGRPCClient interface {
 	Close()
 	ProcessOrder(ctx context.Context, req *providerpb.OrderProcessRequest) error
+	QueryOrder(ctx context.Context, req *providerpb.OrderQueryRequest) (*providerpb.OrderQueryResponse, error)
 }
 
 type providerGRPCClient struct {
@@ -44,3 +45,7 @@ func (p *providerGRPCClient) ProcessOrder(ctx context.Context, req *providerpb.O
 	_, err := p.GrpcProviderService.ProcessOrder(ctx, req)
 	return err
 }
+
+func (p *providerGRPCClient) QueryOrder(ctx context.Context, req *providerpb.OrderQueryRequest) (*providerpb.OrderQueryResponse, error) {
+	return p.GrpcProviderService.QueryOrder(ctx, req)
+}
@@ -1,6 +1,8 @@
 package grpc
 
 import (
+	"top-up-api/config"
+	grpcClient "top-up-api/internal/grpc/client"
 	"top-up-api/internal/service"
 	pb "top-up-api/proto/order"
 
@@ -11,9 +13,9 @@ type GRPCServiceServer struct {
 	OrderGRPCServer *OrderGRPCServer
 }
 
-func NewGRPCServiceServer(services *service.Container) *GRPCServiceServer {
+func NewGRPCServiceServer(services *service.Container, auth grpcClient.AuthGRPCClient, paymentConfig config.Payment) *GRPCServiceServer {
 	return &GRPCServiceServer{
-		OrderGRPCServer: NewOrderGRPCServer(services.OrderService),
+		OrderGRPCServer: NewOrderGRPCServer(services.OrderService, services.ProviderCallbackService, auth, paymentConfig.ClientNames),
 	}
 }
 
@@ -0,0 +1,124 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"top-up-api/pkg/idempotency"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+const (
+	_idempotencyKeyMetadata  = "idempotency-key"
+	_idempotencyKeyMaxLength = 255
+)
+
+// NewIdempotencyInterceptor replays the stored result when a call is retried
+// with the same idempotency-key metadata, and rejects a key reused for another
+// call with Aborted. Calls without the key run as usual.
+func NewIdempotencyInterceptor(store *idempotency.Store) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		key := firstMetadataValue(md, _idempotencyKeyMetadata)
+		message, ok := req.(proto.Message)
+		if key == "" || !ok {
+			return handler(ctx, req)
+		}
+		if len(key) > _idempotencyKeyMaxLength {
+			return nil, status.Error(codes.InvalidArgument, "idempotency key is too long")
+		}
+
+		body, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		// Keys run before the calls authenticate the caller, they are scoped to
+		// the credentials the call carries
+		key = idempotency.ScopedKey("grpc", key,
+			[]byte(firstMetadataValue(md, _authorizationMetadata)),
+			[]byte(firstMetadataValue(md, _providerCodeMetadata)),
+			clientCertificate(ctx))
+		fingerprint := idempotency.Fingerprint([]byte(info.FullMethod), body)
+
+		stored, err := store.Begin(ctx, key, fingerprint)
+		switch {
+		case errors.Is(err, idempotency.ErrKeyReused), errors.Is(err, idempotency.ErrInProgress):
+			return nil, status.Error(codes.Aborted, err.Error())
+		case err != nil:
+			return nil, status.Error(codes.Unavailable, err.Error())
+		case stored != nil:
+			return replayResponse(stored)
+		}
+
+		resp, err := handler(ctx, req)
+		storeResponse(context.WithoutCancel(ctx), store, key, fingerprint, resp, err)
+		return resp, err
+	}
+}
+
+// clientCertificate returns the verified client certificate of the caller, nil
+// when there is none.
+func clientCertificate(ctx context.Context) []byte {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return tlsInfo.State.VerifiedChains[0][0].Raw
+}
+
+// storeResponse keeps the result of a call, results worth retrying free the key
+// instead.
+func storeResponse(ctx context.Context, store *idempotency.Store, key, fingerprint string, resp any, err error) {
+	code := status.Code(err)
+	response := idempotency.Response{Fingerprint: fingerprint, Status: int(code)}
+	switch code {
+	case codes.OK:
+		message, ok := resp.(proto.Message)
+		if !ok {
+			store.Release(ctx, key)
+			return
+		}
+		body, err := proto.Marshal(message)
+		if err != nil {
+			store.Release(ctx, key)
+			return
+		}
+		response.ContentType = string(message.ProtoReflect().Descriptor().FullName())
+		response.Body = body
+	case codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.FailedPrecondition, codes.OutOfRange:
+		response.Body = []byte(status.Convert(err).Message())
+	default:
+		store.Release(ctx, key)
+		return
+	}
+	store.Complete(ctx, key, response)
+}
+
+func replayResponse(stored *idempotency.Response) (any, error) {
+	code := codes.Code(stored.Status)
+	if code != codes.OK {
+		return nil, status.Error(code, string(stored.Body))
+	}
+
+	messageType, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(stored.ContentType))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	message := messageType.New().Interface()
+	if err := proto.Unmarshal(stored.Body, message); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return message, nil
+}
@@ -2,31 +2,70 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	grpcClient "top-up-api/internal/grpc/client"
 	"top-up-api/internal/mapper"
+	"top-up-api/internal/model"
+	"top-up-api/internal/schema"
 	"top-up-api/internal/service"
+	"top-up-api/internal/statemachine"
+	"top-up-api/pkg/errs"
 	pb "top-up-api/proto/order"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata keys a provider sends its callback credentials in
+const (
+	_providerCodeMetadata      = "x-provider-code"
+	_providerNonceMetadata     = "x-provider-nonce"
+	_providerSignatureMetadata = "x-provider-signature"
+
+	_authorizationMetadata = "authorization"
 )
 
 type OrderGRPCServer struct {
 	pb.UnimplementedOrderServiceServer
-	orderService service.OrderService
+	orderService   service.OrderService
+	callbacks      service.ProviderCallbackService
+	auth           grpcClient.AuthGRPCClient
+	paymentClients []string
+	orderStates    *statemachine.OrderStateMachine
 }
 
-func NewOrderGRPCServer(orderService service.OrderService) *OrderGRPCServer {
+func NewOrderGRPCServer(orderService service.OrderService, callbacks service.ProviderCallbackService, auth grpcClient.AuthGRPCClient, paymentClients []string) *OrderGRPCServer {
 	return &OrderGRPCServer{
-		orderService: orderService,
+		orderService:   orderService,
+		callbacks:      callbacks,
+		auth:           auth,
+		paymentClients: paymentClients,
+		orderStates:    statemachine.NewOrderStateMachine(),
 	}
 }
 
+// ConfirmOrder takes payment results, only from the payment service
+// authenticated by its client certificate.
 func (s *OrderGRPCServer) ConfirmOrder(ctx context.Context, req *pb.OrderConfirmRequest) (*pb.ConfirmOrderResponse, error) {
 	orderConfirmRequest := mapper.OrderConfirmRequestFromProto(req)
-	err := s.orderService.ConfirmOrder(ctx, *orderConfirmRequest)
+	err := s.authenticatePayment(ctx)
+	if err == nil {
+		err = s.orderStates.Validate(orderConfirmRequest.Status)
+	}
+	if err == nil {
+		ctx = service.WithOrderEventSource(ctx, model.OrderStatusEventSourceGRPC)
+		err = s.orderService.ConfirmOrder(ctx, *orderConfirmRequest)
+	}
 	if err != nil {
 		return &pb.ConfirmOrderResponse{
 			Success: false,
 			Error:   err.Error(),
-		}, err
+		}, toStatusError(err)
 	}
 	return &pb.ConfirmOrderResponse{
 		Success: true,
@@ -35,18 +74,155 @@ func (s *OrderGRPCServer) ConfirmOrder(ctx context.Context, req *pb.OrderConfirm
 
 }
 
+// UpdateOrderStatus takes provider status callbacks. The provider signs the
+// fields of the request joined by dots, see callbackPayload, and sends the
+// credentials as metadata.
 func (s *OrderGRPCServer) UpdateOrderStatus(ctx context.Context, req *pb.OrderUpdateRequest) (*pb.OrderUpdateResponse, error) {
 	orderUpdateRequest := mapper.OrderUpdateRequestFromProto(req)
-	err := s.orderService.UpdateOrderStatus(ctx, *orderUpdateRequest)
+	providerCode, err := s.authenticateCallback(ctx, req)
+	if err == nil {
+		orderUpdateRequest.ProviderCode = providerCode
+		err = s.orderStates.Validate(orderUpdateRequest.Status)
+	}
+	if err == nil {
+		ctx = service.WithOrderEventSource(ctx, model.OrderStatusEventSourceGRPC)
+		err = s.orderService.UpdateOrderStatus(ctx, *orderUpdateRequest)
+	}
 	if err != nil {
-		fmt.Print(err)
 		return &pb.OrderUpdateResponse{
 			Success: false,
 			Error:   err.Error(),
-		}, err
+		}, toStatusError(err)
 	}
 	return &pb.OrderUpdateResponse{
 		Success: true,
 		Error:   "",
 	}, nil
 }
+
+// GetOrder returns the order of the user in the request, who must be the one
+// authenticated by the bearer token in the metadata. Orders of other users are
+// reported as not found.
+func (s *OrderGRPCServer) GetOrder(ctx context.Context, req *pb.GetOrderRequest) (*pb.GetOrderResponse, error) {
+	if err := s.authenticateUser(ctx, req.UserId); err != nil {
+		return nil, toStatusError(err)
+	}
+	order, err := s.orderService.GetOrder(ctx, uint(req.OrderId), uint(req.UserId))
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return mapper.OrderDetailResponseToProto(order), nil
+}
+
+// WatchOrder streams the status changes of an order until it settles or the
+// client goes away. The user is authenticated like in GetOrder before the
+// stream subscribes.
+func (s *OrderGRPCServer) WatchOrder(req *pb.WatchOrderRequest, stream pb.OrderService_WatchOrderServer) error {
+	if err := s.authenticateUser(stream.Context(), req.UserId); err != nil {
+		return toStatusError(err)
+	}
+	updates, err := s.orderService.WatchOrder(stream.Context(), uint(req.OrderId), uint(req.UserId))
+	if err != nil {
+		return toStatusError(err)
+	}
+	for update := range updates {
+		if err := stream.Send(mapper.OrderStatusUpdateToProto(update)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// authenticateUser checks the bearer token in the authorization metadata with
+// the auth service, as the HTTP API does with the Authorization header.
+func (s *OrderGRPCServer) authenticateUser(ctx context.Context, userID uint64) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	token := firstMetadataValue(md, _authorizationMetadata)
+	if token == "" {
+		return &errs.UnauthorizedError{Message: "bearer token is required"}
+	}
+	if err := s.auth.AuthenticateService(ctx, mapper.ToAuthRequest(token, userID)); err != nil {
+		return &errs.UnauthorizedError{Message: status.Convert(err).Message()}
+	}
+	return nil
+}
+
+func (s *OrderGRPCServer) authenticateCallback(ctx context.Context, req *pb.OrderUpdateRequest) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	credentials := schema.ProviderCallbackCredentials{
+		ProviderCode: firstMetadataValue(md, _providerCodeMetadata),
+		Nonce:        firstMetadataValue(md, _providerNonceMetadata),
+		Signature:    firstMetadataValue(md, _providerSignatureMetadata),
+	}
+	if err := s.callbacks.Authenticate(ctx, credentials, callbackPayload(req)); err != nil {
+		return "", err
+	}
+	return credentials.ProviderCode, nil
+}
+
+// callbackPayload is the part of a callback a provider signs after the nonce,
+// "<order_id>.<status>.<phone_number>". Unlike the protobuf encoding it reads
+// the same to a provider written in any language.
+func callbackPayload(req *pb.OrderUpdateRequest) []byte {
+	return fmt.Appendf(nil, "%d.%s.%s", req.OrderId, req.Status, req.PhoneNumber)
+}
+
+func firstMetadataValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// authenticatePayment accepts callers with a client certificate verified by the
+// client CA and issued to one of the payment clients, by common name or DNS name.
+func (s *OrderGRPCServer) authenticatePayment(ctx context.Context) error {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return &errs.UnauthorizedError{Message: "payment client certificate is required"}
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return &errs.UnauthorizedError{Message: "payment client certificate is required"}
+	}
+
+	certificate := tlsInfo.State.VerifiedChains[0][0]
+	if slices.Contains(s.paymentClients, certificate.Subject.CommonName) {
+		return nil
+	}
+	for _, name := range certificate.DNSNames {
+		if slices.Contains(s.paymentClients, name) {
+			return nil
+		}
+	}
+	return &errs.ForbiddenError{Message: fmt.Sprintf("client certificate %s is not a payment client", certificate.Subject.CommonName)}
+}
+
+// toStatusError maps order state machine errors to gRPC status codes.
+func toStatusError(err error) error {
+	var transitionErr *statemachine.TransitionError
+	var unknownStatusErr *statemachine.UnknownStatusError
+	var notFoundErr *errs.NotFoundError
+	var unauthorizedErr *errs.UnauthorizedError
+	var forbiddenErr *errs.ForbiddenError
+	var badRequestErr *errs.BadRequestError
+	var conflictErr *errs.ConflictError
+	switch {
+	case errors.As(err, &notFoundErr):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.As(err, &badRequestErr):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.As(err, &conflictErr):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.As(err, &unauthorizedErr):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.As(err, &forbiddenErr):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.As(err, &transitionErr):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.As(err, &unknownStatusErr):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -23,6 +23,7 @@ type Consumers struct {
 // NewContainer creates and initializes all dependencies
 func NewConsumers(
 	config *config.Kafka,
+	paymentConfig config.Payment,
 	services *service.Container,
 ) *Consumers {
 	// Initialize Kafka factories
@@ -31,7 +32,7 @@ func NewConsumers(
 	if err != nil {
 		services.Logger.Error(err)
 	}
-	orderConsumer := NewOrderConsumer(services.Logger, services.OrderService, orderKafkaConsumer)
+	orderConsumer := NewOrderConsumer(services.Logger, services.OrderService, orderKafkaConsumer, paymentConfig.MessageSecret)
 
 	return &Consumers{
 		// Config
@@ -5,33 +5,61 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
+	"top-up-api/internal/model"
 	"top-up-api/internal/schema"
 	"top-up-api/internal/service"
+	"top-up-api/internal/statemachine"
+	"top-up-api/pkg/errs"
 	kfk "top-up-api/pkg/kafka"
 	"top-up-api/pkg/logger"
+	"top-up-api/pkg/signature"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"go.uber.org/zap"
 )
 
+// _signatureHeader is the Kafka header holding the signature of the message
+// value, formatted like the webhook signatures.
+const _signatureHeader = "signature"
+
 type OrderConsumer struct {
-	logger   logger.Interface
-	service  service.OrderService
-	consumer kfk.Consumer
+	logger        logger.Interface
+	service       service.OrderService
+	consumer      kfk.Consumer
+	orderStates   *statemachine.OrderStateMachine
+	messageSecret string
 }
 
-func NewOrderConsumer(l logger.Interface, s service.OrderService, c kfk.Consumer) *OrderConsumer {
-	return &OrderConsumer{logger: l, service: s, consumer: c}
+func NewOrderConsumer(l logger.Interface, s service.OrderService, c kfk.Consumer, messageSecret string) *OrderConsumer {
+	return &OrderConsumer{logger: l, service: s, consumer: c, orderStates: statemachine.NewOrderStateMachine(), messageSecret: messageSecret}
 }
 
 func (c *OrderConsumer) StartOrderConfirmConsumer(ctx context.Context, topic, groupID string) error {
+	ctx = service.WithOrderEventSource(ctx, model.OrderStatusEventSourceKafka)
 	if err := c.consumer.Consume(ctx, topic, groupID, func(msg *kafka.Message) error {
+		if err := c.verifySignature(msg); err != nil {
+			c.logger.Warn("unsigned order confirm event: ", zap.Error(err))
+			return nil
+		}
+
 		var orderConfirmRequest schema.OrderConfirmRequest
 		if err := json.Unmarshal(msg.Value, &orderConfirmRequest); err != nil {
 			c.logger.Warn("failed to unmarshal order confirm event: ", zap.Error(err))
 			return nil
 		}
+		if err := c.orderStates.Validate(orderConfirmRequest.Status); err != nil {
+			c.logger.Warn("invalid order confirm event: ", zap.Error(err))
+			return nil
+		}
 		if err := c.service.ConfirmOrder(ctx, orderConfirmRequest); err != nil {
+			var transitionErr *statemachine.TransitionError
+			var badRequestErr *errs.BadRequestError
+			var conflictErr *errs.ConflictError
+			if errors.As(err, &transitionErr) || errors.As(err, &badRequestErr) || errors.As(err, &conflictErr) {
+				c.logger.Warn("rejected order confirm event: ", zap.Error(err))
+				return nil
+			}
 			c.logger.Error(errors.New("failed to process confirm event: "), zap.Error(err))
 		}
 		return nil
@@ -44,6 +72,23 @@ func (c *OrderConsumer) StartOrderConfirmConsumer(ctx context.Context, topic, gr
 	return nil
 }
 
+// verifySignature checks the message was signed by the payment service with the
+// shared message secret. Every message is rejected when no secret is configured.
+// The timestamp isn't bounded since a lagging consumer reads old messages, a
+// replayed confirmation is rejected by the order status and payment reference.
+func (c *OrderConsumer) verifySignature(msg *kafka.Message) error {
+	if c.messageSecret == "" {
+		return errors.New("no payment message secret is configured")
+	}
+	for _, header := range msg.Headers {
+		if header.Key == _signatureHeader {
+			_, err := signature.Verify(c.messageSecret, string(header.Value), msg.Value, 0, time.Now())
+			return err
+		}
+	}
+	return errors.New("message has no signature header")
+}
+
 func (c *OrderConsumer) Close() error {
 	if err := c.consumer.Close(); err != nil {
 		return err
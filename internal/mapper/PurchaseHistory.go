@@ -7,25 +7,43 @@ import (
 
 func PurchaseHistoryResponseFromModel(purchaseHistory *model.PurchaseHistory) *schema.PurchaseHistoryResponse {
 	return &schema.PurchaseHistoryResponse{
-		OrderID:       purchaseHistory.OrderID,
-		UserID:        purchaseHistory.UserID,
-		SkuID:         purchaseHistory.SkuID,
-		TotalPrice:    purchaseHistory.TotalPrice,
-		PhoneNumber:   purchaseHistory.PhoneNumber,
-		Status:        string(purchaseHistory.Status),
-		CashBackValue: purchaseHistory.CashBackValue,
-		Sku:           *SkuResponseFromModel(purchaseHistory.Sku),
+		OrderID:          purchaseHistory.OrderID,
+		UserID:           purchaseHistory.UserID,
+		SkuID:            purchaseHistory.SkuID,
+		TotalPrice:       purchaseHistory.TotalPrice,
+		PhoneNumber:      purchaseHistory.PhoneNumber,
+		Status:           string(purchaseHistory.Status),
+		CashBackValue:    purchaseHistory.CashBackValue,
+		PaymentReference: purchaseHistory.PaymentReference,
+		Sku:              *SkuResponseFromModel(purchaseHistory.Sku),
 	}
 }
 
-func PurchaseHistoryFromOrderConfirmRequest(orderConfirmRequest schema.OrderConfirmRequest) *model.PurchaseHistory {
+// PurchaseHistoryFromConfirmedOrder records the payment result of an order. The
+// amounts are taken from the order, never from the payment request.
+func PurchaseHistoryFromConfirmedOrder(order *schema.OrderResponse, orderConfirmRequest schema.OrderConfirmRequest) *model.PurchaseHistory {
 	return &model.PurchaseHistory{
-		UserID:        orderConfirmRequest.UserID,
-		OrderID:       orderConfirmRequest.OrderID,
-		SkuID:         orderConfirmRequest.SkuID,
-		PhoneNumber:   orderConfirmRequest.PhoneNumber,
-		TotalPrice:    orderConfirmRequest.TotalPrice,
-		Status:        orderConfirmRequest.Status,
-		CashBackValue: orderConfirmRequest.CashBackValue,
+		UserID:           order.UserID,
+		OrderID:          order.OrderID,
+		SkuID:            order.Sku.ID,
+		PhoneNumber:      order.PhoneNumber,
+		TotalPrice:       order.TotalPrice,
+		Status:           orderConfirmRequest.Status,
+		CashBackValue:    order.CashBackValue,
+		PaymentReference: orderConfirmRequest.PaymentReference,
+	}
+}
+
+// PurchaseHistoryFromExpiredOrder records an order that expired before it was
+// paid, so it has no payment reference.
+func PurchaseHistoryFromExpiredOrder(order *schema.OrderResponse) *model.PurchaseHistory {
+	return &model.PurchaseHistory{
+		UserID:        order.UserID,
+		OrderID:       order.OrderID,
+		SkuID:         order.Sku.ID,
+		PhoneNumber:   order.PhoneNumber,
+		TotalPrice:    order.TotalPrice,
+		Status:        model.PurchaseHistoryStatusExpired,
+		CashBackValue: order.CashBackValue,
 	}
 }
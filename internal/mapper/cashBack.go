@@ -19,3 +19,20 @@ func CashBackFromModel(cashBack model.CashBack) schema.CashBackInterface {
 		Value: cashBack.Value,
 	}
 }
+
+func CashBackFromRequest(request schema.CashBackRequest) *model.CashBack {
+	return &model.CashBack{
+		Code:  request.Code,
+		Type:  request.Type,
+		Value: request.Value,
+	}
+}
+
+func CashBackAdminResponseFromModel(cashBack *model.CashBack) *schema.CashBackAdminResponse {
+	return &schema.CashBackAdminResponse{
+		ID:    cashBack.ID,
+		Code:  cashBack.Code,
+		Type:  cashBack.Type,
+		Value: cashBack.Value,
+	}
+}
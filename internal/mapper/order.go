@@ -7,7 +7,7 @@ import (
 	providerpb "top-up-api/proto/provider"
 )
 
-func OrderResponseFromOrderRequest(orderRequest schema.OrderRequest, sku *model.Sku, orderID uint) *schema.OrderResponse {
+func OrderResponseFromOrderRequest(orderRequest schema.OrderRequest, sku *model.Sku, orderID uint, evaluation *schema.PromotionEvaluation) *schema.OrderResponse {
 	skuResponse := SkuResponseFromModel(*sku)
 
 	return &schema.OrderResponse{
@@ -17,11 +17,81 @@ func OrderResponseFromOrderRequest(orderRequest schema.OrderRequest, sku *model.
 		TotalPrice:    skuResponse.Price,
 		Status:        model.PurchaseHistoryStatusPending,
 		PhoneNumber:   orderRequest.PhoneNumber,
-		CashBackValue: skuResponse.CashBackInterface.CalculateCashBack(sku.Price),
+		CashBackValue: evaluation.CashBackValue,
+		WalletAmount:  orderRequest.WalletAmount,
+		Promotions:    evaluation.Promotions,
 	}
 
 }
 
+func OrderFromOrderResponse(orderResponse *schema.OrderResponse) *model.Order {
+	return &model.Order{
+		OrderID:       orderResponse.OrderID,
+		UserID:        orderResponse.UserID,
+		SkuID:         orderResponse.Sku.ID,
+		TotalPrice:    orderResponse.TotalPrice,
+		PhoneNumber:   orderResponse.PhoneNumber,
+		CashBackValue: orderResponse.CashBackValue,
+		WalletAmount:  orderResponse.WalletAmount,
+		Status:        orderResponse.Status,
+		BatchID:       orderResponse.BatchID,
+		BatchLine:     orderResponse.BatchLine,
+	}
+}
+
+func OrderResponseFromModel(order *model.Order) *schema.OrderResponse {
+	return &schema.OrderResponse{
+		OrderID:       order.OrderID,
+		UserID:        order.UserID,
+		Sku:           *SkuResponseFromModel(order.Sku),
+		TotalPrice:    order.TotalPrice,
+		Status:        order.Status,
+		PhoneNumber:   order.PhoneNumber,
+		CashBackValue: order.CashBackValue,
+		WalletAmount:  order.WalletAmount,
+		Promotions:    AppliedPromotionsFromRedemptions(order.Promotions),
+		BatchID:       order.BatchID,
+		BatchLine:     order.BatchLine,
+	}
+}
+
+// OrderDetailResponseFromOrder merges the order with its purchase history, which
+// holds the persisted status once the order is confirmed.
+func OrderDetailResponseFromOrder(order *schema.OrderResponse, purchaseHistory *model.PurchaseHistory) *schema.OrderDetailResponse {
+	detail := &schema.OrderDetailResponse{OrderResponse: *order}
+	if purchaseHistory != nil {
+		detail.Status = purchaseHistory.Status
+		detail.ConfirmedAt = &purchaseHistory.CreatedAt
+		detail.UpdatedAt = &purchaseHistory.UpdatedAt
+	}
+	return detail
+}
+
+func OrderDetailResponseToProto(order *schema.OrderDetailResponse) *pb.GetOrderResponse {
+	response := &pb.GetOrderResponse{
+		OrderId:       uint64(order.OrderID),
+		UserId:        uint64(order.UserID),
+		SkuId:         uint64(order.Sku.ID),
+		SupplierCode:  order.Sku.SupplierInfo.Code,
+		Price:         int64(order.Sku.Price),
+		TotalPrice:    int64(order.TotalPrice),
+		Status:        string(order.Status),
+		PhoneNumber:   order.PhoneNumber,
+		CashBackValue: int64(order.CashBackValue),
+		WalletAmount:  int64(order.WalletAmount),
+	}
+	for _, promotion := range order.Promotions {
+		response.PromotionCodes = append(response.PromotionCodes, promotion.Code)
+	}
+	if order.ConfirmedAt != nil {
+		response.ConfirmedAt = order.ConfirmedAt.Unix()
+	}
+	if order.UpdatedAt != nil {
+		response.UpdatedAt = order.UpdatedAt.Unix()
+	}
+	return response
+}
+
 func OrderProviderRequestFromOrderResponse(orderResponse *schema.OrderResponse, callbackUrl string) *schema.OrderProviderRequest {
 	return &schema.OrderProviderRequest{
 		OrderID:     orderResponse.OrderID,
@@ -34,13 +104,14 @@ func OrderProviderRequestFromOrderResponse(orderResponse *schema.OrderResponse,
 
 func OrderConfirmRequestFromProto(order *pb.OrderConfirmRequest) *schema.OrderConfirmRequest {
 	return &schema.OrderConfirmRequest{
-		OrderID:       uint(order.OrderId),
-		UserID:        uint(order.UserId),
-		SkuID:         uint(order.SkuId),
-		TotalPrice:    int(order.TotalPrice),
-		Status:        model.PurchaseHistoryStatus(order.Status),
-		PhoneNumber:   order.PhoneNumber,
-		CashBackValue: int(order.CashBackValue),
+		OrderID:          uint(order.OrderId),
+		UserID:           uint(order.UserId),
+		SkuID:            uint(order.SkuId),
+		TotalPrice:       int(order.TotalPrice),
+		Status:           model.PurchaseHistoryStatus(order.Status),
+		PhoneNumber:      order.PhoneNumber,
+		CashBackValue:    int(order.CashBackValue),
+		PaymentReference: order.PaymentReference,
 	}
 }
 
@@ -0,0 +1,61 @@
+package mapper
+
+import (
+	"top-up-api/internal/model"
+	"top-up-api/internal/schema"
+)
+
+// OrderBatchFromOrders totals the orders of a new batch, which are its lines
+// in order.
+func OrderBatchFromOrders(batchID, userID uint, orders []*schema.OrderResponse) *model.OrderBatch {
+	batch := &model.OrderBatch{
+		BatchID:   batchID,
+		UserID:    userID,
+		LineCount: len(orders),
+		Status:    model.PurchaseHistoryStatusPending,
+		Orders:    make([]model.Order, len(orders)),
+	}
+	for i, order := range orders {
+		batch.TotalPrice += order.TotalPrice
+		batch.CashBackValue += order.CashBackValue
+		batch.Orders[i] = *OrderFromOrderResponse(order)
+	}
+	return batch
+}
+
+func OrderBatchResponseFromModel(batch *model.OrderBatch) *schema.OrderBatchResponse {
+	response := &schema.OrderBatchResponse{
+		BatchID:       batch.BatchID,
+		UserID:        batch.UserID,
+		Status:        batch.Status,
+		TotalPrice:    batch.TotalPrice,
+		CashBackValue: batch.CashBackValue,
+		LineCount:     batch.LineCount,
+		Lines:         make([]schema.OrderBatchLineResponse, len(batch.Orders)),
+		CreatedAt:     batch.CreatedAt,
+	}
+	for i, order := range batch.Orders {
+		response.Lines[i] = schema.OrderBatchLineResponse{
+			Line:          order.BatchLine,
+			OrderID:       order.OrderID,
+			SkuID:         order.SkuID,
+			PhoneNumber:   order.PhoneNumber,
+			TotalPrice:    order.TotalPrice,
+			CashBackValue: order.CashBackValue,
+			Status:        order.Status,
+		}
+		switch order.Status {
+		case model.PurchaseHistoryStatusPending:
+			response.Progress.Pending++
+		case model.PurchaseHistoryStatusConfirm:
+			response.Progress.Confirm++
+		case model.PurchaseHistoryStatusSuccess:
+			response.Progress.Success++
+		case model.PurchaseHistoryStatusFailed:
+			response.Progress.Failed++
+		case model.PurchaseHistoryStatusExpired:
+			response.Progress.Expired++
+		}
+	}
+	return response
+}
@@ -0,0 +1,29 @@
+package mapper
+
+import (
+	"top-up-api/internal/model"
+	"top-up-api/internal/schema"
+)
+
+func OrderReviewFromStuckOrder(order *model.Order, reason string) *model.OrderReview {
+	return &model.OrderReview{
+		OrderID:      order.OrderID,
+		ProviderCode: order.ProviderCode,
+		Reason:       reason,
+		Status:       model.OrderReviewStatusOpen,
+	}
+}
+
+func OrderReviewResponseFromModel(review *model.OrderReview) *schema.OrderReviewResponse {
+	return &schema.OrderReviewResponse{
+		ID:           review.ID,
+		OrderID:      review.OrderID,
+		ProviderCode: review.ProviderCode,
+		Reason:       review.Reason,
+		Status:       review.Status,
+		Resolution:   review.Resolution,
+		Note:         review.Note,
+		ResolvedAt:   review.ResolvedAt,
+		CreatedAt:    review.CreatedAt,
+	}
+}
@@ -0,0 +1,56 @@
+package mapper
+
+import (
+	"time"
+	"top-up-api/internal/model"
+	"top-up-api/internal/schema"
+	pb "top-up-api/proto/order"
+)
+
+func OrderStatusEventFromTransition(orderID uint, previousStatus *model.PurchaseHistoryStatus, status model.PurchaseHistoryStatus, source model.OrderStatusEventSource) *model.OrderStatusEvent {
+	return &model.OrderStatusEvent{
+		OrderID:        orderID,
+		Source:         source,
+		PreviousStatus: previousStatus,
+		Status:         status,
+		CreatedAt:      time.Now(),
+	}
+}
+
+func OrderTimelineResponseFromModel(orderID uint, events []model.OrderStatusEvent) *schema.OrderTimelineResponse {
+	response := &schema.OrderTimelineResponse{
+		OrderID: orderID,
+		Events:  make([]schema.OrderStatusEventResponse, 0, len(events)),
+	}
+	for _, event := range events {
+		response.Events = append(response.Events, schema.OrderStatusEventResponse{
+			PreviousStatus: event.PreviousStatus,
+			Status:         event.Status,
+			Source:         event.Source,
+			Reason:         event.Reason,
+			CreatedAt:      event.CreatedAt,
+		})
+	}
+	return response
+}
+
+func OrderStatusUpdateFromTransition(orderID uint, previousStatus *model.PurchaseHistoryStatus, status model.PurchaseHistoryStatus) schema.OrderStatusUpdate {
+	return schema.OrderStatusUpdate{
+		OrderID:        orderID,
+		PreviousStatus: previousStatus,
+		Status:         status,
+		UpdatedAt:      time.Now(),
+	}
+}
+
+func OrderStatusUpdateToProto(update schema.OrderStatusUpdate) *pb.OrderStatusUpdate {
+	response := &pb.OrderStatusUpdate{
+		OrderId:   uint64(update.OrderID),
+		Status:    string(update.Status),
+		UpdatedAt: update.UpdatedAt.Unix(),
+	}
+	if update.PreviousStatus != nil {
+		response.PreviousStatus = string(*update.PreviousStatus)
+	}
+	return response
+}
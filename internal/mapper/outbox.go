@@ -0,0 +1,44 @@
+package mapper
+
+import (
+	"time"
+	"top-up-api/internal/model"
+)
+
+func OutboxMessageFromHTTPRequest(aggregateID uint, eventType, method, url string, payload []byte) *model.OutboxMessage {
+	return &model.OutboxMessage{
+		AggregateID:   aggregateID,
+		EventType:     eventType,
+		Channel:       model.OutboxChannelHTTP,
+		Destination:   url,
+		Method:        method,
+		Payload:       string(payload),
+		Status:        model.OutboxStatusPending,
+		NextAttemptAt: time.Now(),
+	}
+}
+
+func OutboxMessageFromKafkaMessage(aggregateID uint, eventType, topic, key string, payload []byte) *model.OutboxMessage {
+	return &model.OutboxMessage{
+		AggregateID:   aggregateID,
+		EventType:     eventType,
+		Channel:       model.OutboxChannelKafka,
+		Destination:   topic,
+		MessageKey:    key,
+		Payload:       string(payload),
+		Status:        model.OutboxStatusPending,
+		NextAttemptAt: time.Now(),
+	}
+}
+
+func OutboxMessageFromWebhookEvent(aggregateID uint, eventType string, payload []byte) *model.OutboxMessage {
+	return &model.OutboxMessage{
+		AggregateID:   aggregateID,
+		EventType:     eventType,
+		Channel:       model.OutboxChannelWebhook,
+		Destination:   eventType,
+		Payload:       string(payload),
+		Status:        model.OutboxStatusPending,
+		NextAttemptAt: time.Now(),
+	}
+}
@@ -0,0 +1,99 @@
+package mapper
+
+import (
+	"strings"
+	"top-up-api/internal/model"
+	"top-up-api/internal/schema"
+)
+
+// NormalizePromotionCode makes codes entered at checkout match however they were typed
+func NormalizePromotionCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func PromotionFromRequest(request schema.PromotionRequest) *model.Promotion {
+	active := true
+	if request.Active != nil {
+		active = *request.Active
+	}
+	supplierCodes := request.SupplierCodes
+	if supplierCodes == nil {
+		supplierCodes = []string{}
+	}
+	skuIDs := request.SkuIDs
+	if skuIDs == nil {
+		skuIDs = []uint{}
+	}
+	return &model.Promotion{
+		Code:          NormalizePromotionCode(request.Code),
+		Name:          request.Name,
+		Type:          request.Type,
+		Value:         request.Value,
+		MaxCashBack:   request.MaxCashBack,
+		StartsAt:      request.StartsAt,
+		EndsAt:        request.EndsAt,
+		SupplierCodes: supplierCodes,
+		SkuIDs:        skuIDs,
+		GlobalLimit:   request.GlobalLimit,
+		PerUserLimit:  request.PerUserLimit,
+		Stackable:     request.Stackable,
+		Voucher:       request.Voucher,
+		Active:        active,
+	}
+}
+
+func PromotionResponseFromModel(promotion *model.Promotion) *schema.PromotionResponse {
+	return &schema.PromotionResponse{
+		ID:              promotion.ID,
+		Code:            promotion.Code,
+		Name:            promotion.Name,
+		Type:            promotion.Type,
+		Value:           promotion.Value,
+		MaxCashBack:     promotion.MaxCashBack,
+		StartsAt:        promotion.StartsAt,
+		EndsAt:          promotion.EndsAt,
+		SupplierCodes:   promotion.SupplierCodes,
+		SkuIDs:          promotion.SkuIDs,
+		GlobalLimit:     promotion.GlobalLimit,
+		PerUserLimit:    promotion.PerUserLimit,
+		RedemptionCount: promotion.RedemptionCount,
+		Stackable:       promotion.Stackable,
+		Voucher:         promotion.Voucher,
+		Active:          promotion.Active,
+		CreatedAt:       promotion.CreatedAt,
+	}
+}
+
+func AppliedPromotionFromModel(promotion *model.Promotion, cashBack int) schema.AppliedPromotion {
+	return schema.AppliedPromotion{
+		PromotionID:   promotion.ID,
+		Code:          promotion.Code,
+		CashBackValue: cashBack,
+	}
+}
+
+func AppliedPromotionsFromRedemptions(redemptions []model.PromotionRedemption) []schema.AppliedPromotion {
+	if len(redemptions) == 0 {
+		return nil
+	}
+	applied := make([]schema.AppliedPromotion, len(redemptions))
+	for i, redemption := range redemptions {
+		applied[i] = schema.AppliedPromotion{
+			PromotionID:   redemption.PromotionID,
+			Code:          redemption.Code,
+			CashBackValue: redemption.CashBackValue,
+			Released:      redemption.ReleasedAt != nil,
+		}
+	}
+	return applied
+}
+
+func PromotionRedemptionFromApplied(applied schema.AppliedPromotion, orderID, userID uint) *model.PromotionRedemption {
+	return &model.PromotionRedemption{
+		PromotionID:   applied.PromotionID,
+		OrderID:       orderID,
+		UserID:        userID,
+		Code:          applied.Code,
+		CashBackValue: applied.CashBackValue,
+	}
+}
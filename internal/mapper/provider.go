@@ -0,0 +1,40 @@
+package mapper
+
+import (
+	"top-up-api/internal/model"
+	"top-up-api/internal/schema"
+)
+
+func ProviderFromRequest(request schema.ProviderRequest) *model.Provider {
+	return &model.Provider{
+		Code:     request.Code,
+		Source:   request.Source,
+		Type:     request.Type,
+		Discount: request.Discount,
+	}
+}
+
+func ProviderAdminResponseFromModel(provider *model.Provider) *schema.ProviderAdminResponse {
+	suppliers := make([]schema.ProviderSupplierResponse, len(provider.Suppliers))
+	for i, supplier := range provider.Suppliers {
+		suppliers[i] = schema.ProviderSupplierResponse{
+			SupplierCode: supplier.Code,
+			Weight:       provider.SupplierWeight(supplier.ID),
+		}
+	}
+	return &schema.ProviderAdminResponse{
+		ID:        provider.ID,
+		Code:      provider.Code,
+		Source:    provider.Source,
+		Type:      provider.Type,
+		Discount:  provider.Discount,
+		Suppliers: suppliers,
+	}
+}
+
+func ProviderSecretResponseFromModel(provider *model.Provider) *schema.ProviderSecretResponse {
+	return &schema.ProviderSecretResponse{
+		ProviderAdminResponse: *ProviderAdminResponseFromModel(provider),
+		CallbackSecret:        provider.CallbackSecret,
+	}
+}
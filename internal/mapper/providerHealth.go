@@ -0,0 +1,22 @@
+package mapper
+
+import (
+	"top-up-api/internal/schema"
+	"top-up-api/pkg/circuitbreaker"
+)
+
+func ProviderHealthResponseFromSnapshot(providerCode string, snapshot circuitbreaker.Snapshot) schema.ProviderHealthResponse {
+	response := schema.ProviderHealthResponse{
+		ProviderCode: providerCode,
+		State:        string(snapshot.State),
+		Requests:     snapshot.Requests,
+		SuccessRate:  snapshot.SuccessRate,
+		AvgLatencyMs: snapshot.AvgLatency.Milliseconds(),
+		HealthScore:  snapshot.HealthScore,
+	}
+	if !snapshot.OpenedAt.IsZero() {
+		openedAt := snapshot.OpenedAt
+		response.OpenedAt = &openedAt
+	}
+	return response
+}
@@ -0,0 +1,70 @@
+package mapper
+
+import (
+	"strconv"
+	"top-up-api/internal/model"
+	"top-up-api/internal/schema"
+)
+
+// SettlementCSVHeader names the columns of SettlementCSVRecord.
+var SettlementCSVHeader = []string{"day", "provider_code", "supplier_code", "supplier_name", "orders", "face_value", "amount_charged", "cash_back"}
+
+func SettlementReportRowFromModel(row *model.SettlementRow) *schema.SettlementReportRow {
+	return &schema.SettlementReportRow{
+		Day:           row.Day,
+		ProviderCode:  row.ProviderCode,
+		SupplierCode:  row.SupplierCode,
+		SupplierName:  row.SupplierName,
+		Orders:        row.Orders,
+		FaceValue:     row.FaceValue,
+		AmountCharged: row.AmountCharged,
+		CashBack:      row.CashBack,
+	}
+}
+
+func SettlementCSVRecord(row *schema.SettlementReportRow) []string {
+	return []string{
+		row.Day,
+		row.ProviderCode,
+		row.SupplierCode,
+		row.SupplierName,
+		strconv.Itoa(row.Orders),
+		strconv.Itoa(row.FaceValue),
+		strconv.Itoa(row.AmountCharged),
+		strconv.Itoa(row.CashBack),
+	}
+}
+
+func SettlementImportResponseFromModel(settlementImport *model.SettlementImport) *schema.SettlementImportResponse {
+	response := &schema.SettlementImportResponse{
+		ID:               settlementImport.ID,
+		ProviderCode:     settlementImport.ProviderCode,
+		Day:              settlementImport.Day.Format("2006-01-02"),
+		FileName:         settlementImport.FileName,
+		RowCount:         settlementImport.RowCount,
+		MatchedCount:     settlementImport.MatchedCount,
+		DiscrepancyCount: settlementImport.DiscrepancyCount,
+		CreatedAt:        settlementImport.CreatedAt,
+	}
+	for i := range settlementImport.Discrepancies {
+		response.Discrepancies = append(response.Discrepancies, SettlementDiscrepancyResponseFromModel(&settlementImport.Discrepancies[i]))
+	}
+	return response
+}
+
+func SettlementDiscrepancyResponseFromModel(discrepancy *model.SettlementDiscrepancy) *schema.SettlementDiscrepancyResponse {
+	return &schema.SettlementDiscrepancyResponse{
+		ID:          discrepancy.ID,
+		ImportID:    discrepancy.ImportID,
+		OrderID:     discrepancy.OrderID,
+		Type:        discrepancy.Type,
+		OurAmount:   discrepancy.OurAmount,
+		TheirAmount: discrepancy.TheirAmount,
+		OurStatus:   discrepancy.OurStatus,
+		TheirStatus: discrepancy.TheirStatus,
+		Status:      discrepancy.Status,
+		Note:        discrepancy.Note,
+		ResolvedAt:  discrepancy.ResolvedAt,
+		CreatedAt:   discrepancy.CreatedAt,
+	}
+}
@@ -48,3 +48,20 @@ func SkusGroupBySupplierFromModel(skus []model.Sku) *[]schema.SkusGroupBySupplie
 	}
 	return &result
 }
+
+func SkuFromRequest(request schema.SkuRequest) *model.Sku {
+	return &model.Sku{
+		SupplierCode: request.SupplierCode,
+		CashBackCode: request.CashBackCode,
+		Price:        request.Price,
+	}
+}
+
+func SkuAdminResponseFromModel(sku *model.Sku) *schema.SkuAdminResponse {
+	return &schema.SkuAdminResponse{
+		ID:           sku.ID,
+		SupplierCode: sku.SupplierCode,
+		CashBackCode: sku.CashBackCode,
+		Price:        sku.Price,
+	}
+}
@@ -13,3 +13,28 @@ func SupplierResponseFromModel(supplier *model.Supplier) *schema.SupplierRespons
 		Status: supplier.Status,
 	}
 }
+
+func SupplierFromRequest(request schema.SupplierRequest) *model.Supplier {
+	routingStrategy := request.RoutingStrategy
+	if routingStrategy == "" {
+		routingStrategy = model.RoutingStrategyWeightedRandom
+	}
+	return &model.Supplier{
+		Code:            request.Code,
+		Name:            request.Name,
+		LogoUrl:         request.LogoUrl,
+		Status:          request.Status,
+		RoutingStrategy: routingStrategy,
+	}
+}
+
+func SupplierAdminResponseFromModel(supplier *model.Supplier) *schema.SupplierAdminResponse {
+	return &schema.SupplierAdminResponse{
+		ID:              supplier.ID,
+		Code:            supplier.Code,
+		Name:            supplier.Name,
+		LogoUrl:         supplier.LogoUrl,
+		Status:          supplier.Status,
+		RoutingStrategy: supplier.RoutingStrategy,
+	}
+}
@@ -0,0 +1,30 @@
+package mapper
+
+import (
+	"top-up-api/internal/model"
+	"top-up-api/internal/schema"
+)
+
+// LedgerTransactionForOrder moves amount into the user's wallet from a system
+// account, a negative amount moves it out of the wallet.
+func LedgerTransactionForOrder(transactionType model.LedgerTransactionType, orderID uint, wallet, system *model.LedgerAccount, amount int) *model.LedgerTransaction {
+	return &model.LedgerTransaction{
+		Type:    transactionType,
+		OrderID: orderID,
+		Entries: []model.LedgerEntry{
+			{AccountID: wallet.ID, Amount: amount},
+			{AccountID: system.ID, Amount: -amount},
+		},
+	}
+}
+
+func WalletStatementEntryResponseFromModel(entry *model.LedgerEntry) *schema.WalletStatementEntryResponse {
+	return &schema.WalletStatementEntryResponse{
+		ID:           entry.ID,
+		Type:         entry.Transaction.Type,
+		OrderID:      entry.Transaction.OrderID,
+		Amount:       entry.Amount,
+		BalanceAfter: entry.BalanceAfter,
+		CreatedAt:    entry.CreatedAt,
+	}
+}
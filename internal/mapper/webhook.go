@@ -0,0 +1,65 @@
+package mapper
+
+import (
+	"time"
+	"top-up-api/internal/model"
+	"top-up-api/internal/schema"
+)
+
+func WebhookSubscriptionFromRequest(userID uint, request schema.WebhookSubscriptionRequest) *model.WebhookSubscription {
+	active := true
+	if request.Active != nil {
+		active = *request.Active
+	}
+	return &model.WebhookSubscription{
+		UserID:     userID,
+		Name:       request.Name,
+		URL:        request.URL,
+		EventTypes: request.EventTypes,
+		Active:     active,
+	}
+}
+
+func WebhookSubscriptionResponseFromModel(subscription *model.WebhookSubscription) *schema.WebhookSubscriptionResponse {
+	return &schema.WebhookSubscriptionResponse{
+		ID:         subscription.ID,
+		UserID:     subscription.UserID,
+		Name:       subscription.Name,
+		URL:        subscription.URL,
+		EventTypes: subscription.EventTypes,
+		Active:     subscription.Active,
+		CreatedAt:  subscription.CreatedAt,
+	}
+}
+
+func WebhookDeliveryResponseFromModel(delivery *model.WebhookDelivery) *schema.WebhookDeliveryResponse {
+	return &schema.WebhookDeliveryResponse{
+		ID:             delivery.ID,
+		SubscriptionID: delivery.SubscriptionID,
+		EventType:      delivery.EventType,
+		AggregateID:    delivery.AggregateID,
+		Payload:        delivery.Payload,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		ResponseStatus: delivery.ResponseStatus,
+		LastError:      delivery.LastError,
+		NextAttemptAt:  delivery.NextAttemptAt,
+		DeliveredAt:    delivery.DeliveredAt,
+		CreatedAt:      delivery.CreatedAt,
+	}
+}
+
+func WebhookDeliveriesFromMessage(subscriptions []model.WebhookSubscription, message *model.OutboxMessage) []model.WebhookDelivery {
+	deliveries := make([]model.WebhookDelivery, len(subscriptions))
+	for i, subscription := range subscriptions {
+		deliveries[i] = model.WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			EventType:      message.EventType,
+			AggregateID:    message.AggregateID,
+			Payload:        message.Payload,
+			Status:         model.WebhookDeliveryStatusPending,
+			NextAttemptAt:  time.Now(),
+		}
+	}
+	return deliveries
+}
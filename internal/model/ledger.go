@@ -0,0 +1,84 @@
+package model
+
+import "time"
+
+type LedgerAccountType string
+
+const (
+	// LedgerAccountTypeWallet is the wallet of a user, there is one per user
+	LedgerAccountTypeWallet LedgerAccountType = "wallet"
+	// LedgerAccountTypeCashBack is the system account funding cashback credits
+	LedgerAccountTypeCashBack LedgerAccountType = "cashback"
+	// LedgerAccountTypeOrderPayment is the system account receiving wallet payments of orders
+	LedgerAccountTypeOrderPayment LedgerAccountType = "order_payment"
+)
+
+type LedgerTransactionType string
+
+const (
+	LedgerTransactionTypeCashBack         LedgerTransactionType = "cashback"
+	LedgerTransactionTypeCashBackReversal LedgerTransactionType = "cashback_reversal"
+	LedgerTransactionTypeOrderPayment     LedgerTransactionType = "order_payment"
+	LedgerTransactionTypeOrderRefund      LedgerTransactionType = "order_refund"
+)
+
+// AllowsOverdraft reports whether the transaction may take a wallet below zero.
+// Only a cashback reversal does, the user may already have spent the cashback.
+func (t LedgerTransactionType) AllowsOverdraft() bool {
+	return t == LedgerTransactionTypeCashBackReversal
+}
+
+// LedgerAccount holds money in the ledger. Balance is the sum of the account's
+// entries, kept on the account so it can be checked while posting. System
+// accounts have no user and a UserID of 0.
+type LedgerAccount struct {
+	ID        uint              `json:"id" gorm:"primarykey"`
+	Type      LedgerAccountType `json:"type" gorm:"type:ledger_account_type; not null; uniqueIndex:uni_ledger_accounts_type_user_id"`
+	UserID    uint              `json:"user_id" gorm:"not null; default:0; uniqueIndex:uni_ledger_accounts_type_user_id"`
+	Balance   int               `json:"balance" gorm:"not null; default:0"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+func (LedgerAccount) TableName() string {
+	return "ledger_accounts"
+}
+
+// LedgerTransaction moves money between accounts for an order. Its entries sum
+// up to zero, and an order has at most one transaction of every type.
+type LedgerTransaction struct {
+	ID        uint                  `json:"id" gorm:"primarykey"`
+	Type      LedgerTransactionType `json:"type" gorm:"type:ledger_transaction_type; not null; uniqueIndex:uni_ledger_transactions_order_id_type"`
+	OrderID   uint                  `json:"order_id" gorm:"not null; uniqueIndex:uni_ledger_transactions_order_id_type"`
+	CreatedAt time.Time             `json:"created_at"`
+	Entries   []LedgerEntry         `json:"entries" gorm:"foreignKey:TransactionID"`
+}
+
+func (LedgerTransaction) TableName() string {
+	return "ledger_transactions"
+}
+
+// Balanced reports whether the entries of the transaction sum up to zero.
+func (t *LedgerTransaction) Balanced() bool {
+	sum := 0
+	for _, entry := range t.Entries {
+		sum += entry.Amount
+	}
+	return len(t.Entries) > 0 && sum == 0
+}
+
+// LedgerEntry is one side of a transaction. Amount is added to the account,
+// BalanceAfter is the account balance once the entry was posted.
+type LedgerEntry struct {
+	ID            uint              `json:"id" gorm:"primarykey"`
+	TransactionID uint              `json:"transaction_id" gorm:"not null; index"`
+	AccountID     uint              `json:"account_id" gorm:"not null; index:idx_ledger_entries_account_id_id"`
+	Amount        int               `json:"amount" gorm:"not null"`
+	BalanceAfter  int               `json:"balance_after" gorm:"not null"`
+	CreatedAt     time.Time         `json:"created_at"`
+	Transaction   LedgerTransaction `json:"transaction" gorm:"foreignKey:TransactionID"`
+}
+
+func (LedgerEntry) TableName() string {
+	return "ledger_entries"
+}
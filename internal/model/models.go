@@ -1,11 +0,0 @@
-package model
-
-func GetModels() []interface{} {
-	return []interface{}{
-		&Sku{},
-		&CashBack{},
-		&Provider{},
-		&Supplier{},
-		&PurchaseHistory{},
-	}
-}
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Order is a top-up of one phone number. The orders of a batch carry its
+// BatchID and their line in it, DispatchedAt is set once the batch dispatcher
+// picked them up.
+type Order struct {
+	gorm.Model
+	OrderID       uint                  `json:"order_id" gorm:"not null;uniqueIndex"`
+	UserID        uint                  `json:"user_id" gorm:"not null;index"`
+	SkuID         uint                  `json:"sku_id" gorm:"not null"`
+	TotalPrice    int                   `json:"total_price" gorm:"not null"`
+	PhoneNumber   string                `json:"phone_number" gorm:"not null"`
+	CashBackValue int                   `json:"cash_back_value" gorm:"default:0"`
+	WalletAmount  int                   `json:"wallet_amount" gorm:"not null;default:0"`
+	Status        PurchaseHistoryStatus `json:"status" gorm:"type:purchase_history_status; not null"`
+	ProviderCode  string                `json:"provider_code" gorm:"not null;default:''"`
+	LockFence     int64                 `json:"-" gorm:"not null;default:0"`
+	BatchID       *uint                 `json:"batch_id" gorm:"index"`
+	BatchLine     int                   `json:"batch_line" gorm:"not null;default:0"`
+	DispatchedAt  *time.Time            `json:"dispatched_at"`
+	Sku           Sku                   `json:"sku" gorm:"foreignKey:SkuID;references:ID"`
+	Promotions    []PromotionRedemption `json:"promotions" gorm:"foreignKey:OrderID;references:OrderID"`
+}
+
+func (Order) TableName() string {
+	return "orders"
+}
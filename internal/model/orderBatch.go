@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// OrderBatch tops up many phone numbers of a business customer with a single
+// payment. Each line is an order of the batch, Status is the one of the
+// payment and PaymentReference the payment transaction that paid the batch.
+type OrderBatch struct {
+	ID               uint                  `json:"id" gorm:"primarykey"`
+	BatchID          uint                  `json:"batch_id" gorm:"not null;uniqueIndex"`
+	UserID           uint                  `json:"user_id" gorm:"not null;index"`
+	TotalPrice       int                   `json:"total_price" gorm:"not null"`
+	CashBackValue    int                   `json:"cash_back_value" gorm:"not null;default:0"`
+	LineCount        int                   `json:"line_count" gorm:"not null"`
+	Status           PurchaseHistoryStatus `json:"status" gorm:"type:purchase_history_status; not null"`
+	PaymentReference string                `json:"payment_reference" gorm:"not null;default:''"`
+	CreatedAt        time.Time             `json:"created_at"`
+	UpdatedAt        time.Time             `json:"updated_at"`
+	Orders           []Order               `json:"orders" gorm:"foreignKey:BatchID;references:BatchID"`
+}
+
+func (OrderBatch) TableName() string {
+	return "order_batches"
+}
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+type OrderReviewStatus string
+
+const (
+	OrderReviewStatusOpen     OrderReviewStatus = "open"
+	OrderReviewStatusResolved OrderReviewStatus = "resolved"
+)
+
+// OrderReview is a confirmed order the reconciliation couldn't settle with its
+// provider, queued for an operator. Resolution is the status the order was
+// settled with, Note says why.
+type OrderReview struct {
+	ID           uint                   `json:"id" gorm:"primarykey"`
+	OrderID      uint                   `json:"order_id" gorm:"not null;uniqueIndex"`
+	ProviderCode string                 `json:"provider_code" gorm:"not null;default:''"`
+	Reason       string                 `json:"reason" gorm:"not null"`
+	Status       OrderReviewStatus      `json:"status" gorm:"type:order_review_status; not null; default:open; index"`
+	Resolution   *PurchaseHistoryStatus `json:"resolution" gorm:"type:purchase_history_status"`
+	Note         string                 `json:"note" gorm:"not null;default:''"`
+	ResolvedAt   *time.Time             `json:"resolved_at"`
+	CreatedAt    time.Time              `json:"created_at"`
+	UpdatedAt    time.Time              `json:"updated_at"`
+}
+
+func (OrderReview) TableName() string {
+	return "order_reviews"
+}
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+type OrderStatusEventSource string
+
+const (
+	OrderStatusEventSourceHTTP             OrderStatusEventSource = "http"
+	OrderStatusEventSourceGRPC             OrderStatusEventSource = "grpc"
+	OrderStatusEventSourceKafka            OrderStatusEventSource = "kafka"
+	OrderStatusEventSourceProviderCallback OrderStatusEventSource = "provider_callback"
+	OrderStatusEventSourceDispatcher       OrderStatusEventSource = "dispatcher"
+	OrderStatusEventSourceExpirySweeper    OrderStatusEventSource = "expiry_sweeper"
+	OrderStatusEventSourceReconciler       OrderStatusEventSource = "reconciler"
+	OrderStatusEventSourceManualReview     OrderStatusEventSource = "manual_review"
+	OrderStatusEventSourceAdmin            OrderStatusEventSource = "admin"
+)
+
+// OrderStatusEvent is an append-only record of a single order status change.
+// PreviousStatus is nil for the event that creates the order. Reason is the note
+// of the operator who made the change, if any.
+type OrderStatusEvent struct {
+	ID             uint                   `json:"id" gorm:"primarykey"`
+	OrderID        uint                   `json:"order_id" gorm:"not null;index"`
+	Source         OrderStatusEventSource `json:"source" gorm:"type:order_status_event_source; not null"`
+	PreviousStatus *PurchaseHistoryStatus `json:"previous_status" gorm:"type:purchase_history_status"`
+	Status         PurchaseHistoryStatus  `json:"status" gorm:"type:purchase_history_status; not null"`
+	Reason         string                 `json:"reason" gorm:"not null;default:''"`
+	CreatedAt      time.Time              `json:"created_at" gorm:"not null"`
+}
+
+func (OrderStatusEvent) TableName() string {
+	return "order_status_events"
+}
@@ -0,0 +1,56 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type OutboxChannel string
+
+const (
+	OutboxChannelHTTP  OutboxChannel = "http"
+	OutboxChannelKafka OutboxChannel = "kafka"
+	// OutboxChannelWebhook hands the event to the webhook subscriptions of its type
+	OutboxChannelWebhook OutboxChannel = "webhook"
+)
+
+type OutboxStatus string
+
+const (
+	OutboxStatusPending   OutboxStatus = "pending"
+	OutboxStatusDelivered OutboxStatus = "delivered"
+	OutboxStatusDead      OutboxStatus = "dead"
+)
+
+const (
+	OutboxEventOrderCreated = "order.created"
+	OutboxEventOrderFailed  = "order.failed"
+	OutboxEventOrderExpired = "order.expired"
+
+	OutboxEventOrderBatchCreated = "order_batch.created"
+	OutboxEventOrderBatchExpired = "order_batch.expired"
+)
+
+// OutboxMessage is an outbound notification written in the same transaction as
+// the change it announces. Destination is a URL for HTTP messages, a topic
+// for Kafka messages and the event type for webhook messages.
+type OutboxMessage struct {
+	gorm.Model
+	AggregateID   uint          `json:"aggregate_id" gorm:"not null;index"`
+	EventType     string        `json:"event_type" gorm:"not null"`
+	Channel       OutboxChannel `json:"channel" gorm:"type:outbox_channel; not null"`
+	Destination   string        `json:"destination" gorm:"not null"`
+	Method        string        `json:"method"`
+	MessageKey    string        `json:"message_key"`
+	Payload       string        `json:"payload" gorm:"type:text; not null"`
+	Status        OutboxStatus  `json:"status" gorm:"type:outbox_status; not null; default:pending; index:idx_outbox_status_next_attempt"`
+	Attempts      int           `json:"attempts" gorm:"not null; default:0"`
+	NextAttemptAt time.Time     `json:"next_attempt_at" gorm:"not null; index:idx_outbox_status_next_attempt"`
+	LastError     string        `json:"last_error"`
+	DeliveredAt   *time.Time    `json:"delivered_at"`
+}
+
+func (OutboxMessage) TableName() string {
+	return "outbox_messages"
+}
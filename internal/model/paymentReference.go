@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// PaymentReference records the payment transaction that paid an order or a
+// batch, whichever of OrderID and BatchID is set. A reference is only
+// recorded once, so a payment can't pay both an order and a batch.
+type PaymentReference struct {
+	Reference string    `json:"reference" gorm:"primaryKey"`
+	OrderID   *uint     `json:"order_id"`
+	BatchID   *uint     `json:"batch_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (PaymentReference) TableName() string {
+	return "payment_references"
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Promotion is a cashback campaign. It runs from StartsAt until EndsAt, a
+// promotion without an end runs until it is deactivated. Empty SupplierCodes
+// and SkuIDs target every sku. A voucher promotion only applies when its code
+// is entered at checkout, the others apply on their own.
+//
+// A stackable promotion adds up with the other stackable ones and the cash back
+// rule of the sku, any other promotion is applied alone.
+type Promotion struct {
+	gorm.Model
+	Code            string       `json:"code" gorm:"not null;unique"`
+	Name            string       `json:"name" gorm:"not null"`
+	Type            CashBackType `json:"type" gorm:"type:cash_back_type; not null"`
+	Value           int          `json:"value" gorm:"not null"`
+	MaxCashBack     int          `json:"max_cash_back" gorm:"not null;default:0"`
+	StartsAt        time.Time    `json:"starts_at" gorm:"not null"`
+	EndsAt          *time.Time   `json:"ends_at"`
+	SupplierCodes   []string     `json:"supplier_codes" gorm:"type:jsonb;serializer:json;not null"`
+	SkuIDs          []uint       `json:"sku_ids" gorm:"type:jsonb;serializer:json;not null"`
+	GlobalLimit     int          `json:"global_limit" gorm:"not null;default:0"`
+	PerUserLimit    int          `json:"per_user_limit" gorm:"not null;default:0"`
+	RedemptionCount int          `json:"redemption_count" gorm:"not null;default:0"`
+	Stackable       bool         `json:"stackable" gorm:"not null;default:false"`
+	Voucher         bool         `json:"voucher" gorm:"not null;default:false"`
+	Active          bool         `json:"active" gorm:"not null;default:true"`
+}
+
+func (Promotion) TableName() string {
+	return "promotions"
+}
+
+// Targets reports whether the promotion applies to the sku.
+func (p *Promotion) Targets(sku *Sku) bool {
+	if len(p.SupplierCodes) > 0 && !slices.Contains(p.SupplierCodes, sku.SupplierCode) {
+		return false
+	}
+	return len(p.SkuIDs) == 0 || slices.Contains(p.SkuIDs, sku.ID)
+}
+
+// CashBackFor returns the cashback of the promotion on price. A percentage
+// cashback is capped at MaxCashBack when it is set.
+func (p *Promotion) CashBackFor(price int) int {
+	if p.Type == CashBackTypeFixed {
+		return p.Value
+	}
+	cashBack := int(float64(price) * float64(p.Value) / 100)
+	if p.MaxCashBack > 0 && cashBack > p.MaxCashBack {
+		return p.MaxCashBack
+	}
+	return cashBack
+}
+
+// PromotionRedemption records a promotion applied to an order. A redemption
+// counts toward the limits of the promotion until the order fails and
+// releases it.
+type PromotionRedemption struct {
+	ID            uint       `json:"id" gorm:"primarykey"`
+	PromotionID   uint       `json:"promotion_id" gorm:"not null; uniqueIndex:uni_promotion_redemptions_promotion_id_order_id; index:idx_promotion_redemptions_promotion_id_user_id"`
+	OrderID       uint       `json:"order_id" gorm:"not null; uniqueIndex:uni_promotion_redemptions_promotion_id_order_id; index"`
+	UserID        uint       `json:"user_id" gorm:"not null; index:idx_promotion_redemptions_promotion_id_user_id"`
+	Code          string     `json:"code" gorm:"not null"`
+	CashBackValue int        `json:"cash_back_value" gorm:"not null"`
+	ReleasedAt    *time.Time `json:"released_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (PromotionRedemption) TableName() string {
+	return "promotion_redemptions"
+}
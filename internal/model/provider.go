@@ -11,13 +11,38 @@ const (
 
 type Provider struct {
 	gorm.Model
-	Code      string     `json:"code" gorm:"unique;not null"`
-	Source    string     `json:"source"`
-	Type      string     `json:"type" orm:"type:provider_type; not null"`
-	Weight    int        `json:"weight"`
-	Suppliers []Supplier `json:"suppliers" gorm:"many2many:provider_suppliers;"`
+	Code              string             `json:"code" gorm:"unique;not null"`
+	Source            string             `json:"source"`
+	Type              string             `json:"type" orm:"type:provider_type; not null"`
+	Discount          float64            `json:"discount" gorm:"not null;default:0"`
+	CallbackSecret    string             `json:"-" gorm:"not null;default:''"`
+	Suppliers         []Supplier         `json:"suppliers" gorm:"many2many:provider_suppliers;"`
+	ProviderSuppliers []ProviderSupplier `json:"-" gorm:"foreignKey:ProviderID"`
 }
 
 func (Provider) TableName() string {
 	return "provider"
 }
+
+// ProviderSupplier is a provider_suppliers row, the weight decides how much of
+// the supplier's traffic the provider takes.
+type ProviderSupplier struct {
+	ProviderID uint `gorm:"primaryKey"`
+	SupplierID uint `gorm:"primaryKey"`
+	Weight     int  `gorm:"not null;default:0"`
+}
+
+func (ProviderSupplier) TableName() string {
+	return "provider_suppliers"
+}
+
+// SupplierWeight is the weight of the provider for the supplier, zero when it
+// does not serve it.
+func (p Provider) SupplierWeight(supplierID uint) int {
+	for _, providerSupplier := range p.ProviderSuppliers {
+		if providerSupplier.SupplierID == supplierID {
+			return providerSupplier.Weight
+		}
+	}
+	return 0
+}
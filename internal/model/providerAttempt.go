@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// ProviderAttempt records a single request sent to a provider while
+// dispatching an order. Attempt counts from 1 for every provider tried.
+type ProviderAttempt struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	OrderID      uint      `json:"order_id" gorm:"not null;index"`
+	ProviderCode string    `json:"provider_code" gorm:"not null"`
+	Attempt      int       `json:"attempt" gorm:"not null"`
+	Success      bool      `json:"success" gorm:"not null"`
+	Error        string    `json:"error"`
+	DurationMs   int64     `json:"duration_ms" gorm:"not null"`
+	CreatedAt    time.Time `json:"created_at" gorm:"not null"`
+}
+
+func (ProviderAttempt) TableName() string {
+	return "provider_attempts"
+}
@@ -0,0 +1,81 @@
+package model
+
+import "time"
+
+// SettlementRow sums up the successful orders a provider handled for a
+// supplier on one day. It is computed from the purchase history, not stored.
+type SettlementRow struct {
+	Day           string
+	ProviderCode  string
+	SupplierCode  string
+	SupplierName  string
+	Orders        int
+	FaceValue     int
+	AmountCharged int
+	CashBack      int
+}
+
+type SettlementDiscrepancyType string
+
+const (
+	// SettlementDiscrepancyTypeMissingOurs is an order the provider fulfilled
+	// that has no purchase history
+	SettlementDiscrepancyTypeMissingOurs SettlementDiscrepancyType = "missing_ours"
+	// SettlementDiscrepancyTypeMissingTheirs is an order that succeeded with the
+	// provider on the day of the file that the file doesn't list
+	SettlementDiscrepancyTypeMissingTheirs SettlementDiscrepancyType = "missing_theirs"
+	// SettlementDiscrepancyTypeAmountMismatch is an order both sides fulfilled
+	// for different amounts
+	SettlementDiscrepancyTypeAmountMismatch SettlementDiscrepancyType = "amount_mismatch"
+	// SettlementDiscrepancyTypeStatusMismatch is an order one side fulfilled
+	// and the other didn't
+	SettlementDiscrepancyTypeStatusMismatch SettlementDiscrepancyType = "status_mismatch"
+)
+
+type SettlementDiscrepancyStatus string
+
+const (
+	SettlementDiscrepancyStatusOpen     SettlementDiscrepancyStatus = "open"
+	SettlementDiscrepancyStatusResolved SettlementDiscrepancyStatus = "resolved"
+)
+
+// SettlementImport is a settlement file of a provider diffed against the
+// purchase history of its day.
+type SettlementImport struct {
+	ID               uint                    `json:"id" gorm:"primarykey"`
+	ProviderCode     string                  `json:"provider_code" gorm:"not null"`
+	Day              time.Time               `json:"day" gorm:"type:date; not null"`
+	FileName         string                  `json:"file_name" gorm:"not null;default:''"`
+	RowCount         int                     `json:"row_count" gorm:"not null"`
+	MatchedCount     int                     `json:"matched_count" gorm:"not null"`
+	DiscrepancyCount int                     `json:"discrepancy_count" gorm:"not null"`
+	CreatedAt        time.Time               `json:"created_at"`
+	Discrepancies    []SettlementDiscrepancy `json:"discrepancies" gorm:"foreignKey:ImportID"`
+}
+
+func (SettlementImport) TableName() string {
+	return "settlement_imports"
+}
+
+// SettlementDiscrepancy is an order the two sides of a settlement disagree on.
+// The amounts are face values and an amount or status is missing on the side
+// that doesn't know the order. Finance resolves it with a note.
+type SettlementDiscrepancy struct {
+	ID          uint                        `json:"id" gorm:"primarykey"`
+	ImportID    uint                        `json:"import_id" gorm:"not null; index"`
+	OrderID     uint                        `json:"order_id" gorm:"not null"`
+	Type        SettlementDiscrepancyType   `json:"type" gorm:"type:settlement_discrepancy_type; not null"`
+	OurAmount   *int                        `json:"our_amount"`
+	TheirAmount *int                        `json:"their_amount"`
+	OurStatus   string                      `json:"our_status" gorm:"not null;default:''"`
+	TheirStatus string                      `json:"their_status" gorm:"not null;default:''"`
+	Status      SettlementDiscrepancyStatus `json:"status" gorm:"type:settlement_discrepancy_status; not null; default:open; index"`
+	Note        string                      `json:"note" gorm:"not null;default:''"`
+	ResolvedAt  *time.Time                  `json:"resolved_at"`
+	CreatedAt   time.Time                   `json:"created_at"`
+	UpdatedAt   time.Time                   `json:"updated_at"`
+}
+
+func (SettlementDiscrepancy) TableName() string {
+	return "settlement_discrepancies"
+}
@@ -11,13 +11,25 @@ const (
 	SupplierStatusInactive SupplierStatus = "inactive"
 )
 
+// RoutingStrategy decides which of a supplier's providers gets an order first
+type RoutingStrategy string
+
+const (
+	RoutingStrategyWeightedRandom    RoutingStrategy = "weighted_random"
+	RoutingStrategyLeastCost         RoutingStrategy = "least_cost"
+	RoutingStrategyLowestLatency     RoutingStrategy = "lowest_latency"
+	RoutingStrategyRoundRobin        RoutingStrategy = "round_robin"
+	RoutingStrategyStickyPhonePrefix RoutingStrategy = "sticky_phone_prefix"
+)
+
 type Supplier struct {
 	gorm.Model
-	Code    string         `json:"code" gorm:"not null;unique"`
-	Name    string         `json:"name" gorm:"not null;unique"`
-	LogoUrl string         `json:"logo_url" gorm:"not null"`
-	Status  SupplierStatus `json:"status" gorm:"type:supplier_status; not null"`
-	Providers []Provider `json:"providers" gorm:"many2many:provider_suppliers;"`
+	Code            string          `json:"code" gorm:"not null;unique"`
+	Name            string          `json:"name" gorm:"not null;unique"`
+	LogoUrl         string          `json:"logo_url" gorm:"not null"`
+	Status          SupplierStatus  `json:"status" gorm:"type:supplier_status; not null"`
+	RoutingStrategy RoutingStrategy `json:"routing_strategy" gorm:"type:routing_strategy;not null;default:weighted_random"`
+	Providers       []Provider      `json:"providers" gorm:"many2many:provider_suppliers;"`
 }
 
 func (Supplier) TableName() string {
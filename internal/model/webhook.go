@@ -0,0 +1,58 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	WebhookEventOrderSucceeded = "order.succeeded"
+	WebhookEventOrderFailed    = "order.failed"
+)
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryStatusDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryStatusFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookSubscription is a partner endpoint and the events it is told about,
+// only events of the orders of UserID reach it. Secret signs every delivery
+// so the partner can check where it came from.
+type WebhookSubscription struct {
+	gorm.Model
+	UserID     uint     `json:"user_id" gorm:"not null;index"`
+	Name       string   `json:"name" gorm:"not null"`
+	URL        string   `json:"url" gorm:"not null"`
+	Secret     string   `json:"-" gorm:"not null"`
+	EventTypes []string `json:"event_types" gorm:"type:jsonb;serializer:json;not null"`
+	Active     bool     `json:"active" gorm:"not null;default:true"`
+}
+
+func (WebhookSubscription) TableName() string {
+	return "webhook_subscriptions"
+}
+
+// WebhookDelivery is one event sent to one subscription, it doubles as the
+// delivery log with the result of the last attempt.
+type WebhookDelivery struct {
+	gorm.Model
+	SubscriptionID uint                  `json:"subscription_id" gorm:"not null;index"`
+	EventType      string                `json:"event_type" gorm:"not null"`
+	AggregateID    uint                  `json:"aggregate_id" gorm:"not null;index"`
+	Payload        string                `json:"payload" gorm:"type:text; not null"`
+	Status         WebhookDeliveryStatus `json:"status" gorm:"type:webhook_delivery_status; not null; default:pending; index:idx_webhook_status_next_attempt"`
+	Attempts       int                   `json:"attempts" gorm:"not null; default:0"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at" gorm:"not null; index:idx_webhook_status_next_attempt"`
+	ResponseStatus int                   `json:"response_status"`
+	LastError      string                `json:"last_error"`
+	DeliveredAt    *time.Time            `json:"delivered_at"`
+	Subscription   WebhookSubscription   `json:"subscription" gorm:"foreignKey:SubscriptionID"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
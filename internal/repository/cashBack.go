@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"top-up-api/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type CashBackRepository interface {
+	GetCashBackByID(ctx context.Context, id uint) (*model.CashBack, error)
+	CreateCashBack(ctx context.Context, cashBack *model.CashBack) error
+	UpdateCashBack(ctx context.Context, cashBack *model.CashBack) error
+	DeleteCashBack(ctx context.Context, id uint) error
+}
+
+type cashBackRepository struct {
+	db *gorm.DB
+}
+
+var _ CashBackRepository = (*cashBackRepository)(nil)
+
+func NewCashBackRepository(db *gorm.DB) *cashBackRepository {
+	return &cashBackRepository{db: db}
+}
+
+func (r *cashBackRepository) GetCashBackByID(ctx context.Context, id uint) (*model.CashBack, error) {
+	var cashBack model.CashBack
+	if err := getDB(ctx, r.db).First(&cashBack, id).Error; err != nil {
+		return nil, err
+	}
+	return &cashBack, nil
+}
+
+func (r *cashBackRepository) CreateCashBack(ctx context.Context, cashBack *model.CashBack) error {
+	return getDB(ctx, r.db).Create(cashBack).Error
+}
+
+func (r *cashBackRepository) UpdateCashBack(ctx context.Context, cashBack *model.CashBack) error {
+	return getDB(ctx, r.db).Save(cashBack).Error
+}
+
+// DeleteCashBack soft deletes the cash back and returns gorm.ErrRecordNotFound when there is none
+func (r *cashBackRepository) DeleteCashBack(ctx context.Context, id uint) error {
+	result := getDB(ctx, r.db).Delete(&model.CashBack{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
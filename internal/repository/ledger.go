@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+	"top-up-api/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInsufficientBalance rejects a transaction that would take a wallet below zero.
+	ErrInsufficientBalance = errors.New("insufficient wallet balance")
+	// ErrUnbalancedTransaction rejects a transaction whose entries don't sum up to zero.
+	ErrUnbalancedTransaction = errors.New("ledger transaction entries must sum up to zero")
+)
+
+type LedgerRepository interface {
+	GetOrCreateWalletAccount(ctx context.Context, userID uint) (*model.LedgerAccount, error)
+	GetWalletAccount(ctx context.Context, userID uint) (*model.LedgerAccount, error)
+	GetSystemAccount(ctx context.Context, accountType model.LedgerAccountType) (*model.LedgerAccount, error)
+	CreateLedgerTransaction(ctx context.Context, transaction *model.LedgerTransaction) error
+	GetLedgerEntriesByAccountIDPaginated(ctx context.Context, accountID uint, page, pageSize int) ([]model.LedgerEntry, int64, error)
+}
+
+type ledgerRepository struct {
+	db *gorm.DB
+}
+
+var _ LedgerRepository = (*ledgerRepository)(nil)
+
+func NewLedgerRepository(db *gorm.DB) *ledgerRepository {
+	return &ledgerRepository{db: db}
+}
+
+func (r *ledgerRepository) GetOrCreateWalletAccount(ctx context.Context, userID uint) (*model.LedgerAccount, error) {
+	account := model.LedgerAccount{Type: model.LedgerAccountTypeWallet, UserID: userID}
+	if err := getDB(ctx, r.db).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&account).Error; err != nil {
+		return nil, err
+	}
+	return r.GetWalletAccount(ctx, userID)
+}
+
+// GetWalletAccount returns gorm.ErrRecordNotFound for a user that never had a wallet.
+func (r *ledgerRepository) GetWalletAccount(ctx context.Context, userID uint) (*model.LedgerAccount, error) {
+	var account model.LedgerAccount
+	if err := getDB(ctx, r.db).
+		Where("type = ? AND user_id = ?", model.LedgerAccountTypeWallet, userID).
+		First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (r *ledgerRepository) GetSystemAccount(ctx context.Context, accountType model.LedgerAccountType) (*model.LedgerAccount, error) {
+	var account model.LedgerAccount
+	if err := getDB(ctx, r.db).
+		Where("type = ? AND user_id = 0", accountType).
+		First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// CreateLedgerTransaction posts the transaction and its entries and moves the
+// balances of their accounts. It returns ErrInsufficientBalance when a wallet
+// would go below zero, unless the transaction type allows it, so it must run
+// inside a transaction.
+func (r *ledgerRepository) CreateLedgerTransaction(ctx context.Context, transaction *model.LedgerTransaction) error {
+	if !transaction.Balanced() {
+		return ErrUnbalancedTransaction
+	}
+
+	// Accounts are always updated in the same order, so concurrent postings
+	// wait for each other instead of deadlocking.
+	sort.Slice(transaction.Entries, func(i, j int) bool {
+		return transaction.Entries[i].AccountID < transaction.Entries[j].AccountID
+	})
+
+	db := getDB(ctx, r.db)
+	for i := range transaction.Entries {
+		entry := &transaction.Entries[i]
+
+		var account model.LedgerAccount
+		query := db.Model(&account).
+			Clauses(clause.Returning{Columns: []clause.Column{{Name: "balance"}}}).
+			Where("id = ?", entry.AccountID)
+		if entry.Amount < 0 && !transaction.Type.AllowsOverdraft() {
+			query = query.Where("(type <> ? OR balance >= ?)", model.LedgerAccountTypeWallet, -entry.Amount)
+		}
+
+		result := query.UpdateColumns(map[string]interface{}{
+			"balance":    gorm.Expr("balance + ?", entry.Amount),
+			"updated_at": time.Now(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInsufficientBalance
+		}
+		entry.BalanceAfter = account.Balance
+	}
+
+	return db.Create(transaction).Error
+}
+
+// GetLedgerEntriesByAccountIDPaginated returns the entries of an account with
+// their transaction, newest first.
+func (r *ledgerRepository) GetLedgerEntriesByAccountIDPaginated(ctx context.Context, accountID uint, page, pageSize int) ([]model.LedgerEntry, int64, error) {
+	var entries []model.LedgerEntry
+	var total int64
+
+	if err := getDB(ctx, r.db).Model(&model.LedgerEntry{}).
+		Where("account_id = ?", accountID).
+		Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := getDB(ctx, r.db).
+		Where("account_id = ?", accountID).
+		Order("id DESC").
+		Limit(pageSize).
+		Offset(offset).
+		Preload("Transaction").
+		Find(&entries).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+	"top-up-api/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// ErrStaleFence rejects a write made under a lock that a newer holder took over.
+var ErrStaleFence = errors.New("order was changed under a newer lock")
+
+type OrderRepository interface {
+	CreateOrder(ctx context.Context, order *model.Order) error
+	GetOrderByOrderID(ctx context.Context, orderID uint) (*model.Order, error)
+	UpdateOrderStatusByOrderID(ctx context.Context, orderID uint, status model.PurchaseHistoryStatus, fence int64) error
+	AssignOrderProvider(ctx context.Context, orderID uint, providerCode string) error
+	GetOrderProviderCode(ctx context.Context, orderID uint) (string, error)
+	GetPendingOrderIDsCreatedBefore(ctx context.Context, createdBefore time.Time, limit int) ([]uint, error)
+	GetStuckOrders(ctx context.Context, updatedBefore time.Time, limit int) ([]model.Order, error)
+}
+
+type orderRepository struct {
+	db *gorm.DB
+}
+
+var _ OrderRepository = (*orderRepository)(nil)
+
+func NewOrderRepository(db *gorm.DB) *orderRepository {
+	return &orderRepository{db: db}
+}
+
+func (r *orderRepository) CreateOrder(ctx context.Context, order *model.Order) error {
+	return getDB(ctx, r.db).Create(order).Error
+}
+
+func (r *orderRepository) GetOrderByOrderID(ctx context.Context, orderID uint) (*model.Order, error) {
+	var order model.Order
+	if err := getDB(ctx, r.db).
+		Where("order_id = ?", orderID).
+		Preload("Sku").
+		Preload("Sku.Supplier").
+		Preload("Sku.CashBack").
+		Preload("Promotions", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id")
+		}).
+		First(&order).Error; err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// UpdateOrderStatusByOrderID writes the status under the lock holding fencing
+// token fence. It returns ErrStaleFence when a later lock holder already changed
+// the order, or when there is no order.
+func (r *orderRepository) UpdateOrderStatusByOrderID(ctx context.Context, orderID uint, status model.PurchaseHistoryStatus, fence int64) error {
+	result := getDB(ctx, r.db).Model(&model.Order{}).
+		Where("order_id = ? AND lock_fence <= ?", orderID, fence).
+		Updates(map[string]interface{}{
+			"status":     status,
+			"lock_fence": fence,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrStaleFence
+	}
+	return nil
+}
+
+func (r *orderRepository) AssignOrderProvider(ctx context.Context, orderID uint, providerCode string) error {
+	return getDB(ctx, r.db).Model(&model.Order{}).
+		Where("order_id = ?", orderID).
+		Update("provider_code", providerCode).Error
+}
+
+// GetOrderProviderCode returns the provider the order was assigned to, empty
+// when it was never dispatched, and gorm.ErrRecordNotFound when there is no order
+func (r *orderRepository) GetOrderProviderCode(ctx context.Context, orderID uint) (string, error) {
+	var order model.Order
+	if err := getDB(ctx, r.db).
+		Select("provider_code").
+		Where("order_id = ?", orderID).
+		First(&order).Error; err != nil {
+		return "", err
+	}
+	return order.ProviderCode, nil
+}
+
+// GetPendingOrderIDsCreatedBefore returns the oldest orders still pending
+// payment that were created before createdBefore. The orders of a batch expire
+// with their batch, so they are left out.
+func (r *orderRepository) GetPendingOrderIDsCreatedBefore(ctx context.Context, createdBefore time.Time, limit int) ([]uint, error) {
+	var orderIDs []uint
+	if err := getDB(ctx, r.db).Model(&model.Order{}).
+		Where("status = ? AND created_at < ? AND batch_id IS NULL", model.PurchaseHistoryStatusPending, createdBefore).
+		Order("created_at").
+		Limit(limit).
+		Pluck("order_id", &orderIDs).Error; err != nil {
+		return nil, err
+	}
+	return orderIDs, nil
+}
+
+// GetStuckOrders returns the oldest confirmed orders that didn't change since
+// updatedBefore and aren't queued for review. Batch orders still waiting for the
+// batch dispatcher aren't stuck. Only their order ID, provider and update time
+// are loaded.
+func (r *orderRepository) GetStuckOrders(ctx context.Context, updatedBefore time.Time, limit int) ([]model.Order, error) {
+	var orders []model.Order
+	if err := getDB(ctx, r.db).
+		Select("order_id", "provider_code", "updated_at").
+		Where("status = ? AND updated_at < ?", model.PurchaseHistoryStatusConfirm, updatedBefore).
+		Where("batch_id IS NULL OR dispatched_at IS NOT NULL").
+		Where("NOT EXISTS (SELECT 1 FROM order_reviews WHERE order_reviews.order_id = orders.order_id)").
+		Order("updated_at").
+		Limit(limit).
+		Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
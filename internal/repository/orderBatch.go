@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+	"top-up-api/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// ErrOrderBatchStatusChanged rejects a batch status change when the batch left
+// the status it was read in.
+var ErrOrderBatchStatusChanged = errors.New("order batch status was changed")
+
+// _orderBatchInsertSize is how many orders of a batch go in one INSERT
+const _orderBatchInsertSize = 200
+
+type OrderBatchRepository interface {
+	CreateOrderBatch(ctx context.Context, batch *model.OrderBatch) error
+	GetOrderBatchByBatchID(ctx context.Context, batchID uint) (*model.OrderBatch, error)
+	UpdateOrderBatchStatus(ctx context.Context, batchID uint, from, to model.PurchaseHistoryStatus, paymentReference string) error
+	GetPendingOrderBatchIDsCreatedBefore(ctx context.Context, createdBefore time.Time, limit int) ([]uint, error)
+	ClaimOrderBatchOrders(ctx context.Context, limit int) ([]uint, error)
+}
+
+type orderBatchRepository struct {
+	db *gorm.DB
+}
+
+var _ OrderBatchRepository = (*orderBatchRepository)(nil)
+
+func NewOrderBatchRepository(db *gorm.DB) *orderBatchRepository {
+	return &orderBatchRepository{db: db}
+}
+
+// CreateOrderBatch creates the batch and its orders. Callers run it inside a
+// transaction, so a batch is never left without some of its orders.
+func (r *orderBatchRepository) CreateOrderBatch(ctx context.Context, batch *model.OrderBatch) error {
+	db := getDB(ctx, r.db)
+	if err := db.Omit("Orders").Create(batch).Error; err != nil {
+		return err
+	}
+	for i := range batch.Orders {
+		batch.Orders[i].BatchID = &batch.BatchID
+	}
+	return db.CreateInBatches(batch.Orders, _orderBatchInsertSize).Error
+}
+
+// GetOrderBatchByBatchID returns the batch with its orders in line order.
+func (r *orderBatchRepository) GetOrderBatchByBatchID(ctx context.Context, batchID uint) (*model.OrderBatch, error) {
+	var batch model.OrderBatch
+	if err := getDB(ctx, r.db).
+		Where("batch_id = ?", batchID).
+		Preload("Orders", func(db *gorm.DB) *gorm.DB {
+			return db.Order("batch_line")
+		}).
+		Preload("Orders.Sku").
+		Preload("Orders.Sku.Supplier").
+		Preload("Orders.Sku.CashBack").
+		First(&batch).Error; err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// UpdateOrderBatchStatus moves the batch from one status to another and
+// records the payment that did it. It returns ErrOrderBatchStatusChanged when
+// the batch is no longer in from, and gorm.ErrDuplicatedKey when the payment
+// already paid another batch.
+func (r *orderBatchRepository) UpdateOrderBatchStatus(ctx context.Context, batchID uint, from, to model.PurchaseHistoryStatus, paymentReference string) error {
+	result := getDB(ctx, r.db).Model(&model.OrderBatch{}).
+		Where("batch_id = ? AND status = ?", batchID, from).
+		Updates(map[string]interface{}{
+			"status":            to,
+			"payment_reference": paymentReference,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOrderBatchStatusChanged
+	}
+	return nil
+}
+
+// GetPendingOrderBatchIDsCreatedBefore returns the oldest batches still pending
+// payment that were created before createdBefore.
+func (r *orderBatchRepository) GetPendingOrderBatchIDsCreatedBefore(ctx context.Context, createdBefore time.Time, limit int) ([]uint, error) {
+	var batchIDs []uint
+	if err := getDB(ctx, r.db).Model(&model.OrderBatch{}).
+		Where("status = ? AND created_at < ?", model.PurchaseHistoryStatusPending, createdBefore).
+		Order("created_at").
+		Limit(limit).
+		Pluck("batch_id", &batchIDs).Error; err != nil {
+		return nil, err
+	}
+	return batchIDs, nil
+}
+
+// ClaimOrderBatchOrders marks at most limit paid batch orders as dispatched and
+// returns their order IDs, the batches paid first go first and their orders in
+// line order. Rows claimed by a concurrent dispatcher are skipped, so an order
+// is only ever claimed once.
+func (r *orderBatchRepository) ClaimOrderBatchOrders(ctx context.Context, limit int) ([]uint, error) {
+	var orderIDs []uint
+	if err := getDB(ctx, r.db).Raw(`
+		UPDATE orders SET dispatched_at = now(), updated_at = now()
+		WHERE id IN (
+			SELECT id FROM orders
+			WHERE batch_id IS NOT NULL AND status = ? AND dispatched_at IS NULL AND deleted_at IS NULL
+			ORDER BY updated_at, batch_line
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING order_id`, model.PurchaseHistoryStatusConfirm, limit).
+		Scan(&orderIDs).Error; err != nil {
+		return nil, err
+	}
+	return orderIDs, nil
+}
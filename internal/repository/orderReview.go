@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+	"top-up-api/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrOrderReviewResolved rejects resolving a review that was already resolved.
+var ErrOrderReviewResolved = errors.New("order review is already resolved")
+
+type OrderReviewRepository interface {
+	GetOrderReviews(ctx context.Context, status model.OrderReviewStatus) ([]model.OrderReview, error)
+	GetOrderReviewByID(ctx context.Context, id uint) (*model.OrderReview, error)
+	CreateOrderReview(ctx context.Context, review *model.OrderReview) error
+	ResolveOrderReview(ctx context.Context, id uint, resolution model.PurchaseHistoryStatus, note string, resolvedAt time.Time) error
+}
+
+type orderReviewRepository struct {
+	db *gorm.DB
+}
+
+var _ OrderReviewRepository = (*orderReviewRepository)(nil)
+
+func NewOrderReviewRepository(db *gorm.DB) *orderReviewRepository {
+	return &orderReviewRepository{db: db}
+}
+
+// GetOrderReviews returns the reviews in status oldest first, every review
+// when status is empty.
+func (r *orderReviewRepository) GetOrderReviews(ctx context.Context, status model.OrderReviewStatus) ([]model.OrderReview, error) {
+	db := getDB(ctx, r.db)
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+
+	var reviews []model.OrderReview
+	if err := db.Order("id").Find(&reviews).Error; err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+func (r *orderReviewRepository) GetOrderReviewByID(ctx context.Context, id uint) (*model.OrderReview, error) {
+	var review model.OrderReview
+	if err := getDB(ctx, r.db).First(&review, id).Error; err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// CreateOrderReview queues the order for review, an order is only queued once.
+func (r *orderReviewRepository) CreateOrderReview(ctx context.Context, review *model.OrderReview) error {
+	return getDB(ctx, r.db).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "order_id"}}, DoNothing: true}).
+		Create(review).Error
+}
+
+// ResolveOrderReview closes an open review and returns ErrOrderReviewResolved
+// when it was closed already.
+func (r *orderReviewRepository) ResolveOrderReview(ctx context.Context, id uint, resolution model.PurchaseHistoryStatus, note string, resolvedAt time.Time) error {
+	result := getDB(ctx, r.db).Model(&model.OrderReview{}).
+		Where("id = ? AND status = ?", id, model.OrderReviewStatusOpen).
+		Updates(map[string]interface{}{
+			"status":      model.OrderReviewStatusResolved,
+			"resolution":  resolution,
+			"note":        note,
+			"resolved_at": resolvedAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOrderReviewResolved
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"top-up-api/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type OrderStatusEventRepository interface {
+	CreateOrderStatusEvent(ctx context.Context, event *model.OrderStatusEvent) error
+	GetOrderStatusEventsByOrderID(ctx context.Context, orderID uint) ([]model.OrderStatusEvent, error)
+}
+
+type orderStatusEventRepository struct {
+	db *gorm.DB
+}
+
+var _ OrderStatusEventRepository = (*orderStatusEventRepository)(nil)
+
+func NewOrderStatusEventRepository(db *gorm.DB) *orderStatusEventRepository {
+	return &orderStatusEventRepository{db: db}
+}
+
+func (r *orderStatusEventRepository) CreateOrderStatusEvent(ctx context.Context, event *model.OrderStatusEvent) error {
+	return getDB(ctx, r.db).Create(event).Error
+}
+
+func (r *orderStatusEventRepository) GetOrderStatusEventsByOrderID(ctx context.Context, orderID uint) ([]model.OrderStatusEvent, error) {
+	var events []model.OrderStatusEvent
+	if err := getDB(ctx, r.db).
+		Where("order_id = ?", orderID).
+		Order("created_at, id").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
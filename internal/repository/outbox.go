@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+	"top-up-api/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type OutboxRepository interface {
+	CreateOutboxMessage(ctx context.Context, message *model.OutboxMessage) error
+	ClaimDueOutboxMessages(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.OutboxMessage, error)
+	MarkOutboxMessageDelivered(ctx context.Context, id uint, attempts int, deliveredAt time.Time) error
+	MarkOutboxMessageRetry(ctx context.Context, id uint, attempts int, nextAttemptAt time.Time, lastError string) error
+	MarkOutboxMessageDead(ctx context.Context, id uint, attempts int, lastError string) error
+}
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+var _ OutboxRepository = (*outboxRepository)(nil)
+
+func NewOutboxRepository(db *gorm.DB) *outboxRepository {
+	return &outboxRepository{db: db}
+}
+
+func (r *outboxRepository) CreateOutboxMessage(ctx context.Context, message *model.OutboxMessage) error {
+	return getDB(ctx, r.db).Create(message).Error
+}
+
+// ClaimDueOutboxMessages leases at most limit pending messages whose next
+// attempt is due by moving their next attempt a lease ahead, and returns them.
+// No other dispatcher picks them up until the lease ends, and the messages of
+// a dispatcher that stopped before recording their result are due again then.
+// Messages are delivered outside of any transaction, each result is recorded
+// on its own.
+func (r *outboxRepository) ClaimDueOutboxMessages(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.OutboxMessage, error) {
+	var messages []model.OutboxMessage
+	if err := getDB(ctx, r.db).Raw(`
+		UPDATE outbox_messages SET next_attempt_at = ?, updated_at = now()
+		WHERE id IN (
+			SELECT id FROM outbox_messages
+			WHERE status = ? AND next_attempt_at <= ? AND deleted_at IS NULL
+			ORDER BY next_attempt_at, id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`, now.Add(lease), model.OutboxStatusPending, now, limit).
+		Scan(&messages).Error; err != nil {
+		return nil, err
+	}
+	// RETURNING keeps no order, the oldest messages go out first
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].ID < messages[j].ID
+	})
+	return messages, nil
+}
+
+func (r *outboxRepository) MarkOutboxMessageDelivered(ctx context.Context, id uint, attempts int, deliveredAt time.Time) error {
+	return getDB(ctx, r.db).Model(&model.OutboxMessage{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":       model.OutboxStatusDelivered,
+			"attempts":     attempts,
+			"delivered_at": deliveredAt,
+			"last_error":   "",
+		}).Error
+}
+
+func (r *outboxRepository) MarkOutboxMessageRetry(ctx context.Context, id uint, attempts int, nextAttemptAt time.Time, lastError string) error {
+	return getDB(ctx, r.db).Model(&model.OutboxMessage{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"attempts":        attempts,
+			"next_attempt_at": nextAttemptAt,
+			"last_error":      lastError,
+		}).Error
+}
+
+func (r *outboxRepository) MarkOutboxMessageDead(ctx context.Context, id uint, attempts int, lastError string) error {
+	return getDB(ctx, r.db).Model(&model.OutboxMessage{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":     model.OutboxStatusDead,
+			"attempts":   attempts,
+			"last_error": lastError,
+		}).Error
+}
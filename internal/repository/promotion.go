@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+	"top-up-api/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrPromotionExhausted rejects a redemption of a promotion that reached its global limit.
+	ErrPromotionExhausted = errors.New("promotion reached its redemption limit")
+	// ErrPromotionUserLimitReached rejects a redemption of a promotion the user already used up.
+	ErrPromotionUserLimitReached = errors.New("promotion reached its redemption limit for the user")
+)
+
+type PromotionRepository interface {
+	GetPromotions(ctx context.Context) ([]model.Promotion, error)
+	GetPromotionByID(ctx context.Context, id uint) (*model.Promotion, error)
+	GetAvailablePromotions(ctx context.Context, now time.Time, voucherCode string) ([]model.Promotion, error)
+	CreatePromotion(ctx context.Context, promotion *model.Promotion) error
+	UpdatePromotion(ctx context.Context, promotion *model.Promotion) error
+	DeletePromotion(ctx context.Context, id uint) error
+	CountUserRedemptions(ctx context.Context, userID uint, promotionIDs []uint) (map[uint]int, error)
+	RedeemPromotion(ctx context.Context, redemption *model.PromotionRedemption) error
+	ReleasePromotionRedemptions(ctx context.Context, orderID uint, releasedAt time.Time) error
+}
+
+type promotionRepository struct {
+	db *gorm.DB
+}
+
+var _ PromotionRepository = (*promotionRepository)(nil)
+
+func NewPromotionRepository(db *gorm.DB) *promotionRepository {
+	return &promotionRepository{db: db}
+}
+
+func (r *promotionRepository) GetPromotions(ctx context.Context) ([]model.Promotion, error) {
+	var promotions []model.Promotion
+	if err := getDB(ctx, r.db).Order("id").Find(&promotions).Error; err != nil {
+		return nil, err
+	}
+	return promotions, nil
+}
+
+func (r *promotionRepository) GetPromotionByID(ctx context.Context, id uint) (*model.Promotion, error) {
+	var promotion model.Promotion
+	if err := getDB(ctx, r.db).First(&promotion, id).Error; err != nil {
+		return nil, err
+	}
+	return &promotion, nil
+}
+
+// GetAvailablePromotions returns the active promotions running at now that
+// still have redemptions left. Voucher promotions are only returned for their
+// voucher code.
+func (r *promotionRepository) GetAvailablePromotions(ctx context.Context, now time.Time, voucherCode string) ([]model.Promotion, error) {
+	var promotions []model.Promotion
+	if err := getDB(ctx, r.db).
+		Where("active AND starts_at <= ? AND (ends_at IS NULL OR ends_at > ?)", now, now).
+		Where("global_limit = 0 OR redemption_count < global_limit").
+		Where("NOT voucher OR code = ?", voucherCode).
+		Order("id").
+		Find(&promotions).Error; err != nil {
+		return nil, err
+	}
+	return promotions, nil
+}
+
+func (r *promotionRepository) CreatePromotion(ctx context.Context, promotion *model.Promotion) error {
+	return getDB(ctx, r.db).Create(promotion).Error
+}
+
+// UpdatePromotion saves the promotion but its redemption count, which only
+// redemptions move.
+func (r *promotionRepository) UpdatePromotion(ctx context.Context, promotion *model.Promotion) error {
+	return getDB(ctx, r.db).Omit("redemption_count").Save(promotion).Error
+}
+
+// DeletePromotion soft deletes the promotion and returns gorm.ErrRecordNotFound when there is none
+func (r *promotionRepository) DeletePromotion(ctx context.Context, id uint) error {
+	result := getDB(ctx, r.db).Delete(&model.Promotion{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// CountUserRedemptions returns how many unreleased redemptions the user has of
+// each promotion, promotions the user never redeemed are left out.
+func (r *promotionRepository) CountUserRedemptions(ctx context.Context, userID uint, promotionIDs []uint) (map[uint]int, error) {
+	var rows []struct {
+		PromotionID uint
+		Count       int
+	}
+	if err := getDB(ctx, r.db).Model(&model.PromotionRedemption{}).
+		Select("promotion_id, COUNT(*) AS count").
+		Where("user_id = ? AND promotion_id IN ? AND released_at IS NULL", userID, promotionIDs).
+		Group("promotion_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		counts[row.PromotionID] = row.Count
+	}
+	return counts, nil
+}
+
+// RedeemPromotion counts the redemption toward the limits of its promotion and
+// records it. Taking the redemption locks the promotion row, so concurrent
+// orders can't both take its last redemption, and it must run inside a
+// transaction. It returns ErrPromotionExhausted or ErrPromotionUserLimitReached
+// when a limit is reached.
+func (r *promotionRepository) RedeemPromotion(ctx context.Context, redemption *model.PromotionRedemption) error {
+	db := getDB(ctx, r.db)
+
+	var promotion model.Promotion
+	result := db.Model(&promotion).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "per_user_limit"}}}).
+		Where("id = ? AND (global_limit = 0 OR redemption_count < global_limit)", redemption.PromotionID).
+		UpdateColumn("redemption_count", gorm.Expr("redemption_count + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPromotionExhausted
+	}
+
+	if promotion.PerUserLimit > 0 {
+		var redeemed int64
+		if err := db.Model(&model.PromotionRedemption{}).
+			Where("promotion_id = ? AND user_id = ? AND released_at IS NULL", redemption.PromotionID, redemption.UserID).
+			Count(&redeemed).Error; err != nil {
+			return err
+		}
+		if int(redeemed) >= promotion.PerUserLimit {
+			return ErrPromotionUserLimitReached
+		}
+	}
+
+	return db.Create(redemption).Error
+}
+
+// ReleasePromotionRedemptions gives the redemptions of an order back to the
+// limits of their promotions. Released redemptions are kept as the record of
+// what the order got.
+func (r *promotionRepository) ReleasePromotionRedemptions(ctx context.Context, orderID uint, releasedAt time.Time) error {
+	db := getDB(ctx, r.db)
+
+	var released []model.PromotionRedemption
+	if err := db.Model(&released).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "promotion_id"}}}).
+		Where("order_id = ? AND released_at IS NULL", orderID).
+		UpdateColumn("released_at", releasedAt).Error; err != nil {
+		return err
+	}
+
+	// Promotions are always updated in the same order, so concurrent releases
+	// wait for each other instead of deadlocking.
+	sort.Slice(released, func(i, j int) bool {
+		return released[i].PromotionID < released[j].PromotionID
+	})
+	for _, redemption := range released {
+		if err := db.Model(&model.Promotion{}).
+			Where("id = ?", redemption.PromotionID).
+			UpdateColumn("redemption_count", gorm.Expr("GREATEST(redemption_count - 1, 0)")).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -5,10 +5,18 @@ import (
 	"top-up-api/internal/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ProviderRepository interface {
 	GetProvidersWithSuppliers(ctx context.Context) ([]model.Provider, error)
+	GetProviderByID(ctx context.Context, id uint) (*model.Provider, error)
+	GetProviderByCode(ctx context.Context, code string) (*model.Provider, error)
+	CreateProvider(ctx context.Context, provider *model.Provider) error
+	UpdateProvider(ctx context.Context, provider *model.Provider) error
+	ReplaceProviderSuppliers(ctx context.Context, providerID uint, providerSuppliers []model.ProviderSupplier) error
+	DeleteProvider(ctx context.Context, id uint) error
+	UpdateProviderCallbackSecret(ctx context.Context, id uint, secret string) error
 }
 
 type providerRepository struct {
@@ -21,6 +29,69 @@ func NewProviderRepository(db *gorm.DB) ProviderRepository {
 
 func (r *providerRepository) GetProvidersWithSuppliers(ctx context.Context) ([]model.Provider, error) {
 	var providers []model.Provider
-	err := r.db.WithContext(ctx).Preload("Suppliers").Find(&providers).Error
+	err := getDB(ctx, r.db).Preload("Suppliers").Preload("ProviderSuppliers").Find(&providers).Error
 	return providers, err
 }
+
+func (r *providerRepository) GetProviderByID(ctx context.Context, id uint) (*model.Provider, error) {
+	var provider model.Provider
+	if err := getDB(ctx, r.db).Preload("Suppliers").Preload("ProviderSuppliers").First(&provider, id).Error; err != nil {
+		return nil, err
+	}
+	return &provider, nil
+}
+
+func (r *providerRepository) GetProviderByCode(ctx context.Context, code string) (*model.Provider, error) {
+	var provider model.Provider
+	if err := getDB(ctx, r.db).Where("code = ?", code).First(&provider).Error; err != nil {
+		return nil, err
+	}
+	return &provider, nil
+}
+
+func (r *providerRepository) CreateProvider(ctx context.Context, provider *model.Provider) error {
+	return getDB(ctx, r.db).Omit(clause.Associations).Create(provider).Error
+}
+
+func (r *providerRepository) UpdateProvider(ctx context.Context, provider *model.Provider) error {
+	return getDB(ctx, r.db).Omit(clause.Associations).Save(provider).Error
+}
+
+// ReplaceProviderSuppliers sets the provider_suppliers rows of the provider to exactly the given ones
+func (r *providerRepository) ReplaceProviderSuppliers(ctx context.Context, providerID uint, providerSuppliers []model.ProviderSupplier) error {
+	db := getDB(ctx, r.db)
+	if err := db.Where("provider_id = ?", providerID).Delete(&model.ProviderSupplier{}).Error; err != nil {
+		return err
+	}
+	if len(providerSuppliers) == 0 {
+		return nil
+	}
+	return db.Create(&providerSuppliers).Error
+}
+
+// DeleteProvider soft deletes the provider and returns gorm.ErrRecordNotFound when there is none
+func (r *providerRepository) DeleteProvider(ctx context.Context, id uint) error {
+	result := getDB(ctx, r.db).Delete(&model.Provider{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UpdateProviderCallbackSecret replaces the callback secret of the provider and
+// returns gorm.ErrRecordNotFound when there is none
+func (r *providerRepository) UpdateProviderCallbackSecret(ctx context.Context, id uint, secret string) error {
+	result := getDB(ctx, r.db).Model(&model.Provider{}).
+		Where("id = ?", id).
+		Update("callback_secret", secret)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
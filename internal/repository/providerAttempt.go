@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"top-up-api/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type ProviderAttemptRepository interface {
+	CreateProviderAttempt(ctx context.Context, attempt *model.ProviderAttempt) error
+	GetProviderAttemptsByOrderID(ctx context.Context, orderID uint) ([]model.ProviderAttempt, error)
+}
+
+type providerAttemptRepository struct {
+	db *gorm.DB
+}
+
+var _ ProviderAttemptRepository = (*providerAttemptRepository)(nil)
+
+func NewProviderAttemptRepository(db *gorm.DB) *providerAttemptRepository {
+	return &providerAttemptRepository{db: db}
+}
+
+func (r *providerAttemptRepository) CreateProviderAttempt(ctx context.Context, attempt *model.ProviderAttempt) error {
+	return getDB(ctx, r.db).Create(attempt).Error
+}
+
+func (r *providerAttemptRepository) GetProviderAttemptsByOrderID(ctx context.Context, orderID uint) ([]model.ProviderAttempt, error) {
+	var attempts []model.ProviderAttempt
+	if err := getDB(ctx, r.db).
+		Where("order_id = ?", orderID).
+		Order("created_at, id").
+		Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
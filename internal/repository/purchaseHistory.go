@@ -12,7 +12,9 @@ type PurchaseHistoryRepository interface {
 	GetPurchaseHistoriesByUserIDPaginated(ctx context.Context, userID uint, page, pageSize int) ([]model.PurchaseHistory, int64, error)
 	GetPurchaseHistoryByID(ctx context.Context, id uint) (*model.PurchaseHistory, error)
 	UpdatePurchaseHistoryStatusByOrderID(ctx context.Context, order_id uint, status model.PurchaseHistoryStatus) error
+	UpdatePurchaseHistoryProviderByOrderID(ctx context.Context, orderID uint, providerCode string) error
 	GetPurchaseHistoryByOrderID(ctx context.Context, order_id uint) (*model.PurchaseHistory, error)
+	CreatePaymentReference(ctx context.Context, paymentReference *model.PaymentReference) error
 }
 
 type purchaseHistoryRepository struct {
@@ -26,7 +28,7 @@ func NewPurchaseHistoryRepository(db *gorm.DB) *purchaseHistoryRepository {
 }
 
 func (r *purchaseHistoryRepository) CreatePurchaseHistory(ctx context.Context, purchaseHistory *model.PurchaseHistory) error {
-	return r.db.WithContext(ctx).Create(purchaseHistory).Error
+	return getDB(ctx, r.db).Create(purchaseHistory).Error
 }
 
 func (r *purchaseHistoryRepository) GetPurchaseHistoriesByUserIDPaginated(ctx context.Context, userID uint, page, pageSize int) ([]model.PurchaseHistory, int64, error) {
@@ -34,7 +36,7 @@ func (r *purchaseHistoryRepository) GetPurchaseHistoriesByUserIDPaginated(ctx co
 	var total int64
 
 	// Count total
-	if err := r.db.WithContext(ctx).Model(&model.PurchaseHistory{}).
+	if err := getDB(ctx, r.db).Model(&model.PurchaseHistory{}).
 		Where("user_id = ?", userID).
 		Count(&total).Error; err != nil {
 		return nil, 0, err
@@ -42,9 +44,8 @@ func (r *purchaseHistoryRepository) GetPurchaseHistoriesByUserIDPaginated(ctx co
 
 	// Fetch paginated
 	offset := (page - 1) * pageSize
-	if err := r.db.WithContext(ctx).
+	if err := getDB(ctx, r.db).
 		Where("user_id = ?", userID).
-		Preload("User").
 		Preload("Sku").
 		Preload("Sku.Supplier").
 		Preload("Sku.CashBack").
@@ -59,23 +60,30 @@ func (r *purchaseHistoryRepository) GetPurchaseHistoriesByUserIDPaginated(ctx co
 
 func (r *purchaseHistoryRepository) GetPurchaseHistoryByID(ctx context.Context, id uint) (*model.PurchaseHistory, error) {
 	var purchaseHistory model.PurchaseHistory
-	if err := r.db.WithContext(ctx).Where("order_id = ?", id).First(&purchaseHistory).Error; err != nil {
+	if err := getDB(ctx, r.db).Where("order_id = ?", id).First(&purchaseHistory).Error; err != nil {
 		return nil, err
 	}
 	return &purchaseHistory, nil
 }
 
 func (r *purchaseHistoryRepository) UpdatePurchaseHistoryStatusByOrderID(ctx context.Context, order_id uint, status model.PurchaseHistoryStatus) error {
-	return r.db.WithContext(ctx).Model(&model.PurchaseHistory{}).
+	return getDB(ctx, r.db).Model(&model.PurchaseHistory{}).
 		Where("order_id = ?", order_id).
 		Update("status", status).Error
 }
 
+// UpdatePurchaseHistoryProviderByOrderID records the provider handling the
+// order, settlement reports group orders by it.
+func (r *purchaseHistoryRepository) UpdatePurchaseHistoryProviderByOrderID(ctx context.Context, orderID uint, providerCode string) error {
+	return getDB(ctx, r.db).Model(&model.PurchaseHistory{}).
+		Where("order_id = ?", orderID).
+		Update("provider_code", providerCode).Error
+}
+
 func (r *purchaseHistoryRepository) GetPurchaseHistoryByOrderID(ctx context.Context, order_id uint) (*model.PurchaseHistory, error) {
 	var purchaseHistory model.PurchaseHistory
-	if err := r.db.WithContext(ctx).
+	if err := getDB(ctx, r.db).
 		Where("order_id = ?", order_id).
-		Preload("User").
 		Preload("Sku").
 		Preload("Sku.Supplier").
 		Preload("Sku.CashBack").
@@ -84,3 +92,9 @@ func (r *purchaseHistoryRepository) GetPurchaseHistoryByOrderID(ctx context.Cont
 	}
 	return &purchaseHistory, nil
 }
+
+// CreatePaymentReference records the payment that paid an order or a batch,
+// it fails with gorm.ErrDuplicatedKey when the payment already paid one.
+func (r *purchaseHistoryRepository) CreatePaymentReference(ctx context.Context, paymentReference *model.PaymentReference) error {
+	return getDB(ctx, r.db).Create(paymentReference).Error
+}
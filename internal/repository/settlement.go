@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"time"
+	"top-up-api/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// _settlementOrderIDChunkSize is the number of listed orders looked up per query.
+const _settlementOrderIDChunkSize = 1000
+
+// ErrSettlementDiscrepancyResolved rejects resolving a discrepancy that was
+// already resolved.
+var ErrSettlementDiscrepancyResolved = errors.New("settlement discrepancy is already resolved")
+
+type SettlementRepository interface {
+	GetSettlementRows(ctx context.Context, from, to time.Time, timezone string) ([]model.SettlementRow, error)
+	GetSettlementPurchaseHistories(ctx context.Context, providerCode string, from, to time.Time, orderIDs []uint) ([]model.PurchaseHistory, error)
+	CreateSettlementImport(ctx context.Context, settlementImport *model.SettlementImport) error
+	GetSettlementImports(ctx context.Context) ([]model.SettlementImport, error)
+	GetSettlementImportByID(ctx context.Context, id uint) (*model.SettlementImport, error)
+	GetSettlementDiscrepancies(ctx context.Context, importID uint, status model.SettlementDiscrepancyStatus) ([]model.SettlementDiscrepancy, error)
+	GetSettlementDiscrepancyByID(ctx context.Context, id uint) (*model.SettlementDiscrepancy, error)
+	ResolveSettlementDiscrepancy(ctx context.Context, id uint, note string, resolvedAt time.Time) error
+}
+
+type settlementRepository struct {
+	db *gorm.DB
+}
+
+var _ SettlementRepository = (*settlementRepository)(nil)
+
+func NewSettlementRepository(db *gorm.DB) *settlementRepository {
+	return &settlementRepository{db: db}
+}
+
+// GetSettlementRows sums up the orders that succeeded from from up to to per
+// day, provider and supplier. Days start at midnight in timezone, and an order
+// counts on the day its purchase history was last updated, the day it
+// succeeded.
+func (r *settlementRepository) GetSettlementRows(ctx context.Context, from, to time.Time, timezone string) ([]model.SettlementRow, error) {
+	var rows []model.SettlementRow
+	err := getDB(ctx, r.db).Model(&model.PurchaseHistory{}).
+		Select(`to_char(purchase_history.updated_at AT TIME ZONE ?, 'YYYY-MM-DD') AS day,
+			purchase_history.provider_code,
+			supplier.code AS supplier_code,
+			supplier.name AS supplier_name,
+			COUNT(*) AS orders,
+			SUM(sku.price) AS face_value,
+			SUM(purchase_history.total_price) AS amount_charged,
+			COALESCE(SUM(purchase_history.cash_back_value), 0) AS cash_back`, timezone).
+		Joins("JOIN sku ON sku.id = purchase_history.sku_id").
+		Joins("JOIN supplier ON supplier.id = sku.supplier_id").
+		Where("purchase_history.status = ? AND purchase_history.updated_at >= ? AND purchase_history.updated_at < ?", model.PurchaseHistoryStatusSuccess, from, to).
+		Group("day, purchase_history.provider_code, supplier.code, supplier.name").
+		Order("day, purchase_history.provider_code, supplier.code").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// GetSettlementPurchaseHistories returns the purchase histories a settlement
+// file of the provider is diffed against: those of the provider's orders the
+// file lists, and those of the orders that succeeded with the provider from
+// from up to to. A listed order of another provider is left out, so the diff
+// reports it like an order we don't know.
+func (r *settlementRepository) GetSettlementPurchaseHistories(ctx context.Context, providerCode string, from, to time.Time, orderIDs []uint) ([]model.PurchaseHistory, error) {
+	db := getDB(ctx, r.db)
+
+	var histories []model.PurchaseHistory
+	if err := db.Where("status = ? AND provider_code = ? AND updated_at >= ? AND updated_at < ?", model.PurchaseHistoryStatusSuccess, providerCode, from, to).
+		Preload("Sku").Find(&histories).Error; err != nil {
+		return nil, err
+	}
+
+	found := make(map[uint]bool, len(histories))
+	for _, history := range histories {
+		found[history.OrderID] = true
+	}
+	// A file can list more orders than Postgres takes bind parameters
+	for chunk := range slices.Chunk(orderIDs, _settlementOrderIDChunkSize) {
+		var listed []model.PurchaseHistory
+		if err := db.Where("provider_code = ? AND order_id IN ?", providerCode, chunk).Preload("Sku").Find(&listed).Error; err != nil {
+			return nil, err
+		}
+		for _, history := range listed {
+			if !found[history.OrderID] {
+				found[history.OrderID] = true
+				histories = append(histories, history)
+			}
+		}
+	}
+
+	sort.Slice(histories, func(i, j int) bool {
+		return histories[i].OrderID < histories[j].OrderID
+	})
+	return histories, nil
+}
+
+// CreateSettlementImport stores the import along with its discrepancies.
+func (r *settlementRepository) CreateSettlementImport(ctx context.Context, settlementImport *model.SettlementImport) error {
+	return getDB(ctx, r.db).Create(settlementImport).Error
+}
+
+// GetSettlementImports returns the imports newest first, without their
+// discrepancies.
+func (r *settlementRepository) GetSettlementImports(ctx context.Context) ([]model.SettlementImport, error) {
+	var imports []model.SettlementImport
+	if err := getDB(ctx, r.db).Order("id DESC").Find(&imports).Error; err != nil {
+		return nil, err
+	}
+	return imports, nil
+}
+
+func (r *settlementRepository) GetSettlementImportByID(ctx context.Context, id uint) (*model.SettlementImport, error) {
+	var settlementImport model.SettlementImport
+	if err := getDB(ctx, r.db).First(&settlementImport, id).Error; err != nil {
+		return nil, err
+	}
+	return &settlementImport, nil
+}
+
+// GetSettlementDiscrepancies returns the discrepancies of an import in status,
+// every discrepancy of the import when status is empty.
+func (r *settlementRepository) GetSettlementDiscrepancies(ctx context.Context, importID uint, status model.SettlementDiscrepancyStatus) ([]model.SettlementDiscrepancy, error) {
+	db := getDB(ctx, r.db).Where("import_id = ?", importID)
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+
+	var discrepancies []model.SettlementDiscrepancy
+	if err := db.Order("id").Find(&discrepancies).Error; err != nil {
+		return nil, err
+	}
+	return discrepancies, nil
+}
+
+func (r *settlementRepository) GetSettlementDiscrepancyByID(ctx context.Context, id uint) (*model.SettlementDiscrepancy, error) {
+	var discrepancy model.SettlementDiscrepancy
+	if err := getDB(ctx, r.db).First(&discrepancy, id).Error; err != nil {
+		return nil, err
+	}
+	return &discrepancy, nil
+}
+
+// ResolveSettlementDiscrepancy closes an open discrepancy and returns
+// ErrSettlementDiscrepancyResolved when it was closed already.
+func (r *settlementRepository) ResolveSettlementDiscrepancy(ctx context.Context, id uint, note string, resolvedAt time.Time) error {
+	result := getDB(ctx, r.db).Model(&model.SettlementDiscrepancy{}).
+		Where("id = ? AND status = ?", id, model.SettlementDiscrepancyStatusOpen).
+		Updates(map[string]interface{}{
+			"status":      model.SettlementDiscrepancyStatusResolved,
+			"note":        note,
+			"resolved_at": resolvedAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSettlementDiscrepancyResolved
+	}
+	return nil
+}
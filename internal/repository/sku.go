@@ -5,12 +5,16 @@ import (
 	"top-up-api/internal/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type SkuRepository interface {
 	GetSkusBySupplierCode(ctx context.Context, supplierCode string) (*[]model.Sku, error)
 	GetSkuByID(ctx context.Context, id uint) (*model.Sku, error)
 	GetSkus(ctx context.Context) (*[]model.Sku, error)
+	CreateSku(ctx context.Context, sku *model.Sku) error
+	UpdateSku(ctx context.Context, sku *model.Sku) error
+	DeleteSku(ctx context.Context, id uint) error
 }
 
 type skuRepository struct {
@@ -25,7 +29,7 @@ func NewSkuRepository(db *gorm.DB) *skuRepository {
 
 func (r *skuRepository) GetSkusBySupplierCode(ctx context.Context, supplierCode string) (*[]model.Sku, error) {
 	var skus []model.Sku
-	if err := r.db.WithContext(ctx).Preload("CashBack").Preload("Supplier").Where("supplier_code = ?", supplierCode).Find(&skus).Error; err != nil {
+	if err := getDB(ctx, r.db).Preload("CashBack").Preload("Supplier").Where("supplier_code = ?", supplierCode).Find(&skus).Error; err != nil {
 		return nil, err
 	}
 	return &skus, nil
@@ -33,7 +37,7 @@ func (r *skuRepository) GetSkusBySupplierCode(ctx context.Context, supplierCode
 
 func (r *skuRepository) GetSkuByID(ctx context.Context, id uint) (*model.Sku, error) {
 	var sku model.Sku
-	if err := r.db.WithContext(ctx).Preload("CashBack").Preload("Supplier").First(&sku, id).Error; err != nil {
+	if err := getDB(ctx, r.db).Preload("CashBack").Preload("Supplier").First(&sku, id).Error; err != nil {
 		return nil, err
 	}
 	return &sku, nil
@@ -41,8 +45,37 @@ func (r *skuRepository) GetSkuByID(ctx context.Context, id uint) (*model.Sku, er
 
 func (r *skuRepository) GetSkus(ctx context.Context) (*[]model.Sku, error) {
 	var skus []model.Sku
-	if err := r.db.WithContext(ctx).Preload("CashBack").Preload("Supplier").Find(&skus).Error; err != nil {
+	if err := getDB(ctx, r.db).Preload("CashBack").Preload("Supplier").Find(&skus).Error; err != nil {
 		return nil, err
 	}
 	return &skus, nil
 }
+
+// CreateSku leaves cash_back_code NULL for a sku without cash back
+func (r *skuRepository) CreateSku(ctx context.Context, sku *model.Sku) error {
+	omit := []string{clause.Associations}
+	if sku.CashBackCode == "" {
+		omit = append(omit, "CashBackCode")
+	}
+	return getDB(ctx, r.db).Omit(omit...).Create(sku).Error
+}
+
+func (r *skuRepository) UpdateSku(ctx context.Context, sku *model.Sku) error {
+	return getDB(ctx, r.db).Model(sku).Updates(map[string]interface{}{
+		"supplier_code":  sku.SupplierCode,
+		"cash_back_code": gorm.Expr("NULLIF(?, '')", sku.CashBackCode),
+		"price":          sku.Price,
+	}).Error
+}
+
+// DeleteSku soft deletes the sku and returns gorm.ErrRecordNotFound when there is none
+func (r *skuRepository) DeleteSku(ctx context.Context, id uint) error {
+	result := getDB(ctx, r.db).Delete(&model.Sku{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -5,10 +5,16 @@ import (
 	"top-up-api/internal/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type SupplierRepository interface {
 	GetSuppliers(ctx context.Context) (*[]model.Supplier, error)
+	GetSupplierByID(ctx context.Context, id uint) (*model.Supplier, error)
+	GetSuppliersByCodes(ctx context.Context, codes []string) ([]model.Supplier, error)
+	CreateSupplier(ctx context.Context, supplier *model.Supplier) error
+	UpdateSupplier(ctx context.Context, supplier *model.Supplier) error
+	DeleteSupplier(ctx context.Context, id uint) error
 }
 type supplierRepository struct {
 	db *gorm.DB
@@ -22,8 +28,44 @@ func NewSupplierRepository(db *gorm.DB) *supplierRepository {
 
 func (r *supplierRepository) GetSuppliers(ctx context.Context) (*[]model.Supplier, error) {
 	var suppliers []model.Supplier
-	if err := r.db.WithContext(ctx).Find(&suppliers).Error; err != nil {
+	if err := getDB(ctx, r.db).Find(&suppliers).Error; err != nil {
 		return nil, err
 	}
 	return &suppliers, nil
 }
+
+func (r *supplierRepository) GetSupplierByID(ctx context.Context, id uint) (*model.Supplier, error) {
+	var supplier model.Supplier
+	if err := getDB(ctx, r.db).First(&supplier, id).Error; err != nil {
+		return nil, err
+	}
+	return &supplier, nil
+}
+
+func (r *supplierRepository) GetSuppliersByCodes(ctx context.Context, codes []string) ([]model.Supplier, error) {
+	var suppliers []model.Supplier
+	if err := getDB(ctx, r.db).Where("code IN ?", codes).Find(&suppliers).Error; err != nil {
+		return nil, err
+	}
+	return suppliers, nil
+}
+
+func (r *supplierRepository) CreateSupplier(ctx context.Context, supplier *model.Supplier) error {
+	return getDB(ctx, r.db).Omit(clause.Associations).Create(supplier).Error
+}
+
+func (r *supplierRepository) UpdateSupplier(ctx context.Context, supplier *model.Supplier) error {
+	return getDB(ctx, r.db).Omit(clause.Associations).Save(supplier).Error
+}
+
+// DeleteSupplier soft deletes the supplier and returns gorm.ErrRecordNotFound when there is none
+func (r *supplierRepository) DeleteSupplier(ctx context.Context, id uint) error {
+	result := getDB(ctx, r.db).Delete(&model.Supplier{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+// TransactionManager runs a function inside a database transaction. Repositories
+// called with the context passed to fn take part in that transaction.
+type TransactionManager interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type transactionManager struct {
+	db *gorm.DB
+}
+
+var _ TransactionManager = (*transactionManager)(nil)
+
+func NewTransactionManager(db *gorm.DB) *transactionManager {
+	return &transactionManager{db: db}
+}
+
+func (t *transactionManager) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return getDB(ctx, t.db).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// getDB returns the transaction bound to ctx, or db when there is none.
+func getDB(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
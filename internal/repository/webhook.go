@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+	"top-up-api/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WebhookRepository interface {
+	GetWebhookSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error)
+	GetWebhookSubscriptionsByUserID(ctx context.Context, userID uint) ([]model.WebhookSubscription, error)
+	GetWebhookSubscriptionByID(ctx context.Context, id uint) (*model.WebhookSubscription, error)
+	GetActiveWebhookSubscriptionsByEvent(ctx context.Context, userID uint, eventType string) ([]model.WebhookSubscription, error)
+	CreateWebhookSubscription(ctx context.Context, subscription *model.WebhookSubscription) error
+	UpdateWebhookSubscription(ctx context.Context, subscription *model.WebhookSubscription) error
+	DeleteWebhookSubscription(ctx context.Context, id uint) error
+	CreateWebhookDeliveries(ctx context.Context, deliveries []model.WebhookDelivery) error
+	GetWebhookDeliveryByID(ctx context.Context, id uint) (*model.WebhookDelivery, error)
+	GetWebhookDeliveriesBySubscriptionIDPaginated(ctx context.Context, subscriptionID uint, page, pageSize int) ([]model.WebhookDelivery, int64, error)
+	ClaimDueWebhookDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error)
+	MarkWebhookDeliveryDelivered(ctx context.Context, id uint, attempts, responseStatus int, deliveredAt time.Time) error
+	MarkWebhookDeliveryRetry(ctx context.Context, id uint, attempts, responseStatus int, nextAttemptAt time.Time, lastError string) error
+	MarkWebhookDeliveryFailed(ctx context.Context, id uint, attempts, responseStatus int, lastError string) error
+	ResetWebhookDelivery(ctx context.Context, id uint, nextAttemptAt time.Time) error
+}
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+var _ WebhookRepository = (*webhookRepository)(nil)
+
+func NewWebhookRepository(db *gorm.DB) *webhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func (r *webhookRepository) GetWebhookSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error) {
+	var subscriptions []model.WebhookSubscription
+	if err := getDB(ctx, r.db).Order("id").Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+func (r *webhookRepository) GetWebhookSubscriptionsByUserID(ctx context.Context, userID uint) ([]model.WebhookSubscription, error) {
+	var subscriptions []model.WebhookSubscription
+	if err := getDB(ctx, r.db).Where("user_id = ?", userID).Order("id").Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+func (r *webhookRepository) GetWebhookSubscriptionByID(ctx context.Context, id uint) (*model.WebhookSubscription, error) {
+	var subscription model.WebhookSubscription
+	if err := getDB(ctx, r.db).First(&subscription, id).Error; err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// GetActiveWebhookSubscriptionsByEvent returns the active subscriptions of a
+// user to an event type
+func (r *webhookRepository) GetActiveWebhookSubscriptionsByEvent(ctx context.Context, userID uint, eventType string) ([]model.WebhookSubscription, error) {
+	eventTypes, err := json.Marshal([]string{eventType})
+	if err != nil {
+		return nil, err
+	}
+
+	var subscriptions []model.WebhookSubscription
+	if err := getDB(ctx, r.db).
+		Where("user_id = ? AND active AND event_types @> ?", userID, string(eventTypes)).
+		Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+func (r *webhookRepository) CreateWebhookSubscription(ctx context.Context, subscription *model.WebhookSubscription) error {
+	return getDB(ctx, r.db).Create(subscription).Error
+}
+
+func (r *webhookRepository) UpdateWebhookSubscription(ctx context.Context, subscription *model.WebhookSubscription) error {
+	return getDB(ctx, r.db).Save(subscription).Error
+}
+
+// DeleteWebhookSubscription soft deletes the subscription and returns gorm.ErrRecordNotFound when there is none
+func (r *webhookRepository) DeleteWebhookSubscription(ctx context.Context, id uint) error {
+	result := getDB(ctx, r.db).Delete(&model.WebhookSubscription{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *webhookRepository) CreateWebhookDeliveries(ctx context.Context, deliveries []model.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return getDB(ctx, r.db).Omit(clause.Associations).Create(&deliveries).Error
+}
+
+func (r *webhookRepository) GetWebhookDeliveryByID(ctx context.Context, id uint) (*model.WebhookDelivery, error) {
+	var delivery model.WebhookDelivery
+	if err := getDB(ctx, r.db).First(&delivery, id).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (r *webhookRepository) GetWebhookDeliveriesBySubscriptionIDPaginated(ctx context.Context, subscriptionID uint, page, pageSize int) ([]model.WebhookDelivery, int64, error) {
+	var deliveries []model.WebhookDelivery
+	var total int64
+
+	if err := getDB(ctx, r.db).Model(&model.WebhookDelivery{}).
+		Where("subscription_id = ?", subscriptionID).
+		Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := getDB(ctx, r.db).
+		Where("subscription_id = ?", subscriptionID).
+		Order("id DESC").
+		Limit(pageSize).
+		Offset(offset).
+		Find(&deliveries).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return deliveries, total, nil
+}
+
+// ClaimDueWebhookDeliveries leases at most limit pending deliveries whose next
+// attempt is due by moving their next attempt a lease ahead, and returns them
+// with their subscription. No other dispatcher picks them up until the lease
+// ends, and the deliveries of a dispatcher that stopped before recording their
+// result are due again then.
+func (r *webhookRepository) ClaimDueWebhookDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error) {
+	var ids []uint
+	if err := getDB(ctx, r.db).Raw(`
+		UPDATE webhook_deliveries SET next_attempt_at = ?, updated_at = now()
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = ? AND next_attempt_at <= ? AND deleted_at IS NULL
+			ORDER BY next_attempt_at, id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id`, now.Add(lease), model.WebhookDeliveryStatusPending, now, limit).
+		Scan(&ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var deliveries []model.WebhookDelivery
+	if err := getDB(ctx, r.db).
+		Where("id IN ?", ids).
+		Order("id").
+		Preload("Subscription", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped()
+		}).
+		Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *webhookRepository) MarkWebhookDeliveryDelivered(ctx context.Context, id uint, attempts, responseStatus int, deliveredAt time.Time) error {
+	return getDB(ctx, r.db).Model(&model.WebhookDelivery{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":          model.WebhookDeliveryStatusDelivered,
+			"attempts":        attempts,
+			"response_status": responseStatus,
+			"delivered_at":    deliveredAt,
+			"last_error":      "",
+		}).Error
+}
+
+func (r *webhookRepository) MarkWebhookDeliveryRetry(ctx context.Context, id uint, attempts, responseStatus int, nextAttemptAt time.Time, lastError string) error {
+	return getDB(ctx, r.db).Model(&model.WebhookDelivery{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"attempts":        attempts,
+			"response_status": responseStatus,
+			"next_attempt_at": nextAttemptAt,
+			"last_error":      lastError,
+		}).Error
+}
+
+func (r *webhookRepository) MarkWebhookDeliveryFailed(ctx context.Context, id uint, attempts, responseStatus int, lastError string) error {
+	return getDB(ctx, r.db).Model(&model.WebhookDelivery{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":          model.WebhookDeliveryStatusFailed,
+			"attempts":        attempts,
+			"response_status": responseStatus,
+			"last_error":      lastError,
+		}).Error
+}
+
+// ResetWebhookDelivery queues a delivery again with a fresh attempt budget,
+// whatever its status was.
+func (r *webhookRepository) ResetWebhookDelivery(ctx context.Context, id uint, nextAttemptAt time.Time) error {
+	return getDB(ctx, r.db).Model(&model.WebhookDelivery{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":          model.WebhookDeliveryStatusPending,
+			"attempts":        0,
+			"next_attempt_at": nextAttemptAt,
+			"delivered_at":    nil,
+		}).Error
+}
@@ -66,3 +66,16 @@ func (c *SkuResponse) UnmarshalJSON(data []byte) error {
 
 	return nil
 }
+
+type SkuRequest struct {
+	SupplierCode string `json:"supplier_code" validate:"required"`
+	CashBackCode string `json:"cash_back_code"`
+	Price        int    `json:"price" validate:"gt=0"`
+}
+
+type SkuAdminResponse struct {
+	ID           uint   `json:"id"`
+	SupplierCode string `json:"supplier_code"`
+	CashBackCode string `json:"cash_back_code,omitempty"`
+	Price        int    `json:"price"`
+}
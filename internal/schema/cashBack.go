@@ -25,3 +25,16 @@ func (c *CashBackPercentage) CalculateCashBack(value int) int {
 func (c *CashBackFixed) CalculateCashBack(value int) int {
 	return c.Value
 }
+
+type CashBackRequest struct {
+	Code  string             `json:"code" validate:"required,max=10"`
+	Type  model.CashBackType `json:"type" validate:"required,oneof=percentage fixed"`
+	Value int                `json:"value" validate:"gt=0"`
+}
+
+type CashBackAdminResponse struct {
+	ID    uint               `json:"id"`
+	Code  string             `json:"code"`
+	Type  model.CashBackType `json:"type"`
+	Value int                `json:"value"`
+}
@@ -2,34 +2,54 @@ package schema
 
 import (
 	"encoding/json"
+	"time"
 	"top-up-api/internal/model"
 )
 
+// OrderConfirmRequest is the payment result of an order. PaymentReference is
+// the payment transaction, it can only ever confirm one order.
 type OrderConfirmRequest struct {
+	OrderID          uint                        `json:"order_id"`
+	UserID           uint                        `json:"user_id"`
+	SkuID            uint                        `json:"sku_id"`
+	TotalPrice       int                         `json:"total_price"`
+	Status           model.PurchaseHistoryStatus `json:"status" validate:"purchasehistorystatus"`
+	PhoneNumber      string                      `json:"phone_number"`
+	CashBackValue    int                         `json:"cash_back_value"`
+	PaymentReference string                      `json:"payment_reference" validate:"required,max=128"`
+}
+
+// OrderRequest creates an order. WalletAmount is the part of the total paid
+// from the user's wallet, the payment service collects the rest. VoucherCode
+// is a promotion code entered at checkout.
+type OrderRequest struct {
+	UserID       uint   `json:"user_id"`
+	SkuID        uint   `json:"sku_id"`
+	PhoneNumber  string `json:"phone_number" validate:"required,vnphone"`
+	WalletAmount int    `json:"wallet_amount"`
+	VoucherCode  string `json:"voucher_code"`
+}
+
+type OrderResponse struct {
 	OrderID       uint                        `json:"order_id"`
 	UserID        uint                        `json:"user_id"`
-	SkuID         uint                        `json:"sku_id"`
+	Sku           SkuResponse                 `json:"sku"`
 	TotalPrice    int                         `json:"total_price"`
-	Status        model.PurchaseHistoryStatus `json:"status" validate:"purchasehistorystatus"`
+	Status        model.PurchaseHistoryStatus `json:"status"`
 	PhoneNumber   string                      `json:"phone_number"`
 	CashBackValue int                         `json:"cash_back_value"`
+	WalletAmount  int                         `json:"wallet_amount"`
+	Promotions    []AppliedPromotion          `json:"promotions,omitempty"`
+	BatchID       *uint                       `json:"batch_id,omitempty"`
+	BatchLine     int                         `json:"batch_line,omitempty"`
 }
 
-type OrderRequest struct {
-	UserID      uint   `json:"user_id"`
-	SkuID       uint   `json:"sku_id"`
-	PhoneNumber string `json:"phone_number"`
-}
-
-type OrderResponse struct {
-	OrderID              uint                        `json:"order_id"`
-	UserID               uint                        `json:"user_id"`
-	Sku                  SkuResponse                 `json:"sku"`
-	TotalPrice           int                         `json:"total_price"`
-	Status               model.PurchaseHistoryStatus `json:"status"`
-	PhoneNumber          string                      `json:"phone_number"`
-	CashBackValue        int                         `json:"cash_back_value"`
-	RandomProviderWeight int                         `json:"rand_provider_weight"`
+// OrderDetailResponse is the order merged with its purchase history. The
+// timestamps stay empty until the payment confirms the order.
+type OrderDetailResponse struct {
+	OrderResponse
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+	UpdatedAt   *time.Time `json:"updated_at"`
 }
 
 type OrderProviderRequest struct {
@@ -40,10 +60,30 @@ type OrderProviderRequest struct {
 	CallBackUrl string `json:"callback_url"`
 }
 
+// Statuses a provider answers an order status query with. A provider reports
+// the top-up result once it has one.
+const (
+	ProviderOrderStatusSuccess    = "success"
+	ProviderOrderStatusFailed     = "failed"
+	ProviderOrderStatusProcessing = "processing"
+	ProviderOrderStatusNotFound   = "not_found"
+)
+
+// OrderProviderQueryResponse is the answer of a provider to an order status
+// query.
+type OrderProviderQueryResponse struct {
+	OrderID uint   `json:"order_id"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// OrderUpdateRequest is a provider status callback, ProviderCode is set from
+// the provider that signed it.
 type OrderUpdateRequest struct {
-	OrderID     uint                        `json:"order_id"`
-	Status      model.PurchaseHistoryStatus `json:"status"`
-	PhoneNumber string                      `json:"phone_number"`
+	OrderID      uint                        `json:"order_id"`
+	Status       model.PurchaseHistoryStatus `json:"status"`
+	PhoneNumber  string                      `json:"phone_number"`
+	ProviderCode string                      `json:"-"`
 }
 
 func (o *OrderResponse) MarshalBinary() ([]byte, error) {
@@ -64,6 +104,7 @@ type OrderIdempotencyResponse struct {
 func (o *OrderResponse) CompareWithOrderConfirmRequest(orderConfirmRequest OrderConfirmRequest) bool {
 	return o.OrderID == orderConfirmRequest.OrderID &&
 		o.UserID == orderConfirmRequest.UserID &&
+		o.Sku.ID == orderConfirmRequest.SkuID &&
 		o.TotalPrice == orderConfirmRequest.TotalPrice &&
 		o.PhoneNumber == orderConfirmRequest.PhoneNumber &&
 		o.CashBackValue == orderConfirmRequest.CashBackValue
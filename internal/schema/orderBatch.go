@@ -0,0 +1,64 @@
+package schema
+
+import (
+	"time"
+	"top-up-api/internal/model"
+)
+
+// OrderBatchLine is a phone number to top up with a sku.
+type OrderBatchLine struct {
+	SkuID       uint   `json:"sku_id"`
+	PhoneNumber string `json:"phone_number"`
+}
+
+// OrderBatchRequest creates an order for each line, all of them paid with a
+// single payment. Batch orders get the cashback of their sku, promotions and
+// the wallet don't apply to them.
+type OrderBatchRequest struct {
+	UserID uint             `json:"user_id"`
+	Lines  []OrderBatchLine `json:"lines"`
+}
+
+// OrderBatchConfirmRequest is the payment result of a batch. PaymentReference
+// is the payment transaction, it can only ever pay one batch.
+type OrderBatchConfirmRequest struct {
+	BatchID          uint                        `json:"batch_id"`
+	UserID           uint                        `json:"user_id"`
+	TotalPrice       int                         `json:"total_price"`
+	Status           model.PurchaseHistoryStatus `json:"status" validate:"purchasehistorystatus"`
+	PaymentReference string                      `json:"payment_reference" validate:"required,max=128"`
+}
+
+// OrderBatchProgress counts the orders of a batch in each status.
+type OrderBatchProgress struct {
+	Pending int `json:"pending"`
+	Confirm int `json:"confirm"`
+	Success int `json:"success"`
+	Failed  int `json:"failed"`
+	Expired int `json:"expired"`
+}
+
+// OrderBatchLineResponse is the result of a line of a batch.
+type OrderBatchLineResponse struct {
+	Line          int                         `json:"line"`
+	OrderID       uint                        `json:"order_id"`
+	SkuID         uint                        `json:"sku_id"`
+	PhoneNumber   string                      `json:"phone_number"`
+	TotalPrice    int                         `json:"total_price"`
+	CashBackValue int                         `json:"cash_back_value"`
+	Status        model.PurchaseHistoryStatus `json:"status"`
+}
+
+// OrderBatchResponse is a batch with the result of each of its lines. Status
+// is the one of the batch payment, Progress the one of its orders.
+type OrderBatchResponse struct {
+	BatchID       uint                        `json:"batch_id"`
+	UserID        uint                        `json:"user_id"`
+	Status        model.PurchaseHistoryStatus `json:"status"`
+	TotalPrice    int                         `json:"total_price"`
+	CashBackValue int                         `json:"cash_back_value"`
+	LineCount     int                         `json:"line_count"`
+	Progress      OrderBatchProgress          `json:"progress"`
+	Lines         []OrderBatchLineResponse    `json:"lines"`
+	CreatedAt     time.Time                   `json:"created_at"`
+}
@@ -0,0 +1,33 @@
+package schema
+
+import (
+	"time"
+	"top-up-api/internal/model"
+)
+
+type OrderReviewResponse struct {
+	ID           uint                         `json:"id"`
+	OrderID      uint                         `json:"order_id"`
+	ProviderCode string                       `json:"provider_code"`
+	Reason       string                       `json:"reason"`
+	Status       model.OrderReviewStatus      `json:"status"`
+	Resolution   *model.PurchaseHistoryStatus `json:"resolution,omitempty"`
+	Note         string                       `json:"note,omitempty"`
+	ResolvedAt   *time.Time                   `json:"resolved_at,omitempty"`
+	CreatedAt    time.Time                    `json:"created_at"`
+}
+
+// OrderReviewResolveRequest settles the order of a review with the top-up
+// result an operator got from the provider.
+type OrderReviewResolveRequest struct {
+	Status model.PurchaseHistoryStatus `json:"status" validate:"required,oneof=success failed"`
+	Note   string                      `json:"note" validate:"required"`
+}
+
+// ReconciliationResult counts the stuck orders a reconciliation run
+// dispatched, settled and queued for review.
+type ReconciliationResult struct {
+	Dispatched int
+	Settled    int
+	Escalated  int
+}
@@ -0,0 +1,34 @@
+package schema
+
+import (
+	"time"
+	"top-up-api/internal/model"
+)
+
+type OrderStatusEventResponse struct {
+	PreviousStatus *model.PurchaseHistoryStatus `json:"previous_status"`
+	Status         model.PurchaseHistoryStatus  `json:"status"`
+	Source         model.OrderStatusEventSource `json:"source"`
+	Reason         string                       `json:"reason,omitempty"`
+	CreatedAt      time.Time                    `json:"created_at"`
+}
+
+type OrderTimelineResponse struct {
+	OrderID uint                       `json:"order_id"`
+	Events  []OrderStatusEventResponse `json:"events"`
+}
+
+// OrderStatusUpdate is pushed to clients watching an order. The first update of
+// a watch carries the current status without a previous status.
+type OrderStatusUpdate struct {
+	OrderID        uint                         `json:"order_id"`
+	PreviousStatus *model.PurchaseHistoryStatus `json:"previous_status"`
+	Status         model.PurchaseHistoryStatus  `json:"status"`
+	UpdatedAt      time.Time                    `json:"updated_at"`
+}
+
+// OrderReversalRequest fails a successful order, e.g. once the carrier took the
+// top-up back. Reason is kept on the timeline of the order.
+type OrderReversalRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
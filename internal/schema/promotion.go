@@ -0,0 +1,59 @@
+package schema
+
+import (
+	"time"
+	"top-up-api/internal/model"
+)
+
+type PromotionRequest struct {
+	Code          string             `json:"code" validate:"required,max=32"`
+	Name          string             `json:"name" validate:"required,max=100"`
+	Type          model.CashBackType `json:"type" validate:"required,oneof=percentage fixed"`
+	Value         int                `json:"value" validate:"gt=0"`
+	MaxCashBack   int                `json:"max_cash_back" validate:"gte=0"`
+	StartsAt      time.Time          `json:"starts_at" validate:"required"`
+	EndsAt        *time.Time         `json:"ends_at"`
+	SupplierCodes []string           `json:"supplier_codes" validate:"dive,required"`
+	SkuIDs        []uint             `json:"sku_ids" validate:"dive,gt=0"`
+	GlobalLimit   int                `json:"global_limit" validate:"gte=0"`
+	PerUserLimit  int                `json:"per_user_limit" validate:"gte=0"`
+	Stackable     bool               `json:"stackable"`
+	Voucher       bool               `json:"voucher"`
+	Active        *bool              `json:"active"`
+}
+
+type PromotionResponse struct {
+	ID              uint               `json:"id"`
+	Code            string             `json:"code"`
+	Name            string             `json:"name"`
+	Type            model.CashBackType `json:"type"`
+	Value           int                `json:"value"`
+	MaxCashBack     int                `json:"max_cash_back"`
+	StartsAt        time.Time          `json:"starts_at"`
+	EndsAt          *time.Time         `json:"ends_at"`
+	SupplierCodes   []string           `json:"supplier_codes"`
+	SkuIDs          []uint             `json:"sku_ids"`
+	GlobalLimit     int                `json:"global_limit"`
+	PerUserLimit    int                `json:"per_user_limit"`
+	RedemptionCount int                `json:"redemption_count"`
+	Stackable       bool               `json:"stackable"`
+	Voucher         bool               `json:"voucher"`
+	Active          bool               `json:"active"`
+	CreatedAt       time.Time          `json:"created_at"`
+}
+
+// AppliedPromotion is a promotion that gave an order cashback. Released is set
+// once the order failed and gave the redemption back.
+type AppliedPromotion struct {
+	PromotionID   uint   `json:"promotion_id"`
+	Code          string `json:"code"`
+	CashBackValue int    `json:"cash_back_value"`
+	Released      bool   `json:"released,omitempty"`
+}
+
+// PromotionEvaluation is the cashback an order gets. CashBackValue includes the
+// cash back rule of the sku unless an exclusive promotion replaced it.
+type PromotionEvaluation struct {
+	CashBackValue int
+	Promotions    []AppliedPromotion
+}
@@ -0,0 +1,37 @@
+package schema
+
+type ProviderRequest struct {
+	Code      string                    `json:"code" validate:"required,max=50"`
+	Source    string                    `json:"source" validate:"required"`
+	Type      string                    `json:"type" validate:"required,oneof=http grpc"`
+	Discount  float64                   `json:"discount" validate:"gte=0,lte=100"`
+	Suppliers []ProviderSupplierRequest `json:"suppliers" validate:"dive"`
+}
+
+// ProviderSupplierRequest assigns a supplier to the provider, the weight is
+// the share of the supplier's traffic the provider takes among its providers.
+type ProviderSupplierRequest struct {
+	SupplierCode string `json:"supplier_code" validate:"required"`
+	Weight       int    `json:"weight" validate:"gte=0"`
+}
+
+// ProviderSecretResponse is only returned when a provider callback secret is
+// issued, it is the one time the secret is shown.
+type ProviderSecretResponse struct {
+	ProviderAdminResponse
+	CallbackSecret string `json:"callback_secret"`
+}
+
+type ProviderAdminResponse struct {
+	ID        uint                       `json:"id"`
+	Code      string                     `json:"code"`
+	Source    string                     `json:"source"`
+	Type      string                     `json:"type"`
+	Discount  float64                    `json:"discount"`
+	Suppliers []ProviderSupplierResponse `json:"suppliers"`
+}
+
+type ProviderSupplierResponse struct {
+	SupplierCode string `json:"supplier_code"`
+	Weight       int    `json:"weight"`
+}
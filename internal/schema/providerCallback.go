@@ -0,0 +1,9 @@
+package schema
+
+// ProviderCallbackCredentials identify the provider that sent a status callback
+// and carry the signature over its nonce and body.
+type ProviderCallbackCredentials struct {
+	ProviderCode string
+	Nonce        string
+	Signature    string
+}
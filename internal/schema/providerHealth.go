@@ -0,0 +1,13 @@
+package schema
+
+import "time"
+
+type ProviderHealthResponse struct {
+	ProviderCode string     `json:"provider_code"`
+	State        string     `json:"state"`
+	Requests     int        `json:"requests"`
+	SuccessRate  float64    `json:"success_rate"`
+	AvgLatencyMs int64      `json:"avg_latency_ms"`
+	HealthScore  float64    `json:"health_score"`
+	OpenedAt     *time.Time `json:"opened_at,omitempty"`
+}
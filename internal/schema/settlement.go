@@ -0,0 +1,68 @@
+package schema
+
+import (
+	"time"
+	"top-up-api/internal/model"
+)
+
+// SettlementReportRequest selects the days of a settlement report, from and to
+// included, as YYYY-MM-DD in the settlement timezone.
+type SettlementReportRequest struct {
+	From string
+	To   string
+}
+
+// SettlementReportRow sums up the successful orders a provider handled for a
+// supplier on one day. FaceValue is the sku price, AmountCharged what the user
+// paid and CashBack what the user got back.
+type SettlementReportRow struct {
+	Day           string `json:"day"`
+	ProviderCode  string `json:"provider_code"`
+	SupplierCode  string `json:"supplier_code"`
+	SupplierName  string `json:"supplier_name"`
+	Orders        int    `json:"orders"`
+	FaceValue     int    `json:"face_value"`
+	AmountCharged int    `json:"amount_charged"`
+	CashBack      int    `json:"cash_back"`
+}
+
+// SettlementImportRequest names the provider a settlement file comes from and
+// the day it covers, as YYYY-MM-DD in the settlement timezone.
+type SettlementImportRequest struct {
+	ProviderCode string `form:"provider_code" validate:"required"`
+	Day          string `form:"day" validate:"required"`
+	FileName     string `form:"-"`
+}
+
+type SettlementImportResponse struct {
+	ID               uint                             `json:"id"`
+	ProviderCode     string                           `json:"provider_code"`
+	Day              string                           `json:"day"`
+	FileName         string                           `json:"file_name"`
+	RowCount         int                              `json:"row_count"`
+	MatchedCount     int                              `json:"matched_count"`
+	DiscrepancyCount int                              `json:"discrepancy_count"`
+	CreatedAt        time.Time                        `json:"created_at"`
+	Discrepancies    []*SettlementDiscrepancyResponse `json:"discrepancies,omitempty"`
+}
+
+type SettlementDiscrepancyResponse struct {
+	ID          uint                              `json:"id"`
+	ImportID    uint                              `json:"import_id"`
+	OrderID     uint                              `json:"order_id"`
+	Type        model.SettlementDiscrepancyType   `json:"type"`
+	OurAmount   *int                              `json:"our_amount"`
+	TheirAmount *int                              `json:"their_amount"`
+	OurStatus   string                            `json:"our_status"`
+	TheirStatus string                            `json:"their_status"`
+	Status      model.SettlementDiscrepancyStatus `json:"status"`
+	Note        string                            `json:"note,omitempty"`
+	ResolvedAt  *time.Time                        `json:"resolved_at,omitempty"`
+	CreatedAt   time.Time                         `json:"created_at"`
+}
+
+// SettlementDiscrepancyResolveRequest closes a discrepancy, the note says how
+// finance settled it with the provider.
+type SettlementDiscrepancyResolveRequest struct {
+	Note string `json:"note" validate:"required"`
+}
@@ -9,7 +9,31 @@ type SupplierResponse struct {
 	Status model.SupplierStatus `json:"status"`
 }
 
+// SupplierSuggestionResponse is the supplier of the carrier a phone number
+// belongs to, PhoneNumber is the number in its national form.
+type SupplierSuggestionResponse struct {
+	PhoneNumber string           `json:"phone_number"`
+	Supplier    SupplierResponse `json:"supplier"`
+}
+
 type SupplierInfo struct {
 	Code string `json:"code"`
 	Name string `json:"name"`
 }
+
+type SupplierRequest struct {
+	Code            string                `json:"code" validate:"required,max=10"`
+	Name            string                `json:"name" validate:"required,max=100"`
+	LogoUrl         string                `json:"logo_url" validate:"required,url"`
+	Status          model.SupplierStatus  `json:"status" validate:"required,oneof=active inactive"`
+	RoutingStrategy model.RoutingStrategy `json:"routing_strategy" validate:"omitempty,oneof=weighted_random least_cost lowest_latency round_robin sticky_phone_prefix"`
+}
+
+type SupplierAdminResponse struct {
+	ID              uint                  `json:"id"`
+	Code            string                `json:"code"`
+	Name            string                `json:"name"`
+	LogoUrl         string                `json:"logo_url"`
+	Status          model.SupplierStatus  `json:"status"`
+	RoutingStrategy model.RoutingStrategy `json:"routing_strategy"`
+}
@@ -0,0 +1,24 @@
+package schema
+
+import (
+	"time"
+	"top-up-api/internal/model"
+)
+
+// WalletBalanceResponse is the balance of a user's wallet, users that never got
+// cashback have a balance of 0.
+type WalletBalanceResponse struct {
+	UserID  uint `json:"user_id"`
+	Balance int  `json:"balance"`
+}
+
+// WalletStatementEntryResponse is one movement of a wallet. Amount is negative
+// for money leaving the wallet.
+type WalletStatementEntryResponse struct {
+	ID           uint                        `json:"id"`
+	Type         model.LedgerTransactionType `json:"type"`
+	OrderID      uint                        `json:"order_id"`
+	Amount       int                         `json:"amount"`
+	BalanceAfter int                         `json:"balance_after"`
+	CreatedAt    time.Time                   `json:"created_at"`
+}
@@ -0,0 +1,62 @@
+package schema
+
+import (
+	"time"
+	"top-up-api/internal/model"
+)
+
+type WebhookSubscriptionRequest struct {
+	Name       string   `json:"name" validate:"required,max=100"`
+	URL        string   `json:"url" validate:"required,url"`
+	EventTypes []string `json:"event_types" validate:"required,min=1,dive,oneof=order.succeeded order.failed"`
+	Active     *bool    `json:"active"`
+}
+
+// AdminWebhookSubscriptionRequest is a subscription an admin manages on behalf
+// of the partner that owns it
+type AdminWebhookSubscriptionRequest struct {
+	WebhookSubscriptionRequest
+	UserID uint `json:"user_id" validate:"required"`
+}
+
+type WebhookSubscriptionResponse struct {
+	ID         uint      `json:"id"`
+	UserID     uint      `json:"user_id"`
+	Name       string    `json:"name"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// WebhookSubscriptionCreatedResponse is only returned when a subscription is
+// created, it is the one time the signing secret is shown.
+type WebhookSubscriptionCreatedResponse struct {
+	WebhookSubscriptionResponse
+	Secret string `json:"secret"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID             uint                        `json:"id"`
+	SubscriptionID uint                        `json:"subscription_id"`
+	EventType      string                      `json:"event_type"`
+	AggregateID    uint                        `json:"aggregate_id"`
+	Payload        string                      `json:"payload"`
+	Status         model.WebhookDeliveryStatus `json:"status"`
+	Attempts       int                         `json:"attempts"`
+	ResponseStatus int                         `json:"response_status"`
+	LastError      string                      `json:"last_error"`
+	NextAttemptAt  time.Time                   `json:"next_attempt_at"`
+	DeliveredAt    *time.Time                  `json:"delivered_at"`
+	CreatedAt      time.Time                   `json:"created_at"`
+}
+
+// WebhookOrderEvent is the body partners receive for order lifecycle events
+type WebhookOrderEvent struct {
+	Event          string                      `json:"event"`
+	OrderID        uint                        `json:"order_id"`
+	UserID         uint                        `json:"user_id"`
+	PreviousStatus model.PurchaseHistoryStatus `json:"previous_status"`
+	Status         model.PurchaseHistoryStatus `json:"status"`
+	OccurredAt     time.Time                   `json:"occurred_at"`
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"top-up-api/internal/mapper"
+	"top-up-api/internal/model"
+	"top-up-api/internal/repository"
+	"top-up-api/internal/schema"
+	"top-up-api/pkg/errs"
+)
+
+type CashBackService interface {
+	CreateCashBack(ctx context.Context, request schema.CashBackRequest) (*schema.CashBackAdminResponse, error)
+	UpdateCashBack(ctx context.Context, id uint, request schema.CashBackRequest) (*schema.CashBackAdminResponse, error)
+	DeleteCashBack(ctx context.Context, id uint) error
+}
+
+type cashBackService struct {
+	repo repository.CashBackRepository
+}
+
+var _ CashBackService = (*cashBackService)(nil)
+
+func NewCashBackService(repo repository.CashBackRepository) *cashBackService {
+	return &cashBackService{repo: repo}
+}
+
+func (s *cashBackService) CreateCashBack(ctx context.Context, request schema.CashBackRequest) (*schema.CashBackAdminResponse, error) {
+	if err := validateCashBackValue(request); err != nil {
+		return nil, err
+	}
+
+	cashBack := mapper.CashBackFromRequest(request)
+	if err := s.repo.CreateCashBack(ctx, cashBack); err != nil {
+		return nil, catalogError(err, "cash back")
+	}
+	return mapper.CashBackAdminResponseFromModel(cashBack), nil
+}
+
+func (s *cashBackService) UpdateCashBack(ctx context.Context, id uint, request schema.CashBackRequest) (*schema.CashBackAdminResponse, error) {
+	if err := validateCashBackValue(request); err != nil {
+		return nil, err
+	}
+	existing, err := s.repo.GetCashBackByID(ctx, id)
+	if err != nil {
+		return nil, catalogError(err, "cash back")
+	}
+
+	cashBack := mapper.CashBackFromRequest(request)
+	cashBack.Model = existing.Model
+	if err := s.repo.UpdateCashBack(ctx, cashBack); err != nil {
+		return nil, catalogError(err, "cash back")
+	}
+	return mapper.CashBackAdminResponseFromModel(cashBack), nil
+}
+
+func (s *cashBackService) DeleteCashBack(ctx context.Context, id uint) error {
+	return catalogError(s.repo.DeleteCashBack(ctx, id), "cash back")
+}
+
+// validateCashBackValue rejects a percentage above 100, which would pay out more than the sku price
+func validateCashBackValue(request schema.CashBackRequest) error {
+	if request.Type == model.CashBackTypePercentage && request.Value > 100 {
+		return &errs.BadRequestError{Message: "percentage cash back must not exceed 100"}
+	}
+	return nil
+}
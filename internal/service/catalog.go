@@ -0,0 +1,38 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"top-up-api/pkg/errs"
+
+	"gorm.io/gorm"
+)
+
+// catalogError turns repository errors of a catalog write into errors the
+// admin API can map to a status code. The entity names the record in messages.
+func catalogError(err error, entity string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return &errs.NotFoundError{Message: entity + " not found"}
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return &errs.ConflictError{Message: entity + " already exists"}
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return &errs.BadRequestError{Message: entity + " references a record that does not exist"}
+	default:
+		return err
+	}
+}
+
+const _secretBytes = 32
+
+// newSecret generates a signing secret, the prefix tells what it signs.
+func newSecret(prefix string) (string, error) {
+	secret := make([]byte, _secretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", errors.New("failed to generate secret: " + err.Error())
+	}
+	return prefix + hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+	"top-up-api/internal/model"
+)
+
+type orderEventSourceKey struct{}
+
+type orderEventReasonKey struct{}
+
+// WithOrderEventSource tags ctx with the channel an order change arrived
+// through. It is stored as the source of the order status events written for it.
+func WithOrderEventSource(ctx context.Context, source model.OrderStatusEventSource) context.Context {
+	return context.WithValue(ctx, orderEventSourceKey{}, source)
+}
+
+func orderEventSource(ctx context.Context) model.OrderStatusEventSource {
+	if source, ok := ctx.Value(orderEventSourceKey{}).(model.OrderStatusEventSource); ok {
+		return source
+	}
+	return model.OrderStatusEventSourceHTTP
+}
+
+// WithOrderEventReason tags ctx with why an operator changed an order. It is
+// stored as the reason of the order status events written for it.
+func WithOrderEventReason(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, orderEventReasonKey{}, reason)
+}
+
+func orderEventReason(ctx context.Context) string {
+	reason, _ := ctx.Value(orderEventReasonKey{}).(string)
+	return reason
+}
@@ -5,54 +5,108 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"math/rand/v2"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"top-up-api/config"
 	pb "top-up-api/internal/grpc/client"
 	"top-up-api/internal/mapper"
 	"top-up-api/internal/model"
 	"top-up-api/internal/repository"
 	"top-up-api/internal/schema"
+	"top-up-api/internal/statemachine"
+	"top-up-api/pkg/errs"
+	"top-up-api/pkg/phone"
 	"top-up-api/pkg/redis"
 	"top-up-api/pkg/util"
+	providerpb "top-up-api/proto/provider"
 
 	"gorm.io/gorm"
 )
 
 const (
-	_lockTimeOut              = 5 * time.Minute
+	_lockTimeOut              = 30 * time.Second
 	_orderCacheTime           = 30 * time.Minute
 	_idempotencyCacheTime     = 24 * time.Hour
 	_orderRequestKeyPrefix    = "order_id"
 	_providerRequestKeyPrefix = "order_req_id"
-	_paymentCreateURL         = "http://localhost:8081/v1/api/order/create"
-	_paymentUpdateURL         = "http://localhost:8081/v1/api/order/update"
-	_callbackURL              = "http://localhost:8080/v1/api/order/update-status"
+	_defaultPaymentCreateURL  = "http://localhost:8081/v1/api/order/create"
+	_defaultPaymentUpdateURL  = "http://localhost:8081/v1/api/order/update"
+	_defaultCallbackURL       = "http://localhost:8080/v1/api/order/update-status"
 )
 
 type OrderService interface {
 	CreateOrder(ctx context.Context, order schema.OrderRequest) (*schema.OrderResponse, error)
 	ConfirmOrder(ctx context.Context, orderConfirmRequest schema.OrderConfirmRequest) error
 	UpdateOrderStatus(ctx context.Context, orderUpdateInfo schema.OrderUpdateRequest) error
+	GetOrder(ctx context.Context, orderID, userID uint) (*schema.OrderDetailResponse, error)
+	WatchOrder(ctx context.Context, orderID, userID uint) (<-chan schema.OrderStatusUpdate, error)
+	GetOrderTimeline(ctx context.Context, orderID uint) (*schema.OrderTimelineResponse, error)
+	GetUserOrderTimeline(ctx context.Context, orderID, userID uint) (*schema.OrderTimelineResponse, error)
+	// ExpirePendingOrders expires at most limit orders still pending payment
+	// that were created before createdBefore, and returns how many it expired.
+	ExpirePendingOrders(ctx context.Context, createdBefore time.Time, limit int) (int, error)
+	// ReconcileStuckOrders settles the confirmed orders a provider never called
+	// back for, or queues them for manual review.
+	ReconcileStuckOrders(ctx context.Context, updatedBefore, escalateBefore time.Time, limit int) (schema.ReconciliationResult, error)
+	GetOrderReviews(ctx context.Context, status model.OrderReviewStatus) ([]*schema.OrderReviewResponse, error)
+	ResolveOrderReview(ctx context.Context, id uint, request schema.OrderReviewResolveRequest) (*schema.OrderReviewResponse, error)
+	// ReverseOrder fails a successful order on behalf of an operator, refunding
+	// its payment and taking its cashback back.
+	ReverseOrder(ctx context.Context, orderID uint, request schema.OrderReversalRequest) error
+	// CreateOrderBatch creates the orders of a batch, paid with a single
+	// payment that ConfirmOrderBatch records.
+	CreateOrderBatch(ctx context.Context, request schema.OrderBatchRequest) (*schema.OrderBatchResponse, error)
+	CreateOrderBatchFromFile(ctx context.Context, userID uint, file io.Reader) (*schema.OrderBatchResponse, error)
+	ConfirmOrderBatch(ctx context.Context, request schema.OrderBatchConfirmRequest) error
+	GetOrderBatch(ctx context.Context, batchID, userID uint) (*schema.OrderBatchResponse, error)
+	// DispatchOrderBatches starts the dispatch of at most limit orders of the
+	// paid batches, and returns how many it started.
+	DispatchOrderBatches(ctx context.Context, limit int) (int, error)
+	// ExpirePendingOrderBatches expires at most limit batches still pending
+	// payment that were created before createdBefore, and returns how many it expired.
+	ExpirePendingOrderBatches(ctx context.Context, createdBefore time.Time, limit int) (int, error)
+	DispatchOrder(ctx context.Context, orderID uint) error
+	GetProviderHealth(ctx context.Context) []schema.ProviderHealthResponse
+	ReloadProviders(ctx context.Context) error
+	WaitForDispatches()
 }
 
 type orderService struct {
-	skuRepo             repository.SkuRepository
-	purchaseHistoryRepo repository.PurchaseHistoryRepository
-	redisClient         redis.Interface
-	providerClients     map[string]providerServiceList
-}
-
-type providerServiceList struct {
-	totalWeight     int
-	providerClients []providerClient
+	skuRepo              repository.SkuRepository
+	purchaseHistoryRepo  repository.PurchaseHistoryRepository
+	orderRepo            repository.OrderRepository
+	orderStatusEventRepo repository.OrderStatusEventRepository
+	outboxRepo           repository.OutboxRepository
+	providerAttemptRepo  repository.ProviderAttemptRepository
+	providerRepo         repository.ProviderRepository
+	orderReviewRepo      repository.OrderReviewRepository
+	orderBatchRepo       repository.OrderBatchRepository
+	walletService        WalletService
+	promotionService     PromotionService
+	txManager            repository.TransactionManager
+	redisClient          redis.Interface
+	grpcClients          *pb.GRPCServiceClient
+	orderStates          *statemachine.OrderStateMachine
+	routing              atomic.Pointer[routingTable]
+	strategies           map[model.RoutingStrategy]routingStrategy
+	reloadMu             sync.Mutex
+	dispatchConfig       config.ProviderDispatch
+	paymentConfig        config.Payment
+	dispatches           sync.WaitGroup
 }
 
 type providerClient interface {
-	getCumulativeWeight() int
-	sendRequest(order *schema.OrderResponse) error
+	getProviderCode() string
+	sendRequest(ctx context.Context, order *schema.OrderResponse) error
+	// queryOrder asks the provider for the status of an order it was sent.
+	queryOrder(ctx context.Context, orderID uint) (string, error)
 }
 
 var _ OrderService = (*orderService)(nil)
@@ -62,20 +116,80 @@ var _ providerClient = (*grpcProviderClient)(nil)
 func NewOrderService(
 	skuRepo repository.SkuRepository,
 	purchaseHistoryRepo repository.PurchaseHistoryRepository,
+	orderRepo repository.OrderRepository,
+	orderStatusEventRepo repository.OrderStatusEventRepository,
+	outboxRepo repository.OutboxRepository,
+	providerAttemptRepo repository.ProviderAttemptRepository,
+	txManager repository.TransactionManager,
 	redisClient redis.Interface,
-	grpcClients pb.GRPCServiceClient,
+	grpcClients *pb.GRPCServiceClient,
 	providerRepo repository.ProviderRepository,
+	orderReviewRepo repository.OrderReviewRepository,
+	orderBatchRepo repository.OrderBatchRepository,
+	walletService WalletService,
+	promotionService PromotionService,
+	dispatchConfig config.ProviderDispatch,
+	paymentConfig config.Payment,
 ) *orderService {
+	if dispatchConfig.MaxAttempts <= 0 {
+		dispatchConfig.MaxAttempts = _defaultDispatchMaxAttempts
+	}
+	if dispatchConfig.RetryBackoff <= 0 {
+		dispatchConfig.RetryBackoff = _defaultDispatchRetryBackoff
+	}
+	if dispatchConfig.RequestTimeout <= 0 {
+		dispatchConfig.RequestTimeout = _defaultDispatchRequestTimeout
+	}
+	if paymentConfig.CreateURL == "" {
+		paymentConfig.CreateURL = _defaultPaymentCreateURL
+	}
+	if paymentConfig.UpdateURL == "" {
+		paymentConfig.UpdateURL = _defaultPaymentUpdateURL
+	}
+	if paymentConfig.CallbackURL == "" {
+		paymentConfig.CallbackURL = _defaultCallbackURL
+	}
+	if paymentConfig.BatchCreateURL == "" {
+		paymentConfig.BatchCreateURL = _defaultPaymentBatchCreateURL
+	}
+	if paymentConfig.BatchUpdateURL == "" {
+		paymentConfig.BatchUpdateURL = _defaultPaymentBatchUpdateURL
+	}
 
-	return &orderService{
-		skuRepo:             skuRepo,
-		purchaseHistoryRepo: purchaseHistoryRepo,
-		redisClient:         redisClient,
-		providerClients:     getProviderClientsListMapping(providerRepo, grpcClients),
+	s := &orderService{
+		skuRepo:              skuRepo,
+		purchaseHistoryRepo:  purchaseHistoryRepo,
+		orderRepo:            orderRepo,
+		orderStatusEventRepo: orderStatusEventRepo,
+		outboxRepo:           outboxRepo,
+		providerAttemptRepo:  providerAttemptRepo,
+		providerRepo:         providerRepo,
+		orderReviewRepo:      orderReviewRepo,
+		orderBatchRepo:       orderBatchRepo,
+		walletService:        walletService,
+		promotionService:     promotionService,
+		txManager:            txManager,
+		redisClient:          redisClient,
+		grpcClients:          grpcClients,
+		orderStates:          statemachine.NewOrderStateMachine(),
+		strategies:           newRoutingStrategies(),
+		dispatchConfig:       dispatchConfig,
+		paymentConfig:        paymentConfig,
 	}
+
+	// Orders can't be dispatched until ReloadProviders filled the routing table
+	s.routing.Store(newRoutingTable())
+
+	return s
 }
 
 func (s *orderService) CreateOrder(ctx context.Context, order schema.OrderRequest) (*schema.OrderResponse, error) {
+	phoneNumber, err := phone.Normalize(order.PhoneNumber)
+	if err != nil {
+		return nil, &errs.BadRequestError{Message: "phone number must be a Vietnamese mobile number"}
+	}
+	order.PhoneNumber = phoneNumber
+
 	sku, err := s.skuRepo.GetSkuByID(ctx, order.SkuID)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -83,128 +197,365 @@ func (s *orderService) CreateOrder(ctx context.Context, order schema.OrderReques
 		}
 		return nil, err
 	}
+	if err := checkCarrier(phoneNumber, sku); err != nil {
+		return nil, err
+	}
+
+	if order.WalletAmount < 0 || order.WalletAmount > sku.Price {
+		return nil, &errs.BadRequestError{Message: "wallet amount must be between 0 and the order total"}
+	}
+
+	evaluation, err := s.promotionService.Evaluate(ctx, order.UserID, sku, order.VoucherCode)
+	if err != nil {
+		return nil, err
+	}
 
 	orderID := util.GenerateOrderID()
-	orderResponse := mapper.OrderResponseFromOrderRequest(order, sku, orderID)
-	SupplierCode := orderResponse.Sku.SupplierInfo.Code
-	orderResponse.RandomProviderWeight = getRandomWeight(s.providerClients[SupplierCode].totalWeight)
+	orderResponse := mapper.OrderResponseFromOrderRequest(order, sku, orderID, evaluation)
 
 	orderResponseJSON, err := json.Marshal(orderResponse)
 	if err != nil {
 		return nil, errors.New("failed to marshal order response: " + err.Error())
 	}
 
-	cacheKey := getCachKey(_orderRequestKeyPrefix, strconv.Itoa(int(orderID)))
-	err = s.redisClient.Set(ctx, cacheKey, orderResponseJSON, _orderCacheTime)
+	err = s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.orderRepo.CreateOrder(ctx, mapper.OrderFromOrderResponse(orderResponse)); err != nil {
+			return err
+		}
+		if err := s.promotionService.Redeem(ctx, orderResponse.UserID, orderID, orderResponse.Promotions); err != nil {
+			return err
+		}
+		if err := s.walletService.PayOrder(ctx, orderResponse.UserID, orderID, orderResponse.WalletAmount); err != nil {
+			return err
+		}
+		event := mapper.OrderStatusEventFromTransition(orderID, nil, orderResponse.Status, orderEventSource(ctx))
+		if err := s.orderStatusEventRepo.CreateOrderStatusEvent(ctx, event); err != nil {
+			return err
+		}
+		message := mapper.OutboxMessageFromHTTPRequest(orderID, model.OutboxEventOrderCreated, http.MethodPost, s.paymentConfig.CreateURL, orderResponseJSON)
+		return s.outboxRepo.CreateOutboxMessage(ctx, message)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	go util.SendPostRequest(_paymentCreateURL, orderResponseJSON)
+	// The order is durable at this point, a failed cache write is repaired by
+	// the read-through in getCachedOrder.
+	cacheKey := getCachKey(_orderRequestKeyPrefix, strconv.Itoa(int(orderID)))
+	s.redisClient.Set(ctx, cacheKey, orderResponseJSON, _orderCacheTime)
 
 	return orderResponse, nil
 }
 
+// checkCarrier rejects an order of a sku for another carrier than the one of
+// the phone number, the provider would only reject it after it was paid.
+func checkCarrier(phoneNumber string, sku *model.Sku) error {
+	carrier, err := phone.Carrier(phoneNumber)
+	if err != nil {
+		return &errs.BadRequestError{Message: fmt.Sprintf("phone number %s doesn't belong to a known carrier", phoneNumber)}
+	}
+	if carrier != sku.SupplierCode {
+		return &errs.BadRequestError{Message: fmt.Sprintf("phone number %s is a %s number, the sku is for %s", phoneNumber, carrier, sku.SupplierCode)}
+	}
+	return nil
+}
+
+// ConfirmOrder records the payment result of an order. The payment has to match
+// the order the server priced, and its reference can only confirm one order.
 func (s *orderService) ConfirmOrder(ctx context.Context, orderConfirmRequest schema.OrderConfirmRequest) error {
+	if orderConfirmRequest.PaymentReference == "" {
+		return &errs.BadRequestError{Message: "payment reference is required"}
+	}
+
 	orderID := strconv.Itoa(int(orderConfirmRequest.OrderID))
-	err := s.redisClient.TryAcquireLock(ctx, orderID, _lockTimeOut)
+	lock, unlock, err := s.lockOrder(ctx, orderConfirmRequest.OrderID)
 	if err != nil {
 		return err
 	}
-	defer s.redisClient.ReleaseLock(ctx, orderID)
+	defer unlock()
 
 	cacheKey := getCachKey(_orderRequestKeyPrefix, orderID)
 
-	orderResponse, err := s.getCachedOrder(ctx, cacheKey)
+	orderResponse, err := s.getStoredOrder(ctx, orderConfirmRequest.OrderID)
 	if err != nil {
 		return err
 	}
+	if orderResponse.BatchID != nil {
+		return &errs.BadRequestError{Message: fmt.Sprintf("order %d is paid with order batch %d", orderConfirmRequest.OrderID, *orderResponse.BatchID)}
+	}
 	if !orderResponse.CompareWithOrderConfirmRequest(orderConfirmRequest) {
-		return errors.New("order mismatch")
+		return &errs.BadRequestError{Message: "order mismatch"}
 	}
 
-	if orderConfirmRequest.Status == model.PurchaseHistoryStatusPending {
-		return errors.New("order is pending")
+	err = s.orderStates.TransitionFrom(model.PurchaseHistoryStatusPending, orderResponse.Status, orderConfirmRequest.Status)
+	if err != nil {
+		return err
 	}
-
-	if orderResponse.Status != model.PurchaseHistoryStatusPending {
-		return errors.New("order already confirmed or failed")
+	if err := checkPaymentStatus(orderConfirmRequest.Status); err != nil {
+		return err
 	}
 
-	purchaseHistory := mapper.PurchaseHistoryFromOrderConfirmRequest(orderConfirmRequest)
-	err = s.purchaseHistoryRepo.CreatePurchaseHistory(ctx, purchaseHistory)
+	purchaseHistory := mapper.PurchaseHistoryFromConfirmedOrder(orderResponse, orderConfirmRequest)
+	err = s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		paymentReference := &model.PaymentReference{Reference: orderConfirmRequest.PaymentReference, OrderID: &orderConfirmRequest.OrderID}
+		if err := s.createPaymentReference(ctx, paymentReference); err != nil {
+			return err
+		}
+		if err := s.purchaseHistoryRepo.CreatePurchaseHistory(ctx, purchaseHistory); err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return &errs.ConflictError{Message: fmt.Sprintf("order %d was already confirmed", orderConfirmRequest.OrderID)}
+			}
+			return err
+		}
+		return s.changeOrderStatus(ctx, orderResponse, orderConfirmRequest.Status, lock.Fence)
+	})
 	if err != nil {
 		return err
 	}
 
+	previousStatus := orderResponse.Status
 	orderResponse.Status = orderConfirmRequest.Status
-	err = s.updateCacheOrderStaus(ctx, cacheKey, orderResponse)
-	if err != nil {
-		return err
-	}
+	s.updateCacheOrderStaus(ctx, cacheKey, orderResponse)
+	s.publishOrderStatus(ctx, orderConfirmRequest.OrderID, previousStatus, orderConfirmRequest.Status)
 
 	if orderConfirmRequest.Status == model.PurchaseHistoryStatusConfirm {
-		go s.sendRequestToProvider(orderResponse)
+		s.startDispatch(ctx, orderResponse)
 	}
 
 	return nil
 }
 
+// checkPaymentStatus keeps the payment service to the statuses a payment can
+// give an order. The state machine also lets a pending order expire, but only
+// the expiry sweeper may do that.
+func checkPaymentStatus(status model.PurchaseHistoryStatus) error {
+	if status != model.PurchaseHistoryStatusConfirm && status != model.PurchaseHistoryStatusFailed {
+		return &errs.BadRequestError{Message: fmt.Sprintf("payment can only confirm or fail an order, not set it to %s", status)}
+	}
+	return nil
+}
+
+// createPaymentReference records the payment that paid an order or a batch. The
+// references of both share one table, a payment that already paid either is
+// rejected.
+func (s *orderService) createPaymentReference(ctx context.Context, paymentReference *model.PaymentReference) error {
+	if err := s.purchaseHistoryRepo.CreatePaymentReference(ctx, paymentReference); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return &errs.ConflictError{Message: fmt.Sprintf("payment %s already paid an order or an order batch", paymentReference.Reference)}
+		}
+		return err
+	}
+	return nil
+}
+
 func (s *orderService) UpdateOrderStatus(ctx context.Context, orderUpdateInfo schema.OrderUpdateRequest) error {
-	orderID := strconv.Itoa(int(orderUpdateInfo.OrderID))
+	// Checked before the idempotency cache, so a rejected callback never answers
+	// for the one of the assigned provider.
+	if err := s.checkCallbackProvider(ctx, orderUpdateInfo.OrderID, orderUpdateInfo.ProviderCode); err != nil {
+		return err
+	}
+	return s.settleOrder(ctx, orderUpdateInfo.OrderID, orderUpdateInfo.Status)
+}
 
-	idempotencyKey := getCachKey(_providerRequestKeyPrefix, orderID)
+// settleOrder applies the top-up result of a dispatched order. Provider
+// callbacks, the reconciliation and manual reviews all settle orders through it.
+func (s *orderService) settleOrder(ctx context.Context, settledOrderID uint, status model.PurchaseHistoryStatus) error {
+	orderID := strconv.Itoa(int(settledOrderID))
+
+	// Keyed by status too, a callback with another result isn't answered with
+	// the result of the first one
+	idempotencyKey := getCachKey(_providerRequestKeyPrefix, orderID+":"+string(status))
 	cachedResponse, err := s.redisClient.Get(ctx, idempotencyKey)
 	if err == nil && cachedResponse != "" {
 		return getIdempotencyResponseValue(cachedResponse)
 	}
 
-	err = s.redisClient.TryAcquireLock(ctx, orderID, _lockTimeOut)
+	lock, unlock, err := s.lockOrder(ctx, settledOrderID)
 	if err != nil {
 		return err
 	}
-	defer s.redisClient.ReleaseLock(ctx, orderID)
+	defer unlock()
 
 	orderCacheKey := getCachKey(_orderRequestKeyPrefix, orderID)
-	orderResponse, err := s.getCachedOrder(ctx, orderCacheKey)
+	orderResponse, err := s.getStoredOrder(ctx, settledOrderID)
 	if err != nil {
-		s.cacheIdempotencyResponse(ctx, idempotencyKey, false, err.Error())
 		return err
 	}
 
-	if orderResponse.Status != model.PurchaseHistoryStatusConfirm {
-		err := errors.New("order is not confirmed or failed")
+	// Providers only settle dispatched orders. Only final outcomes are cached,
+	// an order out of confirm never gets back to it, while other errors are
+	// transient and the reconciler and manual review retry under the same key.
+	err = s.orderStates.TransitionFrom(model.PurchaseHistoryStatusConfirm, orderResponse.Status, status)
+	if err != nil {
 		s.cacheIdempotencyResponse(ctx, idempotencyKey, false, err.Error())
 		return err
 	}
 
-	err = s.purchaseHistoryRepo.UpdatePurchaseHistoryStatusByOrderID(ctx, orderUpdateInfo.OrderID, orderUpdateInfo.Status)
+	err = s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.purchaseHistoryRepo.UpdatePurchaseHistoryStatusByOrderID(ctx, settledOrderID, status); err != nil {
+			return err
+		}
+		if err := s.changeOrderStatus(ctx, orderResponse, status, lock.Fence); err != nil {
+			return err
+		}
+		if status == model.PurchaseHistoryStatusFailed {
+			return s.enqueueFailedOrder(ctx, settledOrderID)
+		}
+		return nil
+	})
 	if err != nil {
-		s.cacheIdempotencyResponse(ctx, idempotencyKey, false, err.Error())
 		return err
 	}
 
-	orderResponse.Status = orderUpdateInfo.Status
-	err = s.updateCacheOrderStaus(ctx, orderCacheKey, orderResponse)
+	previousStatus := orderResponse.Status
+	orderResponse.Status = status
+	s.updateCacheOrderStaus(ctx, orderCacheKey, orderResponse)
+	s.publishOrderStatus(ctx, settledOrderID, previousStatus, status)
+
+	s.cacheIdempotencyResponse(ctx, idempotencyKey, true, "")
+
+	return nil
+}
+
+// GetOrder returns the order of a user merged with its purchase history. An
+// order of another user is reported as not found.
+func (s *orderService) GetOrder(ctx context.Context, orderID, userID uint) (*schema.OrderDetailResponse, error) {
+	orderResponse, err := s.getCachedOrder(ctx, orderID)
 	if err != nil {
-		s.cacheIdempotencyResponse(ctx, idempotencyKey, false, err.Error())
+		return nil, err
+	}
+	if orderResponse.UserID != userID {
+		return nil, &errs.NotFoundError{Message: "order not found"}
+	}
+
+	// The purchase history only exists once the payment confirmed the order
+	purchaseHistory, err := s.purchaseHistoryRepo.GetPurchaseHistoryByOrderID(ctx, orderID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		purchaseHistory = nil
+	}
+
+	return mapper.OrderDetailResponseFromOrder(orderResponse, purchaseHistory), nil
+}
+
+func (s *orderService) GetOrderTimeline(ctx context.Context, orderID uint) (*schema.OrderTimelineResponse, error) {
+	events, err := s.orderStatusEventRepo.GetOrderStatusEventsByOrderID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, &errs.NotFoundError{Message: "order not found"}
+	}
+	return mapper.OrderTimelineResponseFromModel(orderID, events), nil
+}
+
+// GetUserOrderTimeline returns the timeline of an order of a user. An order of
+// another user is reported as not found.
+func (s *orderService) GetUserOrderTimeline(ctx context.Context, orderID, userID uint) (*schema.OrderTimelineResponse, error) {
+	orderResponse, err := s.getCachedOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if orderResponse.UserID != userID {
+		return nil, &errs.NotFoundError{Message: "order not found"}
+	}
+	return s.GetOrderTimeline(ctx, orderID)
+}
+
+// changeOrderStatus writes an already validated status change to the order,
+// appends it to the order's timeline, books it in the user's wallet and queues
+// the partner webhooks it triggers. Callers run it inside a transaction,
+// holding the order lock whose fencing token is fence.
+func (s *orderService) changeOrderStatus(ctx context.Context, order *schema.OrderResponse, to model.PurchaseHistoryStatus, fence int64) error {
+	orderID, from := order.OrderID, order.Status
+	if err := s.orderRepo.UpdateOrderStatusByOrderID(ctx, orderID, to, fence); err != nil {
+		if errors.Is(err, repository.ErrStaleFence) {
+			return &errs.ConflictError{Message: fmt.Sprintf("order %d lock expired before its status was written", orderID)}
+		}
 		return err
 	}
+	event := mapper.OrderStatusEventFromTransition(orderID, &from, to, orderEventSource(ctx))
+	event.Reason = orderEventReason(ctx)
+	if err := s.orderStatusEventRepo.CreateOrderStatusEvent(ctx, event); err != nil {
+		return err
+	}
+	if err := s.postOrderToWallet(ctx, order, to); err != nil {
+		return err
+	}
+	if (to == model.PurchaseHistoryStatusFailed || to == model.PurchaseHistoryStatusExpired) && len(order.Promotions) > 0 {
+		if err := s.promotionService.Release(ctx, orderID); err != nil {
+			return err
+		}
+		for i := range order.Promotions {
+			order.Promotions[i].Released = true
+		}
+	}
+	return s.enqueueWebhookEvent(ctx, orderID, order.UserID, from, to)
+}
 
-	if orderUpdateInfo.Status == model.PurchaseHistoryStatusFailed {
-		go sendFailedOrder(_paymentUpdateURL, orderUpdateInfo)
+// postOrderToWallet credits the cashback of an order that succeeded. An order
+// that failed or expired gets its wallet payment back, and a reversed order
+// loses the cashback it got when it succeeded.
+func (s *orderService) postOrderToWallet(ctx context.Context, order *schema.OrderResponse, to model.PurchaseHistoryStatus) error {
+	switch to {
+	case model.PurchaseHistoryStatusSuccess:
+		return s.walletService.CreditCashBack(ctx, order.UserID, order.OrderID, order.CashBackValue)
+	case model.PurchaseHistoryStatusFailed, model.PurchaseHistoryStatusExpired:
+		if order.Status == model.PurchaseHistoryStatusSuccess {
+			if err := s.walletService.ReverseCashBack(ctx, order.UserID, order.OrderID, order.CashBackValue); err != nil {
+				return err
+			}
+		}
+		return s.walletService.RefundOrder(ctx, order.UserID, order.OrderID, order.WalletAmount)
+	default:
+		return nil
 	}
+}
 
-	s.cacheIdempotencyResponse(ctx, idempotencyKey, true, "")
+// lockOrder takes the lock of an order and keeps renewing its lease until unlock
+// is called. Status writes made under it pass lock.Fence to the repository.
+func (s *orderService) lockOrder(ctx context.Context, orderID uint) (*redis.Lock, func(), error) {
+	return s.acquireLock(ctx, strconv.Itoa(int(orderID)))
+}
+
+func (s *orderService) acquireLock(ctx context.Context, key string) (*redis.Lock, func(), error) {
+	lock, err := s.redisClient.TryAcquireLock(ctx, key, _lockTimeOut)
+	if err != nil {
+		return nil, nil, err
+	}
+	stopRenewal := redis.KeepAlive(ctx, s.redisClient, lock, _lockTimeOut)
+	return lock, func() {
+		stopRenewal()
+		s.redisClient.ReleaseLock(context.WithoutCancel(ctx), lock)
+	}, nil
+}
 
+// checkCallbackProvider rejects status callbacks of any provider but the one the
+// order was last sent to.
+func (s *orderService) checkCallbackProvider(ctx context.Context, orderID uint, providerCode string) error {
+	assigned, err := s.orderRepo.GetOrderProviderCode(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &errs.NotFoundError{Message: "order not found"}
+		}
+		return err
+	}
+	if assigned == "" || assigned != providerCode {
+		return &errs.ForbiddenError{Message: fmt.Sprintf("order %d is not assigned to provider %s", orderID, providerCode)}
+	}
 	return nil
 }
 
-func (s *orderService) getCachedOrder(ctx context.Context, cacheKey string) (*schema.OrderResponse, error) {
+// getCachedOrder reads the order from Redis and falls back to Postgres on a
+// cache miss, repopulating the cache with the persisted order.
+func (s *orderService) getCachedOrder(ctx context.Context, orderID uint) (*schema.OrderResponse, error) {
+	cacheKey := getCachKey(_orderRequestKeyPrefix, strconv.Itoa(int(orderID)))
 
 	order, err := s.redisClient.Get(ctx, cacheKey)
 	if err != nil {
-		return nil, errors.New("order not found or expired")
+		return s.loadOrder(ctx, cacheKey, orderID)
 	}
 
 	var orderResponse *schema.OrderResponse
@@ -216,19 +567,40 @@ func (s *orderService) getCachedOrder(ctx context.Context, cacheKey string) (*sc
 	return orderResponse, nil
 }
 
-func (s *orderService) updateCacheOrderStaus(ctx context.Context, cacheKey string, orderResponse *schema.OrderResponse) error {
-	orderResponseJSON, err := json.Marshal(orderResponse)
-
+func (s *orderService) loadOrder(ctx context.Context, cacheKey string, orderID uint) (*schema.OrderResponse, error) {
+	orderResponse, err := s.getStoredOrder(ctx, orderID)
 	if err != nil {
-		return errors.New("failed to marshal order response: " + err.Error())
+		return nil, err
 	}
+	s.updateCacheOrderStaus(ctx, cacheKey, orderResponse)
+
+	return orderResponse, nil
+}
 
-	err = s.redisClient.Set(ctx, cacheKey, orderResponseJSON, _orderCacheTime)
+// getStoredOrder reads the order from Postgres. Status changes made under the
+// order lock decide on it, the cache may still hold the order as it was
+// before the last change of another instance.
+func (s *orderService) getStoredOrder(ctx context.Context, orderID uint) (*schema.OrderResponse, error) {
+	order, err := s.orderRepo.GetOrderByOrderID(ctx, orderID)
 	if err != nil {
-		return err
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &errs.NotFoundError{Message: "order not found or expired"}
+		}
+		return nil, err
 	}
+	return mapper.OrderResponseFromModel(order), nil
+}
 
-	return nil
+// updateCacheOrderStaus refreshes the cached order. Postgres holds the source of
+// truth, so when the write fails the stale entry is dropped instead.
+func (s *orderService) updateCacheOrderStaus(ctx context.Context, cacheKey string, orderResponse *schema.OrderResponse) {
+	orderResponseJSON, err := json.Marshal(orderResponse)
+	if err == nil {
+		err = s.redisClient.Set(ctx, cacheKey, orderResponseJSON, _orderCacheTime)
+	}
+	if err != nil {
+		s.redisClient.Del(ctx, cacheKey)
+	}
 }
 
 func (s *orderService) cacheIdempotencyResponse(ctx context.Context, key string, success bool, errorMessage string) {
@@ -246,48 +618,56 @@ func (s *orderService) cacheIdempotencyResponse(ctx context.Context, key string,
 	s.redisClient.Set(ctx, key, responseJSON, _idempotencyCacheTime)
 }
 
-func (s *orderService) sendRequestToProvider(orderResponse *schema.OrderResponse) error {
-	supplierCode := orderResponse.Sku.SupplierInfo.Code
-
-	for _, client := range s.providerClients[supplierCode].providerClients {
-		if orderResponse.RandomProviderWeight <= client.getCumulativeWeight() {
-			err := client.sendRequest(orderResponse)
-			return err
-		}
+// enqueueFailedOrder writes the payment service notification for a failed
+// order to the outbox. Callers run it inside the transaction that fails the order.
+func (s *orderService) enqueueFailedOrder(ctx context.Context, orderID uint) error {
+	return s.enqueuePaymentUpdate(ctx, orderID, model.OutboxEventOrderFailed, model.PurchaseHistoryStatusFailed)
+}
 
-	}
-	return errors.New("can't find suitable provider")
+// enqueueExpiredOrder tells the payment service to cancel the payment of an
+// expired order. Callers run it inside the transaction that expires the order.
+func (s *orderService) enqueueExpiredOrder(ctx context.Context, orderID uint) error {
+	return s.enqueuePaymentUpdate(ctx, orderID, model.OutboxEventOrderExpired, model.PurchaseHistoryStatusExpired)
 }
 
-func sendFailedOrder(url string, orderUpdateInfo schema.OrderUpdateRequest) {
-	payload, _ := json.Marshal(map[string]interface{}{
-		"order_id": orderUpdateInfo.OrderID,
-		"status":   orderUpdateInfo.Status,
+func (s *orderService) enqueuePaymentUpdate(ctx context.Context, orderID uint, eventType string, status model.PurchaseHistoryStatus) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"order_id": orderID,
+		"status":   status,
 	})
-
-	req, err := http.NewRequest(http.MethodPatch, url, bytes.NewBuffer(payload))
 	if err != nil {
-		return
+		return err
 	}
+	message := mapper.OutboxMessageFromHTTPRequest(orderID, eventType, http.MethodPatch, s.paymentConfig.UpdateURL, payload)
+	return s.outboxRepo.CreateOutboxMessage(ctx, message)
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	_, err = http.DefaultClient.Do(req)
+// enqueueWebhookEvent writes the partner webhook event of a status change to the
+// outbox, changes partners aren't told about are skipped. The event carries the
+// user of the order, only the subscriptions of that user receive it.
+func (s *orderService) enqueueWebhookEvent(ctx context.Context, orderID, userID uint, from, to model.PurchaseHistoryStatus) error {
+	eventType, ok := webhookEventOf(to)
+	if !ok {
+		return nil
+	}
+	payload, err := json.Marshal(schema.WebhookOrderEvent{
+		Event:          eventType,
+		OrderID:        orderID,
+		UserID:         userID,
+		PreviousStatus: from,
+		Status:         to,
+		OccurredAt:     time.Now(),
+	})
 	if err != nil {
-		return
+		return err
 	}
+	return s.outboxRepo.CreateOutboxMessage(ctx, mapper.OutboxMessageFromWebhookEvent(orderID, eventType, payload))
 }
 
 func getCachKey(prefix string, orderID string) string {
 	return prefix + orderID
 }
 
-func getRandomWeight(totalWeight int) int {
-	if totalWeight <= 0 {
-		return 0
-	}
-	return rand.IntN(totalWeight)
-}
-
 func getIdempotencyResponseValue(cachedResponse string) error {
 	var idempotencyResponse schema.OrderIdempotencyResponse
 	if err := json.Unmarshal([]byte(cachedResponse), &idempotencyResponse); err != nil {
@@ -300,78 +680,98 @@ func getIdempotencyResponseValue(cachedResponse string) error {
 }
 
 type httpProviderClient struct {
-	url              string
-	callbacks        string
-	cumulativeWeight int
+	code      string
+	url       string
+	callbacks string
 }
 
-func (h *httpProviderClient) sendRequest(order *schema.OrderResponse) error {
+func (h *httpProviderClient) sendRequest(ctx context.Context, order *schema.OrderResponse) error {
 	orderProviderRequest := mapper.OrderProviderRequestFromOrderResponse(order, h.callbacks)
 	orderProviderRequestJSON, err := json.Marshal(orderProviderRequest)
 	if err != nil {
 		return err
 	}
-	util.SendPostRequest(h.url, orderProviderRequestJSON)
-	return nil
-}
 
-func (h *httpProviderClient) getCumulativeWeight() int {
-	return h.cumulativeWeight
-}
-
-type grpcProviderClient struct {
-	client           pb.ProviderGRPCClient
-	callbacks        string
-	cumulativeWeight int
-}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewBuffer(orderProviderRequestJSON))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
 
-func (g *grpcProviderClient) sendRequest(order *schema.OrderResponse) error {
-	ctx := context.Background()
-	defer ctx.Done()
-	req := mapper.OrderProcessRequestFromOrder(order, g.callbacks)
-	return g.client.ProcessOrder(ctx, req)
-}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
 
-func (g *grpcProviderClient) getCumulativeWeight() int {
-	return g.cumulativeWeight
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &providerStatusError{StatusCode: resp.StatusCode}
+	}
+	return nil
 }
 
-func getProviderClientsListMapping(providerRepo repository.ProviderRepository, grpcClients pb.GRPCServiceClient) map[string]providerServiceList {
-	ctx := context.Background()
-	providers, err := providerRepo.GetProvidersWithSuppliers(ctx)
+// queryOrder gets <provider url>/<order id>, a provider that answers 404 never
+// got the order.
+func (h *httpProviderClient) queryOrder(ctx context.Context, orderID uint) (string, error) {
+	url := strings.TrimSuffix(h.url, "/") + "/" + strconv.Itoa(int(orderID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		panic("failed to load providers: " + err.Error())
+		return "", err
 	}
 
-	grpcClients.BuildProviderGRPCClients(providers)
-	supplierClients := make(map[string]providerServiceList)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
 
-	for _, provider := range providers {
-		for _, supplier := range provider.Suppliers {
-			entry := supplierClients[supplier.Code]
-			cumulativeWeight := entry.totalWeight + provider.Weight
+	if resp.StatusCode == http.StatusNotFound {
+		return schema.ProviderOrderStatusNotFound, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", &providerStatusError{StatusCode: resp.StatusCode}
+	}
 
-			client := createProviderClient(provider, grpcClients, cumulativeWeight)
-			entry.providerClients = append(entry.providerClients, client)
-			entry.totalWeight = cumulativeWeight
-			supplierClients[supplier.Code] = entry
-		}
+	var queryResponse schema.OrderProviderQueryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&queryResponse); err != nil {
+		return "", fmt.Errorf("failed to decode provider response: %w", err)
 	}
+	return queryResponse.Status, nil
+}
 
-	return supplierClients
+func (h *httpProviderClient) getProviderCode() string {
+	return h.code
 }
 
-func createProviderClient(provider model.Provider, grpcClients pb.GRPCServiceClient, cumulativeWeight int) providerClient {
-	switch provider.Type {
-	case "http":
-		return &httpProviderClient{
-			url: provider.Source, callbacks: _callbackURL, cumulativeWeight: cumulativeWeight,
-		}
-	case "grpc":
-		return &grpcProviderClient{
-			client: grpcClients.ProviderGRPCClients[provider.Code], callbacks: _callbackURL, cumulativeWeight: cumulativeWeight,
-		}
-	default:
-		panic("unsupported provider type: " + provider.Type)
+// grpcProviderClient looks its connection up on every request, so a
+// connection replaced by a reload is never used after it was closed.
+type grpcProviderClient struct {
+	code      string
+	clients   *pb.GRPCServiceClient
+	callbacks string
+}
+
+func (g *grpcProviderClient) sendRequest(ctx context.Context, order *schema.OrderResponse) error {
+	client, ok := g.clients.ProviderGRPCClient(g.code)
+	if !ok {
+		return fmt.Errorf("%w %s", errProviderNotConnected, g.code)
+	}
+	req := mapper.OrderProcessRequestFromOrder(order, g.callbacks)
+	return client.ProcessOrder(ctx, req)
+}
+
+func (g *grpcProviderClient) queryOrder(ctx context.Context, orderID uint) (string, error) {
+	client, ok := g.clients.ProviderGRPCClient(g.code)
+	if !ok {
+		return "", fmt.Errorf("no gRPC connection for provider %s", g.code)
+	}
+	resp, err := client.QueryOrder(ctx, &providerpb.OrderQueryRequest{OrderId: uint64(orderID)})
+	if err != nil {
+		return "", err
 	}
+	return resp.Status, nil
+}
+
+func (g *grpcProviderClient) getProviderCode() string {
+	return g.code
 }
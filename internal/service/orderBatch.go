@@ -0,0 +1,393 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"top-up-api/internal/mapper"
+	"top-up-api/internal/model"
+	"top-up-api/internal/repository"
+	"top-up-api/internal/schema"
+	"top-up-api/internal/statemachine"
+	"top-up-api/pkg/errs"
+	"top-up-api/pkg/phone"
+	"top-up-api/pkg/redis"
+	"top-up-api/pkg/util"
+
+	"gorm.io/gorm"
+)
+
+const (
+	_maxOrderBatchLines           = 1000
+	_orderBatchLockPrefix         = "order_batch:"
+	_defaultPaymentBatchCreateURL = "http://localhost:8081/v1/api/order/batch/create"
+	_defaultPaymentBatchUpdateURL = "http://localhost:8081/v1/api/order/batch/update"
+)
+
+// CreateOrderBatch creates an order for each line of a batch, priced like
+// CreateOrder prices an order with the cashback of its sku. A batch with
+// invalid lines is rejected with the errors of all of them, so the list can be
+// fixed at once. The payment service is told to collect the batch total.
+func (s *orderService) CreateOrderBatch(ctx context.Context, request schema.OrderBatchRequest) (*schema.OrderBatchResponse, error) {
+	if len(request.Lines) == 0 {
+		return nil, &errs.BadRequestError{Message: "order batch has no lines"}
+	}
+	if len(request.Lines) > _maxOrderBatchLines {
+		return nil, &errs.BadRequestError{Message: fmt.Sprintf("an order batch has at most %d lines", _maxOrderBatchLines)}
+	}
+
+	batchID := util.GenerateOrderID()
+	skus := make(map[uint]*model.Sku)
+	orders := make([]*schema.OrderResponse, 0, len(request.Lines))
+	var lineErrors []string
+	for i, line := range request.Lines {
+		order, err := s.priceOrderBatchLine(ctx, request.UserID, line, skus)
+		if err != nil {
+			var badRequestErr *errs.BadRequestError
+			if !errors.As(err, &badRequestErr) {
+				return nil, err
+			}
+			lineErrors = append(lineErrors, fmt.Sprintf("line %d: %s", i+1, badRequestErr.Message))
+			continue
+		}
+		order.BatchID = &batchID
+		order.BatchLine = i + 1
+		orders = append(orders, order)
+	}
+	if len(lineErrors) > 0 {
+		return nil, &errs.BadRequestError{Message: strings.Join(lineErrors, "; ")}
+	}
+
+	batch := mapper.OrderBatchFromOrders(batchID, request.UserID, orders)
+	err := s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.orderBatchRepo.CreateOrderBatch(ctx, batch); err != nil {
+			return err
+		}
+		for _, order := range orders {
+			event := mapper.OrderStatusEventFromTransition(order.OrderID, nil, order.Status, orderEventSource(ctx))
+			if err := s.orderStatusEventRepo.CreateOrderStatusEvent(ctx, event); err != nil {
+				return err
+			}
+		}
+		payload, err := json.Marshal(mapper.OrderBatchResponseFromModel(batch))
+		if err != nil {
+			return err
+		}
+		message := mapper.OutboxMessageFromHTTPRequest(batchID, model.OutboxEventOrderBatchCreated, http.MethodPost, s.paymentConfig.BatchCreateURL, payload)
+		return s.outboxRepo.CreateOutboxMessage(ctx, message)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mapper.OrderBatchResponseFromModel(batch), nil
+}
+
+// CreateOrderBatchFromFile creates a batch from a CSV file with a phone_number
+// and a sku_id column, each row after the header is a line of the batch.
+func (s *orderService) CreateOrderBatchFromFile(ctx context.Context, userID uint, file io.Reader) (*schema.OrderBatchResponse, error) {
+	lines, err := parseOrderBatchFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return s.CreateOrderBatch(ctx, schema.OrderBatchRequest{UserID: userID, Lines: lines})
+}
+
+// priceOrderBatchLine prices a line of a batch. skus holds the skus already
+// read for the batch.
+func (s *orderService) priceOrderBatchLine(ctx context.Context, userID uint, line schema.OrderBatchLine, skus map[uint]*model.Sku) (*schema.OrderResponse, error) {
+	phoneNumber, err := phone.Normalize(line.PhoneNumber)
+	if err != nil {
+		return nil, &errs.BadRequestError{Message: fmt.Sprintf("phone number %q must be a Vietnamese mobile number", line.PhoneNumber)}
+	}
+
+	sku, ok := skus[line.SkuID]
+	if !ok {
+		sku, err = s.skuRepo.GetSkuByID(ctx, line.SkuID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, &errs.BadRequestError{Message: fmt.Sprintf("sku %d not found", line.SkuID)}
+			}
+			return nil, err
+		}
+		skus[line.SkuID] = sku
+	}
+	if err := checkCarrier(phoneNumber, sku); err != nil {
+		return nil, err
+	}
+
+	evaluation := &schema.PromotionEvaluation{
+		CashBackValue: min(mapper.CashBackFromModel(sku.CashBack).CalculateCashBack(sku.Price), sku.Price),
+	}
+	orderRequest := schema.OrderRequest{UserID: userID, SkuID: sku.ID, PhoneNumber: phoneNumber}
+	return mapper.OrderResponseFromOrderRequest(orderRequest, sku, util.GenerateOrderID(), evaluation), nil
+}
+
+// ConfirmOrderBatch records the payment result of a batch on the batch and all
+// of its orders. The orders of a paid batch are left to DispatchOrderBatches,
+// which sends them to their providers at a limited rate.
+func (s *orderService) ConfirmOrderBatch(ctx context.Context, request schema.OrderBatchConfirmRequest) error {
+	if request.PaymentReference == "" {
+		return &errs.BadRequestError{Message: "payment reference is required"}
+	}
+
+	lock, unlock, err := s.lockOrderBatch(ctx, request.BatchID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	batch, err := s.getOrderBatch(ctx, request.BatchID)
+	if err != nil {
+		return err
+	}
+	if batch.UserID != request.UserID || batch.TotalPrice != request.TotalPrice {
+		return &errs.BadRequestError{Message: "order batch mismatch"}
+	}
+
+	err = s.orderStates.TransitionFrom(model.PurchaseHistoryStatusPending, batch.Status, request.Status)
+	if err != nil {
+		return err
+	}
+	if err := checkPaymentStatus(request.Status); err != nil {
+		return err
+	}
+	return s.changeOrderBatchStatus(ctx, batch, request.Status, request.PaymentReference, lock.Fence)
+}
+
+// GetOrderBatch returns a batch of a user with the result of each of its
+// lines. A batch of another user is reported as not found.
+func (s *orderService) GetOrderBatch(ctx context.Context, batchID, userID uint) (*schema.OrderBatchResponse, error) {
+	batch, err := s.getOrderBatch(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if batch.UserID != userID {
+		return nil, &errs.NotFoundError{Message: "order batch not found"}
+	}
+	return mapper.OrderBatchResponseFromModel(batch), nil
+}
+
+// DispatchOrderBatches starts the dispatch of at most limit orders of the paid
+// batches and returns how many it started. Run at a fixed interval, it caps
+// the rate batch orders reach the providers at. An order that can't be read
+// once claimed isn't claimed again, the reconciliation escalates it.
+func (s *orderService) DispatchOrderBatches(ctx context.Context, limit int) (int, error) {
+	orderIDs, err := s.orderBatchRepo.ClaimOrderBatchOrders(ctx, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	dispatched := 0
+	var failures []error
+	for _, orderID := range orderIDs {
+		orderResponse, err := s.getCachedOrder(ctx, orderID)
+		if err != nil {
+			failures = append(failures, fmt.Errorf("order %d: %w", orderID, err))
+			continue
+		}
+		s.startDispatch(ctx, orderResponse)
+		dispatched++
+	}
+	return dispatched, errors.Join(failures...)
+}
+
+// ExpirePendingOrderBatches expires the batches whose payment was abandoned,
+// with all their orders, like ExpirePendingOrders expires single orders.
+func (s *orderService) ExpirePendingOrderBatches(ctx context.Context, createdBefore time.Time, limit int) (int, error) {
+	batchIDs, err := s.orderBatchRepo.GetPendingOrderBatchIDsCreatedBefore(ctx, createdBefore, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	var failures []error
+	for _, batchID := range batchIDs {
+		err := s.expireOrderBatch(ctx, batchID)
+		var transitionErr *statemachine.TransitionError
+		switch {
+		case err == nil:
+			expired++
+		case errors.As(err, &transitionErr):
+			// Paid or failed in the meantime, there is nothing to expire
+		default:
+			failures = append(failures, fmt.Errorf("order batch %d: %w", batchID, err))
+		}
+	}
+	return expired, errors.Join(failures...)
+}
+
+func (s *orderService) expireOrderBatch(ctx context.Context, batchID uint) error {
+	ctx = WithOrderEventSource(ctx, model.OrderStatusEventSourceExpirySweeper)
+	lock, unlock, err := s.lockOrderBatch(ctx, batchID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	batch, err := s.getOrderBatch(ctx, batchID)
+	if err != nil {
+		return err
+	}
+	err = s.orderStates.TransitionFrom(model.PurchaseHistoryStatusPending, batch.Status, model.PurchaseHistoryStatusExpired)
+	if err != nil {
+		return err
+	}
+	return s.changeOrderBatchStatus(ctx, batch, model.PurchaseHistoryStatusExpired, "", lock.Fence)
+}
+
+// changeOrderBatchStatus moves a pending batch and all of its orders to status
+// to in one transaction, recording each order in the purchase history. As a
+// payment reference is only recorded once, the orders of a paid batch get the
+// reference suffixed with their line. The orders are written under the batch
+// lock whose fencing token is fence, nothing else writes them while the batch
+// is pending.
+func (s *orderService) changeOrderBatchStatus(ctx context.Context, batch *model.OrderBatch, to model.PurchaseHistoryStatus, paymentReference string, fence int64) error {
+	orders := make([]*schema.OrderResponse, len(batch.Orders))
+	for i := range batch.Orders {
+		orders[i] = mapper.OrderResponseFromModel(&batch.Orders[i])
+	}
+
+	err := s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.orderBatchRepo.UpdateOrderBatchStatus(ctx, batch.BatchID, batch.Status, to, paymentReference); err != nil {
+			switch {
+			case errors.Is(err, gorm.ErrDuplicatedKey):
+				return &errs.ConflictError{Message: fmt.Sprintf("payment %s already paid an order batch", paymentReference)}
+			case errors.Is(err, repository.ErrOrderBatchStatusChanged):
+				return &errs.ConflictError{Message: fmt.Sprintf("order batch %d was changed before its status was written", batch.BatchID)}
+			}
+			return err
+		}
+		if paymentReference != "" {
+			if err := s.createPaymentReference(ctx, &model.PaymentReference{Reference: paymentReference, BatchID: &batch.BatchID}); err != nil {
+				return err
+			}
+		}
+		for _, order := range orders {
+			if err := s.orderStates.TransitionFrom(model.PurchaseHistoryStatusPending, order.Status, to); err != nil {
+				return err
+			}
+			purchaseHistory := mapper.PurchaseHistoryFromExpiredOrder(order)
+			if to != model.PurchaseHistoryStatusExpired {
+				purchaseHistory = mapper.PurchaseHistoryFromConfirmedOrder(order, schema.OrderConfirmRequest{
+					Status:           to,
+					PaymentReference: fmt.Sprintf("%s#%d", paymentReference, order.BatchLine),
+				})
+			}
+			if err := s.purchaseHistoryRepo.CreatePurchaseHistory(ctx, purchaseHistory); err != nil {
+				if errors.Is(err, gorm.ErrDuplicatedKey) {
+					return &errs.ConflictError{Message: fmt.Sprintf("order %d was already confirmed", order.OrderID)}
+				}
+				return err
+			}
+			if err := s.changeOrderStatus(ctx, order, to, fence); err != nil {
+				return err
+			}
+		}
+		if to == model.PurchaseHistoryStatusExpired {
+			return s.enqueueExpiredOrderBatch(ctx, batch.BatchID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, order := range orders {
+		previousStatus := order.Status
+		order.Status = to
+		s.updateCacheOrderStaus(ctx, getCachKey(_orderRequestKeyPrefix, strconv.Itoa(int(order.OrderID))), order)
+		s.publishOrderStatus(ctx, order.OrderID, previousStatus, to)
+	}
+	return nil
+}
+
+func (s *orderService) getOrderBatch(ctx context.Context, batchID uint) (*model.OrderBatch, error) {
+	batch, err := s.orderBatchRepo.GetOrderBatchByBatchID(ctx, batchID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &errs.NotFoundError{Message: "order batch not found"}
+		}
+		return nil, err
+	}
+	return batch, nil
+}
+
+// lockOrderBatch takes the lock of a batch, the orders of a pending batch are
+// only written under it.
+func (s *orderService) lockOrderBatch(ctx context.Context, batchID uint) (*redis.Lock, func(), error) {
+	return s.acquireLock(ctx, _orderBatchLockPrefix+strconv.Itoa(int(batchID)))
+}
+
+// enqueueExpiredOrderBatch tells the payment service to cancel the payment of
+// an expired batch. Callers run it inside the transaction that expires the batch.
+func (s *orderService) enqueueExpiredOrderBatch(ctx context.Context, batchID uint) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"batch_id": batchID,
+		"status":   model.PurchaseHistoryStatusExpired,
+	})
+	if err != nil {
+		return err
+	}
+	message := mapper.OutboxMessageFromHTTPRequest(batchID, model.OutboxEventOrderBatchExpired, http.MethodPatch, s.paymentConfig.BatchUpdateURL, payload)
+	return s.outboxRepo.CreateOutboxMessage(ctx, message)
+}
+
+// parseOrderBatchFile reads the lines of a batch file. The first line names
+// the columns, matched ignoring case.
+func parseOrderBatchFile(file io.Reader) ([]schema.OrderBatchLine, error) {
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, &errs.BadRequestError{Message: "order batch file is empty"}
+	}
+	if err != nil {
+		return nil, &errs.BadRequestError{Message: fmt.Sprintf("invalid order batch file: %s", err)}
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	phoneIndex, ok := columns["phone_number"]
+	if !ok {
+		return nil, &errs.BadRequestError{Message: "order batch file has no phone_number column"}
+	}
+	skuIndex, ok := columns["sku_id"]
+	if !ok {
+		return nil, &errs.BadRequestError{Message: "order batch file has no sku_id column"}
+	}
+
+	var lines []schema.OrderBatchLine
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, &errs.BadRequestError{Message: fmt.Sprintf("invalid order batch file: %s", err)}
+		}
+		if len(lines) == _maxOrderBatchLines {
+			return nil, &errs.BadRequestError{Message: fmt.Sprintf("an order batch has at most %d lines", _maxOrderBatchLines)}
+		}
+		line, _ := reader.FieldPos(0)
+
+		skuID, err := strconv.ParseUint(strings.TrimSpace(record[skuIndex]), 10, 64)
+		if err != nil {
+			return nil, &errs.BadRequestError{Message: fmt.Sprintf("file line %d: invalid sku id %q", line, record[skuIndex])}
+		}
+		lines = append(lines, schema.OrderBatchLine{
+			SkuID:       uint(skuID),
+			PhoneNumber: strings.TrimSpace(record[phoneIndex]),
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"top-up-api/internal/mapper"
+	"top-up-api/internal/model"
+	"top-up-api/internal/statemachine"
+)
+
+// ExpirePendingOrders expires the orders whose payment was abandoned. An order
+// that was confirmed while the batch ran is skipped, and an order that can't be
+// expired is left for the next sweep.
+func (s *orderService) ExpirePendingOrders(ctx context.Context, createdBefore time.Time, limit int) (int, error) {
+	orderIDs, err := s.orderRepo.GetPendingOrderIDsCreatedBefore(ctx, createdBefore, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	var failures []error
+	for _, orderID := range orderIDs {
+		err := s.expireOrder(ctx, orderID)
+		var transitionErr *statemachine.TransitionError
+		switch {
+		case err == nil:
+			expired++
+		case errors.As(err, &transitionErr):
+			// Paid or failed in the meantime, there is nothing to expire
+		default:
+			failures = append(failures, err)
+		}
+	}
+	return expired, errors.Join(failures...)
+}
+
+// expireOrder expires a pending order, records it in the purchase history and
+// tells the payment service to cancel its payment through the outbox.
+func (s *orderService) expireOrder(ctx context.Context, orderID uint) error {
+	ctx = WithOrderEventSource(ctx, model.OrderStatusEventSourceExpirySweeper)
+	lock, unlock, err := s.lockOrder(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	orderResponse, err := s.getStoredOrder(ctx, orderID)
+	if err != nil {
+		return err
+	}
+
+	// The payment may have been confirmed since the order was listed.
+	err = s.orderStates.TransitionFrom(model.PurchaseHistoryStatusPending, orderResponse.Status, model.PurchaseHistoryStatusExpired)
+	if err != nil {
+		return err
+	}
+
+	err = s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.purchaseHistoryRepo.CreatePurchaseHistory(ctx, mapper.PurchaseHistoryFromExpiredOrder(orderResponse)); err != nil {
+			return err
+		}
+		if err := s.changeOrderStatus(ctx, orderResponse, model.PurchaseHistoryStatusExpired, lock.Fence); err != nil {
+			return err
+		}
+		return s.enqueueExpiredOrder(ctx, orderID)
+	})
+	if err != nil {
+		return err
+	}
+
+	previousStatus := orderResponse.Status
+	orderResponse.Status = model.PurchaseHistoryStatusExpired
+	s.updateCacheOrderStaus(ctx, getCachKey(_orderRequestKeyPrefix, strconv.Itoa(int(orderID))), orderResponse)
+	s.publishOrderStatus(ctx, orderID, previousStatus, model.PurchaseHistoryStatusExpired)
+	return nil
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"top-up-api/internal/mapper"
+	"top-up-api/internal/model"
+	"top-up-api/internal/repository"
+	"top-up-api/internal/schema"
+	"top-up-api/pkg/errs"
+)
+
+// ReconcileStuckOrders asks the providers of the confirmed orders that didn't
+// change since updatedBefore for the result of their top-up, and settles the
+// orders a provider reports settled. Orders that never reached a provider are
+// dispatched again. An order still unresolved that didn't change since
+// escalateBefore is queued for manual review.
+func (s *orderService) ReconcileStuckOrders(ctx context.Context, updatedBefore, escalateBefore time.Time, limit int) (schema.ReconciliationResult, error) {
+	var result schema.ReconciliationResult
+	orders, err := s.orderRepo.GetStuckOrders(ctx, updatedBefore, limit)
+	if err != nil {
+		return result, err
+	}
+
+	ctx = WithOrderEventSource(ctx, model.OrderStatusEventSourceReconciler)
+	var failures []error
+	for i := range orders {
+		order := &orders[i]
+		if order.ProviderCode == "" {
+			err := s.redispatchOrder(ctx, order.OrderID)
+			switch {
+			case err == nil, errors.Is(err, errProviderOutcomeUnsure):
+				result.Dispatched++
+				continue
+			case errors.Is(err, errAllProvidersFailed):
+				result.Settled++
+				continue
+			case !errors.Is(err, errDispatchDeferred):
+				failures = append(failures, fmt.Errorf("order %d: %w", order.OrderID, err))
+				continue
+			}
+		}
+
+		status, reason := s.queryOrderStatus(ctx, order)
+		if status != "" {
+			if err := s.settleOrder(ctx, order.OrderID, status); err != nil {
+				failures = append(failures, fmt.Errorf("order %d: %w", order.OrderID, err))
+				continue
+			}
+			result.Settled++
+			continue
+		}
+
+		if !order.UpdatedAt.Before(escalateBefore) {
+			continue
+		}
+		if err := s.orderReviewRepo.CreateOrderReview(ctx, mapper.OrderReviewFromStuckOrder(order, reason)); err != nil {
+			failures = append(failures, fmt.Errorf("order %d: %w", order.OrderID, err))
+			continue
+		}
+		result.Escalated++
+	}
+	return result, errors.Join(failures...)
+}
+
+// redispatchOrder dispatches a confirmed order that no provider was assigned,
+// because there was none to route it to or the assignment failed.
+func (s *orderService) redispatchOrder(ctx context.Context, orderID uint) error {
+	orderResponse, err := s.getStoredOrder(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	if orderResponse.Status != model.PurchaseHistoryStatusConfirm {
+		return fmt.Errorf("order %d is %s, only confirmed orders can be dispatched", orderID, orderResponse.Status)
+	}
+	return s.dispatchOrder(ctx, orderResponse)
+}
+
+// queryOrderStatus asks the provider the order was assigned to for its result.
+// It returns the status to settle the order with, or why it is unresolved.
+func (s *orderService) queryOrderStatus(ctx context.Context, order *model.Order) (model.PurchaseHistoryStatus, string) {
+	if order.ProviderCode == "" {
+		return "", "order was never dispatched to a provider"
+	}
+	client, ok := s.routing.Load().providers[order.ProviderCode]
+	if !ok {
+		return "", fmt.Sprintf("provider %s is no longer configured", order.ProviderCode)
+	}
+
+	requestCtx, cancel := context.WithTimeout(ctx, s.dispatchConfig.RequestTimeout)
+	defer cancel()
+	providerStatus, err := client.queryOrder(requestCtx, order.OrderID)
+	if err != nil {
+		return "", fmt.Sprintf("provider %s couldn't be queried: %s", order.ProviderCode, err)
+	}
+
+	switch providerStatus {
+	case schema.ProviderOrderStatusSuccess:
+		return model.PurchaseHistoryStatusSuccess, ""
+	case schema.ProviderOrderStatusFailed:
+		return model.PurchaseHistoryStatusFailed, ""
+	case schema.ProviderOrderStatusNotFound:
+		return "", fmt.Sprintf("provider %s never got the order", order.ProviderCode)
+	default:
+		return "", fmt.Sprintf("provider %s reports the order as %s", order.ProviderCode, providerStatus)
+	}
+}
+
+func (s *orderService) GetOrderReviews(ctx context.Context, status model.OrderReviewStatus) ([]*schema.OrderReviewResponse, error) {
+	reviews, err := s.orderReviewRepo.GetOrderReviews(ctx, status)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*schema.OrderReviewResponse, len(reviews))
+	for i := range reviews {
+		responses[i] = mapper.OrderReviewResponseFromModel(&reviews[i])
+	}
+	return responses, nil
+}
+
+// ResolveOrderReview settles the order of an open review with the result an
+// operator got from the provider and closes the review. An order settled with
+// the same result since it was queued only has its review closed.
+func (s *orderService) ResolveOrderReview(ctx context.Context, id uint, request schema.OrderReviewResolveRequest) (*schema.OrderReviewResponse, error) {
+	review, err := s.orderReviewRepo.GetOrderReviewByID(ctx, id)
+	if err != nil {
+		return nil, catalogError(err, "order review")
+	}
+	if review.Status != model.OrderReviewStatusOpen {
+		return nil, &errs.ConflictError{Message: fmt.Sprintf("order review %d is already resolved", id)}
+	}
+
+	ctx = WithOrderEventSource(ctx, model.OrderStatusEventSourceManualReview)
+	ctx = WithOrderEventReason(ctx, request.Note)
+	if err := s.settleOrder(ctx, review.OrderID, request.Status); err != nil {
+		order, getErr := s.getStoredOrder(ctx, review.OrderID)
+		if getErr != nil || !s.orderStates.IsSettled(order.Status) {
+			return nil, err
+		}
+		if order.Status != request.Status {
+			return nil, &errs.ConflictError{Message: fmt.Sprintf("order %d was already settled as %s", review.OrderID, order.Status)}
+		}
+	}
+
+	resolvedAt := time.Now()
+	if err := s.orderReviewRepo.ResolveOrderReview(ctx, id, request.Status, request.Note, resolvedAt); err != nil {
+		if errors.Is(err, repository.ErrOrderReviewResolved) {
+			return nil, &errs.ConflictError{Message: fmt.Sprintf("order review %d is already resolved", id)}
+		}
+		return nil, err
+	}
+
+	review.Status = model.OrderReviewStatusResolved
+	review.Resolution = &request.Status
+	review.Note = request.Note
+	review.ResolvedAt = &resolvedAt
+	return mapper.OrderReviewResponseFromModel(review), nil
+}
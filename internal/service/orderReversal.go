@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"strconv"
+
+	"top-up-api/internal/model"
+	"top-up-api/internal/schema"
+)
+
+// ReverseOrder fails a successful order, e.g. once the carrier took the top-up
+// back. Provider callbacks can't do it, success is terminal for them. The
+// reversal is kept on the timeline of the order with the operator's reason, the
+// payment service is told to refund the order through the outbox and the wallet
+// gives the wallet payment back and takes the cashback back.
+func (s *orderService) ReverseOrder(ctx context.Context, orderID uint, request schema.OrderReversalRequest) error {
+	ctx = WithOrderEventSource(ctx, model.OrderStatusEventSourceAdmin)
+	ctx = WithOrderEventReason(ctx, request.Reason)
+	lock, unlock, err := s.lockOrder(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	orderResponse, err := s.getStoredOrder(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	status, err := s.orderStates.Reverse(orderResponse.Status)
+	if err != nil {
+		return err
+	}
+
+	err = s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.purchaseHistoryRepo.UpdatePurchaseHistoryStatusByOrderID(ctx, orderID, status); err != nil {
+			return err
+		}
+		if err := s.changeOrderStatus(ctx, orderResponse, status, lock.Fence); err != nil {
+			return err
+		}
+		return s.enqueueFailedOrder(ctx, orderID)
+	})
+	if err != nil {
+		return err
+	}
+
+	previousStatus := orderResponse.Status
+	orderResponse.Status = status
+	s.updateCacheOrderStaus(ctx, getCachKey(_orderRequestKeyPrefix, strconv.Itoa(int(orderID))), orderResponse)
+	s.publishOrderStatus(ctx, orderID, previousStatus, status)
+	return nil
+}
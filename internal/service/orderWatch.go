@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"top-up-api/internal/mapper"
+	"top-up-api/internal/model"
+	"top-up-api/internal/schema"
+)
+
+const _orderStatusChannelPrefix = "order_status:"
+
+// WatchOrder streams the status changes of an order of the user. The current
+// status is sent first, and the channel is closed once the order settles or
+// expires, or ctx is done.
+func (s *orderService) WatchOrder(ctx context.Context, orderID, userID uint) (<-chan schema.OrderStatusUpdate, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	// Subscribe before reading the order so no change slips in between
+	messages, err := s.redisClient.Subscribe(ctx, getOrderStatusChannel(orderID))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	order, err := s.GetOrder(ctx, orderID, userID)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	updates := make(chan schema.OrderStatusUpdate)
+	go func() {
+		defer cancel()
+		defer close(updates)
+
+		current := mapper.OrderStatusUpdateFromTransition(orderID, nil, order.Status)
+		if !s.sendOrderStatusUpdate(ctx, updates, current) {
+			return
+		}
+
+		for message := range messages {
+			var update schema.OrderStatusUpdate
+			if err := json.Unmarshal([]byte(message), &update); err != nil {
+				continue
+			}
+			// The first update may already include a change published while it was read
+			if update.Status == current.Status {
+				continue
+			}
+			current = update
+			if !s.sendOrderStatusUpdate(ctx, updates, current) {
+				return
+			}
+		}
+	}()
+
+	return updates, nil
+}
+
+// sendOrderStatusUpdate hands an update to the watcher and reports whether the
+// watch goes on.
+func (s *orderService) sendOrderStatusUpdate(ctx context.Context, updates chan<- schema.OrderStatusUpdate, update schema.OrderStatusUpdate) bool {
+	select {
+	case updates <- update:
+		return !s.orderStates.IsSettled(update.Status) && !s.orderStates.IsTerminal(update.Status)
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// publishOrderStatus announces a committed status change to the watchers on
+// every instance. Watching is best effort, so a failed publish is dropped.
+func (s *orderService) publishOrderStatus(ctx context.Context, orderID uint, from, to model.PurchaseHistoryStatus) {
+	payload, err := json.Marshal(mapper.OrderStatusUpdateFromTransition(orderID, &from, to))
+	if err != nil {
+		return
+	}
+	s.redisClient.Publish(ctx, getOrderStatusChannel(orderID), payload)
+}
+
+func getOrderStatusChannel(orderID uint) string {
+	return _orderStatusChannelPrefix + strconv.Itoa(int(orderID))
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"top-up-api/config"
+	"top-up-api/internal/model"
+	"top-up-api/internal/repository"
+	kfk "top-up-api/pkg/kafka"
+)
+
+const (
+	_defaultOutboxBatchSize   = 50
+	_defaultOutboxMaxAttempts = 10
+	_defaultOutboxBaseBackoff = time.Second
+	_defaultOutboxMaxBackoff  = 5 * time.Minute
+	_defaultOutboxHTTPTimeout = 10 * time.Second
+)
+
+type OutboxService interface {
+	// DispatchPending delivers the outbox messages that are due and returns how
+	// many of them were delivered.
+	DispatchPending(ctx context.Context) (int, error)
+}
+
+type outboxService struct {
+	outboxRepo repository.OutboxRepository
+	txManager  repository.TransactionManager
+	producer   kfk.Producer
+	webhooks   WebhookService
+	httpClient *http.Client
+	config     config.Outbox
+}
+
+var _ OutboxService = (*outboxService)(nil)
+
+func NewOutboxService(
+	outboxRepo repository.OutboxRepository,
+	txManager repository.TransactionManager,
+	producer kfk.Producer,
+	webhooks WebhookService,
+	cfg config.Outbox,
+) *outboxService {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = _defaultOutboxBatchSize
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = _defaultOutboxMaxAttempts
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = _defaultOutboxBaseBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = _defaultOutboxMaxBackoff
+	}
+	if cfg.HTTPTimeout <= 0 {
+		cfg.HTTPTimeout = _defaultOutboxHTTPTimeout
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = defaultLease(cfg.BatchSize, cfg.HTTPTimeout)
+	}
+
+	return &outboxService{
+		outboxRepo: outboxRepo,
+		txManager:  txManager,
+		producer:   producer,
+		webhooks:   webhooks,
+		httpClient: &http.Client{Timeout: cfg.HTTPTimeout},
+		config:     cfg,
+	}
+}
+
+func (s *outboxService) DispatchPending(ctx context.Context) (int, error) {
+	messages, err := s.outboxRepo.ClaimDueOutboxMessages(ctx, time.Now(), s.config.Lease, s.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	// A result that could not be recorded doesn't hold the rest of the batch
+	// back, the message is due again once its lease ends
+	delivered := 0
+	var failures []error
+	for i := range messages {
+		ok, err := s.dispatch(ctx, &messages[i])
+		if err != nil {
+			failures = append(failures, fmt.Errorf("outbox message %d: %w", messages[i].ID, err))
+		}
+		if ok {
+			delivered++
+		}
+	}
+	return delivered, errors.Join(failures...)
+}
+
+// dispatch makes one delivery attempt and records its result. The returned
+// error is only set when the result could not be recorded.
+func (s *outboxService) dispatch(ctx context.Context, message *model.OutboxMessage) (bool, error) {
+	attempts := message.Attempts + 1
+
+	var deliveryErr error
+	if message.Channel == model.OutboxChannelWebhook {
+		// The webhook deliveries are written with the result, so the message
+		// is never fanned out twice
+		deliveryErr = s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+			if err := s.fanOut(ctx, message); err != nil {
+				return err
+			}
+			return s.outboxRepo.MarkOutboxMessageDelivered(ctx, message.ID, attempts, time.Now())
+		})
+		if deliveryErr == nil {
+			return true, nil
+		}
+	} else {
+		deliveryErr = s.deliver(ctx, message)
+		if deliveryErr == nil {
+			return true, s.outboxRepo.MarkOutboxMessageDelivered(ctx, message.ID, attempts, time.Now())
+		}
+	}
+
+	if attempts >= s.config.MaxAttempts {
+		return false, s.outboxRepo.MarkOutboxMessageDead(ctx, message.ID, attempts, deliveryErr.Error())
+	}
+
+	nextAttemptAt := time.Now().Add(s.backoff(attempts))
+	return false, s.outboxRepo.MarkOutboxMessageRetry(ctx, message.ID, attempts, nextAttemptAt, deliveryErr.Error())
+}
+
+func (s *outboxService) deliver(ctx context.Context, message *model.OutboxMessage) error {
+	switch message.Channel {
+	case model.OutboxChannelHTTP:
+		return s.deliverHTTP(ctx, message)
+	case model.OutboxChannelKafka:
+		if s.producer == nil {
+			return errors.New("kafka producer is not available")
+		}
+		return s.producer.Produce(ctx, message.Destination, message.MessageKey, message.Payload)
+	default:
+		return fmt.Errorf("unsupported outbox channel: %s", message.Channel)
+	}
+}
+
+func (s *outboxService) fanOut(ctx context.Context, message *model.OutboxMessage) error {
+	if s.webhooks == nil {
+		return errors.New("webhook service is not available")
+	}
+	return s.webhooks.FanOut(ctx, message)
+}
+
+func (s *outboxService) deliverHTTP(ctx context.Context, message *model.OutboxMessage) error {
+	method := message.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, message.Destination, strings.NewReader(message.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// backoff doubles the base delay for every failed attempt, capped at MaxBackoff.
+func (s *outboxService) backoff(attempts int) time.Duration {
+	return exponentialBackoff(s.config.BaseBackoff, s.config.MaxBackoff, attempts)
+}
+
+func exponentialBackoff(base, maxDelay time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxDelay {
+			return maxDelay
+		}
+	}
+	return min(delay, maxDelay)
+}
+
+// defaultLease is long enough for every message of a batch to time out before
+// the batch is claimed again
+func defaultLease(batchSize int, timeout time.Duration) time.Duration {
+	return time.Duration(batchSize+1) * timeout
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"top-up-api/internal/mapper"
+	"top-up-api/internal/model"
+	"top-up-api/internal/repository"
+	"top-up-api/internal/schema"
+	"top-up-api/pkg/errs"
+)
+
+type PromotionService interface {
+	CreatePromotion(ctx context.Context, request schema.PromotionRequest) (*schema.PromotionResponse, error)
+	GetPromotions(ctx context.Context) ([]*schema.PromotionResponse, error)
+	UpdatePromotion(ctx context.Context, id uint, request schema.PromotionRequest) (*schema.PromotionResponse, error)
+	DeletePromotion(ctx context.Context, id uint) error
+	// Evaluate picks the promotions an order of the sku gets. An empty voucher
+	// code applies the promotions that need none.
+	Evaluate(ctx context.Context, userID uint, sku *model.Sku, voucherCode string) (*schema.PromotionEvaluation, error)
+	// Redeem and Release are called by the order service inside the transaction
+	// that creates or fails the order.
+	Redeem(ctx context.Context, userID, orderID uint, promotions []schema.AppliedPromotion) error
+	Release(ctx context.Context, orderID uint) error
+}
+
+type promotionService struct {
+	repo repository.PromotionRepository
+}
+
+var _ PromotionService = (*promotionService)(nil)
+
+func NewPromotionService(repo repository.PromotionRepository) *promotionService {
+	return &promotionService{repo: repo}
+}
+
+func (s *promotionService) CreatePromotion(ctx context.Context, request schema.PromotionRequest) (*schema.PromotionResponse, error) {
+	if err := validatePromotion(request); err != nil {
+		return nil, err
+	}
+
+	promotion := mapper.PromotionFromRequest(request)
+	if err := s.repo.CreatePromotion(ctx, promotion); err != nil {
+		return nil, catalogError(err, "promotion")
+	}
+	return mapper.PromotionResponseFromModel(promotion), nil
+}
+
+func (s *promotionService) GetPromotions(ctx context.Context) ([]*schema.PromotionResponse, error) {
+	promotions, err := s.repo.GetPromotions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*schema.PromotionResponse, len(promotions))
+	for i := range promotions {
+		responses[i] = mapper.PromotionResponseFromModel(&promotions[i])
+	}
+	return responses, nil
+}
+
+func (s *promotionService) UpdatePromotion(ctx context.Context, id uint, request schema.PromotionRequest) (*schema.PromotionResponse, error) {
+	if err := validatePromotion(request); err != nil {
+		return nil, err
+	}
+	existing, err := s.repo.GetPromotionByID(ctx, id)
+	if err != nil {
+		return nil, catalogError(err, "promotion")
+	}
+
+	promotion := mapper.PromotionFromRequest(request)
+	promotion.Model = existing.Model
+	promotion.RedemptionCount = existing.RedemptionCount
+	if err := s.repo.UpdatePromotion(ctx, promotion); err != nil {
+		return nil, catalogError(err, "promotion")
+	}
+	return mapper.PromotionResponseFromModel(promotion), nil
+}
+
+func (s *promotionService) DeletePromotion(ctx context.Context, id uint) error {
+	return catalogError(s.repo.DeletePromotion(ctx, id), "promotion")
+}
+
+// Evaluate gives the order the best cashback the stacking rules allow: either
+// the cash back rule of the sku with every stackable promotion, or a single
+// exclusive promotion. A voucher code has to name a promotion the order can
+// get, and that promotion is always applied. The cashback never exceeds the
+// sku price.
+func (s *promotionService) Evaluate(ctx context.Context, userID uint, sku *model.Sku, voucherCode string) (*schema.PromotionEvaluation, error) {
+	voucherCode = mapper.NormalizePromotionCode(voucherCode)
+
+	available, err := s.repo.GetAvailablePromotions(ctx, time.Now(), voucherCode)
+	if err != nil {
+		return nil, err
+	}
+	eligible, err := s.eligiblePromotions(ctx, userID, sku, available)
+	if err != nil {
+		return nil, err
+	}
+
+	var voucher *model.Promotion
+	if voucherCode != "" {
+		for i := range eligible {
+			if eligible[i].Code == voucherCode {
+				voucher = &eligible[i]
+			}
+		}
+		if voucher == nil {
+			return nil, &errs.BadRequestError{Message: fmt.Sprintf("voucher code %s is not valid for this order", voucherCode)}
+		}
+	}
+
+	best := &schema.PromotionEvaluation{
+		CashBackValue: mapper.CashBackFromModel(sku.CashBack).CalculateCashBack(sku.Price),
+	}
+	if voucher == nil || voucher.Stackable {
+		for i := range eligible {
+			if eligible[i].Stackable {
+				cashBack := eligible[i].CashBackFor(sku.Price)
+				best.CashBackValue += cashBack
+				best.Promotions = append(best.Promotions, mapper.AppliedPromotionFromModel(&eligible[i], cashBack))
+			}
+		}
+	}
+	for i := range eligible {
+		promotion := &eligible[i]
+		if promotion.Stackable || (voucher != nil && promotion != voucher) {
+			continue
+		}
+		cashBack := promotion.CashBackFor(sku.Price)
+		if promotion == voucher || cashBack > best.CashBackValue {
+			best = &schema.PromotionEvaluation{
+				CashBackValue: cashBack,
+				Promotions:    []schema.AppliedPromotion{mapper.AppliedPromotionFromModel(promotion, cashBack)},
+			}
+		}
+	}
+
+	best.CashBackValue = min(best.CashBackValue, sku.Price)
+	return best, nil
+}
+
+// eligiblePromotions keeps the promotions that target the sku and that the
+// user hasn't used up.
+func (s *promotionService) eligiblePromotions(ctx context.Context, userID uint, sku *model.Sku, promotions []model.Promotion) ([]model.Promotion, error) {
+	var eligible []model.Promotion
+	var limited []uint
+	for _, promotion := range promotions {
+		if !promotion.Targets(sku) {
+			continue
+		}
+		eligible = append(eligible, promotion)
+		if promotion.PerUserLimit > 0 {
+			limited = append(limited, promotion.ID)
+		}
+	}
+	if len(limited) == 0 {
+		return eligible, nil
+	}
+
+	redeemed, err := s.repo.CountUserRedemptions(ctx, userID, limited)
+	if err != nil {
+		return nil, err
+	}
+	withinLimits := eligible[:0]
+	for _, promotion := range eligible {
+		if promotion.PerUserLimit == 0 || redeemed[promotion.ID] < promotion.PerUserLimit {
+			withinLimits = append(withinLimits, promotion)
+		}
+	}
+	return withinLimits, nil
+}
+
+// Redeem records the promotions applied to an order. A promotion that ran out
+// since the order was evaluated fails the order, it is evaluated again on retry.
+func (s *promotionService) Redeem(ctx context.Context, userID, orderID uint, promotions []schema.AppliedPromotion) error {
+	// Redeemed by ID like ReleasePromotionRedemptions releases them, so orders
+	// that share promotions lock their rows in the same order.
+	promotions = slices.Clone(promotions)
+	sort.Slice(promotions, func(i, j int) bool {
+		return promotions[i].PromotionID < promotions[j].PromotionID
+	})
+	for _, applied := range promotions {
+		if err := s.repo.RedeemPromotion(ctx, mapper.PromotionRedemptionFromApplied(applied, orderID, userID)); err != nil {
+			if errors.Is(err, repository.ErrPromotionExhausted) || errors.Is(err, repository.ErrPromotionUserLimitReached) {
+				return &errs.ConflictError{Message: fmt.Sprintf("promotion %s is no longer available", applied.Code)}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Release gives the redemptions of a failed order back to their promotions.
+func (s *promotionService) Release(ctx context.Context, orderID uint) error {
+	return s.repo.ReleasePromotionRedemptions(ctx, orderID, time.Now())
+}
+
+// validatePromotion rejects a percentage above 100 and a promotion that ends before it starts
+func validatePromotion(request schema.PromotionRequest) error {
+	if request.Type == model.CashBackTypePercentage && request.Value > 100 {
+		return &errs.BadRequestError{Message: "percentage cash back must not exceed 100"}
+	}
+	if request.EndsAt != nil && !request.EndsAt.After(request.StartsAt) {
+		return &errs.BadRequestError{Message: "promotion must end after it starts"}
+	}
+	return nil
+}
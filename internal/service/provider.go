@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"top-up-api/internal/mapper"
+	"top-up-api/internal/model"
+	"top-up-api/internal/repository"
+	"top-up-api/internal/schema"
+	"top-up-api/pkg/errs"
+)
+
+const _providerCallbackSecretPrefix = "pcsec_"
+
+type ProviderService interface {
+	// CreateProvider adds a provider with a new callback secret.
+	CreateProvider(ctx context.Context, request schema.ProviderRequest) (*schema.ProviderSecretResponse, error)
+	UpdateProvider(ctx context.Context, id uint, request schema.ProviderRequest) (*schema.ProviderAdminResponse, error)
+	DeleteProvider(ctx context.Context, id uint) error
+	// RotateCallbackSecret issues a new callback secret, callbacks signed with
+	// the previous one are rejected from then on.
+	RotateCallbackSecret(ctx context.Context, id uint) (*schema.ProviderSecretResponse, error)
+}
+
+type providerService struct {
+	repo         repository.ProviderRepository
+	supplierRepo repository.SupplierRepository
+	txManager    repository.TransactionManager
+}
+
+var _ ProviderService = (*providerService)(nil)
+
+func NewProviderService(repo repository.ProviderRepository, supplierRepo repository.SupplierRepository, txManager repository.TransactionManager) *providerService {
+	return &providerService{repo: repo, supplierRepo: supplierRepo, txManager: txManager}
+}
+
+func (s *providerService) CreateProvider(ctx context.Context, request schema.ProviderRequest) (*schema.ProviderSecretResponse, error) {
+	provider := mapper.ProviderFromRequest(request)
+	if err := s.setSuppliers(ctx, provider, request.Suppliers); err != nil {
+		return nil, err
+	}
+	secret, err := newSecret(_providerCallbackSecretPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	provider.CallbackSecret = secret
+	if err := s.saveProvider(ctx, provider, s.repo.CreateProvider); err != nil {
+		return nil, err
+	}
+	return mapper.ProviderSecretResponseFromModel(provider), nil
+}
+
+func (s *providerService) UpdateProvider(ctx context.Context, id uint, request schema.ProviderRequest) (*schema.ProviderAdminResponse, error) {
+	existing, err := s.repo.GetProviderByID(ctx, id)
+	if err != nil {
+		return nil, catalogError(err, "provider")
+	}
+	provider := mapper.ProviderFromRequest(request)
+	if err := s.setSuppliers(ctx, provider, request.Suppliers); err != nil {
+		return nil, err
+	}
+
+	provider.Model = existing.Model
+	provider.CallbackSecret = existing.CallbackSecret
+	if err := s.saveProvider(ctx, provider, s.repo.UpdateProvider); err != nil {
+		return nil, err
+	}
+	return mapper.ProviderAdminResponseFromModel(provider), nil
+}
+
+func (s *providerService) DeleteProvider(ctx context.Context, id uint) error {
+	return catalogError(s.repo.DeleteProvider(ctx, id), "provider")
+}
+
+func (s *providerService) RotateCallbackSecret(ctx context.Context, id uint) (*schema.ProviderSecretResponse, error) {
+	provider, err := s.repo.GetProviderByID(ctx, id)
+	if err != nil {
+		return nil, catalogError(err, "provider")
+	}
+	secret, err := newSecret(_providerCallbackSecretPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateProviderCallbackSecret(ctx, id, secret); err != nil {
+		return nil, catalogError(err, "provider")
+	}
+	provider.CallbackSecret = secret
+	return mapper.ProviderSecretResponseFromModel(provider), nil
+}
+
+// saveProvider writes the provider and its provider_suppliers rows in one transaction
+func (s *providerService) saveProvider(ctx context.Context, provider *model.Provider, write func(context.Context, *model.Provider) error) error {
+	err := s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := write(ctx, provider); err != nil {
+			return err
+		}
+		for i := range provider.ProviderSuppliers {
+			provider.ProviderSuppliers[i].ProviderID = provider.ID
+		}
+		return s.repo.ReplaceProviderSuppliers(ctx, provider.ID, provider.ProviderSuppliers)
+	})
+	return catalogError(err, "provider")
+}
+
+// setSuppliers loads the suppliers a provider serves with their weights and
+// rejects unknown or repeated codes
+func (s *providerService) setSuppliers(ctx context.Context, provider *model.Provider, requests []schema.ProviderSupplierRequest) error {
+	provider.Suppliers = []model.Supplier{}
+	provider.ProviderSuppliers = []model.ProviderSupplier{}
+	if len(requests) == 0 {
+		return nil
+	}
+
+	weights := make(map[string]int, len(requests))
+	for _, request := range requests {
+		if _, ok := weights[request.SupplierCode]; ok {
+			return &errs.BadRequestError{Message: "duplicate supplier code: " + request.SupplierCode}
+		}
+		weights[request.SupplierCode] = request.Weight
+	}
+	codes := slices.Sorted(maps.Keys(weights))
+
+	suppliers, err := s.supplierRepo.GetSuppliersByCodes(ctx, codes)
+	if err != nil {
+		return err
+	}
+
+	var unknown []string
+	for _, code := range codes {
+		if !slices.ContainsFunc(suppliers, func(supplier model.Supplier) bool { return supplier.Code == code }) {
+			unknown = append(unknown, code)
+		}
+	}
+	if len(unknown) > 0 {
+		return &errs.BadRequestError{Message: "unknown supplier codes: " + strings.Join(unknown, ", ")}
+	}
+
+	provider.Suppliers = suppliers
+	for _, supplier := range suppliers {
+		provider.ProviderSuppliers = append(provider.ProviderSuppliers, model.ProviderSupplier{
+			SupplierID: supplier.ID,
+			Weight:     weights[supplier.Code],
+		})
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"top-up-api/config"
+	"top-up-api/internal/mapper"
+	"top-up-api/internal/schema"
+	"top-up-api/pkg/circuitbreaker"
+)
+
+// circuitProviderClient guards a provider client with the provider's circuit
+// breaker. A provider serving several suppliers shares one breaker.
+type circuitProviderClient struct {
+	providerClient
+	breaker *circuitbreaker.Breaker
+}
+
+var _ providerClient = (*circuitProviderClient)(nil)
+
+// sendRequest counts every error but a rejection against the provider. A
+// request the provider rejected still shows that the provider is up.
+func (c *circuitProviderClient) sendRequest(ctx context.Context, order *schema.OrderResponse) error {
+	if !c.breaker.Allow() {
+		return circuitbreaker.ErrOpen
+	}
+
+	start := time.Now()
+	err := c.providerClient.sendRequest(ctx, order)
+	c.breaker.Record(err == nil || classifyProviderError(err) == providerRejected, time.Since(start))
+	return err
+}
+
+// GetProviderHealth returns the circuit breaker state of every provider.
+func (s *orderService) GetProviderHealth(ctx context.Context) []schema.ProviderHealthResponse {
+	breakers := s.routing.Load().breakers
+	codes := make([]string, 0, len(breakers))
+	for code := range breakers {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	health := make([]schema.ProviderHealthResponse, 0, len(codes))
+	for _, code := range codes {
+		health = append(health, mapper.ProviderHealthResponseFromSnapshot(code, breakers[code].Snapshot()))
+	}
+	return health
+}
+
+func newProviderBreaker(cfg config.CircuitBreaker) *circuitbreaker.Breaker {
+	return circuitbreaker.New(circuitbreaker.Config{
+		WindowSize:           cfg.WindowSize,
+		MinRequests:          cfg.MinRequests,
+		FailureRateThreshold: cfg.FailureRateThreshold,
+		SlowCallThreshold:    cfg.SlowCallThreshold,
+		OpenTimeout:          cfg.OpenTimeout,
+		HalfOpenProbes:       cfg.HalfOpenProbes,
+	})
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"top-up-api/config"
+	"top-up-api/internal/repository"
+	"top-up-api/internal/schema"
+	"top-up-api/pkg/errs"
+	"top-up-api/pkg/redis"
+	"top-up-api/pkg/signature"
+
+	"gorm.io/gorm"
+)
+
+const (
+	_defaultCallbackSignatureTolerance = 5 * time.Minute
+	_maxCallbackNonceLength            = 128
+	_callbackNonceKeyPrefix            = "provider_callback_nonce:"
+)
+
+type ProviderCallbackService interface {
+	// Authenticate checks that a status callback was signed by the provider it
+	// claims to come from and that its nonce wasn't seen before. The payload is
+	// the callback exactly as it was signed.
+	Authenticate(ctx context.Context, credentials schema.ProviderCallbackCredentials, payload []byte) error
+}
+
+type providerCallbackService struct {
+	providerRepo repository.ProviderRepository
+	redisClient  redis.Interface
+	config       config.ProviderCallback
+}
+
+var _ ProviderCallbackService = (*providerCallbackService)(nil)
+
+func NewProviderCallbackService(providerRepo repository.ProviderRepository, redisClient redis.Interface, cfg config.ProviderCallback) *providerCallbackService {
+	if cfg.SignatureTolerance <= 0 {
+		cfg.SignatureTolerance = _defaultCallbackSignatureTolerance
+	}
+	return &providerCallbackService{providerRepo: providerRepo, redisClient: redisClient, config: cfg}
+}
+
+func (s *providerCallbackService) Authenticate(ctx context.Context, credentials schema.ProviderCallbackCredentials, payload []byte) error {
+	if credentials.ProviderCode == "" || credentials.Nonce == "" || credentials.Signature == "" {
+		return &errs.UnauthorizedError{Message: "provider callback is not signed"}
+	}
+	if len(credentials.Nonce) > _maxCallbackNonceLength {
+		return &errs.UnauthorizedError{Message: "provider callback nonce is too long"}
+	}
+
+	provider, err := s.providerRepo.GetProviderByCode(ctx, credentials.ProviderCode)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &errs.UnauthorizedError{Message: "unknown provider " + credentials.ProviderCode}
+		}
+		return err
+	}
+	if provider.CallbackSecret == "" {
+		return &errs.UnauthorizedError{Message: "provider " + provider.Code + " has no callback secret"}
+	}
+
+	signed := callbackSignedPayload(credentials.Nonce, payload)
+	if _, err := signature.Verify(provider.CallbackSecret, credentials.Signature, signed, s.config.SignatureTolerance, time.Now()); err != nil {
+		return &errs.UnauthorizedError{Message: "invalid provider callback signature: " + err.Error()}
+	}
+
+	// A nonce is remembered for as long as its timestamp is accepted on either
+	// side of now, so the same callback can't be replayed within that window.
+	key := _callbackNonceKeyPrefix + provider.Code + ":" + credentials.Nonce
+	fresh, err := s.redisClient.SetNX(ctx, key, 1, 2*s.config.SignatureTolerance)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return &errs.UnauthorizedError{Message: "provider callback was already received"}
+	}
+	return nil
+}
+
+// callbackSignedPayload is what a provider signs: the nonce and the body joined
+// by a dot, so the signature covers "<timestamp>.<nonce>.<body>".
+func callbackSignedPayload(nonce string, payload []byte) []byte {
+	signed := make([]byte, 0, len(nonce)+1+len(payload))
+	signed = append(signed, nonce...)
+	signed = append(signed, '.')
+	return append(signed, payload...)
+}
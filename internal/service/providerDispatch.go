@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"top-up-api/internal/model"
+	"top-up-api/internal/schema"
+	"top-up-api/pkg/circuitbreaker"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	_defaultDispatchMaxAttempts    = 3
+	_defaultDispatchRetryBackoff   = 500 * time.Millisecond
+	_defaultDispatchRequestTimeout = 10 * time.Second
+)
+
+var (
+	errNoProvider            = errors.New("can't find suitable provider")
+	errProviderNotConnected  = errors.New("no gRPC connection for provider")
+	errProviderOutcomeUnsure = errors.New("provider may have taken the order")
+	errDispatchDeferred      = errors.New("order is left confirmed to be dispatched again")
+	errAllProvidersFailed    = errors.New("all providers failed")
+)
+
+// providerErrorKind tells from a failed provider request whether the provider
+// may have taken the order.
+type providerErrorKind int
+
+const (
+	// providerRejected means the provider refused the order, the next
+	// provider gets it straight away.
+	providerRejected providerErrorKind = iota
+	// providerUnavailable means the order never reached the provider or was
+	// turned away before it was processed, the request is retried.
+	providerUnavailable
+	// providerUnsure means the provider may have taken the order, so no other
+	// provider may get it.
+	providerUnsure
+)
+
+// providerStatusError is returned when an HTTP provider answers with a non-2xx status.
+type providerStatusError struct {
+	StatusCode int
+}
+
+func (e *providerStatusError) Error() string {
+	return fmt.Sprintf("provider responded with status %d", e.StatusCode)
+}
+
+// DispatchOrder sends a confirmed order to its supplier's providers, retrying
+// transient errors and failing over until one of them accepts it.
+func (s *orderService) DispatchOrder(ctx context.Context, orderID uint) error {
+	orderResponse, err := s.getCachedOrder(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	if orderResponse.Status != model.PurchaseHistoryStatusConfirm {
+		return fmt.Errorf("order %d is %s, only confirmed orders can be dispatched", orderID, orderResponse.Status)
+	}
+	return s.dispatchOrder(ctx, orderResponse)
+}
+
+// WaitForDispatches blocks until every dispatch started by ConfirmOrder has finished.
+func (s *orderService) WaitForDispatches() {
+	s.dispatches.Wait()
+}
+
+// startDispatch dispatches the order in the background. The dispatch outlives
+// the request that confirmed the order, so it drops the request's cancellation.
+func (s *orderService) startDispatch(ctx context.Context, orderResponse *schema.OrderResponse) {
+	ctx = context.WithoutCancel(ctx)
+	s.dispatches.Add(1)
+	go func() {
+		defer s.dispatches.Done()
+		s.dispatchOrder(ctx, orderResponse)
+	}()
+}
+
+// dispatchOrder tries the providers in the order chosen by the supplier's
+// routing strategy, assigning the order to each in turn. A provider only fails
+// over to the next one when it surely didn't take the order, one that may have
+// taken it (a timeout, a dropped connection or a server error) keeps the order
+// confirmed for the reconciler to query. The order is only marked failed once
+// providers were tried and all of them failed. Without a provider to try, or
+// when the order can't be assigned, it stays confirmed for the reconciler to
+// dispatch again.
+func (s *orderService) dispatchOrder(ctx context.Context, orderResponse *schema.OrderResponse) error {
+	candidates := s.providerCandidates(orderResponse)
+	if len(candidates) == 0 {
+		return fmt.Errorf("%w: order %d: %w", errDispatchDeferred, orderResponse.OrderID, errNoProvider)
+	}
+
+	var lastErr error
+	attempted := false
+	for _, client := range candidates {
+		// The provider is assigned before it gets the order, its callback may
+		// arrive before the request returns.
+		if err := s.assignOrderProvider(ctx, orderResponse.OrderID, client.getProviderCode()); err != nil {
+			return fmt.Errorf("%w: order %d: %w", errDispatchDeferred, orderResponse.OrderID, err)
+		}
+		err := s.dispatchToProvider(ctx, orderResponse, client)
+		if err == nil {
+			return nil
+		}
+		if classifyProviderError(err) == providerUnsure {
+			return fmt.Errorf("%w: order %d is left to reconciliation with %s: %w", errProviderOutcomeUnsure, orderResponse.OrderID, client.getProviderCode(), err)
+		}
+		// A breaker that opened since the candidates were picked turned the
+		// order away without sending it
+		attempted = attempted || !errors.Is(err, circuitbreaker.ErrOpen)
+		lastErr = err
+	}
+	if !attempted {
+		return fmt.Errorf("%w: order %d: %w", errDispatchDeferred, orderResponse.OrderID, lastErr)
+	}
+
+	if err := s.failDispatchedOrder(ctx, orderResponse.OrderID); err != nil {
+		return errors.Join(lastErr, err)
+	}
+	return fmt.Errorf("%w: %w", errAllProvidersFailed, lastErr)
+}
+
+// assignOrderProvider records the provider on the order, where its callback is
+// checked against, and on the purchase history the settlement reports are
+// computed from.
+func (s *orderService) assignOrderProvider(ctx context.Context, orderID uint, providerCode string) error {
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.orderRepo.AssignOrderProvider(ctx, orderID, providerCode); err != nil {
+			return err
+		}
+		return s.purchaseHistoryRepo.UpdatePurchaseHistoryProviderByOrderID(ctx, orderID, providerCode)
+	})
+}
+
+// dispatchToProvider sends the order to one provider, retrying the requests
+// that didn't reach it with exponential backoff up to the configured number of
+// attempts.
+func (s *orderService) dispatchToProvider(ctx context.Context, orderResponse *schema.OrderResponse, client providerClient) error {
+	var err error
+	backoff := s.dispatchConfig.RetryBackoff
+	for attempt := 1; attempt <= s.dispatchConfig.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		start := time.Now()
+		requestCtx, cancel := context.WithTimeout(ctx, s.dispatchConfig.RequestTimeout)
+		err = client.sendRequest(requestCtx, orderResponse)
+		cancel()
+		s.recordProviderAttempt(ctx, orderResponse.OrderID, client.getProviderCode(), attempt, time.Since(start), err)
+
+		if err == nil || classifyProviderError(err) != providerUnavailable {
+			return err
+		}
+	}
+	return err
+}
+
+// providerCandidates orders the supplier's available providers with the
+// supplier's routing strategy. Providers whose circuit breaker is open are left
+// out before the strategy runs.
+func (s *orderService) providerCandidates(orderResponse *schema.OrderResponse) []providerClient {
+	supplierCode := orderResponse.Sku.SupplierInfo.Code
+	route := s.routing.Load().suppliers[supplierCode]
+
+	var available []*routedProvider
+	for _, provider := range route.providers {
+		if provider.breaker.Ready() {
+			available = append(available, provider)
+		}
+	}
+	if len(available) == 0 {
+		return nil
+	}
+
+	ranked := s.strategies[route.strategy].rank(supplierCode, orderResponse, available)
+	candidates := make([]providerClient, 0, len(ranked))
+	for _, provider := range ranked {
+		candidates = append(candidates, provider.circuitProviderClient)
+	}
+	return candidates
+}
+
+// recordProviderAttempt stores the outcome of a provider request. A failed write
+// must not stop the dispatch, so its error is dropped.
+func (s *orderService) recordProviderAttempt(ctx context.Context, orderID uint, providerCode string, attempt int, duration time.Duration, err error) {
+	providerAttempt := &model.ProviderAttempt{
+		OrderID:      orderID,
+		ProviderCode: providerCode,
+		Attempt:      attempt,
+		Success:      err == nil,
+		DurationMs:   duration.Milliseconds(),
+		CreatedAt:    time.Now(),
+	}
+	if err != nil {
+		providerAttempt.Error = err.Error()
+	}
+	s.providerAttemptRepo.CreateProviderAttempt(ctx, providerAttempt)
+}
+
+// failDispatchedOrder marks an order failed once every provider has been tried
+// and notifies the payment service through the outbox.
+func (s *orderService) failDispatchedOrder(ctx context.Context, orderID uint) error {
+	ctx = WithOrderEventSource(ctx, model.OrderStatusEventSourceDispatcher)
+	lock, unlock, err := s.lockOrder(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	orderResponse, err := s.getStoredOrder(ctx, orderID)
+	if err != nil {
+		return err
+	}
+
+	// A provider callback may have settled the order while it was dispatched.
+	err = s.orderStates.TransitionFrom(model.PurchaseHistoryStatusConfirm, orderResponse.Status, model.PurchaseHistoryStatusFailed)
+	if err != nil {
+		return err
+	}
+
+	err = s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.purchaseHistoryRepo.UpdatePurchaseHistoryStatusByOrderID(ctx, orderID, model.PurchaseHistoryStatusFailed); err != nil {
+			return err
+		}
+		if err := s.changeOrderStatus(ctx, orderResponse, model.PurchaseHistoryStatusFailed, lock.Fence); err != nil {
+			return err
+		}
+		return s.enqueueFailedOrder(ctx, orderID)
+	})
+	if err != nil {
+		return err
+	}
+
+	previousStatus := orderResponse.Status
+	orderResponse.Status = model.PurchaseHistoryStatusFailed
+	s.updateCacheOrderStaus(ctx, getCachKey(_orderRequestKeyPrefix, strconv.Itoa(int(orderID))), orderResponse)
+	s.publishOrderStatus(ctx, orderID, previousStatus, model.PurchaseHistoryStatusFailed)
+	return nil
+}
+
+// classifyProviderError tells how far a failed request got. Only a refusal or
+// a request that surely wasn't processed lets the order go to another
+// provider, everything else may have topped the phone up already.
+func classifyProviderError(err error) providerErrorKind {
+	if errors.Is(err, circuitbreaker.ErrOpen) {
+		return providerRejected
+	}
+	if errors.Is(err, errProviderNotConnected) {
+		return providerUnavailable
+	}
+
+	var statusErr *providerStatusError
+	if errors.As(err, &statusErr) {
+		switch {
+		case statusErr.StatusCode == http.StatusTooManyRequests,
+			statusErr.StatusCode == http.StatusServiceUnavailable,
+			statusErr.StatusCode == http.StatusRequestTimeout:
+			return providerUnavailable
+		case statusErr.StatusCode == http.StatusConflict, statusErr.StatusCode >= http.StatusInternalServerError:
+			return providerUnsure
+		default:
+			return providerRejected
+		}
+	}
+
+	// The connection couldn't be opened, so nothing was sent
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	if (errors.As(err, &opErr) && opErr.Op == "dial") || errors.As(err, &dnsErr) {
+		return providerUnavailable
+	}
+
+	if st, ok := status.FromError(err); ok {
+		switch st.Code() {
+		case codes.InvalidArgument, codes.NotFound, codes.PermissionDenied, codes.FailedPrecondition,
+			codes.Unauthenticated, codes.Unimplemented, codes.OutOfRange:
+			return providerRejected
+		case codes.ResourceExhausted:
+			return providerUnavailable
+		}
+	}
+	return providerUnsure
+}
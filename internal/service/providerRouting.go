@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	pb "top-up-api/internal/grpc/client"
+	"top-up-api/internal/model"
+	"top-up-api/pkg/circuitbreaker"
+)
+
+// routingTable is an immutable snapshot of the providers serving every
+// supplier. A reload builds a new table and swaps it in atomically.
+type routingTable struct {
+	suppliers map[string]supplierRoute
+	breakers  map[string]*circuitbreaker.Breaker
+	providers map[string]providerClient
+}
+
+func newRoutingTable() *routingTable {
+	return &routingTable{
+		suppliers: make(map[string]supplierRoute),
+		breakers:  make(map[string]*circuitbreaker.Breaker),
+		providers: make(map[string]providerClient),
+	}
+}
+
+// ReloadProviders rebuilds the routing table from the provider table and opens
+// or closes gRPC connections to match. On failure the current table stays in use.
+func (s *orderService) ReloadProviders(ctx context.Context) error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	providers, err := s.providerRepo.GetProvidersWithSuppliers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+	for _, provider := range providers {
+		if provider.Type != "http" && provider.Type != "grpc" {
+			return fmt.Errorf("unsupported provider type %s for provider %s", provider.Type, provider.Code)
+		}
+		for _, supplier := range provider.Suppliers {
+			if _, ok := s.strategies[routingStrategyOf(supplier)]; !ok {
+				return fmt.Errorf("unsupported routing strategy %s for supplier %s", supplier.RoutingStrategy, supplier.Code)
+			}
+		}
+	}
+
+	if err := s.grpcClients.SyncProviderGRPCClients(providers); err != nil {
+		return err
+	}
+
+	s.routing.Store(s.buildRoutingTable(providers, s.routing.Load()))
+	return nil
+}
+
+// buildRoutingTable keeps the circuit breaker of every provider that is still
+// present so a reload does not reset its health.
+func (s *orderService) buildRoutingTable(providers []model.Provider, previous *routingTable) *routingTable {
+	table := newRoutingTable()
+
+	for _, provider := range providers {
+		breaker, ok := table.breakers[provider.Code]
+		if !ok {
+			breaker, ok = previous.breakers[provider.Code]
+		}
+		if !ok {
+			breaker = newProviderBreaker(s.dispatchConfig.CircuitBreaker)
+		}
+		table.breakers[provider.Code] = breaker
+
+		client := &circuitProviderClient{providerClient: createProviderClient(provider, s.grpcClients, s.paymentConfig.CallbackURL), breaker: breaker}
+		table.providers[provider.Code] = client
+		for _, supplier := range provider.Suppliers {
+			route := table.suppliers[supplier.Code]
+			route.strategy = routingStrategyOf(supplier)
+			route.providers = append(route.providers, &routedProvider{
+				circuitProviderClient: client,
+				weight:                provider.SupplierWeight(supplier.ID),
+				discount:              provider.Discount,
+			})
+			table.suppliers[supplier.Code] = route
+		}
+	}
+
+	return table
+}
+
+// createProviderClient expects provider types checked by ReloadProviders.
+func createProviderClient(provider model.Provider, grpcClients *pb.GRPCServiceClient, callbackURL string) providerClient {
+	if provider.Type == "grpc" {
+		return &grpcProviderClient{code: provider.Code, clients: grpcClients, callbacks: callbackURL}
+	}
+	return &httpProviderClient{code: provider.Code, url: provider.Source, callbacks: callbackURL}
+}
+
+// routingStrategyOf falls back to weighted random for suppliers without a strategy.
+func routingStrategyOf(supplier model.Supplier) model.RoutingStrategy {
+	if supplier.RoutingStrategy == "" {
+		return model.RoutingStrategyWeightedRandom
+	}
+	return supplier.RoutingStrategy
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"sort"
+	"sync"
+
+	"top-up-api/internal/model"
+	"top-up-api/internal/schema"
+)
+
+// _stickyPrefixLength is how many leading digits of the phone number pick the
+// provider under the sticky-by-phone-prefix strategy.
+const _stickyPrefixLength = 4
+
+// routedProvider is a provider client together with the routing attributes of
+// the provider.
+type routedProvider struct {
+	*circuitProviderClient
+	weight   int
+	discount float64
+}
+
+// supplierRoute holds the providers serving one supplier and the strategy that
+// chooses between them.
+type supplierRoute struct {
+	strategy  model.RoutingStrategy
+	providers []*routedProvider
+}
+
+// routingStrategy orders the available providers of a supplier for one order.
+// The first provider gets the order, the rest are tried in turn on failure.
+type routingStrategy interface {
+	rank(supplierCode string, orderResponse *schema.OrderResponse, providers []*routedProvider) []*routedProvider
+}
+
+func newRoutingStrategies() map[model.RoutingStrategy]routingStrategy {
+	return map[model.RoutingStrategy]routingStrategy{
+		model.RoutingStrategyWeightedRandom:    weightedRandomStrategy{},
+		model.RoutingStrategyLeastCost:         leastCostStrategy{},
+		model.RoutingStrategyLowestLatency:     lowestLatencyStrategy{},
+		model.RoutingStrategyRoundRobin:        &roundRobinStrategy{next: make(map[string]int)},
+		model.RoutingStrategyStickyPhonePrefix: stickyPhonePrefixStrategy{},
+	}
+}
+
+// weightedRandomStrategy starts from a provider picked at random in proportion
+// to its weight.
+type weightedRandomStrategy struct{}
+
+func (weightedRandomStrategy) rank(_ string, _ *schema.OrderResponse, providers []*routedProvider) []*routedProvider {
+	return rotateProviders(providers, weightedIndex(providers, rand.IntN))
+}
+
+// leastCostStrategy starts from the provider with the largest discount.
+type leastCostStrategy struct{}
+
+func (leastCostStrategy) rank(_ string, _ *schema.OrderResponse, providers []*routedProvider) []*routedProvider {
+	ranked := append([]*routedProvider(nil), providers...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].discount > ranked[j].discount
+	})
+	return ranked
+}
+
+// lowestLatencyStrategy starts from the provider with the lowest average latency
+// in its circuit breaker window. A provider without calls in the window counts
+// as the fastest, so it gets measured.
+type lowestLatencyStrategy struct{}
+
+func (lowestLatencyStrategy) rank(_ string, _ *schema.OrderResponse, providers []*routedProvider) []*routedProvider {
+	ranked := append([]*routedProvider(nil), providers...)
+	latencies := make(map[*routedProvider]int64, len(ranked))
+	for _, provider := range ranked {
+		latencies[provider] = int64(provider.breaker.Snapshot().AvgLatency)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return latencies[ranked[i]] < latencies[ranked[j]]
+	})
+	return ranked
+}
+
+// roundRobinStrategy starts each order of a supplier from the next provider.
+type roundRobinStrategy struct {
+	mu   sync.Mutex
+	next map[string]int
+}
+
+func (r *roundRobinStrategy) rank(supplierCode string, _ *schema.OrderResponse, providers []*routedProvider) []*routedProvider {
+	r.mu.Lock()
+	start := r.next[supplierCode] % len(providers)
+	r.next[supplierCode] = start + 1
+	r.mu.Unlock()
+
+	return rotateProviders(providers, start)
+}
+
+// stickyPhonePrefixStrategy sends every phone number sharing a prefix to the
+// same provider, spreading the prefixes in proportion to provider weight.
+type stickyPhonePrefixStrategy struct{}
+
+func (stickyPhonePrefixStrategy) rank(_ string, orderResponse *schema.OrderResponse, providers []*routedProvider) []*routedProvider {
+	prefix := orderResponse.PhoneNumber
+	if len(prefix) > _stickyPrefixLength {
+		prefix = prefix[:_stickyPrefixLength]
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(prefix))
+
+	return rotateProviders(providers, weightedIndex(providers, func(total int) int {
+		return int(hash.Sum32() % uint32(total))
+	}))
+}
+
+// weightedIndex maps pick(totalWeight) onto the cumulative provider weights.
+// Without any weight the first provider is used.
+func weightedIndex(providers []*routedProvider, pick func(total int) int) int {
+	total := 0
+	for _, provider := range providers {
+		total += provider.weight
+	}
+	if total <= 0 {
+		return 0
+	}
+
+	target, cumulative := pick(total), 0
+	for i, provider := range providers {
+		cumulative += provider.weight
+		if target < cumulative {
+			return i
+		}
+	}
+	return 0
+}
+
+// rotateProviders returns the providers starting at start and wrapping around.
+func rotateProviders(providers []*routedProvider, start int) []*routedProvider {
+	rotated := make([]*routedProvider, 0, len(providers))
+	rotated = append(rotated, providers[start:]...)
+	return append(rotated, providers[:start]...)
+}
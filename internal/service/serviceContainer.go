@@ -1,13 +1,18 @@
 package service
 
 import (
+	"context"
+	"errors"
+
 	"top-up-api/config"
 	grpcClient "top-up-api/internal/grpc/client"
 	"top-up-api/internal/repository"
+	kfk "top-up-api/pkg/kafka"
 	"top-up-api/pkg/logger"
 	"top-up-api/pkg/redis"
 	"top-up-api/pkg/validator"
 
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
@@ -21,10 +26,18 @@ type Container struct {
 	Validator validator.Interface
 
 	// Services
-	SupplierService        SupplierService
-	SkuService             SkuService
-	PurchaseHistoryService PurchaseHistoryService
-	OrderService           OrderService
+	SupplierService         SupplierService
+	SkuService              SkuService
+	PurchaseHistoryService  PurchaseHistoryService
+	OrderService            OrderService
+	OutboxService           OutboxService
+	CashBackService         CashBackService
+	ProviderService         ProviderService
+	ProviderCallbackService ProviderCallbackService
+	WebhookService          WebhookService
+	WalletService           WalletService
+	PromotionService        PromotionService
+	SettlementService       SettlementService
 }
 
 // NewContainer creates and initializes all dependencies
@@ -34,20 +47,46 @@ func NewContainer(
 	redis redis.Interface,
 	validator validator.Interface,
 	config *config.Config,
-	grpcClients grpcClient.GRPCServiceClient,
+	grpcClients *grpcClient.GRPCServiceClient,
+	producer kfk.Producer,
 ) *Container {
 
 	// Initialize repositories
 	supplierRepository := repository.NewSupplierRepository(database)
 	skuRepository := repository.NewSkuRepository(database)
+	cashBackRepository := repository.NewCashBackRepository(database)
 	purchaseHistoryRepository := repository.NewPurchaseHistoryRepository(database)
 	providerRepository := repository.NewProviderRepository(database)
+	orderRepository := repository.NewOrderRepository(database)
+	orderStatusEventRepository := repository.NewOrderStatusEventRepository(database)
+	outboxRepository := repository.NewOutboxRepository(database)
+	providerAttemptRepository := repository.NewProviderAttemptRepository(database)
+	webhookRepository := repository.NewWebhookRepository(database)
+	ledgerRepository := repository.NewLedgerRepository(database)
+	promotionRepository := repository.NewPromotionRepository(database)
+	orderReviewRepository := repository.NewOrderReviewRepository(database)
+	orderBatchRepository := repository.NewOrderBatchRepository(database)
+	settlementRepository := repository.NewSettlementRepository(database)
+	transactionManager := repository.NewTransactionManager(database)
 
 	// Initialize services
 	supplierService := NewSupplierService(supplierRepository)
 	skuService := NewSkuService(skuRepository)
 	purchaseHistoryService := NewPurchaseHistoryService(purchaseHistoryRepository)
-	orderService := NewOrderService(skuRepository, purchaseHistoryRepository, redis, grpcClients, providerRepository)
+	walletService := NewWalletService(ledgerRepository)
+	promotionService := NewPromotionService(promotionRepository)
+	orderService := NewOrderService(skuRepository, purchaseHistoryRepository, orderRepository, orderStatusEventRepository, outboxRepository, providerAttemptRepository, transactionManager, redis, grpcClients, providerRepository, orderReviewRepository, orderBatchRepository, walletService, promotionService, config.ProviderDispatch, config.Payment)
+	// Orders confirmed before the routing table is loaded stay confirmed, the
+	// reloader keeps trying and the reconciler dispatches them afterwards
+	if err := orderService.ReloadProviders(context.Background()); err != nil {
+		logger.Error(errors.New("failed to load the provider routing table"), zap.Error(err))
+	}
+	webhookService := NewWebhookService(webhookRepository, config.Webhook)
+	outboxService := NewOutboxService(outboxRepository, transactionManager, producer, webhookService, config.Outbox)
+	cashBackService := NewCashBackService(cashBackRepository)
+	providerService := NewProviderService(providerRepository, supplierRepository, transactionManager)
+	providerCallbackService := NewProviderCallbackService(providerRepository, redis, config.ProviderCallback)
+	settlementService := NewSettlementService(settlementRepository, providerRepository, config.Settlement)
 
 	return &Container{
 		// Core dependencies
@@ -57,9 +96,17 @@ func NewContainer(
 		Validator: validator,
 
 		// Services
-		SupplierService:        supplierService,
-		SkuService:             skuService,
-		PurchaseHistoryService: purchaseHistoryService,
-		OrderService:           orderService,
+		SupplierService:         supplierService,
+		SkuService:              skuService,
+		PurchaseHistoryService:  purchaseHistoryService,
+		OrderService:            orderService,
+		OutboxService:           outboxService,
+		CashBackService:         cashBackService,
+		ProviderService:         providerService,
+		ProviderCallbackService: providerCallbackService,
+		WebhookService:          webhookService,
+		WalletService:           walletService,
+		PromotionService:        promotionService,
+		SettlementService:       settlementService,
 	}
 }
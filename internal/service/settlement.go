@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"time"
+
+	"top-up-api/config"
+	"top-up-api/internal/mapper"
+	"top-up-api/internal/model"
+	"top-up-api/internal/repository"
+	"top-up-api/internal/schema"
+	"top-up-api/pkg/errs"
+)
+
+const (
+	_settlementDayLayout = "2006-01-02"
+	// _maxSettlementDays bounds the period of a report, a year is enough for
+	// any invoice finance reconciles
+	_maxSettlementDays = 366
+)
+
+type SettlementService interface {
+	GetSettlementReport(ctx context.Context, request schema.SettlementReportRequest) ([]*schema.SettlementReportRow, error)
+	// ExportSettlementReport writes the report as CSV, with a header line.
+	ExportSettlementReport(ctx context.Context, w io.Writer, request schema.SettlementReportRequest) error
+	// ImportProviderSettlement diffs the settlement file of a provider against
+	// the purchase history and stores the discrepancies found.
+	ImportProviderSettlement(ctx context.Context, request schema.SettlementImportRequest, file io.Reader) (*schema.SettlementImportResponse, error)
+	GetSettlementImports(ctx context.Context) ([]*schema.SettlementImportResponse, error)
+	GetSettlementDiscrepancies(ctx context.Context, importID uint, status model.SettlementDiscrepancyStatus) ([]*schema.SettlementDiscrepancyResponse, error)
+	ResolveSettlementDiscrepancy(ctx context.Context, id uint, request schema.SettlementDiscrepancyResolveRequest) (*schema.SettlementDiscrepancyResponse, error)
+}
+
+type settlementService struct {
+	repo         repository.SettlementRepository
+	providerRepo repository.ProviderRepository
+	cfg          config.Settlement
+	location     *time.Location
+}
+
+var _ SettlementService = (*settlementService)(nil)
+
+func NewSettlementService(repo repository.SettlementRepository, providerRepo repository.ProviderRepository, cfg config.Settlement) *settlementService {
+	return &settlementService{repo: repo, providerRepo: providerRepo, cfg: cfg, location: cfg.Location()}
+}
+
+func (s *settlementService) GetSettlementReport(ctx context.Context, request schema.SettlementReportRequest) ([]*schema.SettlementReportRow, error) {
+	from, to, err := s.settlementPeriod(request)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.repo.GetSettlementRows(ctx, from, to, s.location.String())
+	if err != nil {
+		return nil, err
+	}
+	report := make([]*schema.SettlementReportRow, len(rows))
+	for i := range rows {
+		report[i] = mapper.SettlementReportRowFromModel(&rows[i])
+	}
+	return report, nil
+}
+
+func (s *settlementService) ExportSettlementReport(ctx context.Context, w io.Writer, request schema.SettlementReportRequest) error {
+	report, err := s.GetSettlementReport(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(mapper.SettlementCSVHeader); err != nil {
+		return err
+	}
+	for _, row := range report {
+		if err := writer.Write(mapper.SettlementCSVRecord(row)); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// settlementPeriod returns the start of the first day of the report and the
+// end of its last day in the settlement timezone.
+func (s *settlementService) settlementPeriod(request schema.SettlementReportRequest) (time.Time, time.Time, error) {
+	from, err := time.ParseInLocation(_settlementDayLayout, request.From, s.location)
+	if err != nil {
+		return time.Time{}, time.Time{}, &errs.BadRequestError{Message: fmt.Sprintf("invalid from day %q, expected YYYY-MM-DD", request.From)}
+	}
+	to, err := time.ParseInLocation(_settlementDayLayout, request.To, s.location)
+	if err != nil {
+		return time.Time{}, time.Time{}, &errs.BadRequestError{Message: fmt.Sprintf("invalid to day %q, expected YYYY-MM-DD", request.To)}
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, &errs.BadRequestError{Message: "from must not be after to"}
+	}
+	end := to.AddDate(0, 0, 1)
+	if end.After(from.AddDate(0, 0, _maxSettlementDays)) {
+		return time.Time{}, time.Time{}, &errs.BadRequestError{Message: fmt.Sprintf("a settlement report covers at most %d days", _maxSettlementDays)}
+	}
+	return from, end, nil
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"top-up-api/config"
+	"top-up-api/internal/mapper"
+	"top-up-api/internal/model"
+	"top-up-api/internal/repository"
+	"top-up-api/internal/schema"
+	"top-up-api/pkg/errs"
+)
+
+// _defaultSettlementLayout is the layout of the providers that have none
+// configured, every field left empty in a configured layout falls back to it.
+var _defaultSettlementLayout = config.SettlementLayout{
+	Delimiter:       ",",
+	OrderIDColumn:   "order_id",
+	AmountColumn:    "amount",
+	StatusColumn:    "status",
+	SuccessStatuses: []string{"success"},
+}
+
+// providerSettlementRow is a transaction listed in a provider settlement file.
+type providerSettlementRow struct {
+	orderID   uint
+	amount    int
+	status    string
+	fulfilled bool
+}
+
+func (s *settlementService) ImportProviderSettlement(ctx context.Context, request schema.SettlementImportRequest, file io.Reader) (*schema.SettlementImportResponse, error) {
+	if _, err := s.providerRepo.GetProviderByCode(ctx, request.ProviderCode); err != nil {
+		return nil, catalogError(err, "provider")
+	}
+	from, to, err := s.settlementPeriod(schema.SettlementReportRequest{From: request.Day, To: request.Day})
+	if err != nil {
+		return nil, err
+	}
+	rows, err := parseSettlementFile(file, s.settlementLayout(request.ProviderCode))
+	if err != nil {
+		return nil, err
+	}
+
+	orderIDs := make([]uint, len(rows))
+	for i, row := range rows {
+		orderIDs[i] = row.orderID
+	}
+	histories, err := s.repo.GetSettlementPurchaseHistories(ctx, request.ProviderCode, from, to, orderIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	settlementImport := diffSettlement(rows, histories)
+	settlementImport.ProviderCode = request.ProviderCode
+	settlementImport.Day = from
+	settlementImport.FileName = request.FileName
+	if err := s.repo.CreateSettlementImport(ctx, settlementImport); err != nil {
+		return nil, err
+	}
+	return mapper.SettlementImportResponseFromModel(settlementImport), nil
+}
+
+func (s *settlementService) GetSettlementImports(ctx context.Context) ([]*schema.SettlementImportResponse, error) {
+	imports, err := s.repo.GetSettlementImports(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*schema.SettlementImportResponse, len(imports))
+	for i := range imports {
+		responses[i] = mapper.SettlementImportResponseFromModel(&imports[i])
+	}
+	return responses, nil
+}
+
+func (s *settlementService) GetSettlementDiscrepancies(ctx context.Context, importID uint, status model.SettlementDiscrepancyStatus) ([]*schema.SettlementDiscrepancyResponse, error) {
+	if _, err := s.repo.GetSettlementImportByID(ctx, importID); err != nil {
+		return nil, catalogError(err, "settlement import")
+	}
+	discrepancies, err := s.repo.GetSettlementDiscrepancies(ctx, importID, status)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*schema.SettlementDiscrepancyResponse, len(discrepancies))
+	for i := range discrepancies {
+		responses[i] = mapper.SettlementDiscrepancyResponseFromModel(&discrepancies[i])
+	}
+	return responses, nil
+}
+
+func (s *settlementService) ResolveSettlementDiscrepancy(ctx context.Context, id uint, request schema.SettlementDiscrepancyResolveRequest) (*schema.SettlementDiscrepancyResponse, error) {
+	discrepancy, err := s.repo.GetSettlementDiscrepancyByID(ctx, id)
+	if err != nil {
+		return nil, catalogError(err, "settlement discrepancy")
+	}
+	if discrepancy.Status != model.SettlementDiscrepancyStatusOpen {
+		return nil, &errs.ConflictError{Message: fmt.Sprintf("settlement discrepancy %d is already resolved", id)}
+	}
+
+	resolvedAt := time.Now()
+	if err := s.repo.ResolveSettlementDiscrepancy(ctx, id, request.Note, resolvedAt); err != nil {
+		if errors.Is(err, repository.ErrSettlementDiscrepancyResolved) {
+			return nil, &errs.ConflictError{Message: fmt.Sprintf("settlement discrepancy %d is already resolved", id)}
+		}
+		return nil, err
+	}
+
+	discrepancy.Status = model.SettlementDiscrepancyStatusResolved
+	discrepancy.Note = request.Note
+	discrepancy.ResolvedAt = &resolvedAt
+	return mapper.SettlementDiscrepancyResponseFromModel(discrepancy), nil
+}
+
+// settlementLayout returns the file layout of the provider with the defaults
+// filled in.
+func (s *settlementService) settlementLayout(providerCode string) config.SettlementLayout {
+	layout := s.cfg.Layout(providerCode)
+	if layout.Delimiter == "" {
+		layout.Delimiter = _defaultSettlementLayout.Delimiter
+	}
+	if layout.OrderIDColumn == "" {
+		layout.OrderIDColumn = _defaultSettlementLayout.OrderIDColumn
+	}
+	if layout.AmountColumn == "" {
+		layout.AmountColumn = _defaultSettlementLayout.AmountColumn
+	}
+	if layout.StatusColumn == "" {
+		layout.StatusColumn = _defaultSettlementLayout.StatusColumn
+	}
+	if len(layout.SuccessStatuses) == 0 {
+		layout.SuccessStatuses = _defaultSettlementLayout.SuccessStatuses
+	}
+	return layout
+}
+
+// parseSettlementFile reads the rows of a settlement file. The first line names
+// the columns, matched to the layout ignoring case, and an order may only be
+// listed once.
+func parseSettlementFile(file io.Reader, layout config.SettlementLayout) ([]providerSettlementRow, error) {
+	reader := csv.NewReader(file)
+	reader.Comma = []rune(layout.Delimiter)[0]
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, &errs.BadRequestError{Message: "settlement file is empty"}
+	}
+	if err != nil {
+		return nil, &errs.BadRequestError{Message: fmt.Sprintf("invalid settlement file: %s", err)}
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	var indexes [3]int
+	for i, name := range []string{layout.OrderIDColumn, layout.AmountColumn, layout.StatusColumn} {
+		index, ok := columns[strings.ToLower(name)]
+		if !ok {
+			return nil, &errs.BadRequestError{Message: fmt.Sprintf("settlement file has no %s column", name)}
+		}
+		indexes[i] = index
+	}
+
+	var rows []providerSettlementRow
+	listedOn := make(map[uint]int)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, &errs.BadRequestError{Message: fmt.Sprintf("invalid settlement file: %s", err)}
+		}
+		line, _ := reader.FieldPos(0)
+
+		orderID, err := strconv.ParseUint(strings.TrimSpace(record[indexes[0]]), 10, 64)
+		if err != nil {
+			return nil, &errs.BadRequestError{Message: fmt.Sprintf("line %d: invalid order id %q", line, record[indexes[0]])}
+		}
+		if previous, ok := listedOn[uint(orderID)]; ok {
+			return nil, &errs.BadRequestError{Message: fmt.Sprintf("line %d: order %d is already listed on line %d", line, orderID, previous)}
+		}
+		listedOn[uint(orderID)] = line
+
+		// Thousands separators are dropped, amounts are whole dong
+		amount, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(record[indexes[1]]), ",", ""), 64)
+		if err != nil {
+			return nil, &errs.BadRequestError{Message: fmt.Sprintf("line %d: invalid amount %q", line, record[indexes[1]])}
+		}
+
+		status := strings.TrimSpace(record[indexes[2]])
+		rows = append(rows, providerSettlementRow{
+			orderID: uint(orderID),
+			amount:  int(math.Round(amount)),
+			status:  status,
+			fulfilled: slices.ContainsFunc(layout.SuccessStatuses, func(success string) bool {
+				return strings.EqualFold(success, status)
+			}),
+		})
+	}
+}
+
+// diffSettlement matches the rows of a settlement file to the purchase
+// histories by order id. The amounts compared are face values. The purchase
+// histories no row matches are the orders that succeeded with the provider on
+// the day of the file, which the provider should have listed.
+func diffSettlement(rows []providerSettlementRow, histories []model.PurchaseHistory) *model.SettlementImport {
+	settlementImport := &model.SettlementImport{RowCount: len(rows)}
+	ours := make(map[uint]*model.PurchaseHistory, len(histories))
+	for i := range histories {
+		ours[histories[i].OrderID] = &histories[i]
+	}
+
+	listed := make(map[uint]bool, len(rows))
+	for _, row := range rows {
+		listed[row.orderID] = true
+		history, ok := ours[row.orderID]
+		switch {
+		case !ok && row.fulfilled:
+			settlementImport.Discrepancies = append(settlementImport.Discrepancies, model.SettlementDiscrepancy{
+				OrderID:     row.orderID,
+				Type:        model.SettlementDiscrepancyTypeMissingOurs,
+				TheirAmount: &row.amount,
+				TheirStatus: row.status,
+			})
+		case !ok:
+			// Neither side fulfilled the order
+			settlementImport.MatchedCount++
+		case row.fulfilled != (history.Status == model.PurchaseHistoryStatusSuccess):
+			settlementImport.Discrepancies = append(settlementImport.Discrepancies, theirSettlementDiscrepancy(model.SettlementDiscrepancyTypeStatusMismatch, row, history))
+		case row.fulfilled && row.amount != history.Sku.Price:
+			settlementImport.Discrepancies = append(settlementImport.Discrepancies, theirSettlementDiscrepancy(model.SettlementDiscrepancyTypeAmountMismatch, row, history))
+		default:
+			settlementImport.MatchedCount++
+		}
+	}
+
+	for i := range histories {
+		history := &histories[i]
+		if listed[history.OrderID] {
+			continue
+		}
+		settlementImport.Discrepancies = append(settlementImport.Discrepancies, model.SettlementDiscrepancy{
+			OrderID:   history.OrderID,
+			Type:      model.SettlementDiscrepancyTypeMissingTheirs,
+			OurAmount: &history.Sku.Price,
+			OurStatus: string(history.Status),
+		})
+	}
+	settlementImport.DiscrepancyCount = len(settlementImport.Discrepancies)
+	return settlementImport
+}
+
+func theirSettlementDiscrepancy(discrepancyType model.SettlementDiscrepancyType, row providerSettlementRow, history *model.PurchaseHistory) model.SettlementDiscrepancy {
+	return model.SettlementDiscrepancy{
+		OrderID:     row.orderID,
+		Type:        discrepancyType,
+		OurAmount:   &history.Sku.Price,
+		TheirAmount: &row.amount,
+		OurStatus:   string(history.Status),
+		TheirStatus: row.status,
+	}
+}
@@ -10,6 +10,9 @@ import (
 type SkuService interface {
 	GetSkusBySupplierCode(ctx context.Context, supplierCode string) (*[]schema.SkuResponse, error)
 	GetSkusGroupBySupplier(ctx context.Context) (*[]schema.SkusGroupBySupplier, error)
+	CreateSku(ctx context.Context, request schema.SkuRequest) (*schema.SkuAdminResponse, error)
+	UpdateSku(ctx context.Context, id uint, request schema.SkuRequest) (*schema.SkuAdminResponse, error)
+	DeleteSku(ctx context.Context, id uint) error
 }
 
 type skuService struct {
@@ -52,3 +55,29 @@ func (s *skuService) GetSkusGroupBySupplier(ctx context.Context) (*[]schema.Skus
 
 	return groupedDetails, nil
 }
+
+func (s *skuService) CreateSku(ctx context.Context, request schema.SkuRequest) (*schema.SkuAdminResponse, error) {
+	sku := mapper.SkuFromRequest(request)
+	if err := s.repo.CreateSku(ctx, sku); err != nil {
+		return nil, catalogError(err, "sku")
+	}
+	return mapper.SkuAdminResponseFromModel(sku), nil
+}
+
+func (s *skuService) UpdateSku(ctx context.Context, id uint, request schema.SkuRequest) (*schema.SkuAdminResponse, error) {
+	existing, err := s.repo.GetSkuByID(ctx, id)
+	if err != nil {
+		return nil, catalogError(err, "sku")
+	}
+
+	sku := mapper.SkuFromRequest(request)
+	sku.Model = existing.Model
+	if err := s.repo.UpdateSku(ctx, sku); err != nil {
+		return nil, catalogError(err, "sku")
+	}
+	return mapper.SkuAdminResponseFromModel(sku), nil
+}
+
+func (s *skuService) DeleteSku(ctx context.Context, id uint) error {
+	return catalogError(s.repo.DeleteSku(ctx, id), "sku")
+}
@@ -2,13 +2,20 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"top-up-api/internal/mapper"
 	"top-up-api/internal/repository"
 	"top-up-api/internal/schema"
+	"top-up-api/pkg/errs"
+	"top-up-api/pkg/phone"
 )
 
 type SupplierService interface {
 	GetSuppliers(ctx context.Context) (*[]schema.SupplierResponse, error)
+	SuggestSupplier(ctx context.Context, phoneNumber string) (*schema.SupplierSuggestionResponse, error)
+	CreateSupplier(ctx context.Context, request schema.SupplierRequest) (*schema.SupplierAdminResponse, error)
+	UpdateSupplier(ctx context.Context, id uint, request schema.SupplierRequest) (*schema.SupplierAdminResponse, error)
+	DeleteSupplier(ctx context.Context, id uint) error
 }
 
 type supplierService struct {
@@ -32,3 +39,54 @@ func (s *supplierService) GetSuppliers(ctx context.Context) (*[]schema.SupplierR
 	}
 	return &supplierResponses, nil
 }
+
+// SuggestSupplier returns the supplier of the carrier the phone number belongs
+// to, as told by its prefix.
+func (s *supplierService) SuggestSupplier(ctx context.Context, phoneNumber string) (*schema.SupplierSuggestionResponse, error) {
+	normalized, err := phone.Normalize(phoneNumber)
+	if err != nil {
+		return nil, &errs.BadRequestError{Message: "phone number must be a Vietnamese mobile number"}
+	}
+	carrier, err := phone.Carrier(normalized)
+	if err != nil {
+		return nil, &errs.NotFoundError{Message: fmt.Sprintf("phone number %s doesn't belong to a known carrier", normalized)}
+	}
+
+	suppliers, err := s.repo.GetSuppliersByCodes(ctx, []string{carrier})
+	if err != nil {
+		return nil, err
+	}
+	if len(suppliers) == 0 {
+		return nil, &errs.NotFoundError{Message: fmt.Sprintf("no supplier for carrier %s", carrier)}
+	}
+	return &schema.SupplierSuggestionResponse{
+		PhoneNumber: normalized,
+		Supplier:    *mapper.SupplierResponseFromModel(&suppliers[0]),
+	}, nil
+}
+
+func (s *supplierService) CreateSupplier(ctx context.Context, request schema.SupplierRequest) (*schema.SupplierAdminResponse, error) {
+	supplier := mapper.SupplierFromRequest(request)
+	if err := s.repo.CreateSupplier(ctx, supplier); err != nil {
+		return nil, catalogError(err, "supplier")
+	}
+	return mapper.SupplierAdminResponseFromModel(supplier), nil
+}
+
+func (s *supplierService) UpdateSupplier(ctx context.Context, id uint, request schema.SupplierRequest) (*schema.SupplierAdminResponse, error) {
+	existing, err := s.repo.GetSupplierByID(ctx, id)
+	if err != nil {
+		return nil, catalogError(err, "supplier")
+	}
+
+	supplier := mapper.SupplierFromRequest(request)
+	supplier.Model = existing.Model
+	if err := s.repo.UpdateSupplier(ctx, supplier); err != nil {
+		return nil, catalogError(err, "supplier")
+	}
+	return mapper.SupplierAdminResponseFromModel(supplier), nil
+}
+
+func (s *supplierService) DeleteSupplier(ctx context.Context, id uint) error {
+	return catalogError(s.repo.DeleteSupplier(ctx, id), "supplier")
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"top-up-api/internal/mapper"
+	"top-up-api/internal/model"
+	"top-up-api/internal/repository"
+	"top-up-api/internal/schema"
+	"top-up-api/pkg/errs"
+
+	"gorm.io/gorm"
+)
+
+type WalletService interface {
+	GetBalance(ctx context.Context, userID uint) (*schema.WalletBalanceResponse, error)
+	GetStatement(ctx context.Context, userID uint, page, pageSize int) (*schema.PaginationResponse, error)
+	// The postings below are made by the order service inside the transaction
+	// that changes the order, a zero amount posts nothing.
+	PayOrder(ctx context.Context, userID, orderID uint, amount int) error
+	RefundOrder(ctx context.Context, userID, orderID uint, amount int) error
+	CreditCashBack(ctx context.Context, userID, orderID uint, amount int) error
+	ReverseCashBack(ctx context.Context, userID, orderID uint, amount int) error
+}
+
+type walletService struct {
+	repo repository.LedgerRepository
+}
+
+var _ WalletService = (*walletService)(nil)
+
+func NewWalletService(repo repository.LedgerRepository) *walletService {
+	return &walletService{repo: repo}
+}
+
+func (s *walletService) GetBalance(ctx context.Context, userID uint) (*schema.WalletBalanceResponse, error) {
+	account, err := s.repo.GetWalletAccount(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &schema.WalletBalanceResponse{UserID: userID}, nil
+		}
+		return nil, err
+	}
+	return &schema.WalletBalanceResponse{UserID: userID, Balance: account.Balance}, nil
+}
+
+func (s *walletService) GetStatement(ctx context.Context, userID uint, page, pageSize int) (*schema.PaginationResponse, error) {
+	account, err := s.repo.GetWalletAccount(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return mapper.PaginationResponseFromModel(0, 0, page, []*schema.WalletStatementEntryResponse{}), nil
+		}
+		return nil, err
+	}
+
+	entries, total, err := s.repo.GetLedgerEntriesByAccountIDPaginated(ctx, account.ID, page, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*schema.WalletStatementEntryResponse, len(entries))
+	for i := range entries {
+		responses[i] = mapper.WalletStatementEntryResponseFromModel(&entries[i])
+	}
+
+	totalPage := (int(total) + pageSize - 1) / pageSize
+	return mapper.PaginationResponseFromModel(int(total), totalPage, page, responses), nil
+}
+
+// PayOrder moves the part of an order paid from the wallet to the order payment
+// account. A wallet can't pay more than its balance.
+func (s *walletService) PayOrder(ctx context.Context, userID, orderID uint, amount int) error {
+	return s.post(ctx, model.LedgerTransactionTypeOrderPayment, model.LedgerAccountTypeOrderPayment, userID, orderID, -amount)
+}
+
+// RefundOrder gives the wallet payment of a failed order back.
+func (s *walletService) RefundOrder(ctx context.Context, userID, orderID uint, amount int) error {
+	return s.post(ctx, model.LedgerTransactionTypeOrderRefund, model.LedgerAccountTypeOrderPayment, userID, orderID, amount)
+}
+
+// CreditCashBack credits the cashback of a successful order to the wallet.
+func (s *walletService) CreditCashBack(ctx context.Context, userID, orderID uint, amount int) error {
+	return s.post(ctx, model.LedgerTransactionTypeCashBack, model.LedgerAccountTypeCashBack, userID, orderID, amount)
+}
+
+// ReverseCashBack takes the cashback of an order that failed after it succeeded
+// back. The wallet goes below zero when the cashback was already spent, later
+// cashback pays it off.
+func (s *walletService) ReverseCashBack(ctx context.Context, userID, orderID uint, amount int) error {
+	return s.post(ctx, model.LedgerTransactionTypeCashBackReversal, model.LedgerAccountTypeCashBack, userID, orderID, -amount)
+}
+
+// post moves amount into the user's wallet from the system account of
+// systemType, a negative amount moves it out of the wallet.
+func (s *walletService) post(ctx context.Context, transactionType model.LedgerTransactionType, systemType model.LedgerAccountType, userID, orderID uint, amount int) error {
+	if amount == 0 {
+		return nil
+	}
+
+	wallet, err := s.repo.GetOrCreateWalletAccount(ctx, userID)
+	if err != nil {
+		return err
+	}
+	system, err := s.repo.GetSystemAccount(ctx, systemType)
+	if err != nil {
+		return err
+	}
+
+	transaction := mapper.LedgerTransactionForOrder(transactionType, orderID, wallet, system, amount)
+	if err := s.repo.CreateLedgerTransaction(ctx, transaction); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInsufficientBalance):
+			return &errs.BadRequestError{Message: "insufficient wallet balance"}
+		case errors.Is(err, gorm.ErrDuplicatedKey):
+			return &errs.ConflictError{Message: fmt.Sprintf("order %d already has a %s ledger transaction", orderID, transactionType)}
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,374 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"top-up-api/config"
+	"top-up-api/internal/mapper"
+	"top-up-api/internal/model"
+	"top-up-api/internal/repository"
+	"top-up-api/internal/schema"
+	"top-up-api/pkg/egress"
+	"top-up-api/pkg/errs"
+	"top-up-api/pkg/signature"
+)
+
+const (
+	_defaultWebhookBatchSize   = 50
+	_defaultWebhookMaxAttempts = 8
+	_defaultWebhookBaseBackoff = 10 * time.Second
+	_defaultWebhookMaxBackoff  = time.Hour
+	_defaultWebhookHTTPTimeout = 10 * time.Second
+
+	_webhookSecretPrefix    = "whsec_"
+	_webhookSignatureHeader = "X-Webhook-Signature"
+	_webhookEventHeader     = "X-Webhook-Event"
+	_webhookDeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// WebhookService manages the webhook subscriptions of partners and delivers
+// their events. The User methods only reach the subscriptions of that user,
+// those of another user are reported as not found.
+type WebhookService interface {
+	CreateSubscription(ctx context.Context, userID uint, request schema.WebhookSubscriptionRequest) (*schema.WebhookSubscriptionCreatedResponse, error)
+	GetSubscriptions(ctx context.Context) ([]*schema.WebhookSubscriptionResponse, error)
+	GetUserSubscriptions(ctx context.Context, userID uint) ([]*schema.WebhookSubscriptionResponse, error)
+	UpdateSubscription(ctx context.Context, id uint, request schema.AdminWebhookSubscriptionRequest) (*schema.WebhookSubscriptionResponse, error)
+	UpdateUserSubscription(ctx context.Context, userID, id uint, request schema.WebhookSubscriptionRequest) (*schema.WebhookSubscriptionResponse, error)
+	DeleteSubscription(ctx context.Context, id uint) error
+	DeleteUserSubscription(ctx context.Context, userID, id uint) error
+	GetDeliveries(ctx context.Context, subscriptionID uint, page, pageSize int) (*schema.PaginationResponse, error)
+	GetUserDeliveries(ctx context.Context, userID, subscriptionID uint, page, pageSize int) (*schema.PaginationResponse, error)
+	// Redeliver queues a delivery again, e.g. once the partner fixed its endpoint.
+	Redeliver(ctx context.Context, deliveryID uint) (*schema.WebhookDeliveryResponse, error)
+	RedeliverUserDelivery(ctx context.Context, userID, deliveryID uint) (*schema.WebhookDeliveryResponse, error)
+	// FanOut queues a delivery of an outbox event for every active subscription
+	// to its type of the user the order belongs to. It runs in the transaction
+	// that dispatches the outbox message.
+	FanOut(ctx context.Context, message *model.OutboxMessage) error
+	// DispatchPending sends the deliveries that are due and returns how many of
+	// them were delivered.
+	DispatchPending(ctx context.Context) (int, error)
+}
+
+type webhookService struct {
+	repo       repository.WebhookRepository
+	httpClient *http.Client
+	config     config.Webhook
+}
+
+var _ WebhookService = (*webhookService)(nil)
+
+func NewWebhookService(repo repository.WebhookRepository, cfg config.Webhook) *webhookService {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = _defaultWebhookBatchSize
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = _defaultWebhookMaxAttempts
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = _defaultWebhookBaseBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = _defaultWebhookMaxBackoff
+	}
+	if cfg.HTTPTimeout <= 0 {
+		cfg.HTTPTimeout = _defaultWebhookHTTPTimeout
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = defaultLease(cfg.BatchSize, cfg.HTTPTimeout)
+	}
+
+	httpClient := egress.NewClient(cfg.HTTPTimeout)
+	if cfg.AllowInsecureURLs {
+		httpClient = &http.Client{Timeout: cfg.HTTPTimeout}
+	}
+
+	return &webhookService{
+		repo:       repo,
+		httpClient: httpClient,
+		config:     cfg,
+	}
+}
+
+func (s *webhookService) CreateSubscription(ctx context.Context, userID uint, request schema.WebhookSubscriptionRequest) (*schema.WebhookSubscriptionCreatedResponse, error) {
+	if err := s.checkURL(request.URL); err != nil {
+		return nil, err
+	}
+	secret, err := newSecret(_webhookSecretPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription := mapper.WebhookSubscriptionFromRequest(userID, request)
+	subscription.Secret = secret
+	if err := s.repo.CreateWebhookSubscription(ctx, subscription); err != nil {
+		return nil, catalogError(err, "webhook subscription")
+	}
+	return &schema.WebhookSubscriptionCreatedResponse{
+		WebhookSubscriptionResponse: *mapper.WebhookSubscriptionResponseFromModel(subscription),
+		Secret:                      secret,
+	}, nil
+}
+
+func (s *webhookService) GetSubscriptions(ctx context.Context) ([]*schema.WebhookSubscriptionResponse, error) {
+	subscriptions, err := s.repo.GetWebhookSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return webhookSubscriptionResponses(subscriptions), nil
+}
+
+func (s *webhookService) GetUserSubscriptions(ctx context.Context, userID uint) ([]*schema.WebhookSubscriptionResponse, error) {
+	subscriptions, err := s.repo.GetWebhookSubscriptionsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return webhookSubscriptionResponses(subscriptions), nil
+}
+
+func (s *webhookService) UpdateSubscription(ctx context.Context, id uint, request schema.AdminWebhookSubscriptionRequest) (*schema.WebhookSubscriptionResponse, error) {
+	existing, err := s.repo.GetWebhookSubscriptionByID(ctx, id)
+	if err != nil {
+		return nil, catalogError(err, "webhook subscription")
+	}
+	return s.updateSubscription(ctx, existing, request.UserID, request.WebhookSubscriptionRequest)
+}
+
+func (s *webhookService) UpdateUserSubscription(ctx context.Context, userID, id uint, request schema.WebhookSubscriptionRequest) (*schema.WebhookSubscriptionResponse, error) {
+	existing, err := s.getUserSubscription(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateSubscription(ctx, existing, userID, request)
+}
+
+// updateSubscription replaces a subscription with the request, its secret is kept
+func (s *webhookService) updateSubscription(ctx context.Context, existing *model.WebhookSubscription, userID uint, request schema.WebhookSubscriptionRequest) (*schema.WebhookSubscriptionResponse, error) {
+	if err := s.checkURL(request.URL); err != nil {
+		return nil, err
+	}
+	subscription := mapper.WebhookSubscriptionFromRequest(userID, request)
+	subscription.Model = existing.Model
+	subscription.Secret = existing.Secret
+	if err := s.repo.UpdateWebhookSubscription(ctx, subscription); err != nil {
+		return nil, catalogError(err, "webhook subscription")
+	}
+	return mapper.WebhookSubscriptionResponseFromModel(subscription), nil
+}
+
+func (s *webhookService) DeleteSubscription(ctx context.Context, id uint) error {
+	return catalogError(s.repo.DeleteWebhookSubscription(ctx, id), "webhook subscription")
+}
+
+func (s *webhookService) DeleteUserSubscription(ctx context.Context, userID, id uint) error {
+	if _, err := s.getUserSubscription(ctx, userID, id); err != nil {
+		return err
+	}
+	return s.DeleteSubscription(ctx, id)
+}
+
+func (s *webhookService) GetDeliveries(ctx context.Context, subscriptionID uint, page, pageSize int) (*schema.PaginationResponse, error) {
+	if _, err := s.repo.GetWebhookSubscriptionByID(ctx, subscriptionID); err != nil {
+		return nil, catalogError(err, "webhook subscription")
+	}
+	return s.getDeliveries(ctx, subscriptionID, page, pageSize)
+}
+
+func (s *webhookService) GetUserDeliveries(ctx context.Context, userID, subscriptionID uint, page, pageSize int) (*schema.PaginationResponse, error) {
+	if _, err := s.getUserSubscription(ctx, userID, subscriptionID); err != nil {
+		return nil, err
+	}
+	return s.getDeliveries(ctx, subscriptionID, page, pageSize)
+}
+
+func (s *webhookService) getDeliveries(ctx context.Context, subscriptionID uint, page, pageSize int) (*schema.PaginationResponse, error) {
+	deliveries, total, err := s.repo.GetWebhookDeliveriesBySubscriptionIDPaginated(ctx, subscriptionID, page, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*schema.WebhookDeliveryResponse, len(deliveries))
+	for i := range deliveries {
+		responses[i] = mapper.WebhookDeliveryResponseFromModel(&deliveries[i])
+	}
+
+	totalPage := (int(total) + pageSize - 1) / pageSize
+	return mapper.PaginationResponseFromModel(int(total), totalPage, page, responses), nil
+}
+
+func (s *webhookService) Redeliver(ctx context.Context, deliveryID uint) (*schema.WebhookDeliveryResponse, error) {
+	delivery, err := s.repo.GetWebhookDeliveryByID(ctx, deliveryID)
+	if err != nil {
+		return nil, catalogError(err, "webhook delivery")
+	}
+	return s.redeliver(ctx, delivery)
+}
+
+func (s *webhookService) RedeliverUserDelivery(ctx context.Context, userID, deliveryID uint) (*schema.WebhookDeliveryResponse, error) {
+	delivery, err := s.repo.GetWebhookDeliveryByID(ctx, deliveryID)
+	if err != nil {
+		return nil, catalogError(err, "webhook delivery")
+	}
+	if _, err := s.getUserSubscription(ctx, userID, delivery.SubscriptionID); err != nil {
+		return nil, &errs.NotFoundError{Message: "webhook delivery not found"}
+	}
+	return s.redeliver(ctx, delivery)
+}
+
+func (s *webhookService) redeliver(ctx context.Context, delivery *model.WebhookDelivery) (*schema.WebhookDeliveryResponse, error) {
+	now := time.Now()
+	if err := s.repo.ResetWebhookDelivery(ctx, delivery.ID, now); err != nil {
+		return nil, err
+	}
+
+	delivery.Status = model.WebhookDeliveryStatusPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now
+	delivery.DeliveredAt = nil
+	return mapper.WebhookDeliveryResponseFromModel(delivery), nil
+}
+
+// checkURL keeps subscriptions from pointing the server at the internal network
+func (s *webhookService) checkURL(url string) error {
+	if s.config.AllowInsecureURLs {
+		return nil
+	}
+	if err := egress.CheckURL(url); err != nil {
+		return &errs.BadRequestError{Message: "webhook url rejected: " + err.Error()}
+	}
+	return nil
+}
+
+// getUserSubscription returns a subscription of a user, a subscription of
+// another user is reported as not found.
+func (s *webhookService) getUserSubscription(ctx context.Context, userID, id uint) (*model.WebhookSubscription, error) {
+	subscription, err := s.repo.GetWebhookSubscriptionByID(ctx, id)
+	if err != nil {
+		return nil, catalogError(err, "webhook subscription")
+	}
+	if subscription.UserID != userID {
+		return nil, &errs.NotFoundError{Message: "webhook subscription not found"}
+	}
+	return subscription, nil
+}
+
+func (s *webhookService) FanOut(ctx context.Context, message *model.OutboxMessage) error {
+	var event schema.WebhookOrderEvent
+	if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+		return fmt.Errorf("failed to decode webhook event: %w", err)
+	}
+	// Without its user the event can't be scoped to the partner it belongs to
+	if event.UserID == 0 {
+		return errors.New("webhook event has no user")
+	}
+
+	subscriptions, err := s.repo.GetActiveWebhookSubscriptionsByEvent(ctx, event.UserID, message.EventType)
+	if err != nil {
+		return err
+	}
+	return s.repo.CreateWebhookDeliveries(ctx, mapper.WebhookDeliveriesFromMessage(subscriptions, message))
+}
+
+func (s *webhookService) DispatchPending(ctx context.Context) (int, error) {
+	deliveries, err := s.repo.ClaimDueWebhookDeliveries(ctx, time.Now(), s.config.Lease, s.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	// A result that could not be recorded doesn't hold the rest of the batch
+	// back, the delivery is due again once its lease ends
+	delivered := 0
+	var failures []error
+	for i := range deliveries {
+		ok, err := s.dispatch(ctx, &deliveries[i])
+		if err != nil {
+			failures = append(failures, fmt.Errorf("webhook delivery %d: %w", deliveries[i].ID, err))
+		}
+		if ok {
+			delivered++
+		}
+	}
+	return delivered, errors.Join(failures...)
+}
+
+// dispatch makes one delivery attempt and records its result. The returned
+// error is only set when the result could not be recorded.
+func (s *webhookService) dispatch(ctx context.Context, delivery *model.WebhookDelivery) (bool, error) {
+	attempts := delivery.Attempts + 1
+
+	subscription := delivery.Subscription
+	if subscription.DeletedAt.Valid || !subscription.Active {
+		return false, s.repo.MarkWebhookDeliveryFailed(ctx, delivery.ID, delivery.Attempts, 0, "subscription is no longer active")
+	}
+
+	responseStatus, deliveryErr := s.deliver(ctx, delivery)
+	if deliveryErr == nil {
+		return true, s.repo.MarkWebhookDeliveryDelivered(ctx, delivery.ID, attempts, responseStatus, time.Now())
+	}
+
+	if attempts >= s.config.MaxAttempts {
+		return false, s.repo.MarkWebhookDeliveryFailed(ctx, delivery.ID, attempts, responseStatus, deliveryErr.Error())
+	}
+
+	nextAttemptAt := time.Now().Add(exponentialBackoff(s.config.BaseBackoff, s.config.MaxBackoff, attempts))
+	return false, s.repo.MarkWebhookDeliveryRetry(ctx, delivery.ID, attempts, responseStatus, nextAttemptAt, deliveryErr.Error())
+}
+
+// deliver posts the signed payload to the subscription and returns the
+// response status, which is 0 when no response arrived.
+func (s *webhookService) deliver(ctx context.Context, delivery *model.WebhookDelivery) (int, error) {
+	// Subscriptions stored before the check are held to it as well
+	if err := s.checkURL(delivery.Subscription.URL); err != nil {
+		return 0, err
+	}
+
+	payload := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Subscription.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(_webhookEventHeader, delivery.EventType)
+	req.Header.Set(_webhookDeliveryHeader, strconv.Itoa(int(delivery.ID)))
+	req.Header.Set(_webhookSignatureHeader, signature.Header(delivery.Subscription.Secret, time.Now(), payload))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status: %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func webhookSubscriptionResponses(subscriptions []model.WebhookSubscription) []*schema.WebhookSubscriptionResponse {
+	responses := make([]*schema.WebhookSubscriptionResponse, len(subscriptions))
+	for i := range subscriptions {
+		responses[i] = mapper.WebhookSubscriptionResponseFromModel(&subscriptions[i])
+	}
+	return responses
+}
+
+// webhookEventOf returns the webhook event announcing an order status, only
+// settled orders are announced to partners.
+func webhookEventOf(status model.PurchaseHistoryStatus) (string, bool) {
+	switch status {
+	case model.PurchaseHistoryStatusSuccess:
+		return model.WebhookEventOrderSucceeded, true
+	case model.PurchaseHistoryStatusFailed:
+		return model.WebhookEventOrderFailed, true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,120 @@
+package statemachine
+
+import (
+	"fmt"
+	"top-up-api/internal/model"
+)
+
+// TransitionError is returned when an order is moved to a status that is not
+// reachable from its current status.
+type TransitionError struct {
+	From model.PurchaseHistoryStatus
+	To   model.PurchaseHistoryStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("invalid order status transition from %s to %s", e.From, e.To)
+}
+
+// UnknownStatusError is returned for a status that is not part of the
+// transition table.
+type UnknownStatusError struct {
+	Status model.PurchaseHistoryStatus
+}
+
+func (e *UnknownStatusError) Error() string {
+	return fmt.Sprintf("unknown order status: %s", e.Status)
+}
+
+// _orderTransitions lists, for every order status, the statuses it may move to.
+// Statuses with no outgoing transitions are terminal. A pending order that isn't
+// paid in time expires.
+var _orderTransitions = map[model.PurchaseHistoryStatus][]model.PurchaseHistoryStatus{
+	model.PurchaseHistoryStatusPending: {model.PurchaseHistoryStatusConfirm, model.PurchaseHistoryStatusFailed, model.PurchaseHistoryStatusExpired},
+	model.PurchaseHistoryStatusConfirm: {model.PurchaseHistoryStatusSuccess, model.PurchaseHistoryStatusFailed},
+	model.PurchaseHistoryStatusSuccess: {},
+	model.PurchaseHistoryStatusFailed:  {},
+	model.PurchaseHistoryStatusExpired: {},
+}
+
+// _orderReversals lists the status a terminal order may be reversed to, e.g. a
+// successful top-up the carrier took back. Reversals aren't transitions, only
+// an operator reverses an order.
+var _orderReversals = map[model.PurchaseHistoryStatus]model.PurchaseHistoryStatus{
+	model.PurchaseHistoryStatusSuccess: model.PurchaseHistoryStatusFailed,
+}
+
+type OrderStateMachine struct {
+	transitions map[model.PurchaseHistoryStatus]map[model.PurchaseHistoryStatus]struct{}
+}
+
+func NewOrderStateMachine() *OrderStateMachine {
+	transitions := make(map[model.PurchaseHistoryStatus]map[model.PurchaseHistoryStatus]struct{}, len(_orderTransitions))
+	for from, targets := range _orderTransitions {
+		transitions[from] = make(map[model.PurchaseHistoryStatus]struct{}, len(targets))
+		for _, to := range targets {
+			transitions[from][to] = struct{}{}
+		}
+	}
+	return &OrderStateMachine{transitions: transitions}
+}
+
+// Validate checks that status is a known order status.
+func (m *OrderStateMachine) Validate(status model.PurchaseHistoryStatus) error {
+	if _, ok := m.transitions[status]; !ok {
+		return &UnknownStatusError{Status: status}
+	}
+	return nil
+}
+
+// Transition checks that an order in status from may move to status to.
+func (m *OrderStateMachine) Transition(from, to model.PurchaseHistoryStatus) error {
+	if err := m.Validate(from); err != nil {
+		return err
+	}
+	if err := m.Validate(to); err != nil {
+		return err
+	}
+	if _, ok := m.transitions[from][to]; !ok {
+		return &TransitionError{From: from, To: to}
+	}
+	return nil
+}
+
+// TransitionFrom is Transition for write paths that only act on orders in the
+// expected status, e.g. a payment confirmation only applies to pending orders.
+func (m *OrderStateMachine) TransitionFrom(expected, from, to model.PurchaseHistoryStatus) error {
+	if err := m.Transition(from, to); err != nil {
+		return err
+	}
+	if from != expected {
+		return &TransitionError{From: from, To: to}
+	}
+	return nil
+}
+
+// Reverse returns the status an order in status from is reversed to.
+func (m *OrderStateMachine) Reverse(from model.PurchaseHistoryStatus) (model.PurchaseHistoryStatus, error) {
+	if err := m.Validate(from); err != nil {
+		return "", err
+	}
+	to, ok := _orderReversals[from]
+	if !ok {
+		return "", &TransitionError{From: from, To: model.PurchaseHistoryStatusFailed}
+	}
+	return to, nil
+}
+
+func (m *OrderStateMachine) CanTransition(from, to model.PurchaseHistoryStatus) bool {
+	return m.Transition(from, to) == nil
+}
+
+func (m *OrderStateMachine) IsTerminal(status model.PurchaseHistoryStatus) bool {
+	targets, ok := m.transitions[status]
+	return ok && len(targets) == 0
+}
+
+// IsSettled reports whether the top-up of an order in status has a result.
+func (m *OrderStateMachine) IsSettled(status model.PurchaseHistoryStatus) bool {
+	return status == model.PurchaseHistoryStatusSuccess || status == model.PurchaseHistoryStatusFailed
+}
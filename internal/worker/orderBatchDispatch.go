@@ -0,0 +1,52 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"time"
+	"top-up-api/internal/service"
+	"top-up-api/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+const (
+	_defaultOrderBatchDispatchInterval = time.Second
+	_defaultOrderBatchDispatchRate     = 10
+)
+
+// OrderBatchDispatcher sends the orders of paid batches to their providers,
+// at most rate orders every interval, so a large batch doesn't flood them
+type OrderBatchDispatcher struct {
+	logger   logger.Interface
+	service  service.OrderService
+	interval time.Duration
+	rate     int
+}
+
+func NewOrderBatchDispatcher(l logger.Interface, s service.OrderService, interval time.Duration, rate int) *OrderBatchDispatcher {
+	if interval <= 0 {
+		interval = _defaultOrderBatchDispatchInterval
+	}
+	if rate <= 0 {
+		rate = _defaultOrderBatchDispatchRate
+	}
+	return &OrderBatchDispatcher{logger: l, service: s, interval: interval, rate: rate}
+}
+
+func (d *OrderBatchDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := d.service.DispatchOrderBatches(ctx, d.rate)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				d.logger.Error(errors.New("order batch dispatcher: failed to dispatch batch orders"), zap.Error(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"top-up-api/internal/service"
+	"top-up-api/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+const (
+	_defaultOrderPendingTimeout = 30 * time.Minute
+	_defaultOrderSweepInterval  = time.Minute
+	_defaultOrderSweepBatchSize = 100
+)
+
+// OrderExpirySweeper expires the orders and order batches still pending payment
+// after the pending timeout, so abandoned payments are cancelled on the payment
+// service
+type OrderExpirySweeper struct {
+	logger         logger.Interface
+	service        service.OrderService
+	pendingTimeout time.Duration
+	interval       time.Duration
+	batchSize      int
+}
+
+func NewOrderExpirySweeper(l logger.Interface, s service.OrderService, pendingTimeout, interval time.Duration, batchSize int) *OrderExpirySweeper {
+	if pendingTimeout <= 0 {
+		pendingTimeout = _defaultOrderPendingTimeout
+	}
+	if interval <= 0 {
+		interval = _defaultOrderSweepInterval
+	}
+	if batchSize <= 0 {
+		batchSize = _defaultOrderSweepBatchSize
+	}
+	return &OrderExpirySweeper{logger: l, service: s, pendingTimeout: pendingTimeout, interval: interval, batchSize: batchSize}
+}
+
+func (w *OrderExpirySweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			createdBefore := time.Now().Add(-w.pendingTimeout)
+			expired, err := w.service.ExpirePendingOrders(ctx, createdBefore, w.batchSize)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				w.logger.Error(errors.New("order expiry sweeper: failed to expire pending orders"), zap.Error(err))
+			}
+			if expired > 0 {
+				w.logger.Info(fmt.Sprintf("order expiry sweeper: expired %d orders", expired))
+			}
+
+			expired, err = w.service.ExpirePendingOrderBatches(ctx, createdBefore, w.batchSize)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				w.logger.Error(errors.New("order expiry sweeper: failed to expire pending order batches"), zap.Error(err))
+			}
+			if expired > 0 {
+				w.logger.Info(fmt.Sprintf("order expiry sweeper: expired %d order batches", expired))
+			}
+		}
+	}
+}
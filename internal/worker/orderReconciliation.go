@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"top-up-api/internal/service"
+	"top-up-api/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+const (
+	_defaultReconciliationSLA           = 15 * time.Minute
+	_defaultReconciliationEscalateAfter = 2 * time.Hour
+	_defaultReconciliationInterval      = time.Minute
+	_defaultReconciliationBatchSize     = 50
+)
+
+// OrderReconciler queries the providers of the confirmed orders that got no
+// callback within the SLA, and queues the orders still unresolved after
+// escalateAfter for manual review
+type OrderReconciler struct {
+	logger        logger.Interface
+	service       service.OrderService
+	sla           time.Duration
+	escalateAfter time.Duration
+	interval      time.Duration
+	batchSize     int
+}
+
+func NewOrderReconciler(l logger.Interface, s service.OrderService, sla, escalateAfter, interval time.Duration, batchSize int) *OrderReconciler {
+	if sla <= 0 {
+		sla = _defaultReconciliationSLA
+	}
+	if escalateAfter <= 0 {
+		escalateAfter = _defaultReconciliationEscalateAfter
+	}
+	// An order is queried at least once before it is escalated
+	escalateAfter = max(escalateAfter, sla)
+	if interval <= 0 {
+		interval = _defaultReconciliationInterval
+	}
+	if batchSize <= 0 {
+		batchSize = _defaultReconciliationBatchSize
+	}
+	return &OrderReconciler{logger: l, service: s, sla: sla, escalateAfter: escalateAfter, interval: interval, batchSize: batchSize}
+}
+
+func (r *OrderReconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now()
+			result, err := r.service.ReconcileStuckOrders(ctx, now.Add(-r.sla), now.Add(-r.escalateAfter), r.batchSize)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				r.logger.Error(errors.New("order reconciler: failed to reconcile stuck orders"), zap.Error(err))
+			}
+			if result.Dispatched > 0 || result.Settled > 0 || result.Escalated > 0 {
+				r.logger.Info(fmt.Sprintf("order reconciler: dispatched %d orders, settled %d, queued %d for manual review", result.Dispatched, result.Settled, result.Escalated))
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"top-up-api/internal/service"
+	"top-up-api/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+const _defaultOutboxPollInterval = time.Second
+
+// OutboxDispatcher polls the outbox and hands due messages to the outbox service
+type OutboxDispatcher struct {
+	logger   logger.Interface
+	service  service.OutboxService
+	interval time.Duration
+}
+
+func NewOutboxDispatcher(l logger.Interface, s service.OutboxService, interval time.Duration) *OutboxDispatcher {
+	if interval <= 0 {
+		interval = _defaultOutboxPollInterval
+	}
+	return &OutboxDispatcher{logger: l, service: s, interval: interval}
+}
+
+func (d *OutboxDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			delivered, err := d.service.DispatchPending(ctx)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				d.logger.Error(errors.New("outbox dispatcher: failed to dispatch pending messages"), zap.Error(err))
+				continue
+			}
+			if delivered > 0 {
+				d.logger.Debug(fmt.Sprintf("outbox dispatcher: delivered %d messages", delivered))
+			}
+		}
+	}
+}
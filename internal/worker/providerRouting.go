@@ -0,0 +1,44 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"time"
+	"top-up-api/internal/service"
+	"top-up-api/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+const _defaultProviderReloadInterval = time.Minute
+
+// ProviderRoutingReloader periodically rebuilds the provider routing table so
+// provider changes in the database take effect without a restart
+type ProviderRoutingReloader struct {
+	logger   logger.Interface
+	service  service.OrderService
+	interval time.Duration
+}
+
+func NewProviderRoutingReloader(l logger.Interface, s service.OrderService, interval time.Duration) *ProviderRoutingReloader {
+	if interval <= 0 {
+		interval = _defaultProviderReloadInterval
+	}
+	return &ProviderRoutingReloader{logger: l, service: s, interval: interval}
+}
+
+func (r *ProviderRoutingReloader) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.service.ReloadProviders(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				r.logger.Error(errors.New("provider routing reloader: keeping previous routing table"), zap.Error(err))
+			}
+		}
+	}
+}
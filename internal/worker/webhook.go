@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"top-up-api/internal/service"
+	"top-up-api/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+const _defaultWebhookPollInterval = time.Second
+
+// WebhookDispatcher polls the webhook deliveries and hands due ones to the webhook service
+type WebhookDispatcher struct {
+	logger   logger.Interface
+	service  service.WebhookService
+	interval time.Duration
+}
+
+func NewWebhookDispatcher(l logger.Interface, s service.WebhookService, interval time.Duration) *WebhookDispatcher {
+	if interval <= 0 {
+		interval = _defaultWebhookPollInterval
+	}
+	return &WebhookDispatcher{logger: l, service: s, interval: interval}
+}
+
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			delivered, err := d.service.DispatchPending(ctx)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				d.logger.Error(errors.New("webhook dispatcher: failed to dispatch pending deliveries"), zap.Error(err))
+				continue
+			}
+			if delivered > 0 {
+				d.logger.Debug(fmt.Sprintf("webhook dispatcher: delivered %d webhooks", delivered))
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"top-up-api/config"
+	"top-up-api/internal/service"
+	"top-up-api/pkg/logger"
+)
+
+// Workers holds the background jobs that run next to the HTTP and gRPC servers
+type Workers struct {
+	// Dependency
+	logger logger.Interface
+	// Worker
+	outboxDispatcher        *OutboxDispatcher
+	webhookDispatcher       *WebhookDispatcher
+	providerRoutingReloader *ProviderRoutingReloader
+	orderExpirySweeper      *OrderExpirySweeper
+	orderReconciler         *OrderReconciler
+	orderBatchDispatcher    *OrderBatchDispatcher
+
+	wg sync.WaitGroup
+}
+
+// NewWorkers creates every background worker from the service container
+func NewWorkers(
+	config *config.Config,
+	services *service.Container,
+) *Workers {
+	outboxDispatcher := NewOutboxDispatcher(services.Logger, services.OutboxService, config.Outbox.PollInterval)
+	webhookDispatcher := NewWebhookDispatcher(services.Logger, services.WebhookService, config.Webhook.PollInterval)
+	providerRoutingReloader := NewProviderRoutingReloader(services.Logger, services.OrderService, config.ProviderDispatch.ReloadInterval)
+	orderExpirySweeper := NewOrderExpirySweeper(services.Logger, services.OrderService, config.OrderExpiry.PendingTimeout, config.OrderExpiry.SweepInterval, config.OrderExpiry.BatchSize)
+	orderReconciler := NewOrderReconciler(services.Logger, services.OrderService, config.Reconciliation.SLA, config.Reconciliation.EscalateAfter, config.Reconciliation.Interval, config.Reconciliation.BatchSize)
+	orderBatchDispatcher := NewOrderBatchDispatcher(services.Logger, services.OrderService, config.OrderBatch.DispatchInterval, config.OrderBatch.DispatchRate)
+
+	return &Workers{
+		// Dependency
+		logger: services.Logger,
+
+		// Worker
+		outboxDispatcher:        outboxDispatcher,
+		webhookDispatcher:       webhookDispatcher,
+		providerRoutingReloader: providerRoutingReloader,
+		orderExpirySweeper:      orderExpirySweeper,
+		orderReconciler:         orderReconciler,
+		orderBatchDispatcher:    orderBatchDispatcher,
+	}
+}
+
+// StartWorkers runs every worker until ctx is cancelled
+func (w *Workers) StartWorkers(ctx context.Context) {
+	w.logger.Info("Starting background workers...")
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.outboxDispatcher.Run(ctx)
+	}()
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.webhookDispatcher.Run(ctx)
+	}()
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.providerRoutingReloader.Run(ctx)
+	}()
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.orderExpirySweeper.Run(ctx)
+	}()
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.orderReconciler.Run(ctx)
+	}()
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.orderBatchDispatcher.Run(ctx)
+	}()
+
+	w.logger.Info("All background workers started successfully")
+}
+
+// Wait blocks until every worker has returned after its context was cancelled
+func (w *Workers) Wait() {
+	w.wg.Wait()
+}
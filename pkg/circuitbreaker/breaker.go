@@ -0,0 +1,257 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+type State string
+
+const (
+	StateClosed   State = "closed"
+	StateOpen     State = "open"
+	StateHalfOpen State = "half_open"
+)
+
+const (
+	_defaultWindowSize           = 20
+	_defaultMinRequests          = 5
+	_defaultFailureRateThreshold = 0.5
+	_defaultSlowCallThreshold    = 5 * time.Second
+	_defaultOpenTimeout          = 30 * time.Second
+	_defaultHalfOpenProbes       = 1
+)
+
+var ErrOpen = errors.New("circuit breaker is open")
+
+// Config controls when a breaker opens and how it recovers. Zero values fall
+// back to the package defaults.
+type Config struct {
+	// WindowSize is the number of most recent calls the breaker keeps.
+	WindowSize int
+	// MinRequests is the number of calls in the window before it may open.
+	MinRequests int
+	// FailureRateThreshold opens the breaker once the failure rate reaches it.
+	FailureRateThreshold float64
+	// SlowCallThreshold is the latency above which a call counts as a failure
+	// and lowers the health score.
+	SlowCallThreshold time.Duration
+	// OpenTimeout is how long the breaker stays open before probing.
+	OpenTimeout time.Duration
+	// HalfOpenProbes is the number of successful probes needed to close again.
+	HalfOpenProbes int
+}
+
+// Snapshot is a point in time view of a breaker.
+type Snapshot struct {
+	State       State
+	Requests    int
+	SuccessRate float64
+	AvgLatency  time.Duration
+	HealthScore float64
+	OpenedAt    time.Time
+}
+
+type call struct {
+	success bool
+	latency time.Duration
+}
+
+// Breaker is a count based circuit breaker. It tracks the outcome and latency
+// of the last WindowSize calls.
+type Breaker struct {
+	mu     sync.Mutex
+	config Config
+
+	state          State
+	window         []call
+	next           int
+	openedAt       time.Time
+	probesInFlight int
+	probeSuccesses int
+}
+
+func New(cfg Config) *Breaker {
+	if cfg.WindowSize <= 0 {
+		cfg.WindowSize = _defaultWindowSize
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = _defaultMinRequests
+	}
+	if cfg.MinRequests > cfg.WindowSize {
+		cfg.MinRequests = cfg.WindowSize
+	}
+	if cfg.FailureRateThreshold <= 0 || cfg.FailureRateThreshold > 1 {
+		cfg.FailureRateThreshold = _defaultFailureRateThreshold
+	}
+	if cfg.SlowCallThreshold <= 0 {
+		cfg.SlowCallThreshold = _defaultSlowCallThreshold
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = _defaultOpenTimeout
+	}
+	if cfg.HalfOpenProbes <= 0 {
+		cfg.HalfOpenProbes = _defaultHalfOpenProbes
+	}
+
+	return &Breaker{
+		config: cfg,
+		state:  StateClosed,
+		window: make([]call, 0, cfg.WindowSize),
+	}
+}
+
+// Ready reports whether a call would currently be let through, without
+// reserving a half-open probe.
+func (b *Breaker) Ready() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.currentState() {
+	case StateOpen:
+		return false
+	case StateHalfOpen:
+		return b.probesInFlight < b.config.HalfOpenProbes
+	default:
+		return true
+	}
+}
+
+// Allow reports whether a call may go ahead. In the half-open state it
+// reserves one of the probes, so every allowed call must be followed by Record.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.currentState() {
+	case StateOpen:
+		return false
+	case StateHalfOpen:
+		if b.probesInFlight >= b.config.HalfOpenProbes {
+			return false
+		}
+		b.probesInFlight++
+		return true
+	default:
+		return true
+	}
+}
+
+// Record adds the outcome of an allowed call.
+func (b *Breaker) Record(success bool, latency time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.currentState() {
+	case StateHalfOpen:
+		if b.probesInFlight > 0 {
+			b.probesInFlight--
+		}
+		if b.failed(call{success: success, latency: latency}) {
+			b.open()
+			return
+		}
+		b.probeSuccesses++
+		if b.probeSuccesses >= b.config.HalfOpenProbes {
+			b.close()
+		}
+	case StateClosed:
+		b.push(call{success: success, latency: latency})
+		if b.shouldOpen() {
+			b.open()
+		}
+	}
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.currentState()
+}
+
+func (b *Breaker) Snapshot() Snapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	snapshot := Snapshot{
+		State:       b.currentState(),
+		Requests:    len(b.window),
+		SuccessRate: 1,
+		HealthScore: 1,
+		OpenedAt:    b.openedAt,
+	}
+	if len(b.window) > 0 {
+		var successes int
+		var latency time.Duration
+		for _, c := range b.window {
+			if c.success {
+				successes++
+			}
+			latency += c.latency
+		}
+		snapshot.SuccessRate = float64(successes) / float64(len(b.window))
+		snapshot.AvgLatency = latency / time.Duration(len(b.window))
+		snapshot.HealthScore = snapshot.SuccessRate
+		if snapshot.AvgLatency > b.config.SlowCallThreshold {
+			snapshot.HealthScore *= float64(b.config.SlowCallThreshold) / float64(snapshot.AvgLatency)
+		}
+	}
+	if snapshot.State == StateOpen {
+		snapshot.HealthScore = 0
+	}
+	return snapshot
+}
+
+// currentState moves an open breaker to half-open once OpenTimeout has passed.
+func (b *Breaker) currentState() State {
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.config.OpenTimeout {
+		b.state = StateHalfOpen
+		b.probesInFlight = 0
+		b.probeSuccesses = 0
+	}
+	return b.state
+}
+
+func (b *Breaker) push(c call) {
+	if len(b.window) < b.config.WindowSize {
+		b.window = append(b.window, c)
+		return
+	}
+	b.window[b.next] = c
+	b.next = (b.next + 1) % b.config.WindowSize
+}
+
+func (b *Breaker) shouldOpen() bool {
+	if len(b.window) < b.config.MinRequests {
+		return false
+	}
+	var failures int
+	for _, c := range b.window {
+		if b.failed(c) {
+			failures++
+		}
+	}
+	return float64(failures)/float64(len(b.window)) >= b.config.FailureRateThreshold
+}
+
+// failed treats a call that took longer than SlowCallThreshold as a failure,
+// a provider that answers too slowly is as unusable as one that errors.
+func (b *Breaker) failed(c call) bool {
+	return !c.success || c.latency > b.config.SlowCallThreshold
+}
+
+func (b *Breaker) open() {
+	b.state = StateOpen
+	b.openedAt = time.Now()
+	b.probesInFlight = 0
+	b.probeSuccesses = 0
+}
+
+func (b *Breaker) close() {
+	b.state = StateClosed
+	b.window = b.window[:0]
+	b.next = 0
+	b.probesInFlight = 0
+	b.probeSuccesses = 0
+}
@@ -0,0 +1,85 @@
+// Package egress keeps requests to URLs that users supplied, such as webhook
+// endpoints, from reaching the internal network.
+package egress
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const _dialTimeout = 10 * time.Second
+
+var (
+	ErrInsecureURL      = errors.New("url must be an https url")
+	ErrForbiddenAddress = errors.New("address is loopback, private, link-local or unspecified")
+)
+
+// CheckURL rejects a URL that isn't https or whose host is a forbidden address
+// or localhost. Other host names are only resolved when they are dialed.
+func CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || u.Hostname() == "" {
+		return ErrInsecureURL
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrForbiddenAddress
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && Forbidden(addr) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+// Forbidden reports whether an address belongs to the host or its network.
+func Forbidden(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsUnspecified()
+}
+
+// NewClient returns a client that only talks to https URLs on public
+// addresses. The address is checked when the connection is made, after the
+// host name was resolved, so a name that resolves to an internal address,
+// first or after a rebind, is refused too. Redirects are held to CheckURL.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: _dialTimeout, Control: control}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would be dialed instead of the target and hide its address
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return CheckURL(req.URL.String())
+		},
+	}
+}
+
+// control runs right before the dialer connects to a resolved address
+func control(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if Forbidden(addrPort.Addr()) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+	"top-up-api/pkg/redis"
+)
+
+const (
+	_keyPrefix = "idempotency:"
+
+	_defaultTTL         = 24 * time.Hour
+	_defaultLockTimeout = time.Minute
+)
+
+var (
+	ErrKeyReused  = errors.New("idempotency key was already used for a different request")
+	ErrInProgress = errors.New("a request with this idempotency key is still in progress")
+)
+
+// Response is what a request with an idempotency key answered, replayed for
+// every retry with the same key. Status is the HTTP status or the gRPC code.
+type Response struct {
+	Fingerprint string `json:"fingerprint"`
+	Completed   bool   `json:"completed"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Store keeps the responses of requests sent with an idempotency key in Redis.
+type Store struct {
+	redis       redis.Interface
+	ttl         time.Duration
+	lockTimeout time.Duration
+}
+
+// NewStore keeps responses for ttl. A key is held for lockTimeout while its
+// first request runs, so a request that never completes frees its key again.
+func NewStore(redis redis.Interface, ttl, lockTimeout time.Duration) *Store {
+	if ttl <= 0 {
+		ttl = _defaultTTL
+	}
+	if lockTimeout <= 0 {
+		lockTimeout = _defaultLockTimeout
+	}
+	return &Store{redis: redis, ttl: ttl, lockTimeout: lockTimeout}
+}
+
+// Begin claims key for the request with fingerprint. It returns nil when the
+// caller should run the request and then Complete or Release the key, and the
+// stored response when the same request already completed. A key used for
+// another request returns ErrKeyReused, one still running ErrInProgress.
+func (s *Store) Begin(ctx context.Context, key, fingerprint string) (*Response, error) {
+	pending, err := json.Marshal(Response{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, err
+	}
+	claimed, err := s.redis.SetNX(ctx, _keyPrefix+key, pending, s.lockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if claimed {
+		return nil, nil
+	}
+
+	stored, err := s.redis.Get(ctx, _keyPrefix+key)
+	if err != nil {
+		if errors.Is(err, redis.NotFound) {
+			// Released or expired since the claim failed, the retry claims it again
+			return nil, ErrInProgress
+		}
+		return nil, err
+	}
+	var response Response
+	if err := json.Unmarshal([]byte(stored), &response); err != nil {
+		return nil, err
+	}
+	if response.Fingerprint != fingerprint {
+		return nil, ErrKeyReused
+	}
+	if !response.Completed {
+		return nil, ErrInProgress
+	}
+	return &response, nil
+}
+
+// Complete stores the response of the request that claimed key.
+func (s *Store) Complete(ctx context.Context, key string, response Response) error {
+	response.Completed = true
+	payload, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	return s.redis.Set(ctx, _keyPrefix+key, payload, s.ttl)
+}
+
+// Release frees key without a response, so a retry runs the request again.
+func (s *Store) Release(ctx context.Context, key string) error {
+	return s.redis.Del(ctx, _keyPrefix+key)
+}
+
+// ScopedKey scopes the idempotency key a client sent to the credentials of the
+// caller, e.g. its bearer token. Callers never share a key, so nobody claims a
+// key ahead of another caller or gets a response replayed that was made for
+// someone else. Credentials are only kept hashed.
+func ScopedKey(channel, key string, credentials ...[]byte) string {
+	return channel + ":" + Fingerprint(credentials...) + ":" + key
+}
+
+// Fingerprint hashes the parts identifying a request, e.g. its method, path
+// and body. Every part is length prefixed so parts can't run into each other.
+func Fingerprint(parts ...[]byte) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		var length [8]byte
+		binary.BigEndian.PutUint64(length[:], uint64(len(part)))
+		hash.Write(length[:])
+		hash.Write(part)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,370 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// _lockKey is the Postgres advisory lock held while migrations run, so two
+// migrate processes never apply the same migration.
+const _lockKey = 7423509614
+
+const _createTableQuery = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version BIGINT PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+)`
+
+var (
+	_fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+	_name     = regexp.MustCompile(`^\w+$`)
+
+	ErrNoChange = errors.New("no change")
+)
+
+// Migration is a numbered schema change with the SQL to apply and revert it.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration and, when it was applied, the time it was applied at.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrator applies the migrations of a directory and records them in the
+// schema_migrations table.
+type Migrator struct {
+	db     *sql.DB
+	source fs.FS
+}
+
+func New(db *sql.DB, source fs.FS) *Migrator {
+	return &Migrator{db: db, source: source}
+}
+
+// Load reads every migration in the source ordered by version. Both the up and
+// the down file of a version must exist.
+func Load(source fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(source, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := _fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(source, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has files with different names: %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Create writes an empty up and down file for a new migration numbered after
+// the last one in dir, and returns their paths.
+func Create(dir, name string) (string, string, error) {
+	if !_name.MatchString(name) {
+		return "", "", fmt.Errorf("migration name %q may only contain letters, digits and underscores", name)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", "", err
+	}
+	migrations, err := Load(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+
+	version := int64(1)
+	if len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+	base := filepath.Join(dir, fmt.Sprintf("%06d_%s", version, name))
+	up, down := base+".up.sql", base+".down.sql"
+	if err := os.WriteFile(up, []byte("-- Write the schema change here\n"), 0o644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(down, []byte("-- Revert the schema change here\n"), 0o644); err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
+
+// Status lists every migration in the source with the time it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	migrations, err := Load(m.source)
+	if err != nil {
+		return nil, err
+	}
+	// Status only reads, a database that was never migrated has no table yet
+	var table sql.NullString
+	if err := m.db.QueryRowContext(ctx, "SELECT to_regclass('schema_migrations')::text").Scan(&table); err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]time.Time)
+	if table.Valid {
+		if applied, err = appliedVersions(ctx, m.db); err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]Status, len(migrations))
+	for i, migration := range migrations {
+		statuses[i] = Status{Migration: migration}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// Up applies pending migrations in version order, at most steps of them when
+// steps is positive. Each migration runs in its own transaction.
+func (m *Migrator) Up(ctx context.Context, steps int) ([]Migration, error) {
+	return m.run(ctx, func(migrations []Migration, applied map[int64]time.Time) []Migration {
+		return limit(pending(migrations, applied, 0), steps)
+	}, applyUp)
+}
+
+// Down reverts the last applied migrations, steps of them.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	return m.run(ctx, func(migrations []Migration, applied map[int64]time.Time) []Migration {
+		var done []Migration
+		for i := len(migrations) - 1; i >= 0; i-- {
+			if _, ok := applied[migrations[i].Version]; ok {
+				done = append(done, migrations[i])
+			}
+		}
+		return limit(done, steps)
+	}, applyDown)
+}
+
+// Baseline records the pending migrations up to version as applied without
+// running them. It adopts a database whose schema was created before it was
+// managed by migrations, so up only runs the migrations after version.
+func (m *Migrator) Baseline(ctx context.Context, version int64) ([]Migration, error) {
+	migrations, err := Load(m.source)
+	if err != nil {
+		return nil, err
+	}
+	if !containsVersion(migrations, version) {
+		return nil, fmt.Errorf("there is no migration %d to baseline at", version)
+	}
+
+	return m.run(ctx, func(migrations []Migration, applied map[int64]time.Time) []Migration {
+		return pending(migrations, applied, version)
+	}, record)
+}
+
+func (m *Migrator) run(ctx context.Context, choose func([]Migration, map[int64]time.Time) []Migration, apply func(context.Context, *sql.Conn, Migration) error) ([]Migration, error) {
+	migrations, err := Load(m.source)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", _lockKey); err != nil {
+		return nil, fmt.Errorf("failed to lock schema migrations: %w", err)
+	}
+	defer conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", _lockKey)
+
+	if _, err := conn.ExecContext(ctx, _createTableQuery); err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	for version := range applied {
+		if !containsVersion(migrations, version) {
+			return nil, fmt.Errorf("applied migration %d has no file in the migrations directory", version)
+		}
+	}
+
+	chosen := choose(migrations, applied)
+	if len(chosen) == 0 {
+		return nil, ErrNoChange
+	}
+
+	var done []Migration
+	for _, migration := range chosen {
+		if err := apply(ctx, conn, migration); err != nil {
+			return done, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+func applyUp(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	return apply(ctx, conn, migration.Up, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", migration.Version, migration.Name)
+}
+
+func applyDown(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	return apply(ctx, conn, migration.Down, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+}
+
+// record marks a migration as applied without running it
+func record(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	return apply(ctx, conn, "", "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", migration.Version, migration.Name)
+}
+
+// apply runs the SQL of one migration and records it in the same transaction,
+// so a failed migration leaves neither the schema change nor the record behind.
+func apply(ctx context.Context, conn *sql.Conn, query, recordQuery string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if query != "" {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, recordQuery, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func appliedVersions(ctx context.Context, db queryer) (map[int64]time.Time, error) {
+	rows, err := db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+func containsVersion(migrations []Migration, version int64) bool {
+	for _, migration := range migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// pending returns the migrations that weren't applied in version order, only
+// those up to version when version is positive.
+func pending(migrations []Migration, applied map[int64]time.Time, version int64) []Migration {
+	var result []Migration
+	for _, migration := range migrations {
+		if version > 0 && migration.Version > version {
+			break
+		}
+		if _, ok := applied[migration.Version]; !ok {
+			result = append(result, migration)
+		}
+	}
+	return result
+}
+
+func limit(migrations []Migration, steps int) []Migration {
+	if steps > 0 && steps < len(migrations) {
+		return migrations[:steps]
+	}
+	return migrations
+}
+
+// Seed runs every .sql file of the source in name order inside one transaction.
+// Seeding is never part of a migration, it only runs when asked for.
+func Seed(ctx context.Context, db *sql.DB, source fs.FS) ([]string, error) {
+	entries, err := fs.ReadDir(source, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		content, err := fs.ReadFile(source, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, string(content)); err != nil {
+			return nil, fmt.Errorf("seed %s: %w", entry.Name(), err)
+		}
+		files = append(files, entry.Name())
+	}
+	return files, tx.Commit()
+}
@@ -0,0 +1,95 @@
+// Package phone normalizes Vietnamese mobile numbers and tells the carrier of
+// a number from its prefix.
+package phone
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// Carrier codes, they are the codes of the carriers in the supplier table.
+const (
+	CarrierViettel      = "VTL"
+	CarrierMobiFone     = "MBF"
+	CarrierVinaPhone    = "VNP"
+	CarrierVietnamobile = "VNM"
+	CarrierGmobile      = "GML"
+	CarrierItelecom     = "ITL"
+	CarrierWintel       = "WNT"
+)
+
+var (
+	// ErrInvalidNumber rejects anything but a 10 digit Vietnamese mobile number.
+	ErrInvalidNumber = errors.New("invalid Vietnamese mobile number")
+	// ErrUnknownCarrier rejects a mobile number whose prefix no carrier uses.
+	ErrUnknownCarrier = errors.New("phone number doesn't belong to a known carrier")
+)
+
+// _carrierPrefixes maps the first three digits of a national number to its
+// carrier. Numbers moved to another carrier keep their prefix, so a ported
+// number is reported under the carrier it left.
+var _carrierPrefixes = map[string]string{
+	"032": CarrierViettel, "033": CarrierViettel, "034": CarrierViettel, "035": CarrierViettel,
+	"036": CarrierViettel, "037": CarrierViettel, "038": CarrierViettel, "039": CarrierViettel,
+	"086": CarrierViettel, "096": CarrierViettel, "097": CarrierViettel, "098": CarrierViettel,
+
+	"070": CarrierMobiFone, "076": CarrierMobiFone, "077": CarrierMobiFone, "078": CarrierMobiFone,
+	"079": CarrierMobiFone, "089": CarrierMobiFone, "090": CarrierMobiFone, "093": CarrierMobiFone,
+
+	"081": CarrierVinaPhone, "082": CarrierVinaPhone, "083": CarrierVinaPhone, "084": CarrierVinaPhone,
+	"085": CarrierVinaPhone, "088": CarrierVinaPhone, "091": CarrierVinaPhone, "094": CarrierVinaPhone,
+
+	"052": CarrierVietnamobile, "056": CarrierVietnamobile, "058": CarrierVietnamobile, "092": CarrierVietnamobile,
+
+	"059": CarrierGmobile, "099": CarrierGmobile,
+
+	"087": CarrierItelecom,
+
+	"055": CarrierWintel,
+}
+
+var (
+	_separators   = strings.NewReplacer(" ", "", ".", "", "-", "", "(", "", ")", "")
+	_mobileNumber = regexp.MustCompile(`^0[35789][0-9]{8}$`)
+)
+
+// Normalize returns the number in its national form, a 0 followed by 9 digits.
+// It accepts the +84, 0084 and 84 country prefixes and ignores spaces, dots,
+// dashes and parentheses.
+func Normalize(number string) (string, error) {
+	number = _separators.Replace(strings.TrimSpace(number))
+	switch {
+	case strings.HasPrefix(number, "+84"):
+		number = "0" + number[3:]
+	case strings.HasPrefix(number, "0084"):
+		number = "0" + number[4:]
+	case strings.HasPrefix(number, "84") && len(number) == 11:
+		number = "0" + number[2:]
+	}
+
+	if !_mobileNumber.MatchString(number) {
+		return "", ErrInvalidNumber
+	}
+	return number, nil
+}
+
+// Carrier returns the carrier code of a number, which doesn't need to be
+// normalized.
+func Carrier(number string) (string, error) {
+	number, err := Normalize(number)
+	if err != nil {
+		return "", err
+	}
+	carrier, ok := _carrierPrefixes[number[:3]]
+	if !ok {
+		return "", ErrUnknownCarrier
+	}
+	return carrier, nil
+}
+
+// Valid reports whether the number is a Vietnamese mobile number.
+func Valid(number string) bool {
+	_, err := Normalize(number)
+	return err == nil
+}
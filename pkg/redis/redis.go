@@ -2,24 +2,67 @@ package redis
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"time"
 	"top-up-api/config"
 
 	"github.com/redis/go-redis/v9"
 )
 
-const (
-	_timeOut = 5 * time.Minute
+// _fenceKey counts lock acquisitions. It is shared by every lock key, so it is
+// the only key that never expires and fencing tokens only ever grow.
+const _fenceKey = "lock:fence"
+
+var (
+	NotFound = redis.Nil
+
+	ErrLockNotHeld = errors.New("lock is not held")
+)
+
+var (
+	_acquireScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0`)
+
+	_releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+	_renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
 )
 
-var NotFound = redis.Nil
+// Lock is a lock held by the owner of Token. Fence grows with every acquisition,
+// writes made under the lock pass it on so storage can reject the writes of an
+// owner whose lease expired meanwhile.
+type Lock struct {
+	Key   string
+	Token string
+	Fence int64
+}
 
 type Interface interface {
 	Get(ctx context.Context, key string) (string, error)
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
 	Del(ctx context.Context, key string) error
-	ReleaseLock(ctx context.Context, key string) error
-	TryAcquireLock(ctx context.Context, key string, timeout time.Duration) error
+	// SetNX sets key only when it doesn't exist yet and reports whether it did.
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error)
+	// ReleaseLock returns ErrLockNotHeld when the lease of lock already expired.
+	ReleaseLock(ctx context.Context, lock *Lock) error
+	// RenewLock extends the lease of lock to ttl, ErrLockNotHeld when it expired.
+	RenewLock(ctx context.Context, lock *Lock, ttl time.Duration) error
+	TryAcquireLock(ctx context.Context, key string, ttl time.Duration) (*Lock, error)
+	Publish(ctx context.Context, channel string, message interface{}) error
+	Subscribe(ctx context.Context, channel string) (<-chan string, error)
 }
 
 type redisClient struct {
@@ -48,49 +91,142 @@ func (r *redisClient) Del(ctx context.Context, key string) error {
 	return r.Client.Del(ctx, key).Err()
 }
 
-func (r *redisClient) getLock(ctx context.Context, encodeKey string) (bool, error) {
-	wasSet, err := r.Client.SetNX(ctx, encodeKey, 1, _timeOut).Result()
-	return wasSet, err
+func (r *redisClient) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	return r.Client.SetNX(ctx, key, value, expiration).Result()
 }
 
-func (r *redisClient) ReleaseLock(ctx context.Context, key string) error {
-	encodeKey := getEncodeKey(key)
-	releaseChannel := getReleashKey(encodeKey)
-	err := r.Client.Del(ctx, encodeKey).Err()
+func (r *redisClient) Publish(ctx context.Context, channel string, message interface{}) error {
+	return r.Client.Publish(ctx, channel, message).Err()
+}
+
+// Subscribe listens on channel until ctx is done. The subscription is active
+// when Subscribe returns, and the returned channel is closed once it ends.
+func (r *redisClient) Subscribe(ctx context.Context, channel string) (<-chan string, error) {
+	pubsub := r.Client.Subscribe(ctx, channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	messages := make(chan string)
+	go func() {
+		defer close(messages)
+		defer pubsub.Close()
+
+		received := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-received:
+				if !ok {
+					return
+				}
+				select {
+				case messages <- msg.Payload:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return messages, nil
+}
+
+func (r *redisClient) getLock(ctx context.Context, encodeKey, token string, ttl time.Duration) (int64, error) {
+	return _acquireScript.Run(ctx, r.Client, []string{encodeKey, _fenceKey}, token, ttl.Milliseconds()).Int64()
+}
+
+// ReleaseLock deletes the lock only while lock still owns it, so a holder whose
+// lease expired can't release the lock of the next owner.
+func (r *redisClient) ReleaseLock(ctx context.Context, lock *Lock) error {
+	encodeKey := getEncodeKey(lock.Key)
+	released, err := _releaseScript.Run(ctx, r.Client, []string{encodeKey}, lock.Token).Int64()
 	if err != nil {
 		return err
 	}
+	if released == 0 {
+		return ErrLockNotHeld
+	}
+
+	return r.Client.Publish(ctx, getReleashKey(encodeKey), "released").Err()
+}
 
-	return r.Client.Publish(ctx, releaseChannel, "released").Err()
+func (r *redisClient) RenewLock(ctx context.Context, lock *Lock, ttl time.Duration) error {
+	renewed, err := _renewScript.Run(ctx, r.Client, []string{getEncodeKey(lock.Key)}, lock.Token, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if renewed == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
 }
 
-func (r *redisClient) TryAcquireLock(ctx context.Context, key string, timeout time.Duration) error {
-	expireTime := time.Now().Add(timeout)
+// TryAcquireLock waits for key to be free, at most for ttl since every lease
+// ends by then unless it is renewed, and leases it for ttl.
+func (r *redisClient) TryAcquireLock(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	expireTime := time.Now().Add(ttl)
 	encodeKey := getEncodeKey(key)
 	releaseChannel := getReleashKey(encodeKey)
 
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+
 	pubsub := r.Client.Subscribe(ctx, releaseChannel)
 	defer pubsub.Close()
 
 	for {
-		ok, err := r.getLock(ctx, encodeKey)
+		fence, err := r.getLock(ctx, encodeKey, token, ttl)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		if ok {
-			return nil
+		if fence > 0 {
+			return &Lock{Key: key, Token: token, Fence: fence}, nil
 		}
 
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return nil, ctx.Err()
 		case <-time.After(time.Until(expireTime)):
-			return context.DeadlineExceeded
+			return nil, context.DeadlineExceeded
 		case <-pubsub.Channel():
 		}
 	}
 }
 
+// KeepAlive renews the lease of lock to ttl every third of ttl, for operations
+// that may outlast one lease. It stops once the returned function is called, ctx
+// is done or a renewal fails, the fencing token then guards the writes.
+func KeepAlive(ctx context.Context, client Interface, lock *Lock, ttl time.Duration) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := client.RenewLock(ctx, lock, ttl); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return cancel
+}
+
+func newLockToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
 func getEncodeKey(key string) string {
 	return "lock:" + key
 }
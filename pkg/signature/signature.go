@@ -0,0 +1,78 @@
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformed = errors.New("malformed signature header")
+	ErrMismatch  = errors.New("signature does not match")
+	ErrExpired   = errors.New("signature timestamp outside tolerance")
+)
+
+// Sign returns the hex HMAC-SHA256 of "<unix timestamp>.<payload>", so a
+// signature can't be replayed with another timestamp.
+func Sign(secret string, timestamp time.Time, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Header formats the signature header value "t=<unix timestamp>,v1=<signature>".
+func Header(secret string, timestamp time.Time, payload []byte) string {
+	return fmt.Sprintf("t=%d,v1=%s", timestamp.Unix(), Sign(secret, timestamp, payload))
+}
+
+// Verify checks a header made by Header against the payload. Timestamps further
+// than tolerance from now are rejected, a zero tolerance accepts any timestamp.
+func Verify(secret, header string, payload []byte, tolerance time.Duration, now time.Time) (time.Time, error) {
+	timestamp, signature, err := parseHeader(header)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if tolerance > 0 {
+		if age := now.Sub(timestamp); age > tolerance || age < -tolerance {
+			return time.Time{}, ErrExpired
+		}
+	}
+
+	expected := Sign(secret, timestamp, payload)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return time.Time{}, ErrMismatch
+	}
+	return timestamp, nil
+}
+
+func parseHeader(header string) (time.Time, string, error) {
+	var timestamp time.Time
+	var signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return time.Time{}, "", ErrMalformed
+		}
+		switch key {
+		case "t":
+			unix, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return time.Time{}, "", ErrMalformed
+			}
+			timestamp = time.Unix(unix, 0)
+		case "v1":
+			signature = value
+		}
+	}
+	if timestamp.IsZero() || signature == "" {
+		return time.Time{}, "", ErrMalformed
+	}
+	return timestamp, signature, nil
+}
@@ -2,6 +2,7 @@ package validator
 
 import (
 	"fmt"
+	"top-up-api/pkg/phone"
 
 	"github.com/go-playground/locales/en"
 	ut "github.com/go-playground/universal-translator"
@@ -77,6 +78,18 @@ func NewValidator() *Validator {
 		return t
 	})
 
+	// Register custom validation for Vietnamese mobile numbers
+	v.RegisterValidation("vnphone", func(fl validator.FieldLevel) bool {
+		return phone.Valid(fl.Field().String())
+	})
+
+	v.RegisterTranslation("vnphone", trans, func(ut ut.Translator) error {
+		return ut.Add("vnphone", "{0} must be a Vietnamese mobile number", true)
+	}, func(ut ut.Translator, fe validator.FieldError) string {
+		t, _ := ut.T("vnphone", fe.Field())
+		return t
+	})
+
 	return &Validator{validator: v, translator: trans}
 }
 
@@ -149,6 +149,104 @@ func (x *OrderProcessResponse) GetError() string {
 	return ""
 }
 
+type OrderQueryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	OrderId       uint64                 `protobuf:"varint,1,opt,name=order_id,json=orderId,proto3" json:"order_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OrderQueryRequest) Reset() {
+	*x = OrderQueryRequest{}
+	mi := &file_provider_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OrderQueryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OrderQueryRequest) ProtoMessage() {}
+
+func (x *OrderQueryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_provider_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OrderQueryRequest.ProtoReflect.Descriptor instead.
+func (*OrderQueryRequest) Descriptor() ([]byte, []int) {
+	return file_provider_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *OrderQueryRequest) GetOrderId() uint64 {
+	if x != nil {
+		return x.OrderId
+	}
+	return 0
+}
+
+// status is success or failed once the provider settled the top-up, processing
+// while it still runs and not_found when the provider never got the order.
+type OrderQueryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OrderQueryResponse) Reset() {
+	*x = OrderQueryResponse{}
+	mi := &file_provider_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OrderQueryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OrderQueryResponse) ProtoMessage() {}
+
+func (x *OrderQueryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_provider_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OrderQueryResponse.ProtoReflect.Descriptor instead.
+func (*OrderQueryResponse) Descriptor() ([]byte, []int) {
+	return file_provider_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *OrderQueryResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *OrderQueryResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
 var File_provider_proto protoreflect.FileDescriptor
 
 const file_provider_proto_rawDesc = "" +
@@ -163,9 +261,16 @@ const file_provider_proto_rawDesc = "" +
 	"\rcall_back_url\x18\x05 \x01(\tR\vcallBackUrl\"F\n" +
 	"\x14OrderProcessResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x14\n" +
-	"\x05error\x18\x02 \x01(\tR\x05error2Z\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\".\n" +
+	"\x11OrderQueryRequest\x12\x19\n" +
+	"\border_id\x18\x01 \x01(\x04R\aorderId\"B\n" +
+	"\x12OrderQueryResponse\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error2\x9d\x01\n" +
 	"\x0fProviderService\x12G\n" +
-	"\fProcessOrder\x12\x1a.order.OrderProcessRequest\x1a\x1b.order.OrderProcessResponseB\x1bZ\x19proto/provider;providerpbb\x06proto3"
+	"\fProcessOrder\x12\x1a.order.OrderProcessRequest\x1a\x1b.order.OrderProcessResponse\x12A\n" +
+	"\n" +
+	"QueryOrder\x12\x18.order.OrderQueryRequest\x1a\x19.order.OrderQueryResponseB\x1bZ\x19proto/provider;providerpbb\x06proto3"
 
 var (
 	file_provider_proto_rawDescOnce sync.Once
@@ -179,16 +284,20 @@ func file_provider_proto_rawDescGZIP() []byte {
 	return file_provider_proto_rawDescData
 }
 
-var file_provider_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_provider_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
 var file_provider_proto_goTypes = []any{
 	(*OrderProcessRequest)(nil),  // 0: order.OrderProcessRequest
 	(*OrderProcessResponse)(nil), // 1: order.OrderProcessResponse
+	(*OrderQueryRequest)(nil),    // 2: order.OrderQueryRequest
+	(*OrderQueryResponse)(nil),   // 3: order.OrderQueryResponse
 }
 var file_provider_proto_depIdxs = []int32{
 	0, // 0: order.ProviderService.ProcessOrder:input_type -> order.OrderProcessRequest
-	1, // 1: order.ProviderService.ProcessOrder:output_type -> order.OrderProcessResponse
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
+	2, // 1: order.ProviderService.QueryOrder:input_type -> order.OrderQueryRequest
+	1, // 2: order.ProviderService.ProcessOrder:output_type -> order.OrderProcessResponse
+	3, // 3: order.ProviderService.QueryOrder:output_type -> order.OrderQueryResponse
+	2, // [2:4] is the sub-list for method output_type
+	0, // [0:2] is the sub-list for method input_type
 	0, // [0:0] is the sub-list for extension type_name
 	0, // [0:0] is the sub-list for extension extendee
 	0, // [0:0] is the sub-list for field type_name
@@ -205,7 +314,7 @@ func file_provider_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_provider_proto_rawDesc), len(file_provider_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   2,
+			NumMessages:   4,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
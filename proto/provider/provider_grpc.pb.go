@@ -20,6 +20,7 @@ const _ = grpc.SupportPackageIsVersion9
 
 const (
 	ProviderService_ProcessOrder_FullMethodName = "/order.ProviderService/ProcessOrder"
+	ProviderService_QueryOrder_FullMethodName   = "/order.ProviderService/QueryOrder"
 )
 
 // ProviderServiceClient is the client API for ProviderService service.
@@ -27,6 +28,7 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type ProviderServiceClient interface {
 	ProcessOrder(ctx context.Context, in *OrderProcessRequest, opts ...grpc.CallOption) (*OrderProcessResponse, error)
+	QueryOrder(ctx context.Context, in *OrderQueryRequest, opts ...grpc.CallOption) (*OrderQueryResponse, error)
 }
 
 type providerServiceClient struct {
@@ -47,11 +49,22 @@ func (c *providerServiceClient) ProcessOrder(ctx context.Context, in *OrderProce
 	return out, nil
 }
 
+func (c *providerServiceClient) QueryOrder(ctx context.Context, in *OrderQueryRequest, opts ...grpc.CallOption) (*OrderQueryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OrderQueryResponse)
+	err := c.cc.Invoke(ctx, ProviderService_QueryOrder_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ProviderServiceServer is the server API for ProviderService service.
 // All implementations must embed UnimplementedProviderServiceServer
 // for forward compatibility.
 type ProviderServiceServer interface {
 	ProcessOrder(context.Context, *OrderProcessRequest) (*OrderProcessResponse, error)
+	QueryOrder(context.Context, *OrderQueryRequest) (*OrderQueryResponse, error)
 	mustEmbedUnimplementedProviderServiceServer()
 }
 
@@ -65,6 +78,9 @@ type UnimplementedProviderServiceServer struct{}
 func (UnimplementedProviderServiceServer) ProcessOrder(context.Context, *OrderProcessRequest) (*OrderProcessResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ProcessOrder not implemented")
 }
+func (UnimplementedProviderServiceServer) QueryOrder(context.Context, *OrderQueryRequest) (*OrderQueryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QueryOrder not implemented")
+}
 func (UnimplementedProviderServiceServer) mustEmbedUnimplementedProviderServiceServer() {}
 func (UnimplementedProviderServiceServer) testEmbeddedByValue()                         {}
 
@@ -104,6 +120,24 @@ func _ProviderService_ProcessOrder_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ProviderService_QueryOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OrderQueryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProviderServiceServer).QueryOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProviderService_QueryOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProviderServiceServer).QueryOrder(ctx, req.(*OrderQueryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ProviderService_ServiceDesc is the grpc.ServiceDesc for ProviderService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -115,6 +149,10 @@ var ProviderService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ProcessOrder",
 			Handler:    _ProviderService_ProcessOrder_Handler,
 		},
+		{
+			MethodName: "QueryOrder",
+			Handler:    _ProviderService_QueryOrder_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "provider.proto",
@@ -0,0 +1,19 @@
+DROP TABLE IF EXISTS provider_attempts;
+DROP TABLE IF EXISTS outbox_messages;
+DROP TABLE IF EXISTS order_status_events;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS purchase_history;
+DROP TABLE IF EXISTS provider_suppliers;
+DROP TABLE IF EXISTS provider;
+DROP TABLE IF EXISTS sku;
+DROP TABLE IF EXISTS cash_back;
+DROP TABLE IF EXISTS supplier;
+
+DROP TYPE IF EXISTS outbox_status;
+DROP TYPE IF EXISTS outbox_channel;
+DROP TYPE IF EXISTS order_status_event_source;
+DROP TYPE IF EXISTS purchase_history_status;
+DROP TYPE IF EXISTS routing_strategy;
+DROP TYPE IF EXISTS supplier_status;
+DROP TYPE IF EXISTS provider_type;
+DROP TYPE IF EXISTS cash_back_type;
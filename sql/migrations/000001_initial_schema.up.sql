@@ -0,0 +1,141 @@
+-- Baseline of the schema that AutoMigrate used to create. A database AutoMigrate
+-- created already has it and is adopted with `migrate baseline 1` instead.
+
+CREATE TYPE cash_back_type AS ENUM ('percentage', 'fixed');
+CREATE TYPE provider_type AS ENUM ('http', 'grcp');
+CREATE TYPE supplier_status AS ENUM ('active', 'inactive');
+CREATE TYPE routing_strategy AS ENUM ('weighted_random', 'least_cost', 'lowest_latency', 'round_robin', 'sticky_phone_prefix');
+CREATE TYPE purchase_history_status AS ENUM ('pending', 'confirm', 'success', 'failed');
+CREATE TYPE order_status_event_source AS ENUM ('http', 'grpc', 'kafka', 'provider_callback', 'dispatcher');
+CREATE TYPE outbox_channel AS ENUM ('http', 'kafka');
+CREATE TYPE outbox_status AS ENUM ('pending', 'delivered', 'dead');
+
+CREATE TABLE supplier (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    code TEXT NOT NULL CONSTRAINT uni_supplier_code UNIQUE,
+    name TEXT NOT NULL CONSTRAINT uni_supplier_name UNIQUE,
+    logo_url TEXT NOT NULL,
+    status supplier_status NOT NULL,
+    routing_strategy routing_strategy NOT NULL DEFAULT 'weighted_random'
+);
+CREATE INDEX idx_supplier_deleted_at ON supplier (deleted_at);
+
+CREATE TABLE cash_back (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    code TEXT NOT NULL CONSTRAINT uni_cash_back_code UNIQUE,
+    type cash_back_type NOT NULL,
+    value BIGINT NOT NULL
+);
+CREATE INDEX idx_cash_back_deleted_at ON cash_back (deleted_at);
+
+CREATE TABLE sku (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    supplier_code TEXT NOT NULL CONSTRAINT fk_sku_supplier REFERENCES supplier (code),
+    cash_back_code TEXT DEFAULT NULL CONSTRAINT fk_sku_cash_back REFERENCES cash_back (code),
+    price BIGINT NOT NULL
+);
+CREATE INDEX idx_sku_deleted_at ON sku (deleted_at);
+
+CREATE TABLE provider (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    code TEXT NOT NULL CONSTRAINT uni_provider_code UNIQUE,
+    source TEXT,
+    type TEXT,
+    weight BIGINT,
+    discount NUMERIC NOT NULL DEFAULT 0
+);
+CREATE INDEX idx_provider_deleted_at ON provider (deleted_at);
+
+CREATE TABLE provider_suppliers (
+    provider_id BIGINT NOT NULL CONSTRAINT fk_provider_suppliers_provider REFERENCES provider (id),
+    supplier_id BIGINT NOT NULL CONSTRAINT fk_provider_suppliers_supplier REFERENCES supplier (id),
+    PRIMARY KEY (provider_id, supplier_id)
+);
+
+CREATE TABLE purchase_history (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    order_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    sku_id BIGINT NOT NULL CONSTRAINT fk_purchase_history_sku REFERENCES sku (id),
+    total_price BIGINT NOT NULL,
+    phone_number TEXT NOT NULL,
+    cash_back_value BIGINT DEFAULT 0,
+    status purchase_history_status NOT NULL
+);
+CREATE INDEX idx_purchase_history_deleted_at ON purchase_history (deleted_at);
+
+CREATE TABLE orders (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    order_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    sku_id BIGINT NOT NULL CONSTRAINT fk_orders_sku REFERENCES sku (id),
+    total_price BIGINT NOT NULL,
+    phone_number TEXT NOT NULL,
+    cash_back_value BIGINT DEFAULT 0,
+    status purchase_history_status NOT NULL
+);
+CREATE INDEX idx_orders_deleted_at ON orders (deleted_at);
+CREATE UNIQUE INDEX idx_orders_order_id ON orders (order_id);
+CREATE INDEX idx_orders_user_id ON orders (user_id);
+
+CREATE TABLE order_status_events (
+    id BIGSERIAL PRIMARY KEY,
+    order_id BIGINT NOT NULL,
+    source order_status_event_source NOT NULL,
+    previous_status purchase_history_status,
+    status purchase_history_status NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_order_status_events_order_id ON order_status_events (order_id);
+
+CREATE TABLE outbox_messages (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    aggregate_id BIGINT NOT NULL,
+    event_type TEXT NOT NULL,
+    channel outbox_channel NOT NULL,
+    destination TEXT NOT NULL,
+    method TEXT,
+    message_key TEXT,
+    payload TEXT NOT NULL,
+    status outbox_status NOT NULL DEFAULT 'pending',
+    attempts BIGINT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    last_error TEXT,
+    delivered_at TIMESTAMPTZ
+);
+CREATE INDEX idx_outbox_messages_deleted_at ON outbox_messages (deleted_at);
+CREATE INDEX idx_outbox_messages_aggregate_id ON outbox_messages (aggregate_id);
+CREATE INDEX idx_outbox_status_next_attempt ON outbox_messages (status, next_attempt_at);
+
+CREATE TABLE provider_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    order_id BIGINT NOT NULL,
+    provider_code TEXT NOT NULL,
+    attempt BIGINT NOT NULL,
+    success BOOLEAN NOT NULL,
+    error TEXT,
+    duration_ms BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_provider_attempts_order_id ON provider_attempts (order_id);
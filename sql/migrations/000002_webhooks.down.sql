@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_subscriptions;
+DROP TYPE IF EXISTS webhook_delivery_status;
+
+-- Postgres can't drop a single enum value, so only the webhook messages are
+-- removed and 'webhook' stays in outbox_channel.
+DELETE FROM outbox_messages WHERE channel = 'webhook';
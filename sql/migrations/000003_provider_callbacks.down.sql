@@ -0,0 +1,3 @@
+ALTER TABLE orders DROP COLUMN provider_code;
+
+ALTER TABLE provider DROP COLUMN callback_secret;
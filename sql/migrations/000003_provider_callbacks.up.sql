@@ -0,0 +1,13 @@
+ALTER TABLE provider ADD COLUMN callback_secret TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE orders ADD COLUMN provider_code TEXT NOT NULL DEFAULT '';
+
+-- Orders in flight keep accepting callbacks from the provider they were last sent to
+UPDATE orders
+SET provider_code = last_attempt.provider_code
+FROM (
+    SELECT DISTINCT ON (order_id) order_id, provider_code
+    FROM provider_attempts
+    ORDER BY order_id, created_at DESC, id DESC
+) AS last_attempt
+WHERE last_attempt.order_id = orders.order_id;
@@ -0,0 +1,3 @@
+DROP INDEX uni_purchase_history_payment_reference;
+
+ALTER TABLE purchase_history DROP COLUMN payment_reference;
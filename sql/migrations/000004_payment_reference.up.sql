@@ -0,0 +1,4 @@
+ALTER TABLE purchase_history ADD COLUMN payment_reference TEXT NOT NULL DEFAULT '';
+
+-- One payment confirms one order, rows written before references were required keep an empty one
+CREATE UNIQUE INDEX uni_purchase_history_payment_reference ON purchase_history (payment_reference) WHERE payment_reference <> '';
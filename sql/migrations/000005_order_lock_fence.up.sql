@@ -0,0 +1,2 @@
+-- Fencing token of the last lock holder that changed the order's status
+ALTER TABLE orders ADD COLUMN lock_fence BIGINT NOT NULL DEFAULT 0;
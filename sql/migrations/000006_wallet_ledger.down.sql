@@ -0,0 +1,7 @@
+ALTER TABLE orders DROP COLUMN wallet_amount;
+
+DROP TABLE IF EXISTS ledger_entries;
+DROP TABLE IF EXISTS ledger_transactions;
+DROP TABLE IF EXISTS ledger_accounts;
+DROP TYPE IF EXISTS ledger_transaction_type;
+DROP TYPE IF EXISTS ledger_account_type;
@@ -0,0 +1,40 @@
+CREATE TYPE ledger_account_type AS ENUM ('wallet', 'cashback', 'order_payment');
+CREATE TYPE ledger_transaction_type AS ENUM ('cashback', 'cashback_reversal', 'order_payment', 'order_refund');
+
+-- System accounts have a user_id of 0, every user has one wallet
+CREATE TABLE ledger_accounts (
+    id BIGSERIAL PRIMARY KEY,
+    type ledger_account_type NOT NULL,
+    user_id BIGINT NOT NULL DEFAULT 0,
+    balance BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX uni_ledger_accounts_type_user_id ON ledger_accounts (type, user_id);
+
+INSERT INTO ledger_accounts (type, user_id, balance, created_at, updated_at) VALUES
+    ('cashback', 0, 0, NOW(), NOW()),
+    ('order_payment', 0, 0, NOW(), NOW());
+
+-- An order is credited, paid or refunded at most once
+CREATE TABLE ledger_transactions (
+    id BIGSERIAL PRIMARY KEY,
+    type ledger_transaction_type NOT NULL,
+    order_id BIGINT NOT NULL,
+    created_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX uni_ledger_transactions_order_id_type ON ledger_transactions (order_id, type);
+
+CREATE TABLE ledger_entries (
+    id BIGSERIAL PRIMARY KEY,
+    transaction_id BIGINT NOT NULL CONSTRAINT fk_ledger_transactions_entries REFERENCES ledger_transactions (id),
+    account_id BIGINT NOT NULL CONSTRAINT fk_ledger_entries_account REFERENCES ledger_accounts (id),
+    amount BIGINT NOT NULL,
+    balance_after BIGINT NOT NULL,
+    created_at TIMESTAMPTZ
+);
+CREATE INDEX idx_ledger_entries_transaction_id ON ledger_entries (transaction_id);
+CREATE INDEX idx_ledger_entries_account_id_id ON ledger_entries (account_id, id);
+
+-- Part of the order total paid from the user's wallet
+ALTER TABLE orders ADD COLUMN wallet_amount BIGINT NOT NULL DEFAULT 0;
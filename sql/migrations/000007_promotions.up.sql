@@ -0,0 +1,38 @@
+-- Cashback campaigns, the cash back rule of a sku stays the base every order gets
+CREATE TABLE promotions (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    code TEXT NOT NULL CONSTRAINT uni_promotions_code UNIQUE,
+    name TEXT NOT NULL,
+    type cash_back_type NOT NULL,
+    value BIGINT NOT NULL,
+    max_cash_back BIGINT NOT NULL DEFAULT 0,
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ,
+    supplier_codes JSONB NOT NULL DEFAULT '[]',
+    sku_ids JSONB NOT NULL DEFAULT '[]',
+    global_limit BIGINT NOT NULL DEFAULT 0,
+    per_user_limit BIGINT NOT NULL DEFAULT 0,
+    redemption_count BIGINT NOT NULL DEFAULT 0,
+    stackable BOOLEAN NOT NULL DEFAULT FALSE,
+    voucher BOOLEAN NOT NULL DEFAULT FALSE,
+    active BOOLEAN NOT NULL DEFAULT TRUE
+);
+CREATE INDEX idx_promotions_deleted_at ON promotions (deleted_at);
+
+-- A promotion applies to an order at most once, a failed order releases it
+CREATE TABLE promotion_redemptions (
+    id BIGSERIAL PRIMARY KEY,
+    promotion_id BIGINT NOT NULL CONSTRAINT fk_promotion_redemptions_promotion REFERENCES promotions (id),
+    order_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    code TEXT NOT NULL,
+    cash_back_value BIGINT NOT NULL,
+    released_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX uni_promotion_redemptions_promotion_id_order_id ON promotion_redemptions (promotion_id, order_id);
+CREATE INDEX idx_promotion_redemptions_promotion_id_user_id ON promotion_redemptions (promotion_id, user_id);
+CREATE INDEX idx_promotion_redemptions_order_id ON promotion_redemptions (order_id);
@@ -0,0 +1,22 @@
+DROP INDEX IF EXISTS idx_orders_pending_created_at;
+
+-- Enum values can't be dropped, the types are recreated without them. Expired
+-- orders are kept as failed ones.
+UPDATE orders SET status = 'failed' WHERE status = 'expired';
+UPDATE purchase_history SET status = 'failed' WHERE status = 'expired';
+UPDATE order_status_events SET status = 'failed' WHERE status = 'expired';
+UPDATE order_status_events SET source = 'dispatcher' WHERE source = 'expiry_sweeper';
+
+ALTER TYPE purchase_history_status RENAME TO purchase_history_status_old;
+CREATE TYPE purchase_history_status AS ENUM ('pending', 'confirm', 'success', 'failed');
+ALTER TABLE orders ALTER COLUMN status TYPE purchase_history_status USING status::text::purchase_history_status;
+ALTER TABLE purchase_history ALTER COLUMN status TYPE purchase_history_status USING status::text::purchase_history_status;
+ALTER TABLE order_status_events
+    ALTER COLUMN previous_status TYPE purchase_history_status USING previous_status::text::purchase_history_status,
+    ALTER COLUMN status TYPE purchase_history_status USING status::text::purchase_history_status;
+DROP TYPE purchase_history_status_old;
+
+ALTER TYPE order_status_event_source RENAME TO order_status_event_source_old;
+CREATE TYPE order_status_event_source AS ENUM ('http', 'grpc', 'kafka', 'provider_callback', 'dispatcher');
+ALTER TABLE order_status_events ALTER COLUMN source TYPE order_status_event_source USING source::text::order_status_event_source;
+DROP TYPE order_status_event_source_old;
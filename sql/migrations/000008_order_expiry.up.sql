@@ -0,0 +1,5 @@
+-- Pending orders that aren't paid in time are expired by the expiry sweeper
+ALTER TYPE purchase_history_status ADD VALUE 'expired';
+ALTER TYPE order_status_event_source ADD VALUE 'expiry_sweeper';
+
+CREATE INDEX idx_orders_pending_created_at ON orders (created_at) WHERE status = 'pending';
@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_orders_confirm_updated_at;
+
+DROP TABLE IF EXISTS order_reviews;
+DROP TYPE IF EXISTS order_review_status;
+
+-- Enum values can't be dropped, the type is recreated without them
+UPDATE order_status_events SET source = 'provider_callback' WHERE source IN ('reconciler', 'manual_review');
+
+ALTER TYPE order_status_event_source RENAME TO order_status_event_source_old;
+CREATE TYPE order_status_event_source AS ENUM ('http', 'grpc', 'kafka', 'provider_callback', 'dispatcher', 'expiry_sweeper');
+ALTER TABLE order_status_events ALTER COLUMN source TYPE order_status_event_source USING source::text::order_status_event_source;
+DROP TYPE order_status_event_source_old;
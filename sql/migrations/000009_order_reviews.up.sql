@@ -0,0 +1,23 @@
+-- Confirmed orders stuck without a provider callback are reconciled with their
+-- provider, those it can't settle are queued for manual review
+ALTER TYPE order_status_event_source ADD VALUE 'reconciler';
+ALTER TYPE order_status_event_source ADD VALUE 'manual_review';
+
+CREATE TYPE order_review_status AS ENUM ('open', 'resolved');
+
+CREATE TABLE order_reviews (
+    id BIGSERIAL PRIMARY KEY,
+    order_id BIGINT NOT NULL,
+    provider_code TEXT NOT NULL DEFAULT '',
+    reason TEXT NOT NULL,
+    status order_review_status NOT NULL DEFAULT 'open',
+    resolution purchase_history_status,
+    note TEXT NOT NULL DEFAULT '',
+    resolved_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX idx_order_reviews_order_id ON order_reviews (order_id);
+CREATE INDEX idx_order_reviews_status ON order_reviews (status);
+
+CREATE INDEX idx_orders_confirm_updated_at ON orders (updated_at) WHERE status = 'confirm';
@@ -0,0 +1,3 @@
+DROP INDEX idx_purchase_history_success_updated_at;
+
+ALTER TABLE purchase_history DROP COLUMN provider_code;
@@ -0,0 +1,9 @@
+-- Settlement reports group successful orders by the provider that handled them
+ALTER TABLE purchase_history ADD COLUMN provider_code TEXT NOT NULL DEFAULT '';
+
+UPDATE purchase_history
+SET provider_code = orders.provider_code
+FROM orders
+WHERE orders.order_id = purchase_history.order_id AND orders.provider_code <> '';
+
+CREATE INDEX idx_purchase_history_success_updated_at ON purchase_history (updated_at) WHERE status = 'success';
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_purchase_history_order_id;
+
+DROP TABLE IF EXISTS settlement_discrepancies;
+DROP TABLE IF EXISTS settlement_imports;
+DROP TYPE IF EXISTS settlement_discrepancy_status;
+DROP TYPE IF EXISTS settlement_discrepancy_type;
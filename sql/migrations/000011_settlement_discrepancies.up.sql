@@ -0,0 +1,36 @@
+-- Provider settlement files are diffed against the purchase history, finance
+-- resolves the discrepancies found
+CREATE TYPE settlement_discrepancy_type AS ENUM ('missing_ours', 'missing_theirs', 'amount_mismatch', 'status_mismatch');
+CREATE TYPE settlement_discrepancy_status AS ENUM ('open', 'resolved');
+
+CREATE TABLE settlement_imports (
+    id BIGSERIAL PRIMARY KEY,
+    provider_code TEXT NOT NULL,
+    day DATE NOT NULL,
+    file_name TEXT NOT NULL DEFAULT '',
+    row_count INT NOT NULL,
+    matched_count INT NOT NULL,
+    discrepancy_count INT NOT NULL,
+    created_at TIMESTAMPTZ
+);
+
+CREATE TABLE settlement_discrepancies (
+    id BIGSERIAL PRIMARY KEY,
+    import_id BIGINT NOT NULL CONSTRAINT fk_settlement_imports_discrepancies REFERENCES settlement_imports (id) ON DELETE CASCADE,
+    order_id BIGINT NOT NULL,
+    type settlement_discrepancy_type NOT NULL,
+    our_amount BIGINT,
+    their_amount BIGINT,
+    our_status TEXT NOT NULL DEFAULT '',
+    their_status TEXT NOT NULL DEFAULT '',
+    status settlement_discrepancy_status NOT NULL DEFAULT 'open',
+    note TEXT NOT NULL DEFAULT '',
+    resolved_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+CREATE INDEX idx_settlement_discrepancies_import_id ON settlement_discrepancies (import_id);
+CREATE INDEX idx_settlement_discrepancies_status ON settlement_discrepancies (status);
+
+-- Settlement file rows are matched to purchase histories by order id
+CREATE INDEX idx_purchase_history_order_id ON purchase_history (order_id);
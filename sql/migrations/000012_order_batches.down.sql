@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_orders_batch_undispatched;
+DROP INDEX IF EXISTS idx_orders_batch_id;
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS dispatched_at,
+    DROP COLUMN IF EXISTS batch_line,
+    DROP COLUMN IF EXISTS batch_id;
+
+DROP TABLE IF EXISTS order_batches;
@@ -0,0 +1,27 @@
+-- Business customers top up many phones in one batch. The batch is paid with a
+-- single payment, its lines are orders dispatched at a limited rate
+CREATE TABLE order_batches (
+    id BIGSERIAL PRIMARY KEY,
+    batch_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    total_price BIGINT NOT NULL,
+    cash_back_value BIGINT NOT NULL DEFAULT 0,
+    line_count INT NOT NULL,
+    status purchase_history_status NOT NULL,
+    payment_reference TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX idx_order_batches_batch_id ON order_batches (batch_id);
+CREATE INDEX idx_order_batches_user_id ON order_batches (user_id);
+CREATE INDEX idx_order_batches_pending_created_at ON order_batches (created_at) WHERE status = 'pending';
+-- One payment pays one batch
+CREATE UNIQUE INDEX uni_order_batches_payment_reference ON order_batches (payment_reference) WHERE payment_reference <> '';
+
+ALTER TABLE orders
+    ADD COLUMN batch_id BIGINT,
+    ADD COLUMN batch_line INT NOT NULL DEFAULT 0,
+    ADD COLUMN dispatched_at TIMESTAMPTZ;
+CREATE INDEX idx_orders_batch_id ON orders (batch_id);
+-- The paid batch orders waiting for the batch dispatcher
+CREATE INDEX idx_orders_batch_undispatched ON orders (updated_at, batch_line) WHERE batch_id IS NOT NULL AND status = 'confirm' AND dispatched_at IS NULL;
@@ -0,0 +1,9 @@
+ALTER TABLE order_status_events DROP COLUMN IF EXISTS reason;
+
+-- Enum values can't be dropped, the type is recreated without them
+UPDATE order_status_events SET source = 'manual_review' WHERE source = 'admin';
+
+ALTER TYPE order_status_event_source RENAME TO order_status_event_source_old;
+CREATE TYPE order_status_event_source AS ENUM ('http', 'grpc', 'kafka', 'provider_callback', 'dispatcher', 'expiry_sweeper', 'reconciler', 'manual_review');
+ALTER TABLE order_status_events ALTER COLUMN source TYPE order_status_event_source USING source::text::order_status_event_source;
+DROP TYPE order_status_event_source_old;
@@ -0,0 +1,5 @@
+-- Successful orders are only failed by an operator reversing them, the reason
+-- is kept on the order status event
+ALTER TYPE order_status_event_source ADD VALUE 'admin';
+
+ALTER TABLE order_status_events ADD COLUMN reason TEXT NOT NULL DEFAULT '';
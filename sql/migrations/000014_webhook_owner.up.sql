@@ -0,0 +1,7 @@
+-- A subscription only hears about the orders of the user that owns it.
+-- Subscriptions made before they had an owner are deactivated until an
+-- admin assigns them to their partner.
+ALTER TABLE webhook_subscriptions ADD COLUMN user_id BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE webhook_subscriptions ALTER COLUMN user_id DROP DEFAULT;
+UPDATE webhook_subscriptions SET active = FALSE WHERE user_id = 0;
+CREATE INDEX idx_webhook_subscriptions_user_id ON webhook_subscriptions (user_id);
@@ -0,0 +1,24 @@
+-- A payment pays either one order or one batch. The references of both are
+-- recorded in one table so a reference used by one can't be used by the other
+CREATE TABLE payment_references (
+    reference TEXT PRIMARY KEY,
+    order_id BIGINT,
+    batch_id BIGINT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((order_id IS NULL) <> (batch_id IS NULL))
+);
+
+INSERT INTO payment_references (reference, batch_id, created_at)
+SELECT payment_reference, batch_id, COALESCE(updated_at, NOW())
+FROM order_batches
+WHERE payment_reference <> ''
+ON CONFLICT (reference) DO NOTHING;
+
+-- The lines of a batch are recorded with the batch reference suffixed, only
+-- the orders paid on their own are taken
+INSERT INTO payment_references (reference, order_id, created_at)
+SELECT ph.payment_reference, ph.order_id, COALESCE(ph.created_at, NOW())
+FROM purchase_history ph
+JOIN orders o ON o.order_id = ph.order_id
+WHERE ph.payment_reference <> '' AND o.batch_id IS NULL
+ON CONFLICT (reference) DO NOTHING;
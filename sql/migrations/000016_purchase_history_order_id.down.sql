@@ -0,0 +1 @@
+DROP INDEX IF EXISTS uni_purchase_history_order_id;
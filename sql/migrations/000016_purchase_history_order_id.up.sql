@@ -0,0 +1,4 @@
+-- An order is recorded in the purchase history once, a second confirmation of
+-- the same order fails on the index. Duplicates written before have to be
+-- resolved by hand before this migration can run
+CREATE UNIQUE INDEX uni_purchase_history_order_id ON purchase_history (order_id);
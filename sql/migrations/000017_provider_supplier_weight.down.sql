@@ -0,0 +1,12 @@
+ALTER TABLE provider ADD COLUMN weight BIGINT;
+
+UPDATE provider p
+SET weight = ps.weight
+FROM (
+    SELECT provider_id, MAX(weight) AS weight
+    FROM provider_suppliers
+    GROUP BY provider_id
+) ps
+WHERE p.id = ps.provider_id;
+
+ALTER TABLE provider_suppliers DROP COLUMN weight;
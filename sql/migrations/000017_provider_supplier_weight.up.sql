@@ -0,0 +1,11 @@
+-- The weight is set per supplier a provider serves, a provider can take most
+-- of the traffic of one supplier and little of another. Existing assignments
+-- keep the weight their provider had
+ALTER TABLE provider_suppliers ADD COLUMN weight BIGINT NOT NULL DEFAULT 0;
+
+UPDATE provider_suppliers ps
+SET weight = COALESCE(p.weight, 0)
+FROM provider p
+WHERE p.id = ps.provider_id;
+
+ALTER TABLE provider DROP COLUMN weight;
@@ -0,0 +1,126 @@
+package circuitbreaker
+
+import (
+	"testing"
+	"time"
+	"top-up-api/pkg/circuitbreaker"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestBreaker() *circuitbreaker.Breaker {
+	return circuitbreaker.New(circuitbreaker.Config{
+		WindowSize:           4,
+		MinRequests:          4,
+		FailureRateThreshold: 0.5,
+		SlowCallThreshold:    100 * time.Millisecond,
+		OpenTimeout:          20 * time.Millisecond,
+		HalfOpenProbes:       1,
+	})
+}
+
+func TestBreaker_Opens(t *testing.T) {
+	tests := []struct {
+		name          string
+		calls         []bool
+		expectedState circuitbreaker.State
+	}{
+		{name: "stays closed below min requests", calls: []bool{false, false, false}, expectedState: circuitbreaker.StateClosed},
+		{name: "stays closed below failure rate", calls: []bool{true, true, true, false}, expectedState: circuitbreaker.StateClosed},
+		{name: "opens at failure rate", calls: []bool{true, false, true, false}, expectedState: circuitbreaker.StateOpen},
+		{name: "old successes leave the window", calls: []bool{true, true, true, false, true, true, false}, expectedState: circuitbreaker.StateOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker := newTestBreaker()
+			for _, success := range tt.calls {
+				if breaker.Allow() {
+					breaker.Record(success, time.Millisecond)
+				}
+			}
+			assert.Equal(t, tt.expectedState, breaker.State())
+			assert.Equal(t, tt.expectedState != circuitbreaker.StateOpen, breaker.Ready())
+		})
+	}
+}
+
+func TestBreaker_OpensOnSlowCalls(t *testing.T) {
+	tests := []struct {
+		name          string
+		latencies     []time.Duration
+		expectedState circuitbreaker.State
+	}{
+		{name: "calls at the threshold are not slow", latencies: []time.Duration{100 * time.Millisecond, 100 * time.Millisecond, 100 * time.Millisecond, 100 * time.Millisecond}, expectedState: circuitbreaker.StateClosed},
+		{name: "stays closed below failure rate", latencies: []time.Duration{time.Second, time.Millisecond, time.Millisecond, time.Millisecond}, expectedState: circuitbreaker.StateClosed},
+		{name: "opens when half the calls are slow", latencies: []time.Duration{time.Second, time.Millisecond, time.Second, time.Millisecond}, expectedState: circuitbreaker.StateOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker := newTestBreaker()
+			for _, latency := range tt.latencies {
+				if breaker.Allow() {
+					breaker.Record(true, latency)
+				}
+			}
+			assert.Equal(t, tt.expectedState, breaker.State())
+		})
+	}
+}
+
+func TestBreaker_HalfOpen(t *testing.T) {
+	tests := []struct {
+		name          string
+		probeSuccess  bool
+		probeLatency  time.Duration
+		expectedState circuitbreaker.State
+	}{
+		{name: "successful probe closes the breaker", probeSuccess: true, probeLatency: time.Millisecond, expectedState: circuitbreaker.StateClosed},
+		{name: "failed probe opens the breaker again", probeSuccess: false, probeLatency: time.Millisecond, expectedState: circuitbreaker.StateOpen},
+		{name: "slow probe opens the breaker again", probeSuccess: true, probeLatency: time.Second, expectedState: circuitbreaker.StateOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker := newTestBreaker()
+			for range 4 {
+				breaker.Allow()
+				breaker.Record(false, time.Millisecond)
+			}
+			assert.False(t, breaker.Allow())
+
+			time.Sleep(30 * time.Millisecond)
+			assert.Equal(t, circuitbreaker.StateHalfOpen, breaker.State())
+			assert.True(t, breaker.Allow())
+			assert.False(t, breaker.Allow(), "only one probe is let through")
+
+			breaker.Record(tt.probeSuccess, tt.probeLatency)
+			assert.Equal(t, tt.expectedState, breaker.State())
+		})
+	}
+}
+
+func TestBreaker_Snapshot(t *testing.T) {
+	breaker := newTestBreaker()
+	snapshot := breaker.Snapshot()
+	assert.Equal(t, 0, snapshot.Requests)
+	assert.Equal(t, 1.0, snapshot.HealthScore)
+
+	breaker.Record(true, 100*time.Millisecond)
+	breaker.Record(true, 300*time.Millisecond)
+	breaker.Record(false, 200*time.Millisecond)
+
+	snapshot = breaker.Snapshot()
+	assert.Equal(t, circuitbreaker.StateClosed, snapshot.State)
+	assert.Equal(t, 3, snapshot.Requests)
+	assert.InDelta(t, 2.0/3.0, snapshot.SuccessRate, 0.001)
+	assert.Equal(t, 200*time.Millisecond, snapshot.AvgLatency)
+	assert.InDelta(t, 2.0/3.0*0.5, snapshot.HealthScore, 0.001)
+
+	breaker.Record(false, time.Millisecond)
+	snapshot = breaker.Snapshot()
+	assert.Equal(t, circuitbreaker.StateOpen, snapshot.State)
+	assert.Equal(t, 0.0, snapshot.HealthScore)
+	assert.False(t, snapshot.OpenedAt.IsZero())
+}
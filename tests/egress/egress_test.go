@@ -0,0 +1,68 @@
+package egress
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+	"top-up-api/pkg/egress"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url           string
+		expectedError error
+	}{
+		{url: "https://partner.example.com/hooks"},
+		{url: "https://8.8.8.8/hooks"},
+		{url: "http://partner.example.com/hooks", expectedError: egress.ErrInsecureURL},
+		{url: "ftp://partner.example.com/hooks", expectedError: egress.ErrInsecureURL},
+		{url: "https:///hooks", expectedError: egress.ErrInsecureURL},
+		{url: "https://localhost:8081/v1/api/payment", expectedError: egress.ErrForbiddenAddress},
+		{url: "https://api.localhost./hooks", expectedError: egress.ErrForbiddenAddress},
+		{url: "https://127.0.0.1/hooks", expectedError: egress.ErrForbiddenAddress},
+		{url: "https://[::1]/hooks", expectedError: egress.ErrForbiddenAddress},
+		{url: "https://169.254.169.254/latest/meta-data", expectedError: egress.ErrForbiddenAddress},
+		{url: "https://10.1.2.3/hooks", expectedError: egress.ErrForbiddenAddress},
+		{url: "https://172.31.0.1/hooks", expectedError: egress.ErrForbiddenAddress},
+		{url: "https://192.168.0.1/hooks", expectedError: egress.ErrForbiddenAddress},
+		{url: "https://[fd00::1]/hooks", expectedError: egress.ErrForbiddenAddress},
+		{url: "https://[fe80::1]/hooks", expectedError: egress.ErrForbiddenAddress},
+		{url: "https://0.0.0.0/hooks", expectedError: egress.ErrForbiddenAddress},
+		{url: "https://[::ffff:10.0.0.1]/hooks", expectedError: egress.ErrForbiddenAddress},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			assert.Equal(t, tt.expectedError, egress.CheckURL(tt.url))
+		})
+	}
+}
+
+func TestForbidden(t *testing.T) {
+	assert.False(t, egress.Forbidden(netip.MustParseAddr("93.184.216.34")))
+	assert.False(t, egress.Forbidden(netip.MustParseAddr("2606:4700::1111")))
+	assert.True(t, egress.Forbidden(netip.MustParseAddr("127.0.0.53")))
+	assert.True(t, egress.Forbidden(netip.MustParseAddr("::")))
+}
+
+// The client checks the address it connects to, so a public looking URL whose
+// name resolves to an internal address is refused as well.
+func TestNewClient_RefusesInternalAddressesAtDialTime(t *testing.T) {
+	var requests int
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	client := egress.NewClient(time.Second)
+	for _, url := range []string{server.URL, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)} {
+		_, err := client.Get(url)
+		assert.ErrorIs(t, err, egress.ErrForbiddenAddress, url)
+	}
+	assert.Equal(t, 0, requests)
+}
@@ -0,0 +1,149 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"top-up-api/pkg/idempotency"
+	"top-up-api/pkg/redis"
+
+	mockRedis "top-up-api/tests/mock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	_ttl         = time.Hour
+	_lockTimeout = time.Minute
+)
+
+var ctx = context.Background()
+
+func storedResponse(response idempotency.Response) string {
+	payload, _ := json.Marshal(response)
+	return string(payload)
+}
+
+func TestStore_Begin(t *testing.T) {
+	fingerprint := idempotency.Fingerprint([]byte("POST"), []byte("/v1/api/order/create"), []byte(`{"sku_id":1}`))
+	completed := idempotency.Response{Fingerprint: fingerprint, Completed: true, Status: 200, ContentType: "application/json", Body: []byte(`{"order_id":1}`)}
+
+	tests := []struct {
+		name          string
+		setupMocks    func(*mockRedis.RedisMock)
+		expected      *idempotency.Response
+		expectedError error
+	}{
+		{
+			name: "first request claims the key",
+			setupMocks: func(r *mockRedis.RedisMock) {
+				r.On("SetNX", mock.Anything, "idempotency:key-1", mock.MatchedBy(func(value []byte) bool {
+					var pending idempotency.Response
+					return json.Unmarshal(value, &pending) == nil && pending.Fingerprint == fingerprint && !pending.Completed
+				}), _lockTimeout).Return(true, nil)
+			},
+		},
+		{
+			name: "retry gets the stored response",
+			setupMocks: func(r *mockRedis.RedisMock) {
+				r.On("SetNX", mock.Anything, "idempotency:key-1", mock.Anything, _lockTimeout).Return(false, nil)
+				r.On("Get", mock.Anything, "idempotency:key-1").Return(storedResponse(completed), nil)
+			},
+			expected: &completed,
+		},
+		{
+			name: "key reused for another request",
+			setupMocks: func(r *mockRedis.RedisMock) {
+				r.On("SetNX", mock.Anything, "idempotency:key-1", mock.Anything, _lockTimeout).Return(false, nil)
+				r.On("Get", mock.Anything, "idempotency:key-1").Return(storedResponse(idempotency.Response{Fingerprint: "other", Completed: true, Status: 200}), nil)
+			},
+			expectedError: idempotency.ErrKeyReused,
+		},
+		{
+			name: "first request still running",
+			setupMocks: func(r *mockRedis.RedisMock) {
+				r.On("SetNX", mock.Anything, "idempotency:key-1", mock.Anything, _lockTimeout).Return(false, nil)
+				r.On("Get", mock.Anything, "idempotency:key-1").Return(storedResponse(idempotency.Response{Fingerprint: fingerprint}), nil)
+			},
+			expectedError: idempotency.ErrInProgress,
+		},
+		{
+			name: "key released after the claim failed",
+			setupMocks: func(r *mockRedis.RedisMock) {
+				r.On("SetNX", mock.Anything, "idempotency:key-1", mock.Anything, _lockTimeout).Return(false, nil)
+				r.On("Get", mock.Anything, "idempotency:key-1").Return("", redis.NotFound)
+			},
+			expectedError: idempotency.ErrInProgress,
+		},
+		{
+			name: "redis unavailable",
+			setupMocks: func(r *mockRedis.RedisMock) {
+				r.On("SetNX", mock.Anything, "idempotency:key-1", mock.Anything, _lockTimeout).Return(false, errors.New("connection refused"))
+			},
+			expectedError: errors.New("connection refused"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := new(mockRedis.RedisMock)
+			tt.setupMocks(r)
+			store := idempotency.NewStore(r, _ttl, _lockTimeout)
+
+			response, err := store.Begin(ctx, "key-1", fingerprint)
+
+			if tt.expectedError != nil {
+				assert.EqualError(t, err, tt.expectedError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expected, response)
+			r.AssertExpectations(t)
+		})
+	}
+}
+
+func TestStore_Complete(t *testing.T) {
+	r := new(mockRedis.RedisMock)
+	r.On("Set", mock.Anything, "idempotency:key-1", mock.MatchedBy(func(value []byte) bool {
+		var stored idempotency.Response
+		return json.Unmarshal(value, &stored) == nil && stored.Completed && stored.Status == 201 && string(stored.Body) == "created"
+	}), _ttl).Return(nil)
+	store := idempotency.NewStore(r, _ttl, _lockTimeout)
+
+	err := store.Complete(ctx, "key-1", idempotency.Response{Fingerprint: "fp", Status: 201, Body: []byte("created")})
+
+	assert.NoError(t, err)
+	r.AssertExpectations(t)
+}
+
+func TestStore_Release(t *testing.T) {
+	r := new(mockRedis.RedisMock)
+	r.On("Del", mock.Anything, "idempotency:key-1").Return(nil)
+	store := idempotency.NewStore(r, _ttl, _lockTimeout)
+
+	assert.NoError(t, store.Release(ctx, "key-1"))
+	r.AssertExpectations(t)
+}
+
+func TestFingerprint(t *testing.T) {
+	assert.Equal(t, idempotency.Fingerprint([]byte("a"), []byte("bc")), idempotency.Fingerprint([]byte("a"), []byte("bc")))
+	assert.NotEqual(t, idempotency.Fingerprint([]byte("a"), []byte("bc")), idempotency.Fingerprint([]byte("ab"), []byte("c")))
+	assert.NotEqual(t, idempotency.Fingerprint([]byte("POST"), []byte("/order"), []byte("{}")), idempotency.Fingerprint([]byte("PUT"), []byte("/order"), []byte("{}")))
+}
+
+func TestScopedKey(t *testing.T) {
+	alice := idempotency.ScopedKey("http", "key-1", []byte("Bearer alice"), nil)
+	bob := idempotency.ScopedKey("http", "key-1", []byte("Bearer bob"), nil)
+
+	assert.Equal(t, alice, idempotency.ScopedKey("http", "key-1", []byte("Bearer alice"), nil))
+	assert.NotEqual(t, alice, bob)
+	assert.NotEqual(t, alice, idempotency.ScopedKey("grpc", "key-1", []byte("Bearer alice"), nil))
+	assert.NotContains(t, alice, "alice")
+	assert.True(t, strings.HasPrefix(alice, "http:"))
+	assert.True(t, strings.HasSuffix(alice, ":key-1"))
+}
@@ -0,0 +1,95 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"top-up-api/pkg/migrate"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name             string
+		files            fstest.MapFS
+		expectedVersions []int64
+		expectedErr      string
+	}{
+		{
+			name: "orders by version and skips other files",
+			files: fstest.MapFS{
+				"000002_add_index.up.sql":        {Data: []byte("CREATE INDEX")},
+				"000002_add_index.down.sql":      {Data: []byte("DROP INDEX")},
+				"000001_initial_schema.up.sql":   {Data: []byte("CREATE TABLE")},
+				"000001_initial_schema.down.sql": {Data: []byte("DROP TABLE")},
+				"README.md":                      {Data: []byte("notes")},
+			},
+			expectedVersions: []int64{1, 2},
+		},
+		{
+			name: "missing down file",
+			files: fstest.MapFS{
+				"000001_initial_schema.up.sql": {Data: []byte("CREATE TABLE")},
+			},
+			expectedErr: "migration 1_initial_schema needs both an up and a down file",
+		},
+		{
+			name: "mismatched names",
+			files: fstest.MapFS{
+				"000001_initial_schema.up.sql": {Data: []byte("CREATE TABLE")},
+				"000001_other.down.sql":        {Data: []byte("DROP TABLE")},
+			},
+			expectedErr: "has files with different names",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := migrate.Load(tt.files)
+			if tt.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			versions := make([]int64, len(migrations))
+			for i, migration := range migrations {
+				versions[i] = migration.Version
+			}
+			assert.Equal(t, tt.expectedVersions, versions)
+			assert.Equal(t, "initial_schema", migrations[0].Name)
+			assert.Equal(t, "CREATE TABLE", migrations[0].Up)
+			assert.Equal(t, "DROP TABLE", migrations[0].Down)
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+
+	up, down, err := migrate.Create(dir, "initial_schema")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "000001_initial_schema.up.sql"), up)
+	assert.Equal(t, filepath.Join(dir, "000001_initial_schema.down.sql"), down)
+
+	up, _, err = migrate.Create(dir, "add_orders")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "000002_add_orders.up.sql"), up)
+
+	migrations, err := migrate.Load(os.DirFS(dir))
+	require.NoError(t, err)
+	assert.Len(t, migrations, 2)
+
+	_, _, err = migrate.Create(dir, "bad name")
+	assert.Error(t, err)
+}
+
+func TestRepositoryMigrations(t *testing.T) {
+	migrations, err := migrate.Load(os.DirFS("../../sql/migrations"))
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	assert.Equal(t, int64(1), migrations[0].Version)
+}
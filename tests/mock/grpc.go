@@ -19,6 +19,14 @@ func (m *ProviderGRPCClientMock) ProcessOrder(ctx context.Context, req *pb.Order
 	return args.Error(0)
 }
 
+func (m *ProviderGRPCClientMock) QueryOrder(ctx context.Context, req *pb.OrderQueryRequest) (*pb.OrderQueryResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pb.OrderQueryResponse), args.Error(1)
+}
+
 func (m *ProviderGRPCClientMock) Close() {
 	m.Called()
 }
@@ -29,8 +37,9 @@ type GRPCServiceClientMock struct {
 	ProviderGRPCClients map[string]*ProviderGRPCClientMock
 }
 
-func (m *GRPCServiceClientMock) BuildProviderGRPCClients(providers []model.Provider) {
-	m.Called(providers)
+func (m *GRPCServiceClientMock) SyncProviderGRPCClients(providers []model.Provider) error {
+	args := m.Called(providers)
+	return args.Error(0)
 }
 
 func (m *GRPCServiceClientMock) CloseConnection() {
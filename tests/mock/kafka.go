@@ -0,0 +1,22 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// KafkaProducerMock mocks the Kafka producer
+type KafkaProducerMock struct {
+	mock.Mock
+}
+
+func (m *KafkaProducerMock) Produce(ctx context.Context, topic string, key string, value interface{}) error {
+	args := m.Called(ctx, topic, key, value)
+	return args.Error(0)
+}
+
+func (m *KafkaProducerMock) Close() error {
+	args := m.Called()
+	return args.Error(0)
+}
@@ -3,6 +3,7 @@ package mock
 import (
 	"context"
 	"time"
+	"top-up-api/pkg/redis"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -26,12 +27,38 @@ func (m *RedisMock) Del(ctx context.Context, key string) error {
 	return args.Error(0)
 }
 
-func (m *RedisMock) ReleaseLock(ctx context.Context, key string) error {
-	args := m.Called(ctx, key)
+func (m *RedisMock) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	args := m.Called(ctx, key, value, expiration)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *RedisMock) ReleaseLock(ctx context.Context, lock *redis.Lock) error {
+	args := m.Called(ctx, lock)
 	return args.Error(0)
 }
 
-func (m *RedisMock) TryAcquireLock(ctx context.Context, key string, timeout time.Duration) error {
-	args := m.Called(ctx, key, timeout)
+func (m *RedisMock) RenewLock(ctx context.Context, lock *redis.Lock, ttl time.Duration) error {
+	args := m.Called(ctx, lock, ttl)
 	return args.Error(0)
 }
+
+func (m *RedisMock) TryAcquireLock(ctx context.Context, key string, ttl time.Duration) (*redis.Lock, error) {
+	args := m.Called(ctx, key, ttl)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*redis.Lock), args.Error(1)
+}
+
+func (m *RedisMock) Publish(ctx context.Context, channel string, message interface{}) error {
+	args := m.Called(ctx, channel, message)
+	return args.Error(0)
+}
+
+func (m *RedisMock) Subscribe(ctx context.Context, channel string) (<-chan string, error) {
+	args := m.Called(ctx, channel)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(<-chan string), args.Error(1)
+}
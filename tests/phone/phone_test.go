@@ -0,0 +1,96 @@
+package phone
+
+import (
+	"testing"
+	"top-up-api/pkg/phone"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name          string
+		number        string
+		expected      string
+		expectedError error
+	}{
+		{name: "national number", number: "0981234567", expected: "0981234567"},
+		{name: "plus country prefix", number: "+84981234567", expected: "0981234567"},
+		{name: "double zero country prefix", number: "0084981234567", expected: "0981234567"},
+		{name: "country prefix without plus", number: "84981234567", expected: "0981234567"},
+		{name: "separators are ignored", number: " +84 (98) 123-45.67 ", expected: "0981234567"},
+		{name: "empty", number: "", expectedError: phone.ErrInvalidNumber},
+		{name: "too short", number: "098123456", expectedError: phone.ErrInvalidNumber},
+		{name: "too long", number: "098123456789", expectedError: phone.ErrInvalidNumber},
+		{name: "landline", number: "02438123456", expectedError: phone.ErrInvalidNumber},
+		{name: "letters", number: "09812345ab", expectedError: phone.ErrInvalidNumber},
+		{name: "another country", number: "+6285567890123", expectedError: phone.ErrInvalidNumber},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normalized, err := phone.Normalize(tt.number)
+
+			assert.Equal(t, tt.expectedError, err)
+			assert.Equal(t, tt.expected, normalized)
+			assert.Equal(t, tt.expectedError == nil, phone.Valid(tt.number))
+		})
+	}
+}
+
+func TestCarrier(t *testing.T) {
+	tests := []struct {
+		number        string
+		expected      string
+		expectedError error
+	}{
+		{number: "0351234567", expected: phone.CarrierViettel},
+		{number: "+84861234567", expected: phone.CarrierViettel},
+		{number: "0901234567", expected: phone.CarrierMobiFone},
+		{number: "0701234567", expected: phone.CarrierMobiFone},
+		{number: "0911234567", expected: phone.CarrierVinaPhone},
+		{number: "0881234567", expected: phone.CarrierVinaPhone},
+		{number: "0921234567", expected: phone.CarrierVietnamobile},
+		{number: "0991234567", expected: phone.CarrierGmobile},
+		{number: "0871234567", expected: phone.CarrierItelecom},
+		{number: "0551234567", expected: phone.CarrierWintel},
+		{number: "0501234567", expectedError: phone.ErrUnknownCarrier},
+		{number: "12345", expectedError: phone.ErrInvalidNumber},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.number, func(t *testing.T) {
+			carrier, err := phone.Carrier(tt.number)
+
+			assert.Equal(t, tt.expectedError, err)
+			assert.Equal(t, tt.expected, carrier)
+		})
+	}
+}
+
+// The carrier codes have to match the supplier codes seeded in
+// sql/data/dataseeding.sql, orders are checked against the SKU's supplier code.
+func TestCarrier_MatchesSeededSupplierCodes(t *testing.T) {
+	tests := []struct {
+		number       string
+		supplierCode string
+	}{
+		{number: "0961234567", supplierCode: "VTL"},
+		{number: "0931234567", supplierCode: "MBF"},
+		{number: "0941234567", supplierCode: "VNP"},
+		{number: "0561234567", supplierCode: "VNM"},
+		{number: "0551234567", supplierCode: "WNT"},
+		{number: "0871234567", supplierCode: "ITL"},
+		{number: "0591234567", supplierCode: "GML"},
+		{number: "0991234567", supplierCode: "GML"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.supplierCode+"/"+tt.number, func(t *testing.T) {
+			carrier, err := phone.Carrier(tt.number)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.supplierCode, carrier)
+		})
+	}
+}
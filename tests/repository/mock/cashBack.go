@@ -0,0 +1,35 @@
+package mock
+
+import (
+	"context"
+	"top-up-api/internal/model"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type CashBackRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *CashBackRepositoryMock) GetCashBackByID(ctx context.Context, id uint) (*model.CashBack, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.CashBack), args.Error(1)
+}
+
+func (m *CashBackRepositoryMock) CreateCashBack(ctx context.Context, cashBack *model.CashBack) error {
+	args := m.Called(ctx, cashBack)
+	return args.Error(0)
+}
+
+func (m *CashBackRepositoryMock) UpdateCashBack(ctx context.Context, cashBack *model.CashBack) error {
+	args := m.Called(ctx, cashBack)
+	return args.Error(0)
+}
+
+func (m *CashBackRepositoryMock) DeleteCashBack(ctx context.Context, id uint) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
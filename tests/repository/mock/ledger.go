@@ -0,0 +1,49 @@
+package mock
+
+import (
+	"context"
+	"top-up-api/internal/model"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type LedgerRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *LedgerRepositoryMock) GetOrCreateWalletAccount(ctx context.Context, userID uint) (*model.LedgerAccount, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LedgerAccount), args.Error(1)
+}
+
+func (m *LedgerRepositoryMock) GetWalletAccount(ctx context.Context, userID uint) (*model.LedgerAccount, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LedgerAccount), args.Error(1)
+}
+
+func (m *LedgerRepositoryMock) GetSystemAccount(ctx context.Context, accountType model.LedgerAccountType) (*model.LedgerAccount, error) {
+	args := m.Called(ctx, accountType)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LedgerAccount), args.Error(1)
+}
+
+func (m *LedgerRepositoryMock) CreateLedgerTransaction(ctx context.Context, transaction *model.LedgerTransaction) error {
+	args := m.Called(ctx, transaction)
+	return args.Error(0)
+}
+
+func (m *LedgerRepositoryMock) GetLedgerEntriesByAccountIDPaginated(ctx context.Context, accountID uint, page, pageSize int) ([]model.LedgerEntry, int64, error) {
+	args := m.Called(ctx, accountID, page, pageSize)
+	if args.Get(0) == nil {
+		return nil, args.Get(1).(int64), args.Error(2)
+	}
+	return args.Get(0).([]model.LedgerEntry), args.Get(1).(int64), args.Error(2)
+}
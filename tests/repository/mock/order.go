@@ -0,0 +1,57 @@
+package mock
+
+import (
+	"context"
+	"time"
+	"top-up-api/internal/model"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type OrderRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *OrderRepositoryMock) CreateOrder(ctx context.Context, order *model.Order) error {
+	args := m.Called(ctx, order)
+	return args.Error(0)
+}
+
+func (m *OrderRepositoryMock) GetOrderByOrderID(ctx context.Context, orderID uint) (*model.Order, error) {
+	args := m.Called(ctx, orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Order), args.Error(1)
+}
+
+func (m *OrderRepositoryMock) UpdateOrderStatusByOrderID(ctx context.Context, orderID uint, status model.PurchaseHistoryStatus, fence int64) error {
+	args := m.Called(ctx, orderID, status, fence)
+	return args.Error(0)
+}
+
+func (m *OrderRepositoryMock) AssignOrderProvider(ctx context.Context, orderID uint, providerCode string) error {
+	args := m.Called(ctx, orderID, providerCode)
+	return args.Error(0)
+}
+
+func (m *OrderRepositoryMock) GetOrderProviderCode(ctx context.Context, orderID uint) (string, error) {
+	args := m.Called(ctx, orderID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *OrderRepositoryMock) GetPendingOrderIDsCreatedBefore(ctx context.Context, createdBefore time.Time, limit int) ([]uint, error) {
+	args := m.Called(ctx, createdBefore, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uint), args.Error(1)
+}
+
+func (m *OrderRepositoryMock) GetStuckOrders(ctx context.Context, updatedBefore time.Time, limit int) ([]model.Order, error) {
+	args := m.Called(ctx, updatedBefore, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Order), args.Error(1)
+}
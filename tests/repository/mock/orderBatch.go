@@ -0,0 +1,47 @@
+package mock
+
+import (
+	"context"
+	"time"
+	"top-up-api/internal/model"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type OrderBatchRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *OrderBatchRepositoryMock) CreateOrderBatch(ctx context.Context, batch *model.OrderBatch) error {
+	args := m.Called(ctx, batch)
+	return args.Error(0)
+}
+
+func (m *OrderBatchRepositoryMock) GetOrderBatchByBatchID(ctx context.Context, batchID uint) (*model.OrderBatch, error) {
+	args := m.Called(ctx, batchID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.OrderBatch), args.Error(1)
+}
+
+func (m *OrderBatchRepositoryMock) UpdateOrderBatchStatus(ctx context.Context, batchID uint, from, to model.PurchaseHistoryStatus, paymentReference string) error {
+	args := m.Called(ctx, batchID, from, to, paymentReference)
+	return args.Error(0)
+}
+
+func (m *OrderBatchRepositoryMock) GetPendingOrderBatchIDsCreatedBefore(ctx context.Context, createdBefore time.Time, limit int) ([]uint, error) {
+	args := m.Called(ctx, createdBefore, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uint), args.Error(1)
+}
+
+func (m *OrderBatchRepositoryMock) ClaimOrderBatchOrders(ctx context.Context, limit int) ([]uint, error) {
+	args := m.Called(ctx, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uint), args.Error(1)
+}
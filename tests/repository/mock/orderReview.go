@@ -0,0 +1,39 @@
+package mock
+
+import (
+	"context"
+	"time"
+	"top-up-api/internal/model"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type OrderReviewRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *OrderReviewRepositoryMock) GetOrderReviews(ctx context.Context, status model.OrderReviewStatus) ([]model.OrderReview, error) {
+	args := m.Called(ctx, status)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.OrderReview), args.Error(1)
+}
+
+func (m *OrderReviewRepositoryMock) GetOrderReviewByID(ctx context.Context, id uint) (*model.OrderReview, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.OrderReview), args.Error(1)
+}
+
+func (m *OrderReviewRepositoryMock) CreateOrderReview(ctx context.Context, review *model.OrderReview) error {
+	args := m.Called(ctx, review)
+	return args.Error(0)
+}
+
+func (m *OrderReviewRepositoryMock) ResolveOrderReview(ctx context.Context, id uint, resolution model.PurchaseHistoryStatus, note string, resolvedAt time.Time) error {
+	args := m.Called(ctx, id, resolution, note, resolvedAt)
+	return args.Error(0)
+}
@@ -0,0 +1,25 @@
+package mock
+
+import (
+	"context"
+	"top-up-api/internal/model"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type OrderStatusEventRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *OrderStatusEventRepositoryMock) CreateOrderStatusEvent(ctx context.Context, event *model.OrderStatusEvent) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+func (m *OrderStatusEventRepositoryMock) GetOrderStatusEventsByOrderID(ctx context.Context, orderID uint) ([]model.OrderStatusEvent, error) {
+	args := m.Called(ctx, orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.OrderStatusEvent), args.Error(1)
+}
@@ -0,0 +1,41 @@
+package mock
+
+import (
+	"context"
+	"time"
+	"top-up-api/internal/model"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type OutboxRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *OutboxRepositoryMock) CreateOutboxMessage(ctx context.Context, message *model.OutboxMessage) error {
+	args := m.Called(ctx, message)
+	return args.Error(0)
+}
+
+func (m *OutboxRepositoryMock) ClaimDueOutboxMessages(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.OutboxMessage, error) {
+	args := m.Called(ctx, now, lease, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.OutboxMessage), args.Error(1)
+}
+
+func (m *OutboxRepositoryMock) MarkOutboxMessageDelivered(ctx context.Context, id uint, attempts int, deliveredAt time.Time) error {
+	args := m.Called(ctx, id, attempts, deliveredAt)
+	return args.Error(0)
+}
+
+func (m *OutboxRepositoryMock) MarkOutboxMessageRetry(ctx context.Context, id uint, attempts int, nextAttemptAt time.Time, lastError string) error {
+	args := m.Called(ctx, id, attempts, nextAttemptAt, lastError)
+	return args.Error(0)
+}
+
+func (m *OutboxRepositoryMock) MarkOutboxMessageDead(ctx context.Context, id uint, attempts int, lastError string) error {
+	args := m.Called(ctx, id, attempts, lastError)
+	return args.Error(0)
+}
@@ -0,0 +1,70 @@
+package mock
+
+import (
+	"context"
+	"time"
+	"top-up-api/internal/model"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type PromotionRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *PromotionRepositoryMock) GetPromotions(ctx context.Context) ([]model.Promotion, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Promotion), args.Error(1)
+}
+
+func (m *PromotionRepositoryMock) GetPromotionByID(ctx context.Context, id uint) (*model.Promotion, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Promotion), args.Error(1)
+}
+
+func (m *PromotionRepositoryMock) GetAvailablePromotions(ctx context.Context, now time.Time, voucherCode string) ([]model.Promotion, error) {
+	args := m.Called(ctx, now, voucherCode)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Promotion), args.Error(1)
+}
+
+func (m *PromotionRepositoryMock) CreatePromotion(ctx context.Context, promotion *model.Promotion) error {
+	args := m.Called(ctx, promotion)
+	return args.Error(0)
+}
+
+func (m *PromotionRepositoryMock) UpdatePromotion(ctx context.Context, promotion *model.Promotion) error {
+	args := m.Called(ctx, promotion)
+	return args.Error(0)
+}
+
+func (m *PromotionRepositoryMock) DeletePromotion(ctx context.Context, id uint) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *PromotionRepositoryMock) CountUserRedemptions(ctx context.Context, userID uint, promotionIDs []uint) (map[uint]int, error) {
+	args := m.Called(ctx, userID, promotionIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[uint]int), args.Error(1)
+}
+
+func (m *PromotionRepositoryMock) RedeemPromotion(ctx context.Context, redemption *model.PromotionRedemption) error {
+	args := m.Called(ctx, redemption)
+	return args.Error(0)
+}
+
+func (m *PromotionRepositoryMock) ReleasePromotionRedemptions(ctx context.Context, orderID uint, releasedAt time.Time) error {
+	args := m.Called(ctx, orderID, releasedAt)
+	return args.Error(0)
+}
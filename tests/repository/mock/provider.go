@@ -18,3 +18,44 @@ func (m *ProviderRepositoryMock) GetProvidersWithSuppliers(ctx context.Context)
 	}
 	return args.Get(0).([]model.Provider), args.Error(1)
 }
+
+func (m *ProviderRepositoryMock) GetProviderByID(ctx context.Context, id uint) (*model.Provider, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Provider), args.Error(1)
+}
+
+func (m *ProviderRepositoryMock) CreateProvider(ctx context.Context, provider *model.Provider) error {
+	args := m.Called(ctx, provider)
+	return args.Error(0)
+}
+
+func (m *ProviderRepositoryMock) UpdateProvider(ctx context.Context, provider *model.Provider) error {
+	args := m.Called(ctx, provider)
+	return args.Error(0)
+}
+
+func (m *ProviderRepositoryMock) ReplaceProviderSuppliers(ctx context.Context, providerID uint, providerSuppliers []model.ProviderSupplier) error {
+	args := m.Called(ctx, providerID, providerSuppliers)
+	return args.Error(0)
+}
+
+func (m *ProviderRepositoryMock) DeleteProvider(ctx context.Context, id uint) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *ProviderRepositoryMock) GetProviderByCode(ctx context.Context, code string) (*model.Provider, error) {
+	args := m.Called(ctx, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Provider), args.Error(1)
+}
+
+func (m *ProviderRepositoryMock) UpdateProviderCallbackSecret(ctx context.Context, id uint, secret string) error {
+	args := m.Called(ctx, id, secret)
+	return args.Error(0)
+}
@@ -0,0 +1,25 @@
+package mock
+
+import (
+	"context"
+	"top-up-api/internal/model"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type ProviderAttemptRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *ProviderAttemptRepositoryMock) CreateProviderAttempt(ctx context.Context, attempt *model.ProviderAttempt) error {
+	args := m.Called(ctx, attempt)
+	return args.Error(0)
+}
+
+func (m *ProviderAttemptRepositoryMock) GetProviderAttemptsByOrderID(ctx context.Context, orderID uint) ([]model.ProviderAttempt, error) {
+	args := m.Called(ctx, orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.ProviderAttempt), args.Error(1)
+}
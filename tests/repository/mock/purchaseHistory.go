@@ -35,7 +35,20 @@ func (m *PurchaseHistoryRepositoryMock) UpdatePurchaseHistoryStatusByOrderID(ctx
 	return args.Error(0)
 }
 
+func (m *PurchaseHistoryRepositoryMock) UpdatePurchaseHistoryProviderByOrderID(ctx context.Context, orderID uint, providerCode string) error {
+	args := m.Called(ctx, orderID, providerCode)
+	return args.Error(0)
+}
+
 func (m *PurchaseHistoryRepositoryMock) GetPurchaseHistoryByOrderID(ctx context.Context, order_id uint) (*model.PurchaseHistory, error) {
 	args := m.Called(ctx, order_id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
 	return args.Get(0).(*model.PurchaseHistory), args.Error(1)
 }
+
+func (m *PurchaseHistoryRepositoryMock) CreatePaymentReference(ctx context.Context, paymentReference *model.PaymentReference) error {
+	args := m.Called(ctx, paymentReference)
+	return args.Error(0)
+}
@@ -0,0 +1,71 @@
+package mock
+
+import (
+	"context"
+	"time"
+	"top-up-api/internal/model"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type SettlementRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *SettlementRepositoryMock) GetSettlementRows(ctx context.Context, from, to time.Time, timezone string) ([]model.SettlementRow, error) {
+	args := m.Called(ctx, from, to, timezone)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.SettlementRow), args.Error(1)
+}
+
+func (m *SettlementRepositoryMock) GetSettlementPurchaseHistories(ctx context.Context, providerCode string, from, to time.Time, orderIDs []uint) ([]model.PurchaseHistory, error) {
+	args := m.Called(ctx, providerCode, from, to, orderIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.PurchaseHistory), args.Error(1)
+}
+
+func (m *SettlementRepositoryMock) CreateSettlementImport(ctx context.Context, settlementImport *model.SettlementImport) error {
+	args := m.Called(ctx, settlementImport)
+	return args.Error(0)
+}
+
+func (m *SettlementRepositoryMock) GetSettlementImports(ctx context.Context) ([]model.SettlementImport, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.SettlementImport), args.Error(1)
+}
+
+func (m *SettlementRepositoryMock) GetSettlementImportByID(ctx context.Context, id uint) (*model.SettlementImport, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.SettlementImport), args.Error(1)
+}
+
+func (m *SettlementRepositoryMock) GetSettlementDiscrepancies(ctx context.Context, importID uint, status model.SettlementDiscrepancyStatus) ([]model.SettlementDiscrepancy, error) {
+	args := m.Called(ctx, importID, status)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.SettlementDiscrepancy), args.Error(1)
+}
+
+func (m *SettlementRepositoryMock) GetSettlementDiscrepancyByID(ctx context.Context, id uint) (*model.SettlementDiscrepancy, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.SettlementDiscrepancy), args.Error(1)
+}
+
+func (m *SettlementRepositoryMock) ResolveSettlementDiscrepancy(ctx context.Context, id uint, note string, resolvedAt time.Time) error {
+	args := m.Called(ctx, id, note, resolvedAt)
+	return args.Error(0)
+}
@@ -34,3 +34,18 @@ func (m *SkuRepositoryMock) GetSkus(ctx context.Context) (*[]model.Sku, error) {
 	}
 	return args.Get(0).(*[]model.Sku), args.Error(1)
 }
+
+func (m *SkuRepositoryMock) CreateSku(ctx context.Context, sku *model.Sku) error {
+	args := m.Called(ctx, sku)
+	return args.Error(0)
+}
+
+func (m *SkuRepositoryMock) UpdateSku(ctx context.Context, sku *model.Sku) error {
+	args := m.Called(ctx, sku)
+	return args.Error(0)
+}
+
+func (m *SkuRepositoryMock) DeleteSku(ctx context.Context, id uint) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
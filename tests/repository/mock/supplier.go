@@ -18,3 +18,34 @@ func (m *SupplierRepositoryMock) GetSuppliers(ctx context.Context) (*[]model.Sup
 	}
 	return args.Get(0).(*[]model.Supplier), args.Error(1)
 }
+
+func (m *SupplierRepositoryMock) GetSupplierByID(ctx context.Context, id uint) (*model.Supplier, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Supplier), args.Error(1)
+}
+
+func (m *SupplierRepositoryMock) GetSuppliersByCodes(ctx context.Context, codes []string) ([]model.Supplier, error) {
+	args := m.Called(ctx, codes)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Supplier), args.Error(1)
+}
+
+func (m *SupplierRepositoryMock) CreateSupplier(ctx context.Context, supplier *model.Supplier) error {
+	args := m.Called(ctx, supplier)
+	return args.Error(0)
+}
+
+func (m *SupplierRepositoryMock) UpdateSupplier(ctx context.Context, supplier *model.Supplier) error {
+	args := m.Called(ctx, supplier)
+	return args.Error(0)
+}
+
+func (m *SupplierRepositoryMock) DeleteSupplier(ctx context.Context, id uint) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
@@ -0,0 +1,21 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// TransactionManagerMock runs fn directly with the caller's context unless an
+// error is configured for WithinTransaction.
+type TransactionManagerMock struct {
+	mock.Mock
+}
+
+func (m *TransactionManagerMock) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	args := m.Called(ctx)
+	if err := args.Error(0); err != nil {
+		return err
+	}
+	return fn(ctx)
+}
@@ -0,0 +1,109 @@
+package mock
+
+import (
+	"context"
+	"time"
+	"top-up-api/internal/model"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type WebhookRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *WebhookRepositoryMock) GetWebhookSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.WebhookSubscription), args.Error(1)
+}
+
+func (m *WebhookRepositoryMock) GetWebhookSubscriptionsByUserID(ctx context.Context, userID uint) ([]model.WebhookSubscription, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.WebhookSubscription), args.Error(1)
+}
+
+func (m *WebhookRepositoryMock) GetWebhookSubscriptionByID(ctx context.Context, id uint) (*model.WebhookSubscription, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.WebhookSubscription), args.Error(1)
+}
+
+func (m *WebhookRepositoryMock) GetActiveWebhookSubscriptionsByEvent(ctx context.Context, userID uint, eventType string) ([]model.WebhookSubscription, error) {
+	args := m.Called(ctx, userID, eventType)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.WebhookSubscription), args.Error(1)
+}
+
+func (m *WebhookRepositoryMock) CreateWebhookSubscription(ctx context.Context, subscription *model.WebhookSubscription) error {
+	args := m.Called(ctx, subscription)
+	return args.Error(0)
+}
+
+func (m *WebhookRepositoryMock) UpdateWebhookSubscription(ctx context.Context, subscription *model.WebhookSubscription) error {
+	args := m.Called(ctx, subscription)
+	return args.Error(0)
+}
+
+func (m *WebhookRepositoryMock) DeleteWebhookSubscription(ctx context.Context, id uint) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *WebhookRepositoryMock) CreateWebhookDeliveries(ctx context.Context, deliveries []model.WebhookDelivery) error {
+	args := m.Called(ctx, deliveries)
+	return args.Error(0)
+}
+
+func (m *WebhookRepositoryMock) GetWebhookDeliveryByID(ctx context.Context, id uint) (*model.WebhookDelivery, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.WebhookDelivery), args.Error(1)
+}
+
+func (m *WebhookRepositoryMock) GetWebhookDeliveriesBySubscriptionIDPaginated(ctx context.Context, subscriptionID uint, page, pageSize int) ([]model.WebhookDelivery, int64, error) {
+	args := m.Called(ctx, subscriptionID, page, pageSize)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]model.WebhookDelivery), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *WebhookRepositoryMock) ClaimDueWebhookDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error) {
+	args := m.Called(ctx, now, lease, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.WebhookDelivery), args.Error(1)
+}
+
+func (m *WebhookRepositoryMock) MarkWebhookDeliveryDelivered(ctx context.Context, id uint, attempts, responseStatus int, deliveredAt time.Time) error {
+	args := m.Called(ctx, id, attempts, responseStatus, deliveredAt)
+	return args.Error(0)
+}
+
+func (m *WebhookRepositoryMock) MarkWebhookDeliveryRetry(ctx context.Context, id uint, attempts, responseStatus int, nextAttemptAt time.Time, lastError string) error {
+	args := m.Called(ctx, id, attempts, responseStatus, nextAttemptAt, lastError)
+	return args.Error(0)
+}
+
+func (m *WebhookRepositoryMock) MarkWebhookDeliveryFailed(ctx context.Context, id uint, attempts, responseStatus int, lastError string) error {
+	args := m.Called(ctx, id, attempts, responseStatus, lastError)
+	return args.Error(0)
+}
+
+func (m *WebhookRepositoryMock) ResetWebhookDelivery(ctx context.Context, id uint, nextAttemptAt time.Time) error {
+	args := m.Called(ctx, id, nextAttemptAt)
+	return args.Error(0)
+}
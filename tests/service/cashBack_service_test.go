@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"top-up-api/internal/model"
+	"top-up-api/internal/schema"
+	"top-up-api/internal/service"
+	"top-up-api/pkg/errs"
+	mockRepo "top-up-api/tests/repository/mock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func TestCashBackService_CreateCashBack(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name          string
+		request       schema.CashBackRequest
+		setupMock     func(*mockRepo.CashBackRepositoryMock)
+		expectedError string
+	}{
+		{
+			name:    "Success",
+			request: schema.CashBackRequest{Code: "CB5P", Type: model.CashBackTypePercentage, Value: 5},
+			setupMock: func(m *mockRepo.CashBackRepositoryMock) {
+				m.On("CreateCashBack", ctx, mock.AnythingOfType("*model.CashBack")).Return(nil)
+			},
+		},
+		{
+			name:          "Percentage above 100",
+			request:       schema.CashBackRequest{Code: "CB150P", Type: model.CashBackTypePercentage, Value: 150},
+			setupMock:     func(m *mockRepo.CashBackRepositoryMock) {},
+			expectedError: "percentage cash back must not exceed 100",
+		},
+		{
+			name:    "Fixed value above 100",
+			request: schema.CashBackRequest{Code: "CB5000F", Type: model.CashBackTypeFixed, Value: 5000},
+			setupMock: func(m *mockRepo.CashBackRepositoryMock) {
+				m.On("CreateCashBack", ctx, mock.AnythingOfType("*model.CashBack")).Return(nil)
+			},
+		},
+		{
+			name:    "Duplicate code",
+			request: schema.CashBackRequest{Code: "CB5P", Type: model.CashBackTypePercentage, Value: 5},
+			setupMock: func(m *mockRepo.CashBackRepositoryMock) {
+				m.On("CreateCashBack", ctx, mock.AnythingOfType("*model.CashBack")).Return(gorm.ErrDuplicatedKey)
+			},
+			expectedError: "cash back already exists",
+		},
+		{
+			name:    "Repository error",
+			request: schema.CashBackRequest{Code: "CB5P", Type: model.CashBackTypePercentage, Value: 5},
+			setupMock: func(m *mockRepo.CashBackRepositoryMock) {
+				m.On("CreateCashBack", ctx, mock.AnythingOfType("*model.CashBack")).Return(errors.New("db error"))
+			},
+			expectedError: "db error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(mockRepo.CashBackRepositoryMock)
+			tt.setupMock(repo)
+
+			got, err := service.NewCashBackService(repo).CreateCashBack(ctx, tt.request)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.request.Code, got.Code)
+				assert.Equal(t, tt.request.Value, got.Value)
+			}
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCashBackService_UpdateCashBack(t *testing.T) {
+	ctx := context.Background()
+	request := schema.CashBackRequest{Code: "CB10P", Type: model.CashBackTypePercentage, Value: 10}
+
+	t.Run("Success", func(t *testing.T) {
+		repo := new(mockRepo.CashBackRepositoryMock)
+		repo.On("GetCashBackByID", ctx, uint(2)).Return(&model.CashBack{Model: gorm.Model{ID: 2}, Code: "CB5P", Type: model.CashBackTypePercentage, Value: 5}, nil)
+		repo.On("UpdateCashBack", ctx, mock.MatchedBy(func(cashBack *model.CashBack) bool {
+			return cashBack.ID == 2 && cashBack.Code == "CB10P" && cashBack.Value == 10
+		})).Return(nil)
+
+		got, err := service.NewCashBackService(repo).UpdateCashBack(ctx, 2, request)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(2), got.ID)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		repo := new(mockRepo.CashBackRepositoryMock)
+		repo.On("GetCashBackByID", ctx, uint(9)).Return(nil, gorm.ErrRecordNotFound)
+
+		got, err := service.NewCashBackService(repo).UpdateCashBack(ctx, 9, request)
+		var notFoundErr *errs.NotFoundError
+		assert.ErrorAs(t, err, &notFoundErr)
+		assert.Nil(t, got)
+	})
+}
+
+func TestCashBackService_DeleteCashBack(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepo.CashBackRepositoryMock)
+	repo.On("DeleteCashBack", ctx, uint(2)).Return(nil)
+	repo.On("DeleteCashBack", ctx, uint(9)).Return(gorm.ErrRecordNotFound)
+
+	svc := service.NewCashBackService(repo)
+	assert.NoError(t, svc.DeleteCashBack(ctx, 2))
+	assert.EqualError(t, svc.DeleteCashBack(ctx, 9), "cash back not found")
+}
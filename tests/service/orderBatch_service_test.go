@@ -0,0 +1,456 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	grpcClient "top-up-api/internal/grpc/client"
+	"top-up-api/internal/model"
+	"top-up-api/internal/repository"
+	"top-up-api/internal/schema"
+	"top-up-api/internal/service"
+	mockGrpc "top-up-api/tests/mock"
+	mockRepo "top-up-api/tests/repository/mock"
+	"top-up-api/tests/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+// createTestOrderBatch builds batch 5001 of user 1 with an order of the
+// Viettel 10000 sku on each line, in status
+func createTestOrderBatch(status model.PurchaseHistoryStatus, orderIDs ...uint) *model.OrderBatch {
+	sku := util.CreateMockSku(1, "VTL", 10000, model.CashBackTypePercentage, 5, "Viettel")
+	batchID := uint(5001)
+	batch := &model.OrderBatch{BatchID: batchID, UserID: 1, LineCount: len(orderIDs), Status: status}
+	for i, orderID := range orderIDs {
+		order := util.CreatePersistedOrder(orderID, 1, 10000, "0981234567", 500, status, sku)
+		order.BatchID = &batchID
+		order.BatchLine = i + 1
+		batch.Orders = append(batch.Orders, *order)
+		batch.TotalPrice += order.TotalPrice
+		batch.CashBackValue += order.CashBackValue
+	}
+	return batch
+}
+
+func newOrderBatchTestService(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, eventRepo *mockRepo.OrderStatusEventRepositoryMock, outboxRepo *mockRepo.OutboxRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock, batchRepo *mockRepo.OrderBatchRepositoryMock) service.OrderService {
+	txManager := new(mockRepo.TransactionManagerMock)
+	util.SetupTransactionMocks(txManager)
+	attemptRepo := new(mockRepo.ProviderAttemptRepositoryMock)
+	util.SetupDefaultProviderAttemptMocks(attemptRepo)
+	grpcClients := &grpcClient.GRPCServiceClient{
+		ProviderGRPCClients: make(map[string]grpcClient.ProviderGRPCClient),
+	}
+	orderService := service.NewOrderService(skuRepo, purchaseRepo, orderRepo, eventRepo, outboxRepo, attemptRepo, txManager, redis, grpcClients, providerRepo, new(mockRepo.OrderReviewRepositoryMock), batchRepo, service.NewWalletService(new(mockRepo.LedgerRepositoryMock)), service.NewPromotionService(new(mockRepo.PromotionRepositoryMock)), dispatchTestConfig, paymentTestConfig)
+	orderService.ReloadProviders(context.Background())
+	return orderService
+}
+
+func TestOrderService_CreateOrderBatch(t *testing.T) {
+	viettelSku := util.CreateMockSku(1, "VTL", 10000, model.CashBackTypePercentage, 5, "Viettel")
+	testCases := []struct {
+		Name          string
+		Lines         []schema.OrderBatchLine
+		ExpectCreated bool
+		ExpectedError string
+	}{
+		{
+			Name: "every line becomes an order of the batch",
+			Lines: []schema.OrderBatchLine{
+				{SkuID: 1, PhoneNumber: "+84981234567"},
+				{SkuID: 1, PhoneNumber: "0971234567"},
+			},
+			ExpectCreated: true,
+		},
+		{
+			Name: "the errors of every invalid line are reported",
+			Lines: []schema.OrderBatchLine{
+				{SkuID: 1, PhoneNumber: "12345"},
+				{SkuID: 1, PhoneNumber: "0981234567"},
+				{SkuID: 9, PhoneNumber: "0981234567"},
+				{SkuID: 1, PhoneNumber: "0901234567"},
+			},
+			ExpectedError: `line 1: phone number "12345" must be a Vietnamese mobile number; line 3: sku 9 not found; line 4: phone number 0901234567 is a MBF number, the sku is for VTL`,
+		},
+		{
+			Name:          "empty batch",
+			ExpectedError: "order batch has no lines",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			skuRepo := new(mockRepo.SkuRepositoryMock)
+			skuRepo.On("GetSkuByID", mock.Anything, uint(1)).Return(viettelSku, nil).Maybe()
+			skuRepo.On("GetSkuByID", mock.Anything, uint(9)).Return(nil, gorm.ErrRecordNotFound).Maybe()
+			batchRepo := new(mockRepo.OrderBatchRepositoryMock)
+			eventRepo := new(mockRepo.OrderStatusEventRepositoryMock)
+			outboxRepo := new(mockRepo.OutboxRepositoryMock)
+			if tc.ExpectCreated {
+				batchRepo.On("CreateOrderBatch", mock.Anything, mock.MatchedBy(func(batch *model.OrderBatch) bool {
+					return batch.UserID == 1 && batch.TotalPrice == 20000 && batch.CashBackValue == 1000 && batch.LineCount == 2 &&
+						batch.Status == model.PurchaseHistoryStatusPending &&
+						batch.Orders[0].PhoneNumber == "0981234567" && batch.Orders[0].BatchLine == 1 && *batch.Orders[0].BatchID == batch.BatchID &&
+						batch.Orders[1].BatchLine == 2 && batch.Orders[0].OrderID != batch.Orders[1].OrderID
+				})).Return(nil).Once()
+				eventRepo.On("CreateOrderStatusEvent", mock.Anything, mock.MatchedBy(func(event *model.OrderStatusEvent) bool {
+					return event.PreviousStatus == nil && event.Status == model.PurchaseHistoryStatusPending
+				})).Return(nil).Twice()
+				outboxRepo.On("CreateOutboxMessage", mock.Anything, mock.MatchedBy(func(message *model.OutboxMessage) bool {
+					return message.EventType == model.OutboxEventOrderBatchCreated && message.Method == http.MethodPost &&
+						message.Destination == paymentTestConfig.BatchCreateURL && strings.Contains(message.Payload, `"total_price":20000`)
+				})).Return(nil).Once()
+			}
+
+			providerRepo := new(mockRepo.ProviderRepositoryMock)
+			providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+			orderService := newOrderBatchTestService(skuRepo, new(mockRepo.PurchaseHistoryRepositoryMock), new(mockRepo.OrderRepositoryMock), eventRepo, outboxRepo, new(mockGrpc.RedisMock), providerRepo, batchRepo)
+			batch, err := orderService.CreateOrderBatch(context.Background(), schema.OrderBatchRequest{UserID: 1, Lines: tc.Lines})
+
+			if tc.ExpectedError != "" {
+				assert.EqualError(t, err, tc.ExpectedError)
+				assert.Nil(t, batch)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, 20000, batch.TotalPrice)
+				assert.Equal(t, schema.OrderBatchProgress{Pending: 2}, batch.Progress)
+				assert.Len(t, batch.Lines, 2)
+				assert.Equal(t, 500, batch.Lines[1].CashBackValue)
+				skuRepo.AssertNumberOfCalls(t, "GetSkuByID", 1)
+			}
+			batchRepo.AssertExpectations(t)
+			eventRepo.AssertExpectations(t)
+			outboxRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOrderService_CreateOrderBatchFromFile(t *testing.T) {
+	testCases := []struct {
+		Name          string
+		File          string
+		ExpectedError string
+	}{
+		{
+			Name: "columns are matched ignoring case and order",
+			File: "\ufeffSKU_ID,Phone_Number\n1,0981234567\n1, 0971234567\n",
+		},
+		{
+			Name:          "missing column",
+			File:          "phone_number,amount\n0981234567,10000\n",
+			ExpectedError: "order batch file has no sku_id column",
+		},
+		{
+			Name:          "invalid sku id",
+			File:          "phone_number,sku_id\n0981234567,1\n0971234567,abc\n",
+			ExpectedError: `file line 3: invalid sku id "abc"`,
+		},
+		{
+			Name:          "empty file",
+			ExpectedError: "order batch file is empty",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			skuRepo := new(mockRepo.SkuRepositoryMock)
+			skuRepo.On("GetSkuByID", mock.Anything, uint(1)).Return(util.CreateMockSku(1, "VTL", 10000, model.CashBackTypePercentage, 5, "Viettel"), nil).Maybe()
+			batchRepo := new(mockRepo.OrderBatchRepositoryMock)
+			batchRepo.On("CreateOrderBatch", mock.Anything, mock.AnythingOfType("*model.OrderBatch")).Return(nil).Maybe()
+			eventRepo := new(mockRepo.OrderStatusEventRepositoryMock)
+			util.SetupDefaultOrderStatusEventMocks(eventRepo)
+			outboxRepo := new(mockRepo.OutboxRepositoryMock)
+			util.SetupDefaultOutboxMocks(outboxRepo)
+
+			providerRepo := new(mockRepo.ProviderRepositoryMock)
+			providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+			orderService := newOrderBatchTestService(skuRepo, new(mockRepo.PurchaseHistoryRepositoryMock), new(mockRepo.OrderRepositoryMock), eventRepo, outboxRepo, new(mockGrpc.RedisMock), providerRepo, batchRepo)
+			batch, err := orderService.CreateOrderBatchFromFile(context.Background(), 1, strings.NewReader(tc.File))
+
+			if tc.ExpectedError != "" {
+				assert.EqualError(t, err, tc.ExpectedError)
+				batchRepo.AssertNotCalled(t, "CreateOrderBatch", mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"0981234567", "0971234567"}, []string{batch.Lines[0].PhoneNumber, batch.Lines[1].PhoneNumber})
+		})
+	}
+}
+
+func TestOrderService_ConfirmOrderBatch(t *testing.T) {
+	paid := schema.OrderBatchConfirmRequest{BatchID: 5001, UserID: 1, TotalPrice: 20000, Status: model.PurchaseHistoryStatusConfirm, PaymentReference: "PAY-1"}
+	testCases := []struct {
+		Name           string
+		Request        schema.OrderBatchConfirmRequest
+		BatchStatus    model.PurchaseHistoryStatus
+		UpdateError    error
+		ReferenceError error
+		ExpectPaid     bool
+		ExpectedError  string
+	}{
+		{
+			Name:        "payment confirms every order of the batch",
+			Request:     paid,
+			BatchStatus: model.PurchaseHistoryStatusPending,
+			ExpectPaid:  true,
+		},
+		{
+			Name:          "payment for another total",
+			Request:       schema.OrderBatchConfirmRequest{BatchID: 5001, UserID: 1, TotalPrice: 10000, Status: model.PurchaseHistoryStatusConfirm, PaymentReference: "PAY-1"},
+			BatchStatus:   model.PurchaseHistoryStatusPending,
+			ExpectedError: "order batch mismatch",
+		},
+		{
+			Name:          "payment cannot expire a batch",
+			Request:       schema.OrderBatchConfirmRequest{BatchID: 5001, UserID: 1, TotalPrice: 20000, Status: model.PurchaseHistoryStatusExpired, PaymentReference: "PAY-1"},
+			BatchStatus:   model.PurchaseHistoryStatusPending,
+			ExpectedError: "payment can only confirm or fail an order, not set it to expired",
+		},
+		{
+			Name:          "batch already paid",
+			Request:       paid,
+			BatchStatus:   model.PurchaseHistoryStatusConfirm,
+			ExpectedError: "invalid order status transition from confirm to confirm",
+		},
+		{
+			Name:          "payment that already paid another batch",
+			Request:       paid,
+			BatchStatus:   model.PurchaseHistoryStatusPending,
+			UpdateError:   gorm.ErrDuplicatedKey,
+			ExpectedError: "payment PAY-1 already paid an order batch",
+		},
+		{
+			Name:           "payment that already confirmed an order",
+			Request:        paid,
+			BatchStatus:    model.PurchaseHistoryStatusPending,
+			ReferenceError: gorm.ErrDuplicatedKey,
+			ExpectedError:  "payment PAY-1 already paid an order or an order batch",
+		},
+		{
+			Name:          "batch changed by the expiry sweeper",
+			Request:       paid,
+			BatchStatus:   model.PurchaseHistoryStatusPending,
+			UpdateError:   repository.ErrOrderBatchStatusChanged,
+			ExpectedError: "order batch 5001 was changed before its status was written",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			redis := new(mockGrpc.RedisMock)
+			redis.On("TryAcquireLock", mock.Anything, "order_batch:5001", mock.AnythingOfType("time.Duration")).Return(util.NewTestLock("order_batch:5001"), nil)
+			redis.On("ReleaseLock", mock.Anything, util.LockOf("order_batch:5001")).Return(nil)
+
+			batchRepo := new(mockRepo.OrderBatchRepositoryMock)
+			batchRepo.On("GetOrderBatchByBatchID", mock.Anything, uint(5001)).Return(createTestOrderBatch(tc.BatchStatus, 2001, 2002), nil)
+			if tc.UpdateError != nil {
+				batchRepo.On("UpdateOrderBatchStatus", mock.Anything, uint(5001), model.PurchaseHistoryStatusPending, model.PurchaseHistoryStatusConfirm, "PAY-1").Return(tc.UpdateError)
+			}
+			purchaseRepo := new(mockRepo.PurchaseHistoryRepositoryMock)
+			if tc.ReferenceError != nil {
+				batchRepo.On("UpdateOrderBatchStatus", mock.Anything, uint(5001), model.PurchaseHistoryStatusPending, model.PurchaseHistoryStatusConfirm, "PAY-1").Return(nil)
+				purchaseRepo.On("CreatePaymentReference", mock.Anything, mock.AnythingOfType("*model.PaymentReference")).Return(tc.ReferenceError)
+			}
+			orderRepo := new(mockRepo.OrderRepositoryMock)
+			eventRepo := new(mockRepo.OrderStatusEventRepositoryMock)
+			if tc.ExpectPaid {
+				batchRepo.On("UpdateOrderBatchStatus", mock.Anything, uint(5001), model.PurchaseHistoryStatusPending, model.PurchaseHistoryStatusConfirm, "PAY-1").Return(nil).Once()
+				purchaseRepo.On("CreatePaymentReference", mock.Anything, mock.MatchedBy(func(reference *model.PaymentReference) bool {
+					return reference.Reference == "PAY-1" && reference.OrderID == nil && *reference.BatchID == 5001
+				})).Return(nil).Once()
+				for _, order := range []struct {
+					ID        uint
+					Key       string
+					Reference string
+				}{{2001, "2001", "PAY-1#1"}, {2002, "2002", "PAY-1#2"}} {
+					purchaseRepo.On("CreatePurchaseHistory", mock.Anything, mock.MatchedBy(func(history *model.PurchaseHistory) bool {
+						return history.OrderID == order.ID && history.Status == model.PurchaseHistoryStatusConfirm && history.PaymentReference == order.Reference
+					})).Return(nil).Once()
+					orderRepo.On("UpdateOrderStatusByOrderID", mock.Anything, order.ID, model.PurchaseHistoryStatusConfirm, int64(1)).Return(nil).Once()
+					redis.On("Set", mock.Anything, "order_id"+order.Key, mock.MatchedBy(func(value []byte) bool {
+						var cached schema.OrderResponse
+						return json.Unmarshal(value, &cached) == nil && cached.Status == model.PurchaseHistoryStatusConfirm && *cached.BatchID == 5001
+					}), mock.AnythingOfType("time.Duration")).Return(nil).Once()
+					redis.On("Publish", mock.Anything, "order_status:"+order.Key, mock.AnythingOfType("[]uint8")).Return(nil).Once()
+				}
+				eventRepo.On("CreateOrderStatusEvent", mock.Anything, mock.MatchedBy(func(event *model.OrderStatusEvent) bool {
+					return *event.PreviousStatus == model.PurchaseHistoryStatusPending && event.Status == model.PurchaseHistoryStatusConfirm
+				})).Return(nil).Twice()
+			}
+
+			providerRepo := new(mockRepo.ProviderRepositoryMock)
+			providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+			orderService := newOrderBatchTestService(new(mockRepo.SkuRepositoryMock), purchaseRepo, orderRepo, eventRepo, new(mockRepo.OutboxRepositoryMock), redis, providerRepo, batchRepo)
+			err := orderService.ConfirmOrderBatch(context.Background(), tc.Request)
+			orderService.WaitForDispatches()
+
+			if tc.ExpectedError != "" {
+				assert.ErrorContains(t, err, tc.ExpectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			// The orders of a paid batch wait for the batch dispatcher
+			orderRepo.AssertNotCalled(t, "AssignOrderProvider", mock.Anything, mock.Anything, mock.Anything)
+			redis.AssertExpectations(t)
+			batchRepo.AssertExpectations(t)
+			purchaseRepo.AssertExpectations(t)
+			orderRepo.AssertExpectations(t)
+			eventRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOrderService_ConfirmOrderRejectsBatchOrders(t *testing.T) {
+	batchID := uint(5001)
+	cachedOrder := util.CreateCachedOrderResponse(2001, 1, 10000, "0981234567", 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+	cachedOrder.BatchID = &batchID
+	cachedOrder.BatchLine = 1
+
+	redis := new(mockGrpc.RedisMock)
+	redis.On("TryAcquireLock", mock.Anything, "2001", mock.AnythingOfType("time.Duration")).Return(util.NewTestLock("2001"), nil)
+	redis.On("ReleaseLock", mock.Anything, util.LockOf("2001")).Return(nil)
+	orderRepo := new(mockRepo.OrderRepositoryMock)
+	util.SetupStoredOrderMocks(orderRepo, cachedOrder)
+
+	providerRepo := new(mockRepo.ProviderRepositoryMock)
+	providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+	purchaseRepo := new(mockRepo.PurchaseHistoryRepositoryMock)
+	orderService := newOrderBatchTestService(new(mockRepo.SkuRepositoryMock), purchaseRepo, orderRepo, new(mockRepo.OrderStatusEventRepositoryMock), new(mockRepo.OutboxRepositoryMock), redis, providerRepo, new(mockRepo.OrderBatchRepositoryMock))
+	err := orderService.ConfirmOrder(context.Background(), schema.OrderConfirmRequest{
+		OrderID:          2001,
+		UserID:           1,
+		SkuID:            1,
+		TotalPrice:       10000,
+		Status:           model.PurchaseHistoryStatusConfirm,
+		PhoneNumber:      "0981234567",
+		CashBackValue:    500,
+		PaymentReference: "PAY-2",
+	})
+
+	assert.EqualError(t, err, "order 2001 is paid with order batch 5001")
+	purchaseRepo.AssertNotCalled(t, "CreatePurchaseHistory", mock.Anything, mock.Anything)
+}
+
+func TestOrderService_GetOrderBatch(t *testing.T) {
+	batch := createTestOrderBatch(model.PurchaseHistoryStatusConfirm, 2001, 2002, 2003)
+	batch.Orders[0].Status = model.PurchaseHistoryStatusSuccess
+	batch.Orders[1].Status = model.PurchaseHistoryStatusFailed
+
+	batchRepo := new(mockRepo.OrderBatchRepositoryMock)
+	batchRepo.On("GetOrderBatchByBatchID", mock.Anything, uint(5001)).Return(batch, nil)
+	batchRepo.On("GetOrderBatchByBatchID", mock.Anything, uint(5002)).Return(nil, gorm.ErrRecordNotFound)
+	providerRepo := new(mockRepo.ProviderRepositoryMock)
+	providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+	orderService := newOrderBatchTestService(new(mockRepo.SkuRepositoryMock), new(mockRepo.PurchaseHistoryRepositoryMock), new(mockRepo.OrderRepositoryMock), new(mockRepo.OrderStatusEventRepositoryMock), new(mockRepo.OutboxRepositoryMock), new(mockGrpc.RedisMock), providerRepo, batchRepo)
+
+	got, err := orderService.GetOrderBatch(context.Background(), 5001, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, model.PurchaseHistoryStatusConfirm, got.Status)
+	assert.Equal(t, schema.OrderBatchProgress{Confirm: 1, Success: 1, Failed: 1}, got.Progress)
+	assert.Equal(t, schema.OrderBatchLineResponse{Line: 2, OrderID: 2002, SkuID: 1, PhoneNumber: "0981234567", TotalPrice: 10000, CashBackValue: 500, Status: model.PurchaseHistoryStatusFailed}, got.Lines[1])
+
+	_, err = orderService.GetOrderBatch(context.Background(), 5001, 2)
+	assert.EqualError(t, err, "order batch not found", "a batch of another user")
+	_, err = orderService.GetOrderBatch(context.Background(), 5002, 1)
+	assert.EqualError(t, err, "order batch not found")
+}
+
+func TestOrderService_DispatchOrderBatches(t *testing.T) {
+	received := make(chan schema.OrderProviderRequest, 2)
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request schema.OrderProviderRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		received <- request
+	}))
+	defer provider.Close()
+
+	batchID := uint(5001)
+	cachedOrder := util.CreateCachedOrderResponse(2001, 1, 10000, "0981234567", 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+	cachedOrder.Status = model.PurchaseHistoryStatusConfirm
+	cachedOrder.BatchID = &batchID
+	cachedOrderJSON, _ := json.Marshal(cachedOrder)
+
+	redis := new(mockGrpc.RedisMock)
+	redis.On("Get", mock.Anything, "order_id2001").Return(string(cachedOrderJSON), nil)
+	redis.On("Get", mock.Anything, "order_id2002").Return("", errors.New("cache unavailable"))
+
+	batchRepo := new(mockRepo.OrderBatchRepositoryMock)
+	batchRepo.On("ClaimOrderBatchOrders", mock.Anything, 10).Return([]uint{2001, 2002}, nil)
+	orderRepo := new(mockRepo.OrderRepositoryMock)
+	orderRepo.On("GetOrderByOrderID", mock.Anything, uint(2002)).Return(nil, errors.New("connection refused"))
+	orderRepo.On("AssignOrderProvider", mock.Anything, uint(2001), "PROVIDER1").Return(nil).Once()
+	purchaseRepo := new(mockRepo.PurchaseHistoryRepositoryMock)
+	util.SetupDefaultPurchaseHistoryProviderMocks(purchaseRepo)
+
+	providerRepo := new(mockRepo.ProviderRepositoryMock)
+	providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return([]model.Provider{
+		util.CreateMockProvider(1, "PROVIDER1", provider.URL, "http", 100, []model.Supplier{util.CreateMockSupplier("VTL", "Viettel")}),
+	}, nil)
+	orderService := newOrderBatchTestService(new(mockRepo.SkuRepositoryMock), purchaseRepo, orderRepo, new(mockRepo.OrderStatusEventRepositoryMock), new(mockRepo.OutboxRepositoryMock), redis, providerRepo, batchRepo)
+	dispatched, err := orderService.DispatchOrderBatches(context.Background(), 10)
+	orderService.WaitForDispatches()
+
+	assert.Equal(t, 1, dispatched)
+	assert.ErrorContains(t, err, "order 2002: connection refused", "an order that can't be read is reported")
+	assert.Len(t, received, 1)
+	assert.Equal(t, uint(2001), (<-received).OrderID)
+	orderRepo.AssertExpectations(t)
+}
+
+func TestOrderService_ExpirePendingOrderBatches(t *testing.T) {
+	createdBefore := time.Now().Add(-30 * time.Minute)
+
+	redis := new(mockGrpc.RedisMock)
+	for _, batchID := range []string{"5001", "5002"} {
+		redis.On("TryAcquireLock", mock.Anything, "order_batch:"+batchID, mock.AnythingOfType("time.Duration")).Return(util.NewTestLock("order_batch:"+batchID), nil)
+		redis.On("ReleaseLock", mock.Anything, util.LockOf("order_batch:"+batchID)).Return(nil)
+	}
+	redis.On("Set", mock.Anything, "order_id2001", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil).Once()
+	redis.On("Publish", mock.Anything, "order_status:2001", mock.AnythingOfType("[]uint8")).Return(nil).Once()
+
+	paidBatch := createTestOrderBatch(model.PurchaseHistoryStatusConfirm, 2002)
+	paidBatch.BatchID = 5002
+	batchRepo := new(mockRepo.OrderBatchRepositoryMock)
+	batchRepo.On("GetPendingOrderBatchIDsCreatedBefore", mock.Anything, createdBefore, 50).Return([]uint{5001, 5002}, nil)
+	batchRepo.On("GetOrderBatchByBatchID", mock.Anything, uint(5001)).Return(createTestOrderBatch(model.PurchaseHistoryStatusPending, 2001), nil)
+	batchRepo.On("GetOrderBatchByBatchID", mock.Anything, uint(5002)).Return(paidBatch, nil)
+	batchRepo.On("UpdateOrderBatchStatus", mock.Anything, uint(5001), model.PurchaseHistoryStatusPending, model.PurchaseHistoryStatusExpired, "").Return(nil).Once()
+	purchaseRepo := new(mockRepo.PurchaseHistoryRepositoryMock)
+	purchaseRepo.On("CreatePurchaseHistory", mock.Anything, mock.MatchedBy(func(history *model.PurchaseHistory) bool {
+		return history.OrderID == 2001 && history.Status == model.PurchaseHistoryStatusExpired && history.PaymentReference == ""
+	})).Return(nil).Once()
+	orderRepo := new(mockRepo.OrderRepositoryMock)
+	orderRepo.On("UpdateOrderStatusByOrderID", mock.Anything, uint(2001), model.PurchaseHistoryStatusExpired, int64(1)).Return(nil).Once()
+	eventRepo := new(mockRepo.OrderStatusEventRepositoryMock)
+	eventRepo.On("CreateOrderStatusEvent", mock.Anything, mock.MatchedBy(func(event *model.OrderStatusEvent) bool {
+		return event.OrderID == 2001 && event.Source == model.OrderStatusEventSourceExpirySweeper && event.Status == model.PurchaseHistoryStatusExpired
+	})).Return(nil).Once()
+	outboxRepo := new(mockRepo.OutboxRepositoryMock)
+	outboxRepo.On("CreateOutboxMessage", mock.Anything, mock.MatchedBy(func(message *model.OutboxMessage) bool {
+		return message.AggregateID == 5001 && message.EventType == model.OutboxEventOrderBatchExpired && message.Method == http.MethodPatch &&
+			strings.Contains(message.Payload, `"batch_id":5001`) && strings.Contains(message.Payload, `"status":"expired"`)
+	})).Return(nil).Once()
+
+	providerRepo := new(mockRepo.ProviderRepositoryMock)
+	providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+	orderService := newOrderBatchTestService(new(mockRepo.SkuRepositoryMock), purchaseRepo, orderRepo, eventRepo, outboxRepo, redis, providerRepo, batchRepo)
+	expired, err := orderService.ExpirePendingOrderBatches(context.Background(), createdBefore, 50)
+
+	assert.NoError(t, err, "a batch paid since it was listed is skipped")
+	assert.Equal(t, 1, expired)
+	redis.AssertExpectations(t)
+	batchRepo.AssertExpectations(t)
+	purchaseRepo.AssertExpectations(t)
+	orderRepo.AssertExpectations(t)
+	eventRepo.AssertExpectations(t)
+	outboxRepo.AssertExpectations(t)
+}
@@ -4,13 +4,20 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"top-up-api/config"
 	grpcClient "top-up-api/internal/grpc/client"
 	"top-up-api/internal/model"
+	"top-up-api/internal/repository"
 	"top-up-api/internal/schema"
 	"top-up-api/internal/service"
+	redisPkg "top-up-api/pkg/redis"
 	mockGrpc "top-up-api/tests/mock"
 	mockRepo "top-up-api/tests/repository/mock"
 	"top-up-api/tests/util"
@@ -21,181 +28,283 @@ import (
 )
 
 var (
+	dispatchTestConfig = config.ProviderDispatch{
+		MaxAttempts:    2,
+		RetryBackoff:   time.Millisecond,
+		RequestTimeout: 100 * time.Millisecond,
+	}
+
+	paymentTestConfig = config.Payment{
+		CreateURL:      "http://payment.test/v1/api/order/create",
+		UpdateURL:      "http://payment.test/v1/api/order/update",
+		CallbackURL:    "http://topup.test/v1/api/order/update-status",
+		BatchCreateURL: "http://payment.test/v1/api/order/batch/create",
+		BatchUpdateURL: "http://payment.test/v1/api/order/batch/update",
+	}
+
 	orderReqPercentage = schema.OrderRequest{
 		UserID:      1,
 		SkuID:       1,
-		PhoneNumber: "081234567890",
+		PhoneNumber: "0981234567",
 	}
 	orderReqFixed = schema.OrderRequest{
 		UserID:      2,
 		SkuID:       2,
-		PhoneNumber: "082345678901",
+		PhoneNumber: "0901234567",
 	}
 	orderReqZeroWeight = schema.OrderRequest{
 		UserID:      3,
 		SkuID:       3,
-		PhoneNumber: "083456789012",
+		PhoneNumber: "0971234567",
 	}
 	orderReqNotFound = schema.OrderRequest{
 		UserID:      1,
 		SkuID:       999,
-		PhoneNumber: "081234567890",
+		PhoneNumber: "0981234567",
 	}
 	orderReqDBError = schema.OrderRequest{
 		UserID:      1,
 		SkuID:       1,
-		PhoneNumber: "081234567890",
+		PhoneNumber: "0981234567",
 	}
 	orderReqTimeout = schema.OrderRequest{
 		UserID:      1,
 		SkuID:       1,
-		PhoneNumber: "081234567890",
+		PhoneNumber: "0981234567",
 	}
 	orderReqRedisSetError = schema.OrderRequest{
 		UserID:      1,
 		SkuID:       1,
-		PhoneNumber: "081234567890",
+		PhoneNumber: "0981234567",
 	}
 	orderReqRedisTimeout = schema.OrderRequest{
 		UserID:      4,
 		SkuID:       4,
-		PhoneNumber: "084567890123",
+		PhoneNumber: "0912345678",
 	}
 	orderReqLarge = schema.OrderRequest{
 		UserID:      999999,
 		SkuID:       100,
-		PhoneNumber: "+6285567890123",
+		PhoneNumber: "+84 92 345 6789",
 	}
 	orderReqEmptyPhone = schema.OrderRequest{
 		UserID:      5,
 		SkuID:       5,
 		PhoneNumber: "",
 	}
+	orderReqWallet = schema.OrderRequest{
+		UserID:       1,
+		SkuID:        1,
+		PhoneNumber:  "0981234567",
+		WalletAmount: 3000,
+	}
+	orderReqWalletAboveTotal = schema.OrderRequest{
+		UserID:       1,
+		SkuID:        1,
+		PhoneNumber:  "0981234567",
+		WalletAmount: 10001,
+	}
+	orderReqVoucher = schema.OrderRequest{
+		UserID:      1,
+		SkuID:       1,
+		PhoneNumber: "0981234567",
+		VoucherCode: " summer10",
+	}
+
+	voucherPromotion = model.Promotion{
+		Model:    gorm.Model{ID: 7},
+		Code:     "SUMMER10",
+		Type:     model.CashBackTypeFixed,
+		Value:    2000,
+		StartsAt: time.Now().Add(-time.Hour),
+		Voucher:  true,
+		Active:   true,
+	}
 
 	confirmReqVTLConfirmStatus = schema.OrderConfirmRequest{
-		OrderID:       1001,
-		UserID:        1,
-		SkuID:         1,
-		TotalPrice:    10000,
-		Status:        model.PurchaseHistoryStatusConfirm,
-		PhoneNumber:   "081234567890",
-		CashBackValue: 500,
+		OrderID:          1001,
+		UserID:           1,
+		SkuID:            1,
+		TotalPrice:       10000,
+		Status:           model.PurchaseHistoryStatusConfirm,
+		PhoneNumber:      "0981234567",
+		CashBackValue:    500,
+		PaymentReference: "PAY-1001",
 	}
 	confirmReqMBFFailedStatus = schema.OrderConfirmRequest{
-		OrderID:       1002,
-		UserID:        2,
-		SkuID:         2,
-		TotalPrice:    20000,
-		Status:        model.PurchaseHistoryStatusFailed,
-		PhoneNumber:   "082345678901",
-		CashBackValue: 1000,
+		OrderID:          1002,
+		UserID:           2,
+		SkuID:            2,
+		TotalPrice:       20000,
+		Status:           model.PurchaseHistoryStatusFailed,
+		PhoneNumber:      "0901234567",
+		CashBackValue:    1000,
+		PaymentReference: "PAY-1002",
 	}
 	confirmReqVTLFailedLock = schema.OrderConfirmRequest{
-		OrderID:       1003,
-		UserID:        1,
-		SkuID:         1,
-		TotalPrice:    10000,
-		Status:        model.PurchaseHistoryStatusConfirm,
-		PhoneNumber:   "081234567890",
-		CashBackValue: 500,
+		OrderID:          1003,
+		UserID:           1,
+		SkuID:            1,
+		TotalPrice:       10000,
+		Status:           model.PurchaseHistoryStatusConfirm,
+		PhoneNumber:      "0981234567",
+		CashBackValue:    500,
+		PaymentReference: "PAY-1003",
 	}
 	confirmReqVTLNotFound = schema.OrderConfirmRequest{
-		OrderID:       1004,
-		UserID:        1,
-		SkuID:         1,
-		TotalPrice:    10000,
-		Status:        model.PurchaseHistoryStatusConfirm,
-		PhoneNumber:   "081234567890",
-		CashBackValue: 500,
+		OrderID:          1004,
+		UserID:           1,
+		SkuID:            1,
+		TotalPrice:       10000,
+		Status:           model.PurchaseHistoryStatusConfirm,
+		PhoneNumber:      "0981234567",
+		CashBackValue:    500,
+		PaymentReference: "PAY-1004",
 	}
 	confirmReqVTLCorrupt = schema.OrderConfirmRequest{
-		OrderID:       1005,
-		UserID:        1,
-		SkuID:         1,
-		TotalPrice:    10000,
-		Status:        model.PurchaseHistoryStatusConfirm,
-		PhoneNumber:   "081234567890",
-		CashBackValue: 500,
+		OrderID:          1005,
+		UserID:           1,
+		SkuID:            1,
+		TotalPrice:       10000,
+		Status:           model.PurchaseHistoryStatusConfirm,
+		PhoneNumber:      "0981234567",
+		CashBackValue:    500,
+		PaymentReference: "PAY-1005",
 	}
 	confirmReqVTLUserMismatchMain = schema.OrderConfirmRequest{
-		OrderID:       1006,
-		UserID:        2,
-		SkuID:         1,
-		TotalPrice:    10000,
-		Status:        model.PurchaseHistoryStatusConfirm,
-		PhoneNumber:   "081234567890",
-		CashBackValue: 500,
+		OrderID:          1006,
+		UserID:           2,
+		SkuID:            1,
+		TotalPrice:       10000,
+		Status:           model.PurchaseHistoryStatusConfirm,
+		PhoneNumber:      "0981234567",
+		CashBackValue:    500,
+		PaymentReference: "PAY-1006",
 	}
 	confirmReqVTLPriceMismatchMain = schema.OrderConfirmRequest{
-		OrderID:       1007,
-		UserID:        1,
-		SkuID:         1,
-		TotalPrice:    15000,
-		Status:        model.PurchaseHistoryStatusConfirm,
-		PhoneNumber:   "081234567890",
-		CashBackValue: 500,
+		OrderID:          1007,
+		UserID:           1,
+		SkuID:            1,
+		TotalPrice:       15000,
+		Status:           model.PurchaseHistoryStatusConfirm,
+		PhoneNumber:      "0981234567",
+		CashBackValue:    500,
+		PaymentReference: "PAY-1007",
 	}
 	confirmReqVTLPendingMain = schema.OrderConfirmRequest{
-		OrderID:       1008,
-		UserID:        1,
-		SkuID:         1,
-		TotalPrice:    10000,
-		Status:        model.PurchaseHistoryStatusPending,
-		PhoneNumber:   "081234567890",
-		CashBackValue: 500,
+		OrderID:          1008,
+		UserID:           1,
+		SkuID:            1,
+		TotalPrice:       10000,
+		Status:           model.PurchaseHistoryStatusPending,
+		PhoneNumber:      "0981234567",
+		CashBackValue:    500,
+		PaymentReference: "PAY-1008",
+	}
+	confirmReqVTLExpiredMain = schema.OrderConfirmRequest{
+		OrderID:          1008,
+		UserID:           1,
+		SkuID:            1,
+		TotalPrice:       10000,
+		Status:           model.PurchaseHistoryStatusExpired,
+		PhoneNumber:      "0981234567",
+		CashBackValue:    500,
+		PaymentReference: "PAY-1008",
 	}
 	confirmReqVTLAlreadyConfirmedMain = schema.OrderConfirmRequest{
-		OrderID:       1009,
-		UserID:        1,
-		SkuID:         1,
-		TotalPrice:    10000,
-		Status:        model.PurchaseHistoryStatusConfirm,
-		PhoneNumber:   "081234567890",
-		CashBackValue: 500,
+		OrderID:          1009,
+		UserID:           1,
+		SkuID:            1,
+		TotalPrice:       10000,
+		Status:           model.PurchaseHistoryStatusConfirm,
+		PhoneNumber:      "0981234567",
+		CashBackValue:    500,
+		PaymentReference: "PAY-1009",
 	}
 	confirmReqVTLDBErrorMain = schema.OrderConfirmRequest{
-		OrderID:       1010,
+		OrderID:          1010,
+		UserID:           1,
+		SkuID:            1,
+		TotalPrice:       10000,
+		Status:           model.PurchaseHistoryStatusConfirm,
+		PhoneNumber:      "0981234567",
+		CashBackValue:    500,
+		PaymentReference: "PAY-1010",
+	}
+	confirmReqVTLNoReference = schema.OrderConfirmRequest{
+		OrderID:       1011,
 		UserID:        1,
 		SkuID:         1,
 		TotalPrice:    10000,
 		Status:        model.PurchaseHistoryStatusConfirm,
-		PhoneNumber:   "081234567890",
+		PhoneNumber:   "0981234567",
 		CashBackValue: 500,
 	}
+	confirmReqVTLSkuMismatchMain = schema.OrderConfirmRequest{
+		OrderID:          1012,
+		UserID:           1,
+		SkuID:            2,
+		TotalPrice:       10000,
+		Status:           model.PurchaseHistoryStatusConfirm,
+		PhoneNumber:      "0981234567",
+		CashBackValue:    500,
+		PaymentReference: "PAY-1012",
+	}
+	confirmReqVTLReusedReference = schema.OrderConfirmRequest{
+		OrderID:          1013,
+		UserID:           1,
+		SkuID:            1,
+		TotalPrice:       10000,
+		Status:           model.PurchaseHistoryStatusConfirm,
+		PhoneNumber:      "0981234567",
+		CashBackValue:    500,
+		PaymentReference: "PAY-1001",
+	}
 
 	updateReqSuccess = schema.OrderUpdateRequest{
-		OrderID:     1001,
-		Status:      model.PurchaseHistoryStatusSuccess,
-		PhoneNumber: "081234567890",
+		OrderID:      1001,
+		Status:       model.PurchaseHistoryStatusSuccess,
+		PhoneNumber:  "0981234567",
+		ProviderCode: "PROVIDER1",
 	}
 	updateReqFailed = schema.OrderUpdateRequest{
-		OrderID:     1001,
-		Status:      model.PurchaseHistoryStatusFailed,
-		PhoneNumber: "081234567890",
+		OrderID:      1001,
+		Status:       model.PurchaseHistoryStatusFailed,
+		PhoneNumber:  "0981234567",
+		ProviderCode: "PROVIDER1",
 	}
 )
 
 type CreateOrderTestCase struct {
-	Name          string
-	OrderRequest  schema.OrderRequest
-	SetupMocks    func(*mockRepo.SkuRepositoryMock, *mockGrpc.RedisMock, *mockRepo.ProviderRepositoryMock)
-	ExpectedError string
-	Assert        func(*testing.T, *schema.OrderResponse)
+	Name               string
+	OrderRequest       schema.OrderRequest
+	SetupMocks         func(*mockRepo.SkuRepositoryMock, *mockGrpc.RedisMock, *mockRepo.ProviderRepositoryMock)
+	ExpectedError      string
+	Assert             func(*testing.T, *schema.OrderResponse)
+	SetupOrderRepo     func(*mockRepo.OrderRepositoryMock)     // Optional, defaults to util.SetupDefaultOrderRepoMocks
+	SetupOutboxRepo    func(*mockRepo.OutboxRepositoryMock)    // Optional, defaults to util.SetupDefaultOutboxMocks
+	SetupLedgerRepo    func(*mockRepo.LedgerRepositoryMock)    // Optional, no wallet posting is expected by default
+	SetupPromotionRepo func(*mockRepo.PromotionRepositoryMock) // Optional, defaults to util.SetupDefaultPromotionMocks
 }
 
 type ConfirmOrderTestCase struct {
 	Name                string
 	OrderConfirmRequest schema.OrderConfirmRequest
-	SetupMocks          func(*mockRepo.SkuRepositoryMock, *mockRepo.PurchaseHistoryRepositoryMock, *mockGrpc.RedisMock, *mockRepo.ProviderRepositoryMock)
+	SetupMocks          func(*mockRepo.SkuRepositoryMock, *mockRepo.PurchaseHistoryRepositoryMock, *mockRepo.OrderRepositoryMock, *mockGrpc.RedisMock, *mockRepo.ProviderRepositoryMock)
 	ExpectedError       string
-	GRPCSetup           *util.GRPCClientSetup // Optional gRPC setup configuration
+	GRPCSetup           *util.GRPCClientSetup               // Optional gRPC setup configuration
+	SetupOrderRepo      func(*mockRepo.OrderRepositoryMock) // Optional, defaults to util.SetupDefaultOrderRepoMocks
 }
 
 type UpdateOrderStatusTestCase struct {
 	Name               string
 	OrderUpdateRequest schema.OrderUpdateRequest
-	SetupMocks         func(*mockRepo.SkuRepositoryMock, *mockRepo.PurchaseHistoryRepositoryMock, *mockGrpc.RedisMock, *mockRepo.ProviderRepositoryMock)
+	SetupMocks         func(*mockRepo.SkuRepositoryMock, *mockRepo.PurchaseHistoryRepositoryMock, *mockRepo.OrderRepositoryMock, *mockGrpc.RedisMock, *mockRepo.ProviderRepositoryMock)
 	ExpectedError      string
+	SetupOrderRepo     func(*mockRepo.OrderRepositoryMock)     // Optional, defaults to util.SetupDefaultOrderRepoMocks
+	SetupOutboxRepo    func(*mockRepo.OutboxRepositoryMock)    // Optional, defaults to util.SetupDefaultOutboxMocks
+	SetupLedgerRepo    func(*mockRepo.LedgerRepositoryMock)    // Optional, no wallet posting is expected by default
+	SetupPromotionRepo func(*mockRepo.PromotionRepositoryMock) // Optional, no promotion is released by default
 }
 
 func runTableDrivenTests[T any](t *testing.T, cases []T, run func(*testing.T, T)) {
@@ -234,6 +343,12 @@ func TestOrderService_CreateOrder(t *testing.T) {
 				}
 				util.SetupBasicMocks(skuRepo, redis, providerRepo, mockSku, providers)
 			},
+			SetupOrderRepo: func(orderRepo *mockRepo.OrderRepositoryMock) {
+				orderRepo.On("CreateOrder", mock.Anything, mock.MatchedBy(func(o *model.Order) bool {
+					return o.UserID == orderReqPercentage.UserID && o.SkuID == orderReqPercentage.SkuID &&
+						o.TotalPrice == 10000 && o.CashBackValue == 500 && o.Status == model.PurchaseHistoryStatusPending
+				})).Return(nil)
+			},
 			ExpectedError: "",
 			Assert: func(t *testing.T, result *schema.OrderResponse) {
 				assert.NotNil(t, result)
@@ -243,8 +358,6 @@ func TestOrderService_CreateOrder(t *testing.T) {
 				assert.Equal(t, 10000, result.TotalPrice)
 				assert.Equal(t, model.PurchaseHistoryStatusPending, result.Status)
 				assert.Greater(t, result.OrderID, uint(0))
-				assert.GreaterOrEqual(t, result.RandomProviderWeight, 0)
-				assert.LessOrEqual(t, result.RandomProviderWeight, 100)
 				assert.Equal(t, 500, result.CashBackValue) // 5% of 10000 = 500
 				assert.Equal(t, "VTL", result.Sku.SupplierInfo.Code)
 				assert.Equal(t, "Viettel", result.Sku.SupplierInfo.Name)
@@ -274,9 +387,7 @@ func TestOrderService_CreateOrder(t *testing.T) {
 				assert.Equal(t, 20000, result.TotalPrice)
 				assert.Equal(t, model.PurchaseHistoryStatusPending, result.Status)
 				assert.Greater(t, result.OrderID, uint(0))
-				assert.GreaterOrEqual(t, result.RandomProviderWeight, 0)
-				assert.LessOrEqual(t, result.RandomProviderWeight, 80) // Total weight 50+30=80
-				assert.Equal(t, 1000, result.CashBackValue)            // Fixed cashback
+				assert.Equal(t, 1000, result.CashBackValue) // Fixed cashback
 				assert.Equal(t, "MBF", result.Sku.SupplierInfo.Code)
 			},
 		},
@@ -299,8 +410,7 @@ func TestOrderService_CreateOrder(t *testing.T) {
 				assert.Equal(t, orderReqZeroWeight.PhoneNumber, result.PhoneNumber)
 				assert.Equal(t, 50000, result.TotalPrice)
 				assert.Equal(t, model.PurchaseHistoryStatusPending, result.Status)
-				assert.Equal(t, 0, result.CashBackValue)        // No cashback
-				assert.Equal(t, 0, result.RandomProviderWeight) // Zero total weight
+				assert.Equal(t, 0, result.CashBackValue) // No cashback
 			},
 		},
 		{
@@ -348,36 +458,129 @@ func TestOrderService_CreateOrder(t *testing.T) {
 				}
 				util.SetupCacheErrorMocks(skuRepo, redis, providerRepo, mockSku, providers, errors.New("redis connection failed"))
 			},
-			ExpectedError: "redis connection failed",
+			ExpectedError: "",
 			Assert: func(t *testing.T, result *schema.OrderResponse) {
-				assert.Nil(t, result)
+				// The order is already persisted, so a cache failure must not fail the request
+				assert.NotNil(t, result)
+				assert.Equal(t, model.PurchaseHistoryStatusPending, result.Status)
 			},
 		},
 		{
 			Name:         "redis timeout error",
 			OrderRequest: orderReqRedisTimeout,
 			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
-				mockSku := util.CreateMockSku(4, "XL", 15000, model.CashBackTypeFixed, 500, "XL Axiata")
+				mockSku := util.CreateMockSku(4, "VNP", 15000, model.CashBackTypeFixed, 500, "VinaPhone")
 				providers := []model.Provider{
 					util.CreateMockProvider(1, "PROVIDER1", "http://provider1.com", "http", 75, []model.Supplier{
-						util.CreateMockSupplier("XL", "XL Axiata"),
+						util.CreateMockSupplier("VNP", "VinaPhone"),
 					}),
 				}
 				util.SetupCacheErrorMocks(skuRepo, redis, providerRepo, mockSku, providers, errors.New("redis timeout"))
 			},
-			ExpectedError: "redis timeout",
+			ExpectedError: "",
+			Assert: func(t *testing.T, result *schema.OrderResponse) {
+				assert.NotNil(t, result)
+				assert.Equal(t, 15000, result.TotalPrice)
+			},
+		},
+		{
+			Name:         "order repository error",
+			OrderRequest: orderReqDBError,
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				mockSku := util.CreateMockSku(1, "VTL", 10000, model.CashBackTypePercentage, 5, "Viettel")
+				skuRepo.On("GetSkuByID", mock.Anything, uint(1)).Return(mockSku, nil)
+				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+			},
+			SetupOrderRepo: func(orderRepo *mockRepo.OrderRepositoryMock) {
+				orderRepo.On("CreateOrder", mock.Anything, mock.AnythingOfType("*model.Order")).Return(errors.New("insert failed"))
+			},
+			ExpectedError: "insert failed",
+			Assert: func(t *testing.T, result *schema.OrderResponse) {
+				assert.Nil(t, result)
+			},
+		},
+		{
+			Name:         "outbox write error",
+			OrderRequest: orderReqDBError,
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				mockSku := util.CreateMockSku(1, "VTL", 10000, model.CashBackTypePercentage, 5, "Viettel")
+				skuRepo.On("GetSkuByID", mock.Anything, uint(1)).Return(mockSku, nil)
+				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+			},
+			SetupOutboxRepo: func(outboxRepo *mockRepo.OutboxRepositoryMock) {
+				outboxRepo.On("CreateOutboxMessage", mock.Anything, mock.MatchedBy(func(message *model.OutboxMessage) bool {
+					return message.EventType == model.OutboxEventOrderCreated && message.Method == "POST" && message.Destination == paymentTestConfig.CreateURL
+				})).Return(errors.New("outbox insert failed"))
+			},
+			ExpectedError: "outbox insert failed",
+			Assert: func(t *testing.T, result *schema.OrderResponse) {
+				assert.Nil(t, result)
+			},
+		},
+		{
+			Name:         "wallet pays part of the order",
+			OrderRequest: orderReqWallet,
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				mockSku := util.CreateMockSku(1, "VTL", 10000, model.CashBackTypePercentage, 5, "Viettel")
+				util.SetupBasicMocks(skuRepo, redis, providerRepo, mockSku, util.SingleProvider("VTL", "Viettel"))
+			},
+			SetupOrderRepo: func(orderRepo *mockRepo.OrderRepositoryMock) {
+				orderRepo.On("CreateOrder", mock.Anything, mock.MatchedBy(func(o *model.Order) bool {
+					return o.TotalPrice == 10000 && o.WalletAmount == 3000
+				})).Return(nil)
+			},
+			SetupLedgerRepo: func(ledgerRepo *mockRepo.LedgerRepositoryMock) {
+				util.SetupDefaultLedgerAccountMocks(ledgerRepo)
+				ledgerRepo.On("CreateLedgerTransaction", mock.Anything, mock.MatchedBy(func(transaction *model.LedgerTransaction) bool {
+					return transaction.Type == model.LedgerTransactionTypeOrderPayment && transaction.Balanced() &&
+						transaction.Entries[0].AccountID == util.TestWalletAccountID && transaction.Entries[0].Amount == -3000
+				})).Return(nil).Once()
+			},
+			ExpectedError: "",
+			Assert: func(t *testing.T, result *schema.OrderResponse) {
+				assert.NotNil(t, result)
+				assert.Equal(t, 10000, result.TotalPrice)
+				assert.Equal(t, 3000, result.WalletAmount)
+			},
+		},
+		{
+			Name:         "wallet balance too low",
+			OrderRequest: orderReqWallet,
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				mockSku := util.CreateMockSku(1, "VTL", 10000, model.CashBackTypePercentage, 5, "Viettel")
+				skuRepo.On("GetSkuByID", mock.Anything, uint(1)).Return(mockSku, nil)
+				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+			},
+			SetupLedgerRepo: func(ledgerRepo *mockRepo.LedgerRepositoryMock) {
+				util.SetupDefaultLedgerAccountMocks(ledgerRepo)
+				ledgerRepo.On("CreateLedgerTransaction", mock.Anything, mock.AnythingOfType("*model.LedgerTransaction")).Return(repository.ErrInsufficientBalance)
+			},
+			ExpectedError: "insufficient wallet balance",
+			Assert: func(t *testing.T, result *schema.OrderResponse) {
+				assert.Nil(t, result)
+			},
+		},
+		{
+			Name:         "wallet amount above the order total",
+			OrderRequest: orderReqWalletAboveTotal,
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				mockSku := util.CreateMockSku(1, "VTL", 10000, model.CashBackTypePercentage, 5, "Viettel")
+				skuRepo.On("GetSkuByID", mock.Anything, uint(1)).Return(mockSku, nil)
+				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+			},
+			ExpectedError: "wallet amount must be between 0 and the order total",
 			Assert: func(t *testing.T, result *schema.OrderResponse) {
 				assert.Nil(t, result)
 			},
 		},
 		{
-			Name:         "successful order with large phone number and high price",
+			Name:         "successful order with an international phone number and high price",
 			OrderRequest: orderReqLarge,
 			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
-				mockSku := util.CreateMockSku(100, "THREE", 500000, model.CashBackTypePercentage, 10, "3 (Tri)")
+				mockSku := util.CreateMockSku(100, "VNM", 500000, model.CashBackTypePercentage, 10, "Vietnamobile")
 				providers := []model.Provider{
 					util.CreateMockProvider(1, "PROVIDER1", "http://provider1.com", "http", 1000, []model.Supplier{
-						util.CreateMockSupplier("THREE", "3 (Tri)"),
+						util.CreateMockSupplier("VNM", "Vietnamobile"),
 					}),
 				}
 				util.SetupBasicMocks(skuRepo, redis, providerRepo, mockSku, providers)
@@ -387,32 +590,94 @@ func TestOrderService_CreateOrder(t *testing.T) {
 				assert.NotNil(t, result)
 				assert.Equal(t, orderReqLarge.UserID, result.UserID)
 				assert.Equal(t, orderReqLarge.SkuID, result.Sku.ID)
-				assert.Equal(t, orderReqLarge.PhoneNumber, result.PhoneNumber)
+				assert.Equal(t, "0923456789", result.PhoneNumber) // Stored in its national form
 				assert.Equal(t, 500000, result.TotalPrice)
 				assert.Equal(t, 50000, result.CashBackValue) // 10% of 500000
-				assert.GreaterOrEqual(t, result.RandomProviderWeight, 0)
-				assert.LessOrEqual(t, result.RandomProviderWeight, 1000)
 			},
 		},
 		{
-			Name:         "empty phone number - edge case",
+			Name:         "empty phone number is rejected",
 			OrderRequest: orderReqEmptyPhone,
 			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
-				mockSku := util.CreateMockSku(5, "SMARTFREN", 25000, model.CashBackTypeFixed, 2500, "Smartfren")
-				providers := []model.Provider{
-					util.CreateMockProvider(1, "PROVIDER1", "http://provider1.com", "http", 200, []model.Supplier{
-						util.CreateMockSupplier("SMARTFREN", "Smartfren"),
-					}),
-				}
-				util.SetupBasicMocks(skuRepo, redis, providerRepo, mockSku, providers)
+				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+			},
+			ExpectedError: "phone number must be a Vietnamese mobile number",
+			Assert: func(t *testing.T, result *schema.OrderResponse) {
+				assert.Nil(t, result)
+			},
+		},
+		{
+			Name:         "phone number of another carrier than the sku",
+			OrderRequest: orderReqPercentage,
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				mockSku := util.CreateMockSku(1, "MBF", 10000, model.CashBackTypePercentage, 5, "MobiFone")
+				skuRepo.On("GetSkuByID", mock.Anything, uint(1)).Return(mockSku, nil)
+				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("MBF", "MobiFone"), nil)
+			},
+			SetupOrderRepo: func(orderRepo *mockRepo.OrderRepositoryMock) {},
+			ExpectedError:  "phone number 0981234567 is a VTL number, the sku is for MBF",
+			Assert: func(t *testing.T, result *schema.OrderResponse) {
+				assert.Nil(t, result)
+			},
+		},
+		{
+			Name:         "voucher promotion replaces the sku cashback",
+			OrderRequest: orderReqVoucher,
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				mockSku := util.CreateMockSku(1, "VTL", 10000, model.CashBackTypePercentage, 5, "Viettel")
+				util.SetupBasicMocks(skuRepo, redis, providerRepo, mockSku, util.SingleProvider("VTL", "Viettel"))
+			},
+			SetupOrderRepo: func(orderRepo *mockRepo.OrderRepositoryMock) {
+				orderRepo.On("CreateOrder", mock.Anything, mock.MatchedBy(func(o *model.Order) bool {
+					return o.CashBackValue == 2000
+				})).Return(nil)
+			},
+			SetupPromotionRepo: func(promotionRepo *mockRepo.PromotionRepositoryMock) {
+				promotionRepo.On("GetAvailablePromotions", mock.Anything, mock.AnythingOfType("time.Time"), "SUMMER10").Return([]model.Promotion{voucherPromotion}, nil)
+				promotionRepo.On("RedeemPromotion", mock.Anything, mock.MatchedBy(func(redemption *model.PromotionRedemption) bool {
+					return redemption.PromotionID == 7 && redemption.UserID == 1 && redemption.OrderID != 0 &&
+						redemption.Code == "SUMMER10" && redemption.CashBackValue == 2000
+				})).Return(nil).Once()
 			},
 			ExpectedError: "",
 			Assert: func(t *testing.T, result *schema.OrderResponse) {
 				assert.NotNil(t, result)
-				assert.Equal(t, orderReqEmptyPhone.UserID, result.UserID)
-				assert.Equal(t, "", result.PhoneNumber) // Empty phone number should be preserved
-				assert.Equal(t, 25000, result.TotalPrice)
-				assert.Equal(t, 2500, result.CashBackValue)
+				assert.Equal(t, 2000, result.CashBackValue)
+				assert.Equal(t, []schema.AppliedPromotion{{PromotionID: 7, Code: "SUMMER10", CashBackValue: 2000}}, result.Promotions)
+			},
+		},
+		{
+			Name:         "unknown voucher code",
+			OrderRequest: orderReqVoucher,
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				mockSku := util.CreateMockSku(1, "VTL", 10000, model.CashBackTypePercentage, 5, "Viettel")
+				skuRepo.On("GetSkuByID", mock.Anything, uint(1)).Return(mockSku, nil)
+				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+			},
+			SetupOrderRepo: func(orderRepo *mockRepo.OrderRepositoryMock) {},
+			SetupPromotionRepo: func(promotionRepo *mockRepo.PromotionRepositoryMock) {
+				promotionRepo.On("GetAvailablePromotions", mock.Anything, mock.AnythingOfType("time.Time"), "SUMMER10").Return([]model.Promotion{}, nil)
+			},
+			ExpectedError: "voucher code SUMMER10 is not valid for this order",
+			Assert: func(t *testing.T, result *schema.OrderResponse) {
+				assert.Nil(t, result)
+			},
+		},
+		{
+			Name:         "voucher ran out while the order was created",
+			OrderRequest: orderReqVoucher,
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				mockSku := util.CreateMockSku(1, "VTL", 10000, model.CashBackTypePercentage, 5, "Viettel")
+				skuRepo.On("GetSkuByID", mock.Anything, uint(1)).Return(mockSku, nil)
+				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+			},
+			SetupPromotionRepo: func(promotionRepo *mockRepo.PromotionRepositoryMock) {
+				promotionRepo.On("GetAvailablePromotions", mock.Anything, mock.AnythingOfType("time.Time"), "SUMMER10").Return([]model.Promotion{voucherPromotion}, nil)
+				promotionRepo.On("RedeemPromotion", mock.Anything, mock.AnythingOfType("*model.PromotionRedemption")).Return(repository.ErrPromotionExhausted)
+			},
+			ExpectedError: "promotion SUMMER10 is no longer available",
+			Assert: func(t *testing.T, result *schema.OrderResponse) {
+				assert.Nil(t, result)
 			},
 		},
 	}
@@ -425,9 +690,40 @@ func TestOrderService_CreateOrder(t *testing.T) {
 		}
 		providerRepo := new(mockRepo.ProviderRepositoryMock)
 
+		orderRepo := new(mockRepo.OrderRepositoryMock)
+		txManager := new(mockRepo.TransactionManagerMock)
+		util.SetupTransactionMocks(txManager)
+		eventRepo := new(mockRepo.OrderStatusEventRepositoryMock)
+		util.SetupDefaultOrderStatusEventMocks(eventRepo)
+		outboxRepo := new(mockRepo.OutboxRepositoryMock)
+		attemptRepo := new(mockRepo.ProviderAttemptRepositoryMock)
+		util.SetupDefaultProviderAttemptMocks(attemptRepo)
+		if tc.SetupOutboxRepo != nil {
+			tc.SetupOutboxRepo(outboxRepo)
+		} else {
+			util.SetupDefaultOutboxMocks(outboxRepo)
+		}
+
 		tc.SetupMocks(skuRepo, redis, providerRepo)
+		if tc.SetupOrderRepo != nil {
+			tc.SetupOrderRepo(orderRepo)
+		} else {
+			util.SetupDefaultOrderRepoMocks(orderRepo)
+		}
+
+		ledgerRepo := new(mockRepo.LedgerRepositoryMock)
+		if tc.SetupLedgerRepo != nil {
+			tc.SetupLedgerRepo(ledgerRepo)
+		}
+		promotionRepo := new(mockRepo.PromotionRepositoryMock)
+		if tc.SetupPromotionRepo != nil {
+			tc.SetupPromotionRepo(promotionRepo)
+		} else {
+			util.SetupDefaultPromotionMocks(promotionRepo)
+		}
 
-		orderService := service.NewOrderService(skuRepo, purchaseRepo, redis, *grpcClients, providerRepo)
+		orderService := service.NewOrderService(skuRepo, purchaseRepo, orderRepo, eventRepo, outboxRepo, attemptRepo, txManager, redis, grpcClients, providerRepo, new(mockRepo.OrderReviewRepositoryMock), new(mockRepo.OrderBatchRepositoryMock), service.NewWalletService(ledgerRepo), service.NewPromotionService(promotionRepo), dispatchTestConfig, paymentTestConfig)
+		orderService.ReloadProviders(context.Background())
 		result, err := orderService.CreateOrder(context.Background(), tc.OrderRequest)
 
 		if tc.ExpectedError != "" {
@@ -442,6 +738,10 @@ func TestOrderService_CreateOrder(t *testing.T) {
 		skuRepo.AssertExpectations(t)
 		redis.AssertExpectations(t)
 		providerRepo.AssertExpectations(t)
+		orderRepo.AssertExpectations(t)
+		outboxRepo.AssertExpectations(t)
+		ledgerRepo.AssertExpectations(t)
+		promotionRepo.AssertExpectations(t)
 	})
 }
 
@@ -450,18 +750,18 @@ func TestOrderService_ConfirmOrder(t *testing.T) {
 		{
 			Name:                "successful order confirmation with status confirm - HTTP provider",
 			OrderConfirmRequest: confirmReqVTLConfirmStatus,
-			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
 				providers := util.SingleProvider("VTL", "Viettel")
-				util.SetupBasicConfirmOrderTest(redis, providerRepo, purchaseRepo, "1001", confirmReqVTLConfirmStatus, "VTL", "Viettel", model.CashBackTypePercentage, 5, providers)
+				util.SetupBasicConfirmOrderTest(redis, providerRepo, purchaseRepo, orderRepo, "1001", confirmReqVTLConfirmStatus, "VTL", "Viettel", model.CashBackTypePercentage, 5, providers)
 			},
 			ExpectedError: "",
 		},
 		{
 			Name:                "successful order confirmation with status confirm - gRPC provider",
 			OrderConfirmRequest: confirmReqVTLConfirmStatus,
-			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
 				providers := util.SingleGrpcProvider("GRPC_PROVIDER1", "VTL", "Viettel")
-				util.SetupBasicConfirmOrderTest(redis, providerRepo, purchaseRepo, "1001", confirmReqVTLConfirmStatus, "VTL", "Viettel", model.CashBackTypePercentage, 5, providers)
+				util.SetupBasicConfirmOrderTest(redis, providerRepo, purchaseRepo, orderRepo, "1001", confirmReqVTLConfirmStatus, "VTL", "Viettel", model.CashBackTypePercentage, 5, providers)
 			},
 			GRPCSetup: &util.GRPCClientSetup{
 				ProviderCode: "GRPC_PROVIDER1",
@@ -472,9 +772,9 @@ func TestOrderService_ConfirmOrder(t *testing.T) {
 		{
 			Name:                "successful order confirmation with status failed - gRPC provider",
 			OrderConfirmRequest: confirmReqMBFFailedStatus,
-			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
 				providers := util.SingleGrpcProvider("GRPC_PROVIDER2", "MBF", "Mobifone")
-				util.SetupBasicConfirmOrderTest(redis, providerRepo, purchaseRepo, "1002", confirmReqMBFFailedStatus, "MBF", "Mobifone", model.CashBackTypeFixed, 1000, providers)
+				util.SetupBasicConfirmOrderTest(redis, providerRepo, purchaseRepo, orderRepo, "1002", confirmReqMBFFailedStatus, "MBF", "Mobifone", model.CashBackTypeFixed, 1000, providers)
 			},
 			GRPCSetup: &util.GRPCClientSetup{
 				ProviderCode: "GRPC_PROVIDER2",
@@ -485,9 +785,9 @@ func TestOrderService_ConfirmOrder(t *testing.T) {
 		{
 			Name:                "successful order confirmation with mixed providers - should use gRPC",
 			OrderConfirmRequest: confirmReqVTLConfirmStatus,
-			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
 				providers := util.MixedProviders("VTL", "Viettel")
-				util.SetupBasicConfirmOrderTest(redis, providerRepo, purchaseRepo, "1001", confirmReqVTLConfirmStatus, "VTL", "Viettel", model.CashBackTypePercentage, 5, providers)
+				util.SetupBasicConfirmOrderTest(redis, providerRepo, purchaseRepo, orderRepo, "1001", confirmReqVTLConfirmStatus, "VTL", "Viettel", model.CashBackTypePercentage, 5, providers)
 			},
 			GRPCSetup: &util.GRPCClientSetup{
 				ProviderCode: "GRPC_PROVIDER1",
@@ -498,18 +798,18 @@ func TestOrderService_ConfirmOrder(t *testing.T) {
 		{
 			Name:                "successful order confirmation with status failed",
 			OrderConfirmRequest: confirmReqMBFFailedStatus,
-			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
 				providers := util.SingleProvider("MBF", "Mobifone")
-				util.SetupBasicConfirmOrderTest(redis, providerRepo, purchaseRepo, "1002", confirmReqMBFFailedStatus, "MBF", "Mobifone", model.CashBackTypeFixed, 1000, providers)
+				util.SetupBasicConfirmOrderTest(redis, providerRepo, purchaseRepo, orderRepo, "1002", confirmReqMBFFailedStatus, "MBF", "Mobifone", model.CashBackTypeFixed, 1000, providers)
 			},
 			ExpectedError: "",
 		},
 		{
 			Name:                "gRPC provider error during order processing",
 			OrderConfirmRequest: confirmReqVTLConfirmStatus,
-			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
 				providers := util.SingleGrpcProvider("GRPC_PROVIDER_ERROR", "VTL", "Viettel")
-				util.SetupBasicConfirmOrderTest(redis, providerRepo, purchaseRepo, "1001", confirmReqVTLConfirmStatus, "VTL", "Viettel", model.CashBackTypePercentage, 5, providers)
+				util.SetupBasicConfirmOrderTest(redis, providerRepo, purchaseRepo, orderRepo, "1001", confirmReqVTLConfirmStatus, "VTL", "Viettel", model.CashBackTypePercentage, 5, providers)
 			},
 			GRPCSetup: &util.GRPCClientSetup{
 				ProviderCode: "GRPC_PROVIDER_ERROR",
@@ -521,77 +821,177 @@ func TestOrderService_ConfirmOrder(t *testing.T) {
 		{
 			Name:                "lock acquisition failed",
 			OrderConfirmRequest: confirmReqVTLFailedLock,
-			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
-				util.SetupErrorConfirmOrderTest(redis, providerRepo, "1003", errors.New("lock acquisition failed"), nil, "")
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				util.SetupErrorConfirmOrderTest(redis, providerRepo, orderRepo, 1003, errors.New("lock acquisition failed"), nil)
 			},
 			ExpectedError: "lock acquisition failed",
 		},
 		{
-			Name:                "order not found in cache",
+			Name:                "order not found",
 			OrderConfirmRequest: confirmReqVTLNotFound,
-			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
-				util.SetupErrorConfirmOrderTest(redis, providerRepo, "1004", nil, errors.New("key not found"), "")
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				util.SetupErrorConfirmOrderTest(redis, providerRepo, orderRepo, 1004, nil, gorm.ErrRecordNotFound)
 			},
 			ExpectedError: "order not found or expired",
 		},
 		{
-			Name:                "corrupted cached order data",
+			Name:                "order missing from cache is confirmed from postgres",
+			OrderConfirmRequest: confirmReqVTLNotFound,
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+				redis.On("TryAcquireLock", mock.Anything, "1004", mock.AnythingOfType("time.Duration")).Return(util.NewTestLock("1004"), nil)
+				redis.On("ReleaseLock", mock.Anything, util.LockOf("1004")).Return(nil)
+				redis.On("Set", mock.Anything, "order_id1004", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
+				redis.On("Publish", mock.Anything, "order_status:1004", mock.AnythingOfType("[]uint8")).Return(nil)
+				util.SetupDefaultPaymentReferenceMocks(purchaseRepo)
+				purchaseRepo.On("CreatePurchaseHistory", mock.Anything, mock.MatchedBy(func(ph *model.PurchaseHistory) bool {
+					return ph.OrderID == confirmReqVTLNotFound.OrderID && ph.Status == model.PurchaseHistoryStatusConfirm
+				})).Return(nil)
+				purchaseRepo.On("UpdatePurchaseHistoryProviderByOrderID", mock.Anything, confirmReqVTLNotFound.OrderID, "PROVIDER1").Return(nil)
+			},
+			SetupOrderRepo: func(orderRepo *mockRepo.OrderRepositoryMock) {
+				sku := util.CreateMockSku(1, "VTL", 10000, model.CashBackTypePercentage, 5, "Viettel")
+				order := util.CreatePersistedOrder(confirmReqVTLNotFound.OrderID, 1, 10000, confirmReqVTLNotFound.PhoneNumber, 500, model.PurchaseHistoryStatusPending, sku)
+				orderRepo.On("GetOrderByOrderID", mock.Anything, confirmReqVTLNotFound.OrderID).Return(order, nil)
+				orderRepo.On("UpdateOrderStatusByOrderID", mock.Anything, confirmReqVTLNotFound.OrderID, model.PurchaseHistoryStatusConfirm, int64(1)).Return(nil)
+				orderRepo.On("AssignOrderProvider", mock.Anything, confirmReqVTLNotFound.OrderID, "PROVIDER1").Return(nil)
+			},
+			ExpectedError: "",
+		},
+		{
+			Name:                "database error loading the order",
 			OrderConfirmRequest: confirmReqVTLCorrupt,
-			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
-				util.SetupErrorConfirmOrderTest(redis, providerRepo, "1005", nil, nil, "invalid json data")
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				util.SetupErrorConfirmOrderTest(redis, providerRepo, orderRepo, 1005, nil, errors.New("database connection failed"))
+			},
+			ExpectedError: "database connection failed",
+		},
+		{
+			Name:                "order confirmed by another instance while its cache entry is stale",
+			OrderConfirmRequest: confirmReqVTLConfirmStatus,
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				cachedOrder := util.CreateCachedOrderResponse(confirmReqVTLConfirmStatus.OrderID, 1, 10000, confirmReqVTLConfirmStatus.PhoneNumber, 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+				cachedOrderJSON, _ := json.Marshal(cachedOrder)
+				redis.On("Get", mock.Anything, "order_id1001").Return(string(cachedOrderJSON), nil).Maybe()
+
+				storedOrder := *cachedOrder
+				storedOrder.Status = model.PurchaseHistoryStatusConfirm
+				util.SetupOrderMismatchTest(redis, providerRepo, orderRepo, confirmReqVTLConfirmStatus, "1001", &storedOrder)
 			},
-			ExpectedError: "failed to unmarshal order",
+			ExpectedError: "invalid order status transition from confirm to confirm",
 		},
 		{
 			Name:                "order mismatch - different user ID",
 			OrderConfirmRequest: confirmReqVTLUserMismatchMain,
-			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
 				cachedOrder := util.CreateCachedOrderResponse(confirmReqVTLUserMismatchMain.OrderID, 1, 10000, confirmReqVTLUserMismatchMain.PhoneNumber, 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
-				util.SetupOrderMismatchTest(redis, providerRepo, confirmReqVTLUserMismatchMain, "1006", cachedOrder)
+				util.SetupOrderMismatchTest(redis, providerRepo, orderRepo, confirmReqVTLUserMismatchMain, "1006", cachedOrder)
 			},
 			ExpectedError: "order mismatch",
 		},
 		{
 			Name:                "order mismatch - different total price",
 			OrderConfirmRequest: confirmReqVTLPriceMismatchMain,
-			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
 				cachedOrder := util.CreateCachedOrderResponse(confirmReqVTLPriceMismatchMain.OrderID, 1, 10000, confirmReqVTLPriceMismatchMain.PhoneNumber, 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
-				util.SetupOrderMismatchTest(redis, providerRepo, confirmReqVTLPriceMismatchMain, "1007", cachedOrder)
+				util.SetupOrderMismatchTest(redis, providerRepo, orderRepo, confirmReqVTLPriceMismatchMain, "1007", cachedOrder)
+			},
+			ExpectedError: "order mismatch",
+		},
+		{
+			Name:                "order mismatch - different sku",
+			OrderConfirmRequest: confirmReqVTLSkuMismatchMain,
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				cachedOrder := util.CreateCachedOrderResponse(confirmReqVTLSkuMismatchMain.OrderID, 1, 10000, confirmReqVTLSkuMismatchMain.PhoneNumber, 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+				util.SetupOrderMismatchTest(redis, providerRepo, orderRepo, confirmReqVTLSkuMismatchMain, "1012", cachedOrder)
 			},
 			ExpectedError: "order mismatch",
 		},
+		{
+			Name:                "missing payment reference",
+			OrderConfirmRequest: confirmReqVTLNoReference,
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+			},
+			ExpectedError: "payment reference is required",
+		},
+		{
+			Name:                "order already has a purchase history",
+			OrderConfirmRequest: confirmReqVTLReusedReference,
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				providers := util.SingleProvider("VTL", "Viettel")
+				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(providers, nil)
+				redis.On("TryAcquireLock", mock.Anything, "1013", mock.AnythingOfType("time.Duration")).Return(util.NewTestLock("1013"), nil)
+				redis.On("ReleaseLock", mock.Anything, util.LockOf("1013")).Return(nil)
+
+				cachedOrder := util.CreateCachedOrderResponse(confirmReqVTLReusedReference.OrderID, 1, 10000, confirmReqVTLReusedReference.PhoneNumber, 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+				util.SetupStoredOrderMocks(orderRepo, cachedOrder)
+
+				util.SetupDefaultPaymentReferenceMocks(purchaseRepo)
+				purchaseRepo.On("CreatePurchaseHistory", mock.Anything, mock.MatchedBy(func(ph *model.PurchaseHistory) bool {
+					return ph.OrderID == confirmReqVTLReusedReference.OrderID && ph.PaymentReference == "PAY-1001"
+				})).Return(gorm.ErrDuplicatedKey)
+			},
+			ExpectedError: "order 1013 was already confirmed",
+		},
+		{
+			Name:                "payment reference already paid an order batch",
+			OrderConfirmRequest: confirmReqVTLReusedReference,
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+				redis.On("TryAcquireLock", mock.Anything, "1013", mock.AnythingOfType("time.Duration")).Return(util.NewTestLock("1013"), nil)
+				redis.On("ReleaseLock", mock.Anything, util.LockOf("1013")).Return(nil)
+
+				cachedOrder := util.CreateCachedOrderResponse(confirmReqVTLReusedReference.OrderID, 1, 10000, confirmReqVTLReusedReference.PhoneNumber, 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+				util.SetupStoredOrderMocks(orderRepo, cachedOrder)
+
+				purchaseRepo.On("CreatePaymentReference", mock.Anything, mock.MatchedBy(func(reference *model.PaymentReference) bool {
+					return reference.Reference == "PAY-1001" && *reference.OrderID == confirmReqVTLReusedReference.OrderID && reference.BatchID == nil
+				})).Return(gorm.ErrDuplicatedKey)
+			},
+			ExpectedError: "payment PAY-1001 already paid an order or an order batch",
+		},
 		{
 			Name:                "order status pending - invalid status transition",
 			OrderConfirmRequest: confirmReqVTLPendingMain,
-			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
 				cachedOrder := util.CreateCachedOrderResponse(confirmReqVTLPendingMain.OrderID, 1, 10000, confirmReqVTLPendingMain.PhoneNumber, 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
-				util.SetupOrderMismatchTest(redis, providerRepo, confirmReqVTLPendingMain, "1008", cachedOrder)
+				util.SetupOrderMismatchTest(redis, providerRepo, orderRepo, confirmReqVTLPendingMain, "1008", cachedOrder)
+			},
+			ExpectedError: "invalid order status transition from pending to pending",
+		},
+		{
+			Name:                "payment cannot expire an order",
+			OrderConfirmRequest: confirmReqVTLExpiredMain,
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				cachedOrder := util.CreateCachedOrderResponse(confirmReqVTLExpiredMain.OrderID, 1, 10000, confirmReqVTLExpiredMain.PhoneNumber, 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+				util.SetupOrderMismatchTest(redis, providerRepo, orderRepo, confirmReqVTLExpiredMain, "1008", cachedOrder)
 			},
-			ExpectedError: "order is pending",
+			ExpectedError: "payment can only confirm or fail an order, not set it to expired",
 		},
 		{
 			Name:                "order already confirmed",
 			OrderConfirmRequest: confirmReqVTLAlreadyConfirmedMain,
-			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
 				cachedOrder := util.CreateCachedOrderResponse(confirmReqVTLAlreadyConfirmedMain.OrderID, 1, 10000, confirmReqVTLAlreadyConfirmedMain.PhoneNumber, 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
 				cachedOrder.Status = model.PurchaseHistoryStatusConfirm // Already confirmed
-				util.SetupOrderMismatchTest(redis, providerRepo, confirmReqVTLAlreadyConfirmedMain, "1009", cachedOrder)
+				util.SetupOrderMismatchTest(redis, providerRepo, orderRepo, confirmReqVTLAlreadyConfirmedMain, "1009", cachedOrder)
 			},
-			ExpectedError: "order already confirmed or failed",
+			ExpectedError: "invalid order status transition from confirm to confirm",
 		},
 		{
 			Name:                "database error during purchase history creation",
 			OrderConfirmRequest: confirmReqVTLDBErrorMain,
-			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
 				providers := util.SingleProvider("VTL", "Viettel")
 				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(providers, nil)
-				redis.On("TryAcquireLock", mock.Anything, "1010", mock.AnythingOfType("time.Duration")).Return(nil)
-				redis.On("ReleaseLock", mock.Anything, "1010").Return(nil)
+				redis.On("TryAcquireLock", mock.Anything, "1010", mock.AnythingOfType("time.Duration")).Return(util.NewTestLock("1010"), nil)
+				redis.On("ReleaseLock", mock.Anything, util.LockOf("1010")).Return(nil)
 
 				cachedOrder := util.CreateCachedOrderResponse(confirmReqVTLDBErrorMain.OrderID, 1, 10000, confirmReqVTLDBErrorMain.PhoneNumber, 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
-				cachedOrderJSON, _ := json.Marshal(cachedOrder)
-				redis.On("Get", mock.Anything, "order_id1010").Return(string(cachedOrderJSON), nil)
+				util.SetupStoredOrderMocks(orderRepo, cachedOrder)
 
+				util.SetupDefaultPaymentReferenceMocks(purchaseRepo)
 				purchaseRepo.On("CreatePurchaseHistory", mock.Anything, mock.MatchedBy(func(ph *model.PurchaseHistory) bool {
 					return ph.OrderID == confirmReqVTLDBErrorMain.OrderID && ph.UserID == confirmReqVTLDBErrorMain.UserID && ph.Status == model.PurchaseHistoryStatusConfirm
 				})).Return(errors.New("database connection failed"))
@@ -609,15 +1009,32 @@ func TestOrderService_ConfirmOrder(t *testing.T) {
 		}
 		providerRepo := new(mockRepo.ProviderRepositoryMock)
 
-		tc.SetupMocks(skuRepo, purchaseRepo, redis, providerRepo)
+		orderRepo := new(mockRepo.OrderRepositoryMock)
+		txManager := new(mockRepo.TransactionManagerMock)
+		util.SetupTransactionMocks(txManager)
+		eventRepo := new(mockRepo.OrderStatusEventRepositoryMock)
+		util.SetupDefaultOrderStatusEventMocks(eventRepo)
+		outboxRepo := new(mockRepo.OutboxRepositoryMock)
+		util.SetupDefaultWebhookEventMocks(outboxRepo)
+		attemptRepo := new(mockRepo.ProviderAttemptRepositoryMock)
+		util.SetupDefaultProviderAttemptMocks(attemptRepo)
+
+		tc.SetupMocks(skuRepo, purchaseRepo, orderRepo, redis, providerRepo)
+		if tc.SetupOrderRepo != nil {
+			tc.SetupOrderRepo(orderRepo)
+		} else {
+			util.SetupDefaultOrderRepoMocks(orderRepo)
+		}
+
+		orderService := service.NewOrderService(skuRepo, purchaseRepo, orderRepo, eventRepo, outboxRepo, attemptRepo, txManager, redis, grpcClients, providerRepo, new(mockRepo.OrderReviewRepositoryMock), new(mockRepo.OrderBatchRepositoryMock), service.NewWalletService(new(mockRepo.LedgerRepositoryMock)), service.NewPromotionService(new(mockRepo.PromotionRepositoryMock)), dispatchTestConfig, paymentTestConfig)
+		orderService.ReloadProviders(context.Background())
 
-		// Setup gRPC client mocks if needed
+		// Swap the provider connection opened by NewOrderService for a mock
 		if tc.GRPCSetup != nil {
 			util.SetupGRPCMockClient(grpcClients, tc.GRPCSetup)
 		}
-
-		orderService := service.NewOrderService(skuRepo, purchaseRepo, redis, *grpcClients, providerRepo)
 		err := orderService.ConfirmOrder(context.Background(), tc.OrderConfirmRequest)
+		orderService.WaitForDispatches()
 
 		if tc.ExpectedError != "" {
 			assert.Error(t, err)
@@ -630,6 +1047,7 @@ func TestOrderService_ConfirmOrder(t *testing.T) {
 		if tc.ExpectedError == "" {
 			purchaseRepo.AssertExpectations(t)
 			providerRepo.AssertExpectations(t)
+			orderRepo.AssertExpectations(t)
 		}
 	})
 }
@@ -639,47 +1057,122 @@ func TestOrderService_UpdateOrderStatus(t *testing.T) {
 		{
 			Name:               "successful order status update to success",
 			OrderUpdateRequest: updateReqSuccess,
-			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
 				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
-				redis.On("Get", mock.Anything, "order_req_id1001").Return("", errors.New("not found"))
-				redis.On("TryAcquireLock", mock.Anything, "1001", mock.AnythingOfType("time.Duration")).Return(nil)
-				redis.On("ReleaseLock", mock.Anything, "1001").Return(nil)
+				redis.On("Get", mock.Anything, "order_req_id1001:success").Return("", errors.New("not found"))
+				redis.On("TryAcquireLock", mock.Anything, "1001", mock.AnythingOfType("time.Duration")).Return(util.NewTestLock("1001"), nil)
+				redis.On("ReleaseLock", mock.Anything, util.LockOf("1001")).Return(nil)
 				cachedOrder := util.CreateCachedOrderResponse(updateReqSuccess.OrderID, 1, 10000, updateReqSuccess.PhoneNumber, 0, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
 				cachedOrder.Status = model.PurchaseHistoryStatusConfirm
-				cachedOrderJSON, _ := json.Marshal(cachedOrder)
-				redis.On("Get", mock.Anything, "order_id1001").Return(string(cachedOrderJSON), nil)
+				util.SetupStoredOrderMocks(orderRepo, cachedOrder)
 				purchaseRepo.On("UpdatePurchaseHistoryStatusByOrderID", mock.Anything, uint(1001), model.PurchaseHistoryStatusSuccess).Return(nil)
 				redis.On("Set", mock.Anything, "order_id1001", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
-				redis.On("Set", mock.Anything, "order_req_id1001", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
+				redis.On("Publish", mock.Anything, "order_status:1001", mock.AnythingOfType("[]uint8")).Return(nil)
+				redis.On("Set", mock.Anything, "order_req_id1001:success", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
 			},
 			ExpectedError: "",
 		},
 		{
 			Name:               "successful order status update to failed",
 			OrderUpdateRequest: updateReqFailed,
-			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
 				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
-				redis.On("Get", mock.Anything, "order_req_id1001").Return("", errors.New("not found"))
-				redis.On("TryAcquireLock", mock.Anything, "1001", mock.AnythingOfType("time.Duration")).Return(nil)
-				redis.On("ReleaseLock", mock.Anything, "1001").Return(nil)
+				redis.On("Get", mock.Anything, "order_req_id1001:failed").Return("", errors.New("not found"))
+				redis.On("TryAcquireLock", mock.Anything, "1001", mock.AnythingOfType("time.Duration")).Return(util.NewTestLock("1001"), nil)
+				redis.On("ReleaseLock", mock.Anything, util.LockOf("1001")).Return(nil)
 				cachedOrder := util.CreateCachedOrderResponse(updateReqFailed.OrderID, 1, 10000, updateReqFailed.PhoneNumber, 0, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
 				cachedOrder.Status = model.PurchaseHistoryStatusConfirm
-				cachedOrderJSON, _ := json.Marshal(cachedOrder)
-				redis.On("Get", mock.Anything, "order_id1001").Return(string(cachedOrderJSON), nil)
+				util.SetupStoredOrderMocks(orderRepo, cachedOrder)
 				purchaseRepo.On("UpdatePurchaseHistoryStatusByOrderID", mock.Anything, uint(1001), model.PurchaseHistoryStatusFailed).Return(nil)
 				redis.On("Set", mock.Anything, "order_id1001", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
-				redis.On("Set", mock.Anything, "order_req_id1001", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
+				redis.On("Publish", mock.Anything, "order_status:1001", mock.AnythingOfType("[]uint8")).Return(nil)
+				redis.On("Set", mock.Anything, "order_req_id1001:failed", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
+			},
+			SetupOutboxRepo: func(outboxRepo *mockRepo.OutboxRepositoryMock) {
+				outboxRepo.On("CreateOutboxMessage", mock.Anything, mock.MatchedBy(func(message *model.OutboxMessage) bool {
+					return message.AggregateID == 1001 &&
+						message.EventType == model.OutboxEventOrderFailed &&
+						message.Channel == model.OutboxChannelHTTP &&
+						message.Method == "PATCH"
+				})).Return(nil).Once()
+				outboxRepo.On("CreateOutboxMessage", mock.Anything, mock.MatchedBy(func(message *model.OutboxMessage) bool {
+					var event schema.WebhookOrderEvent
+					return message.AggregateID == 1001 &&
+						message.EventType == model.WebhookEventOrderFailed &&
+						message.Channel == model.OutboxChannelWebhook &&
+						json.Unmarshal([]byte(message.Payload), &event) == nil &&
+						event.OrderID == 1001 &&
+						event.UserID == 1 &&
+						event.Status == model.PurchaseHistoryStatusFailed
+				})).Return(nil).Once()
 			},
 			ExpectedError: "",
 		},
+		{
+			Name:               "outbox write error during failed update",
+			OrderUpdateRequest: updateReqFailed,
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+				redis.On("Get", mock.Anything, "order_req_id1001:failed").Return("", errors.New("not found"))
+				redis.On("TryAcquireLock", mock.Anything, "1001", mock.AnythingOfType("time.Duration")).Return(util.NewTestLock("1001"), nil)
+				redis.On("ReleaseLock", mock.Anything, util.LockOf("1001")).Return(nil)
+				cachedOrder := util.CreateCachedOrderResponse(updateReqFailed.OrderID, 1, 10000, updateReqFailed.PhoneNumber, 0, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+				cachedOrder.Status = model.PurchaseHistoryStatusConfirm
+				util.SetupStoredOrderMocks(orderRepo, cachedOrder)
+				purchaseRepo.On("UpdatePurchaseHistoryStatusByOrderID", mock.Anything, uint(1001), model.PurchaseHistoryStatusFailed).Return(nil)
+			},
+			SetupOutboxRepo: func(outboxRepo *mockRepo.OutboxRepositoryMock) {
+				outboxRepo.On("CreateOutboxMessage", mock.Anything, mock.AnythingOfType("*model.OutboxMessage")).Return(errors.New("outbox insert failed"))
+			},
+			ExpectedError: "outbox insert failed",
+		},
+		{
+			Name: "callback from a provider the order is not assigned to",
+			OrderUpdateRequest: schema.OrderUpdateRequest{
+				OrderID:      1001,
+				Status:       model.PurchaseHistoryStatusSuccess,
+				PhoneNumber:  "0981234567",
+				ProviderCode: "PROVIDER2",
+			},
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+			},
+			SetupOrderRepo: func(orderRepo *mockRepo.OrderRepositoryMock) {
+				orderRepo.On("GetOrderProviderCode", mock.Anything, uint(1001)).Return("PROVIDER1", nil)
+			},
+			ExpectedError: "order 1001 is not assigned to provider PROVIDER2",
+		},
+		{
+			Name:               "callback for an order that was never dispatched",
+			OrderUpdateRequest: updateReqSuccess,
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+			},
+			SetupOrderRepo: func(orderRepo *mockRepo.OrderRepositoryMock) {
+				orderRepo.On("GetOrderProviderCode", mock.Anything, uint(1001)).Return("", nil)
+			},
+			ExpectedError: "order 1001 is not assigned to provider PROVIDER1",
+		},
+		{
+			Name:               "callback for an unknown order",
+			OrderUpdateRequest: updateReqSuccess,
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+			},
+			SetupOrderRepo: func(orderRepo *mockRepo.OrderRepositoryMock) {
+				orderRepo.On("GetOrderProviderCode", mock.Anything, uint(1001)).Return("", gorm.ErrRecordNotFound)
+			},
+			ExpectedError: "order not found",
+		},
 		{
 			Name: "idempotency - request already processed successfully",
 			OrderUpdateRequest: schema.OrderUpdateRequest{
-				OrderID:     1001,
-				Status:      model.PurchaseHistoryStatusSuccess,
-				PhoneNumber: "081234567890",
+				OrderID:      1001,
+				Status:       model.PurchaseHistoryStatusSuccess,
+				PhoneNumber:  "0981234567",
+				ProviderCode: "PROVIDER1",
 			},
-			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
 				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
 				idempotencyResponse := schema.OrderIdempotencyResponse{
 					Success:      true,
@@ -687,18 +1180,19 @@ func TestOrderService_UpdateOrderStatus(t *testing.T) {
 					Timestamp:    time.Now().Unix(),
 				}
 				responseJSON, _ := json.Marshal(idempotencyResponse)
-				redis.On("Get", mock.Anything, "order_req_id1001").Return(string(responseJSON), nil)
+				redis.On("Get", mock.Anything, "order_req_id1001:success").Return(string(responseJSON), nil)
 			},
 			ExpectedError: "",
 		},
 		{
 			Name: "idempotency - request already processed with error",
 			OrderUpdateRequest: schema.OrderUpdateRequest{
-				OrderID:     1001,
-				Status:      model.PurchaseHistoryStatusSuccess,
-				PhoneNumber: "081234567890",
+				OrderID:      1001,
+				Status:       model.PurchaseHistoryStatusSuccess,
+				PhoneNumber:  "0981234567",
+				ProviderCode: "PROVIDER1",
 			},
-			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
 				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
 				idempotencyResponse := schema.OrderIdempotencyResponse{
 					Success:      false,
@@ -706,51 +1200,171 @@ func TestOrderService_UpdateOrderStatus(t *testing.T) {
 					Timestamp:    time.Now().Unix(),
 				}
 				responseJSON, _ := json.Marshal(idempotencyResponse)
-				redis.On("Get", mock.Anything, "order_req_id1001").Return(string(responseJSON), nil)
+				redis.On("Get", mock.Anything, "order_req_id1001:success").Return(string(responseJSON), nil)
 			},
 			ExpectedError: "previous error",
 		},
 		{
 			Name: "order not confirmed",
 			OrderUpdateRequest: schema.OrderUpdateRequest{
-				OrderID:     1001,
-				Status:      model.PurchaseHistoryStatusSuccess,
-				PhoneNumber: "081234567890",
+				OrderID:      1001,
+				Status:       model.PurchaseHistoryStatusSuccess,
+				PhoneNumber:  "0981234567",
+				ProviderCode: "PROVIDER1",
 			},
-			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
 				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
-				redis.On("Get", mock.Anything, "order_req_id1001").Return("", errors.New("not found"))
-				redis.On("TryAcquireLock", mock.Anything, "1001", mock.AnythingOfType("time.Duration")).Return(nil)
-				redis.On("ReleaseLock", mock.Anything, "1001").Return(nil)
-				cachedOrder := util.CreateCachedOrderResponse(1001, 1, 10000, "081234567890", 0, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+				redis.On("Get", mock.Anything, "order_req_id1001:success").Return("", errors.New("not found"))
+				redis.On("TryAcquireLock", mock.Anything, "1001", mock.AnythingOfType("time.Duration")).Return(util.NewTestLock("1001"), nil)
+				redis.On("ReleaseLock", mock.Anything, util.LockOf("1001")).Return(nil)
+				cachedOrder := util.CreateCachedOrderResponse(1001, 1, 10000, "0981234567", 0, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
 				cachedOrder.Status = model.PurchaseHistoryStatusPending // Not confirmed
-				cachedOrderJSON, _ := json.Marshal(cachedOrder)
-				redis.On("Get", mock.Anything, "order_id1001").Return(string(cachedOrderJSON), nil)
-				redis.On("Set", mock.Anything, "order_req_id1001", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
+				util.SetupStoredOrderMocks(orderRepo, cachedOrder)
+				redis.On("Set", mock.Anything, "order_req_id1001:success", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
 			},
-			ExpectedError: "order is not confirmed or failed",
+			ExpectedError: "invalid order status transition from pending to success",
 		},
 		{
-			Name: "database error during status update",
-			OrderUpdateRequest: schema.OrderUpdateRequest{
-				OrderID:     1001,
-				Status:      model.PurchaseHistoryStatusSuccess,
-				PhoneNumber: "081234567890",
-			},
-			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+			Name:               "success credits the cashback to the wallet",
+			OrderUpdateRequest: updateReqSuccess,
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
 				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
-				redis.On("Get", mock.Anything, "order_req_id1001").Return("", errors.New("not found"))
-				redis.On("TryAcquireLock", mock.Anything, "1001", mock.AnythingOfType("time.Duration")).Return(nil)
-				redis.On("ReleaseLock", mock.Anything, "1001").Return(nil)
-				cachedOrder := util.CreateCachedOrderResponse(1001, 1, 10000, "081234567890", 0, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+				redis.On("Get", mock.Anything, "order_req_id1001:success").Return("", errors.New("not found"))
+				redis.On("TryAcquireLock", mock.Anything, "1001", mock.AnythingOfType("time.Duration")).Return(util.NewTestLock("1001"), nil)
+				redis.On("ReleaseLock", mock.Anything, util.LockOf("1001")).Return(nil)
+				cachedOrder := util.CreateCachedOrderResponse(1001, 1, 10000, "0981234567", 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
 				cachedOrder.Status = model.PurchaseHistoryStatusConfirm
-				cachedOrderJSON, _ := json.Marshal(cachedOrder)
-				redis.On("Get", mock.Anything, "order_id1001").Return(string(cachedOrderJSON), nil)
-				purchaseRepo.On("UpdatePurchaseHistoryStatusByOrderID", mock.Anything, uint(1001), model.PurchaseHistoryStatusSuccess).Return(errors.New("database error"))
-				redis.On("Set", mock.Anything, "order_req_id1001", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
+				util.SetupStoredOrderMocks(orderRepo, cachedOrder)
+				purchaseRepo.On("UpdatePurchaseHistoryStatusByOrderID", mock.Anything, uint(1001), model.PurchaseHistoryStatusSuccess).Return(nil)
+				redis.On("Set", mock.Anything, "order_id1001", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
+				redis.On("Publish", mock.Anything, "order_status:1001", mock.AnythingOfType("[]uint8")).Return(nil)
+				redis.On("Set", mock.Anything, "order_req_id1001:success", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
 			},
-			ExpectedError: "database error",
-		},
+			SetupLedgerRepo: func(ledgerRepo *mockRepo.LedgerRepositoryMock) {
+				util.SetupDefaultLedgerAccountMocks(ledgerRepo)
+				ledgerRepo.On("CreateLedgerTransaction", mock.Anything, util.LedgerTransactionOf(model.LedgerTransactionTypeCashBack, 1001, 500)).Return(nil).Once()
+			},
+			ExpectedError: "",
+		},
+		{
+			Name:               "cashback credit error rolls the status update back",
+			OrderUpdateRequest: updateReqSuccess,
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+				redis.On("Get", mock.Anything, "order_req_id1001:success").Return("", errors.New("not found"))
+				redis.On("TryAcquireLock", mock.Anything, "1001", mock.AnythingOfType("time.Duration")).Return(util.NewTestLock("1001"), nil)
+				redis.On("ReleaseLock", mock.Anything, util.LockOf("1001")).Return(nil)
+				cachedOrder := util.CreateCachedOrderResponse(1001, 1, 10000, "0981234567", 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+				cachedOrder.Status = model.PurchaseHistoryStatusConfirm
+				util.SetupStoredOrderMocks(orderRepo, cachedOrder)
+				purchaseRepo.On("UpdatePurchaseHistoryStatusByOrderID", mock.Anything, uint(1001), model.PurchaseHistoryStatusSuccess).Return(nil)
+			},
+			SetupLedgerRepo: func(ledgerRepo *mockRepo.LedgerRepositoryMock) {
+				util.SetupDefaultLedgerAccountMocks(ledgerRepo)
+				ledgerRepo.On("CreateLedgerTransaction", mock.Anything, mock.AnythingOfType("*model.LedgerTransaction")).Return(errors.New("ledger insert failed"))
+			},
+			ExpectedError: "ledger insert failed",
+		},
+		{
+			Name:               "provider callback can't fail a successful order",
+			OrderUpdateRequest: updateReqFailed,
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+				redis.On("Get", mock.Anything, "order_req_id1001:failed").Return("", errors.New("not found"))
+				redis.On("TryAcquireLock", mock.Anything, "1001", mock.AnythingOfType("time.Duration")).Return(util.NewTestLock("1001"), nil)
+				redis.On("ReleaseLock", mock.Anything, util.LockOf("1001")).Return(nil)
+				cachedOrder := util.CreateCachedOrderResponse(1001, 1, 10000, "0981234567", 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+				cachedOrder.Status = model.PurchaseHistoryStatusSuccess
+				cachedOrder.WalletAmount = 2000
+				util.SetupStoredOrderMocks(orderRepo, cachedOrder)
+				redis.On("Set", mock.Anything, "order_req_id1001:failed", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
+			},
+			ExpectedError: "invalid order status transition from success to failed",
+		},
+		{
+			Name: "successful order can't be confirmed again",
+			OrderUpdateRequest: schema.OrderUpdateRequest{
+				OrderID:      1001,
+				Status:       model.PurchaseHistoryStatusConfirm,
+				PhoneNumber:  "0981234567",
+				ProviderCode: "PROVIDER1",
+			},
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+				redis.On("Get", mock.Anything, "order_req_id1001:confirm").Return("", errors.New("not found"))
+				redis.On("TryAcquireLock", mock.Anything, "1001", mock.AnythingOfType("time.Duration")).Return(util.NewTestLock("1001"), nil)
+				redis.On("ReleaseLock", mock.Anything, util.LockOf("1001")).Return(nil)
+				cachedOrder := util.CreateCachedOrderResponse(1001, 1, 10000, "0981234567", 0, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+				cachedOrder.Status = model.PurchaseHistoryStatusSuccess
+				util.SetupStoredOrderMocks(orderRepo, cachedOrder)
+				redis.On("Set", mock.Anything, "order_req_id1001:confirm", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
+			},
+			ExpectedError: "invalid order status transition from success to confirm",
+		},
+		{
+			Name: "database error during status update isn't cached for the retry",
+			OrderUpdateRequest: schema.OrderUpdateRequest{
+				OrderID:      1001,
+				Status:       model.PurchaseHistoryStatusSuccess,
+				PhoneNumber:  "0981234567",
+				ProviderCode: "PROVIDER1",
+			},
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+				redis.On("Get", mock.Anything, "order_req_id1001:success").Return("", errors.New("not found"))
+				redis.On("TryAcquireLock", mock.Anything, "1001", mock.AnythingOfType("time.Duration")).Return(util.NewTestLock("1001"), nil)
+				redis.On("ReleaseLock", mock.Anything, util.LockOf("1001")).Return(nil)
+				cachedOrder := util.CreateCachedOrderResponse(1001, 1, 10000, "0981234567", 0, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+				cachedOrder.Status = model.PurchaseHistoryStatusConfirm
+				util.SetupStoredOrderMocks(orderRepo, cachedOrder)
+				purchaseRepo.On("UpdatePurchaseHistoryStatusByOrderID", mock.Anything, uint(1001), model.PurchaseHistoryStatusSuccess).Return(errors.New("database error"))
+			},
+			ExpectedError: "database error",
+		},
+		{
+			Name:               "lock taken over before the status was written",
+			OrderUpdateRequest: updateReqSuccess,
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+				redis.On("Get", mock.Anything, "order_req_id1001:success").Return("", errors.New("not found"))
+				redis.On("TryAcquireLock", mock.Anything, "1001", mock.AnythingOfType("time.Duration")).Return(util.NewTestLock("1001"), nil)
+				redis.On("ReleaseLock", mock.Anything, util.LockOf("1001")).Return(redisPkg.ErrLockNotHeld)
+				cachedOrder := util.CreateCachedOrderResponse(1001, 1, 10000, "0981234567", 0, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+				cachedOrder.Status = model.PurchaseHistoryStatusConfirm
+				util.SetupStoredOrderMocks(orderRepo, cachedOrder)
+				purchaseRepo.On("UpdatePurchaseHistoryStatusByOrderID", mock.Anything, uint(1001), model.PurchaseHistoryStatusSuccess).Return(nil)
+			},
+			SetupOrderRepo: func(orderRepo *mockRepo.OrderRepositoryMock) {
+				orderRepo.On("GetOrderProviderCode", mock.Anything, uint(1001)).Return("PROVIDER1", nil)
+				orderRepo.On("UpdateOrderStatusByOrderID", mock.Anything, uint(1001), model.PurchaseHistoryStatusSuccess, int64(1)).Return(repository.ErrStaleFence)
+			},
+			ExpectedError: "order 1001 lock expired before its status was written",
+		},
+		{
+			Name:               "failed order releases its promotions",
+			OrderUpdateRequest: updateReqFailed,
+			SetupMocks: func(skuRepo *mockRepo.SkuRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock) {
+				providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+				redis.On("Get", mock.Anything, "order_req_id1001:failed").Return("", errors.New("not found"))
+				redis.On("TryAcquireLock", mock.Anything, "1001", mock.AnythingOfType("time.Duration")).Return(util.NewTestLock("1001"), nil)
+				redis.On("ReleaseLock", mock.Anything, util.LockOf("1001")).Return(nil)
+				cachedOrder := util.CreateCachedOrderResponse(1001, 1, 10000, "0981234567", 2000, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+				cachedOrder.Status = model.PurchaseHistoryStatusConfirm
+				cachedOrder.Promotions = []schema.AppliedPromotion{{PromotionID: 7, Code: "SUMMER10", CashBackValue: 2000}}
+				util.SetupStoredOrderMocks(orderRepo, cachedOrder)
+				purchaseRepo.On("UpdatePurchaseHistoryStatusByOrderID", mock.Anything, uint(1001), model.PurchaseHistoryStatusFailed).Return(nil)
+				redis.On("Set", mock.Anything, "order_id1001", mock.MatchedBy(func(value []byte) bool {
+					var order schema.OrderResponse
+					return json.Unmarshal(value, &order) == nil && len(order.Promotions) == 1 && order.Promotions[0].Released
+				}), mock.AnythingOfType("time.Duration")).Return(nil)
+				redis.On("Publish", mock.Anything, "order_status:1001", mock.AnythingOfType("[]uint8")).Return(nil)
+				redis.On("Set", mock.Anything, "order_req_id1001:failed", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
+			},
+			SetupPromotionRepo: func(promotionRepo *mockRepo.PromotionRepositoryMock) {
+				promotionRepo.On("ReleasePromotionRedemptions", mock.Anything, uint(1001), mock.AnythingOfType("time.Time")).Return(nil).Once()
+			},
+			ExpectedError: "",
+		},
 	}
 	runTableDrivenTests(t, tests, func(t *testing.T, tc UpdateOrderStatusTestCase) {
 		skuRepo := new(mockRepo.SkuRepositoryMock)
@@ -761,9 +1375,38 @@ func TestOrderService_UpdateOrderStatus(t *testing.T) {
 		}
 		providerRepo := new(mockRepo.ProviderRepositoryMock)
 
-		tc.SetupMocks(skuRepo, purchaseRepo, redis, providerRepo)
+		orderRepo := new(mockRepo.OrderRepositoryMock)
+		txManager := new(mockRepo.TransactionManagerMock)
+		util.SetupTransactionMocks(txManager)
+		eventRepo := new(mockRepo.OrderStatusEventRepositoryMock)
+		util.SetupDefaultOrderStatusEventMocks(eventRepo)
+		outboxRepo := new(mockRepo.OutboxRepositoryMock)
+		attemptRepo := new(mockRepo.ProviderAttemptRepositoryMock)
+		util.SetupDefaultProviderAttemptMocks(attemptRepo)
+		if tc.SetupOutboxRepo != nil {
+			tc.SetupOutboxRepo(outboxRepo)
+		} else {
+			util.SetupDefaultOutboxMocks(outboxRepo)
+		}
+
+		tc.SetupMocks(skuRepo, purchaseRepo, orderRepo, redis, providerRepo)
+		if tc.SetupOrderRepo != nil {
+			tc.SetupOrderRepo(orderRepo)
+		} else {
+			util.SetupDefaultOrderRepoMocks(orderRepo)
+		}
 
-		orderService := service.NewOrderService(skuRepo, purchaseRepo, redis, *grpcClients, providerRepo)
+		ledgerRepo := new(mockRepo.LedgerRepositoryMock)
+		if tc.SetupLedgerRepo != nil {
+			tc.SetupLedgerRepo(ledgerRepo)
+		}
+		promotionRepo := new(mockRepo.PromotionRepositoryMock)
+		if tc.SetupPromotionRepo != nil {
+			tc.SetupPromotionRepo(promotionRepo)
+		}
+
+		orderService := service.NewOrderService(skuRepo, purchaseRepo, orderRepo, eventRepo, outboxRepo, attemptRepo, txManager, redis, grpcClients, providerRepo, new(mockRepo.OrderReviewRepositoryMock), new(mockRepo.OrderBatchRepositoryMock), service.NewWalletService(ledgerRepo), service.NewPromotionService(promotionRepo), dispatchTestConfig, paymentTestConfig)
+		orderService.ReloadProviders(context.Background())
 		err := orderService.UpdateOrderStatus(context.Background(), tc.OrderUpdateRequest)
 
 		if tc.ExpectedError != "" {
@@ -775,6 +1418,9 @@ func TestOrderService_UpdateOrderStatus(t *testing.T) {
 
 		redis.AssertExpectations(t)
 		providerRepo.AssertExpectations(t)
+		outboxRepo.AssertExpectations(t)
+		ledgerRepo.AssertExpectations(t)
+		promotionRepo.AssertExpectations(t)
 	})
 }
 func BenchmarkOrderService_CreateOrder(b *testing.B) {
@@ -808,23 +1454,39 @@ func BenchmarkOrderService_CreateOrder(b *testing.B) {
 			Code:   "PROVIDER1",
 			Source: "http://provider1.com",
 			Type:   "http",
-			Weight: 100,
 			Suppliers: []model.Supplier{
 				{Code: "VTL", Name: "Viettel"},
 			},
+			ProviderSuppliers: []model.ProviderSupplier{
+				{ProviderID: 1, Weight: 100},
+			},
 		},
 	}
 
 	skuRepo.On("GetSkuByID", mock.Anything, uint(1)).Return(mockSku, nil)
 	providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(providers, nil)
 	redis.On("Set", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
+	redis.On("Publish", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8")).Return(nil)
+
+	orderRepo := new(mockRepo.OrderRepositoryMock)
+	txManager := new(mockRepo.TransactionManagerMock)
+	util.SetupTransactionMocks(txManager)
+	eventRepo := new(mockRepo.OrderStatusEventRepositoryMock)
+	util.SetupDefaultOrderStatusEventMocks(eventRepo)
+	outboxRepo := new(mockRepo.OutboxRepositoryMock)
+	util.SetupDefaultOutboxMocks(outboxRepo)
+	attemptRepo := new(mockRepo.ProviderAttemptRepositoryMock)
+	orderRepo.On("CreateOrder", mock.Anything, mock.AnythingOfType("*model.Order")).Return(nil)
+	promotionRepo := new(mockRepo.PromotionRepositoryMock)
+	util.SetupDefaultPromotionMocks(promotionRepo)
 
-	orderService := service.NewOrderService(skuRepo, purchaseRepo, redis, *grpcClients, providerRepo)
+	orderService := service.NewOrderService(skuRepo, purchaseRepo, orderRepo, eventRepo, outboxRepo, attemptRepo, txManager, redis, grpcClients, providerRepo, new(mockRepo.OrderReviewRepositoryMock), new(mockRepo.OrderBatchRepositoryMock), service.NewWalletService(new(mockRepo.LedgerRepositoryMock)), service.NewPromotionService(promotionRepo), dispatchTestConfig, paymentTestConfig)
+	orderService.ReloadProviders(context.Background())
 
 	orderRequest := schema.OrderRequest{
 		UserID:      1,
 		SkuID:       1,
-		PhoneNumber: "081234567890",
+		PhoneNumber: "0981234567",
 	}
 
 	b.ResetTimer()
@@ -837,10 +1499,9 @@ func BenchmarkOrderService_CreateOrder(b *testing.B) {
 }
 
 type InitializationTestCase struct {
-	Name           string
-	SetupProviders func() []model.Provider
-	ExpectPanic    bool
-	PanicMessage   string
+	Name              string
+	SetupProviders    func() []model.Provider
+	ExpectedProviders []string
 }
 
 func runInitializationTestCases(t *testing.T, cases []InitializationTestCase) {
@@ -853,19 +1514,26 @@ func runInitializationTestCases(t *testing.T, cases []InitializationTestCase) {
 				ProviderGRPCClients: make(map[string]grpcClient.ProviderGRPCClient),
 			}
 			providerRepo := new(mockRepo.ProviderRepositoryMock)
+			orderRepo := new(mockRepo.OrderRepositoryMock)
+			txManager := new(mockRepo.TransactionManagerMock)
+			eventRepo := new(mockRepo.OrderStatusEventRepositoryMock)
+			outboxRepo := new(mockRepo.OutboxRepositoryMock)
+			attemptRepo := new(mockRepo.ProviderAttemptRepositoryMock)
 
 			providers := tc.SetupProviders()
 			providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(providers, nil)
 
-			if tc.ExpectPanic {
-				assert.PanicsWithValue(t, tc.PanicMessage, func() {
-					service.NewOrderService(skuRepo, purchaseRepo, redis, *grpcClients, providerRepo)
-				})
-			} else {
-				assert.NotPanics(t, func() {
-					service.NewOrderService(skuRepo, purchaseRepo, redis, *grpcClients, providerRepo)
-				})
+			var orderService service.OrderService
+			assert.NotPanics(t, func() {
+				orderService = service.NewOrderService(skuRepo, purchaseRepo, orderRepo, eventRepo, outboxRepo, attemptRepo, txManager, redis, grpcClients, providerRepo, new(mockRepo.OrderReviewRepositoryMock), new(mockRepo.OrderBatchRepositoryMock), service.NewWalletService(new(mockRepo.LedgerRepositoryMock)), service.NewPromotionService(new(mockRepo.PromotionRepositoryMock)), dispatchTestConfig, paymentTestConfig)
+				orderService.ReloadProviders(context.Background())
+			})
+
+			codes := []string{}
+			for _, health := range orderService.GetProviderHealth(context.Background()) {
+				codes = append(codes, health.ProviderCode)
 			}
+			assert.Equal(t, tc.ExpectedProviders, codes)
 
 			providerRepo.AssertExpectations(t)
 		})
@@ -898,7 +1566,7 @@ func TestOrderService_Initialization(t *testing.T) {
 					grpcProvider(2, "GRPC_PROVIDER", "grpc://test.com:9090", 150, "MBF", "Mobifone"),
 				}
 			},
-			ExpectPanic: false,
+			ExpectedProviders: []string{"GRPC_PROVIDER", "HTTP_PROVIDER"},
 		},
 		{
 			Name: "unsupported provider type",
@@ -907,10 +1575,1476 @@ func TestOrderService_Initialization(t *testing.T) {
 					unknownProvider(1, "UNKNOWN_PROVIDER", "unknown://test.com", "unknown", 100, "VTL", "Viettel"),
 				}
 			},
-			ExpectPanic:  true,
-			PanicMessage: "unsupported provider type: unknown",
+			// The reload is rejected and the service starts with an empty routing table
+			ExpectedProviders: []string{},
 		},
 	}
 
 	runInitializationTestCases(t, testCases)
 }
+
+func TestOrderService_GetOrderTimeline(t *testing.T) {
+	pending := model.PurchaseHistoryStatusPending
+	confirm := model.PurchaseHistoryStatusConfirm
+
+	testCases := []struct {
+		Name          string
+		Events        []model.OrderStatusEvent
+		RepoError     error
+		ExpectedError string
+		Assert        func(t *testing.T, result *schema.OrderTimelineResponse)
+	}{
+		{
+			Name: "returns events in order with their source",
+			Events: []model.OrderStatusEvent{
+				{OrderID: 1, Status: model.PurchaseHistoryStatusPending, Source: model.OrderStatusEventSourceHTTP},
+				{OrderID: 1, PreviousStatus: &pending, Status: model.PurchaseHistoryStatusConfirm, Source: model.OrderStatusEventSourceKafka},
+				{OrderID: 1, PreviousStatus: &confirm, Status: model.PurchaseHistoryStatusSuccess, Source: model.OrderStatusEventSourceProviderCallback},
+			},
+			Assert: func(t *testing.T, result *schema.OrderTimelineResponse) {
+				assert.Equal(t, uint(1), result.OrderID)
+				assert.Len(t, result.Events, 3)
+				assert.Nil(t, result.Events[0].PreviousStatus)
+				assert.Equal(t, model.OrderStatusEventSourceKafka, result.Events[1].Source)
+				assert.Equal(t, model.PurchaseHistoryStatusConfirm, *result.Events[2].PreviousStatus)
+				assert.Equal(t, model.PurchaseHistoryStatusSuccess, result.Events[2].Status)
+			},
+		},
+		{
+			Name:          "order without events is not found",
+			Events:        []model.OrderStatusEvent{},
+			ExpectedError: "order not found",
+			Assert: func(t *testing.T, result *schema.OrderTimelineResponse) {
+				assert.Nil(t, result)
+			},
+		},
+		{
+			Name:          "repository error",
+			RepoError:     errors.New("db error"),
+			ExpectedError: "db error",
+			Assert: func(t *testing.T, result *schema.OrderTimelineResponse) {
+				assert.Nil(t, result)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			providerRepo := new(mockRepo.ProviderRepositoryMock)
+			providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return([]model.Provider{}, nil)
+			eventRepo := new(mockRepo.OrderStatusEventRepositoryMock)
+			if tc.RepoError != nil {
+				eventRepo.On("GetOrderStatusEventsByOrderID", mock.Anything, uint(1)).Return(nil, tc.RepoError)
+			} else {
+				eventRepo.On("GetOrderStatusEventsByOrderID", mock.Anything, uint(1)).Return(tc.Events, nil)
+			}
+			grpcClients := &grpcClient.GRPCServiceClient{
+				ProviderGRPCClients: make(map[string]grpcClient.ProviderGRPCClient),
+			}
+
+			purchaseRepo := new(mockRepo.PurchaseHistoryRepositoryMock)
+			util.SetupDefaultPurchaseHistoryProviderMocks(purchaseRepo)
+			txManager := new(mockRepo.TransactionManagerMock)
+			util.SetupTransactionMocks(txManager)
+			orderService := service.NewOrderService(
+				new(mockRepo.SkuRepositoryMock),
+				purchaseRepo,
+				new(mockRepo.OrderRepositoryMock),
+				eventRepo,
+				new(mockRepo.OutboxRepositoryMock),
+				new(mockRepo.ProviderAttemptRepositoryMock),
+				txManager,
+				new(mockGrpc.RedisMock),
+				grpcClients,
+				providerRepo,
+				new(mockRepo.OrderReviewRepositoryMock),
+				new(mockRepo.OrderBatchRepositoryMock),
+				service.NewWalletService(new(mockRepo.LedgerRepositoryMock)),
+				service.NewPromotionService(new(mockRepo.PromotionRepositoryMock)),
+				dispatchTestConfig,
+				paymentTestConfig,
+			)
+			orderService.ReloadProviders(context.Background())
+			result, err := orderService.GetOrderTimeline(context.Background(), 1)
+
+			if tc.ExpectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.ExpectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			tc.Assert(t, result)
+			eventRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOrderService_GetUserOrderTimeline(t *testing.T) {
+	cachedOrder := util.CreateCachedOrderResponse(1001, 1, 10000, "0981234567", 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+	cachedOrderJSON, _ := json.Marshal(cachedOrder)
+	events := []model.OrderStatusEvent{
+		{OrderID: 1001, Status: model.PurchaseHistoryStatusPending, Source: model.OrderStatusEventSourceHTTP},
+	}
+
+	testCases := []struct {
+		Name          string
+		UserID        uint
+		ExpectEvents  bool
+		ExpectedError string
+	}{
+		{
+			Name:         "timeline of an order of the user",
+			UserID:       1,
+			ExpectEvents: true,
+		},
+		{
+			Name:          "order of another user is not found",
+			UserID:        2,
+			ExpectedError: "order not found",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			providerRepo := new(mockRepo.ProviderRepositoryMock)
+			providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return([]model.Provider{}, nil)
+			redis := new(mockGrpc.RedisMock)
+			redis.On("Get", mock.Anything, "order_id1001").Return(string(cachedOrderJSON), nil)
+			eventRepo := new(mockRepo.OrderStatusEventRepositoryMock)
+			if tc.ExpectEvents {
+				eventRepo.On("GetOrderStatusEventsByOrderID", mock.Anything, uint(1001)).Return(events, nil)
+			}
+			grpcClients := &grpcClient.GRPCServiceClient{
+				ProviderGRPCClients: make(map[string]grpcClient.ProviderGRPCClient),
+			}
+
+			orderService := service.NewOrderService(
+				new(mockRepo.SkuRepositoryMock),
+				new(mockRepo.PurchaseHistoryRepositoryMock),
+				new(mockRepo.OrderRepositoryMock),
+				eventRepo,
+				new(mockRepo.OutboxRepositoryMock),
+				new(mockRepo.ProviderAttemptRepositoryMock),
+				new(mockRepo.TransactionManagerMock),
+				redis,
+				grpcClients,
+				providerRepo,
+				new(mockRepo.OrderReviewRepositoryMock),
+				new(mockRepo.OrderBatchRepositoryMock),
+				service.NewWalletService(new(mockRepo.LedgerRepositoryMock)),
+				service.NewPromotionService(new(mockRepo.PromotionRepositoryMock)),
+				dispatchTestConfig,
+				paymentTestConfig,
+			)
+			orderService.ReloadProviders(context.Background())
+			result, err := orderService.GetUserOrderTimeline(context.Background(), 1001, tc.UserID)
+
+			if tc.ExpectedError != "" {
+				assert.ErrorContains(t, err, tc.ExpectedError)
+				assert.Nil(t, result)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, uint(1001), result.OrderID)
+				assert.Len(t, result.Events, 1)
+			}
+			eventRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func newProviderTestServer(statuses ...int) *httptest.Server {
+	var calls int
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := statuses[min(calls, len(statuses)-1)]
+		calls++
+		w.WriteHeader(status)
+	}))
+}
+
+func TestOrderService_GetOrder(t *testing.T) {
+	confirmedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	cachedOrder := util.CreateCachedOrderResponse(1001, 1, 10000, "0981234567", 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+	cachedOrder.Status = model.PurchaseHistoryStatusConfirm
+	cachedOrderJSON, _ := json.Marshal(cachedOrder)
+	sku := util.CreateMockSku(1, "VTL", 10000, model.CashBackTypePercentage, 5, "Viettel")
+
+	testCases := []struct {
+		Name          string
+		UserID        uint
+		SetupMocks    func(*mockGrpc.RedisMock, *mockRepo.OrderRepositoryMock, *mockRepo.PurchaseHistoryRepositoryMock)
+		ExpectedError string
+		Assert        func(t *testing.T, result *schema.OrderDetailResponse)
+	}{
+		{
+			Name:   "merges cached order with purchase history",
+			UserID: 1,
+			SetupMocks: func(redis *mockGrpc.RedisMock, orderRepo *mockRepo.OrderRepositoryMock, historyRepo *mockRepo.PurchaseHistoryRepositoryMock) {
+				redis.On("Get", mock.Anything, "order_id1001").Return(string(cachedOrderJSON), nil)
+				historyRepo.On("GetPurchaseHistoryByOrderID", mock.Anything, uint(1001)).Return(&model.PurchaseHistory{
+					Model:   gorm.Model{CreatedAt: confirmedAt, UpdatedAt: confirmedAt.Add(time.Minute)},
+					OrderID: 1001,
+					UserID:  1,
+					Status:  model.PurchaseHistoryStatusSuccess,
+				}, nil)
+			},
+			Assert: func(t *testing.T, result *schema.OrderDetailResponse) {
+				assert.Equal(t, uint(1001), result.OrderID)
+				assert.Equal(t, "VTL", result.Sku.SupplierInfo.Code)
+				assert.Equal(t, model.PurchaseHistoryStatusSuccess, result.Status)
+				assert.Equal(t, confirmedAt, *result.ConfirmedAt)
+				assert.Equal(t, confirmedAt.Add(time.Minute), *result.UpdatedAt)
+			},
+		},
+		{
+			Name:   "pending order without purchase history",
+			UserID: 1,
+			SetupMocks: func(redis *mockGrpc.RedisMock, orderRepo *mockRepo.OrderRepositoryMock, historyRepo *mockRepo.PurchaseHistoryRepositoryMock) {
+				redis.On("Get", mock.Anything, "order_id1001").Return("", errors.New("key not found"))
+				orderRepo.On("GetOrderByOrderID", mock.Anything, uint(1001)).Return(
+					util.CreatePersistedOrder(1001, 1, 10000, "0981234567", 500, model.PurchaseHistoryStatusPending, sku), nil)
+				redis.On("Set", mock.Anything, "order_id1001", mock.Anything, mock.Anything).Return(nil)
+				historyRepo.On("GetPurchaseHistoryByOrderID", mock.Anything, uint(1001)).Return(nil, gorm.ErrRecordNotFound)
+			},
+			Assert: func(t *testing.T, result *schema.OrderDetailResponse) {
+				assert.Equal(t, model.PurchaseHistoryStatusPending, result.Status)
+				assert.Nil(t, result.ConfirmedAt)
+				assert.Nil(t, result.UpdatedAt)
+			},
+		},
+		{
+			Name:   "order of another user is not found",
+			UserID: 2,
+			SetupMocks: func(redis *mockGrpc.RedisMock, orderRepo *mockRepo.OrderRepositoryMock, historyRepo *mockRepo.PurchaseHistoryRepositoryMock) {
+				redis.On("Get", mock.Anything, "order_id1001").Return(string(cachedOrderJSON), nil)
+			},
+			ExpectedError: "order not found",
+			Assert: func(t *testing.T, result *schema.OrderDetailResponse) {
+				assert.Nil(t, result)
+			},
+		},
+		{
+			Name:   "unknown order is not found",
+			UserID: 1,
+			SetupMocks: func(redis *mockGrpc.RedisMock, orderRepo *mockRepo.OrderRepositoryMock, historyRepo *mockRepo.PurchaseHistoryRepositoryMock) {
+				redis.On("Get", mock.Anything, "order_id1001").Return("", errors.New("key not found"))
+				orderRepo.On("GetOrderByOrderID", mock.Anything, uint(1001)).Return(nil, gorm.ErrRecordNotFound)
+			},
+			ExpectedError: "order not found or expired",
+			Assert: func(t *testing.T, result *schema.OrderDetailResponse) {
+				assert.Nil(t, result)
+			},
+		},
+		{
+			Name:   "purchase history error",
+			UserID: 1,
+			SetupMocks: func(redis *mockGrpc.RedisMock, orderRepo *mockRepo.OrderRepositoryMock, historyRepo *mockRepo.PurchaseHistoryRepositoryMock) {
+				redis.On("Get", mock.Anything, "order_id1001").Return(string(cachedOrderJSON), nil)
+				historyRepo.On("GetPurchaseHistoryByOrderID", mock.Anything, uint(1001)).Return(nil, errors.New("db error"))
+			},
+			ExpectedError: "db error",
+			Assert: func(t *testing.T, result *schema.OrderDetailResponse) {
+				assert.Nil(t, result)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			providerRepo := new(mockRepo.ProviderRepositoryMock)
+			providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return([]model.Provider{}, nil)
+			redis := new(mockGrpc.RedisMock)
+			orderRepo := new(mockRepo.OrderRepositoryMock)
+			historyRepo := new(mockRepo.PurchaseHistoryRepositoryMock)
+			tc.SetupMocks(redis, orderRepo, historyRepo)
+			grpcClients := &grpcClient.GRPCServiceClient{
+				ProviderGRPCClients: make(map[string]grpcClient.ProviderGRPCClient),
+			}
+
+			orderService := service.NewOrderService(
+				new(mockRepo.SkuRepositoryMock),
+				historyRepo,
+				orderRepo,
+				new(mockRepo.OrderStatusEventRepositoryMock),
+				new(mockRepo.OutboxRepositoryMock),
+				new(mockRepo.ProviderAttemptRepositoryMock),
+				new(mockRepo.TransactionManagerMock),
+				redis,
+				grpcClients,
+				providerRepo,
+				new(mockRepo.OrderReviewRepositoryMock),
+				new(mockRepo.OrderBatchRepositoryMock),
+				service.NewWalletService(new(mockRepo.LedgerRepositoryMock)),
+				service.NewPromotionService(new(mockRepo.PromotionRepositoryMock)),
+				dispatchTestConfig,
+				paymentTestConfig,
+			)
+			orderService.ReloadProviders(context.Background())
+			result, err := orderService.GetOrder(context.Background(), 1001, tc.UserID)
+
+			if tc.ExpectedError != "" {
+				assert.Error(t, err)
+				assert.EqualError(t, err, tc.ExpectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			tc.Assert(t, result)
+			redis.AssertExpectations(t)
+			orderRepo.AssertExpectations(t)
+			historyRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOrderService_WatchOrder(t *testing.T) {
+	pending := model.PurchaseHistoryStatusPending
+	confirm := model.PurchaseHistoryStatusConfirm
+	publish := func(from *model.PurchaseHistoryStatus, to model.PurchaseHistoryStatus) string {
+		payload, _ := json.Marshal(schema.OrderStatusUpdate{OrderID: 1001, PreviousStatus: from, Status: to})
+		return string(payload)
+	}
+
+	testCases := []struct {
+		Name             string
+		UserID           uint
+		CachedStatus     model.PurchaseHistoryStatus
+		Published        []string
+		SubscribeError   error
+		ExpectedError    string
+		ExpectedStatuses []model.PurchaseHistoryStatus
+	}{
+		{
+			Name:             "streams the current status and every change until the order settles",
+			UserID:           1,
+			CachedStatus:     model.PurchaseHistoryStatusPending,
+			Published:        []string{publish(&pending, confirm), publish(&confirm, model.PurchaseHistoryStatusSuccess)},
+			ExpectedStatuses: []model.PurchaseHistoryStatus{pending, confirm, model.PurchaseHistoryStatusSuccess},
+		},
+		{
+			Name:             "skips a change already included in the current status",
+			UserID:           1,
+			CachedStatus:     model.PurchaseHistoryStatusConfirm,
+			Published:        []string{publish(&pending, confirm), "not json", publish(&confirm, model.PurchaseHistoryStatusFailed)},
+			ExpectedStatuses: []model.PurchaseHistoryStatus{confirm, model.PurchaseHistoryStatusFailed},
+		},
+		{
+			Name:             "settled order ends after the current status",
+			UserID:           1,
+			CachedStatus:     model.PurchaseHistoryStatusSuccess,
+			ExpectedStatuses: []model.PurchaseHistoryStatus{model.PurchaseHistoryStatusSuccess},
+		},
+		{
+			Name:          "order of another user is not found",
+			UserID:        2,
+			CachedStatus:  model.PurchaseHistoryStatusPending,
+			ExpectedError: "order not found",
+		},
+		{
+			Name:           "subscribe error",
+			UserID:         1,
+			SubscribeError: errors.New("redis down"),
+			ExpectedError:  "redis down",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			providerRepo := new(mockRepo.ProviderRepositoryMock)
+			providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return([]model.Provider{}, nil)
+			redis := new(mockGrpc.RedisMock)
+			historyRepo := new(mockRepo.PurchaseHistoryRepositoryMock)
+
+			messages := make(chan string, len(tc.Published))
+			for _, message := range tc.Published {
+				messages <- message
+			}
+			if tc.SubscribeError != nil {
+				redis.On("Subscribe", mock.Anything, "order_status:1001").Return(nil, tc.SubscribeError)
+			} else {
+				redis.On("Subscribe", mock.Anything, "order_status:1001").Return((<-chan string)(messages), nil)
+				cachedOrder := util.CreateCachedOrderResponse(1001, 1, 10000, "0981234567", 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+				cachedOrder.Status = tc.CachedStatus
+				cachedOrderJSON, _ := json.Marshal(cachedOrder)
+				redis.On("Get", mock.Anything, "order_id1001").Return(string(cachedOrderJSON), nil)
+				historyRepo.On("GetPurchaseHistoryByOrderID", mock.Anything, uint(1001)).Return(nil, gorm.ErrRecordNotFound)
+			}
+			grpcClients := &grpcClient.GRPCServiceClient{
+				ProviderGRPCClients: make(map[string]grpcClient.ProviderGRPCClient),
+			}
+
+			orderService := service.NewOrderService(
+				new(mockRepo.SkuRepositoryMock),
+				historyRepo,
+				new(mockRepo.OrderRepositoryMock),
+				new(mockRepo.OrderStatusEventRepositoryMock),
+				new(mockRepo.OutboxRepositoryMock),
+				new(mockRepo.ProviderAttemptRepositoryMock),
+				new(mockRepo.TransactionManagerMock),
+				redis,
+				grpcClients,
+				providerRepo,
+				new(mockRepo.OrderReviewRepositoryMock),
+				new(mockRepo.OrderBatchRepositoryMock),
+				service.NewWalletService(new(mockRepo.LedgerRepositoryMock)),
+				service.NewPromotionService(new(mockRepo.PromotionRepositoryMock)),
+				dispatchTestConfig,
+				paymentTestConfig,
+			)
+			orderService.ReloadProviders(context.Background())
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			updates, err := orderService.WatchOrder(ctx, 1001, tc.UserID)
+
+			if tc.ExpectedError != "" {
+				assert.EqualError(t, err, tc.ExpectedError)
+				assert.Nil(t, updates)
+				return
+			}
+			assert.NoError(t, err)
+
+			var statuses []model.PurchaseHistoryStatus
+			for update := range updates {
+				assert.Equal(t, uint(1001), update.OrderID)
+				statuses = append(statuses, update.Status)
+			}
+			assert.Equal(t, tc.ExpectedStatuses, statuses)
+			assert.NoError(t, ctx.Err(), "stream should end when the order settles")
+		})
+	}
+}
+
+func TestOrderService_DispatchOrder(t *testing.T) {
+	testCases := []struct {
+		Name             string
+		CachedStatus     model.PurchaseHistoryStatus
+		Provider1        []int
+		Provider2        []int
+		ExpectedAttempts []string
+		ExpectedAssigned []string
+		NoProviders      bool
+		AssignError      error
+		ExpectFailed     bool
+		ExpectedError    string
+	}{
+		{
+			Name:             "first provider accepts the order",
+			CachedStatus:     model.PurchaseHistoryStatusConfirm,
+			Provider1:        []int{http.StatusOK},
+			Provider2:        []int{http.StatusOK},
+			ExpectedAttempts: []string{"PROVIDER1#1 ok"},
+			ExpectedAssigned: []string{"PROVIDER1"},
+		},
+		{
+			Name:             "transient error is retried on the same provider",
+			CachedStatus:     model.PurchaseHistoryStatusConfirm,
+			Provider1:        []int{http.StatusServiceUnavailable, http.StatusOK},
+			Provider2:        []int{http.StatusOK},
+			ExpectedAttempts: []string{"PROVIDER1#1 failed", "PROVIDER1#2 ok"},
+			ExpectedAssigned: []string{"PROVIDER1"},
+		},
+		{
+			Name:             "fails over to the next provider after the last retry",
+			CachedStatus:     model.PurchaseHistoryStatusConfirm,
+			Provider1:        []int{http.StatusServiceUnavailable},
+			Provider2:        []int{http.StatusOK},
+			ExpectedAttempts: []string{"PROVIDER1#1 failed", "PROVIDER1#2 failed", "PROVIDER2#1 ok"},
+			ExpectedAssigned: []string{"PROVIDER1", "PROVIDER2"},
+		},
+		{
+			Name:             "rejected request fails over without a retry",
+			CachedStatus:     model.PurchaseHistoryStatusConfirm,
+			Provider1:        []int{http.StatusBadRequest},
+			Provider2:        []int{http.StatusOK},
+			ExpectedAttempts: []string{"PROVIDER1#1 failed", "PROVIDER2#1 ok"},
+			ExpectedAssigned: []string{"PROVIDER1", "PROVIDER2"},
+		},
+		{
+			Name:             "order is failed once every provider is exhausted",
+			CachedStatus:     model.PurchaseHistoryStatusConfirm,
+			Provider1:        []int{http.StatusServiceUnavailable},
+			Provider2:        []int{http.StatusTooManyRequests},
+			ExpectedAttempts: []string{"PROVIDER1#1 failed", "PROVIDER1#2 failed", "PROVIDER2#1 failed", "PROVIDER2#2 failed"},
+			ExpectedAssigned: []string{"PROVIDER1", "PROVIDER2"},
+			ExpectFailed:     true,
+			ExpectedError:    "all providers failed",
+		},
+		{
+			Name:             "server error leaves the order with the provider that may have taken it",
+			CachedStatus:     model.PurchaseHistoryStatusConfirm,
+			Provider1:        []int{http.StatusInternalServerError},
+			Provider2:        []int{http.StatusOK},
+			ExpectedAttempts: []string{"PROVIDER1#1 failed"},
+			ExpectedAssigned: []string{"PROVIDER1"},
+			ExpectedError:    "provider may have taken the order",
+		},
+		{
+			Name:             "conflict is not retried or failed over",
+			CachedStatus:     model.PurchaseHistoryStatusConfirm,
+			Provider1:        []int{http.StatusServiceUnavailable, http.StatusConflict},
+			Provider2:        []int{http.StatusOK},
+			ExpectedAttempts: []string{"PROVIDER1#1 failed", "PROVIDER1#2 failed"},
+			ExpectedAssigned: []string{"PROVIDER1"},
+			ExpectedError:    "provider may have taken the order",
+		},
+		{
+			Name:          "order stays confirmed while no provider is loaded",
+			CachedStatus:  model.PurchaseHistoryStatusConfirm,
+			NoProviders:   true,
+			ExpectedError: "order is left confirmed to be dispatched again",
+		},
+		{
+			Name:             "order stays confirmed when it can't be assigned to a provider",
+			CachedStatus:     model.PurchaseHistoryStatusConfirm,
+			Provider1:        []int{http.StatusOK},
+			Provider2:        []int{http.StatusOK},
+			ExpectedAssigned: []string{"PROVIDER1"},
+			AssignError:      errors.New("connection refused"),
+			ExpectedError:    "order is left confirmed to be dispatched again: order 1001: connection refused",
+		},
+		{
+			Name:          "order that is not confirmed is not dispatched",
+			CachedStatus:  model.PurchaseHistoryStatusPending,
+			Provider1:     []int{http.StatusOK},
+			Provider2:     []int{http.StatusOK},
+			ExpectedError: "only confirmed orders can be dispatched",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			provider1 := newProviderTestServer(tc.Provider1...)
+			defer provider1.Close()
+			provider2 := newProviderTestServer(tc.Provider2...)
+			defer provider2.Close()
+
+			providers := []model.Provider{
+				util.CreateMockProvider(1, "PROVIDER1", provider1.URL, "http", 50, []model.Supplier{util.CreateMockSupplierWithStrategy("VTL", "Viettel", model.RoutingStrategyRoundRobin)}),
+				util.CreateMockProvider(2, "PROVIDER2", provider2.URL, "http", 50, []model.Supplier{util.CreateMockSupplierWithStrategy("VTL", "Viettel", model.RoutingStrategyRoundRobin)}),
+			}
+			if tc.NoProviders {
+				providers = []model.Provider{}
+			}
+			providerRepo := new(mockRepo.ProviderRepositoryMock)
+			providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(providers, nil)
+
+			cachedOrder := util.CreateCachedOrderResponse(1001, 1, 10000, "0981234567", 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+			cachedOrder.Status = tc.CachedStatus
+			cachedOrderJSON, _ := json.Marshal(cachedOrder)
+			redis := new(mockGrpc.RedisMock)
+			redis.On("Get", mock.Anything, "order_id1001").Return(string(cachedOrderJSON), nil)
+
+			var attempts []string
+			attemptRepo := new(mockRepo.ProviderAttemptRepositoryMock)
+			attemptRepo.On("CreateProviderAttempt", mock.Anything, mock.AnythingOfType("*model.ProviderAttempt")).
+				Run(func(args mock.Arguments) {
+					attempt := args.Get(1).(*model.ProviderAttempt)
+					result := "ok"
+					if !attempt.Success {
+						result = "failed"
+					}
+					attempts = append(attempts, fmt.Sprintf("%s#%d %s", attempt.ProviderCode, attempt.Attempt, result))
+				}).Return(nil).Maybe()
+
+			var assigned []string
+			purchaseRepo := new(mockRepo.PurchaseHistoryRepositoryMock)
+			util.SetupDefaultPurchaseHistoryProviderMocks(purchaseRepo)
+			orderRepo := new(mockRepo.OrderRepositoryMock)
+			orderRepo.On("AssignOrderProvider", mock.Anything, uint(1001), mock.AnythingOfType("string")).
+				Run(func(args mock.Arguments) {
+					assigned = append(assigned, args.String(2))
+				}).Return(tc.AssignError).Maybe()
+			eventRepo := new(mockRepo.OrderStatusEventRepositoryMock)
+			outboxRepo := new(mockRepo.OutboxRepositoryMock)
+			txManager := new(mockRepo.TransactionManagerMock)
+			util.SetupTransactionMocks(txManager)
+			if tc.ExpectFailed {
+				redis.On("TryAcquireLock", mock.Anything, "1001", mock.AnythingOfType("time.Duration")).Return(util.NewTestLock("1001"), nil)
+				redis.On("ReleaseLock", mock.Anything, util.LockOf("1001")).Return(nil)
+				util.SetupStoredOrderMocks(orderRepo, cachedOrder)
+				redis.On("Set", mock.Anything, "order_id1001", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
+				redis.On("Publish", mock.Anything, "order_status:1001", mock.AnythingOfType("[]uint8")).Return(nil)
+				purchaseRepo.On("UpdatePurchaseHistoryStatusByOrderID", mock.Anything, uint(1001), model.PurchaseHistoryStatusFailed).Return(nil)
+				orderRepo.On("UpdateOrderStatusByOrderID", mock.Anything, uint(1001), model.PurchaseHistoryStatusFailed, int64(1)).Return(nil)
+				eventRepo.On("CreateOrderStatusEvent", mock.Anything, mock.MatchedBy(func(event *model.OrderStatusEvent) bool {
+					return event.Source == model.OrderStatusEventSourceDispatcher && event.Status == model.PurchaseHistoryStatusFailed
+				})).Return(nil)
+				outboxRepo.On("CreateOutboxMessage", mock.Anything, mock.MatchedBy(func(message *model.OutboxMessage) bool {
+					return message.AggregateID == 1001 && message.EventType == model.OutboxEventOrderFailed
+				})).Return(nil)
+			}
+
+			grpcClients := &grpcClient.GRPCServiceClient{
+				ProviderGRPCClients: make(map[string]grpcClient.ProviderGRPCClient),
+			}
+			orderService := service.NewOrderService(new(mockRepo.SkuRepositoryMock), purchaseRepo, orderRepo, eventRepo, outboxRepo, attemptRepo, txManager, redis, grpcClients, providerRepo, new(mockRepo.OrderReviewRepositoryMock), new(mockRepo.OrderBatchRepositoryMock), service.NewWalletService(new(mockRepo.LedgerRepositoryMock)), service.NewPromotionService(new(mockRepo.PromotionRepositoryMock)), dispatchTestConfig, paymentTestConfig)
+			orderService.ReloadProviders(context.Background())
+			err := orderService.DispatchOrder(context.Background(), 1001)
+
+			if tc.ExpectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.ExpectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.ExpectedAttempts, attempts)
+			assert.Equal(t, tc.ExpectedAssigned, assigned, "every provider tried is assigned the order before it gets it")
+
+			redis.AssertExpectations(t)
+			purchaseRepo.AssertExpectations(t)
+			orderRepo.AssertExpectations(t)
+			eventRepo.AssertExpectations(t)
+			outboxRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOrderService_DispatchOrderNetworkErrors(t *testing.T) {
+	testCases := []struct {
+		Name             string
+		Provider1        func() *httptest.Server
+		ExpectedAttempts []string
+		ExpectedError    string
+	}{
+		{
+			Name: "unreachable provider is retried and failed over",
+			Provider1: func() *httptest.Server {
+				server := newProviderTestServer(http.StatusOK)
+				server.Close()
+				return server
+			},
+			ExpectedAttempts: []string{"PROVIDER1#1", "PROVIDER1#2", "PROVIDER2#1"},
+		},
+		{
+			Name: "timed out request is neither retried nor failed over",
+			Provider1: func() *httptest.Server {
+				return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					time.Sleep(2 * dispatchTestConfig.RequestTimeout)
+				}))
+			},
+			ExpectedAttempts: []string{"PROVIDER1#1"},
+			ExpectedError:    "provider may have taken the order",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			provider1 := tc.Provider1()
+			defer provider1.Close()
+			provider2 := newProviderTestServer(http.StatusOK)
+			defer provider2.Close()
+
+			providers := []model.Provider{
+				util.CreateMockProvider(1, "PROVIDER1", provider1.URL, "http", 50, []model.Supplier{util.CreateMockSupplierWithStrategy("VTL", "Viettel", model.RoutingStrategyRoundRobin)}),
+				util.CreateMockProvider(2, "PROVIDER2", provider2.URL, "http", 50, []model.Supplier{util.CreateMockSupplierWithStrategy("VTL", "Viettel", model.RoutingStrategyRoundRobin)}),
+			}
+			providerRepo := new(mockRepo.ProviderRepositoryMock)
+			providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(providers, nil)
+
+			cachedOrder := util.CreateCachedOrderResponse(1001, 1, 10000, "0981234567", 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+			cachedOrder.Status = model.PurchaseHistoryStatusConfirm
+			cachedOrderJSON, _ := json.Marshal(cachedOrder)
+			redis := new(mockGrpc.RedisMock)
+			redis.On("Get", mock.Anything, "order_id1001").Return(string(cachedOrderJSON), nil)
+
+			var attempts []string
+			attemptRepo := new(mockRepo.ProviderAttemptRepositoryMock)
+			attemptRepo.On("CreateProviderAttempt", mock.Anything, mock.AnythingOfType("*model.ProviderAttempt")).
+				Run(func(args mock.Arguments) {
+					attempt := args.Get(1).(*model.ProviderAttempt)
+					attempts = append(attempts, fmt.Sprintf("%s#%d", attempt.ProviderCode, attempt.Attempt))
+				}).Return(nil)
+
+			orderRepo := new(mockRepo.OrderRepositoryMock)
+			util.SetupDefaultOrderRepoMocks(orderRepo)
+			purchaseRepo := new(mockRepo.PurchaseHistoryRepositoryMock)
+			util.SetupDefaultPurchaseHistoryProviderMocks(purchaseRepo)
+			txManager := new(mockRepo.TransactionManagerMock)
+			util.SetupTransactionMocks(txManager)
+			grpcClients := &grpcClient.GRPCServiceClient{
+				ProviderGRPCClients: make(map[string]grpcClient.ProviderGRPCClient),
+			}
+			orderService := service.NewOrderService(new(mockRepo.SkuRepositoryMock), purchaseRepo, orderRepo, new(mockRepo.OrderStatusEventRepositoryMock), new(mockRepo.OutboxRepositoryMock), attemptRepo, txManager, redis, grpcClients, providerRepo, new(mockRepo.OrderReviewRepositoryMock), new(mockRepo.OrderBatchRepositoryMock), service.NewWalletService(new(mockRepo.LedgerRepositoryMock)), service.NewPromotionService(new(mockRepo.PromotionRepositoryMock)), dispatchTestConfig, paymentTestConfig)
+			orderService.ReloadProviders(context.Background())
+			err := orderService.DispatchOrder(context.Background(), 1001)
+
+			if tc.ExpectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.ExpectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.ExpectedAttempts, attempts)
+		})
+	}
+}
+
+func TestOrderService_DispatchOrderSkipsOpenProvider(t *testing.T) {
+	provider1 := newProviderTestServer(http.StatusServiceUnavailable)
+	defer provider1.Close()
+	provider2 := newProviderTestServer(http.StatusOK)
+	defer provider2.Close()
+
+	providers := []model.Provider{
+		util.CreateMockProvider(1, "PROVIDER1", provider1.URL, "http", 50, []model.Supplier{util.CreateMockSupplierWithStrategy("VTL", "Viettel", model.RoutingStrategyRoundRobin)}),
+		util.CreateMockProvider(2, "PROVIDER2", provider2.URL, "http", 50, []model.Supplier{util.CreateMockSupplierWithStrategy("VTL", "Viettel", model.RoutingStrategyRoundRobin)}),
+	}
+	providerRepo := new(mockRepo.ProviderRepositoryMock)
+	providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(providers, nil)
+
+	cachedOrder := util.CreateCachedOrderResponse(1001, 1, 10000, "0981234567", 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+	cachedOrder.Status = model.PurchaseHistoryStatusConfirm
+	cachedOrderJSON, _ := json.Marshal(cachedOrder)
+	redis := new(mockGrpc.RedisMock)
+	redis.On("Get", mock.Anything, "order_id1001").Return(string(cachedOrderJSON), nil)
+
+	var attempts []string
+	attemptRepo := new(mockRepo.ProviderAttemptRepositoryMock)
+	attemptRepo.On("CreateProviderAttempt", mock.Anything, mock.AnythingOfType("*model.ProviderAttempt")).
+		Run(func(args mock.Arguments) {
+			attempt := args.Get(1).(*model.ProviderAttempt)
+			attempts = append(attempts, fmt.Sprintf("%s#%d", attempt.ProviderCode, attempt.Attempt))
+		}).Return(nil)
+
+	orderRepo := new(mockRepo.OrderRepositoryMock)
+	util.SetupDefaultOrderRepoMocks(orderRepo)
+
+	dispatchConfig := dispatchTestConfig
+	dispatchConfig.CircuitBreaker = config.CircuitBreaker{WindowSize: 2, MinRequests: 2, OpenTimeout: time.Minute}
+	grpcClients := &grpcClient.GRPCServiceClient{
+		ProviderGRPCClients: make(map[string]grpcClient.ProviderGRPCClient),
+	}
+	purchaseRepo := new(mockRepo.PurchaseHistoryRepositoryMock)
+	util.SetupDefaultPurchaseHistoryProviderMocks(purchaseRepo)
+	txManager := new(mockRepo.TransactionManagerMock)
+	util.SetupTransactionMocks(txManager)
+	orderService := service.NewOrderService(
+		new(mockRepo.SkuRepositoryMock),
+		purchaseRepo,
+		orderRepo,
+		new(mockRepo.OrderStatusEventRepositoryMock),
+		new(mockRepo.OutboxRepositoryMock),
+		attemptRepo,
+		txManager,
+		redis,
+		grpcClients,
+		providerRepo,
+		new(mockRepo.OrderReviewRepositoryMock),
+		new(mockRepo.OrderBatchRepositoryMock),
+		service.NewWalletService(new(mockRepo.LedgerRepositoryMock)),
+		service.NewPromotionService(new(mockRepo.PromotionRepositoryMock)),
+		dispatchConfig,
+		paymentTestConfig,
+	)
+	orderService.ReloadProviders(context.Background())
+
+	assert.NoError(t, orderService.DispatchOrder(context.Background(), 1001))
+	assert.Equal(t, []string{"PROVIDER1#1", "PROVIDER1#2", "PROVIDER2#1"}, attempts)
+
+	attempts = nil
+	assert.NoError(t, orderService.DispatchOrder(context.Background(), 1001))
+	assert.Equal(t, []string{"PROVIDER2#1"}, attempts, "the open provider is left out of the selection")
+
+	health := orderService.GetProviderHealth(context.Background())
+	assert.Len(t, health, 2)
+	assert.Equal(t, "PROVIDER1", health[0].ProviderCode)
+	assert.Equal(t, "open", health[0].State)
+	assert.Equal(t, 0.0, health[0].HealthScore)
+	assert.NotNil(t, health[0].OpenedAt)
+	assert.Equal(t, "PROVIDER2", health[1].ProviderCode)
+	assert.Equal(t, "closed", health[1].State)
+	assert.Equal(t, 2, health[1].Requests)
+}
+
+func TestOrderService_ReloadProviders(t *testing.T) {
+	provider1 := newProviderTestServer(http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	defer provider1.Close()
+	provider2 := newProviderTestServer(http.StatusOK)
+	defer provider2.Close()
+
+	initial := []model.Provider{
+		util.CreateMockProvider(1, "PROVIDER1", provider1.URL, "http", 100, []model.Supplier{util.CreateMockSupplierWithStrategy("VTL", "Viettel", model.RoutingStrategyRoundRobin)}),
+	}
+	reloaded := []model.Provider{
+		util.CreateMockProvider(1, "PROVIDER1", provider1.URL, "http", 100, []model.Supplier{util.CreateMockSupplierWithStrategy("VTL", "Viettel", model.RoutingStrategyRoundRobin)}),
+		util.CreateMockProvider(2, "PROVIDER2", provider2.URL, "http", 100, []model.Supplier{util.CreateMockSupplierWithStrategy("VTL", "Viettel", model.RoutingStrategyRoundRobin)}),
+	}
+	providerRepo := new(mockRepo.ProviderRepositoryMock)
+	providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(initial, nil).Once()
+	providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(nil, errors.New("db error")).Once()
+	providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(reloaded, nil).Once()
+
+	cachedOrder := util.CreateCachedOrderResponse(1001, 1, 10000, "0981234567", 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+	cachedOrder.Status = model.PurchaseHistoryStatusConfirm
+	cachedOrderJSON, _ := json.Marshal(cachedOrder)
+	redis := new(mockGrpc.RedisMock)
+	redis.On("Get", mock.Anything, "order_id1001").Return(string(cachedOrderJSON), nil)
+	// Keep the failed first dispatch from touching the order
+	redis.On("TryAcquireLock", mock.Anything, "1001", mock.AnythingOfType("time.Duration")).Return(nil, errors.New("locked")).Once()
+
+	attemptRepo := new(mockRepo.ProviderAttemptRepositoryMock)
+	util.SetupDefaultProviderAttemptMocks(attemptRepo)
+
+	orderRepo := new(mockRepo.OrderRepositoryMock)
+	util.SetupDefaultOrderRepoMocks(orderRepo)
+
+	dispatchConfig := dispatchTestConfig
+	dispatchConfig.CircuitBreaker = config.CircuitBreaker{WindowSize: 2, MinRequests: 2, OpenTimeout: time.Minute}
+	grpcClients := &grpcClient.GRPCServiceClient{
+		ProviderGRPCClients: make(map[string]grpcClient.ProviderGRPCClient),
+	}
+	purchaseRepo := new(mockRepo.PurchaseHistoryRepositoryMock)
+	util.SetupDefaultPurchaseHistoryProviderMocks(purchaseRepo)
+	txManager := new(mockRepo.TransactionManagerMock)
+	util.SetupTransactionMocks(txManager)
+	orderService := service.NewOrderService(
+		new(mockRepo.SkuRepositoryMock),
+		purchaseRepo,
+		orderRepo,
+		new(mockRepo.OrderStatusEventRepositoryMock),
+		new(mockRepo.OutboxRepositoryMock),
+		attemptRepo,
+		txManager,
+		redis,
+		grpcClients,
+		providerRepo,
+		new(mockRepo.OrderReviewRepositoryMock),
+		new(mockRepo.OrderBatchRepositoryMock),
+		service.NewWalletService(new(mockRepo.LedgerRepositoryMock)),
+		service.NewPromotionService(new(mockRepo.PromotionRepositoryMock)),
+		dispatchConfig,
+		paymentTestConfig,
+	)
+	orderService.ReloadProviders(context.Background())
+
+	// Open the breaker of PROVIDER1 so its state can be followed across reloads
+	orderService.DispatchOrder(context.Background(), 1001)
+
+	err := orderService.ReloadProviders(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "db error")
+	health := orderService.GetProviderHealth(context.Background())
+	assert.Len(t, health, 1, "a failed reload keeps the previous routing table")
+	assert.Equal(t, "open", health[0].State)
+
+	assert.NoError(t, orderService.ReloadProviders(context.Background()))
+	health = orderService.GetProviderHealth(context.Background())
+	assert.Len(t, health, 2)
+	assert.Equal(t, "PROVIDER1", health[0].ProviderCode)
+	assert.Equal(t, "open", health[0].State, "a reload keeps the breaker of an existing provider")
+	assert.Equal(t, "PROVIDER2", health[1].ProviderCode)
+
+	assert.NoError(t, orderService.DispatchOrder(context.Background(), 1001), "the added provider takes the order")
+	providerRepo.AssertExpectations(t)
+}
+
+func TestOrderService_RoutingStrategies(t *testing.T) {
+	testCases := []struct {
+		Name            string
+		Strategy        model.RoutingStrategy
+		Weights         [2]int
+		Discounts       [2]float64
+		Provider1Delay  time.Duration
+		PhoneNumbers    []string
+		ExpectedFirstBy []string
+	}{
+		{
+			Name:            "weighted random never starts from a provider without weight",
+			Strategy:        model.RoutingStrategyWeightedRandom,
+			Weights:         [2]int{0, 100},
+			PhoneNumbers:    []string{"0912345678", "0912345678", "0912345678"},
+			ExpectedFirstBy: []string{"PROVIDER2", "PROVIDER2", "PROVIDER2"},
+		},
+		{
+			Name:            "supplier without a strategy uses weighted random",
+			Weights:         [2]int{100, 0},
+			PhoneNumbers:    []string{"0912345678", "0912345678"},
+			ExpectedFirstBy: []string{"PROVIDER1", "PROVIDER1"},
+		},
+		{
+			Name:            "least cost starts from the largest discount",
+			Strategy:        model.RoutingStrategyLeastCost,
+			Weights:         [2]int{90, 10},
+			Discounts:       [2]float64{2.5, 4},
+			PhoneNumbers:    []string{"0912345678", "0987654321"},
+			ExpectedFirstBy: []string{"PROVIDER2", "PROVIDER2"},
+		},
+		{
+			Name:            "round robin alternates between providers",
+			Strategy:        model.RoutingStrategyRoundRobin,
+			Weights:         [2]int{90, 10},
+			PhoneNumbers:    []string{"0912345678", "0912345678", "0912345678"},
+			ExpectedFirstBy: []string{"PROVIDER1", "PROVIDER2", "PROVIDER1"},
+		},
+		{
+			Name:            "lowest latency measures every provider and then keeps the faster one",
+			Strategy:        model.RoutingStrategyLowestLatency,
+			Weights:         [2]int{50, 50},
+			Provider1Delay:  20 * time.Millisecond,
+			PhoneNumbers:    []string{"0912345678", "0912345678", "0912345678"},
+			ExpectedFirstBy: []string{"PROVIDER1", "PROVIDER2", "PROVIDER2"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			provider1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(tc.Provider1Delay)
+			}))
+			defer provider1.Close()
+			provider2 := newProviderTestServer(http.StatusOK)
+			defer provider2.Close()
+
+			supplier := util.CreateMockSupplierWithStrategy("VTL", "Viettel", tc.Strategy)
+			supplier.ID = 1
+			providers := []model.Provider{
+				util.CreateMockProvider(1, "PROVIDER1", provider1.URL, "http", tc.Weights[0], []model.Supplier{supplier}),
+				util.CreateMockProvider(2, "PROVIDER2", provider2.URL, "http", tc.Weights[1], []model.Supplier{supplier}),
+			}
+			// Both providers also serve MBF with the opposite weights, VTL orders only follow the VTL weights
+			otherSupplier := util.CreateMockSupplierWithStrategy("MBF", "Mobifone", tc.Strategy)
+			otherSupplier.ID = 2
+			for i := range providers {
+				providers[i].Suppliers = append(providers[i].Suppliers, otherSupplier)
+				providers[i].ProviderSuppliers = append(providers[i].ProviderSuppliers, model.ProviderSupplier{
+					ProviderID: providers[i].ID, SupplierID: otherSupplier.ID, Weight: tc.Weights[1-i],
+				})
+			}
+			providers[0].Discount, providers[1].Discount = tc.Discounts[0], tc.Discounts[1]
+			providerRepo := new(mockRepo.ProviderRepositoryMock)
+			providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(providers, nil)
+
+			redis := new(mockGrpc.RedisMock)
+			for i, phoneNumber := range tc.PhoneNumbers {
+				cachedOrder := util.CreateCachedOrderResponse(uint(2001+i), 1, 10000, phoneNumber, 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+				cachedOrder.Status = model.PurchaseHistoryStatusConfirm
+				cachedOrderJSON, _ := json.Marshal(cachedOrder)
+				redis.On("Get", mock.Anything, fmt.Sprintf("order_id%d", 2001+i)).Return(string(cachedOrderJSON), nil)
+			}
+
+			var firstBy []string
+			attemptRepo := new(mockRepo.ProviderAttemptRepositoryMock)
+			attemptRepo.On("CreateProviderAttempt", mock.Anything, mock.AnythingOfType("*model.ProviderAttempt")).
+				Run(func(args mock.Arguments) {
+					firstBy = append(firstBy, args.Get(1).(*model.ProviderAttempt).ProviderCode)
+				}).Return(nil)
+
+			orderRepo := new(mockRepo.OrderRepositoryMock)
+			util.SetupDefaultOrderRepoMocks(orderRepo)
+
+			grpcClients := &grpcClient.GRPCServiceClient{
+				ProviderGRPCClients: make(map[string]grpcClient.ProviderGRPCClient),
+			}
+			purchaseRepo := new(mockRepo.PurchaseHistoryRepositoryMock)
+			util.SetupDefaultPurchaseHistoryProviderMocks(purchaseRepo)
+			txManager := new(mockRepo.TransactionManagerMock)
+			util.SetupTransactionMocks(txManager)
+			orderService := service.NewOrderService(
+				new(mockRepo.SkuRepositoryMock),
+				purchaseRepo,
+				orderRepo,
+				new(mockRepo.OrderStatusEventRepositoryMock),
+				new(mockRepo.OutboxRepositoryMock),
+				attemptRepo,
+				txManager,
+				redis,
+				grpcClients,
+				providerRepo,
+				new(mockRepo.OrderReviewRepositoryMock),
+				new(mockRepo.OrderBatchRepositoryMock),
+				service.NewWalletService(new(mockRepo.LedgerRepositoryMock)),
+				service.NewPromotionService(new(mockRepo.PromotionRepositoryMock)),
+				dispatchTestConfig,
+				paymentTestConfig,
+			)
+			orderService.ReloadProviders(context.Background())
+
+			for i := range tc.PhoneNumbers {
+				assert.NoError(t, orderService.DispatchOrder(context.Background(), uint(2001+i)))
+			}
+			assert.Equal(t, tc.ExpectedFirstBy, firstBy)
+		})
+	}
+}
+
+func TestOrderService_StickyPhonePrefixRouting(t *testing.T) {
+	provider1 := newProviderTestServer(http.StatusOK)
+	defer provider1.Close()
+	provider2 := newProviderTestServer(http.StatusOK)
+	defer provider2.Close()
+
+	supplier := util.CreateMockSupplierWithStrategy("VTL", "Viettel", model.RoutingStrategyStickyPhonePrefix)
+	providers := []model.Provider{
+		util.CreateMockProvider(1, "PROVIDER1", provider1.URL, "http", 50, []model.Supplier{supplier}),
+		util.CreateMockProvider(2, "PROVIDER2", provider2.URL, "http", 50, []model.Supplier{supplier}),
+	}
+	providerRepo := new(mockRepo.ProviderRepositoryMock)
+	providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(providers, nil)
+
+	// Numbers sharing the first four digits, and one number per other prefix
+	phoneNumbers := []string{"0912000001", "0912999999", "0912345678", "0987000001", "0352000001", "0777000001", "0888000001"}
+	redis := new(mockGrpc.RedisMock)
+	for i, phoneNumber := range phoneNumbers {
+		cachedOrder := util.CreateCachedOrderResponse(uint(3001+i), 1, 10000, phoneNumber, 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+		cachedOrder.Status = model.PurchaseHistoryStatusConfirm
+		cachedOrderJSON, _ := json.Marshal(cachedOrder)
+		redis.On("Get", mock.Anything, fmt.Sprintf("order_id%d", 3001+i)).Return(string(cachedOrderJSON), nil)
+	}
+
+	firstBy := make(map[uint]string)
+	attemptRepo := new(mockRepo.ProviderAttemptRepositoryMock)
+	attemptRepo.On("CreateProviderAttempt", mock.Anything, mock.AnythingOfType("*model.ProviderAttempt")).
+		Run(func(args mock.Arguments) {
+			attempt := args.Get(1).(*model.ProviderAttempt)
+			firstBy[attempt.OrderID] = attempt.ProviderCode
+		}).Return(nil)
+
+	orderRepo := new(mockRepo.OrderRepositoryMock)
+	util.SetupDefaultOrderRepoMocks(orderRepo)
+
+	grpcClients := &grpcClient.GRPCServiceClient{
+		ProviderGRPCClients: make(map[string]grpcClient.ProviderGRPCClient),
+	}
+	purchaseRepo := new(mockRepo.PurchaseHistoryRepositoryMock)
+	util.SetupDefaultPurchaseHistoryProviderMocks(purchaseRepo)
+	txManager := new(mockRepo.TransactionManagerMock)
+	util.SetupTransactionMocks(txManager)
+	orderService := service.NewOrderService(
+		new(mockRepo.SkuRepositoryMock),
+		purchaseRepo,
+		orderRepo,
+		new(mockRepo.OrderStatusEventRepositoryMock),
+		new(mockRepo.OutboxRepositoryMock),
+		attemptRepo,
+		txManager,
+		redis,
+		grpcClients,
+		providerRepo,
+		new(mockRepo.OrderReviewRepositoryMock),
+		new(mockRepo.OrderBatchRepositoryMock),
+		service.NewWalletService(new(mockRepo.LedgerRepositoryMock)),
+		service.NewPromotionService(new(mockRepo.PromotionRepositoryMock)),
+		dispatchTestConfig,
+		paymentTestConfig,
+	)
+	orderService.ReloadProviders(context.Background())
+
+	for round := 0; round < 2; round++ {
+		for i := range phoneNumbers {
+			orderID := uint(3001 + i)
+			previous, seen := firstBy[orderID]
+			assert.NoError(t, orderService.DispatchOrder(context.Background(), orderID))
+			if seen {
+				assert.Equal(t, previous, firstBy[orderID], "the same number keeps its provider")
+			}
+		}
+	}
+	assert.Equal(t, firstBy[3001], firstBy[3002], "numbers with the same prefix share a provider")
+	assert.Equal(t, firstBy[3001], firstBy[3003], "numbers with the same prefix share a provider")
+
+	used := make(map[string]bool)
+	for _, providerCode := range firstBy {
+		used[providerCode] = true
+	}
+	assert.Len(t, used, 2, "different prefixes are spread over the providers")
+}
+
+func TestOrderService_ExpirePendingOrders(t *testing.T) {
+	createdBefore := time.Now().Add(-30 * time.Minute)
+	testCases := []struct {
+		Name            string
+		StoredStatus    model.PurchaseHistoryStatus
+		ExpectExpired   bool
+		ExpectedExpired int
+	}{
+		{
+			Name:            "abandoned order is expired and its payment cancelled",
+			StoredStatus:    model.PurchaseHistoryStatusPending,
+			ExpectExpired:   true,
+			ExpectedExpired: 1,
+		},
+		{
+			Name:         "order confirmed since it was listed is skipped",
+			StoredStatus: model.PurchaseHistoryStatusConfirm,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			storedOrder := util.CreateCachedOrderResponse(1001, 1, 10000, "0981234567", 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+			storedOrder.Status = tc.StoredStatus
+			storedOrder.WalletAmount = 2000
+			storedOrder.Promotions = []schema.AppliedPromotion{{PromotionID: 7, Code: "SUMMER10", CashBackValue: 500}}
+
+			redis := new(mockGrpc.RedisMock)
+			redis.On("TryAcquireLock", mock.Anything, "1001", mock.AnythingOfType("time.Duration")).Return(util.NewTestLock("1001"), nil)
+			redis.On("ReleaseLock", mock.Anything, util.LockOf("1001")).Return(nil)
+
+			orderRepo := new(mockRepo.OrderRepositoryMock)
+			util.SetupStoredOrderMocks(orderRepo, storedOrder)
+			orderRepo.On("GetPendingOrderIDsCreatedBefore", mock.Anything, createdBefore, 50).Return([]uint{1001}, nil)
+			purchaseRepo := new(mockRepo.PurchaseHistoryRepositoryMock)
+			eventRepo := new(mockRepo.OrderStatusEventRepositoryMock)
+			outboxRepo := new(mockRepo.OutboxRepositoryMock)
+			ledgerRepo := new(mockRepo.LedgerRepositoryMock)
+			promotionRepo := new(mockRepo.PromotionRepositoryMock)
+			txManager := new(mockRepo.TransactionManagerMock)
+			util.SetupTransactionMocks(txManager)
+			if tc.ExpectExpired {
+				redis.On("Set", mock.Anything, "order_id1001", mock.MatchedBy(func(value []byte) bool {
+					var order schema.OrderResponse
+					return json.Unmarshal(value, &order) == nil && order.Status == model.PurchaseHistoryStatusExpired && order.Promotions[0].Released
+				}), mock.AnythingOfType("time.Duration")).Return(nil)
+				redis.On("Publish", mock.Anything, "order_status:1001", mock.AnythingOfType("[]uint8")).Return(nil)
+				purchaseRepo.On("CreatePurchaseHistory", mock.Anything, mock.MatchedBy(func(history *model.PurchaseHistory) bool {
+					return history.OrderID == 1001 && history.Status == model.PurchaseHistoryStatusExpired && history.TotalPrice == 10000 && history.PaymentReference == ""
+				})).Return(nil)
+				orderRepo.On("UpdateOrderStatusByOrderID", mock.Anything, uint(1001), model.PurchaseHistoryStatusExpired, int64(1)).Return(nil)
+				eventRepo.On("CreateOrderStatusEvent", mock.Anything, mock.MatchedBy(func(event *model.OrderStatusEvent) bool {
+					return event.Source == model.OrderStatusEventSourceExpirySweeper && event.Status == model.PurchaseHistoryStatusExpired
+				})).Return(nil)
+				outboxRepo.On("CreateOutboxMessage", mock.Anything, mock.MatchedBy(func(message *model.OutboxMessage) bool {
+					return message.AggregateID == 1001 && message.EventType == model.OutboxEventOrderExpired &&
+						message.Method == http.MethodPatch && message.Destination == paymentTestConfig.UpdateURL && strings.Contains(message.Payload, `"status":"expired"`)
+				})).Return(nil)
+				util.SetupDefaultLedgerAccountMocks(ledgerRepo)
+				ledgerRepo.On("CreateLedgerTransaction", mock.Anything, util.LedgerTransactionOf(model.LedgerTransactionTypeOrderRefund, 1001, 2000)).Return(nil).Once()
+				promotionRepo.On("ReleasePromotionRedemptions", mock.Anything, uint(1001), mock.AnythingOfType("time.Time")).Return(nil).Once()
+			}
+
+			grpcClients := &grpcClient.GRPCServiceClient{
+				ProviderGRPCClients: make(map[string]grpcClient.ProviderGRPCClient),
+			}
+			providerRepo := new(mockRepo.ProviderRepositoryMock)
+			providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+			orderService := service.NewOrderService(new(mockRepo.SkuRepositoryMock), purchaseRepo, orderRepo, eventRepo, outboxRepo, new(mockRepo.ProviderAttemptRepositoryMock), txManager, redis, grpcClients, providerRepo, new(mockRepo.OrderReviewRepositoryMock), new(mockRepo.OrderBatchRepositoryMock), service.NewWalletService(ledgerRepo), service.NewPromotionService(promotionRepo), dispatchTestConfig, paymentTestConfig)
+			orderService.ReloadProviders(context.Background())
+			expired, err := orderService.ExpirePendingOrders(context.Background(), createdBefore, 50)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.ExpectedExpired, expired)
+			redis.AssertExpectations(t)
+			purchaseRepo.AssertExpectations(t)
+			orderRepo.AssertExpectations(t)
+			eventRepo.AssertExpectations(t)
+			outboxRepo.AssertExpectations(t)
+			ledgerRepo.AssertExpectations(t)
+			promotionRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOrderService_ExpirePendingOrdersKeepsGoingAfterAFailure(t *testing.T) {
+	createdBefore := time.Now().Add(-30 * time.Minute)
+	redis := new(mockGrpc.RedisMock)
+	redis.On("TryAcquireLock", mock.Anything, "1001", mock.AnythingOfType("time.Duration")).Return(nil, context.DeadlineExceeded)
+	redis.On("TryAcquireLock", mock.Anything, "1002", mock.AnythingOfType("time.Duration")).Return(util.NewTestLock("1002"), nil)
+	redis.On("ReleaseLock", mock.Anything, util.LockOf("1002")).Return(nil)
+	redis.On("Set", mock.Anything, "order_id1002", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
+	redis.On("Publish", mock.Anything, "order_status:1002", mock.AnythingOfType("[]uint8")).Return(nil)
+
+	orderRepo := new(mockRepo.OrderRepositoryMock)
+	util.SetupStoredOrderMocks(orderRepo, util.CreateCachedOrderResponse(1002, 1, 10000, "0981234567", 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5))
+	orderRepo.On("GetPendingOrderIDsCreatedBefore", mock.Anything, createdBefore, 50).Return([]uint{1001, 1002}, nil)
+	orderRepo.On("UpdateOrderStatusByOrderID", mock.Anything, uint(1002), model.PurchaseHistoryStatusExpired, int64(1)).Return(nil)
+	purchaseRepo := new(mockRepo.PurchaseHistoryRepositoryMock)
+	purchaseRepo.On("CreatePurchaseHistory", mock.Anything, mock.AnythingOfType("*model.PurchaseHistory")).Return(nil)
+	eventRepo := new(mockRepo.OrderStatusEventRepositoryMock)
+	eventRepo.On("CreateOrderStatusEvent", mock.Anything, mock.AnythingOfType("*model.OrderStatusEvent")).Return(nil)
+	outboxRepo := new(mockRepo.OutboxRepositoryMock)
+	outboxRepo.On("CreateOutboxMessage", mock.Anything, mock.AnythingOfType("*model.OutboxMessage")).Return(nil)
+	txManager := new(mockRepo.TransactionManagerMock)
+	util.SetupTransactionMocks(txManager)
+
+	grpcClients := &grpcClient.GRPCServiceClient{
+		ProviderGRPCClients: make(map[string]grpcClient.ProviderGRPCClient),
+	}
+	providerRepo := new(mockRepo.ProviderRepositoryMock)
+	providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+	orderService := service.NewOrderService(new(mockRepo.SkuRepositoryMock), purchaseRepo, orderRepo, eventRepo, outboxRepo, new(mockRepo.ProviderAttemptRepositoryMock), txManager, redis, grpcClients, providerRepo, new(mockRepo.OrderReviewRepositoryMock), new(mockRepo.OrderBatchRepositoryMock), service.NewWalletService(new(mockRepo.LedgerRepositoryMock)), service.NewPromotionService(new(mockRepo.PromotionRepositoryMock)), dispatchTestConfig, paymentTestConfig)
+	orderService.ReloadProviders(context.Background())
+	expired, err := orderService.ExpirePendingOrders(context.Background(), createdBefore, 50)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, expired)
+	orderRepo.AssertExpectations(t)
+}
+
+func TestOrderService_ReconcileStuckOrders(t *testing.T) {
+	var queried []string
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queried = append(queried, r.Method+" "+r.URL.Path)
+		switch r.URL.Path {
+		case "/orders/1001":
+			json.NewEncoder(w).Encode(schema.OrderProviderQueryResponse{OrderID: 1001, Status: schema.ProviderOrderStatusSuccess})
+		case "/orders/1002":
+			json.NewEncoder(w).Encode(schema.OrderProviderQueryResponse{OrderID: 1002, Status: schema.ProviderOrderStatusProcessing})
+		case "/orders":
+			// 1005 is taken when it is dispatched again
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer provider.Close()
+
+	now := time.Now()
+	updatedBefore, escalateBefore := now.Add(-15*time.Minute), now.Add(-2*time.Hour)
+	stuckOrders := []model.Order{
+		{OrderID: 1001, ProviderCode: "PROVIDER1", Model: gorm.Model{UpdatedAt: now.Add(-20 * time.Minute)}},
+		{OrderID: 1002, ProviderCode: "PROVIDER1", Model: gorm.Model{UpdatedAt: now.Add(-20 * time.Minute)}},
+		{OrderID: 1003, ProviderCode: "PROVIDER1", Model: gorm.Model{UpdatedAt: now.Add(-3 * time.Hour)}},
+		{OrderID: 1004, Model: gorm.Model{UpdatedAt: now.Add(-3 * time.Hour)}},
+		{OrderID: 1005, Model: gorm.Model{UpdatedAt: now.Add(-20 * time.Minute)}},
+	}
+
+	providerRepo := new(mockRepo.ProviderRepositoryMock)
+	providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return([]model.Provider{
+		util.CreateMockProvider(1, "PROVIDER1", provider.URL+"/orders", "http", 100, []model.Supplier{util.CreateMockSupplier("VTL", "Viettel")}),
+	}, nil)
+
+	storedOrder := util.CreateCachedOrderResponse(1001, 1, 10000, "0981234567", 0, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+	storedOrder.Status = model.PurchaseHistoryStatusConfirm
+	redis := new(mockGrpc.RedisMock)
+	redis.On("Get", mock.Anything, "order_req_id1001:success").Return("", errors.New("not found"))
+	redis.On("TryAcquireLock", mock.Anything, "1001", mock.AnythingOfType("time.Duration")).Return(util.NewTestLock("1001"), nil)
+	redis.On("ReleaseLock", mock.Anything, util.LockOf("1001")).Return(nil)
+	redis.On("Set", mock.Anything, "order_id1001", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
+	redis.On("Publish", mock.Anything, "order_status:1001", mock.AnythingOfType("[]uint8")).Return(nil)
+	redis.On("Set", mock.Anything, "order_req_id1001:success", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
+
+	// 1004 has no provider to go to yet, 1005 gets one when it is dispatched again
+	undispatchableOrder := util.CreateCachedOrderResponse(1004, 1, 10000, "0871234567", 0, 2, "ITL", "Itel", model.CashBackTypePercentage, 5)
+	undispatchableOrder.Status = model.PurchaseHistoryStatusConfirm
+	undispatchedOrder := util.CreateCachedOrderResponse(1005, 1, 10000, "0981234567", 0, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+	undispatchedOrder.Status = model.PurchaseHistoryStatusConfirm
+
+	orderRepo := new(mockRepo.OrderRepositoryMock)
+	util.SetupStoredOrderMocks(orderRepo, storedOrder)
+	util.SetupStoredOrderMocks(orderRepo, undispatchableOrder)
+	util.SetupStoredOrderMocks(orderRepo, undispatchedOrder)
+	orderRepo.On("AssignOrderProvider", mock.Anything, uint(1005), "PROVIDER1").Return(nil).Once()
+	orderRepo.On("GetStuckOrders", mock.Anything, updatedBefore, 50).Return(stuckOrders, nil)
+	orderRepo.On("UpdateOrderStatusByOrderID", mock.Anything, uint(1001), model.PurchaseHistoryStatusSuccess, int64(1)).Return(nil)
+	purchaseRepo := new(mockRepo.PurchaseHistoryRepositoryMock)
+	purchaseRepo.On("UpdatePurchaseHistoryStatusByOrderID", mock.Anything, uint(1001), model.PurchaseHistoryStatusSuccess).Return(nil)
+	purchaseRepo.On("UpdatePurchaseHistoryProviderByOrderID", mock.Anything, uint(1005), "PROVIDER1").Return(nil).Once()
+	attemptRepo := new(mockRepo.ProviderAttemptRepositoryMock)
+	util.SetupDefaultProviderAttemptMocks(attemptRepo)
+	eventRepo := new(mockRepo.OrderStatusEventRepositoryMock)
+	eventRepo.On("CreateOrderStatusEvent", mock.Anything, mock.MatchedBy(func(event *model.OrderStatusEvent) bool {
+		return event.OrderID == 1001 && event.Source == model.OrderStatusEventSourceReconciler && event.Status == model.PurchaseHistoryStatusSuccess
+	})).Return(nil)
+	outboxRepo := new(mockRepo.OutboxRepositoryMock)
+	outboxRepo.On("CreateOutboxMessage", mock.Anything, mock.AnythingOfType("*model.OutboxMessage")).Return(nil)
+	reviewRepo := new(mockRepo.OrderReviewRepositoryMock)
+	reviewRepo.On("CreateOrderReview", mock.Anything, mock.MatchedBy(func(review *model.OrderReview) bool {
+		return review.OrderID == 1003 && review.ProviderCode == "PROVIDER1" && review.Reason == "provider PROVIDER1 never got the order"
+	})).Return(nil).Once()
+	reviewRepo.On("CreateOrderReview", mock.Anything, mock.MatchedBy(func(review *model.OrderReview) bool {
+		return review.OrderID == 1004 && review.Reason == "order was never dispatched to a provider"
+	})).Return(nil).Once()
+	txManager := new(mockRepo.TransactionManagerMock)
+	util.SetupTransactionMocks(txManager)
+
+	grpcClients := &grpcClient.GRPCServiceClient{
+		ProviderGRPCClients: make(map[string]grpcClient.ProviderGRPCClient),
+	}
+	orderService := service.NewOrderService(new(mockRepo.SkuRepositoryMock), purchaseRepo, orderRepo, eventRepo, outboxRepo, attemptRepo, txManager, redis, grpcClients, providerRepo, reviewRepo, new(mockRepo.OrderBatchRepositoryMock), service.NewWalletService(new(mockRepo.LedgerRepositoryMock)), service.NewPromotionService(new(mockRepo.PromotionRepositoryMock)), dispatchTestConfig, paymentTestConfig)
+	orderService.ReloadProviders(context.Background())
+	result, err := orderService.ReconcileStuckOrders(context.Background(), updatedBefore, escalateBefore, 50)
+
+	assert.NoError(t, err)
+	assert.Equal(t, schema.ReconciliationResult{Dispatched: 1, Settled: 1, Escalated: 2}, result)
+	assert.Equal(t, []string{"GET /orders/1001", "GET /orders/1002", "GET /orders/1003", "POST /orders"}, queried)
+	redis.AssertExpectations(t)
+	orderRepo.AssertExpectations(t)
+	purchaseRepo.AssertExpectations(t)
+	eventRepo.AssertExpectations(t)
+	reviewRepo.AssertExpectations(t)
+}
+
+func TestOrderService_ResolveOrderReview(t *testing.T) {
+	openReview := &model.OrderReview{ID: 5, OrderID: 1003, ProviderCode: "PROVIDER1", Reason: "provider PROVIDER1 never got the order", Status: model.OrderReviewStatusOpen}
+	request := schema.OrderReviewResolveRequest{Status: model.PurchaseHistoryStatusFailed, Note: "provider confirmed the top-up was rejected"}
+	testCases := []struct {
+		Name          string
+		Review        *model.OrderReview
+		StoredStatus  model.PurchaseHistoryStatus
+		ExpectSettled bool
+		ExpectClosed  bool
+		ExpectedError string
+	}{
+		{
+			Name:          "settles the order and closes the review",
+			Review:        openReview,
+			StoredStatus:  model.PurchaseHistoryStatusConfirm,
+			ExpectSettled: true,
+			ExpectClosed:  true,
+		},
+		{
+			Name:         "order settled with the same result since it was queued",
+			Review:       openReview,
+			StoredStatus: model.PurchaseHistoryStatusFailed,
+			ExpectClosed: true,
+		},
+		{
+			Name:          "order settled with another result since it was queued",
+			Review:        &model.OrderReview{ID: 5, OrderID: 1003, Status: model.OrderReviewStatusOpen},
+			StoredStatus:  model.PurchaseHistoryStatusExpired,
+			ExpectedError: "invalid order status transition from expired to failed",
+		},
+		{
+			Name:          "review already resolved",
+			Review:        &model.OrderReview{ID: 5, OrderID: 1003, Status: model.OrderReviewStatusResolved},
+			ExpectedError: "order review 5 is already resolved",
+		},
+		{
+			Name:          "review not found",
+			ExpectedError: "order review not found",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			reviewRepo := new(mockRepo.OrderReviewRepositoryMock)
+			if tc.Review != nil {
+				review := *tc.Review
+				reviewRepo.On("GetOrderReviewByID", mock.Anything, uint(5)).Return(&review, nil)
+			} else {
+				reviewRepo.On("GetOrderReviewByID", mock.Anything, uint(5)).Return(nil, gorm.ErrRecordNotFound)
+			}
+			if tc.ExpectClosed {
+				reviewRepo.On("ResolveOrderReview", mock.Anything, uint(5), model.PurchaseHistoryStatusFailed, request.Note, mock.AnythingOfType("time.Time")).Return(nil)
+			}
+
+			storedOrder := util.CreateCachedOrderResponse(1003, 1, 10000, "0981234567", 0, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+			storedOrder.Status = tc.StoredStatus
+			redis := new(mockGrpc.RedisMock)
+			redis.On("Get", mock.Anything, "order_req_id1003:failed").Return("", errors.New("not found")).Maybe()
+			redis.On("TryAcquireLock", mock.Anything, "1003", mock.AnythingOfType("time.Duration")).Return(util.NewTestLock("1003"), nil).Maybe()
+			redis.On("ReleaseLock", mock.Anything, util.LockOf("1003")).Return(nil).Maybe()
+			redis.On("Set", mock.Anything, "order_req_id1003:failed", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil).Maybe()
+
+			orderRepo := new(mockRepo.OrderRepositoryMock)
+			orderRepo.On("GetOrderByOrderID", mock.Anything, uint(1003)).Return(util.StoredOrder(storedOrder), nil).Maybe()
+			purchaseRepo := new(mockRepo.PurchaseHistoryRepositoryMock)
+			eventRepo := new(mockRepo.OrderStatusEventRepositoryMock)
+			outboxRepo := new(mockRepo.OutboxRepositoryMock)
+			if tc.ExpectSettled {
+				redis.On("Set", mock.Anything, "order_id1003", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
+				redis.On("Publish", mock.Anything, "order_status:1003", mock.AnythingOfType("[]uint8")).Return(nil)
+				orderRepo.On("UpdateOrderStatusByOrderID", mock.Anything, uint(1003), model.PurchaseHistoryStatusFailed, int64(1)).Return(nil)
+				purchaseRepo.On("UpdatePurchaseHistoryStatusByOrderID", mock.Anything, uint(1003), model.PurchaseHistoryStatusFailed).Return(nil)
+				eventRepo.On("CreateOrderStatusEvent", mock.Anything, mock.MatchedBy(func(event *model.OrderStatusEvent) bool {
+					return event.Source == model.OrderStatusEventSourceManualReview && event.Status == model.PurchaseHistoryStatusFailed && event.Reason == request.Note
+				})).Return(nil)
+				outboxRepo.On("CreateOutboxMessage", mock.Anything, mock.MatchedBy(func(message *model.OutboxMessage) bool {
+					return message.AggregateID == 1003 && message.EventType == model.OutboxEventOrderFailed
+				})).Return(nil)
+			}
+			txManager := new(mockRepo.TransactionManagerMock)
+			util.SetupTransactionMocks(txManager)
+
+			grpcClients := &grpcClient.GRPCServiceClient{
+				ProviderGRPCClients: make(map[string]grpcClient.ProviderGRPCClient),
+			}
+			providerRepo := new(mockRepo.ProviderRepositoryMock)
+			providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+			orderService := service.NewOrderService(new(mockRepo.SkuRepositoryMock), purchaseRepo, orderRepo, eventRepo, outboxRepo, new(mockRepo.ProviderAttemptRepositoryMock), txManager, redis, grpcClients, providerRepo, reviewRepo, new(mockRepo.OrderBatchRepositoryMock), service.NewWalletService(new(mockRepo.LedgerRepositoryMock)), service.NewPromotionService(new(mockRepo.PromotionRepositoryMock)), dispatchTestConfig, paymentTestConfig)
+			orderService.ReloadProviders(context.Background())
+			got, err := orderService.ResolveOrderReview(context.Background(), 5, request)
+
+			if tc.ExpectedError != "" {
+				assert.EqualError(t, err, tc.ExpectedError)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, model.OrderReviewStatusResolved, got.Status)
+				assert.Equal(t, model.PurchaseHistoryStatusFailed, *got.Resolution)
+				assert.Equal(t, request.Note, got.Note)
+				assert.NotNil(t, got.ResolvedAt)
+			}
+			reviewRepo.AssertExpectations(t)
+			orderRepo.AssertExpectations(t)
+			purchaseRepo.AssertExpectations(t)
+			eventRepo.AssertExpectations(t)
+			outboxRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOrderService_ReverseOrder(t *testing.T) {
+	request := schema.OrderReversalRequest{Reason: "carrier took the top-up back"}
+	testCases := []struct {
+		Name           string
+		StoredStatus   model.PurchaseHistoryStatus
+		ExpectReversed bool
+		ExpectedError  string
+	}{
+		{
+			Name:           "fails the successful order, refunds it and takes its cashback back",
+			StoredStatus:   model.PurchaseHistoryStatusSuccess,
+			ExpectReversed: true,
+		},
+		{
+			Name:          "only a successful order can be reversed",
+			StoredStatus:  model.PurchaseHistoryStatusConfirm,
+			ExpectedError: "invalid order status transition from confirm to failed",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			storedOrder := util.CreateCachedOrderResponse(1001, 1, 10000, "0981234567", 500, 1, "VTL", "Viettel", model.CashBackTypePercentage, 5)
+			storedOrder.Status = tc.StoredStatus
+			storedOrder.WalletAmount = 2000
+			redis := new(mockGrpc.RedisMock)
+			redis.On("TryAcquireLock", mock.Anything, "1001", mock.AnythingOfType("time.Duration")).Return(util.NewTestLock("1001"), nil)
+			redis.On("ReleaseLock", mock.Anything, util.LockOf("1001")).Return(nil)
+
+			orderRepo := new(mockRepo.OrderRepositoryMock)
+			util.SetupStoredOrderMocks(orderRepo, storedOrder)
+			purchaseRepo := new(mockRepo.PurchaseHistoryRepositoryMock)
+			eventRepo := new(mockRepo.OrderStatusEventRepositoryMock)
+			outboxRepo := new(mockRepo.OutboxRepositoryMock)
+			ledgerRepo := new(mockRepo.LedgerRepositoryMock)
+			if tc.ExpectReversed {
+				redis.On("Set", mock.Anything, "order_id1001", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
+				redis.On("Publish", mock.Anything, "order_status:1001", mock.AnythingOfType("[]uint8")).Return(nil)
+				orderRepo.On("UpdateOrderStatusByOrderID", mock.Anything, uint(1001), model.PurchaseHistoryStatusFailed, int64(1)).Return(nil)
+				purchaseRepo.On("UpdatePurchaseHistoryStatusByOrderID", mock.Anything, uint(1001), model.PurchaseHistoryStatusFailed).Return(nil)
+				eventRepo.On("CreateOrderStatusEvent", mock.Anything, mock.MatchedBy(func(event *model.OrderStatusEvent) bool {
+					return event.Source == model.OrderStatusEventSourceAdmin && event.Status == model.PurchaseHistoryStatusFailed && event.Reason == request.Reason
+				})).Return(nil)
+				outboxRepo.On("CreateOutboxMessage", mock.Anything, mock.MatchedBy(func(message *model.OutboxMessage) bool {
+					return message.AggregateID == 1001 && message.EventType == model.OutboxEventOrderFailed
+				})).Return(nil)
+				util.SetupDefaultWebhookEventMocks(outboxRepo)
+				util.SetupDefaultLedgerAccountMocks(ledgerRepo)
+				ledgerRepo.On("CreateLedgerTransaction", mock.Anything, util.LedgerTransactionOf(model.LedgerTransactionTypeCashBackReversal, 1001, -500)).Return(nil).Once()
+				ledgerRepo.On("CreateLedgerTransaction", mock.Anything, util.LedgerTransactionOf(model.LedgerTransactionTypeOrderRefund, 1001, 2000)).Return(nil).Once()
+			}
+			txManager := new(mockRepo.TransactionManagerMock)
+			util.SetupTransactionMocks(txManager)
+
+			grpcClients := &grpcClient.GRPCServiceClient{
+				ProviderGRPCClients: make(map[string]grpcClient.ProviderGRPCClient),
+			}
+			providerRepo := new(mockRepo.ProviderRepositoryMock)
+			providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(util.SingleProvider("VTL", "Viettel"), nil)
+			orderService := service.NewOrderService(new(mockRepo.SkuRepositoryMock), purchaseRepo, orderRepo, eventRepo, outboxRepo, new(mockRepo.ProviderAttemptRepositoryMock), txManager, redis, grpcClients, providerRepo, new(mockRepo.OrderReviewRepositoryMock), new(mockRepo.OrderBatchRepositoryMock), service.NewWalletService(ledgerRepo), service.NewPromotionService(new(mockRepo.PromotionRepositoryMock)), dispatchTestConfig, paymentTestConfig)
+			orderService.ReloadProviders(context.Background())
+			err := orderService.ReverseOrder(context.Background(), 1001, request)
+
+			if tc.ExpectedError != "" {
+				assert.EqualError(t, err, tc.ExpectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			redis.AssertExpectations(t)
+			orderRepo.AssertExpectations(t)
+			purchaseRepo.AssertExpectations(t)
+			eventRepo.AssertExpectations(t)
+			outboxRepo.AssertExpectations(t)
+			ledgerRepo.AssertExpectations(t)
+		})
+	}
+}
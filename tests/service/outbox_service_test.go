@@ -0,0 +1,236 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"top-up-api/config"
+	"top-up-api/internal/model"
+	"top-up-api/internal/service"
+	mockGrpc "top-up-api/tests/mock"
+	mockRepo "top-up-api/tests/repository/mock"
+	"top-up-api/tests/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+var outboxTestConfig = config.Outbox{
+	BatchSize:   10,
+	MaxAttempts: 3,
+	BaseBackoff: time.Second,
+	MaxBackoff:  time.Minute,
+	HTTPTimeout: time.Second,
+	Lease:       time.Minute,
+}
+
+func newHTTPOutboxMessage(id uint, url string, attempts int) model.OutboxMessage {
+	return model.OutboxMessage{
+		Model:       gorm.Model{ID: id},
+		AggregateID: 1001,
+		EventType:   model.OutboxEventOrderFailed,
+		Channel:     model.OutboxChannelHTTP,
+		Destination: url,
+		Method:      http.MethodPatch,
+		Payload:     `{"order_id":1001,"status":"failed"}`,
+		Status:      model.OutboxStatusPending,
+		Attempts:    attempts,
+	}
+}
+
+func TestOutboxService_DispatchPending(t *testing.T) {
+	var received []string
+	okServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r.Method+" "+string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer okServer.Close()
+	failingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failingServer.Close()
+
+	testCases := []struct {
+		Name              string
+		SetupMocks        func(*mockRepo.OutboxRepositoryMock, *mockGrpc.KafkaProducerMock)
+		SetupWebhookRepo  func(*mockRepo.WebhookRepositoryMock)
+		ExpectedDelivered int
+		ExpectedError     string
+	}{
+		{
+			Name: "http message is delivered",
+			SetupMocks: func(outboxRepo *mockRepo.OutboxRepositoryMock, producer *mockGrpc.KafkaProducerMock) {
+				outboxRepo.On("ClaimDueOutboxMessages", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Duration"), 10).
+					Return([]model.OutboxMessage{newHTTPOutboxMessage(1, okServer.URL, 0)}, nil)
+				outboxRepo.On("MarkOutboxMessageDelivered", mock.Anything, uint(1), 1, mock.AnythingOfType("time.Time")).Return(nil)
+			},
+			ExpectedDelivered: 1,
+		},
+		{
+			Name: "kafka message is produced to its topic",
+			SetupMocks: func(outboxRepo *mockRepo.OutboxRepositoryMock, producer *mockGrpc.KafkaProducerMock) {
+				message := model.OutboxMessage{
+					Model:       gorm.Model{ID: 2},
+					Channel:     model.OutboxChannelKafka,
+					Destination: "order-events",
+					MessageKey:  "1001",
+					Payload:     `{"order_id":1001}`,
+				}
+				outboxRepo.On("ClaimDueOutboxMessages", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Duration"), 10).
+					Return([]model.OutboxMessage{message}, nil)
+				producer.On("Produce", mock.Anything, "order-events", "1001", `{"order_id":1001}`).Return(nil)
+				outboxRepo.On("MarkOutboxMessageDelivered", mock.Anything, uint(2), 1, mock.AnythingOfType("time.Time")).Return(nil)
+			},
+			ExpectedDelivered: 1,
+		},
+		{
+			Name: "failed delivery is retried with backoff",
+			SetupMocks: func(outboxRepo *mockRepo.OutboxRepositoryMock, producer *mockGrpc.KafkaProducerMock) {
+				outboxRepo.On("ClaimDueOutboxMessages", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Duration"), 10).
+					Return([]model.OutboxMessage{newHTTPOutboxMessage(3, failingServer.URL, 1)}, nil)
+				outboxRepo.On("MarkOutboxMessageRetry", mock.Anything, uint(3), 2,
+					mock.MatchedBy(func(next time.Time) bool {
+						delay := time.Until(next)
+						return delay > time.Second && delay <= 2*time.Second
+					}),
+					"unexpected response status: 503").Return(nil)
+			},
+			ExpectedDelivered: 0,
+		},
+		{
+			Name: "message is dead after the last attempt",
+			SetupMocks: func(outboxRepo *mockRepo.OutboxRepositoryMock, producer *mockGrpc.KafkaProducerMock) {
+				outboxRepo.On("ClaimDueOutboxMessages", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Duration"), 10).
+					Return([]model.OutboxMessage{newHTTPOutboxMessage(4, failingServer.URL, 2)}, nil)
+				outboxRepo.On("MarkOutboxMessageDead", mock.Anything, uint(4), 3, "unexpected response status: 503").Return(nil)
+			},
+			ExpectedDelivered: 0,
+		},
+		{
+			Name: "kafka producer error is retried",
+			SetupMocks: func(outboxRepo *mockRepo.OutboxRepositoryMock, producer *mockGrpc.KafkaProducerMock) {
+				message := model.OutboxMessage{
+					Model:       gorm.Model{ID: 5},
+					Channel:     model.OutboxChannelKafka,
+					Destination: "order-events",
+					Payload:     `{}`,
+				}
+				outboxRepo.On("ClaimDueOutboxMessages", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Duration"), 10).
+					Return([]model.OutboxMessage{message}, nil)
+				producer.On("Produce", mock.Anything, "order-events", "", `{}`).Return(errors.New("broker down"))
+				outboxRepo.On("MarkOutboxMessageRetry", mock.Anything, uint(5), 1, mock.AnythingOfType("time.Time"), "broker down").Return(nil)
+			},
+			ExpectedDelivered: 0,
+		},
+		{
+			Name: "webhook message is fanned out to the subscriptions of its event and user",
+			SetupMocks: func(outboxRepo *mockRepo.OutboxRepositoryMock, producer *mockGrpc.KafkaProducerMock) {
+				message := model.OutboxMessage{
+					Model:       gorm.Model{ID: 6},
+					AggregateID: 1001,
+					EventType:   model.WebhookEventOrderSucceeded,
+					Channel:     model.OutboxChannelWebhook,
+					Destination: model.WebhookEventOrderSucceeded,
+					Payload:     `{"event":"order.succeeded","order_id":1001,"user_id":7}`,
+				}
+				outboxRepo.On("ClaimDueOutboxMessages", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Duration"), 10).
+					Return([]model.OutboxMessage{message}, nil)
+				outboxRepo.On("MarkOutboxMessageDelivered", mock.Anything, uint(6), 1, mock.AnythingOfType("time.Time")).Return(nil)
+			},
+			SetupWebhookRepo: func(webhookRepo *mockRepo.WebhookRepositoryMock) {
+				webhookRepo.On("GetActiveWebhookSubscriptionsByEvent", mock.Anything, uint(7), model.WebhookEventOrderSucceeded).
+					Return([]model.WebhookSubscription{{Model: gorm.Model{ID: 1}}, {Model: gorm.Model{ID: 2}}}, nil)
+				webhookRepo.On("CreateWebhookDeliveries", mock.Anything, mock.MatchedBy(func(deliveries []model.WebhookDelivery) bool {
+					return len(deliveries) == 2 &&
+						deliveries[0].SubscriptionID == 1 && deliveries[1].SubscriptionID == 2 &&
+						deliveries[0].AggregateID == 1001 &&
+						deliveries[0].Status == model.WebhookDeliveryStatusPending &&
+						deliveries[1].Payload == `{"event":"order.succeeded","order_id":1001,"user_id":7}`
+				})).Return(nil)
+			},
+			ExpectedDelivered: 1,
+		},
+		{
+			Name: "webhook message without a user isn't fanned out to anyone",
+			SetupMocks: func(outboxRepo *mockRepo.OutboxRepositoryMock, producer *mockGrpc.KafkaProducerMock) {
+				message := model.OutboxMessage{
+					Model:       gorm.Model{ID: 7},
+					AggregateID: 1001,
+					EventType:   model.WebhookEventOrderSucceeded,
+					Channel:     model.OutboxChannelWebhook,
+					Destination: model.WebhookEventOrderSucceeded,
+					Payload:     `{"event":"order.succeeded","order_id":1001}`,
+				}
+				outboxRepo.On("ClaimDueOutboxMessages", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Duration"), 10).
+					Return([]model.OutboxMessage{message}, nil)
+				outboxRepo.On("MarkOutboxMessageRetry", mock.Anything, uint(7), 1, mock.AnythingOfType("time.Time"), "webhook event has no user").Return(nil)
+			},
+			ExpectedDelivered: 0,
+		},
+		{
+			Name: "result that can't be recorded doesn't hold the rest of the batch back",
+			SetupMocks: func(outboxRepo *mockRepo.OutboxRepositoryMock, producer *mockGrpc.KafkaProducerMock) {
+				message := model.OutboxMessage{
+					Model:       gorm.Model{ID: 9},
+					Channel:     model.OutboxChannelKafka,
+					Destination: "order-events",
+					Payload:     `{}`,
+				}
+				outboxRepo.On("ClaimDueOutboxMessages", mock.Anything, mock.AnythingOfType("time.Time"), time.Minute, 10).
+					Return([]model.OutboxMessage{newHTTPOutboxMessage(8, failingServer.URL, 0), message}, nil)
+				outboxRepo.On("MarkOutboxMessageRetry", mock.Anything, uint(8), 1, mock.AnythingOfType("time.Time"), "unexpected response status: 503").
+					Return(errors.New("connection reset"))
+				producer.On("Produce", mock.Anything, "order-events", "", `{}`).Return(nil)
+				outboxRepo.On("MarkOutboxMessageDelivered", mock.Anything, uint(9), 1, mock.AnythingOfType("time.Time")).Return(nil)
+			},
+			ExpectedDelivered: 1,
+			ExpectedError:     "outbox message 8: connection reset",
+		},
+		{
+			Name: "repository error",
+			SetupMocks: func(outboxRepo *mockRepo.OutboxRepositoryMock, producer *mockGrpc.KafkaProducerMock) {
+				outboxRepo.On("ClaimDueOutboxMessages", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Duration"), 10).
+					Return(nil, errors.New("db error"))
+			},
+			ExpectedError: "db error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			outboxRepo := new(mockRepo.OutboxRepositoryMock)
+			producer := new(mockGrpc.KafkaProducerMock)
+			txManager := new(mockRepo.TransactionManagerMock)
+			util.SetupTransactionMocks(txManager)
+			tc.SetupMocks(outboxRepo, producer)
+			webhookRepo := new(mockRepo.WebhookRepositoryMock)
+			if tc.SetupWebhookRepo != nil {
+				tc.SetupWebhookRepo(webhookRepo)
+			}
+			webhookService := service.NewWebhookService(webhookRepo, config.Webhook{})
+
+			outboxService := service.NewOutboxService(outboxRepo, txManager, producer, webhookService, outboxTestConfig)
+			delivered, err := outboxService.DispatchPending(ctx)
+
+			if tc.ExpectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.ExpectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.ExpectedDelivered, delivered)
+
+			outboxRepo.AssertExpectations(t)
+			producer.AssertExpectations(t)
+			webhookRepo.AssertExpectations(t)
+		})
+	}
+
+	assert.Equal(t, []string{`PATCH {"order_id":1001,"status":"failed"}`}, received)
+}
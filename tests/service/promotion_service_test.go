@@ -0,0 +1,349 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"top-up-api/internal/model"
+	"top-up-api/internal/repository"
+	"top-up-api/internal/schema"
+	"top-up-api/internal/service"
+	"top-up-api/pkg/errs"
+	mockRepo "top-up-api/tests/repository/mock"
+	"top-up-api/tests/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func testPromotion(id uint, code string, cashBackType model.CashBackType, value int) model.Promotion {
+	return model.Promotion{
+		Model:         gorm.Model{ID: id},
+		Code:          code,
+		Type:          cashBackType,
+		Value:         value,
+		StartsAt:      time.Now().Add(-time.Hour),
+		SupplierCodes: []string{},
+		SkuIDs:        []uint{},
+		Active:        true,
+	}
+}
+
+func TestPromotionService_Evaluate(t *testing.T) {
+	// 10000 with a 5% sku cash back of 500
+	sku := util.CreateMockSku(1, "VTL", 10000, model.CashBackTypePercentage, 5, "Viettel")
+
+	stackableCapped := testPromotion(1, "WEEKEND", model.CashBackTypePercentage, 10)
+	stackableCapped.Stackable = true
+	stackableCapped.MaxCashBack = 300
+	stackableFixed := testPromotion(2, "NEWUSER", model.CashBackTypeFixed, 200)
+	stackableFixed.Stackable = true
+	exclusiveBig := testPromotion(3, "FLASH", model.CashBackTypeFixed, 2000)
+	exclusiveSmall := testPromotion(4, "SMALL", model.CashBackTypeFixed, 100)
+	otherSupplier := testPromotion(5, "MOBI", model.CashBackTypeFixed, 5000)
+	otherSupplier.SupplierCodes = []string{"MBF"}
+	otherSku := testPromotion(6, "SKU9", model.CashBackTypeFixed, 5000)
+	otherSku.SkuIDs = []uint{9}
+	thisSku := testPromotion(7, "SKU1", model.CashBackTypeFixed, 700)
+	thisSku.SupplierCodes = []string{"VTL"}
+	thisSku.SkuIDs = []uint{1}
+	thisSku.Stackable = true
+	oncePerUser := testPromotion(8, "ONCE", model.CashBackTypeFixed, 3000)
+	oncePerUser.PerUserLimit = 1
+	voucherSmall := testPromotion(9, "SMALLVC", model.CashBackTypeFixed, 100)
+	voucherSmall.Voucher = true
+	voucherStackable := testPromotion(10, "STACKVC", model.CashBackTypeFixed, 400)
+	voucherStackable.Voucher = true
+	voucherStackable.Stackable = true
+	aboveTotal := testPromotion(11, "HUGE", model.CashBackTypeFixed, 20000)
+
+	tests := []struct {
+		name          string
+		voucherCode   string
+		promotions    []model.Promotion
+		setupMocks    func(*mockRepo.PromotionRepositoryMock)
+		expected      *schema.PromotionEvaluation
+		expectedError error
+	}{
+		{
+			name:       "no promotion running",
+			promotions: []model.Promotion{},
+			expected:   &schema.PromotionEvaluation{CashBackValue: 500},
+		},
+		{
+			name:       "stackable promotions add up with the sku cash back",
+			promotions: []model.Promotion{stackableCapped, stackableFixed},
+			expected: &schema.PromotionEvaluation{CashBackValue: 1000, Promotions: []schema.AppliedPromotion{
+				{PromotionID: 1, Code: "WEEKEND", CashBackValue: 300},
+				{PromotionID: 2, Code: "NEWUSER", CashBackValue: 200},
+			}},
+		},
+		{
+			name:       "better exclusive promotion replaces the stack",
+			promotions: []model.Promotion{stackableCapped, stackableFixed, exclusiveBig},
+			expected: &schema.PromotionEvaluation{CashBackValue: 2000, Promotions: []schema.AppliedPromotion{
+				{PromotionID: 3, Code: "FLASH", CashBackValue: 2000},
+			}},
+		},
+		{
+			name:       "weaker exclusive promotion is left out",
+			promotions: []model.Promotion{stackableFixed, exclusiveSmall},
+			expected: &schema.PromotionEvaluation{CashBackValue: 700, Promotions: []schema.AppliedPromotion{
+				{PromotionID: 2, Code: "NEWUSER", CashBackValue: 200},
+			}},
+		},
+		{
+			name:       "promotions targeting other suppliers or skus are left out",
+			promotions: []model.Promotion{otherSupplier, otherSku, thisSku},
+			expected: &schema.PromotionEvaluation{CashBackValue: 1200, Promotions: []schema.AppliedPromotion{
+				{PromotionID: 7, Code: "SKU1", CashBackValue: 700},
+			}},
+		},
+		{
+			name:       "promotion the user used up is left out",
+			promotions: []model.Promotion{oncePerUser},
+			setupMocks: func(r *mockRepo.PromotionRepositoryMock) {
+				r.On("CountUserRedemptions", mock.Anything, uint(1), []uint{8}).Return(map[uint]int{8: 1}, nil)
+			},
+			expected: &schema.PromotionEvaluation{CashBackValue: 500},
+		},
+		{
+			name:       "promotion the user has redemptions left of",
+			promotions: []model.Promotion{oncePerUser},
+			setupMocks: func(r *mockRepo.PromotionRepositoryMock) {
+				r.On("CountUserRedemptions", mock.Anything, uint(1), []uint{8}).Return(map[uint]int{}, nil)
+			},
+			expected: &schema.PromotionEvaluation{CashBackValue: 3000, Promotions: []schema.AppliedPromotion{
+				{PromotionID: 8, Code: "ONCE", CashBackValue: 3000},
+			}},
+		},
+		{
+			name:        "exclusive voucher is applied even when the stack is worth more",
+			voucherCode: "smallvc",
+			promotions:  []model.Promotion{stackableCapped, exclusiveBig, voucherSmall},
+			expected: &schema.PromotionEvaluation{CashBackValue: 100, Promotions: []schema.AppliedPromotion{
+				{PromotionID: 9, Code: "SMALLVC", CashBackValue: 100},
+			}},
+		},
+		{
+			name:        "stackable voucher stacks and keeps exclusive promotions out",
+			voucherCode: "STACKVC",
+			promotions:  []model.Promotion{stackableFixed, exclusiveBig, voucherStackable},
+			expected: &schema.PromotionEvaluation{CashBackValue: 1100, Promotions: []schema.AppliedPromotion{
+				{PromotionID: 2, Code: "NEWUSER", CashBackValue: 200},
+				{PromotionID: 10, Code: "STACKVC", CashBackValue: 400},
+			}},
+		},
+		{
+			name:          "voucher not running",
+			voucherCode:   "EXPIRED",
+			promotions:    []model.Promotion{stackableFixed},
+			expectedError: &errs.BadRequestError{Message: "voucher code EXPIRED is not valid for this order"},
+		},
+		{
+			name:          "voucher for another supplier",
+			voucherCode:   "MOBI",
+			promotions:    []model.Promotion{otherSupplier},
+			expectedError: &errs.BadRequestError{Message: "voucher code MOBI is not valid for this order"},
+		},
+		{
+			name:       "cashback never exceeds the sku price",
+			promotions: []model.Promotion{aboveTotal},
+			expected: &schema.PromotionEvaluation{CashBackValue: 10000, Promotions: []schema.AppliedPromotion{
+				{PromotionID: 11, Code: "HUGE", CashBackValue: 20000},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := new(mockRepo.PromotionRepositoryMock)
+			r.On("GetAvailablePromotions", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("string")).Return(tt.promotions, nil)
+			if tt.setupMocks != nil {
+				tt.setupMocks(r)
+			}
+
+			evaluation, err := service.NewPromotionService(r).Evaluate(ctx, 1, sku, tt.voucherCode)
+
+			if tt.expectedError != nil {
+				assert.Equal(t, tt.expectedError, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expected, evaluation)
+			r.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPromotionService_Evaluate_VoucherCodeIsNormalized(t *testing.T) {
+	r := new(mockRepo.PromotionRepositoryMock)
+	r.On("GetAvailablePromotions", mock.Anything, mock.AnythingOfType("time.Time"), "SUMMER10").Return([]model.Promotion{}, nil)
+
+	_, err := service.NewPromotionService(r).Evaluate(ctx, 1, util.CreateMockSku(1, "VTL", 10000, model.CashBackTypeFixed, 0, "Viettel"), "  summer10 ")
+
+	assert.Equal(t, &errs.BadRequestError{Message: "voucher code SUMMER10 is not valid for this order"}, err)
+	r.AssertExpectations(t)
+}
+
+func TestPromotionService_Redeem(t *testing.T) {
+	// Listed in evaluation order, they are redeemed by ID
+	applied := []schema.AppliedPromotion{
+		{PromotionID: 2, Code: "NEWUSER", CashBackValue: 200},
+		{PromotionID: 1, Code: "WEEKEND", CashBackValue: 300},
+	}
+
+	tests := []struct {
+		name          string
+		setupMocks    func(*mockRepo.PromotionRepositoryMock)
+		expectedError error
+	}{
+		{
+			name: "every promotion is redeemed for the order",
+			setupMocks: func(r *mockRepo.PromotionRepositoryMock) {
+				mock.InOrder(
+					r.On("RedeemPromotion", mock.Anything, &model.PromotionRedemption{PromotionID: 1, OrderID: 1001, UserID: 1, Code: "WEEKEND", CashBackValue: 300}).Return(nil).Once(),
+					r.On("RedeemPromotion", mock.Anything, &model.PromotionRedemption{PromotionID: 2, OrderID: 1001, UserID: 1, Code: "NEWUSER", CashBackValue: 200}).Return(nil).Once(),
+				)
+			},
+		},
+		{
+			name: "promotion ran out",
+			setupMocks: func(r *mockRepo.PromotionRepositoryMock) {
+				r.On("RedeemPromotion", mock.Anything, mock.Anything).Return(repository.ErrPromotionExhausted).Once()
+			},
+			expectedError: &errs.ConflictError{Message: "promotion WEEKEND is no longer available"},
+		},
+		{
+			name: "user used the promotion up meanwhile",
+			setupMocks: func(r *mockRepo.PromotionRepositoryMock) {
+				r.On("RedeemPromotion", mock.Anything, mock.Anything).Return(nil).Once()
+				r.On("RedeemPromotion", mock.Anything, mock.Anything).Return(repository.ErrPromotionUserLimitReached).Once()
+			},
+			expectedError: &errs.ConflictError{Message: "promotion NEWUSER is no longer available"},
+		},
+		{
+			name: "repository error",
+			setupMocks: func(r *mockRepo.PromotionRepositoryMock) {
+				r.On("RedeemPromotion", mock.Anything, mock.Anything).Return(errors.New("db down")).Once()
+			},
+			expectedError: errors.New("db down"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := new(mockRepo.PromotionRepositoryMock)
+			tt.setupMocks(r)
+
+			err := service.NewPromotionService(r).Redeem(ctx, 1, 1001, applied)
+
+			if tt.expectedError != nil {
+				assert.Equal(t, tt.expectedError, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			r.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPromotionService_Release(t *testing.T) {
+	r := new(mockRepo.PromotionRepositoryMock)
+	r.On("ReleasePromotionRedemptions", mock.Anything, uint(1001), mock.AnythingOfType("time.Time")).Return(nil)
+
+	assert.NoError(t, service.NewPromotionService(r).Release(ctx, 1001))
+	r.AssertExpectations(t)
+}
+
+func TestPromotionService_CreatePromotion(t *testing.T) {
+	startsAt := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	endsAt := startsAt.AddDate(0, 1, 0)
+
+	tests := []struct {
+		name          string
+		request       schema.PromotionRequest
+		setupMock     func(*mockRepo.PromotionRepositoryMock)
+		expectedError string
+	}{
+		{
+			name:    "Success",
+			request: schema.PromotionRequest{Code: " summer10 ", Name: "Summer", Type: model.CashBackTypePercentage, Value: 10, MaxCashBack: 5000, StartsAt: startsAt, EndsAt: &endsAt},
+			setupMock: func(m *mockRepo.PromotionRepositoryMock) {
+				m.On("CreatePromotion", mock.Anything, mock.MatchedBy(func(promotion *model.Promotion) bool {
+					return promotion.Code == "SUMMER10" && promotion.Active && promotion.SkuIDs != nil && promotion.SupplierCodes != nil
+				})).Return(nil)
+			},
+		},
+		{
+			name:          "Percentage above 100",
+			request:       schema.PromotionRequest{Code: "ALL", Name: "All", Type: model.CashBackTypePercentage, Value: 150, StartsAt: startsAt},
+			setupMock:     func(m *mockRepo.PromotionRepositoryMock) {},
+			expectedError: "percentage cash back must not exceed 100",
+		},
+		{
+			name:          "Ends before it starts",
+			request:       schema.PromotionRequest{Code: "BACK", Name: "Back", Type: model.CashBackTypeFixed, Value: 100, StartsAt: endsAt, EndsAt: &startsAt},
+			setupMock:     func(m *mockRepo.PromotionRepositoryMock) {},
+			expectedError: "promotion must end after it starts",
+		},
+		{
+			name:    "Duplicate code",
+			request: schema.PromotionRequest{Code: "SUMMER10", Name: "Summer", Type: model.CashBackTypeFixed, Value: 100, StartsAt: startsAt},
+			setupMock: func(m *mockRepo.PromotionRepositoryMock) {
+				m.On("CreatePromotion", mock.Anything, mock.AnythingOfType("*model.Promotion")).Return(gorm.ErrDuplicatedKey)
+			},
+			expectedError: "promotion already exists",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := new(mockRepo.PromotionRepositoryMock)
+			tt.setupMock(m)
+
+			response, err := service.NewPromotionService(m).CreatePromotion(ctx, tt.request)
+
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				assert.Nil(t, response)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "SUMMER10", response.Code)
+			}
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPromotionService_UpdatePromotion(t *testing.T) {
+	request := schema.PromotionRequest{Code: "SUMMER10", Name: "Summer", Type: model.CashBackTypeFixed, Value: 100, StartsAt: time.Now()}
+
+	t.Run("Success keeps the redemptions", func(t *testing.T) {
+		existing := testPromotion(3, "SUMMER10", model.CashBackTypeFixed, 50)
+		existing.RedemptionCount = 42
+		m := new(mockRepo.PromotionRepositoryMock)
+		m.On("GetPromotionByID", mock.Anything, uint(3)).Return(&existing, nil)
+		m.On("UpdatePromotion", mock.Anything, mock.MatchedBy(func(promotion *model.Promotion) bool {
+			return promotion.ID == 3 && promotion.Value == 100
+		})).Return(nil)
+
+		response, err := service.NewPromotionService(m).UpdatePromotion(ctx, 3, request)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 42, response.RedemptionCount)
+		m.AssertExpectations(t)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		m := new(mockRepo.PromotionRepositoryMock)
+		m.On("GetPromotionByID", mock.Anything, uint(3)).Return(nil, gorm.ErrRecordNotFound)
+
+		_, err := service.NewPromotionService(m).UpdatePromotion(ctx, 3, request)
+
+		assert.Equal(t, &errs.NotFoundError{Message: "promotion not found"}, err)
+		m.AssertExpectations(t)
+	})
+}
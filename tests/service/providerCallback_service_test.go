@@ -0,0 +1,143 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"top-up-api/config"
+	"top-up-api/internal/model"
+	"top-up-api/internal/schema"
+	"top-up-api/internal/service"
+	"top-up-api/pkg/errs"
+	"top-up-api/pkg/signature"
+	mockGrpc "top-up-api/tests/mock"
+	mockRepo "top-up-api/tests/repository/mock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+const providerCallbackTestSecret = "pcsec_test"
+
+var providerCallbackTestBody = []byte(`{"order_id":1001,"status":"success","phone_number":"0981234567"}`)
+
+type ProviderCallbackTestCase struct {
+	Name          string
+	Credentials   schema.ProviderCallbackCredentials
+	SetupMocks    func(*mockRepo.ProviderRepositoryMock, *mockGrpc.RedisMock)
+	ExpectedError string
+}
+
+func signProviderCallback(secret, nonce string, timestamp time.Time) schema.ProviderCallbackCredentials {
+	signed := append([]byte(nonce+"."), providerCallbackTestBody...)
+	return schema.ProviderCallbackCredentials{
+		ProviderCode: "PROVIDER1",
+		Nonce:        nonce,
+		Signature:    signature.Header(secret, timestamp, signed),
+	}
+}
+
+func TestProviderCallbackService_Authenticate(t *testing.T) {
+	provider := &model.Provider{Code: "PROVIDER1", CallbackSecret: providerCallbackTestSecret}
+
+	testCases := []ProviderCallbackTestCase{
+		{
+			Name:        "signed callback is accepted once",
+			Credentials: signProviderCallback(providerCallbackTestSecret, "nonce-1", time.Now()),
+			SetupMocks: func(providerRepo *mockRepo.ProviderRepositoryMock, redis *mockGrpc.RedisMock) {
+				providerRepo.On("GetProviderByCode", mock.Anything, "PROVIDER1").Return(provider, nil)
+				redis.On("SetNX", mock.Anything, "provider_callback_nonce:PROVIDER1:nonce-1", 1, 10*time.Minute).Return(true, nil)
+			},
+		},
+		{
+			Name:          "unsigned callback",
+			Credentials:   schema.ProviderCallbackCredentials{ProviderCode: "PROVIDER1"},
+			SetupMocks:    func(*mockRepo.ProviderRepositoryMock, *mockGrpc.RedisMock) {},
+			ExpectedError: "provider callback is not signed",
+		},
+		{
+			Name: "nonce that is too long",
+			Credentials: schema.ProviderCallbackCredentials{
+				ProviderCode: "PROVIDER1",
+				Nonce:        strings.Repeat("n", 129),
+				Signature:    "t=1,v1=00",
+			},
+			SetupMocks:    func(*mockRepo.ProviderRepositoryMock, *mockGrpc.RedisMock) {},
+			ExpectedError: "provider callback nonce is too long",
+		},
+		{
+			Name:        "unknown provider",
+			Credentials: signProviderCallback(providerCallbackTestSecret, "nonce-2", time.Now()),
+			SetupMocks: func(providerRepo *mockRepo.ProviderRepositoryMock, redis *mockGrpc.RedisMock) {
+				providerRepo.On("GetProviderByCode", mock.Anything, "PROVIDER1").Return(nil, gorm.ErrRecordNotFound)
+			},
+			ExpectedError: "unknown provider PROVIDER1",
+		},
+		{
+			Name:        "provider without a callback secret",
+			Credentials: signProviderCallback("", "nonce-3", time.Now()),
+			SetupMocks: func(providerRepo *mockRepo.ProviderRepositoryMock, redis *mockGrpc.RedisMock) {
+				providerRepo.On("GetProviderByCode", mock.Anything, "PROVIDER1").Return(&model.Provider{Code: "PROVIDER1"}, nil)
+			},
+			ExpectedError: "provider PROVIDER1 has no callback secret",
+		},
+		{
+			Name:        "callback signed with another secret",
+			Credentials: signProviderCallback("pcsec_other", "nonce-4", time.Now()),
+			SetupMocks: func(providerRepo *mockRepo.ProviderRepositoryMock, redis *mockGrpc.RedisMock) {
+				providerRepo.On("GetProviderByCode", mock.Anything, "PROVIDER1").Return(provider, nil)
+			},
+			ExpectedError: signature.ErrMismatch.Error(),
+		},
+		{
+			Name:        "callback signed outside the tolerance",
+			Credentials: signProviderCallback(providerCallbackTestSecret, "nonce-5", time.Now().Add(-time.Hour)),
+			SetupMocks: func(providerRepo *mockRepo.ProviderRepositoryMock, redis *mockGrpc.RedisMock) {
+				providerRepo.On("GetProviderByCode", mock.Anything, "PROVIDER1").Return(provider, nil)
+			},
+			ExpectedError: signature.ErrExpired.Error(),
+		},
+		{
+			Name:        "replayed callback",
+			Credentials: signProviderCallback(providerCallbackTestSecret, "nonce-6", time.Now()),
+			SetupMocks: func(providerRepo *mockRepo.ProviderRepositoryMock, redis *mockGrpc.RedisMock) {
+				providerRepo.On("GetProviderByCode", mock.Anything, "PROVIDER1").Return(provider, nil)
+				redis.On("SetNX", mock.Anything, "provider_callback_nonce:PROVIDER1:nonce-6", 1, 10*time.Minute).Return(false, nil)
+			},
+			ExpectedError: "provider callback was already received",
+		},
+		{
+			Name:        "redis error",
+			Credentials: signProviderCallback(providerCallbackTestSecret, "nonce-7", time.Now()),
+			SetupMocks: func(providerRepo *mockRepo.ProviderRepositoryMock, redis *mockGrpc.RedisMock) {
+				providerRepo.On("GetProviderByCode", mock.Anything, "PROVIDER1").Return(provider, nil)
+				redis.On("SetNX", mock.Anything, "provider_callback_nonce:PROVIDER1:nonce-7", 1, 10*time.Minute).Return(false, errors.New("redis down"))
+			},
+			ExpectedError: "redis down",
+		},
+	}
+
+	runTableDrivenTests(t, testCases, func(t *testing.T, tc ProviderCallbackTestCase) {
+		providerRepo := new(mockRepo.ProviderRepositoryMock)
+		redis := new(mockGrpc.RedisMock)
+		tc.SetupMocks(providerRepo, redis)
+
+		callbacks := service.NewProviderCallbackService(providerRepo, redis, config.ProviderCallback{SignatureTolerance: 5 * time.Minute})
+		err := callbacks.Authenticate(ctx, tc.Credentials, providerCallbackTestBody)
+
+		if tc.ExpectedError != "" {
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tc.ExpectedError)
+			var unauthorizedErr *errs.UnauthorizedError
+			assert.Equal(t, tc.ExpectedError != "redis down", errors.As(err, &unauthorizedErr))
+		} else {
+			assert.NoError(t, err)
+		}
+
+		providerRepo.AssertExpectations(t)
+		redis.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"top-up-api/internal/model"
+	"top-up-api/internal/schema"
+	"top-up-api/internal/service"
+	"top-up-api/pkg/errs"
+	mockRepo "top-up-api/tests/repository/mock"
+	"top-up-api/tests/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+type ProviderServiceTestCase struct {
+	Name          string
+	Request       schema.ProviderRequest
+	SetupMocks    func(providerRepo *mockRepo.ProviderRepositoryMock, supplierRepo *mockRepo.SupplierRepositoryMock)
+	ExpectedError string
+	Assert        func(t *testing.T, result *schema.ProviderSecretResponse)
+}
+
+func TestProviderService_CreateProvider(t *testing.T) {
+	ctx := context.Background()
+	suppliers := []model.Supplier{util.CreateMockSupplier("MBF", "Mobifone"), util.CreateMockSupplier("VTL", "Viettel")}
+	suppliers[0].ID, suppliers[1].ID = 1, 2
+
+	testCases := []ProviderServiceTestCase{
+		{
+			Name: "creates the provider with its suppliers and their weights",
+			Request: schema.ProviderRequest{Code: "HTTP02", Source: "http://provider.example.com", Type: "http", Discount: 3.5, Suppliers: []schema.ProviderSupplierRequest{
+				{SupplierCode: "VTL", Weight: 80}, {SupplierCode: "MBF", Weight: 10},
+			}},
+			SetupMocks: func(providerRepo *mockRepo.ProviderRepositoryMock, supplierRepo *mockRepo.SupplierRepositoryMock) {
+				supplierRepo.On("GetSuppliersByCodes", mock.Anything, []string{"MBF", "VTL"}).Return(suppliers, nil)
+				providerRepo.On("CreateProvider", mock.Anything, mock.MatchedBy(func(provider *model.Provider) bool {
+					return provider.Code == "HTTP02" && provider.Discount == 3.5 &&
+						strings.HasPrefix(provider.CallbackSecret, "pcsec_")
+				})).Run(func(args mock.Arguments) {
+					args.Get(1).(*model.Provider).ID = 5
+				}).Return(nil)
+				providerRepo.On("ReplaceProviderSuppliers", mock.Anything, uint(5), []model.ProviderSupplier{
+					{ProviderID: 5, SupplierID: 1, Weight: 10}, {ProviderID: 5, SupplierID: 2, Weight: 80},
+				}).Return(nil)
+			},
+			Assert: func(t *testing.T, result *schema.ProviderSecretResponse) {
+				assert.Equal(t, uint(5), result.ID)
+				assert.Equal(t, []schema.ProviderSupplierResponse{{SupplierCode: "MBF", Weight: 10}, {SupplierCode: "VTL", Weight: 80}}, result.Suppliers)
+				assert.True(t, strings.HasPrefix(result.CallbackSecret, "pcsec_"))
+			},
+		},
+		{
+			Name:    "provider without suppliers",
+			Request: schema.ProviderRequest{Code: "GRPC02", Source: "localhost:50054", Type: "grpc"},
+			SetupMocks: func(providerRepo *mockRepo.ProviderRepositoryMock, supplierRepo *mockRepo.SupplierRepositoryMock) {
+				providerRepo.On("CreateProvider", mock.Anything, mock.AnythingOfType("*model.Provider")).Return(nil)
+				providerRepo.On("ReplaceProviderSuppliers", mock.Anything, uint(0), []model.ProviderSupplier{}).Return(nil)
+			},
+			Assert: func(t *testing.T, result *schema.ProviderSecretResponse) {
+				assert.Empty(t, result.Suppliers)
+			},
+		},
+		{
+			Name: "unknown supplier code",
+			Request: schema.ProviderRequest{Code: "HTTP02", Source: "http://provider.example.com", Type: "http", Suppliers: []schema.ProviderSupplierRequest{
+				{SupplierCode: "VTL", Weight: 1}, {SupplierCode: "XXX", Weight: 1},
+			}},
+			SetupMocks: func(providerRepo *mockRepo.ProviderRepositoryMock, supplierRepo *mockRepo.SupplierRepositoryMock) {
+				supplierRepo.On("GetSuppliersByCodes", mock.Anything, []string{"VTL", "XXX"}).Return(suppliers[1:], nil)
+			},
+			ExpectedError: "unknown supplier codes: XXX",
+		},
+		{
+			Name: "supplier assigned twice",
+			Request: schema.ProviderRequest{Code: "HTTP02", Source: "http://provider.example.com", Type: "http", Suppliers: []schema.ProviderSupplierRequest{
+				{SupplierCode: "VTL", Weight: 1}, {SupplierCode: "VTL", Weight: 5},
+			}},
+			SetupMocks:    func(providerRepo *mockRepo.ProviderRepositoryMock, supplierRepo *mockRepo.SupplierRepositoryMock) {},
+			ExpectedError: "duplicate supplier code: VTL",
+		},
+		{
+			Name:    "duplicate provider code",
+			Request: schema.ProviderRequest{Code: "HTTP01", Source: "http://provider.example.com", Type: "http", Suppliers: []schema.ProviderSupplierRequest{{SupplierCode: "VTL", Weight: 1}}},
+			SetupMocks: func(providerRepo *mockRepo.ProviderRepositoryMock, supplierRepo *mockRepo.SupplierRepositoryMock) {
+				supplierRepo.On("GetSuppliersByCodes", mock.Anything, []string{"VTL"}).Return(suppliers[1:], nil)
+				providerRepo.On("CreateProvider", mock.Anything, mock.AnythingOfType("*model.Provider")).Return(gorm.ErrDuplicatedKey)
+			},
+			ExpectedError: "provider already exists",
+		},
+		{
+			Name:    "association error",
+			Request: schema.ProviderRequest{Code: "HTTP02", Source: "http://provider.example.com", Type: "http", Suppliers: []schema.ProviderSupplierRequest{{SupplierCode: "VTL", Weight: 1}}},
+			SetupMocks: func(providerRepo *mockRepo.ProviderRepositoryMock, supplierRepo *mockRepo.SupplierRepositoryMock) {
+				supplierRepo.On("GetSuppliersByCodes", mock.Anything, []string{"VTL"}).Return(suppliers[1:], nil)
+				providerRepo.On("CreateProvider", mock.Anything, mock.AnythingOfType("*model.Provider")).Return(nil)
+				providerRepo.On("ReplaceProviderSuppliers", mock.Anything, uint(0), []model.ProviderSupplier{{SupplierID: 2, Weight: 1}}).Return(errors.New("db error"))
+			},
+			ExpectedError: "db error",
+		},
+	}
+
+	runTableDrivenTests(t, testCases, func(t *testing.T, tc ProviderServiceTestCase) {
+		providerRepo := new(mockRepo.ProviderRepositoryMock)
+		supplierRepo := new(mockRepo.SupplierRepositoryMock)
+		txManager := new(mockRepo.TransactionManagerMock)
+		util.SetupTransactionMocks(txManager)
+		tc.SetupMocks(providerRepo, supplierRepo)
+
+		result, err := service.NewProviderService(providerRepo, supplierRepo, txManager).CreateProvider(ctx, tc.Request)
+		if tc.ExpectedError != "" {
+			assert.EqualError(t, err, tc.ExpectedError)
+			assert.Nil(t, result)
+		} else {
+			assert.NoError(t, err)
+			tc.Assert(t, result)
+		}
+		providerRepo.AssertExpectations(t)
+		supplierRepo.AssertExpectations(t)
+	})
+}
+
+func TestProviderService_UpdateProvider(t *testing.T) {
+	ctx := context.Background()
+	request := schema.ProviderRequest{Code: "HTTP01", Source: "http://provider.example.com", Type: "http", Suppliers: []schema.ProviderSupplierRequest{{SupplierCode: "VTL", Weight: 20}}}
+
+	t.Run("replaces the suppliers and their weights and keeps the callback secret", func(t *testing.T) {
+		providerRepo := new(mockRepo.ProviderRepositoryMock)
+		supplierRepo := new(mockRepo.SupplierRepositoryMock)
+		txManager := new(mockRepo.TransactionManagerMock)
+		util.SetupTransactionMocks(txManager)
+
+		existing := util.CreateMockProvider(1, "HTTP01", "http://old.example.com", "http", 5, []model.Supplier{util.CreateMockSupplier("MBF", "Mobifone")})
+		existing.CallbackSecret = "pcsec_existing"
+		suppliers := []model.Supplier{util.CreateMockSupplier("VTL", "Viettel")}
+		suppliers[0].ID = 2
+		providerRepo.On("GetProviderByID", ctx, uint(1)).Return(&existing, nil)
+		supplierRepo.On("GetSuppliersByCodes", ctx, []string{"VTL"}).Return(suppliers, nil)
+		providerRepo.On("UpdateProvider", ctx, mock.MatchedBy(func(provider *model.Provider) bool {
+			return provider.ID == 1 && provider.Source == "http://provider.example.com" &&
+				provider.CallbackSecret == "pcsec_existing"
+		})).Return(nil)
+		providerRepo.On("ReplaceProviderSuppliers", ctx, uint(1), []model.ProviderSupplier{{ProviderID: 1, SupplierID: 2, Weight: 20}}).Return(nil)
+
+		result, err := service.NewProviderService(providerRepo, supplierRepo, txManager).UpdateProvider(ctx, 1, request)
+		assert.NoError(t, err)
+		assert.Equal(t, []schema.ProviderSupplierResponse{{SupplierCode: "VTL", Weight: 20}}, result.Suppliers)
+		providerRepo.AssertExpectations(t)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		providerRepo := new(mockRepo.ProviderRepositoryMock)
+		providerRepo.On("GetProviderByID", ctx, uint(9)).Return(nil, gorm.ErrRecordNotFound)
+
+		result, err := service.NewProviderService(providerRepo, new(mockRepo.SupplierRepositoryMock), new(mockRepo.TransactionManagerMock)).UpdateProvider(ctx, 9, request)
+		var notFoundErr *errs.NotFoundError
+		assert.ErrorAs(t, err, &notFoundErr)
+		assert.Nil(t, result)
+	})
+}
+
+func TestProviderService_DeleteProvider(t *testing.T) {
+	ctx := context.Background()
+	providerRepo := new(mockRepo.ProviderRepositoryMock)
+	providerRepo.On("DeleteProvider", ctx, uint(1)).Return(nil)
+	providerRepo.On("DeleteProvider", ctx, uint(9)).Return(gorm.ErrRecordNotFound)
+
+	svc := service.NewProviderService(providerRepo, new(mockRepo.SupplierRepositoryMock), new(mockRepo.TransactionManagerMock))
+	assert.NoError(t, svc.DeleteProvider(ctx, 1))
+	assert.EqualError(t, svc.DeleteProvider(ctx, 9), "provider not found")
+}
+
+func TestProviderService_RotateCallbackSecret(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("issues a new secret", func(t *testing.T) {
+		providerRepo := new(mockRepo.ProviderRepositoryMock)
+		existing := util.CreateMockProvider(1, "HTTP01", "http://provider.example.com", "http", 5, nil)
+		existing.CallbackSecret = "pcsec_existing"
+		providerRepo.On("GetProviderByID", ctx, uint(1)).Return(&existing, nil)
+		providerRepo.On("UpdateProviderCallbackSecret", ctx, uint(1), mock.MatchedBy(func(secret string) bool {
+			return strings.HasPrefix(secret, "pcsec_") && secret != "pcsec_existing"
+		})).Return(nil)
+
+		result, err := service.NewProviderService(providerRepo, new(mockRepo.SupplierRepositoryMock), new(mockRepo.TransactionManagerMock)).RotateCallbackSecret(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "HTTP01", result.Code)
+		assert.NotEqual(t, "pcsec_existing", result.CallbackSecret)
+		providerRepo.AssertExpectations(t)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		providerRepo := new(mockRepo.ProviderRepositoryMock)
+		providerRepo.On("GetProviderByID", ctx, uint(9)).Return(nil, gorm.ErrRecordNotFound)
+
+		result, err := service.NewProviderService(providerRepo, new(mockRepo.SupplierRepositoryMock), new(mockRepo.TransactionManagerMock)).RotateCallbackSecret(ctx, 9)
+		var notFoundErr *errs.NotFoundError
+		assert.ErrorAs(t, err, &notFoundErr)
+		assert.Nil(t, result)
+	})
+}
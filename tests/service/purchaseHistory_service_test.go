@@ -25,7 +25,7 @@ var (
 		UserID:        1,
 		SkuID:         2001,
 		TotalPrice:    10000,
-		PhoneNumber:   "081234567890",
+		PhoneNumber:   "0981234567",
 		Status:        model.PurchaseHistoryStatusSuccess,
 		CashBackValue: 100,
 		Sku:           *util.CreateMockSku(2001, "VTL", 10000, model.CashBackTypeFixed, 0, "Viettel"),
@@ -67,7 +67,7 @@ var (
 		UserID:        1,
 		SkuID:         2001,
 		TotalPrice:    10000,
-		PhoneNumber:   "081234567890",
+		PhoneNumber:   "0981234567",
 		Status:        model.PurchaseHistoryStatusSuccess,
 		CashBackValue: 100,
 	}
@@ -157,7 +157,7 @@ func TestPurchaseHistoryService_GetPurchaseHistoriesByUserIDPaginated(t *testing
 					assert.Equal(t, uint(1), actualData[0].UserID)
 					assert.Equal(t, uint(2001), actualData[0].SkuID)
 					assert.Equal(t, 10000, actualData[0].TotalPrice)
-					assert.Equal(t, "081234567890", actualData[0].PhoneNumber)
+					assert.Equal(t, "0981234567", actualData[0].PhoneNumber)
 					assert.Equal(t, "success", actualData[0].Status)
 					assert.Equal(t, 100, actualData[0].CashBackValue)
 				} else {
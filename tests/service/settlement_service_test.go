@@ -0,0 +1,290 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"top-up-api/config"
+	"top-up-api/internal/model"
+	"top-up-api/internal/repository"
+	"top-up-api/internal/schema"
+	"top-up-api/internal/service"
+	mockRepo "top-up-api/tests/repository/mock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func TestSettlementService_GetSettlementReport(t *testing.T) {
+	location, _ := time.LoadLocation("Asia/Ho_Chi_Minh")
+	rows := []model.SettlementRow{
+		{Day: "2026-10-01", ProviderCode: "PROVIDER1", SupplierCode: "VTL", SupplierName: "Viettel", Orders: 3, FaceValue: 60000, AmountCharged: 58000, CashBack: 1200},
+		{Day: "2026-10-02", ProviderCode: "PROVIDER2", SupplierCode: "MBF", SupplierName: "Mobifone", Orders: 1, FaceValue: 10000, AmountCharged: 10000, CashBack: 0},
+	}
+
+	tests := []struct {
+		name          string
+		request       schema.SettlementReportRequest
+		from, to      time.Time
+		expectedRows  int
+		expectedError string
+	}{
+		{
+			name:         "days start at midnight in the settlement timezone",
+			request:      schema.SettlementReportRequest{From: "2026-10-01", To: "2026-10-02"},
+			from:         time.Date(2026, 10, 1, 0, 0, 0, 0, location),
+			to:           time.Date(2026, 10, 3, 0, 0, 0, 0, location),
+			expectedRows: 2,
+		},
+		{
+			name:          "invalid day",
+			request:       schema.SettlementReportRequest{From: "01/10/2026", To: "2026-10-02"},
+			expectedError: `invalid from day "01/10/2026", expected YYYY-MM-DD`,
+		},
+		{
+			name:          "from after to",
+			request:       schema.SettlementReportRequest{From: "2026-10-02", To: "2026-10-01"},
+			expectedError: "from must not be after to",
+		},
+		{
+			name:          "period longer than a year",
+			request:       schema.SettlementReportRequest{From: "2025-01-01", To: "2026-01-02"},
+			expectedError: "a settlement report covers at most 366 days",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(mockRepo.SettlementRepositoryMock)
+			if tt.expectedError == "" {
+				repo.On("GetSettlementRows", ctx, mock.MatchedBy(tt.from.Equal), mock.MatchedBy(tt.to.Equal), "Asia/Ho_Chi_Minh").Return(rows, nil)
+			}
+			settlementService := service.NewSettlementService(repo, new(mockRepo.ProviderRepositoryMock), config.Settlement{Timezone: "Asia/Ho_Chi_Minh"})
+
+			report, err := settlementService.GetSettlementReport(ctx, tt.request)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				assert.Nil(t, report)
+			} else {
+				assert.NoError(t, err)
+				assert.Len(t, report, tt.expectedRows)
+				assert.Equal(t, &schema.SettlementReportRow{Day: "2026-10-01", ProviderCode: "PROVIDER1", SupplierCode: "VTL", SupplierName: "Viettel", Orders: 3, FaceValue: 60000, AmountCharged: 58000, CashBack: 1200}, report[0])
+			}
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSettlementService_ExportSettlementReport(t *testing.T) {
+	repo := new(mockRepo.SettlementRepositoryMock)
+	repo.On("GetSettlementRows", ctx, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time"), "UTC").Return([]model.SettlementRow{
+		{Day: "2026-10-01", ProviderCode: "PROVIDER1", SupplierCode: "VTL", SupplierName: "Viettel, Inc", Orders: 3, FaceValue: 60000, AmountCharged: 58000, CashBack: 1200},
+		{Day: "2026-10-01", SupplierCode: "MBF", SupplierName: "Mobifone", Orders: 1, FaceValue: 10000, AmountCharged: 10000},
+	}, nil).Once()
+	repo.On("GetSettlementRows", ctx, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time"), "UTC").Return(nil, errors.New("connection refused")).Once()
+	settlementService := service.NewSettlementService(repo, new(mockRepo.ProviderRepositoryMock), config.Settlement{})
+	request := schema.SettlementReportRequest{From: "2026-10-01", To: "2026-10-01"}
+
+	var report bytes.Buffer
+	assert.NoError(t, settlementService.ExportSettlementReport(ctx, &report, request))
+	assert.Equal(t, "day,provider_code,supplier_code,supplier_name,orders,face_value,amount_charged,cash_back\n"+
+		"2026-10-01,PROVIDER1,VTL,\"Viettel, Inc\",3,60000,58000,1200\n"+
+		"2026-10-01,,MBF,Mobifone,1,10000,10000,0\n", report.String())
+
+	report.Reset()
+	assert.EqualError(t, settlementService.ExportSettlementReport(ctx, &report, request), "connection refused")
+	assert.Empty(t, report.String())
+}
+
+func settlementHistory(orderID uint, status model.PurchaseHistoryStatus, price int) model.PurchaseHistory {
+	return model.PurchaseHistory{OrderID: orderID, Status: status, ProviderCode: "PROVIDER1", Sku: model.Sku{Price: price}}
+}
+
+func TestSettlementService_ImportProviderSettlement(t *testing.T) {
+	location, _ := time.LoadLocation("Asia/Ho_Chi_Minh")
+	settlementConfig := config.Settlement{
+		Timezone: "Asia/Ho_Chi_Minh",
+		Providers: map[string]config.SettlementLayout{
+			"provider2": {Delimiter: ";", OrderIDColumn: "merchant_ref", AmountColumn: "face_value", StatusColumn: "result", SuccessStatuses: []string{"00"}},
+		},
+	}
+	// 1001 matches, 1002 is missing on our side, 1003 was charged another
+	// amount, 1004 failed with us, 1005 failed on both sides and 1006 was
+	// never listed
+	histories := []model.PurchaseHistory{
+		settlementHistory(1001, model.PurchaseHistoryStatusSuccess, 10000),
+		settlementHistory(1003, model.PurchaseHistoryStatusSuccess, 20000),
+		settlementHistory(1004, model.PurchaseHistoryStatusFailed, 10000),
+		settlementHistory(1005, model.PurchaseHistoryStatusFailed, 10000),
+		settlementHistory(1006, model.PurchaseHistoryStatusSuccess, 50000),
+	}
+	amount := func(value int) *int { return &value }
+
+	tests := []struct {
+		name                  string
+		providerCode          string
+		file                  string
+		expectedOrderIDs      []uint
+		expectedMatched       int
+		expectedDiscrepancies []model.SettlementDiscrepancy
+		expectedError         string
+	}{
+		{
+			name:         "default layout",
+			providerCode: "PROVIDER1",
+			file: "order_id,amount,status\n" +
+				"1001,10000,success\n" +
+				"1002,10000,SUCCESS\n" +
+				"1003,\"10,000\",success\n" +
+				"1004,10000,success\n" +
+				"1005,10000,failed\n",
+			expectedOrderIDs: []uint{1001, 1002, 1003, 1004, 1005},
+			expectedMatched:  2,
+			expectedDiscrepancies: []model.SettlementDiscrepancy{
+				{OrderID: 1002, Type: model.SettlementDiscrepancyTypeMissingOurs, TheirAmount: amount(10000), TheirStatus: "SUCCESS"},
+				{OrderID: 1003, Type: model.SettlementDiscrepancyTypeAmountMismatch, OurAmount: amount(20000), TheirAmount: amount(10000), OurStatus: "success", TheirStatus: "success"},
+				{OrderID: 1004, Type: model.SettlementDiscrepancyTypeStatusMismatch, OurAmount: amount(10000), TheirAmount: amount(10000), OurStatus: "failed", TheirStatus: "success"},
+				{OrderID: 1006, Type: model.SettlementDiscrepancyTypeMissingTheirs, OurAmount: amount(50000), OurStatus: "success"},
+			},
+		},
+		{
+			name:         "configured layout",
+			providerCode: "PROVIDER2",
+			file: "\ufeffMerchant_Ref;Face_Value;Result;Note\n" +
+				"1001;10000;00;ok\n" +
+				"1003;20000;00;ok\n" +
+				"1004;10000;05;rejected\n" +
+				"1005;10000;05;rejected\n" +
+				"1006;50000;00;ok\n",
+			expectedOrderIDs: []uint{1001, 1003, 1004, 1005, 1006},
+			expectedMatched:  5,
+		},
+		{
+			name:          "missing column",
+			providerCode:  "PROVIDER2",
+			file:          "merchant_ref;amount;result\n1001;10000;00\n",
+			expectedError: "settlement file has no face_value column",
+		},
+		{
+			name:          "order listed twice",
+			providerCode:  "PROVIDER1",
+			file:          "order_id,amount,status\n1001,10000,success\n1002,10000,success\n1001,10000,success\n",
+			expectedError: "line 4: order 1001 is already listed on line 2",
+		},
+		{
+			name:          "invalid amount",
+			providerCode:  "PROVIDER1",
+			file:          "order_id,amount,status\n1001,ten,success\n",
+			expectedError: `line 2: invalid amount "ten"`,
+		},
+		{
+			name:          "empty file",
+			providerCode:  "PROVIDER1",
+			expectedError: "settlement file is empty",
+		},
+		{
+			name:          "unknown provider",
+			providerCode:  "UNKNOWN",
+			file:          "order_id,amount,status\n",
+			expectedError: "provider not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			providerRepo := new(mockRepo.ProviderRepositoryMock)
+			if tt.providerCode == "UNKNOWN" {
+				providerRepo.On("GetProviderByCode", ctx, "UNKNOWN").Return(nil, gorm.ErrRecordNotFound)
+			} else {
+				providerRepo.On("GetProviderByCode", ctx, tt.providerCode).Return(&model.Provider{Code: tt.providerCode}, nil)
+			}
+			repo := new(mockRepo.SettlementRepositoryMock)
+			var created *model.SettlementImport
+			if tt.expectedError == "" {
+				from := time.Date(2026, 10, 1, 0, 0, 0, 0, location)
+				repo.On("GetSettlementPurchaseHistories", ctx, tt.providerCode, mock.MatchedBy(from.Equal), mock.MatchedBy(from.AddDate(0, 0, 1).Equal), tt.expectedOrderIDs).Return(histories, nil)
+				repo.On("CreateSettlementImport", ctx, mock.AnythingOfType("*model.SettlementImport")).Run(func(args mock.Arguments) {
+					created = args.Get(1).(*model.SettlementImport)
+				}).Return(nil)
+			}
+			settlementService := service.NewSettlementService(repo, providerRepo, settlementConfig)
+
+			request := schema.SettlementImportRequest{ProviderCode: tt.providerCode, Day: "2026-10-01", FileName: "settlement.csv"}
+			response, err := settlementService.ImportProviderSettlement(ctx, request, strings.NewReader(tt.file))
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				assert.Nil(t, response)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.providerCode, created.ProviderCode)
+				assert.Equal(t, "settlement.csv", created.FileName)
+				assert.Equal(t, len(tt.expectedOrderIDs), created.RowCount)
+				assert.Equal(t, tt.expectedMatched, created.MatchedCount)
+				assert.Equal(t, len(tt.expectedDiscrepancies), created.DiscrepancyCount)
+				assert.Equal(t, tt.expectedDiscrepancies, created.Discrepancies)
+				assert.Equal(t, "2026-10-01", response.Day)
+			}
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSettlementService_ResolveSettlementDiscrepancy(t *testing.T) {
+	request := schema.SettlementDiscrepancyResolveRequest{Note: "provider credited the difference on the next invoice"}
+	tests := []struct {
+		name          string
+		discrepancy   *model.SettlementDiscrepancy
+		resolveErr    error
+		expectedError string
+	}{
+		{
+			name:        "open discrepancy",
+			discrepancy: &model.SettlementDiscrepancy{ID: 7, OrderID: 1003, Type: model.SettlementDiscrepancyTypeAmountMismatch, Status: model.SettlementDiscrepancyStatusOpen},
+		},
+		{
+			name:          "already resolved",
+			discrepancy:   &model.SettlementDiscrepancy{ID: 7, Status: model.SettlementDiscrepancyStatusResolved},
+			expectedError: "settlement discrepancy 7 is already resolved",
+		},
+		{
+			name:          "resolved concurrently",
+			discrepancy:   &model.SettlementDiscrepancy{ID: 7, Status: model.SettlementDiscrepancyStatusOpen},
+			resolveErr:    repository.ErrSettlementDiscrepancyResolved,
+			expectedError: "settlement discrepancy 7 is already resolved",
+		},
+		{
+			name:          "not found",
+			expectedError: "settlement discrepancy not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(mockRepo.SettlementRepositoryMock)
+			if tt.discrepancy != nil {
+				repo.On("GetSettlementDiscrepancyByID", ctx, uint(7)).Return(tt.discrepancy, nil)
+			} else {
+				repo.On("GetSettlementDiscrepancyByID", ctx, uint(7)).Return(nil, gorm.ErrRecordNotFound)
+			}
+			if tt.discrepancy != nil && tt.discrepancy.Status == model.SettlementDiscrepancyStatusOpen {
+				repo.On("ResolveSettlementDiscrepancy", ctx, uint(7), request.Note, mock.AnythingOfType("time.Time")).Return(tt.resolveErr)
+			}
+			settlementService := service.NewSettlementService(repo, new(mockRepo.ProviderRepositoryMock), config.Settlement{})
+
+			response, err := settlementService.ResolveSettlementDiscrepancy(ctx, 7, request)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				assert.Nil(t, response)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, model.SettlementDiscrepancyStatusResolved, response.Status)
+				assert.Equal(t, request.Note, response.Note)
+				assert.NotNil(t, response.ResolvedAt)
+			}
+			repo.AssertExpectations(t)
+		})
+	}
+}
@@ -5,10 +5,14 @@ import (
 	"errors"
 	"testing"
 	"top-up-api/internal/model"
+	"top-up-api/internal/schema"
 	"top-up-api/internal/service"
+	"top-up-api/pkg/errs"
 	mockRepo "top-up-api/tests/repository/mock"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
 )
 
 func TestSkuService_GetSkusBySupplierCode(t *testing.T) {
@@ -191,3 +195,86 @@ func TestSkuService_GetSkusGroupBySupplier(t *testing.T) {
 		})
 	}
 }
+
+func TestSkuService_CreateSku(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name          string
+		request       schema.SkuRequest
+		repoError     error
+		expectedError string
+	}{
+		{
+			name:    "Success",
+			request: schema.SkuRequest{SupplierCode: "VTL", CashBackCode: "CB5P", Price: 50000},
+		},
+		{
+			name:          "Unknown supplier or cash back",
+			request:       schema.SkuRequest{SupplierCode: "XXX", Price: 50000},
+			repoError:     gorm.ErrForeignKeyViolated,
+			expectedError: "sku references a record that does not exist",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(mockRepo.SkuRepositoryMock)
+			repo.On("CreateSku", ctx, mock.MatchedBy(func(sku *model.Sku) bool {
+				return sku.SupplierCode == tt.request.SupplierCode && sku.CashBackCode == tt.request.CashBackCode && sku.Price == tt.request.Price
+			})).Return(tt.repoError)
+
+			got, err := service.NewSkuService(repo).CreateSku(ctx, tt.request)
+			if tt.expectedError != "" {
+				var badRequestErr *errs.BadRequestError
+				assert.ErrorAs(t, err, &badRequestErr)
+				assert.EqualError(t, err, tt.expectedError)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.request.Price, got.Price)
+				assert.Equal(t, tt.request.CashBackCode, got.CashBackCode)
+			}
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSkuService_UpdateSku(t *testing.T) {
+	ctx := context.Background()
+	request := schema.SkuRequest{SupplierCode: "VTL", Price: 20000}
+
+	t.Run("Success clears the cash back", func(t *testing.T) {
+		repo := new(mockRepo.SkuRepositoryMock)
+		repo.On("GetSkuByID", ctx, uint(4)).Return(&model.Sku{Model: gorm.Model{ID: 4}, SupplierCode: "VTL", CashBackCode: "CB5P", Price: 10000}, nil)
+		repo.On("UpdateSku", ctx, mock.MatchedBy(func(sku *model.Sku) bool {
+			return sku.ID == 4 && sku.CashBackCode == "" && sku.Price == 20000
+		})).Return(nil)
+
+		got, err := service.NewSkuService(repo).UpdateSku(ctx, 4, request)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(4), got.ID)
+		assert.Equal(t, 20000, got.Price)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		repo := new(mockRepo.SkuRepositoryMock)
+		repo.On("GetSkuByID", ctx, uint(9)).Return(nil, gorm.ErrRecordNotFound)
+
+		got, err := service.NewSkuService(repo).UpdateSku(ctx, 9, request)
+		assert.EqualError(t, err, "sku not found")
+		assert.Nil(t, got)
+	})
+}
+
+func TestSkuService_DeleteSku(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepo.SkuRepositoryMock)
+	repo.On("DeleteSku", ctx, uint(4)).Return(nil)
+	repo.On("DeleteSku", ctx, uint(9)).Return(gorm.ErrRecordNotFound)
+
+	svc := service.NewSkuService(repo)
+	assert.NoError(t, svc.DeleteSku(ctx, 4))
+	var notFoundErr *errs.NotFoundError
+	assert.ErrorAs(t, svc.DeleteSku(ctx, 9), &notFoundErr)
+}
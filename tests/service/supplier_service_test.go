@@ -5,10 +5,14 @@ import (
 	"errors"
 	"testing"
 	"top-up-api/internal/model"
+	"top-up-api/internal/schema"
 	"top-up-api/internal/service"
+	"top-up-api/pkg/errs"
 	mockRepo "top-up-api/tests/repository/mock"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
 )
 
 func TestSupplierService_GetSuppliers(t *testing.T) {
@@ -94,3 +98,188 @@ func TestSupplierService_GetSuppliers(t *testing.T) {
 		})
 	}
 }
+
+func TestSupplierService_CreateSupplier(t *testing.T) {
+	ctx := context.Background()
+	request := schema.SupplierRequest{Code: "VTL", Name: "Viettel", LogoUrl: "https://cdn.example.com/viettel.png", Status: model.SupplierStatusActive}
+	tests := []struct {
+		name          string
+		request       schema.SupplierRequest
+		repoError     error
+		expectedError string
+		assert        func(t *testing.T, got *schema.SupplierAdminResponse)
+	}{
+		{
+			name:    "Success defaults the routing strategy",
+			request: request,
+			assert: func(t *testing.T, got *schema.SupplierAdminResponse) {
+				assert.Equal(t, uint(7), got.ID)
+				assert.Equal(t, "VTL", got.Code)
+				assert.Equal(t, model.RoutingStrategyWeightedRandom, got.RoutingStrategy)
+			},
+		},
+		{
+			name: "Keeps the requested routing strategy",
+			request: schema.SupplierRequest{
+				Code: "MBF", Name: "Mobifone", LogoUrl: "https://cdn.example.com/mobifone.png",
+				Status: model.SupplierStatusActive, RoutingStrategy: model.RoutingStrategyLeastCost,
+			},
+			assert: func(t *testing.T, got *schema.SupplierAdminResponse) {
+				assert.Equal(t, model.RoutingStrategyLeastCost, got.RoutingStrategy)
+			},
+		},
+		{
+			name:          "Duplicate code",
+			request:       request,
+			repoError:     gorm.ErrDuplicatedKey,
+			expectedError: "supplier already exists",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(mockRepo.SupplierRepositoryMock)
+			repo.On("CreateSupplier", ctx, mock.AnythingOfType("*model.Supplier")).Run(func(args mock.Arguments) {
+				args.Get(1).(*model.Supplier).ID = 7
+			}).Return(tt.repoError)
+
+			got, err := service.NewSupplierService(repo).CreateSupplier(ctx, tt.request)
+			if tt.expectedError != "" {
+				var conflictErr *errs.ConflictError
+				assert.ErrorAs(t, err, &conflictErr)
+				assert.EqualError(t, err, tt.expectedError)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				tt.assert(t, got)
+			}
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSupplierService_UpdateSupplier(t *testing.T) {
+	ctx := context.Background()
+	request := schema.SupplierRequest{Code: "VTL", Name: "Viettel Telecom", LogoUrl: "https://cdn.example.com/viettel.png", Status: model.SupplierStatusInactive, RoutingStrategy: model.RoutingStrategyRoundRobin}
+	existing := &model.Supplier{Model: gorm.Model{ID: 3}, Code: "VTL", Name: "Viettel", Status: model.SupplierStatusActive}
+
+	t.Run("Success", func(t *testing.T) {
+		repo := new(mockRepo.SupplierRepositoryMock)
+		repo.On("GetSupplierByID", ctx, uint(3)).Return(existing, nil)
+		repo.On("UpdateSupplier", ctx, mock.MatchedBy(func(supplier *model.Supplier) bool {
+			return supplier.ID == 3 && supplier.Name == "Viettel Telecom" && supplier.RoutingStrategy == model.RoutingStrategyRoundRobin
+		})).Return(nil)
+
+		got, err := service.NewSupplierService(repo).UpdateSupplier(ctx, 3, request)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(3), got.ID)
+		assert.Equal(t, model.SupplierStatusInactive, got.Status)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		repo := new(mockRepo.SupplierRepositoryMock)
+		repo.On("GetSupplierByID", ctx, uint(9)).Return(nil, gorm.ErrRecordNotFound)
+
+		got, err := service.NewSupplierService(repo).UpdateSupplier(ctx, 9, request)
+		var notFoundErr *errs.NotFoundError
+		assert.ErrorAs(t, err, &notFoundErr)
+		assert.Equal(t, "supplier not found", err.Error())
+		assert.Nil(t, got)
+		repo.AssertNotCalled(t, "UpdateSupplier", mock.Anything, mock.Anything)
+	})
+}
+
+func TestSupplierService_DeleteSupplier(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name          string
+		repoError     error
+		expectedError string
+	}{
+		{name: "Success"},
+		{name: "Not found", repoError: gorm.ErrRecordNotFound, expectedError: "supplier not found"},
+		{name: "Repository error", repoError: errors.New("db error"), expectedError: "db error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(mockRepo.SupplierRepositoryMock)
+			repo.On("DeleteSupplier", ctx, uint(3)).Return(tt.repoError)
+
+			err := service.NewSupplierService(repo).DeleteSupplier(ctx, 3)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSupplierService_SuggestSupplier(t *testing.T) {
+	ctx := context.Background()
+	viettel := model.Supplier{Model: gorm.Model{ID: 1}, Code: "VTL", Name: "Viettel", Status: model.SupplierStatusActive}
+	tests := []struct {
+		name          string
+		phoneNumber   string
+		setupMock     func(*mockRepo.SupplierRepositoryMock)
+		expectedPhone string
+		expectedError string
+	}{
+		{
+			name:        "Success",
+			phoneNumber: "+84 98 123 4567",
+			setupMock: func(m *mockRepo.SupplierRepositoryMock) {
+				m.On("GetSuppliersByCodes", ctx, []string{"VTL"}).Return([]model.Supplier{viettel}, nil)
+			},
+			expectedPhone: "0981234567",
+		},
+		{
+			name:          "Invalid phone number",
+			phoneNumber:   "12345",
+			expectedError: "phone number must be a Vietnamese mobile number",
+		},
+		{
+			name:          "Unknown carrier",
+			phoneNumber:   "0501234567",
+			expectedError: "phone number 0501234567 doesn't belong to a known carrier",
+		},
+		{
+			name:        "No supplier for the carrier",
+			phoneNumber: "0871234567",
+			setupMock: func(m *mockRepo.SupplierRepositoryMock) {
+				m.On("GetSuppliersByCodes", ctx, []string{"ITL"}).Return([]model.Supplier{}, nil)
+			},
+			expectedError: "no supplier for carrier ITL",
+		},
+		{
+			name:        "Repository error",
+			phoneNumber: "0981234567",
+			setupMock: func(m *mockRepo.SupplierRepositoryMock) {
+				m.On("GetSuppliersByCodes", ctx, []string{"VTL"}).Return(nil, errors.New("db error"))
+			},
+			expectedError: "db error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(mockRepo.SupplierRepositoryMock)
+			if tt.setupMock != nil {
+				tt.setupMock(repo)
+			}
+
+			got, err := service.NewSupplierService(repo).SuggestSupplier(ctx, tt.phoneNumber)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedPhone, got.PhoneNumber)
+				assert.Equal(t, "VTL", got.Supplier.Code)
+			}
+			repo.AssertExpectations(t)
+		})
+	}
+}
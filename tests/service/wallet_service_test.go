@@ -0,0 +1,193 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"top-up-api/internal/model"
+	"top-up-api/internal/repository"
+	"top-up-api/internal/schema"
+	"top-up-api/internal/service"
+	"top-up-api/pkg/errs"
+	mockRepo "top-up-api/tests/repository/mock"
+	"top-up-api/tests/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func TestWalletService_GetBalance(t *testing.T) {
+	tests := []struct {
+		name          string
+		setupMocks    func(*mockRepo.LedgerRepositoryMock)
+		expected      *schema.WalletBalanceResponse
+		expectedError string
+	}{
+		{
+			name: "balance of the wallet",
+			setupMocks: func(r *mockRepo.LedgerRepositoryMock) {
+				r.On("GetWalletAccount", mock.Anything, uint(1)).Return(&model.LedgerAccount{ID: 10, UserID: 1, Balance: 1500}, nil)
+			},
+			expected: &schema.WalletBalanceResponse{UserID: 1, Balance: 1500},
+		},
+		{
+			name: "user without a wallet has nothing",
+			setupMocks: func(r *mockRepo.LedgerRepositoryMock) {
+				r.On("GetWalletAccount", mock.Anything, uint(1)).Return(nil, gorm.ErrRecordNotFound)
+			},
+			expected: &schema.WalletBalanceResponse{UserID: 1},
+		},
+		{
+			name: "repository error",
+			setupMocks: func(r *mockRepo.LedgerRepositoryMock) {
+				r.On("GetWalletAccount", mock.Anything, uint(1)).Return(nil, errors.New("db down"))
+			},
+			expectedError: "db down",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := new(mockRepo.LedgerRepositoryMock)
+			tt.setupMocks(r)
+
+			balance, err := service.NewWalletService(r).GetBalance(ctx, 1)
+
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expected, balance)
+			r.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWalletService_GetStatement(t *testing.T) {
+	createdAt := time.Now()
+	entries := []model.LedgerEntry{
+		{
+			ID: 2, TransactionID: 2, AccountID: 10, Amount: -3000, BalanceAfter: 0, CreatedAt: createdAt,
+			Transaction: model.LedgerTransaction{ID: 2, Type: model.LedgerTransactionTypeOrderPayment, OrderID: 1002},
+		},
+		{
+			ID: 1, TransactionID: 1, AccountID: 10, Amount: 3000, BalanceAfter: 3000, CreatedAt: createdAt,
+			Transaction: model.LedgerTransaction{ID: 1, Type: model.LedgerTransactionTypeCashBack, OrderID: 1001},
+		},
+	}
+
+	t.Run("movements of the wallet", func(t *testing.T) {
+		r := new(mockRepo.LedgerRepositoryMock)
+		r.On("GetWalletAccount", mock.Anything, uint(1)).Return(&model.LedgerAccount{ID: 10, UserID: 1}, nil)
+		r.On("GetLedgerEntriesByAccountIDPaginated", mock.Anything, uint(10), 1, 10).Return(entries, int64(2), nil)
+
+		statement, err := service.NewWalletService(r).GetStatement(ctx, 1, 1, 10)
+
+		assert.NoError(t, err)
+		assert.Equal(t, schema.Pagination{TotalCount: 2, TotalPage: 1, CurrentPage: 1}, statement.Pagination)
+		assert.Equal(t, []*schema.WalletStatementEntryResponse{
+			{ID: 2, Type: model.LedgerTransactionTypeOrderPayment, OrderID: 1002, Amount: -3000, BalanceAfter: 0, CreatedAt: createdAt},
+			{ID: 1, Type: model.LedgerTransactionTypeCashBack, OrderID: 1001, Amount: 3000, BalanceAfter: 3000, CreatedAt: createdAt},
+		}, statement.Data)
+		r.AssertExpectations(t)
+	})
+
+	t.Run("user without a wallet has no movements", func(t *testing.T) {
+		r := new(mockRepo.LedgerRepositoryMock)
+		r.On("GetWalletAccount", mock.Anything, uint(1)).Return(nil, gorm.ErrRecordNotFound)
+
+		statement, err := service.NewWalletService(r).GetStatement(ctx, 1, 1, 10)
+
+		assert.NoError(t, err)
+		assert.Equal(t, schema.Pagination{TotalCount: 0, TotalPage: 0, CurrentPage: 1}, statement.Pagination)
+		assert.Equal(t, []*schema.WalletStatementEntryResponse{}, statement.Data)
+		r.AssertExpectations(t)
+	})
+}
+
+func TestWalletService_Postings(t *testing.T) {
+	tests := []struct {
+		name          string
+		post          func(service.WalletService) error
+		setupMocks    func(*mockRepo.LedgerRepositoryMock)
+		expectedError error
+	}{
+		{
+			name: "cashback moves from the cashback account to the wallet",
+			post: func(s service.WalletService) error { return s.CreditCashBack(ctx, 1, 1001, 500) },
+			setupMocks: func(r *mockRepo.LedgerRepositoryMock) {
+				r.On("CreateLedgerTransaction", mock.Anything, mock.MatchedBy(func(transaction *model.LedgerTransaction) bool {
+					return transaction.Type == model.LedgerTransactionTypeCashBack && transaction.OrderID == 1001 &&
+						transaction.Entries[0].AccountID == util.TestWalletAccountID && transaction.Entries[0].Amount == 500 &&
+						transaction.Entries[1].AccountID == util.TestCashBackAccountID && transaction.Entries[1].Amount == -500
+				})).Return(nil)
+			},
+		},
+		{
+			name: "reversal takes the cashback back",
+			post: func(s service.WalletService) error { return s.ReverseCashBack(ctx, 1, 1001, 500) },
+			setupMocks: func(r *mockRepo.LedgerRepositoryMock) {
+				r.On("CreateLedgerTransaction", mock.Anything, util.LedgerTransactionOf(model.LedgerTransactionTypeCashBackReversal, 1001, -500)).Return(nil)
+			},
+		},
+		{
+			name: "order payment moves to the order payment account",
+			post: func(s service.WalletService) error { return s.PayOrder(ctx, 1, 1001, 3000) },
+			setupMocks: func(r *mockRepo.LedgerRepositoryMock) {
+				r.On("CreateLedgerTransaction", mock.Anything, mock.MatchedBy(func(transaction *model.LedgerTransaction) bool {
+					return transaction.Type == model.LedgerTransactionTypeOrderPayment &&
+						transaction.Entries[0].Amount == -3000 &&
+						transaction.Entries[1].AccountID == util.TestOrderPaymentAccountID && transaction.Entries[1].Amount == 3000
+				})).Return(nil)
+			},
+		},
+		{
+			name: "refund gives the payment back",
+			post: func(s service.WalletService) error { return s.RefundOrder(ctx, 1, 1001, 3000) },
+			setupMocks: func(r *mockRepo.LedgerRepositoryMock) {
+				r.On("CreateLedgerTransaction", mock.Anything, util.LedgerTransactionOf(model.LedgerTransactionTypeOrderRefund, 1001, 3000)).Return(nil)
+			},
+		},
+		{
+			name:       "zero amount posts nothing",
+			post:       func(s service.WalletService) error { return s.CreditCashBack(ctx, 1, 1001, 0) },
+			setupMocks: func(r *mockRepo.LedgerRepositoryMock) {},
+		},
+		{
+			name: "wallet balance too low",
+			post: func(s service.WalletService) error { return s.PayOrder(ctx, 1, 1001, 3000) },
+			setupMocks: func(r *mockRepo.LedgerRepositoryMock) {
+				r.On("CreateLedgerTransaction", mock.Anything, mock.Anything).Return(repository.ErrInsufficientBalance)
+			},
+			expectedError: &errs.BadRequestError{Message: "insufficient wallet balance"},
+		},
+		{
+			name: "order already credited",
+			post: func(s service.WalletService) error { return s.CreditCashBack(ctx, 1, 1001, 500) },
+			setupMocks: func(r *mockRepo.LedgerRepositoryMock) {
+				r.On("CreateLedgerTransaction", mock.Anything, mock.Anything).Return(gorm.ErrDuplicatedKey)
+			},
+			expectedError: &errs.ConflictError{Message: "order 1001 already has a cashback ledger transaction"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := new(mockRepo.LedgerRepositoryMock)
+			util.SetupDefaultLedgerAccountMocks(r)
+			tt.setupMocks(r)
+
+			err := tt.post(service.NewWalletService(r))
+
+			if tt.expectedError != nil {
+				assert.Equal(t, tt.expectedError, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			r.AssertExpectations(t)
+		})
+	}
+}
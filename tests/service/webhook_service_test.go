@@ -0,0 +1,383 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"top-up-api/config"
+	"top-up-api/internal/model"
+	"top-up-api/internal/schema"
+	"top-up-api/internal/service"
+	"top-up-api/pkg/errs"
+	"top-up-api/pkg/signature"
+	mockRepo "top-up-api/tests/repository/mock"
+	"top-up-api/tests/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+const webhookTestSecret = "whsec_test"
+
+var webhookTestConfig = config.Webhook{
+	BatchSize:   10,
+	MaxAttempts: 3,
+	BaseBackoff: time.Second,
+	MaxBackoff:  time.Minute,
+	HTTPTimeout: time.Second,
+	Lease:       time.Minute,
+	// The test servers listen on http loopback addresses
+	AllowInsecureURLs: true,
+}
+
+func newWebhookDelivery(id uint, url string, attempts int) model.WebhookDelivery {
+	return model.WebhookDelivery{
+		Model:          gorm.Model{ID: id},
+		SubscriptionID: 1,
+		EventType:      model.WebhookEventOrderSucceeded,
+		AggregateID:    1001,
+		Payload:        `{"event":"order.succeeded","order_id":1001}`,
+		Status:         model.WebhookDeliveryStatusPending,
+		Attempts:       attempts,
+		Subscription: model.WebhookSubscription{
+			Model:  gorm.Model{ID: 1},
+			URL:    url,
+			Secret: webhookTestSecret,
+			Active: true,
+		},
+	}
+}
+
+func TestWebhookService_DispatchPending(t *testing.T) {
+	var verified []string
+	okServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if _, err := signature.Verify(webhookTestSecret, r.Header.Get("X-Webhook-Signature"), body, time.Minute, time.Now()); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		verified = append(verified, r.Header.Get("X-Webhook-Event")+" "+r.Header.Get("X-Webhook-Delivery")+" "+string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer okServer.Close()
+	failingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failingServer.Close()
+
+	testCases := []struct {
+		Name              string
+		SetupMocks        func(*mockRepo.WebhookRepositoryMock)
+		ExpectedDelivered int
+		ExpectedError     string
+	}{
+		{
+			Name: "signed delivery is accepted",
+			SetupMocks: func(webhookRepo *mockRepo.WebhookRepositoryMock) {
+				webhookRepo.On("ClaimDueWebhookDeliveries", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Duration"), 10).
+					Return([]model.WebhookDelivery{newWebhookDelivery(1, okServer.URL, 0)}, nil)
+				webhookRepo.On("MarkWebhookDeliveryDelivered", mock.Anything, uint(1), 1, http.StatusNoContent, mock.AnythingOfType("time.Time")).Return(nil)
+			},
+			ExpectedDelivered: 1,
+		},
+		{
+			Name: "failed delivery is retried with backoff",
+			SetupMocks: func(webhookRepo *mockRepo.WebhookRepositoryMock) {
+				webhookRepo.On("ClaimDueWebhookDeliveries", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Duration"), 10).
+					Return([]model.WebhookDelivery{newWebhookDelivery(2, failingServer.URL, 1)}, nil)
+				webhookRepo.On("MarkWebhookDeliveryRetry", mock.Anything, uint(2), 2, http.StatusInternalServerError,
+					mock.MatchedBy(func(next time.Time) bool {
+						delay := time.Until(next)
+						return delay > time.Second && delay <= 2*time.Second
+					}),
+					"unexpected response status: 500").Return(nil)
+			},
+			ExpectedDelivered: 0,
+		},
+		{
+			Name: "delivery fails after the last attempt",
+			SetupMocks: func(webhookRepo *mockRepo.WebhookRepositoryMock) {
+				webhookRepo.On("ClaimDueWebhookDeliveries", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Duration"), 10).
+					Return([]model.WebhookDelivery{newWebhookDelivery(3, failingServer.URL, 2)}, nil)
+				webhookRepo.On("MarkWebhookDeliveryFailed", mock.Anything, uint(3), 3, http.StatusInternalServerError, "unexpected response status: 500").Return(nil)
+			},
+			ExpectedDelivered: 0,
+		},
+		{
+			Name: "delivery of an inactive subscription fails without a request",
+			SetupMocks: func(webhookRepo *mockRepo.WebhookRepositoryMock) {
+				delivery := newWebhookDelivery(4, failingServer.URL, 0)
+				delivery.Subscription.Active = false
+				webhookRepo.On("ClaimDueWebhookDeliveries", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Duration"), 10).
+					Return([]model.WebhookDelivery{delivery}, nil)
+				webhookRepo.On("MarkWebhookDeliveryFailed", mock.Anything, uint(4), 0, 0, "subscription is no longer active").Return(nil)
+			},
+			ExpectedDelivered: 0,
+		},
+		{
+			Name: "result that can't be recorded doesn't hold the rest of the batch back",
+			SetupMocks: func(webhookRepo *mockRepo.WebhookRepositoryMock) {
+				webhookRepo.On("ClaimDueWebhookDeliveries", mock.Anything, mock.AnythingOfType("time.Time"), time.Minute, 10).
+					Return([]model.WebhookDelivery{newWebhookDelivery(5, failingServer.URL, 0), newWebhookDelivery(6, okServer.URL, 0)}, nil)
+				webhookRepo.On("MarkWebhookDeliveryRetry", mock.Anything, uint(5), 1, http.StatusInternalServerError, mock.AnythingOfType("time.Time"), "unexpected response status: 500").
+					Return(errors.New("connection reset"))
+				webhookRepo.On("MarkWebhookDeliveryDelivered", mock.Anything, uint(6), 1, http.StatusNoContent, mock.AnythingOfType("time.Time")).Return(nil)
+			},
+			ExpectedDelivered: 1,
+			ExpectedError:     "webhook delivery 5: connection reset",
+		},
+		{
+			Name: "repository error",
+			SetupMocks: func(webhookRepo *mockRepo.WebhookRepositoryMock) {
+				webhookRepo.On("ClaimDueWebhookDeliveries", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Duration"), 10).
+					Return(nil, errors.New("db error"))
+			},
+			ExpectedError: "db error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			webhookRepo := new(mockRepo.WebhookRepositoryMock)
+			txManager := new(mockRepo.TransactionManagerMock)
+			util.SetupTransactionMocks(txManager)
+			tc.SetupMocks(webhookRepo)
+
+			webhookService := service.NewWebhookService(webhookRepo, webhookTestConfig)
+			delivered, err := webhookService.DispatchPending(ctx)
+
+			if tc.ExpectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.ExpectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.ExpectedDelivered, delivered)
+
+			webhookRepo.AssertExpectations(t)
+		})
+	}
+
+	assert.Equal(t, []string{
+		`order.succeeded 1 {"event":"order.succeeded","order_id":1001}`,
+		`order.succeeded 6 {"event":"order.succeeded","order_id":1001}`,
+	}, verified)
+}
+
+func TestWebhookService_Subscriptions(t *testing.T) {
+	request := schema.WebhookSubscriptionRequest{
+		Name:       "Partner",
+		URL:        "https://partner.example.com/hooks",
+		EventTypes: []string{model.WebhookEventOrderSucceeded},
+	}
+
+	t.Run("create generates a secret shown once", func(t *testing.T) {
+		webhookRepo := new(mockRepo.WebhookRepositoryMock)
+		var stored *model.WebhookSubscription
+		webhookRepo.On("CreateWebhookSubscription", mock.Anything, mock.AnythingOfType("*model.WebhookSubscription")).
+			Run(func(args mock.Arguments) {
+				stored = args.Get(1).(*model.WebhookSubscription)
+			}).Return(nil)
+
+		webhookService := service.NewWebhookService(webhookRepo, webhookTestConfig)
+		response, err := webhookService.CreateSubscription(ctx, 7, request)
+
+		assert.NoError(t, err)
+		assert.Equal(t, uint(7), stored.UserID)
+		assert.Equal(t, uint(7), response.UserID)
+		assert.True(t, strings.HasPrefix(response.Secret, "whsec_"))
+		assert.Equal(t, stored.Secret, response.Secret)
+		assert.True(t, response.Active)
+		assert.Equal(t, request.EventTypes, response.EventTypes)
+		webhookRepo.AssertExpectations(t)
+	})
+
+	t.Run("update keeps the secret", func(t *testing.T) {
+		webhookRepo := new(mockRepo.WebhookRepositoryMock)
+		webhookRepo.On("GetWebhookSubscriptionByID", mock.Anything, uint(1)).
+			Return(&model.WebhookSubscription{Model: gorm.Model{ID: 1}, UserID: 7, Secret: webhookTestSecret, Active: true}, nil)
+		webhookRepo.On("UpdateWebhookSubscription", mock.Anything, mock.MatchedBy(func(subscription *model.WebhookSubscription) bool {
+			return subscription.ID == 1 && subscription.UserID == 7 && subscription.Secret == webhookTestSecret && !subscription.Active
+		})).Return(nil)
+
+		inactive := false
+		update := request
+		update.Active = &inactive
+		webhookService := service.NewWebhookService(webhookRepo, webhookTestConfig)
+		response, err := webhookService.UpdateUserSubscription(ctx, 7, 1, update)
+
+		assert.NoError(t, err)
+		assert.False(t, response.Active)
+		webhookRepo.AssertExpectations(t)
+	})
+
+	t.Run("admin update assigns the subscription to its owner", func(t *testing.T) {
+		webhookRepo := new(mockRepo.WebhookRepositoryMock)
+		webhookRepo.On("GetWebhookSubscriptionByID", mock.Anything, uint(1)).
+			Return(&model.WebhookSubscription{Model: gorm.Model{ID: 1}, Secret: webhookTestSecret}, nil)
+		webhookRepo.On("UpdateWebhookSubscription", mock.Anything, mock.MatchedBy(func(subscription *model.WebhookSubscription) bool {
+			return subscription.ID == 1 && subscription.UserID == 7 && subscription.Secret == webhookTestSecret && subscription.Active
+		})).Return(nil)
+
+		webhookService := service.NewWebhookService(webhookRepo, webhookTestConfig)
+		response, err := webhookService.UpdateSubscription(ctx, 1, schema.AdminWebhookSubscriptionRequest{WebhookSubscriptionRequest: request, UserID: 7})
+
+		assert.NoError(t, err)
+		assert.Equal(t, uint(7), response.UserID)
+		webhookRepo.AssertExpectations(t)
+	})
+
+	t.Run("subscription of another user is not found", func(t *testing.T) {
+		webhookRepo := new(mockRepo.WebhookRepositoryMock)
+		webhookRepo.On("GetWebhookSubscriptionByID", mock.Anything, uint(1)).
+			Return(&model.WebhookSubscription{Model: gorm.Model{ID: 1}, UserID: 8, Secret: webhookTestSecret, Active: true}, nil)
+
+		webhookService := service.NewWebhookService(webhookRepo, webhookTestConfig)
+		_, updateErr := webhookService.UpdateUserSubscription(ctx, 7, 1, request)
+		deleteErr := webhookService.DeleteUserSubscription(ctx, 7, 1)
+		_, deliveriesErr := webhookService.GetUserDeliveries(ctx, 7, 1, 1, 10)
+
+		var notFoundErr *errs.NotFoundError
+		assert.ErrorAs(t, updateErr, &notFoundErr)
+		assert.ErrorAs(t, deleteErr, &notFoundErr)
+		assert.ErrorAs(t, deliveriesErr, &notFoundErr)
+		webhookRepo.AssertNotCalled(t, "UpdateWebhookSubscription", mock.Anything, mock.Anything)
+		webhookRepo.AssertNotCalled(t, "DeleteWebhookSubscription", mock.Anything, mock.Anything)
+		webhookRepo.AssertExpectations(t)
+	})
+
+	t.Run("user only lists its own subscriptions", func(t *testing.T) {
+		webhookRepo := new(mockRepo.WebhookRepositoryMock)
+		webhookRepo.On("GetWebhookSubscriptionsByUserID", mock.Anything, uint(7)).
+			Return([]model.WebhookSubscription{{Model: gorm.Model{ID: 1}, UserID: 7}}, nil)
+
+		webhookService := service.NewWebhookService(webhookRepo, webhookTestConfig)
+		responses, err := webhookService.GetUserSubscriptions(ctx, 7)
+
+		assert.NoError(t, err)
+		assert.Len(t, responses, 1)
+		webhookRepo.AssertExpectations(t)
+	})
+
+	t.Run("update of a missing subscription is not found", func(t *testing.T) {
+		webhookRepo := new(mockRepo.WebhookRepositoryMock)
+		webhookRepo.On("GetWebhookSubscriptionByID", mock.Anything, uint(9)).Return(nil, gorm.ErrRecordNotFound)
+
+		webhookService := service.NewWebhookService(webhookRepo, webhookTestConfig)
+		_, err := webhookService.UpdateSubscription(ctx, 9, schema.AdminWebhookSubscriptionRequest{WebhookSubscriptionRequest: request, UserID: 7})
+
+		var notFoundErr *errs.NotFoundError
+		assert.ErrorAs(t, err, &notFoundErr)
+		webhookRepo.AssertExpectations(t)
+	})
+}
+
+func TestWebhookService_RejectsInternalURLs(t *testing.T) {
+	strictConfig := webhookTestConfig
+	strictConfig.AllowInsecureURLs = false
+
+	urls := []string{
+		"http://partner.example.com/hooks",
+		"https://localhost:8081/v1/api/payment",
+		"https://127.0.0.1/hooks",
+		"https://[::1]/hooks",
+		"https://169.254.169.254/latest/meta-data",
+		"https://10.0.0.5/hooks",
+		"https://172.16.0.1/hooks",
+		"https://192.168.1.10/hooks",
+		"https://0.0.0.0/hooks",
+		"https://[::ffff:127.0.0.1]/hooks",
+	}
+	for _, url := range urls {
+		t.Run(url, func(t *testing.T) {
+			webhookRepo := new(mockRepo.WebhookRepositoryMock)
+			webhookRepo.On("GetWebhookSubscriptionByID", mock.Anything, uint(1)).
+				Return(&model.WebhookSubscription{Model: gorm.Model{ID: 1}, UserID: 7, Secret: webhookTestSecret, Active: true}, nil)
+			request := schema.WebhookSubscriptionRequest{Name: "Partner", URL: url, EventTypes: []string{model.WebhookEventOrderSucceeded}}
+
+			webhookService := service.NewWebhookService(webhookRepo, strictConfig)
+			_, createErr := webhookService.CreateSubscription(ctx, 7, request)
+			_, updateErr := webhookService.UpdateUserSubscription(ctx, 7, 1, request)
+
+			var badRequestErr *errs.BadRequestError
+			assert.ErrorAs(t, createErr, &badRequestErr)
+			assert.ErrorAs(t, updateErr, &badRequestErr)
+			webhookRepo.AssertNotCalled(t, "CreateWebhookSubscription", mock.Anything, mock.Anything)
+			webhookRepo.AssertNotCalled(t, "UpdateWebhookSubscription", mock.Anything, mock.Anything)
+		})
+	}
+
+	t.Run("stored subscription to an internal address is not delivered", func(t *testing.T) {
+		var requests int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+		}))
+		defer server.Close()
+
+		webhookRepo := new(mockRepo.WebhookRepositoryMock)
+		webhookRepo.On("ClaimDueWebhookDeliveries", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Duration"), 10).
+			Return([]model.WebhookDelivery{newWebhookDelivery(1, server.URL, 0)}, nil)
+		webhookRepo.On("MarkWebhookDeliveryRetry", mock.Anything, uint(1), 1, 0, mock.AnythingOfType("time.Time"),
+			mock.MatchedBy(func(lastError string) bool { return strings.HasPrefix(lastError, "webhook url rejected") })).Return(nil)
+
+		webhookService := service.NewWebhookService(webhookRepo, strictConfig)
+		delivered, err := webhookService.DispatchPending(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, delivered)
+		assert.Equal(t, 0, requests)
+		webhookRepo.AssertExpectations(t)
+	})
+}
+
+func TestWebhookService_Redeliver(t *testing.T) {
+	t.Run("failed delivery is queued again", func(t *testing.T) {
+		webhookRepo := new(mockRepo.WebhookRepositoryMock)
+		delivery := newWebhookDelivery(5, "https://partner.example.com/hooks", 3)
+		delivery.Status = model.WebhookDeliveryStatusFailed
+		webhookRepo.On("GetWebhookDeliveryByID", mock.Anything, uint(5)).Return(&delivery, nil)
+		webhookRepo.On("ResetWebhookDelivery", mock.Anything, uint(5), mock.AnythingOfType("time.Time")).Return(nil)
+
+		webhookService := service.NewWebhookService(webhookRepo, webhookTestConfig)
+		response, err := webhookService.Redeliver(ctx, 5)
+
+		assert.NoError(t, err)
+		assert.Equal(t, model.WebhookDeliveryStatusPending, response.Status)
+		assert.Equal(t, 0, response.Attempts)
+		webhookRepo.AssertExpectations(t)
+	})
+
+	t.Run("delivery of another user is not found", func(t *testing.T) {
+		webhookRepo := new(mockRepo.WebhookRepositoryMock)
+		delivery := newWebhookDelivery(5, "https://partner.example.com/hooks", 3)
+		webhookRepo.On("GetWebhookDeliveryByID", mock.Anything, uint(5)).Return(&delivery, nil)
+		webhookRepo.On("GetWebhookSubscriptionByID", mock.Anything, uint(1)).
+			Return(&model.WebhookSubscription{Model: gorm.Model{ID: 1}, UserID: 8}, nil)
+
+		webhookService := service.NewWebhookService(webhookRepo, webhookTestConfig)
+		_, err := webhookService.RedeliverUserDelivery(ctx, 7, 5)
+
+		var notFoundErr *errs.NotFoundError
+		assert.ErrorAs(t, err, &notFoundErr)
+		webhookRepo.AssertNotCalled(t, "ResetWebhookDelivery", mock.Anything, mock.Anything, mock.Anything)
+		webhookRepo.AssertExpectations(t)
+	})
+
+	t.Run("missing delivery is not found", func(t *testing.T) {
+		webhookRepo := new(mockRepo.WebhookRepositoryMock)
+		webhookRepo.On("GetWebhookDeliveryByID", mock.Anything, uint(6)).Return(nil, gorm.ErrRecordNotFound)
+
+		webhookService := service.NewWebhookService(webhookRepo, webhookTestConfig)
+		_, err := webhookService.Redeliver(ctx, 6)
+
+		var notFoundErr *errs.NotFoundError
+		assert.ErrorAs(t, err, &notFoundErr)
+		webhookRepo.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,129 @@
+package statemachine
+
+import (
+	"errors"
+	"testing"
+	"top-up-api/internal/model"
+	"top-up-api/internal/statemachine"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderStateMachine_Transition(t *testing.T) {
+	tests := []struct {
+		name          string
+		from          model.PurchaseHistoryStatus
+		to            model.PurchaseHistoryStatus
+		expectedError string
+	}{
+		{name: "pending to confirm", from: model.PurchaseHistoryStatusPending, to: model.PurchaseHistoryStatusConfirm},
+		{name: "pending to failed", from: model.PurchaseHistoryStatusPending, to: model.PurchaseHistoryStatusFailed},
+		{name: "pending to expired", from: model.PurchaseHistoryStatusPending, to: model.PurchaseHistoryStatusExpired},
+		{
+			name:          "confirmed order can't expire",
+			from:          model.PurchaseHistoryStatusConfirm,
+			to:            model.PurchaseHistoryStatusExpired,
+			expectedError: "invalid order status transition from confirm to expired",
+		},
+		{
+			name:          "expired is terminal",
+			from:          model.PurchaseHistoryStatusExpired,
+			to:            model.PurchaseHistoryStatusConfirm,
+			expectedError: "invalid order status transition from expired to confirm",
+		},
+		{name: "confirm to success", from: model.PurchaseHistoryStatusConfirm, to: model.PurchaseHistoryStatusSuccess},
+		{name: "confirm to failed", from: model.PurchaseHistoryStatusConfirm, to: model.PurchaseHistoryStatusFailed},
+		{
+			name:          "pending to success skips confirmation",
+			from:          model.PurchaseHistoryStatusPending,
+			to:            model.PurchaseHistoryStatusSuccess,
+			expectedError: "invalid order status transition from pending to success",
+		},
+		{
+			name:          "success is terminal",
+			from:          model.PurchaseHistoryStatusSuccess,
+			to:            model.PurchaseHistoryStatusFailed,
+			expectedError: "invalid order status transition from success to failed",
+		},
+		{
+			name:          "success can't be confirmed again",
+			from:          model.PurchaseHistoryStatusSuccess,
+			to:            model.PurchaseHistoryStatusConfirm,
+			expectedError: "invalid order status transition from success to confirm",
+		},
+		{
+			name:          "failed is terminal",
+			from:          model.PurchaseHistoryStatusFailed,
+			to:            model.PurchaseHistoryStatusSuccess,
+			expectedError: "invalid order status transition from failed to success",
+		},
+		{
+			name:          "unknown target status",
+			from:          model.PurchaseHistoryStatusPending,
+			to:            model.PurchaseHistoryStatus("refunded"),
+			expectedError: "unknown order status: refunded",
+		},
+	}
+
+	machine := statemachine.NewOrderStateMachine()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := machine.Transition(tt.from, tt.to)
+			if tt.expectedError == "" {
+				assert.NoError(t, err)
+				assert.True(t, machine.CanTransition(tt.from, tt.to))
+				return
+			}
+			assert.EqualError(t, err, tt.expectedError)
+			assert.False(t, machine.CanTransition(tt.from, tt.to))
+		})
+	}
+}
+
+func TestOrderStateMachine_TransitionFrom(t *testing.T) {
+	machine := statemachine.NewOrderStateMachine()
+
+	err := machine.TransitionFrom(model.PurchaseHistoryStatusPending, model.PurchaseHistoryStatusConfirm, model.PurchaseHistoryStatusFailed)
+	var transitionErr *statemachine.TransitionError
+	assert.True(t, errors.As(err, &transitionErr))
+	assert.Equal(t, model.PurchaseHistoryStatusConfirm, transitionErr.From)
+	assert.Equal(t, model.PurchaseHistoryStatusFailed, transitionErr.To)
+
+	assert.NoError(t, machine.TransitionFrom(model.PurchaseHistoryStatusPending, model.PurchaseHistoryStatusPending, model.PurchaseHistoryStatusFailed))
+}
+
+func TestOrderStateMachine_IsTerminal(t *testing.T) {
+	machine := statemachine.NewOrderStateMachine()
+
+	assert.False(t, machine.IsTerminal(model.PurchaseHistoryStatusPending))
+	assert.False(t, machine.IsTerminal(model.PurchaseHistoryStatusConfirm))
+	assert.True(t, machine.IsTerminal(model.PurchaseHistoryStatusSuccess))
+	assert.True(t, machine.IsTerminal(model.PurchaseHistoryStatusFailed))
+	assert.True(t, machine.IsTerminal(model.PurchaseHistoryStatusExpired))
+	assert.False(t, machine.IsTerminal(model.PurchaseHistoryStatus("unknown")))
+}
+
+func TestOrderStateMachine_IsSettled(t *testing.T) {
+	machine := statemachine.NewOrderStateMachine()
+
+	assert.False(t, machine.IsSettled(model.PurchaseHistoryStatusPending))
+	assert.False(t, machine.IsSettled(model.PurchaseHistoryStatusConfirm))
+	assert.True(t, machine.IsSettled(model.PurchaseHistoryStatusSuccess))
+	assert.True(t, machine.IsSettled(model.PurchaseHistoryStatusFailed))
+	assert.False(t, machine.IsSettled(model.PurchaseHistoryStatusExpired))
+}
+
+func TestOrderStateMachine_Reverse(t *testing.T) {
+	machine := statemachine.NewOrderStateMachine()
+
+	to, err := machine.Reverse(model.PurchaseHistoryStatusSuccess)
+	assert.NoError(t, err)
+	assert.Equal(t, model.PurchaseHistoryStatusFailed, to)
+
+	_, err = machine.Reverse(model.PurchaseHistoryStatusConfirm)
+	assert.EqualError(t, err, "invalid order status transition from confirm to failed")
+	_, err = machine.Reverse(model.PurchaseHistoryStatusFailed)
+	assert.EqualError(t, err, "invalid order status transition from failed to failed")
+	_, err = machine.Reverse(model.PurchaseHistoryStatus("unknown"))
+	assert.EqualError(t, err, "unknown order status: unknown")
+}
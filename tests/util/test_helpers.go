@@ -1,16 +1,18 @@
 package util
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 	"gorm.io/gorm"
 
 	grpcClient "top-up-api/internal/grpc/client"
+	"top-up-api/internal/mapper"
 	"top-up-api/internal/model"
 	"top-up-api/internal/schema"
+	redisPkg "top-up-api/pkg/redis"
 	mockGrpc "top-up-api/tests/mock"
 	mockRepo "top-up-api/tests/repository/mock"
 )
@@ -33,14 +35,19 @@ func CreateMockSku(id uint, supplierCode string, price int, cashbackType model.C
 	}
 }
 
+// CreateMockProvider serves every supplier with the same weight
 func CreateMockProvider(id uint, code, source, providerType string, weight int, suppliers []model.Supplier) model.Provider {
+	providerSuppliers := make([]model.ProviderSupplier, len(suppliers))
+	for i, supplier := range suppliers {
+		providerSuppliers[i] = model.ProviderSupplier{ProviderID: id, SupplierID: supplier.ID, Weight: weight}
+	}
 	return model.Provider{
-		Model:     gorm.Model{ID: id},
-		Code:      code,
-		Source:    source,
-		Type:      providerType,
-		Weight:    weight,
-		Suppliers: suppliers,
+		Model:             gorm.Model{ID: id},
+		Code:              code,
+		Source:            source,
+		Type:              providerType,
+		Suppliers:         suppliers,
+		ProviderSuppliers: providerSuppliers,
 	}
 }
 
@@ -51,6 +58,12 @@ func CreateMockSupplier(code, name string) model.Supplier {
 	}
 }
 
+func CreateMockSupplierWithStrategy(code, name string, strategy model.RoutingStrategy) model.Supplier {
+	supplier := CreateMockSupplier(code, name)
+	supplier.RoutingStrategy = strategy
+	return supplier
+}
+
 // Common mock setup functions
 func SetupBasicMocks(skuRepo *mockRepo.SkuRepositoryMock, redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock, sku *model.Sku, providers []model.Provider) {
 	skuRepo.On("GetSkuByID", mock.Anything, sku.ID).Return(sku, nil)
@@ -74,16 +87,27 @@ func SetupCacheErrorMocks(skuRepo *mockRepo.SkuRepositoryMock, redis *mockGrpc.R
 	redis.On("Set", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(cacheError)
 }
 
+// NewTestLock is the lock handed out for key, holding fencing token 1
+func NewTestLock(key string) *redisPkg.Lock {
+	return &redisPkg.Lock{Key: key, Token: "token-" + key, Fence: 1}
+}
+
+// LockOf matches the lock of key
+func LockOf(key string) interface{} {
+	return mock.MatchedBy(func(lock *redisPkg.Lock) bool {
+		return lock.Key == key
+	})
+}
+
 // ConfirmOrder test helpers
 func CreateCachedOrderResponse(orderID, userID uint, totalPrice int, phoneNumber string, cashbackValue int, skuID uint, supplierCode, supplierName string, cashbackType model.CashBackType, cashbackTypeValue int) *schema.OrderResponse {
 	response := &schema.OrderResponse{
-		OrderID:              orderID,
-		UserID:               userID,
-		TotalPrice:           totalPrice,
-		PhoneNumber:          phoneNumber,
-		CashBackValue:        cashbackValue,
-		Status:               model.PurchaseHistoryStatusPending,
-		RandomProviderWeight: 50,
+		OrderID:       orderID,
+		UserID:        userID,
+		TotalPrice:    totalPrice,
+		PhoneNumber:   phoneNumber,
+		CashBackValue: cashbackValue,
+		Status:        model.PurchaseHistoryStatusPending,
 		Sku: schema.SkuResponse{
 			ID:    skuID,
 			Price: totalPrice,
@@ -117,18 +141,20 @@ func CreateCashBackInterface(cashbackType model.CashBackType, skuID uint, cashba
 	}
 }
 
-func SetupConfirmOrderMocks(redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderID string, cachedOrder *schema.OrderResponse, purchaseHistoryError error) {
+func SetupConfirmOrderMocks(redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, orderID string, cachedOrder *schema.OrderResponse, purchaseHistoryError error) {
 	providers := []model.Provider{
 		CreateMockProvider(1, "PROVIDER1", "http://provider1.com", "http", 100, []model.Supplier{
 			CreateMockSupplier("VTL", "Viettel"),
 		}),
 	}
 	providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(providers, nil)
-	redis.On("TryAcquireLock", mock.Anything, orderID, mock.AnythingOfType("time.Duration")).Return(nil)
-	redis.On("ReleaseLock", mock.Anything, orderID).Return(nil)
-	cachedOrderBytes, _ := json.Marshal(cachedOrder)
-	redis.On("Get", mock.Anything, "order_id"+orderID).Return(string(cachedOrderBytes), nil)
+	redis.On("TryAcquireLock", mock.Anything, orderID, mock.AnythingOfType("time.Duration")).Return(NewTestLock(orderID), nil)
+	redis.On("ReleaseLock", mock.Anything, LockOf(orderID)).Return(nil)
+	SetupStoredOrderMocks(orderRepo, cachedOrder)
 	redis.On("Set", mock.Anything, "order_id"+orderID, mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
+	redis.On("Publish", mock.Anything, "order_status:"+orderID, mock.AnythingOfType("[]uint8")).Return(nil)
+	SetupDefaultPurchaseHistoryProviderMocks(purchaseRepo)
+	SetupDefaultPaymentReferenceMocks(purchaseRepo)
 	if purchaseHistoryError != nil {
 		purchaseRepo.On("CreatePurchaseHistory", mock.Anything, mock.AnythingOfType("*model.PurchaseHistory")).Return(purchaseHistoryError)
 	} else {
@@ -155,25 +181,27 @@ var SingleGrpcProvider = func(providerCode, code, name string) []model.Provider
 }
 
 // Helper for mixed providers setup
+// MixedProviders routes by least cost, so the cheaper gRPC provider is always tried first
 var MixedProviders = func(code, name string) []model.Provider {
-	return []model.Provider{
-		CreateMockProvider(1, "HTTP_PROVIDER1", "http://provider1.com", "http", 30, []model.Supplier{
-			CreateMockSupplier(code, name),
-		}),
-		CreateMockProvider(2, "GRPC_PROVIDER1", "grpc://provider2.com:9090", "grpc", 70, []model.Supplier{
-			CreateMockSupplier(code, name),
-		}),
-	}
+	supplier := CreateMockSupplierWithStrategy(code, name, model.RoutingStrategyLeastCost)
+
+	httpProvider := CreateMockProvider(1, "HTTP_PROVIDER1", "http://provider1.com", "http", 30, []model.Supplier{supplier})
+	httpProvider.Discount = 2
+	grpcProvider := CreateMockProvider(2, "GRPC_PROVIDER1", "grpc://provider2.com:9090", "grpc", 70, []model.Supplier{supplier})
+	grpcProvider.Discount = 4
+	return []model.Provider{httpProvider, grpcProvider}
 }
 
 // Enhanced confirm order mock setup with provider type flexibility
-func SetupConfirmOrderMocksWithProviders(redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderID string, cachedOrder *schema.OrderResponse, providers []model.Provider, purchaseHistoryError error) {
+func SetupConfirmOrderMocksWithProviders(redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, orderID string, cachedOrder *schema.OrderResponse, providers []model.Provider, purchaseHistoryError error) {
 	providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(providers, nil)
-	redis.On("TryAcquireLock", mock.Anything, orderID, mock.AnythingOfType("time.Duration")).Return(nil)
-	redis.On("ReleaseLock", mock.Anything, orderID).Return(nil)
-	cachedOrderBytes, _ := json.Marshal(cachedOrder)
-	redis.On("Get", mock.Anything, "order_id"+orderID).Return(string(cachedOrderBytes), nil)
+	redis.On("TryAcquireLock", mock.Anything, orderID, mock.AnythingOfType("time.Duration")).Return(NewTestLock(orderID), nil)
+	redis.On("ReleaseLock", mock.Anything, LockOf(orderID)).Return(nil)
+	SetupStoredOrderMocks(orderRepo, cachedOrder)
 	redis.On("Set", mock.Anything, "order_id"+orderID, mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
+	redis.On("Publish", mock.Anything, "order_status:"+orderID, mock.AnythingOfType("[]uint8")).Return(nil)
+	SetupDefaultPurchaseHistoryProviderMocks(purchaseRepo)
+	SetupDefaultPaymentReferenceMocks(purchaseRepo)
 	if purchaseHistoryError != nil {
 		purchaseRepo.On("CreatePurchaseHistory", mock.Anything, mock.AnythingOfType("*model.PurchaseHistory")).Return(purchaseHistoryError)
 	} else {
@@ -182,13 +210,15 @@ func SetupConfirmOrderMocksWithProviders(redis *mockGrpc.RedisMock, providerRepo
 }
 
 // Helper to setup mocks with specific purchase history matcher
-func SetupConfirmOrderMocksWithMatcher(redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderID string, cachedOrder *schema.OrderResponse, providers []model.Provider, purchaseHistoryMatcher interface{}, purchaseHistoryError error) {
+func SetupConfirmOrderMocksWithMatcher(redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, orderID string, cachedOrder *schema.OrderResponse, providers []model.Provider, purchaseHistoryMatcher interface{}, purchaseHistoryError error) {
 	providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(providers, nil)
-	redis.On("TryAcquireLock", mock.Anything, orderID, mock.AnythingOfType("time.Duration")).Return(nil)
-	redis.On("ReleaseLock", mock.Anything, orderID).Return(nil)
-	cachedOrderBytes, _ := json.Marshal(cachedOrder)
-	redis.On("Get", mock.Anything, "order_id"+orderID).Return(string(cachedOrderBytes), nil)
+	redis.On("TryAcquireLock", mock.Anything, orderID, mock.AnythingOfType("time.Duration")).Return(NewTestLock(orderID), nil)
+	redis.On("ReleaseLock", mock.Anything, LockOf(orderID)).Return(nil)
+	SetupStoredOrderMocks(orderRepo, cachedOrder)
 	redis.On("Set", mock.Anything, "order_id"+orderID, mock.AnythingOfType("[]uint8"), mock.AnythingOfType("time.Duration")).Return(nil)
+	redis.On("Publish", mock.Anything, "order_status:"+orderID, mock.AnythingOfType("[]uint8")).Return(nil)
+	SetupDefaultPurchaseHistoryProviderMocks(purchaseRepo)
+	SetupDefaultPaymentReferenceMocks(purchaseRepo)
 	if purchaseHistoryError != nil {
 		purchaseRepo.On("CreatePurchaseHistory", mock.Anything, purchaseHistoryMatcher).Return(purchaseHistoryError)
 	} else {
@@ -197,49 +227,41 @@ func SetupConfirmOrderMocksWithMatcher(redis *mockGrpc.RedisMock, providerRepo *
 }
 
 // Simplified helper for basic confirm order test cases
-func SetupBasicConfirmOrderTest(redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderID string, confirmReq schema.OrderConfirmRequest, supplierCode, supplierName string, cashbackType model.CashBackType, cashbackValue int, providers []model.Provider) {
+func SetupBasicConfirmOrderTest(redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock, purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, orderID string, confirmReq schema.OrderConfirmRequest, supplierCode, supplierName string, cashbackType model.CashBackType, cashbackValue int, providers []model.Provider) {
 	cachedOrder := CreateCachedOrderResponse(confirmReq.OrderID, confirmReq.UserID, confirmReq.TotalPrice, confirmReq.PhoneNumber, confirmReq.CashBackValue, confirmReq.SkuID, supplierCode, supplierName, cashbackType, cashbackValue)
 
-	// Add random provider weight for mixed provider tests
-	if len(providers) > 1 && confirmReq.OrderID == 1001 {
-		cachedOrder.RandomProviderWeight = 50 // This should route to gRPC provider (weight range 30-100)
-	}
-
 	purchaseHistoryMatcher := mock.MatchedBy(func(ph *model.PurchaseHistory) bool {
-		return ph.OrderID == confirmReq.OrderID && ph.UserID == confirmReq.UserID && ph.Status == confirmReq.Status
+		return ph.OrderID == confirmReq.OrderID && ph.UserID == confirmReq.UserID && ph.Status == confirmReq.Status &&
+			ph.TotalPrice == cachedOrder.TotalPrice && ph.CashBackValue == cachedOrder.CashBackValue &&
+			ph.PaymentReference == confirmReq.PaymentReference
 	})
 
-	SetupConfirmOrderMocksWithMatcher(redis, providerRepo, purchaseRepo, orderID, cachedOrder, providers, purchaseHistoryMatcher, nil)
+	SetupConfirmOrderMocksWithMatcher(redis, providerRepo, purchaseRepo, orderRepo, orderID, cachedOrder, providers, purchaseHistoryMatcher, nil)
 }
 
 // Helper for error test cases that only need basic provider and lock setup
-func SetupErrorConfirmOrderTest(redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock, orderID string, lockError error, cacheError error, cachedData string) {
+func SetupErrorConfirmOrderTest(redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, orderID uint, lockError error, loadError error) {
 	providers := SingleProvider("VTL", "Viettel")
 	providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(providers, nil)
 
+	lockKey := fmt.Sprint(orderID)
 	if lockError != nil {
-		redis.On("TryAcquireLock", mock.Anything, orderID, mock.AnythingOfType("time.Duration")).Return(lockError)
+		redis.On("TryAcquireLock", mock.Anything, lockKey, mock.AnythingOfType("time.Duration")).Return(nil, lockError)
 		return
 	}
 
-	redis.On("TryAcquireLock", mock.Anything, orderID, mock.AnythingOfType("time.Duration")).Return(nil)
-	redis.On("ReleaseLock", mock.Anything, orderID).Return(nil)
-
-	if cacheError != nil {
-		redis.On("Get", mock.Anything, "order_id"+orderID).Return("", cacheError)
-	} else {
-		redis.On("Get", mock.Anything, "order_id"+orderID).Return(cachedData, nil)
-	}
+	redis.On("TryAcquireLock", mock.Anything, lockKey, mock.AnythingOfType("time.Duration")).Return(NewTestLock(lockKey), nil)
+	redis.On("ReleaseLock", mock.Anything, LockOf(lockKey)).Return(nil)
+	orderRepo.On("GetOrderByOrderID", mock.Anything, orderID).Return(nil, loadError)
 }
 
 // Helper for order mismatch test cases
-func SetupOrderMismatchTest(redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock, confirmReq schema.OrderConfirmRequest, orderID string, cachedOrder *schema.OrderResponse) {
+func SetupOrderMismatchTest(redis *mockGrpc.RedisMock, providerRepo *mockRepo.ProviderRepositoryMock, orderRepo *mockRepo.OrderRepositoryMock, confirmReq schema.OrderConfirmRequest, orderID string, cachedOrder *schema.OrderResponse) {
 	providers := SingleProvider("VTL", "Viettel")
 	providerRepo.On("GetProvidersWithSuppliers", mock.Anything).Return(providers, nil)
-	redis.On("TryAcquireLock", mock.Anything, orderID, mock.AnythingOfType("time.Duration")).Return(nil)
-	redis.On("ReleaseLock", mock.Anything, orderID).Return(nil)
-	cachedOrderJSON, _ := json.Marshal(cachedOrder)
-	redis.On("Get", mock.Anything, "order_id"+orderID).Return(string(cachedOrderJSON), nil)
+	redis.On("TryAcquireLock", mock.Anything, orderID, mock.AnythingOfType("time.Duration")).Return(NewTestLock(orderID), nil)
+	redis.On("ReleaseLock", mock.Anything, LockOf(orderID)).Return(nil)
+	SetupStoredOrderMocks(orderRepo, cachedOrder)
 }
 
 type GRPCClientSetup struct {
@@ -251,9 +273,144 @@ type GRPCClientSetup struct {
 func SetupGRPCMockClient(grpcClients *grpcClient.GRPCServiceClient, setup *GRPCClientSetup) {
 	mockGrpcClient := new(mockGrpc.ProviderGRPCClientMock)
 	if setup.ShouldError {
-		mockGrpcClient.On("ProcessOrder", mock.Anything, mock.AnythingOfType("*provider.OrderProcessRequest")).Return(errors.New(setup.ErrorMessage))
+		mockGrpcClient.On("ProcessOrder", mock.Anything, mock.AnythingOfType("*providerpb.OrderProcessRequest")).Return(errors.New(setup.ErrorMessage))
 	} else {
-		mockGrpcClient.On("ProcessOrder", mock.Anything, mock.AnythingOfType("*provider.OrderProcessRequest")).Return(nil)
+		mockGrpcClient.On("ProcessOrder", mock.Anything, mock.AnythingOfType("*providerpb.OrderProcessRequest")).Return(nil)
 	}
 	grpcClients.ProviderGRPCClients[setup.ProviderCode] = mockGrpcClient
 }
+
+// SetupTransactionMocks lets every WithinTransaction call run its callback
+func SetupTransactionMocks(txManager *mockRepo.TransactionManagerMock) {
+	txManager.On("WithinTransaction", mock.Anything).Return(nil).Maybe()
+}
+
+// SetupDefaultOrderRepoMocks accepts order writes, reports every order as missing from Postgres
+// and as assigned to the provider of SingleProvider
+func SetupDefaultOrderRepoMocks(orderRepo *mockRepo.OrderRepositoryMock) {
+	orderRepo.On("CreateOrder", mock.Anything, mock.AnythingOfType("*model.Order")).Return(nil).Maybe()
+	orderRepo.On("UpdateOrderStatusByOrderID", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	orderRepo.On("GetOrderByOrderID", mock.Anything, mock.Anything).Return(nil, gorm.ErrRecordNotFound).Maybe()
+	orderRepo.On("AssignOrderProvider", mock.Anything, mock.Anything, mock.AnythingOfType("string")).Return(nil).Maybe()
+	orderRepo.On("GetOrderProviderCode", mock.Anything, mock.Anything).Return("PROVIDER1", nil).Maybe()
+}
+
+// SetupDefaultPurchaseHistoryProviderMocks accepts the provider the dispatcher
+// records on the purchase history of an order
+func SetupDefaultPurchaseHistoryProviderMocks(purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock) {
+	purchaseRepo.On("UpdatePurchaseHistoryProviderByOrderID", mock.Anything, mock.Anything, mock.AnythingOfType("string")).Return(nil).Maybe()
+}
+
+// SetupDefaultPaymentReferenceMocks accepts the payment reference of every
+// confirmed order and batch
+func SetupDefaultPaymentReferenceMocks(purchaseRepo *mockRepo.PurchaseHistoryRepositoryMock) {
+	purchaseRepo.On("CreatePaymentReference", mock.Anything, mock.AnythingOfType("*model.PaymentReference")).Return(nil).Maybe()
+}
+
+// SetupDefaultOrderStatusEventMocks accepts every status event write
+func SetupDefaultOrderStatusEventMocks(eventRepo *mockRepo.OrderStatusEventRepositoryMock) {
+	eventRepo.On("CreateOrderStatusEvent", mock.Anything, mock.AnythingOfType("*model.OrderStatusEvent")).Return(nil).Maybe()
+}
+
+// SetupDefaultOutboxMocks accepts every outbox write
+func SetupDefaultOutboxMocks(outboxRepo *mockRepo.OutboxRepositoryMock) {
+	outboxRepo.On("CreateOutboxMessage", mock.Anything, mock.AnythingOfType("*model.OutboxMessage")).Return(nil).Maybe()
+}
+
+// SetupDefaultWebhookEventMocks accepts the partner webhook events written when an order settles
+func SetupDefaultWebhookEventMocks(outboxRepo *mockRepo.OutboxRepositoryMock) {
+	outboxRepo.On("CreateOutboxMessage", mock.Anything, mock.MatchedBy(func(message *model.OutboxMessage) bool {
+		return message.Channel == model.OutboxChannelWebhook
+	})).Return(nil).Maybe()
+}
+
+// SetupDefaultProviderAttemptMocks accepts every provider attempt write
+func SetupDefaultProviderAttemptMocks(attemptRepo *mockRepo.ProviderAttemptRepositoryMock) {
+	attemptRepo.On("CreateProviderAttempt", mock.Anything, mock.AnythingOfType("*model.ProviderAttempt")).Return(nil).Maybe()
+}
+
+// StoredOrder is the row of an order in Postgres that reads back as order
+func StoredOrder(order *schema.OrderResponse) *model.Order {
+	sku := model.Sku{
+		Model:        gorm.Model{ID: order.Sku.ID},
+		SupplierCode: order.Sku.SupplierInfo.Code,
+		Price:        order.Sku.Price,
+		Supplier: model.Supplier{
+			Code: order.Sku.SupplierInfo.Code,
+			Name: order.Sku.SupplierInfo.Name,
+		},
+	}
+	switch cashBack := order.Sku.CashBackInterface.(type) {
+	case *schema.CashBackPercentage:
+		sku.CashBack = model.CashBack{Code: cashBack.Code, Type: model.CashBackTypePercentage, Value: cashBack.Value}
+	case *schema.CashBackFixed:
+		sku.CashBack = model.CashBack{Code: cashBack.Code, Type: model.CashBackTypeFixed, Value: cashBack.Value}
+	}
+
+	stored := mapper.OrderFromOrderResponse(order)
+	stored.Sku = sku
+	for _, promotion := range order.Promotions {
+		redemption := mapper.PromotionRedemptionFromApplied(promotion, order.OrderID, order.UserID)
+		if promotion.Released {
+			releasedAt := time.Now()
+			redemption.ReleasedAt = &releasedAt
+		}
+		stored.Promotions = append(stored.Promotions, *redemption)
+	}
+	return stored
+}
+
+// SetupStoredOrderMocks serves order from Postgres, which status changes made
+// under the order lock are decided on
+func SetupStoredOrderMocks(orderRepo *mockRepo.OrderRepositoryMock, order *schema.OrderResponse) {
+	orderRepo.On("GetOrderByOrderID", mock.Anything, order.OrderID).Return(StoredOrder(order), nil)
+}
+
+// CreatePersistedOrder builds the Postgres row matching CreateCachedOrderResponse
+func CreatePersistedOrder(orderID, userID uint, totalPrice int, phoneNumber string, cashbackValue int, status model.PurchaseHistoryStatus, sku *model.Sku) *model.Order {
+	return &model.Order{
+		OrderID:       orderID,
+		UserID:        userID,
+		SkuID:         sku.ID,
+		TotalPrice:    totalPrice,
+		PhoneNumber:   phoneNumber,
+		CashBackValue: cashbackValue,
+		Status:        status,
+		Sku:           *sku,
+	}
+}
+
+// Ledger accounts handed out by SetupDefaultLedgerAccountMocks
+const (
+	TestWalletAccountID       uint = 10
+	TestCashBackAccountID     uint = 1
+	TestOrderPaymentAccountID uint = 2
+)
+
+// SetupDefaultLedgerAccountMocks hands out the wallet of every user and the system accounts
+func SetupDefaultLedgerAccountMocks(ledgerRepo *mockRepo.LedgerRepositoryMock) {
+	ledgerRepo.On("GetOrCreateWalletAccount", mock.Anything, mock.Anything).Return(&model.LedgerAccount{ID: TestWalletAccountID, Type: model.LedgerAccountTypeWallet}, nil).Maybe()
+	ledgerRepo.On("GetSystemAccount", mock.Anything, model.LedgerAccountTypeCashBack).Return(&model.LedgerAccount{ID: TestCashBackAccountID, Type: model.LedgerAccountTypeCashBack}, nil).Maybe()
+	ledgerRepo.On("GetSystemAccount", mock.Anything, model.LedgerAccountTypeOrderPayment).Return(&model.LedgerAccount{ID: TestOrderPaymentAccountID, Type: model.LedgerAccountTypeOrderPayment}, nil).Maybe()
+}
+
+// SetupDefaultPromotionMocks runs no promotion, orders only get the cash back of their sku
+func SetupDefaultPromotionMocks(promotionRepo *mockRepo.PromotionRepositoryMock) {
+	promotionRepo.On("GetAvailablePromotions", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("string")).Return([]model.Promotion{}, nil).Maybe()
+}
+
+// LedgerTransactionOf matches the transaction of an order moving walletAmount into the
+// wallet of SetupDefaultLedgerAccountMocks, a negative walletAmount moves it out
+func LedgerTransactionOf(transactionType model.LedgerTransactionType, orderID uint, walletAmount int) interface{} {
+	return mock.MatchedBy(func(transaction *model.LedgerTransaction) bool {
+		if transaction.Type != transactionType || transaction.OrderID != orderID || !transaction.Balanced() {
+			return false
+		}
+		for _, entry := range transaction.Entries {
+			if entry.AccountID == TestWalletAccountID {
+				return entry.Amount == walletAmount
+			}
+		}
+		return false
+	})
+}
@@ -0,0 +1,21 @@
+package validator
+
+import (
+	"testing"
+	"top-up-api/pkg/validator"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type phoneRequest struct {
+	PhoneNumber string `validate:"required,vnphone"`
+}
+
+func TestValidator_VNPhone(t *testing.T) {
+	v := validator.NewValidator()
+
+	assert.NoError(t, v.Validate(phoneRequest{PhoneNumber: "0981234567"}))
+	assert.NoError(t, v.Validate(phoneRequest{PhoneNumber: "+84 98 123 4567"}))
+	assert.EqualError(t, v.Validate(phoneRequest{PhoneNumber: "081234567890"}), "validation failed: [PhoneNumber must be a Vietnamese mobile number]")
+	assert.EqualError(t, v.Validate(phoneRequest{}), "validation failed: [PhoneNumber is a required field]")
+}